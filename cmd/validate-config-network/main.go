@@ -0,0 +1,70 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// validate-config-network lints config-network ConfigMaps before they are applied.
+//
+// Usage:
+//
+//	validate-config-network FILE...
+//
+// Each file must hold a single ConfigMap in YAML or JSON. All the problems found
+// are printed, and the command exits with a non-zero status if there is any.
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	corev1 "k8s.io/api/core/v1"
+	"knative.dev/networking/pkg/config"
+	"sigs.k8s.io/yaml"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprintf(os.Stderr, "Usage: %s FILE...\n", os.Args[0])
+		os.Exit(2)
+	}
+
+	failed := false
+	for _, file := range os.Args[1:] {
+		errs := validateFile(file)
+		for _, err := range errs {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", file, err)
+		}
+		failed = failed || len(errs) > 0
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// validateFile returns the problems found in the ConfigMap held by file.
+func validateFile(file string) []error {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return []error{err}
+	}
+	var cm corev1.ConfigMap
+	if err := yaml.Unmarshal(b, &cm); err != nil {
+		return []error{fmt.Errorf("failed to parse ConfigMap: %w", err)}
+	}
+	if cm.Name != config.ConfigMapName {
+		return []error{fmt.Errorf("ConfigMap is named %q, want: %q", cm.Name, config.ConfigMapName)}
+	}
+	return config.Validate(cm.Data)
+}
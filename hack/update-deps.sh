@@ -21,3 +21,17 @@ set -o pipefail
 source $(dirname "$0")/../vendor/knative.dev/hack/library.sh
 
 go_update_deps "$@"
+
+# The HTTP/3 prober is a module of its own, as quic-go requires Go 1.26 while
+# the rest of the repository builds with Go 1.18.
+group "Go mod tidy pkg/prober/http3"
+(cd "${REPO_ROOT_DIR}/pkg/prober/http3" && GOFLAGS=-mod=mod go mod tidy)
+
+# Its replace directive doesn't apply to its dependents, so the version of
+# knative.dev/networking it requires must be published.
+group "Checking the knative.dev/networking version required by pkg/prober/http3"
+networking_version="$(cd "${REPO_ROOT_DIR}/pkg/prober/http3" && \
+  GOFLAGS=-mod=mod go list -m -f '{{.Version}}' knative.dev/networking)"
+(cd "$(mktemp -d)" && GOFLAGS=-mod=mod GOWORK=off \
+  go mod download "knative.dev/networking@${networking_version}") || \
+  abort "pkg/prober/http3 requires knative.dev/networking@${networking_version}, which is not published"
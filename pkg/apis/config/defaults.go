@@ -18,6 +18,7 @@ package config
 
 import (
 	"fmt"
+	"strings"
 
 	corev1 "k8s.io/api/core/v1"
 
@@ -49,6 +50,7 @@ func NewDefaultsConfigFromMap(data map[string]string) (*Defaults, error) {
 	if err := cm.Parse(data,
 		cm.AsInt64("revision-timeout-seconds", &nc.RevisionTimeoutSeconds),
 		cm.AsInt64("max-revision-timeout-seconds", &nc.MaxRevisionTimeoutSeconds),
+		asDomains("cluster-local-domain-aliases", &nc.ClusterLocalDomainAliases),
 	); err != nil {
 		return nil, err
 	}
@@ -59,6 +61,25 @@ func NewDefaultsConfigFromMap(data map[string]string) (*Defaults, error) {
 	return nc, nil
 }
 
+// asDomains parses the value at key as a comma separated list of domains into
+// the target, if it exists.
+func asDomains(key string, target *[]string) cm.ParseFunc {
+	return func(data map[string]string) error {
+		raw, ok := data[key]
+		if !ok {
+			return nil
+		}
+		var domains []string
+		for _, d := range strings.Split(raw, ",") {
+			if d = strings.Trim(strings.TrimSpace(d), "."); d != "" {
+				domains = append(domains, d)
+			}
+		}
+		*target = domains
+		return nil
+	}
+}
+
 // NewDefaultsConfigFromConfigMap creates a Defaults from the supplied configMap.
 func NewDefaultsConfigFromConfigMap(config *corev1.ConfigMap) (*Defaults, error) {
 	return NewDefaultsConfigFromMap(config.Data)
@@ -70,4 +91,10 @@ type Defaults struct {
 	// This is the timeout set for ingress.
 	// RevisionTimeoutSeconds must be less than this value.
 	MaxRevisionTimeoutSeconds int64
+
+	// ClusterLocalDomainAliases are the domains, besides the cluster domain,
+	// whose hosts are only reachable from within the cluster. The visibility
+	// of Ingress rules whose hosts are all in these domains defaults to
+	// ClusterLocal.
+	ClusterLocalDomainAliases []string
 }
@@ -24,6 +24,11 @@ package config
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Defaults) DeepCopyInto(out *Defaults) {
 	*out = *in
+	if in.ClusterLocalDomainAliases != nil {
+		in, out := &in.ClusterLocalDomainAliases, &out.ClusterLocalDomainAliases
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 	return
 }
 
@@ -46,3 +46,56 @@ func (p ProtocolType) Validate(context.Context) *apis.FieldError {
 	}
 	return apis.ErrInvalidValue(p, apis.CurrentField)
 }
+
+// DataplaneTrust is the level of trust between the components of the data
+// plane, i.e. how the hops of the traffic inside the cluster, from the
+// gateways to the activator and the queue-proxies, are secured.
+type DataplaneTrust string
+
+const (
+	// DataplaneTrustEdge terminates TLS at the edge of the cluster, the
+	// internal hops being unencrypted.
+	DataplaneTrustEdge DataplaneTrust = "edge"
+	// DataplaneTrustIdentity encrypts the internal hops, the clients of each
+	// hop verifying the identity of the server.
+	DataplaneTrustIdentity DataplaneTrust = "identity"
+	// DataplaneTrustMutual encrypts the internal hops with mutual TLS, the
+	// servers of each hop verifying the identity of the client too.
+	DataplaneTrustMutual DataplaneTrust = "mutual"
+)
+
+// dataplaneTrustRanks orders the DataplaneTrust levels.
+var dataplaneTrustRanks = map[DataplaneTrust]int{
+	DataplaneTrustEdge:     1,
+	DataplaneTrustIdentity: 2,
+	DataplaneTrustMutual:   3,
+}
+
+// Validate validates that DataplaneTrust has a correct enum value.
+func (t DataplaneTrust) Validate(context.Context) *apis.FieldError {
+	if _, ok := dataplaneTrustRanks[t]; ok {
+		return nil
+	}
+	if t == "" {
+		return apis.ErrMissingField(apis.CurrentField)
+	}
+	return apis.ErrInvalidValue(t, apis.CurrentField)
+}
+
+// AtLeast returns whether t is at least as strict as other. Unknown levels
+// are less strict than all the known ones.
+func (t DataplaneTrust) AtLeast(other DataplaneTrust) bool {
+	return dataplaneTrustRanks[t] >= dataplaneTrustRanks[other]
+}
+
+// EncryptsInternalHops returns whether the hops inside the cluster are
+// encrypted at level t.
+func (t DataplaneTrust) EncryptsInternalHops() bool {
+	return t.AtLeast(DataplaneTrustIdentity)
+}
+
+// VerifiesClients returns whether the servers of the hops inside the cluster
+// verify the identity of their clients at level t.
+func (t DataplaneTrust) VerifiesClients() bool {
+	return t.AtLeast(DataplaneTrustMutual)
+}
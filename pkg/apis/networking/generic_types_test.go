@@ -54,3 +54,71 @@ func TestProtocolTypeValidate(t *testing.T) {
 		})
 	}
 }
+
+func TestDataplaneTrustValidate(t *testing.T) {
+	cases := []struct {
+		name   string
+		trust  DataplaneTrust
+		expect *apis.FieldError
+	}{{
+		name:   "no trust",
+		expect: apis.ErrMissingField(apis.CurrentField),
+	}, {
+		name:   "invalid trust",
+		trust:  "total",
+		expect: apis.ErrInvalidValue("total", apis.CurrentField),
+	}, {
+		name:  "edge",
+		trust: DataplaneTrustEdge,
+	}, {
+		name:  "identity",
+		trust: DataplaneTrustIdentity,
+	}, {
+		name:  "mutual",
+		trust: DataplaneTrustMutual,
+	}}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got, want := c.trust.Validate(context.Background()), c.expect; !reflect.DeepEqual(got, want) {
+				t.Errorf("got = %v, want: %v", got, want)
+			}
+		})
+	}
+}
+
+func TestDataplaneTrustLevels(t *testing.T) {
+	cases := []struct {
+		trust      DataplaneTrust
+		encrypts   bool
+		verifies   bool
+		atLeastAll bool
+	}{{
+		trust: "unknown",
+	}, {
+		trust: DataplaneTrustEdge,
+	}, {
+		trust:    DataplaneTrustIdentity,
+		encrypts: true,
+	}, {
+		trust:      DataplaneTrustMutual,
+		encrypts:   true,
+		verifies:   true,
+		atLeastAll: true,
+	}}
+	for _, c := range cases {
+		t.Run(string(c.trust), func(t *testing.T) {
+			if got := c.trust.EncryptsInternalHops(); got != c.encrypts {
+				t.Errorf("EncryptsInternalHops() = %v, want: %v", got, c.encrypts)
+			}
+			if got := c.trust.VerifiesClients(); got != c.verifies {
+				t.Errorf("VerifiesClients() = %v, want: %v", got, c.verifies)
+			}
+			if !c.trust.AtLeast(c.trust) {
+				t.Errorf("AtLeast(%q) = false, want: true", c.trust)
+			}
+			if got := c.trust.AtLeast(DataplaneTrustMutual); got != c.atLeastAll {
+				t.Errorf("AtLeast(%q) = %v, want: %v", DataplaneTrustMutual, got, c.atLeastAll)
+			}
+		})
+	}
+}
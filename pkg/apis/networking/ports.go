@@ -38,6 +38,36 @@ const (
 
 	// ServicePortNameHTTPS is the name of the external port of the service for HTTPS
 	ServicePortNameHTTPS = "https"
+
+	// BackendHTTPPort is the backend, i.e. `targetPort`, of the Serving and
+	// Activator K8s services for HTTP/1 endpoints.
+	BackendHTTPPort = 8012
+
+	// BackendHTTP2Port is the backend, i.e. `targetPort`, of the Serving and
+	// Activator K8s services for HTTP/2 endpoints.
+	BackendHTTP2Port = 8013
+
+	// QueueAdminPort is the port of the health checks and lifecycle hooks of
+	// the queue-proxy.
+	QueueAdminPort = 8022
+
+	// QueueAdminPortName is the name of the QueueAdminPort port.
+	QueueAdminPortName = "http-queueadm"
+
+	// AutoscalingQueueMetricsPort is the port of the metrics the queue-proxy
+	// emits for the autoscaler.
+	AutoscalingQueueMetricsPort = 9090
+
+	// AutoscalingQueueMetricsPortName is the name of the
+	// AutoscalingQueueMetricsPort port.
+	AutoscalingQueueMetricsPortName = "http-autometric"
+
+	// UserQueueMetricsPort is the port of the metrics the queue-proxy emits
+	// for the end user.
+	UserQueueMetricsPort = 9091
+
+	// UserQueueMetricsPortName is the name of the UserQueueMetricsPort port.
+	UserQueueMetricsPortName = "http-usermetric"
 )
 
 // ServicePortName returns the port for the app level protocol.
@@ -55,3 +85,12 @@ func ServicePort(proto ProtocolType) int {
 	}
 	return ServiceHTTPPort
 }
+
+// BackendPort chooses the backend port, i.e. `targetPort`, of the services for
+// the app level protocol.
+func BackendPort(proto ProtocolType) int {
+	if proto == ProtocolH2C {
+		return BackendHTTP2Port
+	}
+	return BackendHTTPPort
+}
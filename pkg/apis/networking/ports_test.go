@@ -65,3 +65,26 @@ func TestServicePort(t *testing.T) {
 		})
 	}
 }
+
+func TestBackendPort(t *testing.T) {
+	cases := []struct {
+		name   string
+		proto  ProtocolType
+		expect int
+	}{{
+		name:   "pass h2c protocol to get the backend port for HTTP/2 endpoints",
+		proto:  ProtocolH2C,
+		expect: BackendHTTP2Port,
+	}, {
+		name:   "pass any protocol to get the backend port for HTTP/1 endpoints",
+		proto:  ProtocolHTTP1,
+		expect: BackendHTTPPort,
+	}}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got, want := BackendPort(c.proto), c.expect; !(got == want) {
+				t.Errorf("got = %d, want: %d", got, want)
+			}
+		})
+	}
+}
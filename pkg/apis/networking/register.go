@@ -16,7 +16,11 @@ limitations under the License.
 
 package networking
 
-import "knative.dev/pkg/kmap"
+import (
+	"strings"
+
+	"knative.dev/pkg/kmap"
+)
 
 const (
 	// GroupName is the name for the networking API group.
@@ -37,6 +41,16 @@ const (
 	// to indicate the namespace of the origin secret that the TLS secret is copied from.
 	OriginSecretNamespaceLabelKey = GroupName + "/originSecretNamespace"
 
+	// SKSLabelKey is the label key attached to the K8s Services and
+	// Endpoints of a ServerlessService, whose value is the name of the
+	// ServerlessService.
+	SKSLabelKey = GroupName + "/serverlessservice"
+
+	// ServiceTypeKey is the label key attached to the K8s Services and
+	// Endpoints of a ServerlessService telling whether they are the public or
+	// the private ones, see ServiceType.
+	ServiceTypeKey = GroupName + "/serviceType"
+
 	// RolloutAnnotationKey is the annotation key for storing
 	// the rollout state in the Annotations of the Kingress or Route.Status.
 	RolloutAnnotationKey = GroupName + "/rollout"
@@ -113,6 +127,26 @@ const (
 	// already using labels for domain, it probably best to keep this
 	// consistent.
 	VisibilityLabelKey = PublicGroupName + "/visibility"
+
+	// TrustBundleLabelKey is the label key of the ConfigMaps and Secrets
+	// whose CA certificates are merged into the trust bundle of the data
+	// plane, when set to "true".
+	TrustBundleLabelKey = PublicGroupName + "/trust-bundle"
+)
+
+// ServiceType is the type of the K8s Services of a ServerlessService, the
+// value of the ServiceTypeKey label.
+type ServiceType string
+
+const (
+	// ServiceTypePrivate is the type of the Service load balancing over the
+	// pods of the revision.
+	ServiceTypePrivate ServiceType = "Private"
+
+	// ServiceTypePublic is the type of the Service load balancing over the
+	// pods of the revision or the activators, depending on the mode of the
+	// ServerlessService.
+	ServiceTypePublic ServiceType = "Public"
 )
 
 // Pseudo-constants
@@ -145,6 +179,25 @@ func GetIngressClass(annotations map[string]string) (val string) {
 	return IngressClassAnnotation.Value(annotations)
 }
 
+// GetIngressClasses returns the Ingress classes listed by the ingress class
+// annotation, in order of preference. The annotation holds either a single
+// class or a comma-separated list of classes, the first one available being
+// used, e.g. to migrate the resources from an implementation to another
+// without rewriting them. It returns nil if the annotation is not set.
+func GetIngressClasses(annotations map[string]string) []string {
+	val := GetIngressClass(annotations)
+	if val == "" {
+		return nil
+	}
+	var classes []string
+	for _, class := range strings.Split(val, ",") {
+		if class = strings.TrimSpace(class); class != "" {
+			classes = append(classes, class)
+		}
+	}
+	return classes
+}
+
 func GetCertificateClass(annotations map[string]string) (val string) {
 	return CertificateClassAnnotation.Value(annotations)
 }
@@ -0,0 +1,55 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package networking
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestGetIngressClasses(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		want        []string
+	}{{
+		name: "no annotation",
+	}, {
+		name:        "single class",
+		annotations: map[string]string{IngressClassAnnotationKey: "istio.ingress.networking.knative.dev"},
+		want:        []string{"istio.ingress.networking.knative.dev"},
+	}, {
+		name: "list of classes",
+		annotations: map[string]string{
+			IngressClassAnnotationKey: "kourier.ingress.networking.knative.dev, istio.ingress.networking.knative.dev,",
+		},
+		want: []string{"kourier.ingress.networking.knative.dev", "istio.ingress.networking.knative.dev"},
+	}, {
+		name:        "alternative key",
+		annotations: map[string]string{IngressClassAnnotationAltKey: "kourier.ingress.networking.knative.dev,istio.ingress.networking.knative.dev"},
+		want:        []string{"kourier.ingress.networking.knative.dev", "istio.ingress.networking.knative.dev"},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := GetIngressClasses(test.annotations); !cmp.Equal(got, test.want) {
+				t.Error("GetIngressClasses (-want, +got) =", cmp.Diff(test.want, got))
+			}
+		})
+	}
+}
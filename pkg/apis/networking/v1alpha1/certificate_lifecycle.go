@@ -34,19 +34,45 @@ func (cs *CertificateStatus) MarkReady() {
 }
 
 // MarkNotReady marks the certificate status as unknown.
+// Prefer the helpers using one of the CertificateReason* reasons.
 func (cs *CertificateStatus) MarkNotReady(reason, message string) {
 	certificateCondSet.Manage(cs).MarkUnknown(CertificateConditionReady, reason, message)
 }
 
 // MarkFailed marks the certificate as not ready.
+// Prefer the helpers using one of the CertificateReason* reasons.
 func (cs *CertificateStatus) MarkFailed(reason, message string) {
 	certificateCondSet.Manage(cs).MarkFalse(CertificateConditionReady, reason, message)
 }
 
+// MarkNotYetIssued marks the certificate status as unknown while the
+// certificate is being issued.
+func (cs *CertificateStatus) MarkNotYetIssued(message string) {
+	cs.MarkNotReady(CertificateReasonNotYetIssued, message)
+}
+
+// MarkRenewing marks the certificate status as unknown while the
+// certificate is being renewed.
+func (cs *CertificateStatus) MarkRenewing(message string) {
+	cs.MarkNotReady(CertificateReasonRenewing, message)
+}
+
+// MarkIssuanceFailed marks the certificate as not ready because it could
+// not be issued, e.g. the ACME challenge failed.
+func (cs *CertificateStatus) MarkIssuanceFailed(message string) {
+	cs.MarkFailed(CertificateReasonIssuanceFailed, message)
+}
+
+// MarkExpired marks the certificate as not ready because it expired
+// without being renewed.
+func (cs *CertificateStatus) MarkExpired(message string) {
+	cs.MarkFailed(CertificateReasonExpired, message)
+}
+
 // MarkResourceNotOwned changes the ready condition to false to reflect that we don't own the
 // resource of the given kind and name.
 func (cs *CertificateStatus) MarkResourceNotOwned(kind, name string) {
-	certificateCondSet.Manage(cs).MarkFalse(CertificateConditionReady, "NotOwned",
+	cs.MarkFailed(CertificateReasonNotOwned,
 		fmt.Sprintf("There is an existing %s %q that we do not own.", kind, name))
 }
 
@@ -70,6 +96,31 @@ const (
 	CertificateConditionReady = apis.ConditionReady
 )
 
+// Reasons of the Ready condition of a Certificate. Certificate implementations
+// use them so that consumers can tell the states of certificates apart
+// regardless of the implementation.
+const (
+	// CertificateReasonNotYetIssued is the reason of an unknown Ready condition
+	// while the certificate is being issued.
+	CertificateReasonNotYetIssued = "NotYetIssued"
+
+	// CertificateReasonRenewing is the reason of an unknown Ready condition
+	// while the certificate is being renewed.
+	CertificateReasonRenewing = "Renewing"
+
+	// CertificateReasonIssuanceFailed is the reason of a false Ready condition
+	// when the certificate could not be issued.
+	CertificateReasonIssuanceFailed = "IssuanceFailed"
+
+	// CertificateReasonExpired is the reason of a false Ready condition when
+	// the certificate expired.
+	CertificateReasonExpired = "Expired"
+
+	// CertificateReasonNotOwned is the reason of a false Ready condition when
+	// a resource needed by the certificate exists but is not owned by it.
+	CertificateReasonNotOwned = "NotOwned"
+)
+
 var certificateCondSet = apis.NewLivingConditionSet(CertificateConditionReady)
 
 // GetConditionSet retrieves the condition set for this resource. Implements the KRShaped interface.
@@ -98,6 +98,60 @@ func TestMarkResourceNotOwned(t *testing.T) {
 	apistest.CheckConditionFailed(c, CertificateConditionReady, t)
 }
 
+func TestMarkWithReason(t *testing.T) {
+	tests := []struct {
+		name       string
+		mark       func(*CertificateStatus)
+		wantStatus corev1.ConditionStatus
+		wantReason string
+	}{{
+		name:       "not yet issued",
+		mark:       func(cs *CertificateStatus) { cs.MarkNotYetIssued("waiting for the challenge") },
+		wantStatus: corev1.ConditionUnknown,
+		wantReason: CertificateReasonNotYetIssued,
+	}, {
+		name:       "renewing",
+		mark:       func(cs *CertificateStatus) { cs.MarkRenewing("renewing") },
+		wantStatus: corev1.ConditionUnknown,
+		wantReason: CertificateReasonRenewing,
+	}, {
+		name:       "issuance failed",
+		mark:       func(cs *CertificateStatus) { cs.MarkIssuanceFailed("challenge failed") },
+		wantStatus: corev1.ConditionFalse,
+		wantReason: CertificateReasonIssuanceFailed,
+	}, {
+		name:       "expired",
+		mark:       func(cs *CertificateStatus) { cs.MarkExpired("expired yesterday") },
+		wantStatus: corev1.ConditionFalse,
+		wantReason: CertificateReasonExpired,
+	}, {
+		name:       "not owned",
+		mark:       func(cs *CertificateStatus) { cs.MarkResourceNotOwned("Secret", "cert") },
+		wantStatus: corev1.ConditionFalse,
+		wantReason: CertificateReasonNotOwned,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cs := &CertificateStatus{}
+			cs.InitializeConditions()
+			test.mark(cs)
+			cond := cs.GetCondition(CertificateConditionReady)
+			if cond.Status != test.wantStatus {
+				t.Errorf("Status = %v, want: %v", cond.Status, test.wantStatus)
+			}
+			if cond.Reason != test.wantReason {
+				t.Errorf("Reason = %q, want: %q", cond.Reason, test.wantReason)
+			}
+
+			cs.MarkReady()
+			if c := (&Certificate{Status: *cs}); !c.IsReady() {
+				t.Error("IsReady() = false after MarkReady")
+			}
+		})
+	}
+}
+
 func TestGetCondition(t *testing.T) {
 	c := &CertificateStatus{}
 	c.InitializeConditions()
@@ -118,6 +118,60 @@ type HTTP01Challenge struct {
 
 	// ServicePort is the port of the service to serve HTTP01 challenge requests.
 	ServicePort intstr.IntOrString `json:"servicePort,omitempty"`
+
+	// Path is the path that the HTTP01 challenge is expected to serve on.
+	// If empty, the path of URL is used.
+	// +optional
+	Path string `json:"path,omitempty"`
+
+	// Token is the token of the HTTP01 challenge.
+	// +optional
+	Token string `json:"token,omitempty"`
+}
+
+// Host returns the host that the HTTP01 challenge is expected to serve on.
+func (c *HTTP01Challenge) Host() string {
+	if c.URL == nil {
+		return ""
+	}
+	return c.URL.Host
+}
+
+// ChallengePath returns the path that the HTTP01 challenge is expected to serve on.
+func (c *HTTP01Challenge) ChallengePath() string {
+	if c.Path != "" || c.URL == nil {
+		return c.Path
+	}
+	return c.URL.Path
+}
+
+// HTTPIngressPath returns an Ingress path routing all the HTTP01 challenge
+// requests to the service serving them.
+func (c *HTTP01Challenge) HTTPIngressPath() HTTPIngressPath {
+	return HTTPIngressPath{
+		Path: c.ChallengePath(),
+		Splits: []IngressBackendSplit{{
+			IngressBackend: IngressBackend{
+				ServiceNamespace: c.ServiceNamespace,
+				ServiceName:      c.ServiceName,
+				ServicePort:      c.ServicePort,
+			},
+			Percent: 100,
+		}},
+	}
+}
+
+// HTTP01ChallengePaths returns the Ingress paths serving the given HTTP01
+// challenges, keyed by the host they need to be served on. A host carries
+// several paths when multiple challenges are pending for it, e.g. for a
+// wildcard and its apex domain.
+func HTTP01ChallengePaths(challenges []HTTP01Challenge) map[string][]HTTPIngressPath {
+	paths := make(map[string][]HTTPIngressPath, len(challenges))
+	for i := range challenges {
+		c := &challenges[i]
+		paths[c.Host()] = append(paths[c.Host()], c.HTTPIngressPath())
+	}
+	return paths
 }
 
 // GetStatus retrieves the status of the Certificate. Implements the KRShaped interface.
@@ -17,6 +17,10 @@ package v1alpha1
 
 import (
 	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"knative.dev/pkg/apis"
 )
 
 func TestCertificateGetStatus(t *testing.T) {
@@ -28,3 +32,91 @@ func TestCertificateGetStatus(t *testing.T) {
 		t.Errorf("GetStatus=%v, want=%v", got, want)
 	}
 }
+
+func TestHTTP01ChallengePaths(t *testing.T) {
+	challenge := func(host, path, token string) HTTP01Challenge {
+		return HTTP01Challenge{
+			URL: &apis.URL{
+				Scheme: "http",
+				Host:   host,
+				Path:   "/.well-known/acme-challenge/" + token,
+			},
+			Path:             path,
+			Token:            token,
+			ServiceName:      "solver-" + token,
+			ServiceNamespace: "solvers",
+			ServicePort:      intstr.FromInt(8090),
+		}
+	}
+	path := func(path, token string) HTTPIngressPath {
+		return HTTPIngressPath{
+			Path: path,
+			Splits: []IngressBackendSplit{{
+				IngressBackend: IngressBackend{
+					ServiceName:      "solver-" + token,
+					ServiceNamespace: "solvers",
+					ServicePort:      intstr.FromInt(8090),
+				},
+				Percent: 100,
+			}},
+		}
+	}
+
+	tests := []struct {
+		name       string
+		challenges []HTTP01Challenge
+		want       map[string][]HTTPIngressPath
+	}{{
+		name: "no challenges",
+		want: map[string][]HTTPIngressPath{},
+	}, {
+		name: "one challenge per host",
+		challenges: []HTTP01Challenge{
+			challenge("foo.example.com", "", "foo"),
+			challenge("bar.example.com", "", "bar"),
+		},
+		want: map[string][]HTTPIngressPath{
+			"foo.example.com": {path("/.well-known/acme-challenge/foo", "foo")},
+			"bar.example.com": {path("/.well-known/acme-challenge/bar", "bar")},
+		},
+	}, {
+		name: "multiple challenges for a host",
+		challenges: []HTTP01Challenge{
+			challenge("example.com", "", "apex"),
+			challenge("example.com", "", "wildcard"),
+		},
+		want: map[string][]HTTPIngressPath{
+			"example.com": {
+				path("/.well-known/acme-challenge/apex", "apex"),
+				path("/.well-known/acme-challenge/wildcard", "wildcard"),
+			},
+		},
+	}, {
+		name: "explicit path",
+		challenges: []HTTP01Challenge{
+			challenge("example.com", "/solve/me", "foo"),
+		},
+		want: map[string][]HTTPIngressPath{
+			"example.com": {path("/solve/me", "foo")},
+		},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := HTTP01ChallengePaths(test.challenges)
+			if !cmp.Equal(got, test.want) {
+				t.Error("HTTP01ChallengePaths (-want, +got):", cmp.Diff(test.want, got))
+			}
+		})
+	}
+}
+
+func TestHTTP01ChallengeWithoutURL(t *testing.T) {
+	c := &HTTP01Challenge{Path: "/path"}
+	if got := c.Host(); got != "" {
+		t.Errorf("Host() = %q, want empty", got)
+	}
+	if got, want := c.ChallengePath(), "/path"; got != want {
+		t.Errorf("ChallengePath() = %q, want: %q", got, want)
+	}
+}
@@ -18,8 +18,12 @@ package v1alpha1
 
 import (
 	"context"
+	"net/http"
+	"strings"
 
+	"knative.dev/networking/pkg/apis/config"
 	"knative.dev/pkg/apis"
+	"knative.dev/pkg/network"
 )
 
 // SetDefaults populates default values in Ingress
@@ -38,14 +42,63 @@ func (is *IngressSpec) SetDefaults(ctx context.Context) {
 }
 
 // SetDefaults populates default values in IngressTLS
-func (t *IngressTLS) SetDefaults(ctx context.Context) {}
+func (t *IngressTLS) SetDefaults(ctx context.Context) {
+	normalizeHosts(t.Hosts)
+}
 
 // SetDefaults populates default values in IngressRule
 func (r *IngressRule) SetDefaults(ctx context.Context) {
+	normalizeHosts(r.Hosts)
 	if r.Visibility == "" {
 		r.Visibility = IngressVisibilityExternalIP
+		if allClusterLocalHosts(ctx, r.Hosts) {
+			r.Visibility = IngressVisibilityClusterLocal
+		}
+	}
+	if r.HTTP != nil {
+		r.HTTP.SetDefaults(ctx)
+	}
+	for i := range r.Redirects {
+		if r.Redirects[i].StatusCode == 0 {
+			r.Redirects[i].StatusCode = http.StatusMovedPermanently
+		}
+	}
+}
+
+// normalizeHosts replaces the hosts with their canonical form, e.g.
+// lowercasing them, leaving the invalid hosts for the validation to reject.
+func normalizeHosts(hosts []string) {
+	for i, host := range hosts {
+		if normalized, err := normalizeHost(host); err == nil {
+			hosts[i] = normalized
+		}
+	}
+}
+
+// allClusterLocalHosts returns whether there are hosts and all of them are
+// cluster-local.
+func allClusterLocalHosts(ctx context.Context, hosts []string) bool {
+	for _, host := range hosts {
+		if !isClusterLocalHost(ctx, host) {
+			return false
+		}
+	}
+	return len(hosts) > 0
+}
+
+// isClusterLocalHost returns whether host is in the cluster domain, e.g.
+// `foo.ns.svc.cluster.local`, or in one of its configured aliases.
+// Short names like `foo.ns` can't be told apart from external hosts, and are
+// not considered cluster-local.
+func isClusterLocalHost(ctx context.Context, host string) bool {
+	domains := append([]string{"svc", network.GetClusterDomainName()},
+		config.FromContextOrDefaults(ctx).Defaults.ClusterLocalDomainAliases...)
+	for _, d := range domains {
+		if strings.HasSuffix(host, "."+d) {
+			return true
+		}
 	}
-	r.HTTP.SetDefaults(ctx)
+	return false
 }
 
 // SetDefaults populates default values in HTTPIngressRuleValue
@@ -57,8 +110,54 @@ func (h *HTTPIngressRuleValue) SetDefaults(ctx context.Context) {
 
 // SetDefaults populates default values in HTTPIngressPath
 func (h *HTTPIngressPath) SetDefaults(ctx context.Context) {
-	// If only one split is specified, we default to 100.
-	if len(h.Splits) == 1 && h.Splits[0].Percent == 0 {
-		h.Splits[0].Percent = 100
+	// If only one split is not selected by headers, we default it to 100, as
+	// the splits selected by headers don't take part in the percentage-based
+	// distribution.
+	only := -1
+	for i := range h.Splits {
+		if len(h.Splits[i].Headers) > 0 {
+			continue
+		}
+		if only >= 0 {
+			only = -1
+			break
+		}
+		only = i
+	}
+	if only >= 0 && h.Splits[only].Percent == 0 {
+		h.Splits[only].Percent = 100
+	}
+	canonicalizeHeaders(h.AppendHeaders)
+	canonicalizeHeaders(h.SetHeaders)
+	canonicalizeHeaderNames(h.RemoveHeaders)
+	for i := range h.Splits {
+		canonicalizeHeaders(h.Splits[i].AppendHeaders)
+		canonicalizeHeaders(h.Splits[i].SetHeaders)
+		canonicalizeHeaderNames(h.Splits[i].RemoveHeaders)
+	}
+}
+
+// canonicalizeHeaders canonicalizes the names of the headers, e.g. `x-foo`
+// into `X-Foo`. The names conflicting with another one once canonicalized
+// are left as is, for the validation to report them.
+func canonicalizeHeaders(headers map[string]string) {
+	for name, value := range headers {
+		canonical := http.CanonicalHeaderKey(name)
+		if canonical == name {
+			continue
+		}
+		if _, ok := headers[canonical]; ok {
+			continue
+		}
+		delete(headers, name)
+		headers[canonical] = value
+	}
+}
+
+// canonicalizeHeaderNames canonicalizes the header names in place, e.g.
+// `x-foo` into `X-Foo`.
+func canonicalizeHeaderNames(names []string) {
+	for i, name := range names {
+		names[i] = http.CanonicalHeaderKey(name)
 	}
 }
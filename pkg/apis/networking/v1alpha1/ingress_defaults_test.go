@@ -18,10 +18,12 @@ package v1alpha1
 
 import (
 	"context"
+	"net/http"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
 	"k8s.io/apimachinery/pkg/util/intstr"
+	"knative.dev/networking/pkg/apis/config"
 )
 
 func TestIngressDefaulting(t *testing.T) {
@@ -149,3 +151,229 @@ func TestIngressDefaulting(t *testing.T) {
 	}
 
 }
+
+func TestIngressRuleVisibilityDefaulting(t *testing.T) {
+	aliases := config.ToContext(context.Background(), &config.Config{
+		Defaults: &config.Defaults{ClusterLocalDomainAliases: []string{"internal.corp"}},
+	})
+
+	tests := []struct {
+		name  string
+		ctx   context.Context
+		hosts []string
+		in    IngressVisibility
+		want  IngressVisibility
+	}{{
+		name: "no hosts",
+		want: IngressVisibilityExternalIP,
+	}, {
+		name:  "external hosts",
+		hosts: []string{"foo.example.com"},
+		want:  IngressVisibilityExternalIP,
+	}, {
+		name:  "cluster-local hosts",
+		hosts: []string{"foo.ns.svc", "foo.ns.svc.cluster.local"},
+		want:  IngressVisibilityClusterLocal,
+	}, {
+		name:  "short names",
+		hosts: []string{"foo.ns", "foo.ns.svc.cluster.local"},
+		want:  IngressVisibilityExternalIP,
+	}, {
+		name:  "mixed hosts",
+		hosts: []string{"foo.example.com", "foo.ns.svc.cluster.local"},
+		want:  IngressVisibilityExternalIP,
+	}, {
+		name:  "alias without config",
+		hosts: []string{"foo.internal.corp"},
+		want:  IngressVisibilityExternalIP,
+	}, {
+		name:  "alias",
+		ctx:   aliases,
+		hosts: []string{"foo.internal.corp", "foo.ns.svc.cluster.local"},
+		want:  IngressVisibilityClusterLocal,
+	}, {
+		name:  "explicit visibility is kept",
+		hosts: []string{"foo.ns.svc.cluster.local"},
+		in:    IngressVisibilityExternalIP,
+		want:  IngressVisibilityExternalIP,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := test.ctx
+			if ctx == nil {
+				ctx = context.Background()
+			}
+			r := &IngressRule{
+				Hosts:      test.hosts,
+				Visibility: test.in,
+				HTTP:       &HTTPIngressRuleValue{},
+			}
+			r.SetDefaults(ctx)
+			if r.Visibility != test.want {
+				t.Errorf("Visibility = %s, want: %s", r.Visibility, test.want)
+			}
+		})
+	}
+}
+
+func TestIngressHostsDefaulting(t *testing.T) {
+	ing := &Ingress{
+		Spec: IngressSpec{
+			TLS: []IngressTLS{{
+				Hosts:           []string{"Foo.Example.com", "bücher.example.com"},
+				SecretName:      "secret",
+				SecretNamespace: "default",
+			}},
+			Rules: []IngressRule{{
+				Hosts: []string{"FOO.example.com", "*.Bücher.example.com", "foo.ns.svc.cluster.local", "bad_host.example.com"},
+				HTTP:  &HTTPIngressRuleValue{},
+			}},
+		},
+	}
+	ing.SetDefaults(context.Background())
+
+	if got, want := ing.Spec.TLS[0].Hosts, []string{"foo.example.com", "xn--bcher-kva.example.com"}; !cmp.Equal(got, want) {
+		t.Error("TLS hosts (-want, +got) =", cmp.Diff(want, got))
+	}
+	// The invalid hosts are left for the validation to reject.
+	if got, want := ing.Spec.Rules[0].Hosts, []string{"foo.example.com", "*.xn--bcher-kva.example.com", "foo.ns.svc.cluster.local", "bad_host.example.com"}; !cmp.Equal(got, want) {
+		t.Error("Rule hosts (-want, +got) =", cmp.Diff(want, got))
+	}
+}
+
+func TestIngressRedirectsDefaulting(t *testing.T) {
+	ing := &Ingress{
+		Spec: IngressSpec{
+			Rules: []IngressRule{{
+				Hosts: []string{"old.example.com"},
+				Redirects: []HTTPRedirect{{
+					Host: "new.example.com",
+				}, {
+					Path:       "/tmp",
+					Host:       "tmp.example.com",
+					StatusCode: http.StatusFound,
+				}},
+			}},
+		},
+	}
+	ing.SetDefaults(context.Background())
+
+	want := []HTTPRedirect{{
+		Host:       "new.example.com",
+		StatusCode: http.StatusMovedPermanently,
+	}, {
+		Path:       "/tmp",
+		Host:       "tmp.example.com",
+		StatusCode: http.StatusFound,
+	}}
+	if got := ing.Spec.Rules[0].Redirects; !cmp.Equal(got, want) {
+		t.Error("Redirects (-want, +got) =", cmp.Diff(want, got))
+	}
+	if ing.Spec.Rules[0].HTTP != nil {
+		t.Error("HTTP =", ing.Spec.Rules[0].HTTP, ", want nil")
+	}
+}
+
+func TestIngressAppendHeadersDefaulting(t *testing.T) {
+	ing := &Ingress{
+		Spec: IngressSpec{
+			Rules: []IngressRule{{
+				Hosts: []string{"example.com"},
+				HTTP: &HTTPIngressRuleValue{
+					Paths: []HTTPIngressPath{{
+						AppendHeaders: map[string]string{
+							"knative-serving-namespace": "default",
+							"X-Custom":                  "foo",
+							// Conflicting with X-Custom, left for the validation to report.
+							"x-CUSTOM": "bar",
+						},
+						Splits: []IngressBackendSplit{{
+							AppendHeaders: map[string]string{"knative-serving-revision": "revision-000"},
+						}},
+					}},
+				},
+			}},
+		},
+	}
+	ing.SetDefaults(context.Background())
+
+	path := ing.Spec.Rules[0].HTTP.Paths[0]
+	want := map[string]string{
+		"Knative-Serving-Namespace": "default",
+		"X-Custom":                  "foo",
+		"x-CUSTOM":                  "bar",
+	}
+	if !cmp.Equal(path.AppendHeaders, want) {
+		t.Error("AppendHeaders (-want, +got) =", cmp.Diff(want, path.AppendHeaders))
+	}
+	wantSplit := map[string]string{"Knative-Serving-Revision": "revision-000"}
+	if got := path.Splits[0].AppendHeaders; !cmp.Equal(got, wantSplit) {
+		t.Error("Split AppendHeaders (-want, +got) =", cmp.Diff(wantSplit, got))
+	}
+}
+
+func TestIngressHeaderModifiersDefaulting(t *testing.T) {
+	path := HTTPIngressPath{
+		SetHeaders:    map[string]string{"x-set": "foo"},
+		RemoveHeaders: []string{"x-internal", "X-Other"},
+		Splits: []IngressBackendSplit{{
+			SetHeaders:    map[string]string{"x-split": "bar"},
+			RemoveHeaders: []string{"x-revision-internal"},
+		}},
+	}
+	path.SetDefaults(context.Background())
+
+	if want := map[string]string{"X-Set": "foo"}; !cmp.Equal(path.SetHeaders, want) {
+		t.Error("SetHeaders (-want, +got) =", cmp.Diff(want, path.SetHeaders))
+	}
+	if want := []string{"X-Internal", "X-Other"}; !cmp.Equal(path.RemoveHeaders, want) {
+		t.Error("RemoveHeaders (-want, +got) =", cmp.Diff(want, path.RemoveHeaders))
+	}
+	split := path.Splits[0]
+	if want := map[string]string{"X-Split": "bar"}; !cmp.Equal(split.SetHeaders, want) {
+		t.Error("Split SetHeaders (-want, +got) =", cmp.Diff(want, split.SetHeaders))
+	}
+	if want := []string{"X-Revision-Internal"}; !cmp.Equal(split.RemoveHeaders, want) {
+		t.Error("Split RemoveHeaders (-want, +got) =", cmp.Diff(want, split.RemoveHeaders))
+	}
+}
+
+func TestIngressHeaderSplitsDefaulting(t *testing.T) {
+	selected := map[string]HeaderMatch{"X-Canary": {Exact: "true"}}
+	tests := []struct {
+		name   string
+		splits []IngressBackendSplit
+		want   []int
+	}{{
+		name:   "only split without headers",
+		splits: []IngressBackendSplit{{Headers: selected}, {}},
+		want:   []int{0, 100},
+	}, {
+		name:   "several splits without headers",
+		splits: []IngressBackendSplit{{Headers: selected}, {}, {}},
+		want:   []int{0, 0, 0},
+	}, {
+		name:   "split without headers first",
+		splits: []IngressBackendSplit{{}, {Headers: selected}},
+		want:   []int{100, 0},
+	}, {
+		name:   "only split selected by headers",
+		splits: []IngressBackendSplit{{Headers: selected}},
+		want:   []int{0},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := HTTPIngressPath{Splits: test.splits}
+			path.SetDefaults(context.Background())
+			got := make([]int, 0, len(path.Splits))
+			for _, s := range path.Splits {
+				got = append(got, s.Percent)
+			}
+			if !cmp.Equal(got, test.want) {
+				t.Error("Percents (-want, +got) =", cmp.Diff(test.want, got))
+			}
+		})
+	}
+}
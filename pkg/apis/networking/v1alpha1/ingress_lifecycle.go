@@ -18,6 +18,7 @@ package v1alpha1
 
 import (
 	"fmt"
+	"sort"
 
 	"k8s.io/apimachinery/pkg/runtime/schema"
 	"knative.dev/pkg/apis"
@@ -60,6 +61,13 @@ func (is *IngressStatus) MarkResourceNotOwned(kind, name string) {
 		fmt.Sprintf("There is an existing %s %q that we do not own.", kind, name))
 }
 
+// MarkHostConflict changes the "NetworkConfigured" condition to false to reflect that
+// the given host is claimed by another Ingress taking precedence over this one.
+func (is *IngressStatus) MarkHostConflict(host, owner string) {
+	ingressCondSet.Manage(is).MarkFalse(IngressConditionNetworkConfigured, "HostConflict",
+		fmt.Sprintf("Host %q is already claimed by Ingress %q.", host, owner))
+}
+
 // MarkLoadBalancerReady marks the Ingress with IngressConditionLoadBalancerReady,
 // and also populate the address of the load balancer.
 func (is *IngressStatus) MarkLoadBalancerReady(publicLbs []LoadBalancerIngressStatus, privateLbs []LoadBalancerIngressStatus) {
@@ -69,6 +77,55 @@ func (is *IngressStatus) MarkLoadBalancerReady(publicLbs []LoadBalancerIngressSt
 	ingressCondSet.Manage(is).MarkTrue(IngressConditionLoadBalancerReady)
 }
 
+// LoadBalancerIngressIP returns a load balancer ingress point reached through the given IP.
+func LoadBalancerIngressIP(ip string) LoadBalancerIngressStatus {
+	return LoadBalancerIngressStatus{IP: ip}
+}
+
+// LoadBalancerIngressDomain returns a load balancer ingress point reached through the
+// given DNS name.
+func LoadBalancerIngressDomain(domain string) LoadBalancerIngressStatus {
+	return LoadBalancerIngressStatus{Domain: domain}
+}
+
+// LoadBalancerIngressDomainInternal returns a load balancer ingress point reached through
+// the given cluster-local DNS name.
+func LoadBalancerIngressDomainInternal(domain string) LoadBalancerIngressStatus {
+	return LoadBalancerIngressStatus{DomainInternal: domain}
+}
+
+// MergeLoadBalancerIngresses merges the given lists of load balancer ingress points,
+// e.g. the ones of the multiple gateways exposing an Ingress, into a single list
+// suitable for MarkLoadBalancerReady. Duplicates are dropped and the result is sorted,
+// so that the status doesn't change with the order the gateways are listed in.
+func MergeLoadBalancerIngresses(lbs ...[]LoadBalancerIngressStatus) []LoadBalancerIngressStatus {
+	seen := make(map[LoadBalancerIngressStatus]struct{})
+	var merged []LoadBalancerIngressStatus
+	for _, l := range lbs {
+		for _, lb := range l {
+			if _, ok := seen[lb]; ok {
+				continue
+			}
+			seen[lb] = struct{}{}
+			merged = append(merged, lb)
+		}
+	}
+	sort.Slice(merged, func(i, j int) bool {
+		a, b := merged[i], merged[j]
+		switch {
+		case a.Domain != b.Domain:
+			return a.Domain < b.Domain
+		case a.DomainInternal != b.DomainInternal:
+			return a.DomainInternal < b.DomainInternal
+		case a.IP != b.IP:
+			return a.IP < b.IP
+		default:
+			return !a.MeshOnly && b.MeshOnly
+		}
+	})
+	return merged
+}
+
 // MarkLoadBalancerNotReady marks the "IngressConditionLoadBalancerReady" condition to unknown to
 // reflect that the load balancer is not ready yet.
 func (is *IngressStatus) MarkLoadBalancerNotReady() {
@@ -76,6 +133,12 @@ func (is *IngressStatus) MarkLoadBalancerNotReady() {
 		"Waiting for load balancer to be ready")
 }
 
+// MarkLoadBalancerPending marks the "IngressConditionLoadBalancerReady" condition to unknown
+// with the given reason and message, e.g. detailing what the load balancer is waiting for.
+func (is *IngressStatus) MarkLoadBalancerPending(reason, message string) {
+	ingressCondSet.Manage(is).MarkUnknown(IngressConditionLoadBalancerReady, reason, message)
+}
+
 // MarkLoadBalancerFailed marks the "IngressConditionLoadBalancerReady" condition to false.
 func (is *IngressStatus) MarkLoadBalancerFailed(reason, message string) {
 	ingressCondSet.Manage(is).MarkFalse(IngressConditionLoadBalancerReady, reason, message)
@@ -93,3 +156,18 @@ func (i *Ingress) IsReady() bool {
 	return is.ObservedGeneration == i.Generation &&
 		is.GetCondition(IngressConditionReady).IsTrue()
 }
+
+// MarkProgrammed records that the spec hashing to hash, e.g. with
+// ingress.SpecHash, is live in the data plane. The implementations call it
+// once they programmed the latest spec, e.g. after probing the gateways.
+func (is *IngressStatus) MarkProgrammed(hash string) {
+	is.ProgrammedHash = hash
+}
+
+// IsProgrammed returns true if the latest spec has been observed and the
+// spec live in the data plane hashes to hash, e.g. with ingress.SpecHash.
+func (i *Ingress) IsProgrammed(hash string) bool {
+	is := i.Status
+	return hash != "" && is.ObservedGeneration == i.Generation &&
+		is.ProgrammedHash == hash
+}
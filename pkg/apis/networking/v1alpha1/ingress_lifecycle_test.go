@@ -77,6 +77,12 @@ func TestIngressTypicalFlow(t *testing.T) {
 	apistest.CheckConditionOngoing(r, IngressConditionLoadBalancerReady, t)
 	apistest.CheckConditionOngoing(r, IngressConditionReady, t)
 
+	r.MarkLoadBalancerPending("ProbesPending", "some message")
+	apistest.CheckConditionOngoing(r, IngressConditionLoadBalancerReady, t)
+	if got, want := r.GetCondition(IngressConditionLoadBalancerReady).Reason, "ProbesPending"; got != want {
+		t.Errorf("Reason = %q, want: %q", got, want)
+	}
+
 	r.MarkLoadBalancerFailed("some reason", "some message")
 	apistest.CheckConditionFailed(r, IngressConditionLoadBalancerReady, t)
 	apistest.CheckConditionFailed(r, IngressConditionLoadBalancerReady, t)
@@ -105,6 +111,14 @@ func TestIngressTypicalFlow(t *testing.T) {
 		t.Fatal("IsReady()=false, wanted true")
 	}
 
+	// Mark host conflict.
+	r.MarkHostConflict("foo.example.com", "ns/owner")
+	apistest.CheckConditionFailed(r, IngressConditionReady, t)
+	if got, want := r.GetCondition(IngressConditionNetworkConfigured).Reason, "HostConflict"; got != want {
+		t.Errorf("Reason = %q, want: %q", got, want)
+	}
+	r.MarkNetworkConfigured()
+
 	// Mark ingress not ready
 	r.MarkIngressNotReady("", "")
 	apistest.CheckConditionOngoing(r, IngressConditionReady, t)
@@ -140,3 +154,121 @@ func TestIngressGetCondition(t *testing.T) {
 		})
 	}
 }
+
+func TestMergeLoadBalancerIngresses(t *testing.T) {
+	tests := []struct {
+		name string
+		lbs  [][]LoadBalancerIngressStatus
+		want []LoadBalancerIngressStatus
+	}{{
+		name: "nothing",
+	}, {
+		name: "single gateway",
+		lbs: [][]LoadBalancerIngressStatus{{
+			LoadBalancerIngressIP("10.0.0.2"),
+			LoadBalancerIngressIP("10.0.0.1"),
+		}},
+		want: []LoadBalancerIngressStatus{
+			LoadBalancerIngressIP("10.0.0.1"),
+			LoadBalancerIngressIP("10.0.0.2"),
+		},
+	}, {
+		name: "multiple gateways with duplicates",
+		lbs: [][]LoadBalancerIngressStatus{{
+			LoadBalancerIngressDomainInternal("gateway-b.ns.svc.cluster.local"),
+			LoadBalancerIngressIP("10.0.0.1"),
+		}, {
+			LoadBalancerIngressDomain("lb.example.com"),
+			LoadBalancerIngressIP("10.0.0.1"),
+			{IP: "10.0.0.1", MeshOnly: true},
+			LoadBalancerIngressDomainInternal("gateway-a.ns.svc.cluster.local"),
+		}},
+		want: []LoadBalancerIngressStatus{
+			LoadBalancerIngressIP("10.0.0.1"),
+			{IP: "10.0.0.1", MeshOnly: true},
+			LoadBalancerIngressDomainInternal("gateway-a.ns.svc.cluster.local"),
+			LoadBalancerIngressDomainInternal("gateway-b.ns.svc.cluster.local"),
+			LoadBalancerIngressDomain("lb.example.com"),
+		},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := MergeLoadBalancerIngresses(test.lbs...)
+			if !cmp.Equal(got, test.want) {
+				t.Error("MergeLoadBalancerIngresses (-want, +got):", cmp.Diff(test.want, got))
+			}
+			// The order of the gateways doesn't matter.
+			reversed := make([][]LoadBalancerIngressStatus, 0, len(test.lbs))
+			for i := len(test.lbs) - 1; i >= 0; i-- {
+				reversed = append(reversed, test.lbs[i])
+			}
+			if got := MergeLoadBalancerIngresses(reversed...); !cmp.Equal(got, test.want) {
+				t.Error("MergeLoadBalancerIngresses of reversed gateways (-want, +got):", cmp.Diff(test.want, got))
+			}
+		})
+	}
+}
+
+func TestIngressIsProgrammed(t *testing.T) {
+	const hash = "f988d74e91a2dfb0312c91d069c70203b4676889203682fa75e755f251b52843"
+
+	tests := []struct {
+		name   string
+		status IngressStatus
+		hash   string
+		want   bool
+	}{{
+		name: "not programmed yet",
+		status: IngressStatus{
+			Status: duckv1.Status{ObservedGeneration: 2},
+		},
+		hash: hash,
+	}, {
+		name: "latest spec programmed",
+		status: IngressStatus{
+			Status:         duckv1.Status{ObservedGeneration: 2},
+			ProgrammedHash: hash,
+		},
+		hash: hash,
+		want: true,
+	}, {
+		name: "older spec programmed",
+		status: IngressStatus{
+			Status:         duckv1.Status{ObservedGeneration: 2},
+			ProgrammedHash: "older",
+		},
+		hash: hash,
+	}, {
+		name: "latest generation not observed",
+		status: IngressStatus{
+			Status:         duckv1.Status{ObservedGeneration: 1},
+			ProgrammedHash: hash,
+		},
+		hash: hash,
+	}, {
+		name: "no hash",
+		status: IngressStatus{
+			Status: duckv1.Status{ObservedGeneration: 2},
+		},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ing := &Ingress{Status: test.status}
+			ing.Generation = 2
+			if got := ing.IsProgrammed(test.hash); got != test.want {
+				t.Errorf("IsProgrammed() = %v, want: %v", got, test.want)
+			}
+		})
+	}
+
+	ing := &Ingress{}
+	ing.Status.MarkProgrammed(hash)
+	if got := ing.Status.ProgrammedHash; got != hash {
+		t.Errorf("ProgrammedHash = %q, want: %q", got, hash)
+	}
+	if !ing.IsProgrammed(hash) {
+		t.Error("IsProgrammed() = false after MarkProgrammed")
+	}
+}
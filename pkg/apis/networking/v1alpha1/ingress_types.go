@@ -17,8 +17,14 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"math"
+	"net"
+	"net/http"
+	"strings"
+
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
+	"knative.dev/networking/pkg/apis/networking"
 	"knative.dev/pkg/apis"
 	duckv1 "knative.dev/pkg/apis/duck/v1"
 	"knative.dev/pkg/kmeta"
@@ -100,9 +106,167 @@ type IngressSpec struct {
 	// +optional
 	Rules []IngressRule `json:"rules,omitempty"`
 
+	// L4Rules exposes TCP and UDP ports of the gateways, forwarding the
+	// traffic received on them to backends as is, for the workloads which
+	// don't speak HTTP, e.g. MQTT brokers. An Ingress may only have L4Rules.
+	//
+	// This field is currently experimental, requires the l4-rules feature,
+	// and is not supported by all Ingress implementations.
+	// +optional
+	L4Rules []IngressL4Rule `json:"l4Rules,omitempty"`
+
 	// HTTPOption is the option of HTTP. It has the following two values:
 	// `HTTPOptionEnabled`, `HTTPOptionRedirected`
 	HTTPOption HTTPOption `json:"httpOption,omitempty"`
+
+	// DataplaneTrust raises the level of trust between the components of the
+	// data plane for the traffic of this Ingress above the one configured
+	// for the cluster, e.g. to require mutual TLS on the internal hops of a
+	// sensitive service. It can't lower the level of the cluster. One of
+	// `edge`, `identity` or `mutual`.
+	//
+	// This field is currently experimental and not supported by all Ingress implementations.
+	// +optional
+	DataplaneTrust networking.DataplaneTrust `json:"dataplaneTrust,omitempty"`
+
+	// Extensions configures implementation-specific features of the Ingress,
+	// which are not part of the API. The keys must be prefixed with a DNS
+	// subdomain owned by the implementation, e.g.
+	// `istio.example.com/proxy-buffering`, and the implementations must ignore
+	// the keys they don't know. The `networking.knative.dev` prefix and its
+	// subdomains are reserved.
+	//
+	// This field is currently experimental and not supported by all Ingress implementations.
+	// +optional
+	Extensions map[string]string `json:"extensions,omitempty"`
+
+	// LoadBalancer requests specific behaviors of the load balancer exposing
+	// the Ingress, e.g. an internal load balancer or a static IP, which the
+	// implementations map to the annotations of their gateway Services.
+	//
+	// This field is currently experimental and not supported by all Ingress implementations.
+	// +optional
+	LoadBalancer *LoadBalancerOptions `json:"loadBalancer,omitempty"`
+}
+
+// Extension returns the value of the given implementation-specific extension
+// of the Ingress, and whether it is set. For backward compatibility with the
+// implementations relying on annotations, the annotation of the same key is
+// used if the extension is not set in the spec.
+func (i *Ingress) Extension(key string) (string, bool) {
+	if v, ok := i.Spec.Extensions[key]; ok {
+		return v, true
+	}
+	v, ok := i.Annotations[key]
+	return v, ok
+}
+
+// ExtensionsWithPrefix returns the extensions of the spec in the namespace of
+// the given prefix, e.g. `istio.example.com`, keyed by their names without
+// the prefix.
+func (is *IngressSpec) ExtensionsWithPrefix(prefix string) map[string]string {
+	var ret map[string]string
+	for k, v := range is.Extensions {
+		if name := strings.TrimPrefix(k, prefix+"/"); name != k {
+			if ret == nil {
+				ret = make(map[string]string, len(is.Extensions))
+			}
+			ret[name] = v
+		}
+	}
+	return ret
+}
+
+// DataplaneTrustLevel returns the level of trust between the components of
+// the data plane applying to the traffic of the Ingress: the stricter of its
+// own DataplaneTrust and the level of the cluster, e.g. the
+// DataplaneTrustLevel of the network config.
+func (is *IngressSpec) DataplaneTrustLevel(cluster networking.DataplaneTrust) networking.DataplaneTrust {
+	if is.DataplaneTrust != "" && !cluster.AtLeast(is.DataplaneTrust) {
+		return is.DataplaneTrust
+	}
+	return cluster
+}
+
+// HostMatches returns whether host, e.g. the Host header of a request,
+// matches pattern, a host of a rule. Hosts are compared case-insensitively,
+// ignoring the port and the trailing dot of host. A wildcard pattern, e.g.
+// `*.example.com`, matches the hosts with one or more labels in place of the
+// wildcard, e.g. `foo.example.com` and `bar.foo.example.com`, but not
+// `example.com`.
+func HostMatches(pattern, host string) bool {
+	host = canonicalHost(host)
+	pattern = strings.TrimSuffix(strings.ToLower(pattern), ".")
+	if suffix := strings.TrimPrefix(pattern, "*"); suffix != pattern {
+		return strings.HasSuffix(host, suffix) && len(host) > len(suffix)
+	}
+	return host == pattern
+}
+
+// canonicalHost returns host in lower case, without its port and trailing
+// dot.
+func canonicalHost(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.TrimSuffix(strings.ToLower(host), ".")
+}
+
+// hostSpecificity ranks how specifically pattern matches hosts: an exact
+// host ranks above all the wildcards, which rank by the length of their
+// suffix.
+func hostSpecificity(pattern string) int {
+	if strings.HasPrefix(pattern, "*.") {
+		return len(pattern)
+	}
+	return math.MaxInt32
+}
+
+// MatchRule returns the rule with the given visibility matching host, e.g.
+// the Host header of a request, or nil if none. When several rules match
+// host, the precedence is:
+//  1. the rules listing host itself, over the ones matching it with a
+//     wildcard,
+//  2. among the wildcards, the one with the longest suffix, e.g.
+//     `*.foo.example.com` over `*.example.com` for `bar.foo.example.com`,
+//  3. among the rules matching host equally, the first one.
+//
+// The Ingress implementations must route the requests following the same
+// precedence.
+func (is *IngressSpec) MatchRule(host string, visibility IngressVisibility) *IngressRule {
+	var (
+		match *IngressRule
+		best  int
+	)
+	for i := range is.Rules {
+		rule := &is.Rules[i]
+		if rule.Visibility != visibility {
+			continue
+		}
+		for _, pattern := range rule.Hosts {
+			if !HostMatches(pattern, host) {
+				continue
+			}
+			if s := hostSpecificity(pattern); s > best {
+				match, best = rule, s
+			}
+		}
+	}
+	return match
+}
+
+// RuleHTTPOption returns the HTTPOption applying to the hosts of the given rule:
+// the rule's own HTTPOption if set, otherwise the HTTPOption of the spec,
+// defaulting to `HTTPOptionEnabled`.
+func (is *IngressSpec) RuleHTTPOption(r *IngressRule) HTTPOption {
+	switch {
+	case r.HTTPOption != "":
+		return r.HTTPOption
+	case is.HTTPOption != "":
+		return is.HTTPOption
+	default:
+		return HTTPOptionEnabled
+	}
 }
 
 type HTTPOption string
@@ -130,6 +294,91 @@ const (
 	IngressVisibilityClusterLocal IngressVisibility = "ClusterLocal"
 )
 
+// LoadBalancerOptions are the behaviors requested from the load balancer
+// exposing an Ingress.
+type LoadBalancerOptions struct {
+	// Internal requests a load balancer only reachable from the private
+	// network of the cluster rather than from the internet.
+	// +optional
+	Internal bool `json:"internal,omitempty"`
+
+	// StaticIP references an IP address reserved with the infrastructure
+	// provider, either the address itself or the name of its reservation.
+	// +optional
+	StaticIP string `json:"staticIP,omitempty"`
+
+	// Annotations are passed through to the gateway Services as is. Only the
+	// keys allowed by the implementation are accepted.
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// LoadBalancerAnnotationMapping is how an implementation maps the typed
+// LoadBalancerOptions to the annotations of its gateway Services.
+type LoadBalancerAnnotationMapping struct {
+	// Internal are the annotations requesting an internal load balancer,
+	// e.g. `networking.gke.io/load-balancer-type: Internal`.
+	Internal map[string]string
+	// StaticIP is the key of the annotation referencing the static IP, e.g.
+	// `service.beta.kubernetes.io/azure-load-balancer-ipv4`.
+	StaticIP string
+}
+
+// ServiceAnnotations returns the annotations of the gateway Services
+// implementing the options with the given mapping: the annotations passed
+// through, overridden by the ones of the typed options. The options the
+// mapping has no annotation for are ignored.
+func (lb *LoadBalancerOptions) ServiceAnnotations(m LoadBalancerAnnotationMapping) map[string]string {
+	if lb == nil {
+		return nil
+	}
+	ret := make(map[string]string, len(lb.Annotations)+len(m.Internal)+1)
+	for k, v := range lb.Annotations {
+		ret[k] = v
+	}
+	if lb.Internal {
+		for k, v := range m.Internal {
+			ret[k] = v
+		}
+	}
+	if lb.StaticIP != "" && m.StaticIP != "" {
+		ret[m.StaticIP] = lb.StaticIP
+	}
+	return ret
+}
+
+// L4Protocol is the transport protocol of an IngressL4Rule.
+type L4Protocol string
+
+const (
+	// L4ProtocolTCP forwards the TCP connections.
+	L4ProtocolTCP L4Protocol = "TCP"
+
+	// L4ProtocolUDP forwards the UDP datagrams.
+	L4ProtocolUDP L4Protocol = "UDP"
+)
+
+// IngressL4Rule exposes a port of the gateways of the given visibility,
+// forwarding the traffic received on it to a backend.
+type IngressL4Rule struct {
+	// Port is the port of the gateways the rule is exposed on. The
+	// implementations may reject the ports they serve HTTP on.
+	Port int32 `json:"port"`
+
+	// Protocol is the transport protocol of the rule, either `TCP` or `UDP`.
+	// Defaults to `TCP`.
+	// +optional
+	Protocol L4Protocol `json:"protocol,omitempty"`
+
+	// Visibility signifies whether the port is exposed by the `ExternalIP` or
+	// the `ClusterLocal` gateways. Defaults to `ExternalIP`.
+	// +optional
+	Visibility IngressVisibility `json:"visibility,omitempty"`
+
+	// Backend receives the traffic of the rule.
+	Backend IngressBackend `json:"backend"`
+}
+
 // IngressTLS describes the transport layer security associated with an Ingress.
 type IngressTLS struct {
 	// Hosts is a list of hosts included in the TLS certificate. The values in
@@ -149,6 +398,59 @@ type IngressTLS struct {
 	//
 	// +optional
 	SecretNamespace string `json:"secretNamespace,omitempty"`
+
+	// Visibility restricts the TLS configuration to the listeners of the given
+	// visibility, allowing e.g. cluster-local hosts to be served with a certificate
+	// distinct from the public one. Every host listed must then belong to a rule
+	// of the same visibility. If not specified, the TLS configuration applies to
+	// listeners of any visibility.
+	//
+	// This field is currently experimental and not supported by all Ingress implementations.
+	// +optional
+	Visibility IngressVisibility `json:"visibility,omitempty"`
+
+	// CanonicalHost is one of the Hosts, to which the requests to the other
+	// Hosts are permanently redirected over HTTPS, their paths and queries
+	// being preserved, e.g. to redirect `www.example.com` to `example.com`
+	// with a certificate valid for both. The canonical host must be served
+	// by a rule, and the other Hosts must belong to rules without redirects.
+	//
+	// This field is currently experimental and not supported by all Ingress implementations.
+	// +optional
+	CanonicalHost string `json:"canonicalHost,omitempty"`
+}
+
+// CanonicalRedirect returns the redirect of the requests to host to the
+// canonical host of the TLS configuration it belongs to, if any, so that
+// the implementations can program it as the other redirects.
+func (is *IngressSpec) CanonicalRedirect(host string) *HTTPRedirect {
+	for _, tls := range is.TLS {
+		if tls.CanonicalHost == "" || tls.CanonicalHost == host {
+			continue
+		}
+		for _, h := range tls.Hosts {
+			if h == host {
+				return &HTTPRedirect{
+					StatusCode: http.StatusMovedPermanently,
+					Scheme:     "https",
+					Host:       tls.CanonicalHost,
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// TLSForVisibility returns the TLS configurations applying to the listeners of
+// the given visibility, i.e. the ones with that visibility or with none set.
+func (is *IngressSpec) TLSForVisibility(visibility IngressVisibility) []IngressTLS {
+	var tls []IngressTLS
+	for _, t := range is.TLS {
+		if t.Visibility == "" || t.Visibility == visibility {
+			tls = append(tls, t)
+		}
+	}
+	return tls
 }
 
 // IngressRule represents the rules mapping the paths under a specified host to
@@ -166,17 +468,152 @@ type IngressRule struct {
 	// Both these may change in the future.
 	// If the host is unspecified, the Ingress routes all traffic based on the
 	// specified IngressRuleValue.
-	// If multiple matching Hosts were provided, the first rule will take precedent.
+	// A host may be a wildcard, e.g. `*.example.com`, matching the hosts with
+	// one or more labels in place of the wildcard, but not `example.com`.
+	// The wildcard must be the whole leftmost label, followed by at least two
+	// labels.
+	// If multiple rules match a host, the rule listing the host itself takes
+	// precedence over the wildcards, then the wildcard with the longest
+	// suffix, then the first rule, see IngressSpec.MatchRule.
 	// +optional
 	Hosts []string `json:"hosts,omitempty"`
 
 	// Visibility signifies whether this rule should `ClusterLocal`. If it's not
-	// specified then it defaults to `ExternalIP`.
+	// specified then it defaults to `ClusterLocal` when all the hosts are in
+	// the cluster domain, e.g. `foo.ns.svc.cluster.local`, and to `ExternalIP`
+	// otherwise.
 	Visibility IngressVisibility `json:"visibility,omitempty"`
 
 	// HTTP represents a rule to apply against incoming requests. If the
 	// rule is satisfied, the request is routed to the specified backend.
+	// It may only be omitted if the rule has Redirects.
 	HTTP *HTTPIngressRuleValue `json:"http,omitempty"`
+
+	// Redirects answers the requests to the hosts of this rule with a
+	// redirect rather than routing them to a backend, e.g. to redirect a
+	// domain to another one without a dummy backend. They take precedence
+	// over the paths of HTTP. If multiple redirects match a request, the
+	// first one in the list is applied.
+	//
+	// This field is currently experimental and not supported by all Ingress
+	// implementations.
+	// +optional
+	Redirects []HTTPRedirect `json:"redirects,omitempty"`
+
+	// SourceIPPolicy restricts the client IP addresses allowed to reach
+	// the hosts of this rule. If unspecified, all clients are allowed.
+	//
+	// This field is currently experimental and not supported by all Ingress
+	// implementations.
+	// +optional
+	SourceIPPolicy *SourceIPPolicy `json:"sourceIPPolicy,omitempty"`
+
+	// HTTPOption overrides the HTTPOption of the spec for the hosts of this
+	// rule, e.g. to redirect external hosts to HTTPS while serving cluster-local
+	// hosts over plain HTTP. If unspecified, the HTTPOption of the spec applies.
+	//
+	// This field is currently experimental and not supported by all Ingress
+	// implementations.
+	// +optional
+	HTTPOption HTTPOption `json:"httpOption,omitempty"`
+
+	// ErrorPages replaces the error responses sent for the hosts of this rule,
+	// whether they come from the backends or from the Ingress itself, e.g.
+	// to brand 404 and 503 pages consistently. If multiple pages match a
+	// status code, the one listing it explicitly takes precedence over the
+	// one listing its class.
+	//
+	// This field is currently experimental and not supported by all Ingress
+	// implementations.
+	// +optional
+	ErrorPages []ErrorPage `json:"errorPages,omitempty"`
+}
+
+// HTTPRedirect describes the redirect of the requests matching a path. At
+// least one of Scheme, Host and ReplacePrefix must be set, the other parts of
+// the URL of the requests being preserved.
+type HTTPRedirect struct {
+	// Path is the literal prefix of the paths of the requests redirected.
+	// Paths must begin with a '/'. If unspecified, all the requests are
+	// redirected.
+	// +optional
+	Path string `json:"path,omitempty"`
+
+	// StatusCode is the status code of the redirect, one of 301, 302 and
+	// 308. Defaults to 301.
+	// +optional
+	StatusCode int `json:"statusCode,omitempty"`
+
+	// Scheme replaces the scheme of the requests, either `http` or `https`.
+	// Redirects only setting the Scheme don't apply to the requests already
+	// using it, e.g. to redirect the HTTP requests to HTTPS.
+	// +optional
+	Scheme string `json:"scheme,omitempty"`
+
+	// Host replaces the host of the requests.
+	// +optional
+	Host string `json:"host,omitempty"`
+
+	// ReplacePrefix replaces the prefix of the paths of the requests matched
+	// by Path, the rest of the paths being preserved, e.g. `/v2/` redirects
+	// `/v1/foo` to `/v2/foo` for the Path `/v1/`.
+	// +optional
+	ReplacePrefix string `json:"replacePrefix,omitempty"`
+
+	// StripQuery removes the query of the requests from the redirect URL,
+	// which preserves it otherwise.
+	// +optional
+	StripQuery bool `json:"stripQuery,omitempty"`
+}
+
+// ErrorPage describes the response sent in place of the error responses
+// with the given status codes.
+type ErrorPage struct {
+	// StatusCodes are the error status codes this page applies to, either
+	// explicitly, e.g. `404`, or as a class, e.g. `5xx`.
+	StatusCodes []string `json:"statusCodes"`
+
+	// Backend serves the error page. The request is forwarded to it with
+	// its original path, and the status code of the original response is
+	// kept. Exactly one of Backend and Body must be set.
+	// +optional
+	Backend *IngressBackend `json:"backend,omitempty"`
+
+	// Body is a static error page. Exactly one of Backend and Body must be
+	// set.
+	// +optional
+	Body *ErrorPageBody `json:"body,omitempty"`
+}
+
+// ErrorPageBody references a static error page held by a ConfigMap in the
+// namespace of the Ingress.
+type ErrorPageBody struct {
+	// ConfigMapName is the name of the ConfigMap holding the page.
+	ConfigMapName string `json:"configMapName"`
+
+	// Key is the key of the page in the ConfigMap.
+	Key string `json:"key"`
+
+	// ContentType is the media type of the page. If unspecified, it
+	// defaults to `text/html`.
+	// +optional
+	ContentType string `json:"contentType,omitempty"`
+}
+
+// SourceIPPolicy describes the client IP address ranges allowed to reach
+// a rule. A request is allowed if its source address is in one of the Allow
+// ranges, or Allow is empty, and it is not in any of the Deny ranges.
+type SourceIPPolicy struct {
+	// Allow is a list of CIDRs, e.g. `10.0.0.0/8` or `fd00::/8`, of the
+	// clients allowed to reach the rule. If empty, all clients not denied
+	// are allowed.
+	// +optional
+	Allow []string `json:"allow,omitempty"`
+
+	// Deny is a list of CIDRs of the clients denied to reach the rule.
+	// Deny takes precedence over Allow.
+	// +optional
+	Deny []string `json:"deny,omitempty"`
 }
 
 // HTTPIngressRuleValue is a list of http selectors pointing to backends.
@@ -205,7 +642,10 @@ type HTTPIngressPath struct {
 	// +optional
 	Path string `json:"path,omitempty"`
 
-	// RewriteHost rewrites the incoming request's host header.
+	// RewriteHost rewrites the incoming request's host header. It must be a
+	// lowercase DNS name, without port. The Host header can only be rewritten
+	// through this field, not through the headers modified by the path or
+	// its splits.
 	//
 	// This field is currently experimental and not supported by all Ingress
 	// implementations.
@@ -224,11 +664,109 @@ type HTTPIngressPath struct {
 	Splits []IngressBackendSplit `json:"splits"`
 
 	// AppendHeaders allow specifying additional HTTP headers to add
-	// before forwarding a request to the destination service.
+	// before forwarding a request to the destination service. The header
+	// names are canonicalized, e.g. `x-foo` into `X-Foo`, and must not be
+	// reserved to the networking layer: Host, Forwarded, X-Forwarded-* and
+	// K-Network-*.
 	//
 	// NOTE: This differs from K8s Ingress which doesn't allow header appending.
 	// +optional
 	AppendHeaders map[string]string `json:"appendHeaders,omitempty"`
+
+	// SetHeaders allow specifying HTTP headers to set before forwarding a
+	// request to the destination service, replacing the values the request
+	// may have. The header names follow the rules of AppendHeaders, and a
+	// header can't be both appended and set.
+	//
+	// This field is currently experimental and not supported by all Ingress
+	// implementations.
+	// +optional
+	SetHeaders map[string]string `json:"setHeaders,omitempty"`
+
+	// RemoveHeaders allow specifying HTTP headers to remove before forwarding
+	// a request to the destination service, e.g. to strip internal headers
+	// before they reach the user containers. The header names follow the
+	// rules of AppendHeaders, and a header can't be both removed and appended
+	// or set. The headers of a path are modified before the ones of its
+	// splits, and the headers are removed, then set, then appended.
+	//
+	// This field is currently experimental and not supported by all Ingress
+	// implementations.
+	// +optional
+	RemoveHeaders []string `json:"removeHeaders,omitempty"`
+
+	// MaxRequestBodyBytes is the maximum size of the body of the requests
+	// matching this path. Requests with a larger body are rejected with a
+	// 413 Payload Too Large. If unspecified, the implementation's default
+	// applies.
+	//
+	// This field is currently experimental and not supported by all Ingress
+	// implementations.
+	// +optional
+	MaxRequestBodyBytes *int64 `json:"maxRequestBodyBytes,omitempty"`
+
+	// IdleTimeout is how long the connections of the requests matching this
+	// path may stay idle, i.e. without any byte sent in either direction,
+	// before they are closed, e.g. to let long-polling or Server-Sent Events
+	// endpoints wait longer than the gateways' default. It doesn't bound the
+	// total duration of the requests. It must be at least 1ms. If unspecified,
+	// the implementation's default applies.
+	//
+	// This field is currently experimental and not supported by all Ingress
+	// implementations.
+	// +optional
+	IdleTimeout *metav1.Duration `json:"idleTimeout,omitempty"`
+
+	// ConnectTimeout is how long the Ingress waits for the connections to the
+	// backends of the requests matching this path to be established, e.g. to
+	// fail fast on the backends not programmed yet, while the requests
+	// themselves may last much longer, as streaming ones do. It must be at
+	// least 1ms. If unspecified, the implementation's default applies.
+	//
+	// This field is currently experimental and not supported by all Ingress
+	// implementations.
+	// +optional
+	ConnectTimeout *metav1.Duration `json:"connectTimeout,omitempty"`
+
+	// Compression specifies whether the Ingress compresses the responses to
+	// the requests matching this path, e.g. to opt latency-sensitive gRPC
+	// paths out of it, or web assets in. If unspecified, the implementation's
+	// default applies.
+	//
+	// This field is currently experimental and not supported by all Ingress
+	// implementations.
+	// +optional
+	Compression *Compression `json:"compression,omitempty"`
+}
+
+// CompressionMode specifies whether the Ingress compresses the responses.
+type CompressionMode string
+
+const (
+	// CompressionEnabled compresses the responses, for the clients accepting
+	// it and unless they are already compressed.
+	CompressionEnabled CompressionMode = "Enabled"
+
+	// CompressionDisabled never compresses the responses.
+	CompressionDisabled CompressionMode = "Disabled"
+)
+
+// Compression describes which responses the Ingress compresses.
+type Compression struct {
+	// Mode is either `Enabled` or `Disabled`.
+	Mode CompressionMode `json:"mode"`
+
+	// MinSizeBytes is the size under which the responses are not compressed.
+	// It can only be set when Mode is `Enabled`. If unspecified, the
+	// implementation's default applies.
+	// +optional
+	MinSizeBytes *int64 `json:"minSizeBytes,omitempty"`
+
+	// ContentTypes are the media types of the responses compressed, e.g.
+	// `text/html`. They can only be set when Mode is `Enabled`. If
+	// unspecified, the implementation's default applies.
+	// +optional
+	ContentTypes []string `json:"contentTypes,omitempty"`
 }
 
 // IngressBackendSplit describes all endpoints for a given service and port.
@@ -242,12 +780,187 @@ type IngressBackendSplit struct {
 	// NOTE: This differs from K8s Ingress to allow percentage split.
 	Percent int `json:"percent,omitempty"`
 
+	// Headers selects this split for the requests matching all the header
+	// matching rules, e.g. to route a tag header to the tagged revision.
+	// Splits selected by headers take precedence over the percentage-based
+	// ones, which receive the requests not matching any of them. When several
+	// splits match a request, the first one in the list is selected.
+	// A split selected by headers must not specify Percent.
+	//
+	// This field is currently experimental and not supported by all Ingress
+	// implementations.
+	// +optional
+	Headers map[string]HeaderMatch `json:"headers,omitempty"`
+
 	// AppendHeaders allow specifying additional HTTP headers to add
-	// before forwarding a request to the destination service.
+	// before forwarding a request to the destination service. The header
+	// names are canonicalized, e.g. `x-foo` into `X-Foo`, and must not be
+	// reserved to the networking layer: Host, Forwarded, X-Forwarded-* and
+	// K-Network-*.
 	//
 	// NOTE: This differs from K8s Ingress which doesn't allow header appending.
 	// +optional
 	AppendHeaders map[string]string `json:"appendHeaders,omitempty"`
+
+	// SetHeaders allow specifying HTTP headers to set before forwarding a
+	// request to the destination service, see HTTPIngressPath.SetHeaders.
+	//
+	// This field is currently experimental and not supported by all Ingress
+	// implementations.
+	// +optional
+	SetHeaders map[string]string `json:"setHeaders,omitempty"`
+
+	// RemoveHeaders allow specifying HTTP headers to remove before forwarding
+	// a request to the destination service, see HTTPIngressPath.RemoveHeaders.
+	//
+	// This field is currently experimental and not supported by all Ingress
+	// implementations.
+	// +optional
+	RemoveHeaders []string `json:"removeHeaders,omitempty"`
+
+	// LoadBalancerPolicy specifies how requests are distributed across the
+	// endpoints of the backend. If unspecified, the implementation's default
+	// is used.
+	//
+	// This field is currently experimental and not supported by all Ingress
+	// implementations.
+	// +optional
+	LoadBalancerPolicy *LoadBalancerPolicy `json:"loadBalancerPolicy,omitempty"`
+
+	// UpstreamTLS indicates that the backend speaks TLS, and how its
+	// certificate is verified. If unspecified, plain text is used to reach
+	// the backend.
+	//
+	// This field is currently experimental and not supported by all Ingress
+	// implementations.
+	// +optional
+	UpstreamTLS *UpstreamTLS `json:"upstreamTLS,omitempty"`
+
+	// OutlierDetection ejects the endpoints of the backend failing
+	// consecutively from the load balancing for a while, i.e. passive health
+	// checking. If unspecified, the endpoints are never ejected.
+	//
+	// This field is currently experimental and not supported by all Ingress
+	// implementations.
+	// +optional
+	OutlierDetection *OutlierDetection `json:"outlierDetection,omitempty"`
+
+	// HealthCheck checks the health of the endpoints of the backend with
+	// periodic requests, i.e. active health checking, sending the traffic
+	// only to the healthy ones. If unspecified, no health check is sent.
+	//
+	// This field is currently experimental and not supported by all Ingress
+	// implementations.
+	// +optional
+	HealthCheck *HealthCheck `json:"healthCheck,omitempty"`
+}
+
+// HealthCheck describes the HTTP requests checking the health of the
+// endpoints of a backend.
+type HealthCheck struct {
+	// Path is the path the health check requests are sent to, which must
+	// begin with a '/'. The endpoints answering with a 2xx status are healthy.
+	Path string `json:"path"`
+
+	// Interval is the time between two health checks of an endpoint. If
+	// unspecified, the implementation's default applies.
+	// +optional
+	Interval *metav1.Duration `json:"interval,omitempty"`
+
+	// HealthyThreshold is the number of consecutive successful health checks
+	// after which an unhealthy endpoint is healthy again. If unspecified, the
+	// implementation's default applies.
+	// +optional
+	HealthyThreshold *int32 `json:"healthyThreshold,omitempty"`
+
+	// UnhealthyThreshold is the number of consecutive failed health checks
+	// after which an endpoint is unhealthy. If unspecified, the
+	// implementation's default applies.
+	// +optional
+	UnhealthyThreshold *int32 `json:"unhealthyThreshold,omitempty"`
+}
+
+// OutlierDetection describes when the endpoints of a backend are ejected
+// from the load balancing, based on the responses of the requests they served.
+type OutlierDetection struct {
+	// ConsecutiveErrors is the number of consecutive errors, i.e. 5xx
+	// responses or connection failures, after which an endpoint is ejected.
+	ConsecutiveErrors int32 `json:"consecutiveErrors"`
+
+	// EjectionInterval is how long an endpoint stays ejected. Implementations
+	// may eject endpoints ejected repeatedly for longer. If unspecified, the
+	// implementation's default applies.
+	// +optional
+	EjectionInterval *metav1.Duration `json:"ejectionInterval,omitempty"`
+
+	// MaxEjectionPercent is the maximum percentage of the endpoints of the
+	// backend that can be ejected at the same time, a number between 0 and
+	// 100. If unspecified, the implementation's default applies.
+	// +optional
+	MaxEjectionPercent *int32 `json:"maxEjectionPercent,omitempty"`
+}
+
+// UpstreamTLS describes the TLS connection established to a backend,
+// e.g. to encrypt the hops between the Ingress and the system components.
+type UpstreamTLS struct {
+	// CASecretName is the name of the secret holding the CA bundle, under
+	// the `ca.crt` key, the certificate of the backend is verified against.
+	CASecretName string `json:"caSecretName"`
+
+	// CASecretNamespace is the namespace of the secret holding the CA bundle.
+	CASecretNamespace string `json:"caSecretNamespace"`
+
+	// ServerName is the name sent through SNI and verified against the
+	// certificate of the backend. If unspecified, the name of the backend
+	// Service is used.
+	// +optional
+	ServerName string `json:"serverName,omitempty"`
+}
+
+// LoadBalancerPolicyType is the algorithm used to pick an endpoint of a backend.
+type LoadBalancerPolicyType string
+
+const (
+	// LoadBalancerPolicyRoundRobin picks the endpoints in turn.
+	LoadBalancerPolicyRoundRobin LoadBalancerPolicyType = "RoundRobin"
+
+	// LoadBalancerPolicyLeastRequest picks the endpoint with the fewest
+	// outstanding requests.
+	LoadBalancerPolicyLeastRequest LoadBalancerPolicyType = "LeastRequest"
+
+	// LoadBalancerPolicyRingHash consistently picks the same endpoint for
+	// requests with the same hash key, which is useful for cache-friendly
+	// backends.
+	LoadBalancerPolicyRingHash LoadBalancerPolicyType = "RingHash"
+)
+
+// LoadBalancerPolicy describes how requests are distributed across the
+// endpoints of a backend.
+type LoadBalancerPolicy struct {
+	// Type is the load balancing algorithm, one of `RoundRobin`,
+	// `LeastRequest` or `RingHash`.
+	Type LoadBalancerPolicyType `json:"type"`
+
+	// HashKey specifies what the request is hashed on. It must be set if
+	// and only if Type is `RingHash`.
+	// +optional
+	HashKey *LoadBalancerHashKey `json:"hashKey,omitempty"`
+}
+
+// LoadBalancerHashKey describes the part of a request used by hash-based
+// load balancing. Exactly one of the fields must be set.
+type LoadBalancerHashKey struct {
+	// Header is the name of the request header whose value is hashed.
+	// +optional
+	Header string `json:"header,omitempty"`
+
+	// Cookie is the name of the request cookie whose value is hashed.
+	// +optional
+	Cookie string `json:"cookie,omitempty"`
+
+	// SourceIP hashes on the IP address of the client.
+	// +optional
+	SourceIP bool `json:"sourceIP,omitempty"`
 }
 
 // IngressBackend describes all endpoints for a given service and port.
@@ -255,13 +968,44 @@ type IngressBackend struct {
 	// Specifies the namespace of the referenced service.
 	//
 	// NOTE: This differs from K8s Ingress to allow routing to different namespaces.
-	ServiceNamespace string `json:"serviceNamespace"`
+	// +optional
+	ServiceNamespace string `json:"serviceNamespace,omitempty"`
 
 	// Specifies the name of the referenced service.
-	ServiceName string `json:"serviceName"`
+	// +optional
+	ServiceName string `json:"serviceName,omitempty"`
 
 	// Specifies the port of the referenced service.
-	ServicePort intstr.IntOrString `json:"servicePort"`
+	// +optional
+	ServicePort intstr.IntOrString `json:"servicePort,omitempty"`
+
+	// External routes the traffic to a host outside of the cluster rather
+	// than to the endpoints of a service, in which case ServiceNamespace,
+	// ServiceName and ServicePort must not be set.
+	//
+	// This field is currently experimental, requires the external-backends
+	// feature, and is not supported by all Ingress implementations.
+	// +optional
+	External *ExternalBackend `json:"external,omitempty"`
+}
+
+// ExternalBackend describes a host outside of the cluster, either referenced
+// by a Service of type ExternalName or by its name or IP address. Exactly one
+// of ServiceName and Host must be set.
+type ExternalBackend struct {
+	// ServiceName is the name of a Service of type ExternalName, in the
+	// namespace of the Ingress, whose external name is the host receiving
+	// the traffic.
+	// +optional
+	ServiceName string `json:"serviceName,omitempty"`
+
+	// Host is the DNS name or the IP address of the host receiving the
+	// traffic.
+	// +optional
+	Host string `json:"host,omitempty"`
+
+	// Port is the port of the host receiving the traffic.
+	Port int32 `json:"port"`
 }
 
 // HTTPRetry is DEPRECATED. Retry is not used in KIngress.
@@ -284,6 +1028,12 @@ type IngressStatus struct {
 	// PrivateLoadBalancer contains the current status of the load-balancer.
 	// +optional
 	PrivateLoadBalancer *LoadBalancerStatus `json:"privateLoadBalancer,omitempty"`
+
+	// ProgrammedHash is the hash of the spec of the Ingress live in the data
+	// plane, as set by the implementations once they programmed it. Along
+	// with ObservedGeneration, it tells whether the latest spec is live.
+	// +optional
+	ProgrammedHash string `json:"programmedHash,omitempty"`
 }
 
 // LoadBalancerStatus represents the status of a load-balancer.
@@ -341,8 +1091,8 @@ func (i *Ingress) GetStatus() *duckv1.Status {
 	return &i.Status.Status
 }
 
-// HeaderMatch represents a matching value of Headers in HTTPIngressPath.
-// Currently, only the exact matching is supported.
+// HeaderMatch represents a matching value of Headers in HTTPIngressPath
+// and IngressBackendSplit. Currently, only the exact matching is supported.
 type HeaderMatch struct {
 	Exact string `json:"exact"`
 }
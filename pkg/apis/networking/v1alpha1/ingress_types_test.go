@@ -16,7 +16,12 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"net/http"
 	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"knative.dev/networking/pkg/apis/networking"
 )
 
 func TestIngressGetStatus(t *testing.T) {
@@ -28,3 +33,291 @@ func TestIngressGetStatus(t *testing.T) {
 		t.Errorf("GetStatus=%v, want=%v", got, want)
 	}
 }
+
+func TestRuleHTTPOption(t *testing.T) {
+	tests := []struct {
+		name string
+		spec HTTPOption
+		rule HTTPOption
+		want HTTPOption
+	}{{
+		name: "defaults to enabled",
+		want: HTTPOptionEnabled,
+	}, {
+		name: "inherited from the spec",
+		spec: HTTPOptionRedirected,
+		want: HTTPOptionRedirected,
+	}, {
+		name: "overridden by the rule",
+		spec: HTTPOptionRedirected,
+		rule: HTTPOptionEnabled,
+		want: HTTPOptionEnabled,
+	}, {
+		name: "set on the rule only",
+		rule: HTTPOptionRedirected,
+		want: HTTPOptionRedirected,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			is := &IngressSpec{HTTPOption: test.spec}
+			if got := is.RuleHTTPOption(&IngressRule{HTTPOption: test.rule}); got != test.want {
+				t.Errorf("RuleHTTPOption() = %q, want: %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestDataplaneTrustLevel(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    networking.DataplaneTrust
+		cluster networking.DataplaneTrust
+		want    networking.DataplaneTrust
+	}{{
+		name:    "inherited from the cluster",
+		cluster: networking.DataplaneTrustIdentity,
+		want:    networking.DataplaneTrustIdentity,
+	}, {
+		name:    "raised by the Ingress",
+		spec:    networking.DataplaneTrustMutual,
+		cluster: networking.DataplaneTrustIdentity,
+		want:    networking.DataplaneTrustMutual,
+	}, {
+		name:    "not lowered by the Ingress",
+		spec:    networking.DataplaneTrustEdge,
+		cluster: networking.DataplaneTrustIdentity,
+		want:    networking.DataplaneTrustIdentity,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			is := &IngressSpec{DataplaneTrust: test.spec}
+			if got := is.DataplaneTrustLevel(test.cluster); got != test.want {
+				t.Errorf("DataplaneTrustLevel() = %q, want: %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestHostMatches(t *testing.T) {
+	tests := []struct {
+		pattern string
+		host    string
+		want    bool
+	}{
+		{pattern: "foo.example.com", host: "foo.example.com", want: true},
+		{pattern: "foo.example.com", host: "Foo.Example.com.", want: true},
+		{pattern: "foo.example.com", host: "foo.example.com:8080", want: true},
+		{pattern: "foo.example.com", host: "bar.example.com"},
+		{pattern: "*.example.com", host: "foo.example.com", want: true},
+		{pattern: "*.example.com", host: "bar.foo.example.com", want: true},
+		{pattern: "*.example.com", host: "FOO.example.com:80", want: true},
+		{pattern: "*.example.com", host: "example.com"},
+		{pattern: "*.example.com", host: ".example.com"},
+		{pattern: "*.example.com", host: "fooexample.com"},
+	}
+
+	for _, test := range tests {
+		if got := HostMatches(test.pattern, test.host); got != test.want {
+			t.Errorf("HostMatches(%q, %q) = %v, want: %v", test.pattern, test.host, got, test.want)
+		}
+	}
+}
+
+func TestMatchRule(t *testing.T) {
+	spec := &IngressSpec{Rules: []IngressRule{{
+		Hosts:      []string{"*.example.com"},
+		Visibility: IngressVisibilityExternalIP,
+	}, {
+		Hosts:      []string{"*.foo.example.com"},
+		Visibility: IngressVisibilityExternalIP,
+	}, {
+		Hosts:      []string{"bar.foo.example.com"},
+		Visibility: IngressVisibilityExternalIP,
+	}, {
+		Hosts:      []string{"*.example.com"},
+		Visibility: IngressVisibilityExternalIP,
+	}, {
+		Hosts:      []string{"bar.foo.example.com"},
+		Visibility: IngressVisibilityClusterLocal,
+	}}}
+
+	tests := []struct {
+		host       string
+		visibility IngressVisibility
+		want       int
+	}{
+		// Exact hosts take precedence over the wildcards.
+		{host: "bar.foo.example.com", visibility: IngressVisibilityExternalIP, want: 2},
+		// The longest wildcard takes precedence.
+		{host: "baz.foo.example.com", visibility: IngressVisibilityExternalIP, want: 1},
+		// The first of the equal matches takes precedence.
+		{host: "foo.example.com", visibility: IngressVisibilityExternalIP, want: 0},
+		{host: "bar.foo.example.com", visibility: IngressVisibilityClusterLocal, want: 4},
+		{host: "example.com", visibility: IngressVisibilityExternalIP, want: -1},
+		{host: "baz.foo.example.com", visibility: IngressVisibilityClusterLocal, want: -1},
+	}
+
+	for _, test := range tests {
+		var want *IngressRule
+		if test.want >= 0 {
+			want = &spec.Rules[test.want]
+		}
+		if got := spec.MatchRule(test.host, test.visibility); got != want {
+			t.Errorf("MatchRule(%q, %s) = %v, want rules[%d]", test.host, test.visibility, got, test.want)
+		}
+	}
+}
+
+func TestTLSForVisibility(t *testing.T) {
+	unset := IngressTLS{SecretName: "unset"}
+	local := IngressTLS{SecretName: "local", Visibility: IngressVisibilityClusterLocal}
+	external := IngressTLS{SecretName: "external", Visibility: IngressVisibilityExternalIP}
+	is := &IngressSpec{TLS: []IngressTLS{unset, local, external}}
+
+	if got, want := is.TLSForVisibility(IngressVisibilityClusterLocal), []IngressTLS{unset, local}; !cmp.Equal(got, want) {
+		t.Error("TLSForVisibility(ClusterLocal) (-want, +got) =", cmp.Diff(want, got))
+	}
+	if got, want := is.TLSForVisibility(IngressVisibilityExternalIP), []IngressTLS{unset, external}; !cmp.Equal(got, want) {
+		t.Error("TLSForVisibility(ExternalIP) (-want, +got) =", cmp.Diff(want, got))
+	}
+}
+
+func TestCanonicalRedirect(t *testing.T) {
+	is := &IngressSpec{TLS: []IngressTLS{
+		{Hosts: []string{"foo.example.com"}},
+		{Hosts: []string{"example.com", "www.example.com"}, CanonicalHost: "example.com"},
+	}}
+
+	want := &HTTPRedirect{StatusCode: http.StatusMovedPermanently, Scheme: "https", Host: "example.com"}
+	if got := is.CanonicalRedirect("www.example.com"); !cmp.Equal(got, want) {
+		t.Error("CanonicalRedirect (-want, +got) =", cmp.Diff(want, got))
+	}
+	for _, host := range []string{"example.com", "foo.example.com", "bar.example.com"} {
+		if got := is.CanonicalRedirect(host); got != nil {
+			t.Errorf("CanonicalRedirect(%q) = %v, want: nil", host, got)
+		}
+	}
+}
+
+func TestExtension(t *testing.T) {
+	ing := &Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{
+				"example.com/buffer-size": "512",
+				"example.com/timeout":     "10s",
+			},
+		},
+		Spec: IngressSpec{
+			Extensions: map[string]string{
+				"example.com/buffer-size": "1024",
+				"example.com/empty":       "",
+			},
+		},
+	}
+
+	tests := []struct {
+		name   string
+		key    string
+		want   string
+		wantOK bool
+	}{{
+		name:   "from the spec",
+		key:    "example.com/buffer-size",
+		want:   "1024",
+		wantOK: true,
+	}, {
+		name:   "empty in the spec",
+		key:    "example.com/empty",
+		wantOK: true,
+	}, {
+		name:   "from the annotations",
+		key:    "example.com/timeout",
+		want:   "10s",
+		wantOK: true,
+	}, {
+		name: "unset",
+		key:  "example.com/unset",
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, ok := ing.Extension(test.key)
+			if got != test.want || ok != test.wantOK {
+				t.Errorf("Extension() = (%q, %v), want: (%q, %v)", got, ok, test.want, test.wantOK)
+			}
+		})
+	}
+}
+
+func TestExtensionsWithPrefix(t *testing.T) {
+	is := &IngressSpec{
+		Extensions: map[string]string{
+			"example.com/buffer-size":   "1024",
+			"example.com/timeout":       "10s",
+			"proxy.example.com/timeout": "5s",
+			"example.org/timeout":       "1s",
+		},
+	}
+
+	if got, want := is.ExtensionsWithPrefix("example.com"), map[string]string{
+		"buffer-size": "1024",
+		"timeout":     "10s",
+	}; !cmp.Equal(got, want) {
+		t.Error("ExtensionsWithPrefix (-want, +got) =", cmp.Diff(want, got))
+	}
+	if got := is.ExtensionsWithPrefix("example.net"); got != nil {
+		t.Errorf("ExtensionsWithPrefix() = %v, want: nil", got)
+	}
+}
+
+func TestLoadBalancerServiceAnnotations(t *testing.T) {
+	mapping := LoadBalancerAnnotationMapping{
+		Internal: map[string]string{"networking.gke.io/load-balancer-type": "Internal"},
+		StaticIP: "example.com/static-ip",
+	}
+	tests := []struct {
+		name    string
+		lb      *LoadBalancerOptions
+		mapping LoadBalancerAnnotationMapping
+		want    map[string]string
+	}{{
+		name:    "nil",
+		mapping: mapping,
+	}, {
+		name:    "empty",
+		lb:      &LoadBalancerOptions{},
+		mapping: mapping,
+		want:    map[string]string{},
+	}, {
+		name: "typed options override the pass-through",
+		lb: &LoadBalancerOptions{
+			Internal: true,
+			StaticIP: "10.0.0.10",
+			Annotations: map[string]string{
+				"example.com/static-ip": "10.0.0.1",
+				"example.com/lb-tier":   "premium",
+			},
+		},
+		mapping: mapping,
+		want: map[string]string{
+			"networking.gke.io/load-balancer-type": "Internal",
+			"example.com/static-ip":                "10.0.0.10",
+			"example.com/lb-tier":                  "premium",
+		},
+	}, {
+		name:    "unmapped options",
+		lb:      &LoadBalancerOptions{Internal: true, StaticIP: "10.0.0.10"},
+		mapping: LoadBalancerAnnotationMapping{},
+		want:    map[string]string{},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.lb.ServiceAnnotations(test.mapping); !cmp.Equal(got, test.want) {
+				t.Error("ServiceAnnotations (-want, +got) =", cmp.Diff(test.want, got))
+			}
+		})
+	}
+}
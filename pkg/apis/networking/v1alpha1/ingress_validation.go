@@ -18,10 +18,25 @@ package v1alpha1
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"math"
+	"mime"
+	"net"
+	"net/http"
+	"sort"
 	"strconv"
+	"strings"
+	"time"
 
+	"golang.org/x/net/http/httpguts"
+	"golang.org/x/net/idna"
 	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"knative.dev/networking/pkg/apis/networking"
+	"knative.dev/networking/pkg/config/features"
 	"knative.dev/pkg/apis"
 )
 
@@ -31,6 +46,60 @@ func (i *Ingress) Validate(ctx context.Context) *apis.FieldError {
 	return i.Spec.Validate(apis.WithinSpec(ctx)).ViaField("spec")
 }
 
+// TakesPrecedenceOver returns whether the Ingress owns the hosts it shares
+// with other, e.g. an Ingress of another namespace claiming the same host.
+// The oldest Ingress takes precedence, ties being broken by namespace then
+// name, so that all the implementations agree on the owner of a contested
+// host whatever the order they see the Ingresses in.
+func (i *Ingress) TakesPrecedenceOver(other *Ingress) bool {
+	if !i.CreationTimestamp.Equal(&other.CreationTimestamp) {
+		return i.CreationTimestamp.Before(&other.CreationTimestamp)
+	}
+	if i.Namespace != other.Namespace {
+		return i.Namespace < other.Namespace
+	}
+	return i.Name < other.Name
+}
+
+// ValidateHostConflicts checks that the hosts of the Ingress are not
+// claimed, with the same visibility, by any of others taking precedence over
+// it. It is meant to be used by the implementations to reject or mark the
+// Ingresses losing a contested host, see MarkHostConflict.
+func (i *Ingress) ValidateHostConflicts(others []*Ingress) *apis.FieldError {
+	type claim struct {
+		host       string
+		visibility IngressVisibility
+	}
+	owners := make(map[claim]*Ingress)
+	for _, other := range others {
+		if (other.Namespace == i.Namespace && other.Name == i.Name) ||
+			other.DeletionTimestamp != nil || !other.TakesPrecedenceOver(i) {
+			continue
+		}
+		for _, rule := range other.Spec.Rules {
+			for _, host := range rule.Hosts {
+				c := claim{host: host, visibility: rule.Visibility}
+				// Report the Ingress taking precedence over all the others.
+				if owner, ok := owners[c]; !ok || other.TakesPrecedenceOver(owner) {
+					owners[c] = other
+				}
+			}
+		}
+	}
+
+	var all *apis.FieldError
+	for ridx, rule := range i.Spec.Rules {
+		for hidx, host := range rule.Hosts {
+			if owner, ok := owners[claim{host: host, visibility: rule.Visibility}]; ok {
+				all = all.Also(apis.ErrGeneric(
+					fmt.Sprintf("host %q is already claimed by Ingress %q", host, owner.Namespace+"/"+owner.Name),
+					apis.CurrentField).ViaFieldIndex("hosts", hidx).ViaFieldIndex("rules", ridx))
+			}
+		}
+	}
+	return all.ViaField("spec")
+}
+
 // Validate inspects and validates IngressSpec object.
 func (is *IngressSpec) Validate(ctx context.Context) *apis.FieldError {
 	// Spec must not be empty.
@@ -39,18 +108,293 @@ func (is *IngressSpec) Validate(ctx context.Context) *apis.FieldError {
 	}
 	var all *apis.FieldError
 	// Spec must have at least one rule.
-	if len(is.Rules) == 0 {
+	if len(is.Rules) == 0 && len(is.L4Rules) == 0 {
 		all = all.Also(apis.ErrMissingField("rules"))
 	}
 	// Validate each rule.
 	for idx, rule := range is.Rules {
 		all = all.Also(rule.Validate(ctx).ViaFieldIndex("rules", idx))
 	}
+	all = all.Also(is.validateL4Rules(ctx))
 	// TLS settings are optional.  However, all provided settings should be valid.
 	for idx, tls := range is.TLS {
 		all = all.Also(tls.Validate(ctx).ViaFieldIndex("tls", idx))
 	}
 	all = all.Also(is.HTTPOption.Validate(ctx))
+	if is.DataplaneTrust != "" {
+		all = all.Also(is.DataplaneTrust.Validate(ctx).ViaField("dataplaneTrust"))
+	}
+	all = all.Also(is.validateRuleHTTPOptions())
+	all = all.Also(is.validateTLSVisibility())
+	all = all.Also(is.validateRedirectLoops())
+	all = all.Also(is.validateCanonicalHosts())
+	all = all.Also(validateExtensions(is.Extensions))
+	if is.LoadBalancer != nil {
+		all = all.Also(is.LoadBalancer.Validate(ctx).ViaField("loadBalancer"))
+	}
+	return all
+}
+
+// ExtensionValidator checks the value of an implementation-specific extension
+// of the Ingresses.
+type ExtensionValidator func(value string) error
+
+// extensionValidators holds the validators of the known extensions, keyed by
+// extension key.
+var extensionValidators = make(map[string]ExtensionValidator)
+
+// RegisterExtension registers the validator of the values of the extension
+// with the given key, so that the Ingresses setting invalid values are
+// rejected rather than the values being ignored by the implementation. It is
+// not safe to call concurrently with the validation of Ingresses and is
+// meant to be called from the init functions of the webhooks.
+func RegisterExtension(key string, validate ExtensionValidator) {
+	extensionValidators[key] = validate
+}
+
+// validateExtensions checks that the keys of the extensions are namespaced by
+// a DNS subdomain which isn't reserved, and validates the values of the known
+// extensions.
+func validateExtensions(extensions map[string]string) *apis.FieldError {
+	var all *apis.FieldError
+	for key, value := range extensions {
+		if msgs := validation.IsQualifiedName(key); len(msgs) > 0 {
+			all = all.Also(apis.ErrInvalidKeyName(key, "extensions", msgs...))
+			continue
+		}
+		prefix, _, ok := strings.Cut(key, "/")
+		if !ok {
+			all = all.Also(apis.ErrInvalidKeyName(key, "extensions",
+				"extension keys must be prefixed with a DNS subdomain, e.g. example.com/name"))
+			continue
+		}
+		if isReservedExtensionPrefix(prefix) {
+			all = all.Also(apis.ErrInvalidKeyName(key, "extensions",
+				fmt.Sprintf("the prefix %q is reserved", prefix)))
+			continue
+		}
+		if validate, ok := extensionValidators[key]; ok {
+			if err := validate(value); err != nil {
+				all = all.Also(apis.ErrInvalidValue(value, "extensions["+key+"]", err.Error()))
+			}
+		}
+	}
+	return all
+}
+
+// loadBalancerAnnotations is the allow-list of the annotations of the gateway
+// Services which the Ingresses may pass through.
+var loadBalancerAnnotations = sets.NewString()
+
+// AllowLoadBalancerAnnotations adds the given keys to the allow-list of the
+// annotations of the gateway Services which the Ingresses may pass through,
+// e.g. the ones the implementation knows to be safe for tenants to set. As
+// RegisterExtension, it is meant to be called from the init functions of the
+// webhooks.
+func AllowLoadBalancerAnnotations(keys ...string) {
+	loadBalancerAnnotations.Insert(keys...)
+}
+
+// Validate inspects and validates LoadBalancerOptions object.
+func (lb *LoadBalancerOptions) Validate(ctx context.Context) *apis.FieldError {
+	var all *apis.FieldError
+	if lb.StaticIP != "" && net.ParseIP(lb.StaticIP) == nil && len(validation.IsDNS1123Label(lb.StaticIP)) > 0 {
+		all = all.Also(apis.ErrInvalidValue(lb.StaticIP, "staticIP",
+			"must be an IP address or the name of a reservation"))
+	}
+	for key := range lb.Annotations {
+		if msgs := validation.IsQualifiedName(key); len(msgs) > 0 {
+			all = all.Also(apis.ErrInvalidKeyName(key, "annotations", msgs...))
+		} else if !loadBalancerAnnotations.Has(key) {
+			all = all.Also(apis.ErrInvalidKeyName(key, "annotations",
+				"the annotation is not allowed by the Ingress implementation"))
+		}
+	}
+	return all
+}
+
+// isReservedExtensionPrefix returns whether the prefix belongs to the Knative
+// networking API groups, whose features are part of the API rather than
+// extensions.
+func isReservedExtensionPrefix(prefix string) bool {
+	for _, group := range []string{networking.PublicGroupName, networking.GroupName} {
+		if prefix == group || strings.HasSuffix(prefix, "."+group) {
+			return true
+		}
+	}
+	return false
+}
+
+// validateTLSVisibility checks that the hosts of TLS configurations restricted
+// to a visibility are exposed by rules of that visibility.
+func (is *IngressSpec) validateTLSVisibility() *apis.FieldError {
+	type hostVisibility struct {
+		host       string
+		visibility IngressVisibility
+	}
+	exposed := make(map[hostVisibility]struct{}, len(is.Rules))
+	for _, rule := range is.Rules {
+		visibility := rule.Visibility
+		if visibility == "" {
+			visibility = IngressVisibilityExternalIP
+		}
+		for _, host := range rule.Hosts {
+			exposed[hostVisibility{host, visibility}] = struct{}{}
+		}
+	}
+
+	var all *apis.FieldError
+	for idx, tls := range is.TLS {
+		if tls.Visibility == "" {
+			continue
+		}
+		for j, host := range tls.Hosts {
+			if _, ok := exposed[hostVisibility{host, tls.Visibility}]; !ok {
+				all = all.Also((&apis.FieldError{
+					Message: fmt.Sprintf("host %q is not exposed by any rule with visibility %s", host, tls.Visibility),
+					Paths:   []string{fmt.Sprintf("hosts[%d]", j)},
+				}).ViaFieldIndex("tls", idx))
+			}
+		}
+	}
+	return all
+}
+
+// validateL4Rules validates the L4 rules of the spec, checking that they
+// are allowed and that no two rules claim the same port.
+func (is *IngressSpec) validateL4Rules(ctx context.Context) *apis.FieldError {
+	if len(is.L4Rules) == 0 {
+		return nil
+	}
+	if features.FromContextOrDefaults(ctx).Flag(features.L4Rules) == features.Disabled {
+		return &apis.FieldError{
+			Message: fmt.Sprintf("L4 rules require the %s feature", features.L4Rules),
+			Paths:   []string{"l4Rules"},
+		}
+	}
+	type claim struct {
+		port       int32
+		protocol   L4Protocol
+		visibility IngressVisibility
+	}
+	owners := make(map[claim]int, len(is.L4Rules))
+	var all *apis.FieldError
+	for idx := range is.L4Rules {
+		rule := &is.L4Rules[idx]
+		all = all.Also(rule.Validate(ctx).ViaFieldIndex("l4Rules", idx))
+		c := claim{port: rule.Port, protocol: rule.Protocol, visibility: rule.Visibility}
+		if c.protocol == "" {
+			c.protocol = L4ProtocolTCP
+		}
+		if c.visibility == "" {
+			c.visibility = IngressVisibilityExternalIP
+		}
+		if owner, ok := owners[c]; ok {
+			all = all.Also(apis.ErrGeneric(
+				fmt.Sprintf("%s port %d is already exposed by l4Rules[%d]", c.protocol, c.port, owner),
+				"port").ViaFieldIndex("l4Rules", idx))
+			continue
+		}
+		owners[c] = idx
+	}
+	return all
+}
+
+// Validate inspects and validates IngressL4Rule object.
+func (r *IngressL4Rule) Validate(ctx context.Context) *apis.FieldError {
+	var all *apis.FieldError
+	if r.Port < 1 || r.Port > math.MaxUint16 {
+		all = all.Also(apis.ErrOutOfBoundsValue(r.Port, 1, math.MaxUint16, "port"))
+	}
+	switch r.Protocol {
+	case "", L4ProtocolTCP, L4ProtocolUDP:
+	default:
+		all = all.Also(apis.ErrInvalidValue(r.Protocol, "protocol"))
+	}
+	switch r.Visibility {
+	case "", IngressVisibilityExternalIP, IngressVisibilityClusterLocal:
+	default:
+		all = all.Also(apis.ErrInvalidValue(r.Visibility, "visibility"))
+	}
+	all = all.Also(r.Backend.Validate(ctx).ViaField("backend"))
+	return all
+}
+
+// validateCanonicalHosts checks that the canonical hosts of the TLS
+// configurations are served by the rules, and that the hosts redirected to
+// them belong to rules and are redirected by no other means.
+func (is *IngressSpec) validateCanonicalHosts() *apis.FieldError {
+	// rules holds the index of the first rule of each host.
+	rules := make(map[string]int, len(is.Rules))
+	for idx := len(is.Rules) - 1; idx >= 0; idx-- {
+		for _, host := range is.Rules[idx].Hosts {
+			rules[host] = idx
+		}
+	}
+	// canonical holds the canonical host each host is redirected to.
+	canonical := make(map[string]string)
+
+	var all *apis.FieldError
+	for idx, tls := range is.TLS {
+		if tls.CanonicalHost == "" {
+			continue
+		}
+		if !sets.NewString(tls.Hosts...).Has(tls.CanonicalHost) {
+			all = all.Also(apis.ErrInvalidValue(tls.CanonicalHost, "canonicalHost",
+				"must be one of the hosts of the TLS configuration").ViaFieldIndex("tls", idx))
+			continue
+		}
+		if ridx, ok := rules[tls.CanonicalHost]; !ok {
+			all = all.Also(apis.ErrInvalidValue(tls.CanonicalHost, "canonicalHost",
+				"is not the host of any rule").ViaFieldIndex("tls", idx))
+		} else if rule := &is.Rules[ridx]; rule.HTTP == nil || len(rule.Redirects) > 0 {
+			all = all.Also(apis.ErrInvalidValue(tls.CanonicalHost, "canonicalHost",
+				fmt.Sprintf("must be served rather than redirected by rules[%d]", ridx)).ViaFieldIndex("tls", idx))
+		}
+		for j, host := range tls.Hosts {
+			if host == tls.CanonicalHost {
+				continue
+			}
+			var msg string
+			if ridx, ok := rules[host]; !ok {
+				msg = fmt.Sprintf("host %q is redirected to %q but is not the host of any rule", host, tls.CanonicalHost)
+			} else if len(is.Rules[ridx].Redirects) > 0 {
+				msg = fmt.Sprintf("host %q is redirected both to %q and by the redirects of rules[%d]", host, tls.CanonicalHost, ridx)
+			} else if other, ok := canonical[host]; ok && other != tls.CanonicalHost {
+				msg = fmt.Sprintf("host %q is redirected both to %q and to %q", host, other, tls.CanonicalHost)
+			} else {
+				canonical[host] = tls.CanonicalHost
+				continue
+			}
+			all = all.Also((&apis.FieldError{
+				Message: msg,
+				Paths:   []string{fmt.Sprintf("hosts[%d]", j)},
+			}).ViaFieldIndex("tls", idx))
+		}
+	}
+	return all
+}
+
+// validateRuleHTTPOptions checks that rules sharing a host agree on
+// the HTTPOption applying to it.
+func (is *IngressSpec) validateRuleHTTPOptions() *apis.FieldError {
+	var all *apis.FieldError
+	owners := make(map[string]int, len(is.Rules))
+	for idx := range is.Rules {
+		rule := &is.Rules[idx]
+		opt := is.RuleHTTPOption(rule)
+		for _, host := range rule.Hosts {
+			owner, ok := owners[host]
+			if !ok {
+				owners[host] = idx
+				continue
+			}
+			if other := is.RuleHTTPOption(&is.Rules[owner]); other != opt {
+				all = all.Also(apis.ErrInvalidValue(opt, "httpOption",
+					fmt.Sprintf("conflicts with %s of rules[%d] for host %q", other, owner, host)).ViaFieldIndex("rules", idx))
+			}
+		}
+	}
 	return all
 }
 
@@ -61,10 +405,253 @@ func (r *IngressRule) Validate(ctx context.Context) *apis.FieldError {
 		return apis.ErrMissingField(apis.CurrentField)
 	}
 	var all *apis.FieldError
-	if r.HTTP == nil {
-		all = all.Also(apis.ErrMissingField("http"))
-	} else {
+	if r.HTTP != nil {
 		all = all.Also(r.HTTP.Validate(ctx).ViaField("http"))
+	} else if len(r.Redirects) == 0 {
+		all = all.Also(apis.ErrMissingField("http"))
+	}
+	for idx, redirect := range r.Redirects {
+		all = all.Also(redirect.Validate(ctx).ViaFieldIndex("redirects", idx))
+	}
+	if r.SourceIPPolicy != nil {
+		all = all.Also(r.SourceIPPolicy.Validate(ctx).ViaField("sourceIPPolicy"))
+	}
+	all = all.Also(r.HTTPOption.Validate(ctx))
+	all = all.Also(r.validateErrorPages(ctx))
+	all = all.Also(validateHosts(r.Hosts))
+	if r.Visibility == IngressVisibilityExternalIP {
+		// Exposing cluster-local hosts is most likely a mistake, e.g. a rule
+		// meant to be ClusterLocal but missing its visibility.
+		for idx, host := range r.Hosts {
+			if isClusterLocalHost(ctx, host) {
+				all = all.Also(apis.ErrGeneric(
+					fmt.Sprintf("host %q is cluster-local but exposed with visibility %s", host, r.Visibility),
+					apis.CurrentField).ViaFieldIndex("hosts", idx).At(apis.WarningLevel))
+			}
+		}
+	}
+	return all
+}
+
+// Validate inspects and validates HTTPRedirect object.
+func (r *HTTPRedirect) Validate(ctx context.Context) *apis.FieldError {
+	var all *apis.FieldError
+	if r.Scheme == "" && r.Host == "" && r.ReplacePrefix == "" {
+		all = all.Also(apis.ErrMissingOneOf("scheme", "host", "replacePrefix"))
+	}
+	if r.Path != "" && !strings.HasPrefix(r.Path, "/") {
+		all = all.Also(apis.ErrInvalidValue(r.Path, "path", "paths must begin with a '/'"))
+	}
+	if r.ReplacePrefix != "" && !strings.HasPrefix(r.ReplacePrefix, "/") {
+		all = all.Also(apis.ErrInvalidValue(r.ReplacePrefix, "replacePrefix", "paths must begin with a '/'"))
+	}
+	switch r.StatusCode {
+	case 0, http.StatusMovedPermanently, http.StatusFound, http.StatusPermanentRedirect:
+	default:
+		all = all.Also(apis.ErrInvalidValue(r.StatusCode, "statusCode", "must be one of 301, 302 and 308"))
+	}
+	switch r.Scheme {
+	case "", "http", "https":
+	default:
+		all = all.Also(apis.ErrInvalidValue(r.Scheme, "scheme", "must be either http or https"))
+	}
+	if r.Host != "" {
+		if msgs := validation.IsDNS1123Subdomain(r.Host); len(msgs) > 0 {
+			all = all.Also(apis.ErrInvalidValue(r.Host, "host", strings.Join(msgs, ", ")))
+		}
+	}
+	return all
+}
+
+// applies returns whether the redirect applies to the requests to path
+// using scheme. Redirects only setting the scheme don't apply to the
+// requests already using it.
+func (r *HTTPRedirect) applies(scheme, path string) bool {
+	if r.Host == "" && r.ReplacePrefix == "" && r.Scheme == scheme {
+		return false
+	}
+	return strings.HasPrefix(path, r.Path)
+}
+
+// redirectTarget returns the scheme, host and path the redirect sends the
+// requests using scheme to host and path to.
+func (r *HTTPRedirect) redirectTarget(scheme, host, path string) (string, string, string) {
+	if r.Scheme != "" {
+		scheme = r.Scheme
+	}
+	if r.Host != "" {
+		host = r.Host
+	}
+	if r.ReplacePrefix != "" {
+		path = r.ReplacePrefix + strings.TrimPrefix(path, r.Path)
+	}
+	return scheme, host, path
+}
+
+// matchRedirect returns the redirect of the spec applying to the requests
+// to host and path using scheme, if any, following the precedence of the
+// rules and of their redirects.
+func (is *IngressSpec) matchRedirect(scheme, host, path string) *HTTPRedirect {
+	for i := range is.Rules {
+		rule := &is.Rules[i]
+		if !sets.NewString(rule.Hosts...).Has(host) {
+			continue
+		}
+		for j := range rule.Redirects {
+			if rule.Redirects[j].applies(scheme, path) {
+				return &rule.Redirects[j]
+			}
+		}
+		return nil
+	}
+	return nil
+}
+
+// validateRedirectLoops checks that following the redirects of the spec
+// never leads back to an URL already redirected, whatever the scheme of the
+// requests, as clients would otherwise be redirected forever.
+func (is *IngressSpec) validateRedirectLoops() *apis.FieldError {
+	var all *apis.FieldError
+	for ridx, rule := range is.Rules {
+		for didx := range rule.Redirects {
+			redirect := &rule.Redirects[didx]
+			if redirect.Scheme == "" && redirect.Host == "" && redirect.ReplacePrefix == "" {
+				// Already reported by the validation of the redirect.
+				continue
+			}
+			for _, host := range rule.Hosts {
+				if url, ok := is.findRedirectLoop(host, redirect); ok {
+					all = all.Also(apis.ErrGeneric(
+						fmt.Sprintf("redirecting %s%s leads to a redirect loop through %s", host, redirect.Path, url),
+						apis.CurrentField).ViaFieldIndex("redirects", didx).ViaFieldIndex("rules", ridx))
+					break
+				}
+			}
+		}
+	}
+	return all
+}
+
+// findRedirectLoop follows the redirects of the spec from the requests to
+// host matched by redirect, returning the URL redirected again by a redirect
+// already applied to its host, if any. Such URLs are redirected forever,
+// possibly to ever longer paths, e.g. when `/a/b` replaces the prefix `/a`.
+func (is *IngressSpec) findRedirectLoop(host string, redirect *HTTPRedirect) (string, bool) {
+	type step struct {
+		scheme   string
+		host     string
+		redirect *HTTPRedirect
+	}
+	for _, scheme := range []string{"http", "https"} {
+		if !redirect.applies(scheme, redirect.Path) {
+			continue
+		}
+		host, path := host, redirect.Path
+		visited := make(map[step]bool)
+		for r := redirect; r != nil; r = is.matchRedirect(scheme, host, path) {
+			if visited[step{scheme, host, r}] {
+				return scheme + "://" + host + path, true
+			}
+			visited[step{scheme, host, r}] = true
+			scheme, host, path = r.redirectTarget(scheme, host, path)
+		}
+	}
+	return "", false
+}
+
+// Validate inspects and validates SourceIPPolicy object.
+func (p *SourceIPPolicy) Validate(ctx context.Context) *apis.FieldError {
+	if len(p.Allow) == 0 && len(p.Deny) == 0 {
+		return apis.ErrMissingOneOf("allow", "deny")
+	}
+	var all *apis.FieldError
+	for idx, cidr := range p.Allow {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			all = all.Also(apis.ErrInvalidArrayValue(cidr, "allow", idx))
+		}
+	}
+	for idx, cidr := range p.Deny {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			all = all.Also(apis.ErrInvalidArrayValue(cidr, "deny", idx))
+		}
+	}
+	return all
+}
+
+// validateErrorPages validates the ErrorPages of the rule, checking no
+// status code is listed by multiple pages.
+func (r *IngressRule) validateErrorPages(ctx context.Context) *apis.FieldError {
+	var all *apis.FieldError
+	owners := make(map[string]int, len(r.ErrorPages))
+	for idx := range r.ErrorPages {
+		page := &r.ErrorPages[idx]
+		all = all.Also(page.Validate(ctx).ViaFieldIndex("errorPages", idx))
+		for cidx, code := range page.StatusCodes {
+			if owner, ok := owners[code]; ok {
+				all = all.Also(apis.ErrGeneric(
+					fmt.Sprintf("status code %q is already handled by errorPages[%d]", code, owner),
+					apis.CurrentField).ViaFieldIndex("statusCodes", cidx).ViaFieldIndex("errorPages", idx))
+				continue
+			}
+			owners[code] = idx
+		}
+	}
+	return all
+}
+
+// Validate inspects and validates ErrorPage object.
+func (p *ErrorPage) Validate(ctx context.Context) *apis.FieldError {
+	var all *apis.FieldError
+	if len(p.StatusCodes) == 0 {
+		all = all.Also(apis.ErrMissingField("statusCodes"))
+	}
+	for idx, code := range p.StatusCodes {
+		if !isErrorStatusCode(code) {
+			all = all.Also(apis.ErrInvalidArrayValue(code, "statusCodes", idx))
+		}
+	}
+	switch {
+	case p.Backend == nil && p.Body == nil:
+		all = all.Also(apis.ErrMissingOneOf("backend", "body"))
+	case p.Backend != nil && p.Body != nil:
+		all = all.Also(apis.ErrMultipleOneOf("backend", "body"))
+	case p.Backend != nil:
+		all = all.Also(p.Backend.Validate(ctx).ViaField("backend"))
+	default:
+		all = all.Also(p.Body.Validate(ctx).ViaField("body"))
+	}
+	return all
+}
+
+// isErrorStatusCode returns whether code is a 4xx or 5xx status code, e.g.
+// `404`, or one of these classes, i.e. `4xx` or `5xx`.
+func isErrorStatusCode(code string) bool {
+	if len(code) != 3 || (code[0] != '4' && code[0] != '5') {
+		return false
+	}
+	if code[1:] == "xx" {
+		return true
+	}
+	return '0' <= code[1] && code[1] <= '9' && '0' <= code[2] && code[2] <= '9'
+}
+
+// Validate inspects and validates ErrorPageBody object.
+func (b *ErrorPageBody) Validate(context.Context) *apis.FieldError {
+	var all *apis.FieldError
+	if b.ConfigMapName == "" {
+		all = all.Also(apis.ErrMissingField("configMapName"))
+	} else if errs := validation.IsDNS1123Subdomain(b.ConfigMapName); len(errs) > 0 {
+		all = all.Also(apis.ErrInvalidValue(b.ConfigMapName, "configMapName", strings.Join(errs, ", ")))
+	}
+	if b.Key == "" {
+		all = all.Also(apis.ErrMissingField("key"))
+	} else if errs := validation.IsConfigMapKey(b.Key); len(errs) > 0 {
+		all = all.Also(apis.ErrInvalidValue(b.Key, "key", strings.Join(errs, ", ")))
+	}
+	if b.ContentType != "" {
+		if _, _, err := mime.ParseMediaType(b.ContentType); err != nil {
+			all = all.Also(apis.ErrInvalidValue(b.ContentType, "contentType", err.Error()))
+		}
 	}
 	return all
 }
@@ -91,16 +678,27 @@ func (h HTTPIngressPath) Validate(ctx context.Context) *apis.FieldError {
 	if len(h.Splits) == 0 {
 		all = all.Also(apis.ErrMissingField("splits"))
 	} else {
-		totalPct := 0
+		totalPct, pctSplits := 0, 0
 		for idx, split := range h.Splits {
 			if err := split.Validate(ctx); err != nil {
 				return err.ViaFieldIndex("splits", idx)
 			}
-			totalPct += split.Percent
+			// Splits selected by headers don't take part in the
+			// percentage-based distribution.
+			if len(split.Headers) == 0 {
+				totalPct += split.Percent
+				pctSplits++
+			}
 		}
-		// If a single split is provided we allow missing Percent, and
-		// interpret as 100%.
-		if (len(h.Splits) != 1 || totalPct != 0) && totalPct != 100 {
+		if pctSplits == 0 {
+			// The requests not matching any header must go somewhere.
+			all = all.Also(&apis.FieldError{
+				Message: "at least one split must not be selected by headers",
+				Paths:   []string{"splits"},
+			})
+		} else if (pctSplits != 1 || totalPct != 0) && totalPct != 100 {
+			// If a single split is provided we allow missing Percent, and
+			// interpret as 100%.
 			// Total traffic split percentage must sum up to 100%.
 			all = all.Also(&apis.FieldError{
 				Message: "traffic split percentage must total to 100, but was " + strconv.Itoa(totalPct),
@@ -108,10 +706,66 @@ func (h HTTPIngressPath) Validate(ctx context.Context) *apis.FieldError {
 			})
 		}
 	}
+	if h.RewriteHost != "" {
+		if errs := validation.IsDNS1123Subdomain(h.RewriteHost); len(errs) > 0 {
+			all = all.Also(apis.ErrInvalidValue(h.RewriteHost, "rewriteHost", strings.Join(errs, ", ")))
+		}
+	}
+	all = all.Also(validateHeaderModifiers(h.AppendHeaders, h.SetHeaders, h.RemoveHeaders))
+	if h.MaxRequestBodyBytes != nil && *h.MaxRequestBodyBytes <= 0 {
+		all = all.Also(apis.ErrOutOfBoundsValue(*h.MaxRequestBodyBytes, 1, math.MaxInt64, "maxRequestBodyBytes"))
+	}
+	if h.IdleTimeout != nil && h.IdleTimeout.Duration < time.Millisecond {
+		all = all.Also(apis.ErrInvalidValue(h.IdleTimeout.Duration.String(), "idleTimeout",
+			"the idle timeout must be at least 1ms"))
+	}
+	if h.ConnectTimeout != nil && h.ConnectTimeout.Duration < time.Millisecond {
+		all = all.Also(apis.ErrInvalidValue(h.ConnectTimeout.Duration.String(), "connectTimeout",
+			"the connect timeout must be at least 1ms"))
+	}
+	if h.Compression != nil {
+		all = all.Also(h.Compression.Validate(ctx).ViaField("compression"))
+	}
 
 	return all
 }
 
+// Validate inspects and validates Compression object.
+func (c *Compression) Validate(context.Context) *apis.FieldError {
+	var all *apis.FieldError
+	switch c.Mode {
+	case CompressionEnabled:
+		if c.MinSizeBytes != nil && *c.MinSizeBytes < 0 {
+			all = all.Also(apis.ErrOutOfBoundsValue(*c.MinSizeBytes, 0, math.MaxInt64, "minSizeBytes"))
+		}
+		seen := make(sets.String, len(c.ContentTypes))
+		for i, ct := range c.ContentTypes {
+			mt, params, err := mime.ParseMediaType(ct)
+			switch {
+			case err != nil:
+				all = all.Also(apis.ErrInvalidValue(ct, apis.CurrentField, err.Error()).ViaFieldIndex("contentTypes", i))
+			case len(params) > 0:
+				all = all.Also(apis.ErrInvalidValue(ct, apis.CurrentField, "media type parameters are not allowed").ViaFieldIndex("contentTypes", i))
+			case seen.Has(mt):
+				all = all.Also(apis.ErrGeneric("duplicate media type "+mt, apis.CurrentField).ViaFieldIndex("contentTypes", i))
+			}
+			seen.Insert(mt)
+		}
+	case CompressionDisabled:
+		if c.MinSizeBytes != nil {
+			all = all.Also(apis.ErrDisallowedFields("minSizeBytes"))
+		}
+		if len(c.ContentTypes) > 0 {
+			all = all.Also(apis.ErrDisallowedFields("contentTypes"))
+		}
+	case "":
+		all = all.Also(apis.ErrMissingField("mode"))
+	default:
+		all = all.Also(apis.ErrInvalidValue(c.Mode, "mode"))
+	}
+	return all
+}
+
 // Validate inspects and validates HTTPIngressPath object.
 func (s IngressBackendSplit) Validate(ctx context.Context) *apis.FieldError {
 	// Must not be empty.
@@ -123,9 +777,238 @@ func (s IngressBackendSplit) Validate(ctx context.Context) *apis.FieldError {
 	if s.Percent < 0 || s.Percent > 100 {
 		all = all.Also(apis.ErrInvalidValue(s.Percent, "percent"))
 	}
+	if len(s.Headers) > 0 {
+		if s.Percent != 0 {
+			all = all.Also(apis.ErrMultipleOneOf("headers", "percent"))
+		}
+		for k, m := range s.Headers {
+			if !httpguts.ValidHeaderFieldName(k) {
+				all = all.Also(apis.ErrInvalidKeyName(k, "headers"))
+			} else if m.Exact == "" {
+				all = all.Also(apis.ErrMissingField("exact").ViaKey(k).ViaField("headers"))
+			}
+		}
+	}
+	all = all.Also(validateHeaderModifiers(s.AppendHeaders, s.SetHeaders, s.RemoveHeaders))
+	if s.LoadBalancerPolicy != nil {
+		all = all.Also(s.LoadBalancerPolicy.Validate(ctx).ViaField("loadBalancerPolicy"))
+	}
+	if s.UpstreamTLS != nil {
+		all = all.Also(s.UpstreamTLS.Validate(ctx).ViaField("upstreamTLS"))
+	}
+	if s.OutlierDetection != nil {
+		all = all.Also(s.OutlierDetection.Validate(ctx).ViaField("outlierDetection"))
+	}
+	if s.HealthCheck != nil {
+		all = all.Also(s.HealthCheck.Validate(ctx).ViaField("healthCheck"))
+	}
 	return all.Also(s.IngressBackend.Validate(ctx))
 }
 
+// reservedHeaders are the headers the Ingresses can't modify, as the
+// networking layer relies on them, e.g. to route the probes to the right
+// version of the Ingresses.
+var reservedHeaders = sets.NewString("Host", "Forwarded")
+
+// reservedHeaderPrefixes are the prefixes of the names of the headers the
+// Ingresses can't modify.
+var reservedHeaderPrefixes = []string{"K-Network-", "X-Forwarded-"}
+
+// isReservedHeader returns whether the header can't be modified.
+func isReservedHeader(name string) bool {
+	name = http.CanonicalHeaderKey(name)
+	if reservedHeaders.Has(name) {
+		return true
+	}
+	for _, prefix := range reservedHeaderPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// validateHeaderModifiers validates the headers appended, set and removed,
+// which must not be reserved to the networking layer, nor be modified in
+// more than one way.
+func validateHeaderModifiers(appendHeaders, setHeaders map[string]string, removeHeaders []string) *apis.FieldError {
+	modified := make(map[string]string, len(appendHeaders)+len(setHeaders)+len(removeHeaders))
+	all := validateHeaderNames("appendHeaders", sortedHeaderNames(appendHeaders), false, modified)
+	all = all.Also(validateHeaderNames("setHeaders", sortedHeaderNames(setHeaders), false, modified))
+	return all.Also(validateHeaderNames("removeHeaders", removeHeaders, true, modified))
+}
+
+// sortedHeaderNames returns the names of the headers, sorted for the errors
+// to be stable.
+func sortedHeaderNames(headers map[string]string) []string {
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// validateHeaderNames validates the names of the headers modified through
+// field, a list if indexed and a map otherwise. modified maps the canonical
+// names of the headers modified through the other fields to these fields,
+// and is updated with the headers of field.
+func validateHeaderNames(field string, names []string, indexed bool, modified map[string]string) *apis.FieldError {
+	invalid := func(i int, name string, details ...string) *apis.FieldError {
+		if indexed {
+			return apis.ErrInvalidValue(name, apis.CurrentField, details...).ViaFieldIndex(field, i)
+		}
+		return apis.ErrInvalidKeyName(name, field, details...)
+	}
+
+	var all *apis.FieldError
+	seen := make(map[string]string, len(names))
+	for i, name := range names {
+		canonical := http.CanonicalHeaderKey(name)
+		switch {
+		case !httpguts.ValidHeaderFieldName(name):
+			all = all.Also(invalid(i, name))
+			continue
+		case canonical == "Host":
+			// The implementations disagree on which of the Host header and
+			// the rewritten host wins, so only the latter is allowed.
+			all = all.Also(invalid(i, name, "the Host header can only be rewritten with the rewriteHost of the path"))
+		case isReservedHeader(name):
+			all = all.Also(invalid(i, name, "the header is reserved to the networking layer"))
+		}
+		if other, ok := seen[canonical]; ok {
+			msg := fmt.Sprintf("headers %q and %q are the same header, header names are case-insensitive", other, name)
+			if other == name {
+				msg = fmt.Sprintf("header %q is listed more than once", name)
+			}
+			all = all.Also(&apis.FieldError{
+				Message: msg,
+				Paths:   []string{field},
+			})
+		} else if other, ok := modified[canonical]; ok {
+			all = all.Also(&apis.FieldError{
+				Message: fmt.Sprintf("header %q can't be in both %s and %s", name, other, field),
+				Paths:   []string{other, field},
+			})
+		}
+		if _, ok := seen[canonical]; !ok {
+			seen[canonical] = name
+		}
+	}
+	for canonical := range seen {
+		if _, ok := modified[canonical]; !ok {
+			modified[canonical] = field
+		}
+	}
+	return all
+}
+
+// Validate inspects and validates UpstreamTLS object.
+func (u *UpstreamTLS) Validate(ctx context.Context) *apis.FieldError {
+	var all *apis.FieldError
+	if u.CASecretName == "" {
+		all = all.Also(apis.ErrMissingField("caSecretName"))
+	}
+	if u.CASecretNamespace == "" {
+		all = all.Also(apis.ErrMissingField("caSecretNamespace"))
+	}
+	if u.ServerName != "" {
+		if errs := validation.IsDNS1123Subdomain(u.ServerName); len(errs) > 0 {
+			all = all.Also(apis.ErrInvalidValue(u.ServerName, "serverName", strings.Join(errs, ", ")))
+		}
+	}
+	return all
+}
+
+// Validate inspects and validates OutlierDetection object.
+func (o *OutlierDetection) Validate(ctx context.Context) *apis.FieldError {
+	var all *apis.FieldError
+	if o.ConsecutiveErrors < 1 {
+		all = all.Also(apis.ErrOutOfBoundsValue(o.ConsecutiveErrors, 1, math.MaxInt32, "consecutiveErrors"))
+	}
+	if o.EjectionInterval != nil && o.EjectionInterval.Duration < time.Millisecond {
+		all = all.Also(apis.ErrInvalidValue(o.EjectionInterval.Duration.String(), "ejectionInterval",
+			"the ejection interval must be at least 1ms"))
+	}
+	if o.MaxEjectionPercent != nil && (*o.MaxEjectionPercent < 0 || *o.MaxEjectionPercent > 100) {
+		all = all.Also(apis.ErrOutOfBoundsValue(*o.MaxEjectionPercent, 0, 100, "maxEjectionPercent"))
+	}
+	return all
+}
+
+// Validate inspects and validates HealthCheck object.
+func (h *HealthCheck) Validate(ctx context.Context) *apis.FieldError {
+	var all *apis.FieldError
+	if h.Path == "" {
+		all = all.Also(apis.ErrMissingField("path"))
+	} else if !strings.HasPrefix(h.Path, "/") {
+		all = all.Also(apis.ErrInvalidValue(h.Path, "path", "paths must begin with a '/'"))
+	}
+	if h.Interval != nil && h.Interval.Duration < time.Millisecond {
+		all = all.Also(apis.ErrInvalidValue(h.Interval.Duration.String(), "interval",
+			"the interval must be at least 1ms"))
+	}
+	if h.HealthyThreshold != nil && *h.HealthyThreshold < 1 {
+		all = all.Also(apis.ErrOutOfBoundsValue(*h.HealthyThreshold, 1, math.MaxInt32, "healthyThreshold"))
+	}
+	if h.UnhealthyThreshold != nil && *h.UnhealthyThreshold < 1 {
+		all = all.Also(apis.ErrOutOfBoundsValue(*h.UnhealthyThreshold, 1, math.MaxInt32, "unhealthyThreshold"))
+	}
+	return all
+}
+
+// Validate inspects and validates LoadBalancerPolicy object.
+func (p *LoadBalancerPolicy) Validate(ctx context.Context) *apis.FieldError {
+	var all *apis.FieldError
+	switch p.Type {
+	case LoadBalancerPolicyRoundRobin, LoadBalancerPolicyLeastRequest:
+		if p.HashKey != nil {
+			all = all.Also(apis.ErrDisallowedFields("hashKey"))
+		}
+	case LoadBalancerPolicyRingHash:
+		if p.HashKey == nil {
+			all = all.Also(apis.ErrMissingField("hashKey"))
+		} else {
+			all = all.Also(p.HashKey.Validate(ctx).ViaField("hashKey"))
+		}
+	case "":
+		all = all.Also(apis.ErrMissingField("type"))
+	default:
+		all = all.Also(apis.ErrInvalidValue(p.Type, "type"))
+	}
+	return all
+}
+
+// Validate inspects and validates LoadBalancerHashKey object.
+func (k *LoadBalancerHashKey) Validate(ctx context.Context) *apis.FieldError {
+	var set []string
+	if k.Header != "" {
+		set = append(set, "header")
+	}
+	if k.Cookie != "" {
+		set = append(set, "cookie")
+	}
+	if k.SourceIP {
+		set = append(set, "sourceIP")
+	}
+	switch {
+	case len(set) == 0:
+		return apis.ErrMissingOneOf("header", "cookie", "sourceIP")
+	case len(set) > 1:
+		return apis.ErrMultipleOneOf(set...)
+	}
+
+	var all *apis.FieldError
+	if k.Header != "" && !httpguts.ValidHeaderFieldName(k.Header) {
+		all = all.Also(apis.ErrInvalidValue(k.Header, "header"))
+	}
+	if k.Cookie != "" && !httpguts.ValidHeaderFieldName(k.Cookie) {
+		// Cookie names follow the same token grammar as header names.
+		all = all.Also(apis.ErrInvalidValue(k.Cookie, "cookie"))
+	}
+	return all
+}
+
 // Validate inspects the fields of the type IngressBackend
 // to determine if they are valid.
 func (b IngressBackend) Validate(ctx context.Context) *apis.FieldError {
@@ -133,6 +1016,9 @@ func (b IngressBackend) Validate(ctx context.Context) *apis.FieldError {
 	if equality.Semantic.DeepEqual(b, IngressBackend{}) {
 		return apis.ErrMissingField(apis.CurrentField)
 	}
+	if b.External != nil {
+		return b.validateExternal(ctx)
+	}
 	var all *apis.FieldError
 	if b.ServiceNamespace == "" {
 		all = all.Also(apis.ErrMissingField("serviceNamespace"))
@@ -151,6 +1037,52 @@ func (b IngressBackend) Validate(ctx context.Context) *apis.FieldError {
 	return all
 }
 
+// validateExternal validates a backend routing the traffic outside of the
+// cluster, which is only allowed if the external-backends feature isn't
+// disabled.
+func (b IngressBackend) validateExternal(ctx context.Context) *apis.FieldError {
+	if features.FromContextOrDefaults(ctx).Flag(features.ExternalBackends) == features.Disabled {
+		return &apis.FieldError{
+			Message: fmt.Sprintf("external backends require the %s feature", features.ExternalBackends),
+			Paths:   []string{"external"},
+		}
+	}
+	var all *apis.FieldError
+	if b.ServiceNamespace != "" {
+		all = all.Also(apis.ErrMultipleOneOf("external", "serviceNamespace"))
+	}
+	if b.ServiceName != "" {
+		all = all.Also(apis.ErrMultipleOneOf("external", "serviceName"))
+	}
+	if !equality.Semantic.DeepEqual(b.ServicePort, intstr.IntOrString{}) {
+		all = all.Also(apis.ErrMultipleOneOf("external", "servicePort"))
+	}
+	return all.Also(b.External.Validate(ctx).ViaField("external"))
+}
+
+// Validate inspects and validates an ExternalBackend.
+func (e *ExternalBackend) Validate(ctx context.Context) *apis.FieldError {
+	var all *apis.FieldError
+	switch {
+	case e.ServiceName == "" && e.Host == "":
+		all = all.Also(apis.ErrMissingOneOf("serviceName", "host"))
+	case e.ServiceName != "" && e.Host != "":
+		all = all.Also(apis.ErrMultipleOneOf("serviceName", "host"))
+	case e.ServiceName != "":
+		for _, msg := range validation.IsDNS1035Label(e.ServiceName) {
+			all = all.Also(apis.ErrInvalidValue(e.ServiceName, "serviceName", msg))
+		}
+	case net.ParseIP(e.Host) == nil:
+		for _, msg := range validation.IsDNS1123Subdomain(e.Host) {
+			all = all.Also(apis.ErrInvalidValue(e.Host, "host", msg))
+		}
+	}
+	if e.Port < 1 || e.Port > math.MaxUint16 {
+		all = all.Also(apis.ErrOutOfBoundsValue(e.Port, 1, math.MaxUint16, "port"))
+	}
+	return all
+}
+
 // Validate inspects and validates IngressTLS object.
 func (t *IngressTLS) Validate(ctx context.Context) *apis.FieldError {
 	// Provided TLS setting must not be empty.
@@ -165,9 +1097,75 @@ func (t *IngressTLS) Validate(ctx context.Context) *apis.FieldError {
 	if t.SecretNamespace == "" {
 		all = all.Also(apis.ErrMissingField("secretNamespace"))
 	}
+	switch t.Visibility {
+	case "", IngressVisibilityExternalIP, IngressVisibilityClusterLocal:
+	default:
+		all = all.Also(apis.ErrInvalidValue(t.Visibility, "visibility"))
+	}
+	all = all.Also(validateHosts(t.Hosts))
+	return all
+}
+
+// validateHosts checks that the hosts are in their canonical form, see
+// normalizeHost, so that the implementations can match them against the
+// Host headers and SNI server names without normalizing them.
+func validateHosts(hosts []string) *apis.FieldError {
+	var all *apis.FieldError
+	for idx, host := range hosts {
+		if err := validateWildcard(host); err != nil {
+			all = all.Also(apis.ErrInvalidValue(host, apis.CurrentField, err.Error()).ViaFieldIndex("hosts", idx))
+			continue
+		}
+		normalized, err := normalizeHost(host)
+		if err != nil {
+			all = all.Also(apis.ErrInvalidValue(host, apis.CurrentField, err.Error()).ViaFieldIndex("hosts", idx))
+		} else if normalized != host {
+			all = all.Also(apis.ErrInvalidValue(host, apis.CurrentField,
+				fmt.Sprintf("hosts must be lowercase ASCII, with internationalized labels in punycode: %q", normalized)).ViaFieldIndex("hosts", idx))
+		}
+	}
 	return all
 }
 
+// validateWildcard checks that the wildcard of host, if any, is its whole
+// leftmost label and is followed by at least two labels, so that a wildcard
+// host can't match all the hosts of a top-level domain.
+func validateWildcard(host string) error {
+	if !strings.Contains(host, "*") {
+		return nil
+	}
+	suffix := strings.TrimPrefix(host, "*.")
+	if suffix == host || strings.Contains(suffix, "*") {
+		return errors.New("the wildcard must be the whole leftmost label, e.g. *.example.com")
+	}
+	if !strings.Contains(strings.TrimSuffix(suffix, "."), ".") {
+		return errors.New("the wildcard must be followed by at least two labels, e.g. *.example.com")
+	}
+	return nil
+}
+
+// hostProfile maps the hosts like idna.Lookup, but without the STD3 and
+// hyphen rules, which reject hosts the Ingresses always accepted, e.g. with
+// underscores.
+var hostProfile = idna.New(idna.MapForLookup(), idna.BidiRule(),
+	idna.StrictDomainName(false), idna.CheckHyphens(false))
+
+// normalizeHost returns the canonical form of host: lowercase ASCII, with
+// its internationalized labels encoded in punycode, e.g.
+// `xn--bcher-kva.example.com` for `Bücher.example.com`. A leading wildcard
+// label is kept as is.
+func normalizeHost(host string) (string, error) {
+	prefix := ""
+	if strings.HasPrefix(host, "*.") {
+		prefix, host = "*.", host[2:]
+	}
+	ascii, err := hostProfile.ToASCII(host)
+	if err != nil {
+		return "", err
+	}
+	return prefix + ascii, nil
+}
+
 func (t HTTPOption) Validate(ctx context.Context) (all *apis.FieldError) {
 	switch t {
 	case "", HTTPOptionEnabled, HTTPOptionRedirected:
@@ -18,12 +18,21 @@ package v1alpha1
 
 import (
 	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"knative.dev/networking/pkg/apis/networking"
+	"knative.dev/networking/pkg/config/features"
 	"knative.dev/pkg/apis"
+	"knative.dev/pkg/ptr"
 )
 
 func TestIngressSpecValidation(t *testing.T) {
@@ -145,6 +154,250 @@ func TestIngressSpecValidation(t *testing.T) {
 			}},
 		},
 		want: apis.ErrInvalidValue(199, "rules[0].http.paths[0].splits[0].percent"),
+	}, {
+		name: "invalid-load-balancer-policy",
+		is: &IngressSpec{
+			Rules: []IngressRule{{
+				Hosts: []string{"example.com"},
+				HTTP: &HTTPIngressRuleValue{
+					Paths: []HTTPIngressPath{{
+						Splits: []IngressBackendSplit{{
+							IngressBackend: IngressBackend{
+								ServiceName:      "revision-000",
+								ServiceNamespace: "default",
+								ServicePort:      intstr.FromInt(8080),
+							},
+							LoadBalancerPolicy: &LoadBalancerPolicy{
+								Type: LoadBalancerPolicyRingHash,
+							},
+						}},
+					}},
+				},
+			}},
+		},
+		want: apis.ErrMissingField("rules[0].http.paths[0].splits[0].loadBalancerPolicy.hashKey"),
+	}, {
+		name: "valid-max-request-body-bytes",
+		is: &IngressSpec{
+			Rules: []IngressRule{{
+				Hosts: []string{"example.com"},
+				HTTP: &HTTPIngressRuleValue{
+					Paths: []HTTPIngressPath{{
+						Splits: []IngressBackendSplit{{
+							IngressBackend: IngressBackend{
+								ServiceName:      "revision-000",
+								ServiceNamespace: "default",
+								ServicePort:      intstr.FromInt(8080),
+							},
+						}},
+						MaxRequestBodyBytes: ptr.Int64(10 * 1024 * 1024),
+					}},
+				},
+			}},
+		},
+		want: nil,
+	}, {
+		name: "invalid-max-request-body-bytes",
+		is: &IngressSpec{
+			Rules: []IngressRule{{
+				Hosts: []string{"example.com"},
+				HTTP: &HTTPIngressRuleValue{
+					Paths: []HTTPIngressPath{{
+						Splits: []IngressBackendSplit{{
+							IngressBackend: IngressBackend{
+								ServiceName:      "revision-000",
+								ServiceNamespace: "default",
+								ServicePort:      intstr.FromInt(8080),
+							},
+						}},
+						MaxRequestBodyBytes: ptr.Int64(0),
+					}},
+				},
+			}},
+		},
+		want: apis.ErrOutOfBoundsValue(0, 1, math.MaxInt64, "rules[0].http.paths[0].maxRequestBodyBytes"),
+	}, {
+		name: "valid-idle-timeout",
+		is: &IngressSpec{
+			Rules: []IngressRule{{
+				Hosts: []string{"example.com"},
+				HTTP: &HTTPIngressRuleValue{
+					Paths: []HTTPIngressPath{{
+						Splits: []IngressBackendSplit{{
+							IngressBackend: IngressBackend{
+								ServiceName:      "revision-000",
+								ServiceNamespace: "default",
+								ServicePort:      intstr.FromInt(8080),
+							},
+						}},
+						IdleTimeout: &metav1.Duration{Duration: time.Hour},
+					}},
+				},
+			}},
+		},
+		want: nil,
+	}, {
+		name: "invalid-idle-timeout",
+		is: &IngressSpec{
+			Rules: []IngressRule{{
+				Hosts: []string{"example.com"},
+				HTTP: &HTTPIngressRuleValue{
+					Paths: []HTTPIngressPath{{
+						Splits: []IngressBackendSplit{{
+							IngressBackend: IngressBackend{
+								ServiceName:      "revision-000",
+								ServiceNamespace: "default",
+								ServicePort:      intstr.FromInt(8080),
+							},
+						}},
+						IdleTimeout: &metav1.Duration{Duration: time.Microsecond},
+					}},
+				},
+			}},
+		},
+		want: apis.ErrInvalidValue("1µs", "rules[0].http.paths[0].idleTimeout", "the idle timeout must be at least 1ms"),
+	}, {
+		name: "valid-connect-timeout",
+		is: &IngressSpec{
+			Rules: []IngressRule{{
+				Hosts: []string{"example.com"},
+				HTTP: &HTTPIngressRuleValue{
+					Paths: []HTTPIngressPath{{
+						Splits: []IngressBackendSplit{{
+							IngressBackend: IngressBackend{
+								ServiceName:      "revision-000",
+								ServiceNamespace: "default",
+								ServicePort:      intstr.FromInt(8080),
+							},
+						}},
+						ConnectTimeout: &metav1.Duration{Duration: time.Second},
+						IdleTimeout:    &metav1.Duration{Duration: time.Hour},
+					}},
+				},
+			}},
+		},
+		want: nil,
+	}, {
+		name: "invalid-connect-timeout",
+		is: &IngressSpec{
+			Rules: []IngressRule{{
+				Hosts: []string{"example.com"},
+				HTTP: &HTTPIngressRuleValue{
+					Paths: []HTTPIngressPath{{
+						Splits: []IngressBackendSplit{{
+							IngressBackend: IngressBackend{
+								ServiceName:      "revision-000",
+								ServiceNamespace: "default",
+								ServicePort:      intstr.FromInt(8080),
+							},
+						}},
+						ConnectTimeout: &metav1.Duration{},
+					}},
+				},
+			}},
+		},
+		want: apis.ErrInvalidValue("0s", "rules[0].http.paths[0].connectTimeout", "the connect timeout must be at least 1ms"),
+	}, {
+		name: "invalid-upstream-tls",
+		is: &IngressSpec{
+			Rules: []IngressRule{{
+				Hosts: []string{"example.com"},
+				HTTP: &HTTPIngressRuleValue{
+					Paths: []HTTPIngressPath{{
+						Splits: []IngressBackendSplit{{
+							IngressBackend: IngressBackend{
+								ServiceName:      "revision-000",
+								ServiceNamespace: "default",
+								ServicePort:      intstr.FromInt(8443),
+							},
+							UpstreamTLS: &UpstreamTLS{
+								CASecretName: "ca",
+							},
+						}},
+					}},
+				},
+			}},
+		},
+		want: apis.ErrMissingField("rules[0].http.paths[0].splits[0].upstreamTLS.caSecretNamespace"),
+	}, {
+		name: "header-selected-split",
+		is: &IngressSpec{
+			Rules: []IngressRule{{
+				Hosts: []string{"example.com"},
+				HTTP: &HTTPIngressRuleValue{
+					Paths: []HTTPIngressPath{{
+						Splits: []IngressBackendSplit{{
+							IngressBackend: IngressBackend{
+								ServiceName:      "revision-001",
+								ServiceNamespace: "default",
+								ServicePort:      intstr.FromInt(8080),
+							},
+							Headers: map[string]HeaderMatch{
+								"Knative-Serving-Tag": {Exact: "candidate"},
+							},
+						}, {
+							IngressBackend: IngressBackend{
+								ServiceName:      "revision-000",
+								ServiceNamespace: "default",
+								ServicePort:      intstr.FromInt(8080),
+							},
+						}},
+					}},
+				},
+			}},
+		},
+	}, {
+		name: "header-selected-split-with-percent",
+		is: &IngressSpec{
+			Rules: []IngressRule{{
+				Hosts: []string{"example.com"},
+				HTTP: &HTTPIngressRuleValue{
+					Paths: []HTTPIngressPath{{
+						Splits: []IngressBackendSplit{{
+							IngressBackend: IngressBackend{
+								ServiceName:      "revision-001",
+								ServiceNamespace: "default",
+								ServicePort:      intstr.FromInt(8080),
+							},
+							Headers: map[string]HeaderMatch{
+								"Knative-Serving-Tag": {Exact: "candidate"},
+							},
+							Percent: 10,
+						}, {
+							IngressBackend: IngressBackend{
+								ServiceName:      "revision-000",
+								ServiceNamespace: "default",
+								ServicePort:      intstr.FromInt(8080),
+							},
+							Percent: 90,
+						}},
+					}},
+				},
+			}},
+		},
+		want: apis.ErrMultipleOneOf("rules[0].http.paths[0].splits[0].headers", "rules[0].http.paths[0].splits[0].percent"),
+	}, {
+		name: "invalid-source-ip-policy",
+		is: &IngressSpec{
+			Rules: []IngressRule{{
+				Hosts: []string{"example.com"},
+				HTTP: &HTTPIngressRuleValue{
+					Paths: []HTTPIngressPath{{
+						Splits: []IngressBackendSplit{{
+							IngressBackend: IngressBackend{
+								ServiceName:      "revision-000",
+								ServiceNamespace: "default",
+								ServicePort:      intstr.FromInt(8080),
+							},
+						}},
+					}},
+				},
+				SourceIPPolicy: &SourceIPPolicy{
+					Allow: []string{"10.0.0.0/8", "10.0.0.1"},
+				},
+			}},
+		},
+		want: apis.ErrInvalidArrayValue("10.0.0.1", "rules[0].sourceIPPolicy.allow", 1),
 	}, {
 		name: "missing-split",
 		is: &IngressSpec{
@@ -358,61 +611,182 @@ func TestIngressSpecValidation(t *testing.T) {
 			HTTPOption: "xyz",
 		},
 		want: apis.ErrInvalidValue("xyz", "httpOption"),
-	}}
-
-	ctx := apis.WithinParent(context.Background(), metav1.ObjectMeta{Namespace: "default", Name: "test-ingress"})
-	for _, test := range tests {
-		t.Run(test.name, func(t *testing.T) {
-			got := test.is.Validate(ctx)
-			if diff := cmp.Diff(test.want.Error(), got.Error()); diff != "" {
-				t.Error("Validate (-want, +got) =", diff)
-			}
-		})
-	}
-}
-
-func TestIngressValidation(t *testing.T) {
-	tests := []struct {
-		name string
-		ci   *Ingress
-		want *apis.FieldError
-	}{{
-		name: "valid",
-		ci: &Ingress{
-			ObjectMeta: metav1.ObjectMeta{
-				Namespace: "default",
-				Name:      "test-ingress",
-			},
-			Spec: IngressSpec{
-				TLS: []IngressTLS{{
-					SecretNamespace: "secret-space",
-					SecretName:      "secret-name",
-				}},
-				Rules: []IngressRule{{
-					Hosts: []string{"example.com"},
-					HTTP: &HTTPIngressRuleValue{
-						Paths: []HTTPIngressPath{{
-							Splits: []IngressBackendSplit{{
-								IngressBackend: IngressBackend{
-									ServiceName:      "revision-000",
-									ServiceNamespace: "default",
-									ServicePort:      intstr.FromInt(8080),
-								},
-							}},
-						}},
-					},
-				}},
-			},
-		},
-		want: nil,
 	}, {
-		name: "ingress-namespace-different-with-service",
-		ci: &Ingress{
-			ObjectMeta: metav1.ObjectMeta{
-				Namespace: "test",
-				Name:      "test-ingress",
-			},
-			Spec: IngressSpec{
+		name: "invalid-rule-httpOption",
+		is: &IngressSpec{
+			Rules: []IngressRule{{
+				Hosts:      []string{"example.com"},
+				Visibility: IngressVisibilityExternalIP,
+				HTTP: &HTTPIngressRuleValue{
+					Paths: []HTTPIngressPath{{
+						Splits: []IngressBackendSplit{{
+							IngressBackend: IngressBackend{
+								ServiceName:      "revision-000",
+								ServiceNamespace: "default",
+								ServicePort:      intstr.FromInt(8080),
+							},
+						}},
+					}},
+				},
+				HTTPOption: "xyz",
+			}},
+		},
+		want: apis.ErrInvalidValue("xyz", "rules[0].httpOption"),
+	}, {
+		name: "per-rule-httpOption",
+		is: &IngressSpec{
+			Rules: []IngressRule{{
+				Hosts:      []string{"example.com"},
+				Visibility: IngressVisibilityExternalIP,
+				HTTP: &HTTPIngressRuleValue{
+					Paths: []HTTPIngressPath{{
+						Splits: []IngressBackendSplit{{
+							IngressBackend: IngressBackend{
+								ServiceName:      "revision-000",
+								ServiceNamespace: "default",
+								ServicePort:      intstr.FromInt(8080),
+							},
+						}},
+					}},
+				},
+			}, {
+				Hosts:      []string{"example.default", "example.default.svc.cluster.local"},
+				Visibility: IngressVisibilityClusterLocal,
+				HTTP: &HTTPIngressRuleValue{
+					Paths: []HTTPIngressPath{{
+						Splits: []IngressBackendSplit{{
+							IngressBackend: IngressBackend{
+								ServiceName:      "revision-000",
+								ServiceNamespace: "default",
+								ServicePort:      intstr.FromInt(8080),
+							},
+						}},
+					}},
+				},
+				HTTPOption: HTTPOptionEnabled,
+			}},
+			HTTPOption: HTTPOptionRedirected,
+		},
+	}, {
+		name: "conflicting-rule-httpOption",
+		is: &IngressSpec{
+			Rules: []IngressRule{{
+				Hosts:      []string{"example.com", "www.example.com"},
+				Visibility: IngressVisibilityExternalIP,
+				HTTP: &HTTPIngressRuleValue{
+					Paths: []HTTPIngressPath{{
+						Splits: []IngressBackendSplit{{
+							IngressBackend: IngressBackend{
+								ServiceName:      "revision-000",
+								ServiceNamespace: "default",
+								ServicePort:      intstr.FromInt(8080),
+							},
+						}},
+					}},
+				},
+			}, {
+				Hosts:      []string{"example.com"},
+				Visibility: IngressVisibilityExternalIP,
+				HTTP: &HTTPIngressRuleValue{
+					Paths: []HTTPIngressPath{{
+						Splits: []IngressBackendSplit{{
+							IngressBackend: IngressBackend{
+								ServiceName:      "revision-000",
+								ServiceNamespace: "default",
+								ServicePort:      intstr.FromInt(8080),
+							},
+						}},
+					}},
+				},
+				HTTPOption: HTTPOptionEnabled,
+			}},
+			HTTPOption: HTTPOptionRedirected,
+		},
+		want: apis.ErrInvalidValue(HTTPOptionEnabled, "rules[1].httpOption",
+			`conflicts with Redirected of rules[0] for host "example.com"`),
+	}, {
+		name: "tls-visibility-mismatch",
+		is: &IngressSpec{
+			TLS: []IngressTLS{{
+				Hosts:           []string{"example.com"},
+				SecretNamespace: "secret-space",
+				SecretName:      "secret-name",
+				Visibility:      IngressVisibilityClusterLocal,
+			}},
+			Rules: []IngressRule{{
+				Hosts: []string{"example.com"},
+				HTTP: &HTTPIngressRuleValue{
+					Paths: []HTTPIngressPath{{
+						Splits: []IngressBackendSplit{{
+							IngressBackend: IngressBackend{
+								ServiceName:      "revision-000",
+								ServiceNamespace: "default",
+								ServicePort:      intstr.FromInt(8080),
+							},
+						}},
+					}},
+				},
+			}},
+		},
+		want: &apis.FieldError{
+			Message: `host "example.com" is not exposed by any rule with visibility ClusterLocal`,
+			Paths:   []string{"tls[0].hosts[0]"},
+		},
+	}}
+
+	ctx := apis.WithinParent(context.Background(), metav1.ObjectMeta{Namespace: "default", Name: "test-ingress"})
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := test.is.Validate(ctx)
+			if diff := cmp.Diff(test.want.Error(), got.Error()); diff != "" {
+				t.Error("Validate (-want, +got) =", diff)
+			}
+		})
+	}
+}
+
+func TestIngressValidation(t *testing.T) {
+	tests := []struct {
+		name string
+		ci   *Ingress
+		want *apis.FieldError
+	}{{
+		name: "valid",
+		ci: &Ingress{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "default",
+				Name:      "test-ingress",
+			},
+			Spec: IngressSpec{
+				TLS: []IngressTLS{{
+					SecretNamespace: "secret-space",
+					SecretName:      "secret-name",
+				}},
+				Rules: []IngressRule{{
+					Hosts: []string{"example.com"},
+					HTTP: &HTTPIngressRuleValue{
+						Paths: []HTTPIngressPath{{
+							Splits: []IngressBackendSplit{{
+								IngressBackend: IngressBackend{
+									ServiceName:      "revision-000",
+									ServiceNamespace: "default",
+									ServicePort:      intstr.FromInt(8080),
+								},
+							}},
+						}},
+					},
+				}},
+			},
+		},
+		want: nil,
+	}, {
+		name: "ingress-namespace-different-with-service",
+		ci: &Ingress{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "test",
+				Name:      "test-ingress",
+			},
+			Spec: IngressSpec{
 				TLS: []IngressTLS{{
 					SecretNamespace: "secret-space",
 					SecretName:      "secret-name",
@@ -448,3 +822,1765 @@ func TestIngressValidation(t *testing.T) {
 		})
 	}
 }
+
+func TestLoadBalancerPolicyValidation(t *testing.T) {
+	tests := []struct {
+		name string
+		p    *LoadBalancerPolicy
+		want *apis.FieldError
+	}{{
+		name: "round robin",
+		p:    &LoadBalancerPolicy{Type: LoadBalancerPolicyRoundRobin},
+	}, {
+		name: "least request",
+		p:    &LoadBalancerPolicy{Type: LoadBalancerPolicyLeastRequest},
+	}, {
+		name: "ring hash on header",
+		p: &LoadBalancerPolicy{
+			Type:    LoadBalancerPolicyRingHash,
+			HashKey: &LoadBalancerHashKey{Header: "X-User-Id"},
+		},
+	}, {
+		name: "ring hash on cookie",
+		p: &LoadBalancerPolicy{
+			Type:    LoadBalancerPolicyRingHash,
+			HashKey: &LoadBalancerHashKey{Cookie: "session"},
+		},
+	}, {
+		name: "ring hash on source ip",
+		p: &LoadBalancerPolicy{
+			Type:    LoadBalancerPolicyRingHash,
+			HashKey: &LoadBalancerHashKey{SourceIP: true},
+		},
+	}, {
+		name: "missing type",
+		p:    &LoadBalancerPolicy{},
+		want: apis.ErrMissingField("type"),
+	}, {
+		name: "unknown type",
+		p:    &LoadBalancerPolicy{Type: "Random"},
+		want: apis.ErrInvalidValue("Random", "type"),
+	}, {
+		name: "hash key without ring hash",
+		p: &LoadBalancerPolicy{
+			Type:    LoadBalancerPolicyLeastRequest,
+			HashKey: &LoadBalancerHashKey{SourceIP: true},
+		},
+		want: apis.ErrDisallowedFields("hashKey"),
+	}, {
+		name: "ring hash without hash key",
+		p:    &LoadBalancerPolicy{Type: LoadBalancerPolicyRingHash},
+		want: apis.ErrMissingField("hashKey"),
+	}, {
+		name: "empty hash key",
+		p: &LoadBalancerPolicy{
+			Type:    LoadBalancerPolicyRingHash,
+			HashKey: &LoadBalancerHashKey{},
+		},
+		want: apis.ErrMissingOneOf("hashKey.header", "hashKey.cookie", "hashKey.sourceIP"),
+	}, {
+		name: "multiple hash keys",
+		p: &LoadBalancerPolicy{
+			Type:    LoadBalancerPolicyRingHash,
+			HashKey: &LoadBalancerHashKey{Header: "X-User-Id", SourceIP: true},
+		},
+		want: apis.ErrMultipleOneOf("hashKey.header", "hashKey.sourceIP"),
+	}, {
+		name: "invalid header name",
+		p: &LoadBalancerPolicy{
+			Type:    LoadBalancerPolicyRingHash,
+			HashKey: &LoadBalancerHashKey{Header: "X User Id"},
+		},
+		want: apis.ErrInvalidValue("X User Id", "hashKey.header"),
+	}, {
+		name: "invalid cookie name",
+		p: &LoadBalancerPolicy{
+			Type:    LoadBalancerPolicyRingHash,
+			HashKey: &LoadBalancerHashKey{Cookie: "a;b"},
+		},
+		want: apis.ErrInvalidValue("a;b", "hashKey.cookie"),
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := test.p.Validate(context.Background())
+			if diff := cmp.Diff(test.want.Error(), got.Error()); diff != "" {
+				t.Error("Validate (-want, +got) =", diff)
+			}
+		})
+	}
+}
+
+func TestUpstreamTLSValidation(t *testing.T) {
+	tests := []struct {
+		name string
+		u    *UpstreamTLS
+		want *apis.FieldError
+	}{{
+		name: "valid",
+		u: &UpstreamTLS{
+			CASecretName:      "ca",
+			CASecretNamespace: "knative-serving",
+		},
+	}, {
+		name: "valid with server name",
+		u: &UpstreamTLS{
+			CASecretName:      "ca",
+			CASecretNamespace: "knative-serving",
+			ServerName:        "activator-service.knative-serving.svc.cluster.local",
+		},
+	}, {
+		name: "empty",
+		u:    &UpstreamTLS{},
+		want: apis.ErrMissingField("caSecretName", "caSecretNamespace"),
+	}, {
+		name: "invalid server name",
+		u: &UpstreamTLS{
+			CASecretName:      "ca",
+			CASecretNamespace: "knative-serving",
+			ServerName:        "Not_A_Host",
+		},
+		want: apis.ErrInvalidValue("Not_A_Host", "serverName",
+			"a lowercase RFC 1123 subdomain must consist of lower case alphanumeric characters, '-' or '.', "+
+				"and must start and end with an alphanumeric character (e.g. 'example.com', "+
+				"regex used for validation is '[a-z0-9]([-a-z0-9]*[a-z0-9])?(\\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*')"),
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := test.u.Validate(context.Background())
+			if diff := cmp.Diff(test.want.Error(), got.Error()); diff != "" {
+				t.Error("Validate (-want, +got) =", diff)
+			}
+		})
+	}
+}
+
+func TestOutlierDetectionValidation(t *testing.T) {
+	tests := []struct {
+		name string
+		o    *OutlierDetection
+		want *apis.FieldError
+	}{{
+		name: "consecutive errors only",
+		o:    &OutlierDetection{ConsecutiveErrors: 5},
+	}, {
+		name: "all fields",
+		o: &OutlierDetection{
+			ConsecutiveErrors:  3,
+			EjectionInterval:   &metav1.Duration{Duration: 30 * time.Second},
+			MaxEjectionPercent: ptr.Int32(100),
+		},
+	}, {
+		name: "no ejection",
+		o: &OutlierDetection{
+			ConsecutiveErrors:  3,
+			MaxEjectionPercent: ptr.Int32(0),
+		},
+	}, {
+		name: "missing consecutive errors",
+		o:    &OutlierDetection{},
+		want: apis.ErrOutOfBoundsValue(0, 1, math.MaxInt32, "consecutiveErrors"),
+	}, {
+		name: "negative consecutive errors",
+		o:    &OutlierDetection{ConsecutiveErrors: -1},
+		want: apis.ErrOutOfBoundsValue(-1, 1, math.MaxInt32, "consecutiveErrors"),
+	}, {
+		name: "ejection interval too short",
+		o: &OutlierDetection{
+			ConsecutiveErrors: 5,
+			EjectionInterval:  &metav1.Duration{Duration: time.Microsecond},
+		},
+		want: apis.ErrInvalidValue("1µs", "ejectionInterval", "the ejection interval must be at least 1ms"),
+	}, {
+		name: "max ejection percent out of bounds",
+		o: &OutlierDetection{
+			ConsecutiveErrors:  5,
+			MaxEjectionPercent: ptr.Int32(101),
+		},
+		want: apis.ErrOutOfBoundsValue(101, 0, 100, "maxEjectionPercent"),
+	}, {
+		name: "negative max ejection percent",
+		o: &OutlierDetection{
+			ConsecutiveErrors:  5,
+			MaxEjectionPercent: ptr.Int32(-10),
+		},
+		want: apis.ErrOutOfBoundsValue(-10, 0, 100, "maxEjectionPercent"),
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := test.o.Validate(context.Background())
+			if diff := cmp.Diff(test.want.Error(), got.Error()); diff != "" {
+				t.Error("Validate (-want, +got) =", diff)
+			}
+		})
+	}
+}
+
+func TestSplitOutlierDetectionValidation(t *testing.T) {
+	ctx := apis.WithinParent(context.Background(), metav1.ObjectMeta{Namespace: "default", Name: "test-ingress"})
+	split := IngressBackendSplit{
+		IngressBackend: IngressBackend{
+			ServiceName:      "revision-000",
+			ServiceNamespace: "default",
+			ServicePort:      intstr.FromInt(8080),
+		},
+		OutlierDetection: &OutlierDetection{},
+	}
+	want := apis.ErrOutOfBoundsValue(0, 1, math.MaxInt32, "outlierDetection.consecutiveErrors")
+	if got := split.Validate(ctx); got.Error() != want.Error() {
+		t.Errorf("Validate() = %v, want: %v", got, want)
+	}
+}
+
+func TestHealthCheckValidation(t *testing.T) {
+	tests := []struct {
+		name string
+		h    *HealthCheck
+		want *apis.FieldError
+	}{{
+		name: "path only",
+		h:    &HealthCheck{Path: "/healthz"},
+	}, {
+		name: "all fields",
+		h: &HealthCheck{
+			Path:               "/ready",
+			Interval:           &metav1.Duration{Duration: 5 * time.Second},
+			HealthyThreshold:   ptr.Int32(2),
+			UnhealthyThreshold: ptr.Int32(3),
+		},
+	}, {
+		name: "missing path",
+		h:    &HealthCheck{},
+		want: apis.ErrMissingField("path"),
+	}, {
+		name: "relative path",
+		h:    &HealthCheck{Path: "healthz"},
+		want: apis.ErrInvalidValue("healthz", "path", "paths must begin with a '/'"),
+	}, {
+		name: "interval too short",
+		h: &HealthCheck{
+			Path:     "/healthz",
+			Interval: &metav1.Duration{Duration: time.Microsecond},
+		},
+		want: apis.ErrInvalidValue("1µs", "interval", "the interval must be at least 1ms"),
+	}, {
+		name: "thresholds out of bounds",
+		h: &HealthCheck{
+			Path:               "/healthz",
+			HealthyThreshold:   ptr.Int32(0),
+			UnhealthyThreshold: ptr.Int32(-1),
+		},
+		want: apis.ErrOutOfBoundsValue(0, 1, math.MaxInt32, "healthyThreshold").Also(
+			apis.ErrOutOfBoundsValue(-1, 1, math.MaxInt32, "unhealthyThreshold")),
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := test.h.Validate(context.Background())
+			if diff := cmp.Diff(test.want.Error(), got.Error()); diff != "" {
+				t.Error("Validate (-want, +got) =", diff)
+			}
+		})
+	}
+}
+
+func TestSplitHealthCheckValidation(t *testing.T) {
+	ctx := apis.WithinParent(context.Background(), metav1.ObjectMeta{Namespace: "default", Name: "test-ingress"})
+	split := IngressBackendSplit{
+		IngressBackend: IngressBackend{
+			ServiceName:      "revision-000",
+			ServiceNamespace: "default",
+			ServicePort:      intstr.FromInt(8080),
+		},
+		HealthCheck: &HealthCheck{},
+	}
+	want := apis.ErrMissingField("healthCheck.path")
+	if got := split.Validate(ctx); got.Error() != want.Error() {
+		t.Errorf("Validate() = %v, want: %v", got, want)
+	}
+}
+func TestCompressionValidation(t *testing.T) {
+	tests := []struct {
+		name string
+		c    *Compression
+		want *apis.FieldError
+	}{{
+		name: "enabled",
+		c:    &Compression{Mode: CompressionEnabled},
+	}, {
+		name: "enabled with all fields",
+		c: &Compression{
+			Mode:         CompressionEnabled,
+			MinSizeBytes: ptr.Int64(1024),
+			ContentTypes: []string{"text/html", "application/javascript"},
+		},
+	}, {
+		name: "disabled",
+		c:    &Compression{Mode: CompressionDisabled},
+	}, {
+		name: "missing mode",
+		c:    &Compression{},
+		want: apis.ErrMissingField("mode"),
+	}, {
+		name: "invalid mode",
+		c:    &Compression{Mode: "Sometimes"},
+		want: apis.ErrInvalidValue("Sometimes", "mode"),
+	}, {
+		name: "negative min size",
+		c:    &Compression{Mode: CompressionEnabled, MinSizeBytes: ptr.Int64(-1)},
+		want: apis.ErrOutOfBoundsValue(-1, 0, math.MaxInt64, "minSizeBytes"),
+	}, {
+		name: "invalid content types",
+		c: &Compression{
+			Mode:         CompressionEnabled,
+			ContentTypes: []string{"text/html", "text/", "text/plain; charset=utf-8", "TEXT/HTML"},
+		},
+		want: apis.ErrInvalidValue("text/", "contentTypes[1]", "mime: expected token after slash").Also(
+			apis.ErrInvalidValue("text/plain; charset=utf-8", "contentTypes[2]", "media type parameters are not allowed"),
+			apis.ErrGeneric("duplicate media type text/html", "contentTypes[3]")),
+	}, {
+		name: "disabled with options",
+		c: &Compression{
+			Mode:         CompressionDisabled,
+			MinSizeBytes: ptr.Int64(1024),
+			ContentTypes: []string{"text/html"},
+		},
+		want: apis.ErrDisallowedFields("minSizeBytes").Also(apis.ErrDisallowedFields("contentTypes")),
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := test.c.Validate(context.Background())
+			if diff := cmp.Diff(test.want.Error(), got.Error()); diff != "" {
+				t.Error("Validate (-want, +got) =", diff)
+			}
+		})
+	}
+}
+
+func TestPathCompressionValidation(t *testing.T) {
+	path := HTTPIngressPath{
+		Splits: []IngressBackendSplit{{
+			IngressBackend: IngressBackend{
+				ServiceName:      "revision-000",
+				ServiceNamespace: "default",
+				ServicePort:      intstr.FromInt(8080),
+			},
+		}},
+		Compression: &Compression{},
+	}
+	ctx := apis.WithinParent(context.Background(), metav1.ObjectMeta{Namespace: "default", Name: "test-ingress"})
+	want := apis.ErrMissingField("compression.mode")
+	if got := path.Validate(ctx); got.Error() != want.Error() {
+		t.Errorf("Validate() = %v, want: %v", got, want)
+	}
+}
+
+func TestDataplaneTrustValidation(t *testing.T) {
+	spec := IngressSpec{
+		Rules: []IngressRule{{
+			Hosts: []string{"example.com"},
+			HTTP: &HTTPIngressRuleValue{
+				Paths: []HTTPIngressPath{{
+					Splits: []IngressBackendSplit{{
+						IngressBackend: IngressBackend{
+							ServiceName:      "revision-000",
+							ServiceNamespace: "default",
+							ServicePort:      intstr.FromInt(8080),
+						},
+					}},
+				}},
+			},
+			Visibility: IngressVisibilityExternalIP,
+		}},
+	}
+	ctx := apis.WithinParent(context.Background(), metav1.ObjectMeta{Namespace: "default", Name: "test-ingress"})
+
+	spec.DataplaneTrust = networking.DataplaneTrustMutual
+	if got := spec.Validate(ctx); got != nil {
+		t.Error("Validate() =", got)
+	}
+	spec.DataplaneTrust = "total"
+	want := apis.ErrInvalidValue("total", "dataplaneTrust")
+	if got := spec.Validate(ctx); got.Error() != want.Error() {
+		t.Errorf("Validate() = %v, want: %v", got, want)
+	}
+}
+
+func TestIngressRuleClusterLocalHostsValidation(t *testing.T) {
+	tests := []struct {
+		name       string
+		visibility IngressVisibility
+		hosts      []string
+		want       *apis.FieldError
+	}{{
+		name:       "external hosts",
+		visibility: IngressVisibilityExternalIP,
+		hosts:      []string{"foo.example.com"},
+	}, {
+		name:       "cluster-local hosts with ClusterLocal visibility",
+		visibility: IngressVisibilityClusterLocal,
+		hosts:      []string{"foo.ns", "foo.ns.svc.cluster.local"},
+	}, {
+		name:       "cluster-local hosts with ExternalIP visibility",
+		visibility: IngressVisibilityExternalIP,
+		hosts:      []string{"foo.example.com", "foo.ns.svc.cluster.local"},
+		want: apis.ErrGeneric(`host "foo.ns.svc.cluster.local" is cluster-local but exposed with visibility ExternalIP`,
+			"hosts[1]").At(apis.WarningLevel),
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := apis.WithinParent(context.Background(), metav1.ObjectMeta{Namespace: "default", Name: "test-ingress"})
+			r := &IngressRule{
+				Hosts:      test.hosts,
+				Visibility: test.visibility,
+				HTTP: &HTTPIngressRuleValue{
+					Paths: []HTTPIngressPath{{
+						Splits: []IngressBackendSplit{{
+							IngressBackend: IngressBackend{
+								ServiceName:      "revision-000",
+								ServiceNamespace: "default",
+								ServicePort:      intstr.FromInt(8080),
+							},
+						}},
+					}},
+				},
+			}
+			got := r.Validate(ctx)
+			if diff := cmp.Diff(test.want.Error(), got.Error()); diff != "" {
+				t.Error("Validate (-want, +got) =", diff)
+			}
+			if got.Filter(apis.ErrorLevel) != nil {
+				t.Error("Validate() reported errors:", got.Filter(apis.ErrorLevel))
+			}
+		})
+	}
+}
+
+func TestIngressHostsValidation(t *testing.T) {
+	tests := []struct {
+		name  string
+		hosts []string
+		want  *apis.FieldError
+	}{{
+		name:  "normalized hosts",
+		hosts: []string{"foo.example.com", "*.example.com", "xn--bcher-kva.example.com", "foo.ns", "10.0.0.1"},
+	}, {
+		name:  "uppercase host",
+		hosts: []string{"foo.example.com", "Foo.Example.com"},
+		want: apis.ErrInvalidValue("Foo.Example.com", "hosts[1]",
+			`hosts must be lowercase ASCII, with internationalized labels in punycode: "foo.example.com"`),
+	}, {
+		name:  "internationalized host",
+		hosts: []string{"*.bücher.example.com"},
+		want: apis.ErrInvalidValue("*.bücher.example.com", "hosts[0]",
+			`hosts must be lowercase ASCII, with internationalized labels in punycode: "*.xn--bcher-kva.example.com"`),
+	}, {
+		name:  "wildcard not leftmost",
+		hosts: []string{"foo.*.example.com"},
+		want: apis.ErrInvalidValue("foo.*.example.com", "hosts[0]",
+			"the wildcard must be the whole leftmost label, e.g. *.example.com"),
+	}, {
+		name:  "partial wildcard label",
+		hosts: []string{"*foo.example.com"},
+		want: apis.ErrInvalidValue("*foo.example.com", "hosts[0]",
+			"the wildcard must be the whole leftmost label, e.g. *.example.com"),
+	}, {
+		name:  "multiple wildcards",
+		hosts: []string{"*.*.example.com"},
+		want: apis.ErrInvalidValue("*.*.example.com", "hosts[0]",
+			"the wildcard must be the whole leftmost label, e.g. *.example.com"),
+	}, {
+		name:  "wildcard top-level domain",
+		hosts: []string{"*.com", "*."},
+		want: apis.ErrInvalidValue("*.com", "hosts[0]",
+			"the wildcard must be followed by at least two labels, e.g. *.example.com").Also(
+			apis.ErrInvalidValue("*.", "hosts[1]",
+				"the wildcard must be followed by at least two labels, e.g. *.example.com")),
+	}, {
+		name:  "hosts accepted before normalization",
+		hosts: []string{"under_score.example.com", "-hyphen.example.com", "ab--cd.example.com"},
+	}, {
+		name:  "invalid punycode",
+		hosts: []string{"xn--a.example.com"},
+		want: apis.ErrInvalidValue("xn--a.example.com", "hosts[0]",
+			`idna: invalid label "\u0080"`),
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := apis.WithinParent(context.Background(), metav1.ObjectMeta{Namespace: "default", Name: "test-ingress"})
+			r := &IngressRule{
+				Hosts:      test.hosts,
+				Visibility: IngressVisibilityExternalIP,
+				HTTP: &HTTPIngressRuleValue{
+					Paths: []HTTPIngressPath{{
+						Splits: []IngressBackendSplit{{
+							IngressBackend: IngressBackend{
+								ServiceName:      "revision-000",
+								ServiceNamespace: "default",
+								ServicePort:      intstr.FromInt(8080),
+							},
+						}},
+					}},
+				},
+			}
+			got := r.Validate(ctx)
+			if diff := cmp.Diff(test.want.Error(), got.Error()); diff != "" {
+				t.Error("Validate (-want, +got) =", diff)
+			}
+		})
+	}
+}
+
+func TestIngressHostConflicts(t *testing.T) {
+	older, newer := metav1.NewTime(time.Unix(1000, 0)), metav1.NewTime(time.Unix(2000, 0))
+	ingress := func(ns, name string, created metav1.Time, visibility IngressVisibility, hosts ...string) *Ingress {
+		return &Ingress{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:         ns,
+				Name:              name,
+				CreationTimestamp: created,
+			},
+			Spec: IngressSpec{
+				Rules: []IngressRule{{
+					Hosts:      hosts,
+					Visibility: visibility,
+				}},
+			},
+		}
+	}
+	deleted := ingress("ns0", "deleted", older, IngressVisibilityExternalIP, "foo.example.com")
+	deleted.DeletionTimestamp = &newer
+
+	tests := []struct {
+		name   string
+		ing    *Ingress
+		others []*Ingress
+		want   *apis.FieldError
+	}{{
+		name: "no conflict",
+		ing:  ingress("ns1", "ing", newer, IngressVisibilityExternalIP, "foo.example.com"),
+		others: []*Ingress{
+			ingress("ns2", "other", older, IngressVisibilityExternalIP, "bar.example.com"),
+		},
+	}, {
+		name: "self",
+		ing:  ingress("ns1", "ing", newer, IngressVisibilityExternalIP, "foo.example.com"),
+		others: []*Ingress{
+			ingress("ns1", "ing", newer, IngressVisibilityExternalIP, "foo.example.com"),
+		},
+	}, {
+		name: "older ingress of another namespace",
+		ing:  ingress("ns1", "ing", newer, IngressVisibilityExternalIP, "bar.example.com", "foo.example.com"),
+		others: []*Ingress{
+			ingress("ns2", "other", older, IngressVisibilityExternalIP, "foo.example.com"),
+		},
+		want: apis.ErrGeneric(`host "foo.example.com" is already claimed by Ingress "ns2/other"`,
+			"spec.rules[0].hosts[1]"),
+	}, {
+		name: "newer ingress of another namespace",
+		ing:  ingress("ns1", "ing", older, IngressVisibilityExternalIP, "foo.example.com"),
+		others: []*Ingress{
+			ingress("ns2", "other", newer, IngressVisibilityExternalIP, "foo.example.com"),
+		},
+	}, {
+		name: "same creation time, namespace breaks the tie",
+		ing:  ingress("ns2", "ing", older, IngressVisibilityExternalIP, "foo.example.com"),
+		others: []*Ingress{
+			ingress("ns1", "other", older, IngressVisibilityExternalIP, "foo.example.com"),
+		},
+		want: apis.ErrGeneric(`host "foo.example.com" is already claimed by Ingress "ns1/other"`,
+			"spec.rules[0].hosts[0]"),
+	}, {
+		name: "same creation time and namespace, name breaks the tie",
+		ing:  ingress("ns1", "b", older, IngressVisibilityExternalIP, "foo.example.com"),
+		others: []*Ingress{
+			ingress("ns1", "a", older, IngressVisibilityExternalIP, "foo.example.com"),
+		},
+		want: apis.ErrGeneric(`host "foo.example.com" is already claimed by Ingress "ns1/a"`,
+			"spec.rules[0].hosts[0]"),
+	}, {
+		name: "the owner of the host is reported",
+		ing:  ingress("ns1", "ing", newer, IngressVisibilityExternalIP, "foo.example.com"),
+		others: []*Ingress{
+			ingress("ns3", "other", older, IngressVisibilityExternalIP, "foo.example.com"),
+			ingress("ns2", "other", older, IngressVisibilityExternalIP, "foo.example.com"),
+		},
+		want: apis.ErrGeneric(`host "foo.example.com" is already claimed by Ingress "ns2/other"`,
+			"spec.rules[0].hosts[0]"),
+	}, {
+		name: "different visibility",
+		ing:  ingress("ns1", "ing", newer, IngressVisibilityExternalIP, "foo.example.com"),
+		others: []*Ingress{
+			ingress("ns2", "other", older, IngressVisibilityClusterLocal, "foo.example.com"),
+		},
+	}, {
+		name:   "deleted ingress",
+		ing:    ingress("ns1", "ing", newer, IngressVisibilityExternalIP, "foo.example.com"),
+		others: []*Ingress{deleted},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := test.ing.ValidateHostConflicts(test.others)
+			if diff := cmp.Diff(test.want.Error(), got.Error()); diff != "" {
+				t.Error("ValidateHostConflicts (-want, +got) =", diff)
+			}
+		})
+	}
+}
+
+func TestErrorPagesValidation(t *testing.T) {
+	backend := &IngressBackend{
+		ServiceName:      "error-pages",
+		ServiceNamespace: "default",
+		ServicePort:      intstr.FromInt(8080),
+	}
+	body := &ErrorPageBody{
+		ConfigMapName: "error-pages",
+		Key:           "404.html",
+	}
+
+	tests := []struct {
+		name  string
+		pages []ErrorPage
+		want  *apis.FieldError
+	}{{
+		name: "valid",
+		pages: []ErrorPage{{
+			StatusCodes: []string{"404"},
+			Body:        body,
+		}, {
+			StatusCodes: []string{"503", "5xx"},
+			Backend:     backend,
+		}, {
+			StatusCodes: []string{"4xx"},
+			Body: &ErrorPageBody{
+				ConfigMapName: "error-pages",
+				Key:           "4xx.json",
+				ContentType:   "application/json; charset=utf-8",
+			},
+		}},
+	}, {
+		name: "missing status codes",
+		pages: []ErrorPage{{
+			Body: body,
+		}},
+		want: apis.ErrMissingField("errorPages[0].statusCodes"),
+	}, {
+		name: "invalid status codes",
+		pages: []ErrorPage{{
+			StatusCodes: []string{"200", "3xx", "40x", "5XX", "4040", "5xx"},
+			Body:        body,
+		}},
+		want: apis.ErrInvalidArrayValue("200", "statusCodes", 0).Also(
+			apis.ErrInvalidArrayValue("3xx", "statusCodes", 1),
+			apis.ErrInvalidArrayValue("40x", "statusCodes", 2),
+			apis.ErrInvalidArrayValue("5XX", "statusCodes", 3),
+			apis.ErrInvalidArrayValue("4040", "statusCodes", 4),
+		).ViaFieldIndex("errorPages", 0),
+	}, {
+		name: "duplicate status code",
+		pages: []ErrorPage{{
+			StatusCodes: []string{"404", "5xx"},
+			Body:        body,
+		}, {
+			StatusCodes: []string{"503", "5xx"},
+			Backend:     backend,
+		}},
+		want: apis.ErrGeneric(`status code "5xx" is already handled by errorPages[0]`, "errorPages[1].statusCodes[1]"),
+	}, {
+		name: "neither backend nor body",
+		pages: []ErrorPage{{
+			StatusCodes: []string{"404"},
+		}},
+		want: apis.ErrMissingOneOf("errorPages[0].backend", "errorPages[0].body"),
+	}, {
+		name: "both backend and body",
+		pages: []ErrorPage{{
+			StatusCodes: []string{"404"},
+			Backend:     backend,
+			Body:        body,
+		}},
+		want: apis.ErrMultipleOneOf("errorPages[0].backend", "errorPages[0].body"),
+	}, {
+		name: "invalid backend",
+		pages: []ErrorPage{{
+			StatusCodes: []string{"404"},
+			Backend: &IngressBackend{
+				ServiceName:      "error-pages",
+				ServiceNamespace: "default",
+			},
+		}},
+		want: apis.ErrMissingField("errorPages[0].backend.servicePort"),
+	}, {
+		name: "invalid body",
+		pages: []ErrorPage{{
+			StatusCodes: []string{"404"},
+			Body: &ErrorPageBody{
+				ConfigMapName: "Error_Pages",
+				Key:           "404/html",
+				ContentType:   "text/",
+			},
+		}},
+		want: apis.ErrInvalidValue("Error_Pages", "configMapName",
+			"a lowercase RFC 1123 subdomain must consist of lower case alphanumeric characters, '-' or '.', and must start and end with an alphanumeric character (e.g. 'example.com', regex used for validation is '[a-z0-9]([-a-z0-9]*[a-z0-9])?(\\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*')").Also(
+			apis.ErrInvalidValue("404/html", "key",
+				"a valid config key must consist of alphanumeric characters, '-', '_' or '.' (e.g. 'key.name',  or 'KEY_NAME',  or 'key-name', regex used for validation is '[-._a-zA-Z0-9]+')"),
+			apis.ErrInvalidValue("text/", "contentType", "mime: expected token after slash"),
+		).ViaField("body").ViaFieldIndex("errorPages", 0),
+	}, {
+		name: "missing body fields",
+		pages: []ErrorPage{{
+			StatusCodes: []string{"404"},
+			Body:        &ErrorPageBody{},
+		}},
+		want: apis.ErrMissingField("errorPages[0].body.configMapName", "errorPages[0].body.key"),
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := apis.WithinParent(context.Background(), metav1.ObjectMeta{Namespace: "default", Name: "test-ingress"})
+			r := &IngressRule{
+				Hosts:      []string{"foo.example.com"},
+				Visibility: IngressVisibilityExternalIP,
+				HTTP: &HTTPIngressRuleValue{
+					Paths: []HTTPIngressPath{{
+						Splits: []IngressBackendSplit{{
+							IngressBackend: IngressBackend{
+								ServiceName:      "revision-000",
+								ServiceNamespace: "default",
+								ServicePort:      intstr.FromInt(8080),
+							},
+						}},
+					}},
+				},
+				ErrorPages: test.pages,
+			}
+			got := r.Validate(ctx)
+			if diff := cmp.Diff(test.want.Error(), got.Error()); diff != "" {
+				t.Error("Validate (-want, +got) =", diff)
+			}
+		})
+	}
+}
+
+func TestSplitHeadersValidation(t *testing.T) {
+	backend := func(name string) IngressBackend {
+		return IngressBackend{
+			ServiceName:      name,
+			ServiceNamespace: "default",
+			ServicePort:      intstr.FromInt(8080),
+		}
+	}
+	tag := func(v string) map[string]HeaderMatch {
+		return map[string]HeaderMatch{"Knative-Serving-Tag": {Exact: v}}
+	}
+
+	tests := []struct {
+		name   string
+		splits []IngressBackendSplit
+		want   *apis.FieldError
+	}{{
+		name: "header-selected splits before the default",
+		splits: []IngressBackendSplit{{
+			IngressBackend: backend("revision-002"),
+			Headers:        tag("candidate"),
+		}, {
+			IngressBackend: backend("revision-001"),
+			Headers:        tag("previous"),
+		}, {
+			IngressBackend: backend("revision-000"),
+		}},
+	}, {
+		name: "header-selected split alongside a percentage split",
+		splits: []IngressBackendSplit{{
+			IngressBackend: backend("revision-002"),
+			Headers:        tag("candidate"),
+		}, {
+			IngressBackend: backend("revision-001"),
+			Percent:        20,
+		}, {
+			IngressBackend: backend("revision-000"),
+			Percent:        80,
+		}},
+	}, {
+		name: "only header-selected splits",
+		splits: []IngressBackendSplit{{
+			IngressBackend: backend("revision-001"),
+			Headers:        tag("candidate"),
+		}},
+		want: &apis.FieldError{
+			Message: "at least one split must not be selected by headers",
+			Paths:   []string{"splits"},
+		},
+	}, {
+		name: "percentage splits not totaling 100",
+		splits: []IngressBackendSplit{{
+			IngressBackend: backend("revision-002"),
+			Headers:        tag("candidate"),
+		}, {
+			IngressBackend: backend("revision-001"),
+			Percent:        20,
+		}, {
+			IngressBackend: backend("revision-000"),
+			Percent:        70,
+		}},
+		want: &apis.FieldError{
+			Message: "traffic split percentage must total to 100, but was 90",
+			Paths:   []string{"splits"},
+		},
+	}, {
+		name: "invalid header name",
+		splits: []IngressBackendSplit{{
+			IngressBackend: backend("revision-001"),
+			Headers:        map[string]HeaderMatch{"Bad Header": {Exact: "candidate"}},
+		}, {
+			IngressBackend: backend("revision-000"),
+		}},
+		want: apis.ErrInvalidKeyName("Bad Header", "splits[0].headers"),
+	}, {
+		name: "missing exact value",
+		splits: []IngressBackendSplit{{
+			IngressBackend: backend("revision-001"),
+			Headers:        tag(""),
+		}, {
+			IngressBackend: backend("revision-000"),
+		}},
+		want: apis.ErrMissingField("splits[0].headers[Knative-Serving-Tag].exact"),
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := apis.WithinParent(context.Background(), metav1.ObjectMeta{Namespace: "default", Name: "test-ingress"})
+			p := HTTPIngressPath{Splits: test.splits}
+			got := p.Validate(ctx)
+			if diff := cmp.Diff(test.want.Error(), got.Error()); diff != "" {
+				t.Error("Validate (-want, +got) =", diff)
+			}
+		})
+	}
+}
+
+func TestSourceIPPolicyValidation(t *testing.T) {
+	tests := []struct {
+		name string
+		p    *SourceIPPolicy
+		want *apis.FieldError
+	}{{
+		name: "allow only",
+		p:    &SourceIPPolicy{Allow: []string{"10.0.0.0/8", "fd00::/8"}},
+	}, {
+		name: "deny only",
+		p:    &SourceIPPolicy{Deny: []string{"192.168.1.1/32"}},
+	}, {
+		name: "allow and deny",
+		p: &SourceIPPolicy{
+			Allow: []string{"10.0.0.0/8"},
+			Deny:  []string{"10.1.0.0/16"},
+		},
+	}, {
+		name: "empty",
+		p:    &SourceIPPolicy{},
+		want: apis.ErrMissingOneOf("allow", "deny"),
+	}, {
+		name: "invalid CIDRs",
+		p: &SourceIPPolicy{
+			Allow: []string{"10.0.0.0/33"},
+			Deny:  []string{"10.0.0.0/8", "fd00::"},
+		},
+		want: apis.ErrInvalidArrayValue("10.0.0.0/33", "allow", 0).Also(
+			apis.ErrInvalidArrayValue("fd00::", "deny", 1)),
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := test.p.Validate(context.Background())
+			if diff := cmp.Diff(test.want.Error(), got.Error()); diff != "" {
+				t.Error("Validate (-want, +got) =", diff)
+			}
+		})
+	}
+}
+
+func TestTLSVisibilityValidation(t *testing.T) {
+	rule := func(visibility IngressVisibility, hosts ...string) IngressRule {
+		return IngressRule{
+			Hosts:      hosts,
+			Visibility: visibility,
+			HTTP: &HTTPIngressRuleValue{
+				Paths: []HTTPIngressPath{{
+					Splits: []IngressBackendSplit{{
+						IngressBackend: IngressBackend{
+							ServiceName:      "revision-000",
+							ServiceNamespace: "default",
+							ServicePort:      intstr.FromInt(8080),
+						},
+					}},
+				}},
+			},
+		}
+	}
+	tls := func(visibility IngressVisibility, hosts ...string) IngressTLS {
+		return IngressTLS{
+			Hosts:           hosts,
+			SecretName:      "secret-name",
+			SecretNamespace: "secret-space",
+			Visibility:      visibility,
+		}
+	}
+
+	tests := []struct {
+		name string
+		is   *IngressSpec
+		want *apis.FieldError
+	}{{
+		name: "no visibility",
+		is: &IngressSpec{
+			Rules: []IngressRule{rule(IngressVisibilityClusterLocal, "foo.ns.svc.cluster.local")},
+			TLS:   []IngressTLS{tls("", "foo.ns.svc.cluster.local", "foo.example.com")},
+		},
+	}, {
+		name: "separate listeners",
+		is: &IngressSpec{
+			Rules: []IngressRule{
+				rule(IngressVisibilityClusterLocal, "foo.ns", "foo.ns.svc.cluster.local"),
+				rule(IngressVisibilityExternalIP, "foo.example.com"),
+			},
+			TLS: []IngressTLS{
+				tls(IngressVisibilityClusterLocal, "foo.ns", "foo.ns.svc.cluster.local"),
+				tls(IngressVisibilityExternalIP, "foo.example.com"),
+			},
+		},
+	}, {
+		name: "rule visibility defaults to external",
+		is: &IngressSpec{
+			Rules: []IngressRule{rule("", "foo.example.com")},
+			TLS:   []IngressTLS{tls(IngressVisibilityExternalIP, "foo.example.com")},
+		},
+	}, {
+		name: "host of another visibility",
+		is: &IngressSpec{
+			Rules: []IngressRule{
+				rule(IngressVisibilityClusterLocal, "foo.ns.svc.cluster.local"),
+				rule(IngressVisibilityExternalIP, "foo.example.com"),
+			},
+			TLS: []IngressTLS{tls(IngressVisibilityExternalIP, "foo.example.com", "foo.ns.svc.cluster.local")},
+		},
+		want: &apis.FieldError{
+			Message: `host "foo.ns.svc.cluster.local" is not exposed by any rule with visibility ExternalIP`,
+			Paths:   []string{"tls[0].hosts[1]"},
+		},
+	}, {
+		name: "unknown host",
+		is: &IngressSpec{
+			Rules: []IngressRule{rule(IngressVisibilityClusterLocal, "foo.ns.svc.cluster.local")},
+			TLS: []IngressTLS{
+				tls(IngressVisibilityClusterLocal, "foo.ns.svc.cluster.local"),
+				tls(IngressVisibilityClusterLocal, "bar.ns.svc.cluster.local"),
+			},
+		},
+		want: &apis.FieldError{
+			Message: `host "bar.ns.svc.cluster.local" is not exposed by any rule with visibility ClusterLocal`,
+			Paths:   []string{"tls[1].hosts[0]"},
+		},
+	}, {
+		name: "invalid visibility",
+		is: &IngressSpec{
+			Rules: []IngressRule{rule(IngressVisibilityExternalIP, "foo.example.com")},
+			TLS:   []IngressTLS{tls("Public", "foo.example.com")},
+		},
+		want: apis.ErrInvalidValue("Public", "tls[0].visibility").Also(&apis.FieldError{
+			Message: `host "foo.example.com" is not exposed by any rule with visibility Public`,
+			Paths:   []string{"tls[0].hosts[0]"},
+		}),
+	}}
+
+	ctx := apis.WithinParent(context.Background(), metav1.ObjectMeta{Namespace: "default", Name: "test-ingress"})
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := test.is.Validate(ctx)
+			if diff := cmp.Diff(test.want.Error(), got.Error()); diff != "" {
+				t.Error("Validate (-want, +got) =", diff)
+			}
+		})
+	}
+}
+
+func TestExtensionsValidation(t *testing.T) {
+	RegisterExtension("example.com/buffer-size", func(value string) error {
+		_, err := strconv.Atoi(value)
+		return err
+	})
+	t.Cleanup(func() { delete(extensionValidators, "example.com/buffer-size") })
+
+	tests := []struct {
+		name       string
+		extensions map[string]string
+		want       *apis.FieldError
+	}{{
+		name: "valid",
+		extensions: map[string]string{
+			"example.com/buffer-size":    "1024",
+			"example.com/unknown":        "whatever",
+			"proxy.example.org/timeouts": "",
+		},
+	}, {
+		name: "invalid key",
+		extensions: map[string]string{
+			"example.com/not valid": "",
+		},
+		want: apis.ErrInvalidKeyName("example.com/not valid", "extensions",
+			"name part must consist of alphanumeric characters, '-', '_' or '.', and must start and end with an alphanumeric character (e.g. 'MyName',  or 'my.name',  or '123-abc', regex used for validation is '([A-Za-z0-9][-A-Za-z0-9_.]*)?[A-Za-z0-9]')"),
+	}, {
+		name: "missing prefix",
+		extensions: map[string]string{
+			"buffer-size": "1024",
+		},
+		want: apis.ErrInvalidKeyName("buffer-size", "extensions",
+			"extension keys must be prefixed with a DNS subdomain, e.g. example.com/name"),
+	}, {
+		name: "reserved prefixes",
+		extensions: map[string]string{
+			"networking.knative.dev/ingress.class":     "foo",
+			"istio.networking.knative.dev/buffer-size": "1024",
+			"networking.internal.knative.dev/rollout":  "{}",
+		},
+		want: apis.ErrInvalidKeyName("networking.knative.dev/ingress.class", "extensions",
+			`the prefix "networking.knative.dev" is reserved`).Also(
+			apis.ErrInvalidKeyName("istio.networking.knative.dev/buffer-size", "extensions",
+				`the prefix "istio.networking.knative.dev" is reserved`),
+			apis.ErrInvalidKeyName("networking.internal.knative.dev/rollout", "extensions",
+				`the prefix "networking.internal.knative.dev" is reserved`)),
+	}, {
+		name: "invalid value of a known extension",
+		extensions: map[string]string{
+			"example.com/buffer-size": "1Mi",
+		},
+		want: apis.ErrInvalidValue("1Mi", "extensions[example.com/buffer-size]",
+			`strconv.Atoi: parsing "1Mi": invalid syntax`),
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			is := &IngressSpec{
+				Rules: []IngressRule{{
+					Hosts:      []string{"foo.example.com"},
+					Visibility: IngressVisibilityExternalIP,
+					HTTP: &HTTPIngressRuleValue{
+						Paths: []HTTPIngressPath{{
+							Splits: []IngressBackendSplit{{
+								IngressBackend: IngressBackend{
+									ServiceName:      "revision-000",
+									ServiceNamespace: "default",
+									ServicePort:      intstr.FromInt(8080),
+								},
+							}},
+						}},
+					},
+				}},
+				Extensions: test.extensions,
+			}
+			ctx := apis.WithinParent(context.Background(), metav1.ObjectMeta{Namespace: "default", Name: "test-ingress"})
+			got := is.Validate(ctx)
+			if diff := cmp.Diff(test.want.Error(), got.Error()); diff != "" {
+				t.Error("Validate (-want, +got) =", diff)
+			}
+		})
+	}
+}
+
+func TestRedirectsValidation(t *testing.T) {
+	redirects := func(hosts []string, redirects ...HTTPRedirect) IngressRule {
+		return IngressRule{
+			Hosts:      hosts,
+			Visibility: IngressVisibilityExternalIP,
+			Redirects:  redirects,
+		}
+	}
+
+	tests := []struct {
+		name string
+		is   *IngressSpec
+		want *apis.FieldError
+	}{{
+		name: "redirect only rule",
+		is: &IngressSpec{
+			Rules: []IngressRule{
+				redirects([]string{"old.example.com"}, HTTPRedirect{Host: "new.example.com", StatusCode: http.StatusPermanentRedirect}),
+			},
+		},
+	}, {
+		name: "redirects and paths",
+		is: &IngressSpec{
+			Rules: []IngressRule{{
+				Hosts:      []string{"foo.example.com"},
+				Visibility: IngressVisibilityExternalIP,
+				Redirects: []HTTPRedirect{{
+					Path:          "/v1/",
+					ReplacePrefix: "/v2/",
+					StripQuery:    true,
+				}, {
+					Path:   "/secure",
+					Scheme: "https",
+				}},
+				HTTP: &HTTPIngressRuleValue{
+					Paths: []HTTPIngressPath{{
+						Splits: []IngressBackendSplit{{
+							IngressBackend: IngressBackend{
+								ServiceName:      "revision-000",
+								ServiceNamespace: "default",
+								ServicePort:      intstr.FromInt(8080),
+							},
+						}},
+					}},
+				},
+			}},
+		},
+	}, {
+		name: "chained redirects",
+		is: &IngressSpec{
+			Rules: []IngressRule{
+				redirects([]string{"a.example.com"}, HTTPRedirect{Host: "b.example.com"}),
+				redirects([]string{"b.example.com"}, HTTPRedirect{Host: "c.example.com"}),
+			},
+		},
+	}, {
+		name: "nothing redirected",
+		is: &IngressSpec{
+			Rules: []IngressRule{redirects([]string{"foo.example.com"}, HTTPRedirect{Path: "/foo"})},
+		},
+		want: apis.ErrMissingOneOf("rules[0].redirects[0].host", "rules[0].redirects[0].replacePrefix", "rules[0].redirects[0].scheme"),
+	}, {
+		name: "invalid redirect",
+		is: &IngressSpec{
+			Rules: []IngressRule{redirects([]string{"foo.example.com"}, HTTPRedirect{
+				Path:          "foo",
+				StatusCode:    http.StatusTemporaryRedirect,
+				Scheme:        "ftp",
+				Host:          "bad_host",
+				ReplacePrefix: "bar",
+			})},
+		},
+		want: apis.ErrInvalidValue("foo", "rules[0].redirects[0].path", "paths must begin with a '/'").Also(
+			apis.ErrInvalidValue("bar", "rules[0].redirects[0].replacePrefix", "paths must begin with a '/'"),
+			apis.ErrInvalidValue(http.StatusTemporaryRedirect, "rules[0].redirects[0].statusCode", "must be one of 301, 302 and 308"),
+			apis.ErrInvalidValue("ftp", "rules[0].redirects[0].scheme", "must be either http or https"),
+			apis.ErrInvalidValue("bad_host", "rules[0].redirects[0].host",
+				"a lowercase RFC 1123 subdomain must consist of lower case alphanumeric characters, '-' or '.', and must start and end with an alphanumeric character (e.g. 'example.com', regex used for validation is '[a-z0-9]([-a-z0-9]*[a-z0-9])?(\\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*')"),
+		),
+	}, {
+		name: "HTTPS redirect",
+		is: &IngressSpec{
+			Rules: []IngressRule{redirects([]string{"foo.example.com"}, HTTPRedirect{Scheme: "https"})},
+		},
+	}, {
+		name: "self redirect",
+		is: &IngressSpec{
+			Rules: []IngressRule{redirects([]string{"foo.example.com"}, HTTPRedirect{Scheme: "https", Host: "foo.example.com"})},
+		},
+		want: &apis.FieldError{
+			Message: "redirecting foo.example.com leads to a redirect loop through https://foo.example.com",
+			Paths:   []string{"rules[0].redirects[0]"},
+		},
+	}, {
+		name: "growing path",
+		is: &IngressSpec{
+			Rules: []IngressRule{redirects([]string{"foo.example.com"}, HTTPRedirect{Path: "/a", ReplacePrefix: "/a/b"})},
+		},
+		want: &apis.FieldError{
+			Message: "redirecting foo.example.com/a leads to a redirect loop through http://foo.example.com/a/b",
+			Paths:   []string{"rules[0].redirects[0]"},
+		},
+	}, {
+		name: "loop across rules",
+		is: &IngressSpec{
+			Rules: []IngressRule{
+				redirects([]string{"a.example.com"}, HTTPRedirect{Host: "b.example.com"}),
+				redirects([]string{"b.example.com"}, HTTPRedirect{Host: "a.example.com"}),
+			},
+		},
+		want: (&apis.FieldError{
+			Message: "redirecting a.example.com leads to a redirect loop through http://a.example.com",
+			Paths:   []string{"rules[0].redirects[0]"},
+		}).Also(&apis.FieldError{
+			Message: "redirecting b.example.com leads to a redirect loop through http://b.example.com",
+			Paths:   []string{"rules[1].redirects[0]"},
+		}),
+	}}
+
+	ctx := apis.WithinParent(context.Background(), metav1.ObjectMeta{Namespace: "default", Name: "test-ingress"})
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := test.is.Validate(ctx)
+			if diff := cmp.Diff(test.want.Error(), got.Error()); diff != "" {
+				t.Error("Validate (-want, +got) =", diff)
+			}
+		})
+	}
+}
+
+func TestAppendHeadersValidation(t *testing.T) {
+	backend := IngressBackend{
+		ServiceName:      "revision-000",
+		ServiceNamespace: "default",
+		ServicePort:      intstr.FromInt(8080),
+	}
+
+	tests := []struct {
+		name        string
+		headers     map[string]string
+		splitHeader map[string]string
+		want        *apis.FieldError
+	}{{
+		name:        "valid headers",
+		headers:     map[string]string{"Knative-Serving-Namespace": "default", "x-custom": "foo"},
+		splitHeader: map[string]string{"Knative-Serving-Revision": "revision-000"},
+	}, {
+		name:    "invalid header name",
+		headers: map[string]string{"Bad Header": "foo"},
+		want:    apis.ErrInvalidKeyName("Bad Header", "appendHeaders"),
+	}, {
+		name:    "host",
+		headers: map[string]string{"host": "example.com"},
+		want:    apis.ErrInvalidKeyName("host", "appendHeaders", "the Host header can only be rewritten with the rewriteHost of the path"),
+	}, {
+		name:    "probe hash",
+		headers: map[string]string{"K-Network-Hash": "override"},
+		want:    apis.ErrInvalidKeyName("K-Network-Hash", "appendHeaders", "the header is reserved to the networking layer"),
+	}, {
+		name:    "forwarded headers",
+		headers: map[string]string{"Forwarded": "for=1.2.3.4", "X-Forwarded-Proto": "https"},
+		want: apis.ErrInvalidKeyName("Forwarded", "appendHeaders", "the header is reserved to the networking layer").Also(
+			apis.ErrInvalidKeyName("X-Forwarded-Proto", "appendHeaders", "the header is reserved to the networking layer")),
+	}, {
+		name:        "reserved header of a split",
+		splitHeader: map[string]string{"k-network-probe": "probe"},
+		want:        apis.ErrInvalidKeyName("k-network-probe", "splits[0].appendHeaders", "the header is reserved to the networking layer"),
+	}, {
+		name:    "same header",
+		headers: map[string]string{"X-Custom": "foo", "x-custom": "bar"},
+		want: &apis.FieldError{
+			Message: `headers "X-Custom" and "x-custom" are the same header, header names are case-insensitive`,
+			Paths:   []string{"appendHeaders"},
+		},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := HTTPIngressPath{
+				AppendHeaders: test.headers,
+				Splits: []IngressBackendSplit{{
+					IngressBackend: backend,
+					AppendHeaders:  test.splitHeader,
+				}},
+			}
+			ctx := apis.WithinParent(context.Background(), metav1.ObjectMeta{Namespace: "default", Name: "test-ingress"})
+			got := p.Validate(ctx)
+			if diff := cmp.Diff(test.want.Error(), got.Error()); diff != "" {
+				t.Error("Validate (-want, +got) =", diff)
+			}
+		})
+	}
+}
+
+func TestRewriteHostValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		host    string
+		invalid bool
+	}{{
+		name: "valid",
+		host: "latest.route.default.svc.cluster.local",
+	}, {
+		name:    "port",
+		host:    "example.com:8080",
+		invalid: true,
+	}, {
+		name:    "uppercase",
+		host:    "Example.com",
+		invalid: true,
+	}, {
+		name:    "wildcard",
+		host:    "*.example.com",
+		invalid: true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := HTTPIngressPath{
+				RewriteHost: test.host,
+				Splits: []IngressBackendSplit{{
+					IngressBackend: IngressBackend{
+						ServiceName:      "revision-000",
+						ServiceNamespace: "default",
+						ServicePort:      intstr.FromInt(8080),
+					},
+				}},
+			}
+			var want *apis.FieldError
+			if test.invalid {
+				want = apis.ErrInvalidValue(test.host, "rewriteHost", strings.Join(validation.IsDNS1123Subdomain(test.host), ", "))
+			}
+			ctx := apis.WithinParent(context.Background(), metav1.ObjectMeta{Namespace: "default", Name: "test-ingress"})
+			got := p.Validate(ctx)
+			if diff := cmp.Diff(want.Error(), got.Error()); diff != "" {
+				t.Error("Validate (-want, +got) =", diff)
+			}
+		})
+	}
+}
+
+func TestExternalBackendValidation(t *testing.T) {
+	enabled := &features.Features{ExternalBackends: features.Enabled}
+	tests := []struct {
+		name     string
+		features *features.Features
+		backend  IngressBackend
+		want     *apis.FieldError
+	}{{
+		name:     "host",
+		features: enabled,
+		backend:  IngressBackend{External: &ExternalBackend{Host: "api.example.org", Port: 443}},
+	}, {
+		name:     "IP address",
+		features: enabled,
+		backend:  IngressBackend{External: &ExternalBackend{Host: "2001:db8::1", Port: 8080}},
+	}, {
+		name:     "ExternalName service",
+		features: &features.Features{ExternalBackends: features.Allowed},
+		backend:  IngressBackend{External: &ExternalBackend{ServiceName: "legacy", Port: 80}},
+	}, {
+		name:    "feature disabled",
+		backend: IngressBackend{External: &ExternalBackend{Host: "api.example.org", Port: 443}},
+		want: &apis.FieldError{
+			Message: "external backends require the external-backends feature",
+			Paths:   []string{"external"},
+		},
+	}, {
+		name:     "service fields",
+		features: enabled,
+		backend: IngressBackend{
+			ServiceName:      "revision-000",
+			ServiceNamespace: "default",
+			ServicePort:      intstr.FromInt(8080),
+			External:         &ExternalBackend{Host: "api.example.org", Port: 443},
+		},
+		want: apis.ErrMultipleOneOf("external", "serviceNamespace").Also(
+			apis.ErrMultipleOneOf("external", "serviceName"),
+			apis.ErrMultipleOneOf("external", "servicePort")),
+	}, {
+		name:     "no host",
+		features: enabled,
+		backend:  IngressBackend{External: &ExternalBackend{Port: 443}},
+		want:     apis.ErrMissingOneOf("external.serviceName", "external.host"),
+	}, {
+		name:     "host and service",
+		features: enabled,
+		backend:  IngressBackend{External: &ExternalBackend{ServiceName: "legacy", Host: "api.example.org", Port: 443}},
+		want:     apis.ErrMultipleOneOf("external.serviceName", "external.host"),
+	}, {
+		name:     "invalid host",
+		features: enabled,
+		backend:  IngressBackend{External: &ExternalBackend{Host: "api.example.org:443", Port: 443}},
+		want: apis.ErrInvalidValue("api.example.org:443", "external.host",
+			strings.Join(validation.IsDNS1123Subdomain("api.example.org:443"), ", ")),
+	}, {
+		name:     "invalid service name",
+		features: enabled,
+		backend:  IngressBackend{External: &ExternalBackend{ServiceName: "legacy.default", Port: 443}},
+		want: apis.ErrInvalidValue("legacy.default", "external.serviceName",
+			strings.Join(validation.IsDNS1035Label("legacy.default"), ", ")),
+	}, {
+		name:     "no port",
+		features: enabled,
+		backend:  IngressBackend{External: &ExternalBackend{Host: "api.example.org"}},
+		want:     apis.ErrOutOfBoundsValue(0, 1, math.MaxUint16, "external.port"),
+	}, {
+		name:     "port too large",
+		features: enabled,
+		backend:  IngressBackend{External: &ExternalBackend{Host: "api.example.org", Port: 65536}},
+		want:     apis.ErrOutOfBoundsValue(65536, 1, math.MaxUint16, "external.port"),
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := apis.WithinParent(context.Background(), metav1.ObjectMeta{Namespace: "default", Name: "test-ingress"})
+			if test.features != nil {
+				ctx = features.ToContext(ctx, test.features)
+			}
+			got := test.backend.Validate(ctx)
+			if diff := cmp.Diff(test.want.Error(), got.Error()); diff != "" {
+				t.Error("Validate (-want, +got) =", diff)
+			}
+		})
+	}
+}
+
+func TestHeaderModifiersValidation(t *testing.T) {
+	backend := IngressBackend{
+		ServiceName:      "revision-000",
+		ServiceNamespace: "default",
+		ServicePort:      intstr.FromInt(8080),
+	}
+
+	tests := []struct {
+		name  string
+		path  HTTPIngressPath
+		split IngressBackendSplit
+		want  *apis.FieldError
+	}{{
+		name: "valid",
+		path: HTTPIngressPath{
+			AppendHeaders: map[string]string{"X-Append": "foo"},
+			SetHeaders:    map[string]string{"X-Set": "bar"},
+			RemoveHeaders: []string{"X-Internal"},
+		},
+		split: IngressBackendSplit{
+			// The split can modify the headers of the path.
+			SetHeaders:    map[string]string{"X-Append": "baz"},
+			RemoveHeaders: []string{"X-Set"},
+		},
+	}, {
+		name: "invalid header names",
+		path: HTTPIngressPath{
+			SetHeaders:    map[string]string{"Bad Header": "foo"},
+			RemoveHeaders: []string{"X-Fine", "Bad Header"},
+		},
+		want: apis.ErrInvalidKeyName("Bad Header", "setHeaders").Also(
+			apis.ErrInvalidValue("Bad Header", "removeHeaders[1]")),
+	}, {
+		name: "reserved headers",
+		path: HTTPIngressPath{
+			SetHeaders:    map[string]string{"X-Forwarded-For": "1.2.3.4"},
+			RemoveHeaders: []string{"k-network-hash"},
+		},
+		want: apis.ErrInvalidKeyName("X-Forwarded-For", "setHeaders", "the header is reserved to the networking layer").Also(
+			apis.ErrInvalidValue("k-network-hash", "removeHeaders[0]", "the header is reserved to the networking layer")),
+	}, {
+		name: "host removed",
+		path: HTTPIngressPath{
+			RewriteHost:   "example.com",
+			RemoveHeaders: []string{"host"},
+		},
+		want: apis.ErrInvalidValue("host", "removeHeaders[0]", "the Host header can only be rewritten with the rewriteHost of the path"),
+	}, {
+		name: "host set by a split",
+		path: HTTPIngressPath{RewriteHost: "example.com"},
+		split: IngressBackendSplit{
+			SetHeaders: map[string]string{"Host": "other.example.com"},
+		},
+		want: apis.ErrInvalidKeyName("Host", "splits[0].setHeaders", "the Host header can only be rewritten with the rewriteHost of the path"),
+	}, {
+		name: "appended and set",
+		path: HTTPIngressPath{
+			AppendHeaders: map[string]string{"X-Custom": "foo"},
+			SetHeaders:    map[string]string{"x-custom": "bar"},
+		},
+		want: &apis.FieldError{
+			Message: `header "x-custom" can't be in both appendHeaders and setHeaders`,
+			Paths:   []string{"appendHeaders", "setHeaders"},
+		},
+	}, {
+		name: "set and removed in a split",
+		split: IngressBackendSplit{
+			SetHeaders:    map[string]string{"X-Custom": "foo"},
+			RemoveHeaders: []string{"X-Custom"},
+		},
+		want: &apis.FieldError{
+			Message: `header "X-Custom" can't be in both setHeaders and removeHeaders`,
+			Paths:   []string{"splits[0].setHeaders", "splits[0].removeHeaders"},
+		},
+	}, {
+		name: "removed twice",
+		path: HTTPIngressPath{RemoveHeaders: []string{"X-Custom", "x-custom", "X-Custom"}},
+		want: (&apis.FieldError{
+			Message: `headers "X-Custom" and "x-custom" are the same header, header names are case-insensitive`,
+			Paths:   []string{"removeHeaders"},
+		}).Also(&apis.FieldError{
+			Message: `header "X-Custom" is listed more than once`,
+			Paths:   []string{"removeHeaders"},
+		}),
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := test.path
+			split := test.split
+			split.IngressBackend = backend
+			p.Splits = []IngressBackendSplit{split}
+			ctx := apis.WithinParent(context.Background(), metav1.ObjectMeta{Namespace: "default", Name: "test-ingress"})
+			got := p.Validate(ctx)
+			if diff := cmp.Diff(test.want.Error(), got.Error()); diff != "" {
+				t.Error("Validate (-want, +got) =", diff)
+			}
+		})
+	}
+}
+
+func TestLoadBalancerOptionsValidation(t *testing.T) {
+	AllowLoadBalancerAnnotations("example.com/lb-tier")
+	t.Cleanup(func() { loadBalancerAnnotations.Delete("example.com/lb-tier") })
+
+	tests := []struct {
+		name string
+		lb   *LoadBalancerOptions
+		want *apis.FieldError
+	}{{
+		name: "empty",
+		lb:   &LoadBalancerOptions{},
+	}, {
+		name: "valid",
+		lb: &LoadBalancerOptions{
+			Internal:    true,
+			StaticIP:    "tenant-a-ip",
+			Annotations: map[string]string{"example.com/lb-tier": "premium"},
+		},
+	}, {
+		name: "static IP address",
+		lb:   &LoadBalancerOptions{StaticIP: "2001:db8::1"},
+	}, {
+		name: "invalid static IP",
+		lb:   &LoadBalancerOptions{StaticIP: "Tenant_A"},
+		want: apis.ErrInvalidValue("Tenant_A", "staticIP",
+			"must be an IP address or the name of a reservation"),
+	}, {
+		name: "annotation not allowed",
+		lb: &LoadBalancerOptions{
+			Annotations: map[string]string{"service.beta.kubernetes.io/aws-load-balancer-type": "nlb"},
+		},
+		want: apis.ErrInvalidKeyName("service.beta.kubernetes.io/aws-load-balancer-type", "annotations",
+			"the annotation is not allowed by the Ingress implementation"),
+	}, {
+		name: "invalid annotation key",
+		lb: &LoadBalancerOptions{
+			Annotations: map[string]string{"example.com/": ""},
+		},
+		want: apis.ErrInvalidKeyName("example.com/", "annotations", "name part must be non-empty",
+			"name part must consist of alphanumeric characters, '-', '_' or '.', and must start and end with an alphanumeric character (e.g. 'MyName',  or 'my.name',  or '123-abc', regex used for validation is '([A-Za-z0-9][-A-Za-z0-9_.]*)?[A-Za-z0-9]')"),
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := test.lb.Validate(context.Background())
+			if diff := cmp.Diff(test.want.Error(), got.Error()); diff != "" {
+				t.Error("Validate (-want, +got) =", diff)
+			}
+		})
+	}
+}
+
+func TestCanonicalHostValidation(t *testing.T) {
+	rule := func(hosts ...string) IngressRule {
+		return IngressRule{
+			Hosts:      hosts,
+			Visibility: IngressVisibilityExternalIP,
+			HTTP: &HTTPIngressRuleValue{
+				Paths: []HTTPIngressPath{{
+					Splits: []IngressBackendSplit{{
+						IngressBackend: IngressBackend{
+							ServiceName:      "revision-000",
+							ServiceNamespace: "default",
+							ServicePort:      intstr.FromInt(8080),
+						},
+					}},
+				}},
+			},
+		}
+	}
+	redirected := func(hosts ...string) IngressRule {
+		return IngressRule{
+			Hosts:      hosts,
+			Visibility: IngressVisibilityExternalIP,
+			Redirects:  []HTTPRedirect{{Host: "example.org"}},
+		}
+	}
+	tls := func(canonical string, hosts ...string) IngressTLS {
+		return IngressTLS{
+			Hosts:           hosts,
+			SecretName:      "secret-name",
+			SecretNamespace: "secret-space",
+			CanonicalHost:   canonical,
+		}
+	}
+
+	tests := []struct {
+		name string
+		is   *IngressSpec
+		want *apis.FieldError
+	}{{
+		name: "www to apex",
+		is: &IngressSpec{
+			Rules: []IngressRule{rule("example.com", "www.example.com")},
+			TLS:   []IngressTLS{tls("example.com", "example.com", "www.example.com")},
+		},
+	}, {
+		name: "apex to www with separate rules",
+		is: &IngressSpec{
+			Rules: []IngressRule{rule("www.example.com"), rule("example.com")},
+			TLS:   []IngressTLS{tls("www.example.com", "example.com", "www.example.com")},
+		},
+	}, {
+		name: "same canonical host twice",
+		is: &IngressSpec{
+			Rules: []IngressRule{rule("example.com", "www.example.com")},
+			TLS: []IngressTLS{
+				tls("example.com", "example.com", "www.example.com"),
+				tls("example.com", "example.com", "www.example.com"),
+			},
+		},
+	}, {
+		name: "canonical host not in the TLS hosts",
+		is: &IngressSpec{
+			Rules: []IngressRule{rule("example.com", "www.example.com")},
+			TLS:   []IngressTLS{tls("example.com", "www.example.com")},
+		},
+		want: apis.ErrInvalidValue("example.com", "tls[0].canonicalHost",
+			"must be one of the hosts of the TLS configuration"),
+	}, {
+		name: "canonical host without rule",
+		is: &IngressSpec{
+			Rules: []IngressRule{rule("www.example.com")},
+			TLS:   []IngressTLS{tls("example.com", "example.com", "www.example.com")},
+		},
+		want: apis.ErrInvalidValue("example.com", "tls[0].canonicalHost", "is not the host of any rule"),
+	}, {
+		name: "canonical host redirected",
+		is: &IngressSpec{
+			Rules: []IngressRule{rule("www.example.com"), redirected("example.com")},
+			TLS:   []IngressTLS{tls("example.com", "example.com", "www.example.com")},
+		},
+		want: apis.ErrInvalidValue("example.com", "tls[0].canonicalHost",
+			"must be served rather than redirected by rules[1]"),
+	}, {
+		name: "redirected host without rule",
+		is: &IngressSpec{
+			Rules: []IngressRule{rule("example.com")},
+			TLS:   []IngressTLS{tls("example.com", "example.com", "www.example.com")},
+		},
+		want: &apis.FieldError{
+			Message: `host "www.example.com" is redirected to "example.com" but is not the host of any rule`,
+			Paths:   []string{"tls[0].hosts[1]"},
+		},
+	}, {
+		name: "redirected host with redirects",
+		is: &IngressSpec{
+			Rules: []IngressRule{rule("example.com"), redirected("www.example.com")},
+			TLS:   []IngressTLS{tls("example.com", "example.com", "www.example.com")},
+		},
+		want: &apis.FieldError{
+			Message: `host "www.example.com" is redirected both to "example.com" and by the redirects of rules[1]`,
+			Paths:   []string{"tls[0].hosts[1]"},
+		},
+	}, {
+		name: "conflicting canonical hosts",
+		is: &IngressSpec{
+			Rules: []IngressRule{rule("example.com", "example.net", "www.example.com")},
+			TLS: []IngressTLS{
+				tls("example.com", "example.com", "www.example.com"),
+				tls("example.net", "example.net", "www.example.com"),
+			},
+		},
+		want: &apis.FieldError{
+			Message: `host "www.example.com" is redirected both to "example.com" and to "example.net"`,
+			Paths:   []string{"tls[1].hosts[1]"},
+		},
+	}}
+
+	ctx := apis.WithinParent(context.Background(), metav1.ObjectMeta{Namespace: "default", Name: "test-ingress"})
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := test.is.Validate(ctx)
+			if diff := cmp.Diff(test.want.Error(), got.Error()); diff != "" {
+				t.Error("Validate (-want, +got) =", diff)
+			}
+		})
+	}
+}
+
+func TestL4RulesValidation(t *testing.T) {
+	backend := IngressBackend{
+		ServiceName:      "mqtt",
+		ServiceNamespace: "default",
+		ServicePort:      intstr.FromInt(1883),
+	}
+	enabled := &features.Features{L4Rules: features.Enabled}
+
+	tests := []struct {
+		name     string
+		features *features.Features
+		rules    []IngressL4Rule
+		want     *apis.FieldError
+	}{{
+		name:     "valid",
+		features: enabled,
+		rules: []IngressL4Rule{{
+			Port:    1883,
+			Backend: backend,
+		}, {
+			// The same port with another protocol or visibility.
+			Port:     1883,
+			Protocol: L4ProtocolUDP,
+			Backend:  backend,
+		}, {
+			Port:       1883,
+			Visibility: IngressVisibilityClusterLocal,
+			Backend:    backend,
+		}},
+	}, {
+		name:     "feature allowed",
+		features: &features.Features{L4Rules: features.Allowed},
+		rules:    []IngressL4Rule{{Port: 1883, Backend: backend}},
+	}, {
+		name:  "feature disabled",
+		rules: []IngressL4Rule{{Port: 1883, Backend: backend}},
+		want: &apis.FieldError{
+			Message: "L4 rules require the l4-rules feature",
+			Paths:   []string{"l4Rules"},
+		},
+	}, {
+		name:     "invalid fields",
+		features: enabled,
+		rules: []IngressL4Rule{{
+			Port:       0,
+			Protocol:   "SCTP",
+			Visibility: "Public",
+		}},
+		want: apis.ErrOutOfBoundsValue(0, 1, math.MaxUint16, "l4Rules[0].port").Also(
+			apis.ErrInvalidValue("SCTP", "l4Rules[0].protocol"),
+			apis.ErrInvalidValue("Public", "l4Rules[0].visibility"),
+			apis.ErrMissingField("l4Rules[0].backend")),
+	}, {
+		name:     "port claimed twice",
+		features: enabled,
+		rules: []IngressL4Rule{{
+			Port:    1883,
+			Backend: backend,
+		}, {
+			Port:       1883,
+			Protocol:   L4ProtocolTCP,
+			Visibility: IngressVisibilityExternalIP,
+			Backend:    backend,
+		}},
+		want: apis.ErrGeneric("TCP port 1883 is already exposed by l4Rules[0]", "l4Rules[1].port"),
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := apis.WithinParent(context.Background(), metav1.ObjectMeta{Namespace: "default", Name: "test-ingress"})
+			if test.features != nil {
+				ctx = features.ToContext(ctx, test.features)
+			}
+			// The Ingresses may only have L4 rules.
+			is := &IngressSpec{L4Rules: test.rules}
+			got := is.Validate(ctx)
+			if diff := cmp.Diff(test.want.Error(), got.Error()); diff != "" {
+				t.Error("Validate (-want, +got) =", diff)
+			}
+		})
+	}
+}
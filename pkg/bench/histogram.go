@@ -0,0 +1,159 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bench
+
+import (
+	"math"
+	"math/bits"
+	"time"
+)
+
+// subBucketBits is the log2 of the number of linear sub-buckets of the
+// histograms, which keeps 3 significant digits of the values recorded, as
+// High Dynamic Range histograms do.
+const (
+	subBucketBits  = 11
+	subBucketCount = 1 << subBucketBits
+	subBucketHalf  = subBucketCount / 2
+)
+
+// Histogram records latencies with a relative error under 0.1% over the
+// whole range of durations, in a space logarithmic in the largest one, like
+// an HDR (High Dynamic Range) histogram. Values are recorded with a
+// microsecond resolution. Histograms aren't safe for concurrent use, the
+// concurrent recorders must record into their own histograms and Merge them.
+type Histogram struct {
+	counts   []uint64
+	count    uint64
+	sum      time.Duration
+	min, max time.Duration
+}
+
+// NewHistogram creates an empty Histogram.
+func NewHistogram() *Histogram {
+	return &Histogram{}
+}
+
+// bucketIndex returns the index of the bucket of the value, in microseconds.
+func bucketIndex(v int64) int {
+	if v < subBucketCount {
+		return int(v)
+	}
+	shift := bits.Len64(uint64(v)) - subBucketBits
+	sub := int(v >> shift)
+	return subBucketCount + (shift-1)*subBucketHalf + sub - subBucketHalf
+}
+
+// bucketValue returns the highest value, in microseconds, of the bucket at
+// index i.
+func bucketValue(i int) int64 {
+	if i < subBucketCount {
+		return int64(i)
+	}
+	k := i - subBucketCount
+	shift := k/subBucketHalf + 1
+	sub := int64(k%subBucketHalf + subBucketHalf)
+	return (sub+1)<<shift - 1
+}
+
+// Record adds a latency to the histogram. Negative latencies are recorded as
+// zero.
+func (h *Histogram) Record(d time.Duration) {
+	if d < 0 {
+		d = 0
+	}
+	i := bucketIndex(d.Microseconds())
+	if i >= len(h.counts) {
+		h.counts = append(h.counts, make([]uint64, i+1-len(h.counts))...)
+	}
+	h.counts[i]++
+	if h.count == 0 || d < h.min {
+		h.min = d
+	}
+	if d > h.max {
+		h.max = d
+	}
+	h.count++
+	h.sum += d
+}
+
+// Merge adds the latencies recorded by other to the histogram.
+func (h *Histogram) Merge(other *Histogram) {
+	if other.count == 0 {
+		return
+	}
+	if len(other.counts) > len(h.counts) {
+		h.counts = append(h.counts, make([]uint64, len(other.counts)-len(h.counts))...)
+	}
+	for i, c := range other.counts {
+		h.counts[i] += c
+	}
+	if h.count == 0 || other.min < h.min {
+		h.min = other.min
+	}
+	if other.max > h.max {
+		h.max = other.max
+	}
+	h.count += other.count
+	h.sum += other.sum
+}
+
+// Count returns the number of latencies recorded.
+func (h *Histogram) Count() uint64 {
+	return h.count
+}
+
+// Min returns the lowest latency recorded, or 0 if none.
+func (h *Histogram) Min() time.Duration {
+	return h.min
+}
+
+// Max returns the highest latency recorded, or 0 if none.
+func (h *Histogram) Max() time.Duration {
+	return h.max
+}
+
+// Mean returns the mean of the latencies recorded, or 0 if none.
+func (h *Histogram) Mean() time.Duration {
+	if h.count == 0 {
+		return 0
+	}
+	return h.sum / time.Duration(h.count)
+}
+
+// Percentile returns the latency under which p percent of the latencies
+// recorded are, e.g. 99 for the 99th percentile, or 0 if none. The latency
+// returned is the highest one of its bucket, capped by Max.
+func (h *Histogram) Percentile(p float64) time.Duration {
+	if h.count == 0 {
+		return 0
+	}
+	rank := uint64(math.Ceil(p / 100 * float64(h.count)))
+	if rank < 1 {
+		rank = 1
+	}
+	var seen uint64
+	for i, c := range h.counts {
+		if seen += c; seen >= rank {
+			if d := time.Duration(bucketValue(i)) * time.Microsecond; d < h.max {
+				return d
+			}
+			break
+		}
+	}
+	return h.max
+}
@@ -0,0 +1,132 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bench
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestBucketRoundTrip(t *testing.T) {
+	for _, v := range []int64{0, 1, 2047, 2048, 2049, 4095, 4096, 1 << 20, 1<<20 + 12345, math.MaxInt64 / 2} {
+		i := bucketIndex(v)
+		if hi := bucketValue(i); v > hi {
+			t.Errorf("bucketValue(bucketIndex(%d)) = %d, want >= %d", v, hi, v)
+		}
+		if i > 0 {
+			if lo := bucketValue(i - 1); v <= lo {
+				t.Errorf("bucketValue(bucketIndex(%d)-1) = %d, want < %d", v, lo, v)
+			}
+		}
+	}
+}
+
+func TestHistogramEmpty(t *testing.T) {
+	h := NewHistogram()
+	if h.Count() != 0 || h.Min() != 0 || h.Max() != 0 || h.Mean() != 0 || h.Percentile(99) != 0 {
+		t.Errorf("Empty histogram = {count: %d, min: %v, max: %v, mean: %v, p99: %v}, want all zeroes",
+			h.Count(), h.Min(), h.Max(), h.Mean(), h.Percentile(99))
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	h := NewHistogram()
+	for i := 1; i <= 100; i++ {
+		h.Record(time.Duration(i) * time.Millisecond)
+	}
+
+	tests := []struct {
+		name string
+		got  time.Duration
+		want time.Duration
+	}{
+		{"min", h.Min(), time.Millisecond},
+		{"max", h.Max(), 100 * time.Millisecond},
+		{"mean", h.Mean(), 50500 * time.Microsecond},
+		{"p0", h.Percentile(0), time.Millisecond},
+		{"p50", h.Percentile(50), 50 * time.Millisecond},
+		{"p99", h.Percentile(99), 99 * time.Millisecond},
+		{"p100", h.Percentile(100), 100 * time.Millisecond},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if !within(test.got, test.want) {
+				t.Errorf("%s = %v, want: %v", test.name, test.got, test.want)
+			}
+		})
+	}
+	if got, want := h.Count(), uint64(100); got != want {
+		t.Errorf("Count() = %d, want: %d", got, want)
+	}
+}
+
+func TestHistogramPrecision(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	h := NewHistogram()
+	samples := make([]time.Duration, 0, 10000)
+	for i := 0; i < cap(samples); i++ {
+		// Latencies spread from microseconds to minutes.
+		d := time.Duration(math.Exp(rnd.Float64()*25)) * time.Microsecond
+		samples = append(samples, d)
+		h.Record(d)
+	}
+	sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+
+	for _, p := range []float64{1, 10, 50, 90, 99, 99.9} {
+		want := samples[int(math.Ceil(p/100*float64(len(samples))))-1]
+		if got := h.Percentile(p); !within(got, want) {
+			t.Errorf("Percentile(%v) = %v, want: %v", p, got, want)
+		}
+	}
+}
+
+func TestHistogramMerge(t *testing.T) {
+	a, b, all := NewHistogram(), NewHistogram(), NewHistogram()
+	for i := 1; i <= 1000; i++ {
+		d := time.Duration(i*i) * time.Microsecond
+		if i%3 == 0 {
+			a.Record(d)
+		} else {
+			b.Record(d)
+		}
+		all.Record(d)
+	}
+	merged := NewHistogram()
+	merged.Merge(a)
+	merged.Merge(b)
+	merged.Merge(NewHistogram())
+
+	if merged.Count() != all.Count() || merged.Min() != all.Min() || merged.Max() != all.Max() || merged.Mean() != all.Mean() {
+		t.Errorf("Merged histogram = {count: %d, min: %v, max: %v, mean: %v}, want: {count: %d, min: %v, max: %v, mean: %v}",
+			merged.Count(), merged.Min(), merged.Max(), merged.Mean(), all.Count(), all.Min(), all.Max(), all.Mean())
+	}
+	for _, p := range []float64{50, 90, 99} {
+		if got, want := merged.Percentile(p), all.Percentile(p); got != want {
+			t.Errorf("Percentile(%v) = %v, want: %v", p, got, want)
+		}
+	}
+}
+
+// within returns whether got is within 0.1% of want, or within the
+// microsecond resolution of the histograms.
+func within(got, want time.Duration) bool {
+	diff := math.Abs(float64(got - want))
+	return diff <= float64(time.Microsecond) || diff <= float64(want)/1000
+}
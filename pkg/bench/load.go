@@ -0,0 +1,357 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package bench holds a lightweight load generator, recording the latencies
+// of the requests in histograms, for the tests measuring the performance of
+// the networking layer.
+package bench
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/net/http2"
+)
+
+// Protocol is the protocol the load is sent with.
+type Protocol string
+
+const (
+	// ProtocolHTTP1 sends the load over HTTP/1.1, with TLS for the https
+	// targets.
+	ProtocolHTTP1 Protocol = "http1"
+
+	// ProtocolHTTP2 sends the load over HTTP/2: h2c, i.e. HTTP/2 with prior
+	// knowledge, for the http targets, and HTTP/2 negotiated with ALPN for
+	// the https ones.
+	ProtocolHTTP2 Protocol = "http2"
+)
+
+// maxRecordedErrors bounds the errors kept by Result.
+const maxRecordedErrors = 10
+
+// Target is the request sent repeatedly by the load generator.
+type Target struct {
+	// Method is the method of the request, GET if empty.
+	Method string
+	// URL is the URL of the request.
+	URL string
+	// Host overrides the Host header of the request, if set.
+	Host string
+	// Header are the headers of the request.
+	Header http.Header
+	// Body is the body of the request.
+	Body []byte
+}
+
+// Options configures the load sent by Run.
+type Options struct {
+	// Concurrency is the number of requests in flight at all times, 1 if
+	// unset. Each worker sends its next request once the previous one is
+	// answered.
+	Concurrency int
+
+	// Duration is how long the load is sent.
+	Duration time.Duration
+
+	// Requests caps the number of requests sent, if set, in which case Run
+	// may return before Duration.
+	Requests int
+
+	// Timeout bounds how long each request may take, if set.
+	Timeout time.Duration
+
+	// Protocol is the protocol the load is sent with, HTTP/1.1 if unset.
+	Protocol Protocol
+
+	// DialContext establishes the connections, e.g. to send the load to the
+	// load balancer of an Ingress, net.Dialer's if unset.
+	DialContext func(ctx context.Context, network, address string) (net.Conn, error)
+
+	// TLSConfig configures the TLS connections, if set.
+	TLSConfig *tls.Config
+
+	// Client sends the requests instead of a client built from the options
+	// above, if set.
+	Client *http.Client
+
+	// Success tells whether a response is successful, the 2xx ones if unset.
+	Success func(*http.Response) bool
+}
+
+// Result summarizes the load sent by Run.
+type Result struct {
+	// Requests is the number of requests sent.
+	Requests int
+	// Failures is the number of requests which failed or whose response
+	// wasn't successful.
+	Failures int
+	// StatusCodes counts the responses by status code.
+	StatusCodes map[int]int
+	// Errors holds the first errors, including the unsuccessful responses.
+	Errors []string
+	// Latencies are the latencies of the requests answered, including the
+	// time to read the body, whether successful or not.
+	Latencies *Histogram
+	// Duration is how long the load was sent for.
+	Duration time.Duration
+}
+
+// Throughput returns the number of requests answered per second.
+func (r *Result) Throughput() float64 {
+	if r.Duration <= 0 {
+		return 0
+	}
+	return float64(r.Latencies.Count()) / r.Duration.Seconds()
+}
+
+// SuccessRatio returns the ratio of the requests which succeeded, 0 if none
+// was sent.
+func (r *Result) SuccessRatio() float64 {
+	if r.Requests == 0 {
+		return 0
+	}
+	return float64(r.Requests-r.Failures) / float64(r.Requests)
+}
+
+// merge adds the results of a worker to r.
+func (r *Result) merge(other *Result) {
+	r.Requests += other.Requests
+	r.Failures += other.Failures
+	for code, n := range other.StatusCodes {
+		r.StatusCodes[code] += n
+	}
+	for _, err := range other.Errors {
+		if len(r.Errors) < maxRecordedErrors {
+			r.Errors = append(r.Errors, err)
+		}
+	}
+	r.Latencies.Merge(other.Latencies)
+}
+
+func newResult() *Result {
+	return &Result{StatusCodes: map[int]int{}, Latencies: NewHistogram()}
+}
+
+// Run sends requests to the target with the given concurrency until the
+// duration elapsed, the maximum number of requests was sent, or ctx is
+// done, and returns their summary. An error is returned if the options are
+// invalid, the failures of the requests being reported by the Result.
+func Run(ctx context.Context, target Target, opts Options) (*Result, error) {
+	if opts.Duration <= 0 && opts.Requests <= 0 {
+		return nil, errors.New("either the duration or the number of requests must be set")
+	}
+	if _, err := newRequest(ctx, target); err != nil {
+		return nil, err
+	}
+	client, err := opts.client()
+	if err != nil {
+		return nil, err
+	}
+	success := opts.Success
+	if success == nil {
+		success = func(r *http.Response) bool {
+			return r.StatusCode >= 200 && r.StatusCode < 300
+		}
+	}
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	if opts.Duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Duration)
+		defer cancel()
+	}
+
+	// tokens hands out the requests to send, if capped.
+	var tokens chan struct{}
+	if opts.Requests > 0 {
+		tokens = make(chan struct{}, opts.Requests)
+		for i := 0; i < opts.Requests; i++ {
+			tokens <- struct{}{}
+		}
+		close(tokens)
+	}
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		total   = newResult()
+		started = time.Now()
+	)
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r := newResult()
+			for ctx.Err() == nil {
+				if tokens != nil {
+					if _, ok := <-tokens; !ok {
+						break
+					}
+				}
+				send(ctx, client, target, opts.Timeout, success, r)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			total.merge(r)
+		}()
+	}
+	wg.Wait()
+	total.Duration = time.Since(started)
+	return total, nil
+}
+
+// send sends a request to the target, recording its outcome into r.
+func send(ctx context.Context, client *http.Client, target Target, timeout time.Duration, success func(*http.Response) bool, r *Result) {
+	reqCtx := ctx
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		reqCtx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	req, _ := newRequest(reqCtx, target)
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			// The load stopped while the request was in flight.
+			return
+		}
+		r.Requests++
+		r.fail(err.Error())
+		return
+	}
+	_, err = io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+	if err != nil && ctx.Err() != nil {
+		return
+	}
+	r.Requests++
+	r.StatusCodes[resp.StatusCode]++
+	switch {
+	case err != nil:
+		r.fail(fmt.Sprintf("failed to read the response: %v", err))
+	case !success(resp):
+		r.fail(fmt.Sprintf("unexpected status %d", resp.StatusCode))
+		r.Latencies.Record(time.Since(start))
+	default:
+		r.Latencies.Record(time.Since(start))
+	}
+}
+
+// fail records a failed request.
+func (r *Result) fail(msg string) {
+	r.Failures++
+	if len(r.Errors) < maxRecordedErrors {
+		r.Errors = append(r.Errors, msg)
+	}
+}
+
+// newRequest builds the request to the target.
+func newRequest(ctx context.Context, target Target) (*http.Request, error) {
+	method := target.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	var body io.Reader
+	if target.Body != nil {
+		body = bytes.NewReader(target.Body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target.URL, body)
+	if err != nil {
+		return nil, fmt.Errorf("invalid target: %w", err)
+	}
+	for name, values := range target.Header {
+		req.Header[name] = values
+	}
+	if target.Host != "" {
+		req.Host = target.Host
+	}
+	return req, nil
+}
+
+// client returns the client sending the load.
+func (o *Options) client() (*http.Client, error) {
+	if o.Client != nil {
+		return o.Client, nil
+	}
+	dial := o.DialContext
+	if dial == nil {
+		dial = (&net.Dialer{}).DialContext
+	}
+	concurrency := o.Concurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+
+	switch o.Protocol {
+	case ProtocolHTTP1, "":
+		return &http.Client{Transport: &http.Transport{
+			DialContext:         dial,
+			TLSClientConfig:     o.TLSConfig,
+			MaxIdleConnsPerHost: concurrency,
+			// Keep the load on HTTP/1.1 even if the server negotiates HTTP/2.
+			TLSNextProto: map[string]func(string, *tls.Conn) http.RoundTripper{},
+		}}, nil
+	case ProtocolHTTP2:
+		h2 := &http2.Transport{TLSClientConfig: o.TLSConfig}
+		h2c := &http2.Transport{
+			AllowHTTP: true,
+			DialTLS: func(netw, addr string, _ *tls.Config) (net.Conn, error) {
+				return dial(context.Background(), netw, addr)
+			},
+		}
+		h2.DialTLS = func(netw, addr string, cfg *tls.Config) (net.Conn, error) {
+			conn, err := dial(context.Background(), netw, addr)
+			if err != nil {
+				return nil, err
+			}
+			tlsConn := tls.Client(conn, cfg)
+			if err := tlsConn.Handshake(); err != nil {
+				conn.Close()
+				return nil, err
+			}
+			return tlsConn, nil
+		}
+		return &http.Client{Transport: roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			if r.URL.Scheme == "http" {
+				return h2c.RoundTrip(r)
+			}
+			return h2.RoundTrip(r)
+		})}, nil
+	default:
+		return nil, fmt.Errorf("unsupported protocol %q", o.Protocol)
+	}
+}
+
+// roundTripperFunc implements http.RoundTripper with a function.
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+// RoundTrip implements http.RoundTripper.
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
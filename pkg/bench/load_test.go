@@ -0,0 +1,222 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bench
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+func TestRunRequests(t *testing.T) {
+	var served int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt64(&served, 1)
+		if r.Header.Get("Foo") != "bar" || r.Host != "example.com" || r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if body, _ := ioutil.ReadAll(r.Body); string(body) != "payload" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if n%10 == 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	res, err := Run(context.Background(), Target{
+		Method: http.MethodPost,
+		URL:    server.URL,
+		Host:   "example.com",
+		Header: http.Header{"Foo": []string{"bar"}},
+		Body:   []byte("payload"),
+	}, Options{
+		Concurrency: 4,
+		Requests:    100,
+	})
+	if err != nil {
+		t.Fatal("Run() =", err)
+	}
+
+	if got, want := res.Requests, 100; got != want {
+		t.Errorf("Requests = %d, want: %d", got, want)
+	}
+	if got, want := res.Failures, 10; got != want {
+		t.Errorf("Failures = %d, want: %d", got, want)
+	}
+	if want := map[int]int{http.StatusOK: 90, http.StatusServiceUnavailable: 10}; !cmp.Equal(res.StatusCodes, want) {
+		t.Error("StatusCodes (-want, +got):", cmp.Diff(want, res.StatusCodes))
+	}
+	if got, want := len(res.Errors), maxRecordedErrors; got != want {
+		t.Errorf("len(Errors) = %d, want: %d", got, want)
+	}
+	if got, want := res.Latencies.Count(), uint64(100); got != want {
+		t.Errorf("Latencies.Count() = %d, want: %d", got, want)
+	}
+	if got, want := res.SuccessRatio(), 0.9; got != want {
+		t.Errorf("SuccessRatio() = %v, want: %v", got, want)
+	}
+	if res.Throughput() <= 0 {
+		t.Errorf("Throughput() = %v, want > 0", res.Throughput())
+	}
+}
+
+func TestRunDuration(t *testing.T) {
+	const (
+		latency  = 10 * time.Millisecond
+		duration = 200 * time.Millisecond
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(latency)
+	}))
+	defer server.Close()
+
+	res, err := Run(context.Background(), Target{URL: server.URL}, Options{
+		Concurrency: 2,
+		Duration:    duration,
+	})
+	if err != nil {
+		t.Fatal("Run() =", err)
+	}
+	if res.Duration < duration || res.Duration > duration+time.Second {
+		t.Errorf("Duration = %v, want about %v", res.Duration, duration)
+	}
+	if res.Requests == 0 || res.Failures != 0 {
+		t.Errorf("Got %d requests with %d failures, want some and no failures: %v", res.Requests, res.Failures, res.Errors)
+	}
+	if got := res.Latencies.Min(); got < latency {
+		t.Errorf("Latencies.Min() = %v, want >= %v", got, latency)
+	}
+}
+
+func TestRunTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	res, err := Run(context.Background(), Target{URL: server.URL}, Options{
+		Requests: 2,
+		Timeout:  10 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal("Run() =", err)
+	}
+	if res.Requests != 2 || res.Failures != 2 {
+		t.Errorf("Got %d requests with %d failures, want 2 failed requests", res.Requests, res.Failures)
+	}
+	if got := res.Latencies.Count(); got != 0 {
+		t.Errorf("Latencies.Count() = %d, want: 0", got)
+	}
+}
+
+func TestRunProtocol(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Proto", r.Proto)
+	})
+	h2cServer := httptest.NewServer(h2c.NewHandler(handler, &http2.Server{}))
+	defer h2cServer.Close()
+	tlsServer := httptest.NewUnstartedServer(handler)
+	tlsServer.EnableHTTP2 = true
+	tlsServer.StartTLS()
+	defer tlsServer.Close()
+
+	tests := []struct {
+		name     string
+		url      string
+		protocol Protocol
+		want     string
+	}{{
+		name: "default",
+		url:  h2cServer.URL,
+		want: "HTTP/1.1",
+	}, {
+		name:     "http1 over TLS",
+		url:      tlsServer.URL,
+		protocol: ProtocolHTTP1,
+		want:     "HTTP/1.1",
+	}, {
+		name:     "h2c",
+		url:      h2cServer.URL,
+		protocol: ProtocolHTTP2,
+		want:     "HTTP/2.0",
+	}, {
+		name:     "http2 over TLS",
+		url:      tlsServer.URL,
+		protocol: ProtocolHTTP2,
+		want:     "HTTP/2.0",
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			want := test.want
+			res, err := Run(context.Background(), Target{URL: test.url}, Options{
+				Requests:  3,
+				Protocol:  test.protocol,
+				TLSConfig: tlsServer.Client().Transport.(*http.Transport).TLSClientConfig,
+				Success: func(r *http.Response) bool {
+					return r.StatusCode == http.StatusOK && r.Header.Get("Proto") == want
+				},
+			})
+			if err != nil {
+				t.Fatal("Run() =", err)
+			}
+			if res.Failures != 0 {
+				t.Errorf("Got %d failures, want none: %v", res.Failures, res.Errors)
+			}
+		})
+	}
+}
+
+func TestRunInvalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		target Target
+		opts   Options
+	}{{
+		name:   "no duration nor requests",
+		target: Target{URL: "http://example.com"},
+	}, {
+		name:   "invalid URL",
+		target: Target{URL: "http://example.com/%zz"},
+		opts:   Options{Requests: 1},
+	}, {
+		name:   "unsupported protocol",
+		target: Target{URL: "http://example.com"},
+		opts:   Options{Requests: 1, Protocol: "http3"},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := Run(context.Background(), test.target, test.opts); err == nil {
+				t.Error("Run() = nil, want an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,284 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package certificates
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"knative.dev/networking/pkg/apis/networking"
+)
+
+const (
+	// TrustBundleKey is the key of the merged trust bundle in the ConfigMap
+	// written by SyncConfigMap.
+	TrustBundleKey = "ca-bundle.crt"
+
+	// secretCAKey is the key of the CA certificates in the Secrets, the
+	// other keys, e.g. of the private keys, being ignored.
+	secretCAKey = "ca.crt"
+)
+
+// TrustBundle merges the CA certificates of the ConfigMaps and Secrets
+// labeled with networking.TrustBundleLabelKey into a trust bundle, e.g. for
+// the data plane to trust the certificates issued for internal encryption by
+// any of the CAs in rotation. The certificates are deduplicated, the expired
+// ones pruned, and the callbacks registered with OnChange are notified when
+// the bundle changes. It implements cache.ResourceEventHandler, so it can be
+// registered on ConfigMap and Secret informers.
+//
+// All the PEM encoded certificates of the data of the ConfigMaps are merged,
+// but only the ones under the ca.crt key of the Secrets.
+type TrustBundle struct {
+	now func() time.Time
+
+	// mu guards sources, bundle and callbacks.
+	mu sync.Mutex
+	// sources are the certificates of each labeled object, per kind,
+	// namespace and name.
+	sources   map[string][]*x509.Certificate
+	bundle    []byte
+	callbacks []func([]byte)
+	// notifyMu serializes the notifications, so that they are not reordered.
+	notifyMu sync.Mutex
+}
+
+var _ cache.ResourceEventHandler = (*TrustBundle)(nil)
+
+// NewTrustBundle creates a new, empty, TrustBundle.
+func NewTrustBundle() *TrustBundle {
+	return &TrustBundle{
+		now:     time.Now,
+		sources: make(map[string][]*x509.Certificate),
+	}
+}
+
+// OnChange registers cb to be called with the merged bundle, PEM encoded,
+// every time it changes. The callbacks must not block.
+func (b *TrustBundle) OnChange(cb func(bundle []byte)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.callbacks = append(b.callbacks, cb)
+}
+
+// Bundle returns the merged bundle, PEM encoded.
+func (b *TrustBundle) Bundle() []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.bundle
+}
+
+// OnAdd implements cache.ResourceEventHandler.
+func (b *TrustBundle) OnAdd(obj interface{}) {
+	key, certs, ok := trustedCertificates(obj)
+	if !ok {
+		return
+	}
+	b.update(func() {
+		if len(certs) > 0 {
+			b.sources[key] = certs
+		} else {
+			delete(b.sources, key)
+		}
+	})
+}
+
+// OnUpdate implements cache.ResourceEventHandler.
+func (b *TrustBundle) OnUpdate(_, obj interface{}) {
+	b.OnAdd(obj)
+}
+
+// OnDelete implements cache.ResourceEventHandler.
+func (b *TrustBundle) OnDelete(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	key, _, ok := trustedCertificates(obj)
+	if !ok {
+		return
+	}
+	b.update(func() {
+		delete(b.sources, key)
+	})
+}
+
+// Resync merges the bundle again, pruning the certificates which expired
+// since the last change, e.g. periodically or on the resyncs of the
+// informers, which call OnUpdate.
+func (b *TrustBundle) Resync() {
+	b.update(func() {})
+}
+
+// update applies change to the sources and merges the bundle again,
+// notifying the callbacks if it changed.
+func (b *TrustBundle) update(change func()) {
+	b.notifyMu.Lock()
+	defer b.notifyMu.Unlock()
+
+	b.mu.Lock()
+	change()
+	bundle := mergeCertificates(b.now(), b.sources)
+	changed := !bytes.Equal(bundle, b.bundle)
+	b.bundle = bundle
+	callbacks := b.callbacks
+	b.mu.Unlock()
+
+	if changed {
+		for _, cb := range callbacks {
+			cb(bundle)
+		}
+	}
+}
+
+// trustedCertificates returns the key of obj and its certificates, if obj is
+// a ConfigMap or a Secret. The objects not labeled as part of the trust
+// bundle have no certificates.
+func trustedCertificates(obj interface{}) (string, []*x509.Certificate, bool) {
+	var (
+		key  string
+		meta metav1.Object
+		data [][]byte
+	)
+	switch o := obj.(type) {
+	case *corev1.ConfigMap:
+		key, meta = "configmap/"+o.Namespace+"/"+o.Name, o
+		for _, v := range o.Data {
+			data = append(data, []byte(v))
+		}
+		for _, v := range o.BinaryData {
+			data = append(data, v)
+		}
+	case *corev1.Secret:
+		key, meta = "secret/"+o.Namespace+"/"+o.Name, o
+		data = append(data, o.Data[secretCAKey])
+	default:
+		return "", nil, false
+	}
+	if meta.GetLabels()[networking.TrustBundleLabelKey] != "true" {
+		return key, nil, true
+	}
+	var certs []*x509.Certificate
+	for _, d := range data {
+		certs = append(certs, parseCertificates(d)...)
+	}
+	return key, certs, true
+}
+
+// parseCertificates returns the PEM encoded certificates of data, skipping
+// the other blocks and the certificates which fail to parse.
+func parseCertificates(data []byte) []*x509.Certificate {
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return certs
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		if cert, err := x509.ParseCertificate(block.Bytes); err == nil {
+			certs = append(certs, cert)
+		}
+	}
+}
+
+// mergeCertificates returns the PEM encoding of the certificates of the
+// sources not expired at now, deduplicated and sorted by subject and
+// fingerprint, so that the bundle doesn't change with the order of the
+// sources.
+func mergeCertificates(now time.Time, sources map[string][]*x509.Certificate) []byte {
+	type entry struct {
+		subject     string
+		fingerprint [sha256.Size]byte
+		raw         []byte
+	}
+	seen := make(map[[sha256.Size]byte]struct{})
+	var entries []entry
+	for _, certs := range sources {
+		for _, cert := range certs {
+			if now.After(cert.NotAfter) {
+				continue
+			}
+			fp := sha256.Sum256(cert.Raw)
+			if _, ok := seen[fp]; ok {
+				continue
+			}
+			seen[fp] = struct{}{}
+			entries = append(entries, entry{subject: cert.Subject.String(), fingerprint: fp, raw: cert.Raw})
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].subject != entries[j].subject {
+			return entries[i].subject < entries[j].subject
+		}
+		return bytes.Compare(entries[i].fingerprint[:], entries[j].fingerprint[:]) < 0
+	})
+
+	var buf bytes.Buffer
+	for _, e := range entries {
+		pem.Encode(&buf, &pem.Block{Type: "CERTIFICATE", Bytes: e.raw})
+	}
+	return buf.Bytes()
+}
+
+// SyncConfigMap creates or updates the ConfigMap with the given namespace and
+// name to hold the merged bundle under the TrustBundleKey key, e.g. from a
+// callback registered with OnChange. The ConfigMap must not be labeled with
+// networking.TrustBundleLabelKey itself, or the expired certificates would
+// never be pruned.
+func (b *TrustBundle) SyncConfigMap(ctx context.Context, client corev1client.ConfigMapsGetter, namespace, name string) error {
+	bundle := string(b.Bundle())
+	cms := client.ConfigMaps(namespace)
+	cm, err := cms.Get(ctx, name, metav1.GetOptions{})
+	if apierrs.IsNotFound(err) {
+		_, err = cms.Create(ctx, &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+			Data:       map[string]string{TrustBundleKey: bundle},
+		}, metav1.CreateOptions{})
+		if err != nil {
+			return fmt.Errorf("failed to create the trust bundle ConfigMap: %w", err)
+		}
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to get the trust bundle ConfigMap: %w", err)
+	}
+	if cm.Data[TrustBundleKey] == bundle {
+		return nil
+	}
+	cm = cm.DeepCopy()
+	if cm.Data == nil {
+		cm.Data = make(map[string]string, 1)
+	}
+	cm.Data[TrustBundleKey] = bundle
+	if _, err := cms.Update(ctx, cm, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("failed to update the trust bundle ConfigMap: %w", err)
+	}
+	return nil
+}
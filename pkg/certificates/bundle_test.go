@@ -0,0 +1,221 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package certificates
+
+import (
+	"context"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"knative.dev/networking/pkg/apis/networking"
+)
+
+func newTestCA(t *testing.T, name string) *x509.Certificate {
+	return newTestCert(t, &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, nil).cert
+}
+
+func encodeCerts(certs ...*x509.Certificate) []byte {
+	var out []byte
+	for _, cert := range certs {
+		out = append(out, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})...)
+	}
+	return out
+}
+
+var trustBundleLabels = map[string]string{networking.TrustBundleLabelKey: "true"}
+
+func TestTrustBundle(t *testing.T) {
+	ca1, ca2, ca3 := newTestCA(t, "ca-1"), newTestCA(t, "ca-2"), newTestCA(t, "ca-3")
+
+	b := NewTrustBundle()
+	var notified [][]byte
+	b.OnChange(func(bundle []byte) {
+		notified = append(notified, bundle)
+	})
+
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "cm", Labels: trustBundleLabels},
+		Data: map[string]string{
+			"a.crt": string(encodeCerts(ca2)),
+			"b.crt": "not a certificate",
+		},
+		BinaryData: map[string][]byte{
+			"c.crt": append(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: []byte("key")}), encodeCerts(ca1)...),
+		},
+	}
+	b.OnAdd(cm)
+	if got, want := string(b.Bundle()), string(encodeCerts(ca1, ca2)); got != want {
+		t.Errorf("Bundle() = %s, want: %s", got, want)
+	}
+
+	// The duplicates are dropped and only the ca.crt key of the Secrets is
+	// considered.
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "secret", Labels: trustBundleLabels},
+		Data: map[string][]byte{
+			secretCAKey: encodeCerts(ca1, ca3),
+			"tls.crt":   encodeCerts(newTestCA(t, "ignored")),
+		},
+	}
+	b.OnAdd(secret)
+	if got, want := string(b.Bundle()), string(encodeCerts(ca1, ca2, ca3)); got != want {
+		t.Errorf("Bundle() = %s, want: %s", got, want)
+	}
+
+	// Unchanged bundles are not notified.
+	b.OnUpdate(secret, secret.DeepCopy())
+	b.OnAdd(&corev1.Pod{})
+	b.OnAdd(&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "unlabeled"}, Data: cm.Data})
+	if got, want := len(notified), 2; got != want {
+		t.Errorf("#notifications = %d, want: %d", got, want)
+	}
+
+	// Removing the label drops the certificates of the object.
+	unlabeled := cm.DeepCopy()
+	unlabeled.Labels = nil
+	b.OnUpdate(cm, unlabeled)
+	if got, want := string(b.Bundle()), string(encodeCerts(ca1, ca3)); got != want {
+		t.Errorf("Bundle() = %s, want: %s", got, want)
+	}
+
+	b.OnDelete(cache.DeletedFinalStateUnknown{Key: "ns/secret", Obj: secret})
+	if got := b.Bundle(); len(got) != 0 {
+		t.Errorf("Bundle() = %s, want empty", got)
+	}
+
+	if got, want := len(notified), 4; got != want {
+		t.Fatalf("#notifications = %d, want: %d", got, want)
+	}
+	if got, want := string(notified[1]), string(encodeCerts(ca1, ca2, ca3)); got != want {
+		t.Errorf("notification = %s, want: %s", got, want)
+	}
+}
+
+func TestTrustBundleResyncPrunesExpired(t *testing.T) {
+	ca := newTestCA(t, "ca")
+	b := NewTrustBundle()
+	var notified int
+	b.OnChange(func([]byte) { notified++ })
+
+	b.OnAdd(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "secret", Labels: trustBundleLabels},
+		Data:       map[string][]byte{secretCAKey: encodeCerts(ca)},
+	})
+	if got, want := string(b.Bundle()), string(encodeCerts(ca)); got != want {
+		t.Errorf("Bundle() = %s, want: %s", got, want)
+	}
+
+	b.Resync()
+	if notified != 1 {
+		t.Errorf("#notifications = %d, want: 1", notified)
+	}
+
+	b.now = func() time.Time { return ca.NotAfter.Add(time.Second) }
+	b.Resync()
+	if got := b.Bundle(); len(got) != 0 {
+		t.Errorf("Bundle() = %s, want empty", got)
+	}
+	if notified != 2 {
+		t.Errorf("#notifications = %d, want: 2", notified)
+	}
+}
+
+// fakeConfigMaps is a minimal in-memory ConfigMap client.
+type fakeConfigMaps struct {
+	corev1client.ConfigMapInterface
+	cms     map[string]*corev1.ConfigMap
+	updates int
+}
+
+func (f *fakeConfigMaps) ConfigMaps(string) corev1client.ConfigMapInterface {
+	return f
+}
+
+func (f *fakeConfigMaps) Get(_ context.Context, name string, _ metav1.GetOptions) (*corev1.ConfigMap, error) {
+	cm, ok := f.cms[name]
+	if !ok {
+		return nil, apierrs.NewNotFound(corev1.Resource("configmaps"), name)
+	}
+	return cm, nil
+}
+
+func (f *fakeConfigMaps) Create(_ context.Context, cm *corev1.ConfigMap, _ metav1.CreateOptions) (*corev1.ConfigMap, error) {
+	f.cms[cm.Name] = cm
+	return cm, nil
+}
+
+func (f *fakeConfigMaps) Update(_ context.Context, cm *corev1.ConfigMap, _ metav1.UpdateOptions) (*corev1.ConfigMap, error) {
+	f.updates++
+	f.cms[cm.Name] = cm
+	return cm, nil
+}
+
+func TestTrustBundleSyncConfigMap(t *testing.T) {
+	ctx := context.Background()
+	ca1, ca2 := newTestCA(t, "ca-1"), newTestCA(t, "ca-2")
+	client := &fakeConfigMaps{cms: map[string]*corev1.ConfigMap{}}
+
+	b := NewTrustBundle()
+	b.OnAdd(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "secret", Labels: trustBundleLabels},
+		Data:       map[string][]byte{secretCAKey: encodeCerts(ca1)},
+	})
+
+	if err := b.SyncConfigMap(ctx, client, "ns", "knative-ca-bundle"); err != nil {
+		t.Fatal("SyncConfigMap() =", err)
+	}
+	if got, want := client.cms["knative-ca-bundle"].Data[TrustBundleKey], string(encodeCerts(ca1)); got != want {
+		t.Errorf("created bundle = %s, want: %s", got, want)
+	}
+
+	// Syncing an unchanged bundle doesn't update the ConfigMap.
+	if err := b.SyncConfigMap(ctx, client, "ns", "knative-ca-bundle"); err != nil {
+		t.Fatal("SyncConfigMap() =", err)
+	}
+	if client.updates != 0 {
+		t.Errorf("#updates = %d, want: 0", client.updates)
+	}
+
+	b.OnAdd(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "cm", Labels: trustBundleLabels},
+		Data:       map[string]string{"ca.crt": string(encodeCerts(ca2))},
+	})
+	if err := b.SyncConfigMap(ctx, client, "ns", "knative-ca-bundle"); err != nil {
+		t.Fatal("SyncConfigMap() =", err)
+	}
+	if got, want := client.cms["knative-ca-bundle"].Data[TrustBundleKey], string(encodeCerts(ca1, ca2)); got != want {
+		t.Errorf("updated bundle = %s, want: %s", got, want)
+	}
+	if client.updates != 1 {
+		t.Errorf("#updates = %d, want: 1", client.updates)
+	}
+}
@@ -0,0 +1,347 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package certificates holds utilities related to the certificates served
+// and checked by the data plane, e.g. to staple OCSP responses to the
+// serving certificates and to check the revocation of the certificates of
+// the peers during the TLS handshakes.
+package certificates
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	// Registers the hashes of the OCSP certificate IDs.
+	_ "crypto/sha1"
+	_ "crypto/sha256"
+)
+
+// OCSPStatus is the revocation status of a certificate, as reported by an
+// OCSP responder.
+type OCSPStatus int
+
+const (
+	// OCSPGood means that the certificate isn't revoked.
+	OCSPGood OCSPStatus = iota
+	// OCSPRevoked means that the certificate is revoked.
+	OCSPRevoked
+	// OCSPUnknown means that the responder doesn't know the certificate.
+	OCSPUnknown
+)
+
+// String implements fmt.Stringer.
+func (s OCSPStatus) String() string {
+	switch s {
+	case OCSPGood:
+		return "good"
+	case OCSPRevoked:
+		return "revoked"
+	case OCSPUnknown:
+		return "unknown"
+	}
+	return fmt.Sprintf("OCSPStatus(%d)", int(s))
+}
+
+// OCSPResponse is an OCSP response about a single certificate, whose
+// signature was verified.
+type OCSPResponse struct {
+	// Status is the revocation status of the certificate.
+	Status OCSPStatus
+	// SerialNumber is the serial number of the certificate.
+	SerialNumber *big.Int
+	// ProducedAt is when the response was signed.
+	ProducedAt time.Time
+	// ThisUpdate is when the status was known to be correct.
+	ThisUpdate time.Time
+	// NextUpdate is when newer information will be available about the
+	// status, or zero if it's always available.
+	NextUpdate time.Time
+	// RevokedAt is when the certificate was revoked, if it was.
+	RevokedAt time.Time
+	// Raw is the DER encoding of the response, e.g. to staple it.
+	Raw []byte
+}
+
+// Current returns whether the status reported by r is current at now.
+func (r *OCSPResponse) Current(now time.Time) bool {
+	return !now.Before(r.ThisUpdate) && (r.NextUpdate.IsZero() || now.Before(r.NextUpdate))
+}
+
+var (
+	// ErrCertificateRevoked is returned when the OCSP responder reports the
+	// certificate as revoked.
+	ErrCertificateRevoked = errors.New("the certificate is revoked")
+
+	// errMalformedOCSP is returned when an OCSP response can't be parsed.
+	errMalformedOCSP = errors.New("malformed OCSP response")
+)
+
+var (
+	oidOCSPBasic = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 48, 1, 1}
+	oidSHA1      = asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26}
+	oidSHA256    = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+)
+
+// certIDHashes are the hashes of the certificate IDs supported.
+var certIDHashes = []struct {
+	oid  asn1.ObjectIdentifier
+	hash crypto.Hash
+}{
+	{oidSHA1, crypto.SHA1},
+	{oidSHA256, crypto.SHA256},
+}
+
+// signatureAlgorithms maps the OIDs of the signature algorithms of the OCSP
+// responses to their x509 counterparts.
+var signatureAlgorithms = []struct {
+	oid  asn1.ObjectIdentifier
+	algo x509.SignatureAlgorithm
+}{
+	{asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 5}, x509.SHA1WithRSA},
+	{asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 11}, x509.SHA256WithRSA},
+	{asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 12}, x509.SHA384WithRSA},
+	{asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 13}, x509.SHA512WithRSA},
+	{asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}, x509.ECDSAWithSHA256},
+	{asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 3}, x509.ECDSAWithSHA384},
+	{asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 4}, x509.ECDSAWithSHA512},
+	{asn1.ObjectIdentifier{1, 3, 101, 112}, x509.PureEd25519},
+}
+
+// The ASN.1 structures of RFC 6960, restricted to what's needed to request
+// and check the status of a single certificate.
+
+type certID struct {
+	HashAlgorithm pkix.AlgorithmIdentifier
+	NameHash      []byte
+	IssuerKeyHash []byte
+	SerialNumber  *big.Int
+}
+
+type ocspRequest struct {
+	TBSRequest tbsRequest
+}
+
+type tbsRequest struct {
+	Version     int `asn1:"explicit,tag:0,default:0,optional"`
+	RequestList []request
+}
+
+type request struct {
+	Cert certID
+}
+
+type ocspResponse struct {
+	Status   asn1.Enumerated
+	Response responseBytes `asn1:"explicit,tag:0,optional"`
+}
+
+type responseBytes struct {
+	ResponseType asn1.ObjectIdentifier
+	Response     []byte
+}
+
+type basicResponse struct {
+	TBSResponseData    responseData
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Signature          asn1.BitString
+	Certificates       []asn1.RawValue `asn1:"explicit,tag:0,optional"`
+}
+
+type responseData struct {
+	Raw            asn1.RawContent
+	Version        int `asn1:"optional,default:0,explicit,tag:0"`
+	RawResponderID asn1.RawValue
+	ProducedAt     time.Time `asn1:"generalized"`
+	Responses      []singleResponse
+}
+
+type singleResponse struct {
+	CertID     certID
+	Good       asn1.Flag   `asn1:"tag:0,optional"`
+	Revoked    revokedInfo `asn1:"tag:1,optional"`
+	Unknown    asn1.Flag   `asn1:"tag:2,optional"`
+	ThisUpdate time.Time   `asn1:"generalized"`
+	NextUpdate time.Time   `asn1:"generalized,explicit,tag:0,optional"`
+}
+
+type revokedInfo struct {
+	RevocationTime time.Time       `asn1:"generalized"`
+	Reason         asn1.Enumerated `asn1:"explicit,tag:0,optional"`
+}
+
+// newCertID returns the ID of cert, issued by issuer, hashing the name and
+// the key of the issuer with hash.
+func newCertID(cert, issuer *x509.Certificate, oid asn1.ObjectIdentifier, hash crypto.Hash) (certID, error) {
+	var spki struct {
+		Algorithm pkix.AlgorithmIdentifier
+		PublicKey asn1.BitString
+	}
+	if _, err := asn1.Unmarshal(issuer.RawSubjectPublicKeyInfo, &spki); err != nil {
+		return certID{}, fmt.Errorf("failed to parse the public key of the issuer: %w", err)
+	}
+	h := hash.New()
+	h.Write(issuer.RawSubject)
+	nameHash := h.Sum(nil)
+	h.Reset()
+	h.Write(spki.PublicKey.RightAlign())
+
+	return certID{
+		HashAlgorithm: pkix.AlgorithmIdentifier{Algorithm: oid, Parameters: asn1.NullRawValue},
+		NameHash:      nameHash,
+		IssuerKeyHash: h.Sum(nil),
+		SerialNumber:  cert.SerialNumber,
+	}, nil
+}
+
+// CreateOCSPRequest returns the DER encoding of a request of the status of
+// cert, issued by issuer, to be sent to an OCSP responder.
+func CreateOCSPRequest(cert, issuer *x509.Certificate) ([]byte, error) {
+	id, err := newCertID(cert, issuer, oidSHA1, crypto.SHA1)
+	if err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(ocspRequest{
+		TBSRequest: tbsRequest{RequestList: []request{{Cert: id}}},
+	})
+}
+
+// ParseOCSPResponse parses the DER encoded OCSP response der about cert,
+// issued by issuer, and verifies its signature, which must be made either
+// by the issuer or by a responder the issuer delegated the signature of
+// the OCSP responses to.
+// Whether the response is current is left to the caller to check.
+func ParseOCSPResponse(der []byte, cert, issuer *x509.Certificate) (*OCSPResponse, error) {
+	var resp ocspResponse
+	if rest, err := asn1.Unmarshal(der, &resp); err != nil {
+		return nil, fmt.Errorf("%w: %v", errMalformedOCSP, err)
+	} else if len(rest) > 0 {
+		return nil, fmt.Errorf("%w: trailing data", errMalformedOCSP)
+	}
+	if resp.Status != 0 {
+		return nil, fmt.Errorf("the OCSP responder returned the error status %d", resp.Status)
+	}
+	if !resp.Response.ResponseType.Equal(oidOCSPBasic) {
+		return nil, fmt.Errorf("unsupported OCSP response type %v", resp.Response.ResponseType)
+	}
+
+	var basic basicResponse
+	if rest, err := asn1.Unmarshal(resp.Response.Response, &basic); err != nil {
+		return nil, fmt.Errorf("%w: %v", errMalformedOCSP, err)
+	} else if len(rest) > 0 {
+		return nil, fmt.Errorf("%w: trailing data", errMalformedOCSP)
+	}
+	if err := checkOCSPSignature(&basic, issuer); err != nil {
+		return nil, err
+	}
+
+	single, err := findSingleResponse(basic.TBSResponseData.Responses, cert, issuer)
+	if err != nil {
+		return nil, err
+	}
+	r := &OCSPResponse{
+		SerialNumber: single.CertID.SerialNumber,
+		ProducedAt:   basic.TBSResponseData.ProducedAt,
+		ThisUpdate:   single.ThisUpdate,
+		NextUpdate:   single.NextUpdate,
+		Raw:          der,
+	}
+	switch {
+	case bool(single.Good):
+		r.Status = OCSPGood
+	case bool(single.Unknown):
+		r.Status = OCSPUnknown
+	default:
+		r.Status = OCSPRevoked
+		r.RevokedAt = single.Revoked.RevocationTime
+	}
+	return r, nil
+}
+
+// checkOCSPSignature verifies the signature of the response, made either by
+// issuer or by the first certificate of the response, which must then be
+// signed by issuer and allowed to sign OCSP responses.
+func checkOCSPSignature(basic *basicResponse, issuer *x509.Certificate) error {
+	algo := x509.UnknownSignatureAlgorithm
+	for _, sa := range signatureAlgorithms {
+		if sa.oid.Equal(basic.SignatureAlgorithm.Algorithm) {
+			algo = sa.algo
+			break
+		}
+	}
+	if algo == x509.UnknownSignatureAlgorithm {
+		return fmt.Errorf("unsupported OCSP signature algorithm %v", basic.SignatureAlgorithm.Algorithm)
+	}
+
+	signer := issuer
+	if len(basic.Certificates) > 0 {
+		responder, err := x509.ParseCertificate(basic.Certificates[0].FullBytes)
+		if err != nil {
+			return fmt.Errorf("failed to parse the certificate of the OCSP responder: %w", err)
+		}
+		if !bytes.Equal(responder.Raw, issuer.Raw) {
+			if !hasExtKeyUsage(responder, x509.ExtKeyUsageOCSPSigning) {
+				return errors.New("the certificate of the OCSP responder isn't allowed to sign OCSP responses")
+			}
+			if err := responder.CheckSignatureFrom(issuer); err != nil {
+				return fmt.Errorf("the certificate of the OCSP responder isn't signed by the issuer: %w", err)
+			}
+			signer = responder
+		}
+	}
+	if err := signer.CheckSignature(algo, basic.TBSResponseData.Raw, basic.Signature.RightAlign()); err != nil {
+		return fmt.Errorf("invalid signature of the OCSP response: %w", err)
+	}
+	return nil
+}
+
+// findSingleResponse returns the response about cert, issued by issuer.
+func findSingleResponse(responses []singleResponse, cert, issuer *x509.Certificate) (*singleResponse, error) {
+	for i := range responses {
+		r := &responses[i]
+		if r.CertID.SerialNumber == nil || r.CertID.SerialNumber.Cmp(cert.SerialNumber) != 0 {
+			continue
+		}
+		for _, h := range certIDHashes {
+			if !h.oid.Equal(r.CertID.HashAlgorithm.Algorithm) {
+				continue
+			}
+			id, err := newCertID(cert, issuer, h.oid, h.hash)
+			if err != nil {
+				return nil, err
+			}
+			if bytes.Equal(id.NameHash, r.CertID.NameHash) && bytes.Equal(id.IssuerKeyHash, r.CertID.IssuerKeyHash) {
+				return r, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("the OCSP response doesn't include the certificate with serial number %v", cert.SerialNumber)
+}
+
+func hasExtKeyUsage(cert *x509.Certificate, usage x509.ExtKeyUsage) bool {
+	for _, u := range cert.ExtKeyUsage {
+		if u == usage {
+			return true
+		}
+	}
+	return false
+}
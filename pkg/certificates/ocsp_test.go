@@ -0,0 +1,355 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package certificates
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+)
+
+// testSigner is a certificate along with its private key.
+type testSigner struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+// newTestCert creates a certificate from template, signed by parent, or
+// self-signed if parent is nil.
+func newTestCert(t *testing.T, template *x509.Certificate, parent *testSigner) *testSigner {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal("GenerateKey() =", err)
+	}
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(time.Hour)
+	parentCert, parentKey := template, key
+	if parent != nil {
+		parentCert, parentKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parentCert, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal("CreateCertificate() =", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal("ParseCertificate() =", err)
+	}
+	return &testSigner{cert: cert, key: key}
+}
+
+// testPKI is a CA along with a serving certificate it issued and a
+// responder it delegated the signature of the OCSP responses to.
+type testPKI struct {
+	ca, leaf, responder *testSigner
+}
+
+func newTestPKI(t *testing.T, ocspServer string) *testPKI {
+	ca := newTestCert(t, &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "ca"},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, nil)
+	return &testPKI{
+		ca: ca,
+		leaf: newTestCert(t, &x509.Certificate{
+			SerialNumber: big.NewInt(42),
+			Subject:      pkix.Name{CommonName: "example.com"},
+			DNSNames:     []string{"example.com"},
+			ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+			OCSPServer:   []string{ocspServer},
+		}, ca),
+		responder: newTestCert(t, &x509.Certificate{
+			SerialNumber: big.NewInt(2),
+			Subject:      pkix.Name{CommonName: "responder"},
+			ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageOCSPSigning},
+		}, ca),
+	}
+}
+
+// testResponse describes an OCSP response to create.
+type testResponse struct {
+	status     OCSPStatus
+	serial     *big.Int
+	thisUpdate time.Time
+	nextUpdate time.Time
+	revokedAt  time.Time
+	// signer signs the response, and is included in it unless it's the CA.
+	signer *testSigner
+	hash   crypto.Hash
+}
+
+// create returns the DER encoding of the response about the leaf of pki.
+func (r testResponse) create(t *testing.T, pki *testPKI) []byte {
+	t.Helper()
+	oid, hash := oidSHA1, crypto.SHA1
+	if r.hash == crypto.SHA256 {
+		oid, hash = oidSHA256, crypto.SHA256
+	}
+	id, err := newCertID(pki.leaf.cert, pki.ca.cert, oid, hash)
+	if err != nil {
+		t.Fatal("newCertID() =", err)
+	}
+	if r.serial != nil {
+		id.SerialNumber = r.serial
+	}
+	single := singleResponse{CertID: id, ThisUpdate: r.thisUpdate, NextUpdate: r.nextUpdate}
+	switch r.status {
+	case OCSPGood:
+		single.Good = true
+	case OCSPUnknown:
+		single.Unknown = true
+	case OCSPRevoked:
+		single.Revoked.RevocationTime = r.revokedAt
+	}
+
+	signer := r.signer
+	if signer == nil {
+		signer = pki.ca
+	}
+	keyHash := sha256.Sum256(signer.cert.RawSubjectPublicKeyInfo)
+	keyHashDER, err := asn1.Marshal(keyHash[:])
+	if err != nil {
+		t.Fatal("Marshal() =", err)
+	}
+	tbs, err := asn1.Marshal(responseData{
+		RawResponderID: asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 2, IsCompound: true, Bytes: keyHashDER},
+		ProducedAt:     time.Now().UTC().Truncate(time.Second),
+		Responses:      []singleResponse{single},
+	})
+	if err != nil {
+		t.Fatal("Marshal() =", err)
+	}
+	digest := sha256.Sum256(tbs)
+	sig, err := ecdsa.SignASN1(rand.Reader, signer.key, digest[:])
+	if err != nil {
+		t.Fatal("SignASN1() =", err)
+	}
+	basic := basicResponse{
+		TBSResponseData:    responseData{Raw: tbs},
+		SignatureAlgorithm: pkix.AlgorithmIdentifier{Algorithm: asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}},
+		Signature:          asn1.BitString{Bytes: sig, BitLength: 8 * len(sig)},
+	}
+	if signer != pki.ca {
+		basic.Certificates = []asn1.RawValue{{FullBytes: signer.cert.Raw}}
+	}
+	basicDER, err := asn1.Marshal(basic)
+	if err != nil {
+		t.Fatal("Marshal() =", err)
+	}
+	der, err := asn1.Marshal(ocspResponse{
+		Response: responseBytes{ResponseType: oidOCSPBasic, Response: basicDER},
+	})
+	if err != nil {
+		t.Fatal("Marshal() =", err)
+	}
+	return der
+}
+
+func TestCreateOCSPRequest(t *testing.T) {
+	pki := newTestPKI(t, "http://ocsp.example.com")
+
+	der, err := CreateOCSPRequest(pki.leaf.cert, pki.ca.cert)
+	if err != nil {
+		t.Fatal("CreateOCSPRequest() =", err)
+	}
+	var req ocspRequest
+	if _, err := asn1.Unmarshal(der, &req); err != nil {
+		t.Fatal("Unmarshal() =", err)
+	}
+	if got, want := len(req.TBSRequest.RequestList), 1; got != want {
+		t.Fatalf("len(RequestList) = %d, want: %d", got, want)
+	}
+	id := req.TBSRequest.RequestList[0].Cert
+	if got, want := id.SerialNumber, pki.leaf.cert.SerialNumber; got.Cmp(want) != 0 {
+		t.Errorf("SerialNumber = %v, want: %v", got, want)
+	}
+	if !id.HashAlgorithm.Algorithm.Equal(oidSHA1) {
+		t.Errorf("HashAlgorithm = %v, want: %v", id.HashAlgorithm.Algorithm, oidSHA1)
+	}
+	if got, want := len(id.NameHash), crypto.SHA1.Size(); got != want {
+		t.Errorf("len(NameHash) = %d, want: %d", got, want)
+	}
+}
+
+func TestParseOCSPResponse(t *testing.T) {
+	pki := newTestPKI(t, "http://ocsp.example.com")
+	now := time.Now().UTC().Truncate(time.Second)
+	thisUpdate, nextUpdate := now.Add(-time.Minute), now.Add(time.Hour)
+	other := newTestCert(t, &x509.Certificate{SerialNumber: big.NewInt(3), Subject: pkix.Name{CommonName: "other"}}, nil)
+	noEKU := newTestCert(t, &x509.Certificate{SerialNumber: big.NewInt(4), Subject: pkix.Name{CommonName: "no-eku"}}, pki.ca)
+
+	tests := []struct {
+		name       string
+		der        func() []byte
+		wantStatus OCSPStatus
+		wantErr    bool
+	}{{
+		name: "good",
+		der: func() []byte {
+			return testResponse{status: OCSPGood, thisUpdate: thisUpdate, nextUpdate: nextUpdate}.create(t, pki)
+		},
+		wantStatus: OCSPGood,
+	}, {
+		name: "revoked",
+		der: func() []byte {
+			return testResponse{status: OCSPRevoked, thisUpdate: thisUpdate, nextUpdate: nextUpdate, revokedAt: thisUpdate}.create(t, pki)
+		},
+		wantStatus: OCSPRevoked,
+	}, {
+		name: "unknown",
+		der: func() []byte {
+			return testResponse{status: OCSPUnknown, thisUpdate: thisUpdate}.create(t, pki)
+		},
+		wantStatus: OCSPUnknown,
+	}, {
+		name: "SHA-256 certificate ID",
+		der: func() []byte {
+			return testResponse{status: OCSPGood, thisUpdate: thisUpdate, hash: crypto.SHA256}.create(t, pki)
+		},
+		wantStatus: OCSPGood,
+	}, {
+		name: "delegated responder",
+		der: func() []byte {
+			return testResponse{status: OCSPGood, thisUpdate: thisUpdate, signer: pki.responder}.create(t, pki)
+		},
+		wantStatus: OCSPGood,
+	}, {
+		name: "responder not allowed to sign OCSP responses",
+		der: func() []byte {
+			return testResponse{status: OCSPGood, thisUpdate: thisUpdate, signer: noEKU}.create(t, pki)
+		},
+		wantErr: true,
+	}, {
+		name: "responder not signed by the issuer",
+		der: func() []byte {
+			return testResponse{status: OCSPGood, thisUpdate: thisUpdate, signer: other}.create(t, pki)
+		},
+		wantErr: true,
+	}, {
+		name: "other certificate",
+		der: func() []byte {
+			return testResponse{status: OCSPGood, thisUpdate: thisUpdate, serial: big.NewInt(43)}.create(t, pki)
+		},
+		wantErr: true,
+	}, {
+		name: "error status",
+		der: func() []byte {
+			der, _ := asn1.Marshal(ocspResponse{Status: 3})
+			return der
+		},
+		wantErr: true,
+	}, {
+		name:    "garbage",
+		der:     func() []byte { return []byte("garbage") },
+		wantErr: true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			der := test.der()
+			resp, err := ParseOCSPResponse(der, pki.leaf.cert, pki.ca.cert)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("ParseOCSPResponse() = %v, wantErr: %v", err, test.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if resp.Status != test.wantStatus {
+				t.Errorf("Status = %v, want: %v", resp.Status, test.wantStatus)
+			}
+			if !resp.ThisUpdate.Equal(thisUpdate) {
+				t.Errorf("ThisUpdate = %v, want: %v", resp.ThisUpdate, thisUpdate)
+			}
+			if resp.Status == OCSPRevoked && !resp.RevokedAt.Equal(thisUpdate) {
+				t.Errorf("RevokedAt = %v, want: %v", resp.RevokedAt, thisUpdate)
+			}
+			if got, want := resp.SerialNumber, pki.leaf.cert.SerialNumber; got.Cmp(want) != 0 {
+				t.Errorf("SerialNumber = %v, want: %v", got, want)
+			}
+		})
+	}
+}
+
+func TestParseOCSPResponseBadSignature(t *testing.T) {
+	pki := newTestPKI(t, "http://ocsp.example.com")
+	now := time.Now().UTC().Truncate(time.Second)
+	der := testResponse{status: OCSPRevoked, thisUpdate: now, revokedAt: now}.create(t, pki)
+
+	var resp ocspResponse
+	if _, err := asn1.Unmarshal(der, &resp); err != nil {
+		t.Fatal("Unmarshal() =", err)
+	}
+	var basic basicResponse
+	if _, err := asn1.Unmarshal(resp.Response.Response, &basic); err != nil {
+		t.Fatal("Unmarshal() =", err)
+	}
+	basic.Signature.Bytes[len(basic.Signature.Bytes)-1] ^= 1
+	resp.Response.Response, _ = asn1.Marshal(basic)
+	der, _ = asn1.Marshal(resp)
+
+	_, err := ParseOCSPResponse(der, pki.leaf.cert, pki.ca.cert)
+	if err == nil {
+		t.Fatal("ParseOCSPResponse() = nil, want an error")
+	}
+	if errors.Is(err, ErrCertificateRevoked) {
+		t.Error("ParseOCSPResponse() =", err, "want an error other than ErrCertificateRevoked")
+	}
+}
+
+func TestOCSPResponseCurrent(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name string
+		resp OCSPResponse
+		want bool
+	}{{
+		name: "current",
+		resp: OCSPResponse{ThisUpdate: now.Add(-time.Minute), NextUpdate: now.Add(time.Minute)},
+		want: true,
+	}, {
+		name: "no next update",
+		resp: OCSPResponse{ThisUpdate: now.Add(-time.Minute)},
+		want: true,
+	}, {
+		name: "expired",
+		resp: OCSPResponse{ThisUpdate: now.Add(-time.Hour), NextUpdate: now.Add(-time.Minute)},
+	}, {
+		name: "not yet valid",
+		resp: OCSPResponse{ThisUpdate: now.Add(time.Minute)},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.resp.Current(now); got != test.want {
+				t.Errorf("Current() = %v, want: %v", got, test.want)
+			}
+		})
+	}
+}
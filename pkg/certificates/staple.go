@@ -0,0 +1,179 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package certificates
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"knative.dev/networking/pkg/config"
+)
+
+// maxOCSPResponseSize caps the size of the OCSP responses read from the
+// responders.
+const maxOCSPResponseSize = 1 << 20
+
+var (
+	// errNoOCSPServer is returned when a certificate doesn't list any OCSP
+	// responder.
+	errNoOCSPServer = errors.New("the certificate doesn't list any OCSP responder")
+
+	// errNoIssuer is returned when a certificate chain doesn't include the
+	// issuer of its leaf.
+	errNoIssuer = errors.New("the certificate chain doesn't include the issuer of the leaf")
+
+	// errNoOCSPStaple is returned when a peer doesn't staple an OCSP response.
+	errNoOCSPStaple = errors.New("the peer didn't staple an OCSP response")
+)
+
+// FetchOCSPResponse requests the status of cert, issued by issuer, from the
+// first OCSP responder listed in cert.
+func FetchOCSPResponse(ctx context.Context, client *http.Client, cert, issuer *x509.Certificate) (*OCSPResponse, error) {
+	if len(cert.OCSPServer) == 0 {
+		return nil, errNoOCSPServer
+	}
+	body, err := CreateOCSPRequest(cert, issuer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the OCSP request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, cert.OCSPServer[0], bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/ocsp-request")
+	req.Header.Set("Accept", "application/ocsp-response")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach the OCSP responder: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("the OCSP responder %s returned status %d", cert.OCSPServer[0], resp.StatusCode)
+	}
+	der, err := io.ReadAll(io.LimitReader(resp.Body, maxOCSPResponseSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the OCSP response: %w", err)
+	}
+	if len(der) > maxOCSPResponseSize {
+		return nil, fmt.Errorf("the OCSP response is larger than %d bytes", maxOCSPResponseSize)
+	}
+	return ParseOCSPResponse(der, cert, issuer)
+}
+
+// StapleOCSP fetches the OCSP response of the leaf of cert and staples it
+// to cert, so that it's sent to the clients during the TLS handshakes.
+// The chain of cert must include the issuer of the leaf. Only the current
+// responses reporting the leaf as good are stapled, the returned response
+// tells when to staple a fresh one.
+//
+// cert is modified in place, so it must not be in use by a tls.Config, e.g.
+// the caller staples a copy and swaps it in from GetCertificate.
+func StapleOCSP(ctx context.Context, client *http.Client, cert *tls.Certificate) (*OCSPResponse, error) {
+	leaf, issuer, err := leafAndIssuer(cert)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := FetchOCSPResponse(ctx, client, leaf, issuer)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case resp.Status == OCSPRevoked:
+		return resp, ErrCertificateRevoked
+	case resp.Status != OCSPGood:
+		return resp, fmt.Errorf("the OCSP responder reported the certificate status as %v", resp.Status)
+	case !resp.Current(time.Now()):
+		return resp, errors.New("the OCSP response isn't current")
+	}
+	cert.OCSPStaple = resp.Raw
+	return resp, nil
+}
+
+// leafAndIssuer parses the leaf of cert and its issuer.
+func leafAndIssuer(cert *tls.Certificate) (*x509.Certificate, *x509.Certificate, error) {
+	if len(cert.Certificate) < 2 {
+		return nil, nil, errNoIssuer
+	}
+	leaf := cert.Leaf
+	if leaf == nil {
+		var err error
+		if leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+			return nil, nil, fmt.Errorf("failed to parse the leaf certificate: %w", err)
+		}
+	}
+	issuer, err := x509.ParseCertificate(cert.Certificate[1])
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse the issuer certificate: %w", err)
+	}
+	return leaf, issuer, nil
+}
+
+// VerifyOCSPStaple returns a function, to be set as the VerifyConnection of
+// a tls.Config, checking the OCSP response stapled by the peer as specified
+// by check. It returns nil if check is config.OCSPRevocationCheckDisabled.
+//
+// The peers whose certificate is reported as revoked are always rejected.
+// In hard-fail mode, so are the ones which don't staple a valid and current
+// OCSP response reporting their certificate as good.
+func VerifyOCSPStaple(check config.OCSPRevocationCheck) func(tls.ConnectionState) error {
+	if check == config.OCSPRevocationCheckDisabled || check == "" {
+		return nil
+	}
+	hardFail := check == config.OCSPRevocationCheckHardFail
+	return func(cs tls.ConnectionState) error {
+		chain := cs.PeerCertificates
+		if len(cs.VerifiedChains) > 0 {
+			chain = cs.VerifiedChains[0]
+		}
+		err := checkOCSPStaple(cs.OCSPResponse, chain, time.Now())
+		if err == nil || (!hardFail && !errors.Is(err, ErrCertificateRevoked)) {
+			return nil
+		}
+		return err
+	}
+}
+
+// checkOCSPStaple checks the OCSP response staple about the leaf of chain.
+func checkOCSPStaple(staple []byte, chain []*x509.Certificate, now time.Time) error {
+	if len(chain) < 2 {
+		return errNoIssuer
+	}
+	if len(staple) == 0 {
+		return errNoOCSPStaple
+	}
+	resp, err := ParseOCSPResponse(staple, chain[0], chain[1])
+	if err != nil {
+		return err
+	}
+	switch {
+	case resp.Status == OCSPRevoked:
+		return fmt.Errorf("%w: revoked at %v", ErrCertificateRevoked, resp.RevokedAt)
+	case resp.Status != OCSPGood:
+		return fmt.Errorf("the stapled OCSP response reports the certificate status as %v", resp.Status)
+	case !resp.Current(now):
+		return errors.New("the stapled OCSP response isn't current")
+	}
+	return nil
+}
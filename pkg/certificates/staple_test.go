@@ -0,0 +1,190 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package certificates
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"knative.dev/networking/pkg/config"
+)
+
+// newTestResponder returns an OCSP responder answering with the response
+// returned by respond, and the PKI whose leaf lists it.
+func newTestResponder(t *testing.T, respond func(*testPKI) []byte) (*httptest.Server, *testPKI) {
+	var pki *testPKI
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/ocsp-request" || len(body) == 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/ocsp-response")
+		w.Write(respond(pki))
+	}))
+	t.Cleanup(ts.Close)
+	pki = newTestPKI(t, ts.URL)
+	return ts, pki
+}
+
+func TestStapleOCSP(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+
+	tests := []struct {
+		name        string
+		resp        testResponse
+		wantStapled bool
+		wantRevoked bool
+	}{{
+		name:        "good",
+		resp:        testResponse{status: OCSPGood, thisUpdate: now.Add(-time.Minute), nextUpdate: now.Add(time.Hour)},
+		wantStapled: true,
+	}, {
+		name:        "revoked",
+		resp:        testResponse{status: OCSPRevoked, thisUpdate: now.Add(-time.Minute), revokedAt: now.Add(-time.Minute)},
+		wantRevoked: true,
+	}, {
+		name: "unknown",
+		resp: testResponse{status: OCSPUnknown, thisUpdate: now.Add(-time.Minute)},
+	}, {
+		name: "expired",
+		resp: testResponse{status: OCSPGood, thisUpdate: now.Add(-time.Hour), nextUpdate: now.Add(-time.Minute)},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ts, pki := newTestResponder(t, func(pki *testPKI) []byte { return test.resp.create(t, pki) })
+			cert := &tls.Certificate{
+				Certificate: [][]byte{pki.leaf.cert.Raw, pki.ca.cert.Raw},
+				PrivateKey:  pki.leaf.key,
+			}
+
+			resp, err := StapleOCSP(context.Background(), ts.Client(), cert)
+			if (err == nil) != test.wantStapled {
+				t.Errorf("StapleOCSP() = %v, want stapled: %v", err, test.wantStapled)
+			}
+			if got := errors.Is(err, ErrCertificateRevoked); got != test.wantRevoked {
+				t.Errorf("StapleOCSP() = %v, want revoked: %v", err, test.wantRevoked)
+			}
+			if resp == nil {
+				t.Fatal("StapleOCSP() returned no response")
+			}
+			if test.wantStapled != bytes.Equal(cert.OCSPStaple, resp.Raw) {
+				t.Errorf("OCSPStaple = %x, want stapled: %v", cert.OCSPStaple, test.wantStapled)
+			}
+		})
+	}
+}
+
+func TestStapleOCSPErrors(t *testing.T) {
+	ts, pki := newTestResponder(t, func(*testPKI) []byte { return []byte("garbage") })
+
+	tests := []struct {
+		name string
+		cert *tls.Certificate
+	}{{
+		name: "no issuer",
+		cert: &tls.Certificate{Certificate: [][]byte{pki.leaf.cert.Raw}},
+	}, {
+		name: "no OCSP responder",
+		cert: &tls.Certificate{Certificate: [][]byte{pki.ca.cert.Raw, pki.ca.cert.Raw}},
+	}, {
+		name: "malformed response",
+		cert: &tls.Certificate{Certificate: [][]byte{pki.leaf.cert.Raw, pki.ca.cert.Raw}},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := StapleOCSP(context.Background(), ts.Client(), test.cert); err == nil {
+				t.Error("StapleOCSP() = nil, want an error")
+			}
+			if test.cert.OCSPStaple != nil {
+				t.Errorf("OCSPStaple = %x, want none", test.cert.OCSPStaple)
+			}
+		})
+	}
+}
+
+func TestVerifyOCSPStaple(t *testing.T) {
+	pki := newTestPKI(t, "http://ocsp.example.com")
+	now := time.Now().UTC().Truncate(time.Second)
+	chain := []*x509.Certificate{pki.leaf.cert, pki.ca.cert}
+
+	good := testResponse{status: OCSPGood, thisUpdate: now.Add(-time.Minute), nextUpdate: now.Add(time.Hour)}.create(t, pki)
+	revoked := testResponse{status: OCSPRevoked, thisUpdate: now.Add(-time.Minute), revokedAt: now.Add(-time.Minute)}.create(t, pki)
+	expired := testResponse{status: OCSPGood, thisUpdate: now.Add(-time.Hour), nextUpdate: now.Add(-time.Minute)}.create(t, pki)
+
+	tests := []struct {
+		name         string
+		cs           tls.ConnectionState
+		wantSoftFail bool
+		wantHardFail bool
+	}{{
+		name: "good",
+		cs:   tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{chain}, OCSPResponse: good},
+	}, {
+		name:         "revoked",
+		cs:           tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{chain}, OCSPResponse: revoked},
+		wantSoftFail: true,
+		wantHardFail: true,
+	}, {
+		name:         "no staple",
+		cs:           tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{chain}},
+		wantHardFail: true,
+	}, {
+		name:         "expired",
+		cs:           tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{chain}, OCSPResponse: expired},
+		wantHardFail: true,
+	}, {
+		name:         "malformed staple",
+		cs:           tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{chain}, OCSPResponse: []byte("garbage")},
+		wantHardFail: true,
+	}, {
+		name:         "no issuer",
+		cs:           tls.ConnectionState{PeerCertificates: chain[:1], OCSPResponse: good},
+		wantHardFail: true,
+	}, {
+		name:         "unverified chain",
+		cs:           tls.ConnectionState{PeerCertificates: chain, OCSPResponse: revoked},
+		wantSoftFail: true,
+		wantHardFail: true,
+	}}
+
+	if VerifyOCSPStaple(config.OCSPRevocationCheckDisabled) != nil {
+		t.Error("VerifyOCSPStaple(disabled) != nil")
+	}
+	softFail := VerifyOCSPStaple(config.OCSPRevocationCheckSoftFail)
+	hardFail := VerifyOCSPStaple(config.OCSPRevocationCheckHardFail)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := softFail(test.cs); (err != nil) != test.wantSoftFail {
+				t.Errorf("soft-fail: VerifyConnection() = %v, want error: %v", err, test.wantSoftFail)
+			}
+			if err := hardFail(test.cs); (err != nil) != test.wantHardFail {
+				t.Errorf("hard-fail: VerifyConnection() = %v, want error: %v", err, test.wantHardFail)
+			}
+		})
+	}
+}
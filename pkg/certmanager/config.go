@@ -0,0 +1,74 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package certmanager
+
+import (
+	"errors"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// ConfigName is the name of the configmap configuring the cert-manager
+	// Certificates.
+	ConfigName = "config-certmanager"
+
+	// IssuerRefKey is the key of the configmap holding the reference to the
+	// issuer of the cert-manager Certificates, e.g.
+	//
+	//	issuerRef: |
+	//	  kind: ClusterIssuer
+	//	  name: letsencrypt-issuer
+	IssuerRefKey = "issuerRef"
+)
+
+// IssuerRef references the cert-manager Issuer or ClusterIssuer issuing the
+// certificates.
+type IssuerRef struct {
+	// Name is the name of the issuer.
+	Name string `json:"name"`
+
+	// Kind is the kind of the issuer, Issuer if empty.
+	Kind string `json:"kind,omitempty"`
+
+	// Group is the API group of the issuer, cert-manager.io if empty.
+	Group string `json:"group,omitempty"`
+}
+
+// Config contains the cert-manager configuration of the Certificates.
+type Config struct {
+	// IssuerRef is the issuer of the certificates.
+	IssuerRef *IssuerRef
+}
+
+// NewConfigFromConfigMap creates a Config from the supplied ConfigMap.
+func NewConfigFromConfigMap(configMap *corev1.ConfigMap) (*Config, error) {
+	v, ok := configMap.Data[IssuerRefKey]
+	if !ok {
+		return nil, errors.New("issuerRef is required")
+	}
+	ref := &IssuerRef{}
+	if err := yaml.Unmarshal([]byte(v), ref); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", IssuerRefKey, err)
+	}
+	if ref.Name == "" {
+		return nil, fmt.Errorf("%s.name is required", IssuerRefKey)
+	}
+	return &Config{IssuerRef: ref}, nil
+}
@@ -0,0 +1,77 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package certmanager
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestNewConfigFromConfigMap(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    map[string]string
+		want    *Config
+		wantErr bool
+	}{{
+		name:    "missing issuerRef",
+		data:    map[string]string{},
+		wantErr: true,
+	}, {
+		name: "issuer",
+		data: map[string]string{
+			IssuerRefKey: "name: letsencrypt",
+		},
+		want: &Config{IssuerRef: &IssuerRef{Name: "letsencrypt"}},
+	}, {
+		name: "cluster issuer",
+		data: map[string]string{
+			IssuerRefKey: "kind: ClusterIssuer\nname: letsencrypt\ngroup: cert-manager.io",
+		},
+		want: &Config{IssuerRef: &IssuerRef{Name: "letsencrypt", Kind: "ClusterIssuer", Group: "cert-manager.io"}},
+	}, {
+		name: "missing name",
+		data: map[string]string{
+			IssuerRefKey: "kind: ClusterIssuer",
+		},
+		wantErr: true,
+	}, {
+		name: "invalid YAML",
+		data: map[string]string{
+			IssuerRefKey: "name: [letsencrypt",
+		},
+		wantErr: true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := NewConfigFromConfigMap(&corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: ConfigName},
+				Data:       test.data,
+			})
+			if (err != nil) != test.wantErr {
+				t.Fatalf("NewConfigFromConfigMap() = %v, want error: %v", err, test.wantErr)
+			}
+			if !cmp.Equal(got, test.want) {
+				t.Error("NewConfigFromConfigMap (-want, +got) =", cmp.Diff(test.want, got))
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package certmanager implements the Knative Certificates of the
+// cert-manager class with cert-manager Certificates, so that the Knative
+// networking implementations can support them by running the controller
+// returned by NewController.
+package certmanager
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+	"knative.dev/networking/pkg/apis/networking"
+	"knative.dev/networking/pkg/apis/networking/v1alpha1"
+	certinformer "knative.dev/networking/pkg/client/injection/informers/networking/v1alpha1/certificate"
+	certreconciler "knative.dev/networking/pkg/client/injection/reconciler/networking/v1alpha1/certificate"
+	"knative.dev/networking/pkg/config"
+	"knative.dev/pkg/configmap"
+	"knative.dev/pkg/controller"
+	"knative.dev/pkg/injection/clients/dynamicclient"
+	"knative.dev/pkg/logging"
+	pkgreconciler "knative.dev/pkg/reconciler"
+)
+
+// NewController creates the controller reconciling the Knative Certificates
+// of the cert-manager class, configured by the config-certmanager ConfigMap.
+// It watches the cert-manager Certificates with its own informer, started
+// here, as cert-manager has no injection informers.
+func NewController(ctx context.Context, cmw configmap.Watcher) *controller.Impl {
+	logger := logging.FromContext(ctx)
+	certInformer := certinformer.Get(ctx)
+	client := dynamicclient.Get(ctx)
+
+	cmInformer := cache.NewSharedIndexInformer(&cache.ListWatch{
+		ListFunc: func(opts metav1.ListOptions) (runtime.Object, error) {
+			return client.Resource(CertificateGVR).Namespace(metav1.NamespaceAll).List(ctx, opts)
+		},
+		WatchFunc: func(opts metav1.ListOptions) (watch.Interface, error) {
+			return client.Resource(CertificateGVR).Namespace(metav1.NamespaceAll).Watch(ctx, opts)
+		},
+	}, &unstructured.Unstructured{}, controller.GetResyncPeriod(ctx),
+		cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+
+	r := &Reconciler{
+		client: client,
+		lister: cache.NewGenericLister(cmInformer.GetIndexer(), CertificateGVR.GroupResource()),
+		now:    time.Now,
+	}
+	impl := certreconciler.NewImpl(ctx, r, config.CertManagerCertificateClassName)
+
+	classFilter := pkgreconciler.AnnotationFilterFunc(networking.CertificateClassAnnotationKey,
+		config.CertManagerCertificateClassName, false /*allowUnset*/)
+	certInformer.Informer().AddEventHandler(cache.FilteringResourceEventHandler{
+		FilterFunc: classFilter,
+		Handler:    controller.HandleAll(impl.Enqueue),
+	})
+	cmInformer.AddEventHandler(cache.FilteringResourceEventHandler{
+		FilterFunc: controller.FilterController(&v1alpha1.Certificate{}),
+		Handler:    controller.HandleAll(impl.EnqueueControllerOf),
+	})
+
+	cmw.Watch(ConfigName, func(cm *corev1.ConfigMap) {
+		cfg, err := NewConfigFromConfigMap(cm)
+		if err != nil {
+			logger.Errorw("Failed to parse the cert-manager config", zap.Error(err))
+			return
+		}
+		r.config.Store(cfg)
+		impl.FilteredGlobalResync(classFilter, certInformer.Informer())
+	})
+
+	go cmInformer.Run(ctx.Done())
+	if !cache.WaitForCacheSync(ctx.Done(), cmInformer.HasSynced) {
+		logger.Error("Failed to sync the cert-manager Certificates informer")
+	}
+	return impl
+}
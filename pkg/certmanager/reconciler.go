@@ -0,0 +1,115 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package certmanager
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrs "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/tools/cache"
+	"knative.dev/networking/pkg/apis/networking/v1alpha1"
+	certreconciler "knative.dev/networking/pkg/client/injection/reconciler/networking/v1alpha1/certificate"
+	"knative.dev/pkg/kmap"
+	pkgreconciler "knative.dev/pkg/reconciler"
+)
+
+// Reconciler implements the Knative Certificates with cert-manager
+// Certificates.
+type Reconciler struct {
+	client dynamic.Interface
+	lister cache.GenericLister
+
+	// config holds the current *Config, nil until the config-certmanager
+	// ConfigMap is successfully parsed.
+	config atomic.Value
+
+	// now is time.Now, overridable for testing.
+	now func() time.Time
+}
+
+// Check that our Reconciler implements certreconciler.Interface
+var _ certreconciler.Interface = (*Reconciler)(nil)
+
+// ReconcileKind implements certreconciler.Interface, creating or updating
+// the cert-manager Certificate of the Knative Certificate and copying back
+// its status.
+func (r *Reconciler) ReconcileKind(ctx context.Context, cert *v1alpha1.Certificate) pkgreconciler.Event {
+	cfg, _ := r.config.Load().(*Config)
+	if cfg == nil {
+		return fmt.Errorf("the %s ConfigMap is missing or invalid", ConfigName)
+	}
+
+	cert.Status.InitializeConditions()
+	desired := MakeCertManagerCertificate(cert, cfg.IssuerRef)
+	cmCert, err := r.reconcileCertManagerCertificate(ctx, cert, desired)
+	if err != nil {
+		return err
+	}
+	if cmCert == nil {
+		// Not owned by the Knative Certificate.
+		return nil
+	}
+	copyStatus(cert, cmCert, r.now())
+	return nil
+}
+
+// reconcileCertManagerCertificate makes sure the cert-manager Certificate
+// matches desired, returning it or nil if it exists but isn't controlled by
+// cert.
+func (r *Reconciler) reconcileCertManagerCertificate(ctx context.Context, cert *v1alpha1.Certificate, desired *unstructured.Unstructured) (*unstructured.Unstructured, error) {
+	client := r.client.Resource(CertificateGVR).Namespace(desired.GetNamespace())
+	obj, err := r.lister.ByNamespace(desired.GetNamespace()).Get(desired.GetName())
+	if apierrs.IsNotFound(err) {
+		cmCert, err := client.Create(ctx, desired, metav1.CreateOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create the cert-manager Certificate: %w", err)
+		}
+		return cmCert, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get the cert-manager Certificate: %w", err)
+	}
+
+	existing, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return nil, fmt.Errorf("unexpected type %T of the cert-manager Certificate", obj)
+	}
+	if !metav1.IsControlledBy(existing, cert) {
+		cert.Status.MarkResourceNotOwned(CertificateGVK.Kind, existing.GetName())
+		return nil, nil
+	}
+	if equality.Semantic.DeepEqual(existing.Object["spec"], desired.Object["spec"]) &&
+		equality.Semantic.DeepEqual(existing.GetLabels(), desired.GetLabels()) {
+		return existing, nil
+	}
+
+	// Don't modify the informer's copy.
+	want := existing.DeepCopy()
+	want.Object["spec"] = desired.Object["spec"]
+	want.SetLabels(kmap.Copy(desired.GetLabels()))
+	cmCert, err := client.Update(ctx, want, metav1.UpdateOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to update the cert-manager Certificate: %w", err)
+	}
+	return cmCert, nil
+}
@@ -0,0 +1,245 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package certmanager
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/tools/cache"
+	"knative.dev/networking/pkg/apis/networking/v1alpha1"
+	"knative.dev/pkg/apis"
+)
+
+// fakeClient records the cert-manager Certificates created and updated
+// through it. The other methods are not implemented.
+type fakeClient struct {
+	dynamic.Interface
+	dynamic.NamespaceableResourceInterface
+
+	created, updated []*unstructured.Unstructured
+}
+
+func (c *fakeClient) Resource(schema.GroupVersionResource) dynamic.NamespaceableResourceInterface {
+	return c
+}
+
+func (c *fakeClient) Namespace(string) dynamic.ResourceInterface {
+	return c
+}
+
+func (c *fakeClient) Create(_ context.Context, u *unstructured.Unstructured, _ metav1.CreateOptions, _ ...string) (*unstructured.Unstructured, error) {
+	c.created = append(c.created, u)
+	return u, nil
+}
+
+func (c *fakeClient) Update(_ context.Context, u *unstructured.Unstructured, _ metav1.UpdateOptions, _ ...string) (*unstructured.Unstructured, error) {
+	c.updated = append(c.updated, u)
+	return u, nil
+}
+
+func TestReconcileKind(t *testing.T) {
+	now := time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC)
+	issuer := &IssuerRef{Name: "letsencrypt", Kind: "ClusterIssuer"}
+	cert := &v1alpha1.Certificate{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "route-1234",
+			Namespace: "default",
+			UID:       "1234",
+			Labels:    map[string]string{"serving.knative.dev/route": "route"},
+		},
+		Spec: v1alpha1.CertificateSpec{
+			DNSNames:   []string{"route.default.example.com", "*.route.default.example.com"},
+			SecretName: "route-1234",
+		},
+	}
+	withStatus := func(u *unstructured.Unstructured, notAfter string, conditions ...map[string]interface{}) *unstructured.Unstructured {
+		u = u.DeepCopy()
+		c := make([]interface{}, 0, len(conditions))
+		for _, cond := range conditions {
+			c = append(c, cond)
+		}
+		unstructured.SetNestedSlice(u.Object, c, "status", "conditions")
+		if notAfter != "" {
+			unstructured.SetNestedField(u.Object, notAfter, "status", "notAfter")
+		}
+		return u
+	}
+	cond := func(typ, status, reason, message string) map[string]interface{} {
+		return map[string]interface{}{"type": typ, "status": status, "reason": reason, "message": message}
+	}
+	desired := MakeCertManagerCertificate(cert, issuer)
+	outdated := desired.DeepCopy()
+	unstructured.SetNestedStringSlice(outdated.Object, []string{"old.example.com"}, "spec", "dnsNames")
+	notOwned := desired.DeepCopy()
+	notOwned.SetOwnerReferences(nil)
+
+	tests := []struct {
+		name        string
+		existing    *unstructured.Unstructured
+		wantCreated bool
+		wantUpdated bool
+		wantReady   *apis.Condition
+		wantExpiry  *metav1.Time
+	}{{
+		name:        "created",
+		wantCreated: true,
+		wantReady: &apis.Condition{Type: apis.ConditionReady, Status: "Unknown",
+			Reason: v1alpha1.CertificateReasonNotYetIssued, Message: "Waiting for cert-manager to issue the certificate."},
+	}, {
+		name:       "ready",
+		existing:   withStatus(desired, "2022-09-01T00:00:00Z", cond(conditionReady, "True", "Ready", "Certificate is up to date")),
+		wantReady:  &apis.Condition{Type: apis.ConditionReady, Status: "True"},
+		wantExpiry: &metav1.Time{Time: time.Date(2022, 9, 1, 0, 0, 0, 0, time.UTC)},
+	}, {
+		name:        "updated",
+		existing:    withStatus(outdated, "", cond(conditionReady, "False", "DoesNotExist", "Issuing certificate as Secret does not exist")),
+		wantUpdated: true,
+		wantReady: &apis.Condition{Type: apis.ConditionReady, Status: "Unknown",
+			Reason: v1alpha1.CertificateReasonNotYetIssued, Message: "Issuing certificate as Secret does not exist"},
+	}, {
+		name: "renewing",
+		existing: withStatus(desired, "2022-06-15T00:00:00Z",
+			cond(conditionReady, "False", "Renewing", "Renewing certificate"),
+			cond(conditionIssuing, "True", "Renewing", "Renewing certificate as renewal was scheduled")),
+		wantReady: &apis.Condition{Type: apis.ConditionReady, Status: "Unknown",
+			Reason: v1alpha1.CertificateReasonRenewing, Message: "Renewing certificate as renewal was scheduled"},
+		wantExpiry: &metav1.Time{Time: time.Date(2022, 6, 15, 0, 0, 0, 0, time.UTC)},
+	}, {
+		name: "issuance failed",
+		existing: withStatus(desired, "",
+			cond(conditionReady, "False", "DoesNotExist", "Issuing certificate as Secret does not exist"),
+			cond(conditionIssuing, "False", reasonFailed, "The certificate request has failed to complete")),
+		wantReady: &apis.Condition{Type: apis.ConditionReady, Status: "False",
+			Reason: v1alpha1.CertificateReasonIssuanceFailed, Message: "The certificate request has failed to complete"},
+	}, {
+		name:     "expired",
+		existing: withStatus(desired, "2022-05-01T00:00:00Z", cond(conditionReady, "False", "Expired", "Certificate expired")),
+		wantReady: &apis.Condition{Type: apis.ConditionReady, Status: "False",
+			Reason: v1alpha1.CertificateReasonExpired, Message: "The certificate expired on 2022-05-01T00:00:00Z"},
+		wantExpiry: &metav1.Time{Time: time.Date(2022, 5, 1, 0, 0, 0, 0, time.UTC)},
+	}, {
+		name:     "not owned",
+		existing: notOwned,
+		wantReady: &apis.Condition{Type: apis.ConditionReady, Status: "False",
+			Reason: v1alpha1.CertificateReasonNotOwned, Message: `There is an existing Certificate "route-1234" that we do not own.`},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+			if test.existing != nil {
+				indexer.Add(test.existing)
+			}
+			client := &fakeClient{}
+			r := &Reconciler{
+				client: client,
+				lister: cache.NewGenericLister(indexer, CertificateGVR.GroupResource()),
+				now:    func() time.Time { return now },
+			}
+			r.config.Store(&Config{IssuerRef: issuer})
+
+			cert := cert.DeepCopy()
+			if err := r.ReconcileKind(context.Background(), cert); err != nil {
+				t.Fatal("ReconcileKind() =", err)
+			}
+
+			if got := len(client.created) > 0; got != test.wantCreated {
+				t.Errorf("Created = %v, want: %v", got, test.wantCreated)
+			} else if got && !cmp.Equal(client.created[0], desired) {
+				t.Error("Created (-want, +got) =", cmp.Diff(desired, client.created[0]))
+			}
+			if got := len(client.updated) > 0; got != test.wantUpdated {
+				t.Errorf("Updated = %v, want: %v", got, test.wantUpdated)
+			} else if got && !cmp.Equal(client.updated[0].Object["spec"], desired.Object["spec"]) {
+				t.Error("Updated spec (-want, +got) =", cmp.Diff(desired.Object["spec"], client.updated[0].Object["spec"]))
+			}
+
+			got := cert.Status.GetCondition(v1alpha1.CertificateConditionReady)
+			if !cmp.Equal(got, test.wantReady, cmp.FilterPath(func(p cmp.Path) bool {
+				return p.Last().String() == ".LastTransitionTime"
+			}, cmp.Ignore())) {
+				t.Errorf("Ready = %+v, want: %+v", got, test.wantReady)
+			}
+			if !cmp.Equal(cert.Status.NotAfter, test.wantExpiry) {
+				t.Errorf("NotAfter = %v, want: %v", cert.Status.NotAfter, test.wantExpiry)
+			}
+		})
+	}
+}
+
+func TestReconcileKindWithoutConfig(t *testing.T) {
+	r := &Reconciler{now: time.Now}
+	if err := r.ReconcileKind(context.Background(), &v1alpha1.Certificate{}); err == nil {
+		t.Error("ReconcileKind() succeeded without config, want error")
+	}
+}
+
+func TestMakeCertManagerCertificate(t *testing.T) {
+	cert := &v1alpha1.Certificate{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "route-1234",
+			Namespace: "default",
+			UID:       "1234",
+		},
+		Spec: v1alpha1.CertificateSpec{
+			DNSNames:   []string{"route.default.example.com"},
+			SecretName: "route-1234",
+		},
+	}
+	got := MakeCertManagerCertificate(cert, &IssuerRef{Name: "ca-issuer"})
+
+	if got, want := got.GetAPIVersion(), "cert-manager.io/v1"; got != want {
+		t.Errorf("APIVersion = %q, want: %q", got, want)
+	}
+	if got, want := got.Object["spec"], map[string]interface{}{
+		"secretName": "route-1234",
+		"commonName": "route.default.example.com",
+		"dnsNames":   []interface{}{"route.default.example.com"},
+		"issuerRef":  map[string]interface{}{"name": "ca-issuer"},
+	}; !cmp.Equal(got, want) {
+		t.Error("Spec (-want, +got) =", cmp.Diff(want, got))
+	}
+	if !metav1.IsControlledBy(got, cert) {
+		t.Error("The cert-manager Certificate is not controlled by the Knative Certificate")
+	}
+}
+
+func TestMakeCertManagerCertificateLongCommonName(t *testing.T) {
+	host := strings.Repeat("a", 50) + ".default.example.com"
+	cert := &v1alpha1.Certificate{
+		ObjectMeta: metav1.ObjectMeta{Name: "route-1234", Namespace: "default"},
+		Spec: v1alpha1.CertificateSpec{
+			DNSNames:   []string{host},
+			SecretName: "route-1234",
+		},
+	}
+	got := MakeCertManagerCertificate(cert, &IssuerRef{Name: "ca-issuer"})
+	if cn, ok, _ := unstructured.NestedString(got.Object, "spec", "commonName"); ok {
+		t.Errorf("commonName = %q, want none for a host of %d bytes", cn, len(host))
+	}
+	if names, _, _ := unstructured.NestedStringSlice(got.Object, "spec", "dnsNames"); !cmp.Equal(names, []string{host}) {
+		t.Errorf("dnsNames = %v, want: %v", names, []string{host})
+	}
+}
@@ -0,0 +1,153 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package certmanager
+
+import (
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"knative.dev/networking/pkg/apis/networking/v1alpha1"
+	"knative.dev/pkg/kmap"
+	"knative.dev/pkg/kmeta"
+)
+
+var (
+	// CertificateGVR is the resource of the cert-manager Certificates.
+	CertificateGVR = schema.GroupVersionResource{
+		Group:    "cert-manager.io",
+		Version:  "v1",
+		Resource: "certificates",
+	}
+
+	// CertificateGVK is the kind of the cert-manager Certificates.
+	CertificateGVK = CertificateGVR.GroupVersion().WithKind("Certificate")
+)
+
+// The conditions of the cert-manager Certificates.
+const (
+	conditionReady   = "Ready"
+	conditionIssuing = "Issuing"
+
+	// reasonFailed is the reason of the False Issuing condition of the
+	// Certificates whose issuance failed.
+	reasonFailed = "Failed"
+)
+
+// maxCommonNameLength is the maximum length of the common names accepted by
+// cert-manager, from RFC 5280.
+const maxCommonNameLength = 64
+
+// MakeCertManagerCertificate creates the cert-manager Certificate issuing the
+// certificate of the given Knative Certificate with the issuer. It has the
+// name, namespace and labels of the Knative Certificate, which controls it.
+func MakeCertManagerCertificate(cert *v1alpha1.Certificate, issuer *IssuerRef) *unstructured.Unstructured {
+	dnsNames := make([]interface{}, 0, len(cert.Spec.DNSNames))
+	for _, name := range cert.Spec.DNSNames {
+		dnsNames = append(dnsNames, name)
+	}
+	issuerRef := map[string]interface{}{
+		"name": issuer.Name,
+	}
+	if issuer.Kind != "" {
+		issuerRef["kind"] = issuer.Kind
+	}
+	if issuer.Group != "" {
+		issuerRef["group"] = issuer.Group
+	}
+
+	u := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{
+			"secretName": cert.Spec.SecretName,
+			"dnsNames":   dnsNames,
+			"issuerRef":  issuerRef,
+		},
+	}}
+	// Some issuers, e.g. the CA issuers, require a common name, but
+	// cert-manager rejects the ones longer than 64 bytes, which the generated
+	// hosts often are, so the longer ones are only in the DNS names.
+	if len(cert.Spec.DNSNames) > 0 && len(cert.Spec.DNSNames[0]) <= maxCommonNameLength {
+		unstructured.SetNestedField(u.Object, cert.Spec.DNSNames[0], "spec", "commonName")
+	}
+	u.SetGroupVersionKind(CertificateGVK)
+	u.SetName(cert.Name)
+	u.SetNamespace(cert.Namespace)
+	u.SetLabels(kmap.Copy(cert.Labels))
+	u.SetOwnerReferences([]metav1.OwnerReference{*kmeta.NewControllerRef(cert)})
+	return u
+}
+
+// condition is the subset of the conditions of the cert-manager
+// Certificates the Knative Certificates rely on.
+type condition struct {
+	status  string
+	reason  string
+	message string
+}
+
+// getCondition returns the condition of the given type of the cert-manager
+// Certificate, or nil if it isn't set.
+func getCondition(u *unstructured.Unstructured, conditionType string) *condition {
+	conditions, _, _ := unstructured.NestedSlice(u.Object, "status", "conditions")
+	for _, c := range conditions {
+		m, ok := c.(map[string]interface{})
+		if !ok || m["type"] != conditionType {
+			continue
+		}
+		status, _, _ := unstructured.NestedString(m, "status")
+		reason, _, _ := unstructured.NestedString(m, "reason")
+		message, _, _ := unstructured.NestedString(m, "message")
+		return &condition{status: status, reason: reason, message: message}
+	}
+	return nil
+}
+
+// getNotAfter returns the expiration time of the certificate issued for the
+// cert-manager Certificate, or nil if none was issued yet.
+func getNotAfter(u *unstructured.Unstructured) *metav1.Time {
+	v, ok, _ := unstructured.NestedString(u.Object, "status", "notAfter")
+	if !ok {
+		return nil
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return nil
+	}
+	return &metav1.Time{Time: t}
+}
+
+// copyStatus reflects the status of the cert-manager Certificate in the status
+// of the Knative Certificate, as of now.
+func copyStatus(cert *v1alpha1.Certificate, u *unstructured.Unstructured, now time.Time) {
+	cert.Status.NotAfter = getNotAfter(u)
+	ready, issuing := getCondition(u, conditionReady), getCondition(u, conditionIssuing)
+	switch {
+	case ready != nil && ready.status == string(metav1.ConditionTrue):
+		cert.Status.MarkReady()
+	case cert.Status.NotAfter != nil && cert.Status.NotAfter.Time.Before(now):
+		cert.Status.MarkExpired("The certificate expired on " + cert.Status.NotAfter.Format(time.RFC3339))
+	case issuing != nil && issuing.status == string(metav1.ConditionFalse) && issuing.reason == reasonFailed:
+		cert.Status.MarkIssuanceFailed(issuing.message)
+	case issuing != nil && issuing.status == string(metav1.ConditionTrue) && cert.Status.NotAfter != nil:
+		cert.Status.MarkRenewing(issuing.message)
+	case ready != nil && ready.message != "":
+		cert.Status.MarkNotYetIssued(ready.message)
+	default:
+		cert.Status.MarkNotYetIssued("Waiting for cert-manager to issue the certificate.")
+	}
+}
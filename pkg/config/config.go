@@ -21,12 +21,17 @@ import (
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"net/netip"
 	"net/url"
 	"strings"
 	"text/template"
+	"time"
 
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/utils/lru"
+	"knative.dev/networking/pkg/apis/networking"
 	cm "knative.dev/pkg/configmap"
 	"sigs.k8s.io/yaml"
 )
@@ -78,6 +83,10 @@ const (
 	// AutocreateClusterDomainClaims property.
 	AutocreateClusterDomainClaimsKey = "autocreate-cluster-domain-claims"
 
+	// AvailableIngressClassesKey is the name of the configuration entry
+	// that specifies the Ingress classes installed in the cluster.
+	AvailableIngressClassesKey = "available-ingress-classes"
+
 	// AutoTLSKey is the name of the configuration entry
 	// that specifies enabling auto-TLS or not.
 	AutoTLSKey = "auto-tls"
@@ -117,6 +126,15 @@ const (
 	// that specifies the default duration of the configuration rollout.
 	RolloutDurationKey = "rollout-duration"
 
+	// RolloutStepPercentKey is the name of the configuration entry that
+	// specifies the percentage of traffic shifted to the newest revision
+	// at each step of a gradual rollout.
+	RolloutStepPercentKey = "rollout-step-percent"
+
+	// RolloutMinStepIntervalKey is the name of the configuration entry that
+	// specifies the minimal duration between two steps of a gradual rollout.
+	RolloutMinStepIntervalKey = "rollout-min-step-interval"
+
 	// TagTemplateKey is the name of the configuration entry that
 	// specifies the golang template string to use to construct the
 	// hostname for a Route's tag.
@@ -125,6 +143,46 @@ const (
 	// InternalEncryptionKey is the name of the configuration whether
 	// internal traffic is encrypted or not.
 	InternalEncryptionKey = "internal-encryption"
+
+	// TrustedProxyCIDRsKey is the name of the configuration entry
+	// that specifies the CIDRs of the proxies trusted to report the
+	// IP address of the client.
+	TrustedProxyCIDRsKey = "trusted-proxy-cidrs"
+
+	// H2CMaxConcurrentStreamsKey is the name of the configuration entry
+	// that specifies the maximum number of concurrent streams of the h2c
+	// connections of the data plane.
+	H2CMaxConcurrentStreamsKey = "h2c-max-concurrent-streams"
+
+	// H2CIdleTimeoutKey is the name of the configuration entry that
+	// specifies how long the h2c connections of the data plane are kept
+	// open while idle.
+	H2CIdleTimeoutKey = "h2c-idle-timeout"
+
+	// H2CPingIntervalKey is the name of the configuration entry that
+	// specifies after how long without frames the h2c clients of the data
+	// plane ping their connections to check their health.
+	H2CPingIntervalKey = "h2c-ping-interval"
+
+	// OCSPStaplingKey is the name of the configuration entry that
+	// specifies whether the data plane staples the OCSP responses of its
+	// serving certificates.
+	OCSPStaplingKey = "ocsp-stapling"
+
+	// OCSPRevocationCheckKey is the name of the configuration entry that
+	// specifies how the data plane checks the revocation of the
+	// certificates of its peers.
+	OCSPRevocationCheckKey = "ocsp-revocation-check"
+
+	// DataplaneTrustLevelKey is the name of the configuration entry that
+	// specifies the level of trust between the components of the data
+	// plane. It supersedes the dataplane-trust feature flag.
+	DataplaneTrustLevelKey = "dataplane-trust-level"
+
+	// dataplaneTrustFeatureKey is the name of the deprecated dataplane-trust
+	// feature flag, see features.DataplaneTrust, which is equivalent to the
+	// identity DataplaneTrust when Enabled.
+	dataplaneTrustFeatureKey = "dataplane-trust"
 )
 
 // HTTPProtocol indicates a type of HTTP endpoint behavior
@@ -168,6 +226,25 @@ const (
 	MeshCompatibilityModeAuto MeshCompatibilityMode = "auto"
 )
 
+// OCSPRevocationCheck is one of disabled, soft-fail or hard-fail, and
+// specifies how the OCSP responses stapled by the peers of the data plane are
+// checked during the TLS handshakes.
+type OCSPRevocationCheck string
+
+const (
+	// OCSPRevocationCheckDisabled ignores the OCSP responses.
+	OCSPRevocationCheckDisabled OCSPRevocationCheck = "disabled"
+
+	// OCSPRevocationCheckSoftFail rejects the peers whose certificate is
+	// reported as revoked, and accepts the ones which don't staple a valid
+	// and current OCSP response.
+	OCSPRevocationCheckSoftFail OCSPRevocationCheck = "soft-fail"
+
+	// OCSPRevocationCheckHardFail only accepts the peers stapling a valid and
+	// current OCSP response reporting their certificate as good.
+	OCSPRevocationCheckHardFail OCSPRevocationCheck = "hard-fail"
+)
+
 // DomainTemplateValues are the available properties people can choose from
 // in their Route's "DomainTemplate" golang template sting.
 // We could add more over time - e.g. RevisionName if we thought that
@@ -193,6 +270,11 @@ type Config struct {
 	// DefaultIngressClass specifies the default Ingress class.
 	DefaultIngressClass string
 
+	// AvailableIngressClasses specifies the Ingress classes installed in the
+	// cluster, which SelectIngressClass picks from, besides the default one.
+	// Defaults to empty, meaning all the classes are considered available.
+	AvailableIngressClasses sets.String
+
 	// DomainTemplate is the golang text template to use to generate the
 	// Route's domain (host) for the Service.
 	DomainTemplate string
@@ -226,6 +308,15 @@ type Config struct {
 	// RolloutDurationSecs specifies the default duration for the rollout.
 	RolloutDurationSecs int
 
+	// RolloutStepPercent specifies the percentage of traffic shifted to the
+	// newest revision at each step of a gradual rollout.
+	RolloutStepPercent int
+
+	// RolloutMinStepInterval specifies the minimal duration between two steps
+	// of a gradual rollout. It takes precedence over RolloutDurationSecs, i.e.
+	// the rollout takes longer if the steps would otherwise be shorter.
+	RolloutMinStepInterval time.Duration
+
 	// AutocreateClusterDomainClaims specifies whether cluster-wide DomainClaims
 	// should be automatically created (and deleted) as needed when a
 	// DomainMapping is reconciled. If this is false, the
@@ -253,6 +344,101 @@ type Config struct {
 
 	// DefaultExternal specifies whether internal traffic is encrypted or not.
 	InternalEncryption bool
+
+	// TrustedProxyCIDRs are the CIDRs of the proxies, e.g. the load balancers
+	// in front of the cluster, whose X-Forwarded-For and Forwarded headers are
+	// trusted to report the IP address of the client. Defaults to none.
+	TrustedProxyCIDRs CIDRs
+
+	// H2CMaxConcurrentStreams is the maximum number of concurrent streams
+	// of the h2c connections of the data plane. Defaults to 0, meaning the
+	// default of the HTTP/2 implementation.
+	H2CMaxConcurrentStreams uint32
+
+	// H2CIdleTimeout is how long the h2c connections of the data plane are
+	// kept open while idle. Defaults to 0, meaning no timeout.
+	H2CIdleTimeout time.Duration
+
+	// H2CPingInterval is how long the h2c clients of the data plane wait
+	// without receiving frames before pinging the connection, closing it if
+	// the ping isn't answered. Defaults to 0, meaning no pings.
+	H2CPingInterval time.Duration
+
+	// EnableOCSPStapling specifies whether the data plane staples the OCSP
+	// responses of its serving certificates. Defaults to false.
+	EnableOCSPStapling bool
+
+	// OCSPRevocationCheck specifies how the data plane checks the revocation
+	// of the certificates of its peers. Defaults to disabled.
+	OCSPRevocationCheck OCSPRevocationCheck
+
+	// DataplaneTrust is the level of trust between the components of the
+	// data plane, which the Ingresses can raise but not lower. Defaults to
+	// identity if the deprecated dataplane-trust feature flag is Enabled,
+	// and to none otherwise, in which case DataplaneTrustLevel derives it
+	// from InternalEncryption.
+	DataplaneTrust networking.DataplaneTrust
+}
+
+// DataplaneTrustLevel returns the level of trust between the components of
+// the data plane: DataplaneTrust if set, otherwise identity if
+// InternalEncryption is enabled, and edge if not.
+func (c *Config) DataplaneTrustLevel() networking.DataplaneTrust {
+	switch {
+	case c.DataplaneTrust != "":
+		return c.DataplaneTrust
+	case c.InternalEncryption:
+		return networking.DataplaneTrustIdentity
+	default:
+		return networking.DataplaneTrustEdge
+	}
+}
+
+// SelectIngressClass returns the first of the Ingress classes, listed in
+// order of preference, e.g. with networking.GetIngressClasses, which is
+// available, or the default Ingress class if none are listed. It returns
+// false if none of the classes listed is available.
+func (c *Config) SelectIngressClass(classes []string) (string, bool) {
+	if len(classes) == 0 {
+		return c.DefaultIngressClass, true
+	}
+	for _, class := range classes {
+		if len(c.AvailableIngressClasses) == 0 || c.AvailableIngressClasses.Has(class) || class == c.DefaultIngressClass {
+			return class, true
+		}
+	}
+	return "", false
+}
+
+// CIDRs is a list of IP address ranges.
+type CIDRs []netip.Prefix
+
+// Contains returns whether ip is in any of the ranges.
+func (c CIDRs) Contains(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	for _, p := range c {
+		if p.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// DeepCopyInto copies the receiver into out. netip.Prefix is immutable, so
+// copying the slice is enough.
+func (c CIDRs) DeepCopyInto(out *CIDRs) {
+	*out = make(CIDRs, len(c))
+	copy(*out, c)
+}
+
+// DeepCopy copies the receiver, creating a new CIDRs.
+func (c CIDRs) DeepCopy() CIDRs {
+	if c == nil {
+		return nil
+	}
+	var out CIDRs
+	c.DeepCopyInto(&out)
+	return out
 }
 
 func defaultConfig() *Config {
@@ -268,61 +454,165 @@ func defaultConfig() *Config {
 		DefaultExternalScheme:         "http",
 		MeshCompatibilityMode:         MeshCompatibilityModeAuto,
 		InternalEncryption:            false,
+		RolloutStepPercent:            1,
+		OCSPRevocationCheck:           OCSPRevocationCheckDisabled,
 	}
 }
 
 // NewConfigFromMap creates a Config from the supplied data.
 func NewConfigFromMap(data map[string]string) (*Config, error) {
+	nc, errs := parseConfig(data)
+	if len(errs) > 0 {
+		return nil, errs[0]
+	}
+	return nc, nil
+}
+
+// NewConfigFromConfigMap creates a Config from the supplied ConfigMap.
+func NewConfigFromConfigMap(configMap *corev1.ConfigMap) (*Config, error) {
+	return NewConfigFromMap(configMap.Data)
+}
+
+// Validate checks the data of the config-network ConfigMap. Unlike
+// NewConfigFromMap, which stops at the first error, it reports all the
+// problems found, including legacy keys set to a value conflicting with the
+// key superseding them, so that operators can lint a ConfigMap before
+// applying it.
+func Validate(data map[string]string) []error {
+	_, errs := parseConfig(data)
+	errs = append(errs, checkLegacyKeys(data)...)
+	return append(errs, checkDataplaneTrust(data)...)
+}
+
+// legacyKeys maps the legacy keys to the keys superseding them.
+var legacyKeys = map[string]string{
+	"ingress.class":                 DefaultIngressClassKey,
+	"certificate.class":             DefaultCertificateClassKey,
+	"domainTemplate":                DomainTemplateKey,
+	"tagTemplate":                   TagTemplateKey,
+	"rolloutDuration":               RolloutDurationKey,
+	"autocreateClusterDomainClaims": AutocreateClusterDomainClaimsKey,
+	"defaultExternalScheme":         DefaultExternalSchemeKey,
+	"autoTLS":                       AutoTLSKey,
+	"httpProtocol":                  HTTPProtocolKey,
+}
+
+// checkLegacyKeys reports the legacy keys set along with the key superseding
+// them, but to a different value.
+func checkLegacyKeys(data map[string]string) []error {
+	var errs []error
+	for _, legacy := range sets.StringKeySet(legacyKeys).List() {
+		key := legacyKeys[legacy]
+		legacyVal, hasLegacy := data[legacy]
+		val, has := data[key]
+		if hasLegacy && has && legacyVal != val {
+			errs = append(errs, fmt.Errorf("%s is set to %q, conflicting with its legacy equivalent %s set to %q; %s takes precedence",
+				key, val, legacy, legacyVal, key))
+		}
+	}
+	return errs
+}
+
+// checkDataplaneTrust reports the deprecated dataplane-trust feature flag
+// enabled along with a different dataplane-trust-level, which takes
+// precedence.
+func checkDataplaneTrust(data map[string]string) []error {
+	flag, level := data[dataplaneTrustFeatureKey], data[DataplaneTrustLevelKey]
+	if strings.EqualFold(flag, "Enabled") && level != "" &&
+		!strings.EqualFold(level, string(networking.DataplaneTrustIdentity)) {
+		return []error{fmt.Errorf("%s is set to %q, conflicting with the deprecated %s feature flag set to %q; %s takes precedence",
+			DataplaneTrustLevelKey, level, dataplaneTrustFeatureKey, flag, DataplaneTrustLevelKey)}
+	}
+	return nil
+}
+
+// parseConfig creates a Config from the supplied data, returning all the
+// errors encountered along the way.
+func parseConfig(data map[string]string) (*Config, []error) {
 	nc := defaultConfig()
+	var errs []error
 
-	if err := cm.Parse(data,
+	for _, p := range []struct {
+		key   string
+		parse cm.ParseFunc
+	}{
 		// Legacy keys
-		cm.AsString("ingress.class", &nc.DefaultIngressClass),
-		cm.AsString("certificate.class", &nc.DefaultCertificateClass),
-		cm.AsString("domainTemplate", &nc.DomainTemplate),
-		cm.AsString("tagTemplate", &nc.TagTemplate),
-		cm.AsInt("rolloutDuration", &nc.RolloutDurationSecs),
-		cm.AsBool("autocreateClusterDomainClaims", &nc.AutocreateClusterDomainClaims),
-		cm.AsString("defaultExternalScheme", &nc.DefaultExternalScheme),
+		{"ingress.class", cm.AsString("ingress.class", &nc.DefaultIngressClass)},
+		{"certificate.class", cm.AsString("certificate.class", &nc.DefaultCertificateClass)},
+		{"domainTemplate", cm.AsString("domainTemplate", &nc.DomainTemplate)},
+		{"tagTemplate", cm.AsString("tagTemplate", &nc.TagTemplate)},
+		{"rolloutDuration", cm.AsInt("rolloutDuration", &nc.RolloutDurationSecs)},
+		{"autocreateClusterDomainClaims", cm.AsBool("autocreateClusterDomainClaims", &nc.AutocreateClusterDomainClaims)},
+		{"defaultExternalScheme", cm.AsString("defaultExternalScheme", &nc.DefaultExternalScheme)},
 
 		// New key takes precedence.
-		cm.AsString(DefaultIngressClassKey, &nc.DefaultIngressClass),
-		cm.AsString(DefaultCertificateClassKey, &nc.DefaultCertificateClass),
-		cm.AsString(DomainTemplateKey, &nc.DomainTemplate),
-		cm.AsString(TagTemplateKey, &nc.TagTemplate),
-		cm.AsInt(RolloutDurationKey, &nc.RolloutDurationSecs),
-		cm.AsBool(AutocreateClusterDomainClaimsKey, &nc.AutocreateClusterDomainClaims),
-		cm.AsBool(EnableMeshPodAddressabilityKey, &nc.EnableMeshPodAddressability),
-		cm.AsString(DefaultExternalSchemeKey, &nc.DefaultExternalScheme),
-		cm.AsBool(InternalEncryptionKey, &nc.InternalEncryption),
-		asMode(MeshCompatibilityModeKey, &nc.MeshCompatibilityMode),
-		asLabelSelector(NamespaceWildcardCertSelectorKey, &nc.NamespaceWildcardCertSelector),
-	); err != nil {
-		return nil, err
+		{DefaultIngressClassKey, cm.AsString(DefaultIngressClassKey, &nc.DefaultIngressClass)},
+		{AvailableIngressClassesKey, cm.AsStringSet(AvailableIngressClassesKey, &nc.AvailableIngressClasses)},
+		{DefaultCertificateClassKey, cm.AsString(DefaultCertificateClassKey, &nc.DefaultCertificateClass)},
+		{DomainTemplateKey, cm.AsString(DomainTemplateKey, &nc.DomainTemplate)},
+		{TagTemplateKey, cm.AsString(TagTemplateKey, &nc.TagTemplate)},
+		{RolloutDurationKey, cm.AsInt(RolloutDurationKey, &nc.RolloutDurationSecs)},
+		{RolloutStepPercentKey, cm.AsInt(RolloutStepPercentKey, &nc.RolloutStepPercent)},
+		{RolloutMinStepIntervalKey, cm.AsDuration(RolloutMinStepIntervalKey, &nc.RolloutMinStepInterval)},
+		{AutocreateClusterDomainClaimsKey, cm.AsBool(AutocreateClusterDomainClaimsKey, &nc.AutocreateClusterDomainClaims)},
+		{EnableMeshPodAddressabilityKey, cm.AsBool(EnableMeshPodAddressabilityKey, &nc.EnableMeshPodAddressability)},
+		{DefaultExternalSchemeKey, cm.AsString(DefaultExternalSchemeKey, &nc.DefaultExternalScheme)},
+		{InternalEncryptionKey, cm.AsBool(InternalEncryptionKey, &nc.InternalEncryption)},
+		{MeshCompatibilityModeKey, asMode(MeshCompatibilityModeKey, &nc.MeshCompatibilityMode)},
+		{NamespaceWildcardCertSelectorKey, asLabelSelector(NamespaceWildcardCertSelectorKey, &nc.NamespaceWildcardCertSelector)},
+		{TrustedProxyCIDRsKey, asCIDRs(TrustedProxyCIDRsKey, &nc.TrustedProxyCIDRs)},
+		{H2CMaxConcurrentStreamsKey, cm.AsUint32(H2CMaxConcurrentStreamsKey, &nc.H2CMaxConcurrentStreams)},
+		{H2CIdleTimeoutKey, cm.AsDuration(H2CIdleTimeoutKey, &nc.H2CIdleTimeout)},
+		{H2CPingIntervalKey, cm.AsDuration(H2CPingIntervalKey, &nc.H2CPingInterval)},
+		{OCSPStaplingKey, cm.AsBool(OCSPStaplingKey, &nc.EnableOCSPStapling)},
+		{OCSPRevocationCheckKey, asOCSPRevocationCheck(OCSPRevocationCheckKey, &nc.OCSPRevocationCheck)},
+		{dataplaneTrustFeatureKey, asDataplaneTrustFeature(dataplaneTrustFeatureKey, &nc.DataplaneTrust)},
+		{DataplaneTrustLevelKey, asDataplaneTrust(DataplaneTrustLevelKey, &nc.DataplaneTrust)},
+	} {
+		// Parse the keys one at a time to report all the malformed ones.
+		if err := cm.Parse(data, p.parse); err != nil {
+			errs = append(errs, fmt.Errorf("failed to parse %s: %w", p.key, err))
+		}
+	}
+
+	// Drop the empty entries, e.g. of the example value or of trailing commas.
+	nc.AvailableIngressClasses.Delete("")
+	if len(nc.AvailableIngressClasses) == 0 {
+		nc.AvailableIngressClasses = nil
 	}
 
 	if nc.RolloutDurationSecs < 0 {
-		return nil, fmt.Errorf("%s must be a positive integer, but was %d", RolloutDurationKey, nc.RolloutDurationSecs)
+		errs = append(errs, fmt.Errorf("%s must be a positive integer, but was %d", RolloutDurationKey, nc.RolloutDurationSecs))
 	}
-	// Verify domain-template and add to the cache.
-	t, err := template.New("domain-template").Parse(nc.DomainTemplate)
-	if err != nil {
-		return nil, err
+	if nc.RolloutStepPercent < 1 || nc.RolloutStepPercent > 100 {
+		errs = append(errs, fmt.Errorf("%s must be in [1, 100] range, but was %d", RolloutStepPercentKey, nc.RolloutStepPercent))
+	}
+	if nc.H2CIdleTimeout < 0 {
+		errs = append(errs, fmt.Errorf("%s must be a non-negative duration, but was %v", H2CIdleTimeoutKey, nc.H2CIdleTimeout))
+	}
+	if nc.H2CPingInterval < 0 {
+		errs = append(errs, fmt.Errorf("%s must be a non-negative duration, but was %v", H2CPingIntervalKey, nc.H2CPingInterval))
 	}
-	if err := checkDomainTemplate(t); err != nil {
-		return nil, err
+	if nc.RolloutMinStepInterval < 0 {
+		errs = append(errs, fmt.Errorf("%s must be a non-negative duration, but was %v", RolloutMinStepIntervalKey, nc.RolloutMinStepInterval))
+	}
+	// Verify domain-template and add to the cache.
+	if t, err := template.New("domain-template").Parse(nc.DomainTemplate); err != nil {
+		errs = append(errs, err)
+	} else if err := checkDomainTemplate(t); err != nil {
+		errs = append(errs, err)
+	} else {
+		templateCache.Add(nc.DomainTemplate, t)
 	}
-	templateCache.Add(nc.DomainTemplate, t)
 
 	// Verify tag-template and add to the cache.
-	t, err = template.New("tag-template").Parse(nc.TagTemplate)
-	if err != nil {
-		return nil, err
+	if t, err := template.New("tag-template").Parse(nc.TagTemplate); err != nil {
+		errs = append(errs, err)
+	} else if err := checkTagTemplate(t); err != nil {
+		errs = append(errs, err)
+	} else {
+		templateCache.Add(nc.TagTemplate, t)
 	}
-	if err := checkTagTemplate(t); err != nil {
-		return nil, err
-	}
-	templateCache.Add(nc.TagTemplate, t)
 
 	if val, ok := data["autoTLS"]; ok {
 		nc.AutoTLS = strings.EqualFold(val, "enabled")
@@ -348,10 +638,44 @@ func NewConfigFromMap(data map[string]string) (*Config, error) {
 	case string(HTTPRedirected):
 		nc.HTTPProtocol = HTTPRedirected
 	default:
-		return nil, fmt.Errorf("httpProtocol %s in config-network ConfigMap is not supported", data[HTTPProtocolKey])
+		errs = append(errs, fmt.Errorf("httpProtocol %s in config-network ConfigMap is not supported", data[HTTPProtocolKey]))
 	}
 
-	return nc, nil
+	return nc, errs
+}
+
+// RolloutDuration returns the default duration of the rollout.
+func (c *Config) RolloutDuration() time.Duration {
+	return time.Duration(c.RolloutDurationSecs) * time.Second
+}
+
+// GradualRolloutEnabled returns true if traffic is shifted to the newest
+// revision gradually rather than all at once.
+func (c *Config) GradualRolloutEnabled() bool {
+	return c.RolloutDurationSecs > 0
+}
+
+// RolloutSteps returns the number of steps needed to shift all the traffic
+// to the newest revision during a gradual rollout.
+func (c *Config) RolloutSteps() int {
+	step := c.RolloutStepPercent
+	if step < 1 {
+		step = 1
+	}
+	return (100 + step - 1) / step
+}
+
+// RolloutStepInterval returns the duration between two steps of a gradual
+// rollout, or zero if gradual rollout is disabled.
+func (c *Config) RolloutStepInterval() time.Duration {
+	if !c.GradualRolloutEnabled() {
+		return 0
+	}
+	interval := c.RolloutDuration() / time.Duration(c.RolloutSteps())
+	if interval < c.RolloutMinStepInterval {
+		return c.RolloutMinStepInterval
+	}
+	return interval
 }
 
 // GetDomainTemplate returns the golang Template from the config map
@@ -437,6 +761,78 @@ func asLabelSelector(key string, target **metav1.LabelSelector) cm.ParseFunc {
 	}
 }
 
+// asCIDRs parses the value at key as a comma separated list of CIDRs into the
+// target, if it exists.
+func asCIDRs(key string, target *CIDRs) cm.ParseFunc {
+	return func(data map[string]string) error {
+		raw, ok := data[key]
+		if !ok {
+			return nil
+		}
+		var cidrs CIDRs
+		for _, s := range strings.Split(raw, ",") {
+			if s = strings.TrimSpace(s); s == "" {
+				continue
+			}
+			cidr, err := netip.ParsePrefix(s)
+			if err != nil {
+				return err
+			}
+			cidrs = append(cidrs, cidr.Masked())
+		}
+		*target = cidrs
+		return nil
+	}
+}
+
+// asOCSPRevocationCheck parses the value at key as an OCSPRevocationCheck into
+// the target, if it exists.
+func asOCSPRevocationCheck(key string, target *OCSPRevocationCheck) cm.ParseFunc {
+	return func(data map[string]string) error {
+		if raw, ok := data[key]; ok {
+			for _, check := range []OCSPRevocationCheck{OCSPRevocationCheckDisabled, OCSPRevocationCheckSoftFail, OCSPRevocationCheckHardFail} {
+				if strings.EqualFold(raw, string(check)) {
+					*target = check
+					return nil
+				}
+			}
+			return fmt.Errorf("%q is not one of %s, %s or %s", raw,
+				OCSPRevocationCheckDisabled, OCSPRevocationCheckSoftFail, OCSPRevocationCheckHardFail)
+		}
+		return nil
+	}
+}
+
+// asDataplaneTrust parses the value at key as a DataplaneTrust into the
+// target, if it exists and is not empty.
+func asDataplaneTrust(key string, target *networking.DataplaneTrust) cm.ParseFunc {
+	return func(data map[string]string) error {
+		if raw, ok := data[key]; ok && raw != "" {
+			for _, trust := range []networking.DataplaneTrust{networking.DataplaneTrustEdge, networking.DataplaneTrustIdentity, networking.DataplaneTrustMutual} {
+				if strings.EqualFold(raw, string(trust)) {
+					*target = trust
+					return nil
+				}
+			}
+			return fmt.Errorf("%q is not one of %s, %s or %s", raw,
+				networking.DataplaneTrustEdge, networking.DataplaneTrustIdentity, networking.DataplaneTrustMutual)
+		}
+		return nil
+	}
+}
+
+// asDataplaneTrustFeature parses the value at key as the deprecated
+// dataplane-trust feature flag into the target, setting it to identity if
+// the flag is Enabled.
+func asDataplaneTrustFeature(key string, target *networking.DataplaneTrust) cm.ParseFunc {
+	return func(data map[string]string) error {
+		if raw, ok := data[key]; ok && strings.EqualFold(raw, "Enabled") {
+			*target = networking.DataplaneTrustIdentity
+		}
+		return nil
+	}
+}
+
 // asMode parses the value at key as a MeshCompatibilityMode into the target, if it exists.
 func asMode(key string, target *MeshCompatibilityMode) cm.ParseFunc {
 	return func(data map[string]string) error {
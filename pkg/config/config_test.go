@@ -18,13 +18,17 @@ package config
 
 import (
 	"bytes"
+	"net/netip"
 	"testing"
 	"text/template"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/go-cmp/cmp/cmpopts"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/utils/lru"
+	"knative.dev/networking/pkg/apis/networking"
 
 	. "knative.dev/pkg/configmap/testing"
 )
@@ -45,6 +49,7 @@ func TestOurConfig(t *testing.T) {
 func TestConfiguration(t *testing.T) {
 	const nonDefaultDomainTemplate = "{{.Namespace}}.{{.Name}}.{{.Domain}}"
 	ignoreDT := cmpopts.IgnoreFields(Config{}, "DomainTemplate")
+	cmpPrefix := cmp.Comparer(func(a, b netip.Prefix) bool { return a == b })
 
 	networkConfigTests := []struct {
 		name       string
@@ -66,6 +71,17 @@ func TestConfiguration(t *testing.T) {
 			c.DefaultIngressClass = "foo-ingress"
 			return c
 		}(),
+	}, {
+		name: "network configuration with available ingress classes",
+		data: map[string]string{
+			AvailableIngressClassesKey: "kourier.ingress.networking.knative.dev, contour.ingress.networking.knative.dev",
+		},
+		wantConfig: func() *Config {
+			c := defaultConfig()
+			c.AvailableIngressClasses = sets.NewString(
+				"kourier.ingress.networking.knative.dev", "contour.ingress.networking.knative.dev")
+			return c
+		}(),
 	}, {
 		name: "network configuration with non-default rollout duration",
 		data: map[string]string{
@@ -88,6 +104,151 @@ func TestConfiguration(t *testing.T) {
 			RolloutDurationKey: "-444",
 		},
 		wantErr: true,
+	}, {
+		name: "network configuration with gradual rollout knobs",
+		data: map[string]string{
+			RolloutDurationKey:        "120",
+			RolloutStepPercentKey:     "5",
+			RolloutMinStepIntervalKey: "10s",
+		},
+		wantConfig: func() *Config {
+			c := defaultConfig()
+			c.RolloutDurationSecs = 120
+			c.RolloutStepPercent = 5
+			c.RolloutMinStepInterval = 10 * time.Second
+			return c
+		}(),
+	}, {
+		name: "network configuration with zero rollout step percent",
+		data: map[string]string{
+			RolloutStepPercentKey: "0",
+		},
+		wantErr: true,
+	}, {
+		name: "network configuration with too large rollout step percent",
+		data: map[string]string{
+			RolloutStepPercentKey: "101",
+		},
+		wantErr: true,
+	}, {
+		name: "network configuration with bad rollout min step interval",
+		data: map[string]string{
+			RolloutMinStepIntervalKey: "quickly",
+		},
+		wantErr: true,
+	}, {
+		name: "network configuration with negative rollout min step interval",
+		data: map[string]string{
+			RolloutMinStepIntervalKey: "-1s",
+		},
+		wantErr: true,
+	}, {
+		name: "network configuration with trusted proxy cidrs",
+		data: map[string]string{
+			TrustedProxyCIDRsKey: "10.0.0.0/8, 192.168.1.1/24,2001:db8::/32",
+		},
+		wantConfig: func() *Config {
+			c := defaultConfig()
+			c.TrustedProxyCIDRs = CIDRs{
+				netip.MustParsePrefix("10.0.0.0/8"),
+				netip.MustParsePrefix("192.168.1.0/24"),
+				netip.MustParsePrefix("2001:db8::/32"),
+			}
+			return c
+		}(),
+	}, {
+		name: "network configuration with bad trusted proxy cidrs",
+		data: map[string]string{
+			TrustedProxyCIDRsKey: "10.0.0.0/8,10.0.0.1",
+		},
+		wantErr: true,
+	}, {
+		name: "network configuration with h2c knobs",
+		data: map[string]string{
+			H2CMaxConcurrentStreamsKey: "100",
+			H2CIdleTimeoutKey:          "1m",
+			H2CPingIntervalKey:         "10s",
+		},
+		wantConfig: func() *Config {
+			c := defaultConfig()
+			c.H2CMaxConcurrentStreams = 100
+			c.H2CIdleTimeout = time.Minute
+			c.H2CPingInterval = 10 * time.Second
+			return c
+		}(),
+	}, {
+		name: "network configuration with bad h2c max concurrent streams",
+		data: map[string]string{
+			H2CMaxConcurrentStreamsKey: "-1",
+		},
+		wantErr: true,
+	}, {
+		name: "network configuration with negative h2c idle timeout",
+		data: map[string]string{
+			H2CIdleTimeoutKey: "-1s",
+		},
+		wantErr: true,
+	}, {
+		name: "network configuration with negative h2c ping interval",
+		data: map[string]string{
+			H2CPingIntervalKey: "-1s",
+		},
+		wantErr: true,
+	}, {
+		name: "network configuration with OCSP",
+		data: map[string]string{
+			OCSPStaplingKey:        "true",
+			OCSPRevocationCheckKey: "Hard-Fail",
+		},
+		wantConfig: func() *Config {
+			c := defaultConfig()
+			c.EnableOCSPStapling = true
+			c.OCSPRevocationCheck = OCSPRevocationCheckHardFail
+			return c
+		}(),
+	}, {
+		name: "network configuration with bad OCSP revocation check",
+		data: map[string]string{
+			OCSPRevocationCheckKey: "strict",
+		},
+		wantErr: true,
+	}, {
+		name: "network configuration with dataplane trust level",
+		data: map[string]string{
+			DataplaneTrustLevelKey: "Mutual",
+		},
+		wantConfig: func() *Config {
+			c := defaultConfig()
+			c.DataplaneTrust = networking.DataplaneTrustMutual
+			return c
+		}(),
+	}, {
+		name: "network configuration with the deprecated dataplane trust flag",
+		data: map[string]string{
+			"dataplane-trust": "Enabled",
+		},
+		wantConfig: func() *Config {
+			c := defaultConfig()
+			c.DataplaneTrust = networking.DataplaneTrustIdentity
+			return c
+		}(),
+	}, {
+		name: "network configuration with the dataplane trust level overriding the flag",
+		data: map[string]string{
+			"dataplane-trust":      "Enabled",
+			DataplaneTrustLevelKey: "mutual",
+		},
+		wantConfig: func() *Config {
+			c := defaultConfig()
+			c.DataplaneTrust = networking.DataplaneTrustMutual
+			return c
+		}(),
+	}, {
+		name: "network configuration with bad dataplane trust level",
+		data: map[string]string{
+			DataplaneTrustLevelKey: "total",
+		},
+		wantErr: true,
 	}, {
 		name: "network configuration with non-default autocreateClusterDomainClaim value",
 		data: map[string]string{
@@ -313,8 +474,10 @@ func TestConfiguration(t *testing.T) {
 			HTTPProtocol:                  HTTPRedirected,
 			AutoTLS:                       true,
 
-			// This is defaulted
+			// These are defaulted
 			MeshCompatibilityMode: MeshCompatibilityModeAuto,
+			RolloutStepPercent:    1,
+			OCSPRevocationCheck:   OCSPRevocationCheckDisabled,
 		},
 	}, {
 		name: "newer keys take precedence over legacy keys",
@@ -353,8 +516,10 @@ func TestConfiguration(t *testing.T) {
 			HTTPProtocol:                  HTTPEnabled,
 			AutoTLS:                       false,
 
-			// This is defaulted
+			// These are defaulted
 			MeshCompatibilityMode: MeshCompatibilityModeAuto,
+			RolloutStepPercent:    1,
+			OCSPRevocationCheck:   OCSPRevocationCheckDisabled,
 		},
 	}}
 
@@ -380,13 +545,96 @@ func TestConfiguration(t *testing.T) {
 				t.Errorf("DomainTemplate(data) = %s, wanted %s", got, want)
 			}
 
-			if diff := cmp.Diff(actualConfig, tt.wantConfig, ignoreDT); diff != "" {
+			if diff := cmp.Diff(actualConfig, tt.wantConfig, ignoreDT, cmpPrefix); diff != "" {
 				t.Fatalf("diff (-want,+got) %v", diff)
 			}
 		})
 	}
 }
 
+func TestSelectIngressClass(t *testing.T) {
+	const (
+		istio   = IstioIngressClassName
+		kourier = "kourier.ingress.networking.knative.dev"
+		contour = "contour.ingress.networking.knative.dev"
+	)
+	tests := []struct {
+		name      string
+		available sets.String
+		classes   []string
+		want      string
+		wantOK    bool
+	}{{
+		name:   "no classes",
+		want:   istio,
+		wantOK: true,
+	}, {
+		name:    "all available",
+		classes: []string{kourier, istio},
+		want:    kourier,
+		wantOK:  true,
+	}, {
+		name:      "first available",
+		available: sets.NewString(contour),
+		classes:   []string{kourier, contour, istio},
+		want:      contour,
+		wantOK:    true,
+	}, {
+		name:      "default is available",
+		available: sets.NewString(contour),
+		classes:   []string{kourier, istio},
+		want:      istio,
+		wantOK:    true,
+	}, {
+		name:      "none available",
+		available: sets.NewString(contour),
+		classes:   []string{kourier, "gloo.ingress.networking.knative.dev"},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := defaultConfig()
+			c.AvailableIngressClasses = test.available
+			got, ok := c.SelectIngressClass(test.classes)
+			if got != test.want || ok != test.wantOK {
+				t.Errorf("SelectIngressClass() = %q, %v, want: %q, %v", got, ok, test.want, test.wantOK)
+			}
+		})
+	}
+}
+
+func TestDataplaneTrustLevel(t *testing.T) {
+	tests := []struct {
+		name               string
+		trust              networking.DataplaneTrust
+		internalEncryption bool
+		want               networking.DataplaneTrust
+	}{{
+		name: "default",
+		want: networking.DataplaneTrustEdge,
+	}, {
+		name:               "internal encryption",
+		internalEncryption: true,
+		want:               networking.DataplaneTrustIdentity,
+	}, {
+		name:               "explicit level",
+		trust:              networking.DataplaneTrustMutual,
+		internalEncryption: true,
+		want:               networking.DataplaneTrustMutual,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := defaultConfig()
+			c.DataplaneTrust = test.trust
+			c.InternalEncryption = test.internalEncryption
+			if got := c.DataplaneTrustLevel(); got != test.want {
+				t.Errorf("DataplaneTrustLevel() = %q, want: %q", got, test.want)
+			}
+		})
+	}
+}
+
 func TestTemplateCaching(t *testing.T) {
 	// Reset the template cache, to ensure size change.
 	templateCache = lru.New(10)
@@ -534,3 +782,134 @@ func mustExecute(t *testing.T, tmpl *template.Template, data interface{}) string
 	}
 	return buf.String()
 }
+
+func TestRolloutAccessors(t *testing.T) {
+	tests := []struct {
+		name         string
+		config       *Config
+		wantEnabled  bool
+		wantSteps    int
+		wantInterval time.Duration
+	}{{
+		name:      "defaults",
+		config:    defaultConfig(),
+		wantSteps: 100,
+	}, {
+		name: "one percent steps",
+		config: &Config{
+			RolloutDurationSecs: 200,
+			RolloutStepPercent:  1,
+		},
+		wantEnabled:  true,
+		wantSteps:    100,
+		wantInterval: 2 * time.Second,
+	}, {
+		name: "uneven steps",
+		config: &Config{
+			RolloutDurationSecs: 120,
+			RolloutStepPercent:  30,
+		},
+		wantEnabled:  true,
+		wantSteps:    4,
+		wantInterval: 30 * time.Second,
+	}, {
+		name: "min step interval",
+		config: &Config{
+			RolloutDurationSecs:    60,
+			RolloutStepPercent:     10,
+			RolloutMinStepInterval: 10 * time.Second,
+		},
+		wantEnabled:  true,
+		wantSteps:    10,
+		wantInterval: 10 * time.Second,
+	}, {
+		name: "unset step percent",
+		config: &Config{
+			RolloutDurationSecs: 100,
+		},
+		wantEnabled:  true,
+		wantSteps:    100,
+		wantInterval: time.Second,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got, want := test.config.RolloutDuration(), time.Duration(test.config.RolloutDurationSecs)*time.Second; got != want {
+				t.Errorf("RolloutDuration() = %v, want: %v", got, want)
+			}
+			if got := test.config.GradualRolloutEnabled(); got != test.wantEnabled {
+				t.Errorf("GradualRolloutEnabled() = %v, want: %v", got, test.wantEnabled)
+			}
+			if got := test.config.RolloutSteps(); got != test.wantSteps {
+				t.Errorf("RolloutSteps() = %d, want: %d", got, test.wantSteps)
+			}
+			if got := test.config.RolloutStepInterval(); got != test.wantInterval {
+				t.Errorf("RolloutStepInterval() = %v, want: %v", got, test.wantInterval)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	_, example := ConfigMapsFromTestFile(t, ConfigMapName)
+
+	tests := []struct {
+		name string
+		data map[string]string
+		want []string
+	}{{
+		name: "example",
+		data: example.Data,
+	}, {
+		name: "empty",
+		data: map[string]string{},
+	}, {
+		name: "all the errors",
+		data: map[string]string{
+			RolloutDurationKey:               "-1",
+			RolloutStepPercentKey:            "101",
+			AutocreateClusterDomainClaimsKey: "nope",
+			DomainTemplateKey:                "{{.Name}}/{{.Namespace}}.{{.Domain}}",
+			TagTemplateKey:                   "{{.Tag",
+			HTTPProtocolKey:                  "sometimes",
+			NamespaceWildcardCertSelectorKey: "[",
+			EnableMeshPodAddressabilityKey:   "true",
+			RolloutMinStepIntervalKey:        "10s",
+			DefaultIngressClassKey:           "foo",
+			"ingress.class":                  "foo",
+			InternalEncryptionKey:            "false",
+			DefaultCertificateClassKey:       "bar",
+			"certificate.class":              "baz",
+			AutoTLSKey:                       "Enabled",
+			"autoTLS":                        "Disabled",
+			TrustedProxyCIDRsKey:             "10.0.0.0/33",
+			"dataplane-trust":                "Enabled",
+			DataplaneTrustLevelKey:           "edge",
+		},
+		want: []string{
+			`failed to parse autocreate-cluster-domain-claims: strconv.ParseBool: parsing "nope": invalid syntax`,
+			"failed to parse namespace-wildcard-cert-selector: error converting YAML to JSON: yaml: line 1: did not find expected node content",
+			`failed to parse trusted-proxy-cidrs: netip.ParsePrefix("10.0.0.0/33"): prefix length out of range`,
+			"rollout-duration must be a positive integer, but was -1",
+			"rollout-step-percent must be in [1, 100] range, but was 101",
+			"domain template has url path: /bar.baz.com",
+			"template: tag-template:1: unclosed action",
+			"httpProtocol sometimes in config-network ConfigMap is not supported",
+			`auto-tls is set to "Enabled", conflicting with its legacy equivalent autoTLS set to "Disabled"; auto-tls takes precedence`,
+			`certificate-class is set to "bar", conflicting with its legacy equivalent certificate.class set to "baz"; certificate-class takes precedence`,
+			`dataplane-trust-level is set to "edge", conflicting with the deprecated dataplane-trust feature flag set to "Enabled"; dataplane-trust-level takes precedence`,
+		},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var got []string
+			for _, err := range Validate(test.data) {
+				got = append(got, err.Error())
+			}
+			if !cmp.Equal(got, test.want, cmpopts.EquateEmpty()) {
+				t.Error("Validate (-want, +got) =", cmp.Diff(test.want, got, cmpopts.EquateEmpty()))
+			}
+		})
+	}
+}
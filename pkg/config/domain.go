@@ -0,0 +1,181 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"knative.dev/networking/pkg/apis/networking"
+	cm "knative.dev/pkg/configmap"
+	"knative.dev/pkg/network"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// DomainConfigName is the name of the configmap containing the domain
+	// suffixes of the Routes.
+	DomainConfigName = "config-domain"
+
+	// VisibilityClusterLocal is the value of the networking.VisibilityLabelKey
+	// label of the Routes only reachable from within the cluster.
+	VisibilityClusterLocal = "cluster-local"
+)
+
+// LabelSelector represents a map of {key,value} pairs. A single {key,value}
+// in the map is equivalent to a requirement key == value. The requirements
+// are ANDed.
+type LabelSelector struct {
+	Selector map[string]string `json:"selector,omitempty"`
+}
+
+// specificity is the number of requirements of the selector, the most
+// specific selector matching a set of labels taking precedence.
+func (s *LabelSelector) specificity() int {
+	return len(s.Selector)
+}
+
+// Matches returns whether the given labels meet the requirements of the selector.
+func (s *LabelSelector) Matches(labels map[string]string) bool {
+	for label, expectedValue := range s.Selector {
+		if value, ok := labels[label]; !ok || expectedValue != value {
+			return false
+		}
+	}
+	return true
+}
+
+// overlaps returns whether some labels would match both s and other with
+// the same specificity, making the choice between them arbitrary.
+func (s *LabelSelector) overlaps(other *LabelSelector) bool {
+	if s.specificity() != other.specificity() {
+		return false
+	}
+	for label, value := range s.Selector {
+		if otherValue, ok := other.Selector[label]; ok && otherValue != value {
+			return false
+		}
+	}
+	return true
+}
+
+// Domain maps domain suffixes to Routes by matching the label selectors of
+// the domains against the labels of the Routes.
+type Domain struct {
+	// Domains maps from domain suffix to label selector. If a Route has
+	// labels matching a particular selector, it uses the corresponding
+	// domain. If multiple selectors match, the most specific one is chosen.
+	Domains map[string]*LabelSelector
+}
+
+// NewDomainFromMap creates a Domain from the supplied data, the keys being
+// the domain suffixes and the values their selectors, e.g.
+//
+//	example.com: |
+//	  selector:
+//	    app: prod
+//
+// A domain with an empty selector applies to the Routes not matched by any
+// other. If there isn't any, the cluster-local domain is used as the default.
+func NewDomainFromMap(data map[string]string) (*Domain, error) {
+	d, errs := parseDomain(data)
+	if len(errs) > 0 {
+		return nil, errs[0]
+	}
+	return d, nil
+}
+
+// NewDomainFromConfigMap creates a Domain from the supplied ConfigMap.
+func NewDomainFromConfigMap(configMap *corev1.ConfigMap) (*Domain, error) {
+	return NewDomainFromMap(configMap.Data)
+}
+
+// ValidateDomain checks the data of the config-domain ConfigMap. Unlike
+// NewDomainFromMap, which stops at the first error, it reports all the
+// problems found, including invalid domain suffixes and overlapping
+// selectors, which NewDomainFromMap tolerates by choosing the domain which
+// comes first alphabetically.
+func ValidateDomain(data map[string]string) []error {
+	d, errs := parseDomain(data)
+	domains := sets.StringKeySet(d.Domains).List()
+	for i, domain := range domains {
+		if _, ok := data[domain]; !ok {
+			// The default domain.
+			continue
+		}
+		if msgs := validation.IsDNS1123Subdomain(domain); len(msgs) > 0 {
+			errs = append(errs, fmt.Errorf("invalid domain %q: %s", domain, strings.Join(msgs, ", ")))
+		}
+		for _, other := range domains[i+1:] {
+			if d.Domains[domain].overlaps(d.Domains[other]) {
+				errs = append(errs, fmt.Errorf("the selectors of the domains %q and %q overlap: %v and %v",
+					domain, other, d.Domains[domain].Selector, d.Domains[other].Selector))
+			}
+		}
+	}
+	return errs
+}
+
+// parseDomain creates a Domain from the supplied data, returning all the
+// errors encountered along the way.
+func parseDomain(data map[string]string) (*Domain, []error) {
+	d := &Domain{Domains: make(map[string]*LabelSelector, len(data))}
+	var errs []error
+	hasDefault := false
+	for domain, v := range data {
+		if domain == cm.ExampleKey {
+			continue
+		}
+		selector := &LabelSelector{}
+		if err := yaml.Unmarshal([]byte(v), selector); err != nil {
+			errs = append(errs, fmt.Errorf("failed to parse the selector of domain %q: %w", domain, err))
+			continue
+		}
+		if selector.specificity() == 0 {
+			hasDefault = true
+		}
+		d.Domains[domain] = selector
+	}
+	if !hasDefault {
+		d.Domains["svc."+network.GetClusterDomainName()] = &LabelSelector{}
+	}
+	return d, errs
+}
+
+// LookupDomainForLabels returns the domain suffix of a Route with the given
+// labels. As there is always a default domain, it always returns a value.
+func (d *Domain) LookupDomainForLabels(labels map[string]string) string {
+	// The cluster-local Routes always get the cluster domain.
+	if labels[networking.VisibilityLabelKey] == VisibilityClusterLocal {
+		return "svc." + network.GetClusterDomainName()
+	}
+
+	domain, specificity := "", -1
+	for k, v := range d.Domains {
+		// Ignore the selectors not matching, or less specific.
+		if !v.Matches(labels) || v.specificity() < specificity {
+			continue
+		}
+		if v.specificity() > specificity || k < domain {
+			domain, specificity = k, v.specificity()
+		}
+	}
+	return domain
+}
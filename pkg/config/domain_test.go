@@ -0,0 +1,198 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"knative.dev/networking/pkg/apis/networking"
+	cm "knative.dev/pkg/configmap"
+)
+
+func TestNewDomain(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    map[string]string
+		want    *Domain
+		wantErr bool
+	}{{
+		name: "empty",
+		data: map[string]string{},
+		want: &Domain{Domains: map[string]*LabelSelector{
+			"svc.cluster.local": {},
+		}},
+	}, {
+		name: "example is ignored",
+		data: map[string]string{
+			cm.ExampleKey: "not: [valid",
+		},
+		want: &Domain{Domains: map[string]*LabelSelector{
+			"svc.cluster.local": {},
+		}},
+	}, {
+		name: "multiple domains",
+		data: map[string]string{
+			"example.com":   "",
+			"prod.com":      "selector:\n  app: prod",
+			"prod.team.com": "selector:\n  app: prod\n  team: foo",
+		},
+		want: &Domain{Domains: map[string]*LabelSelector{
+			"example.com":   {},
+			"prod.com":      {Selector: map[string]string{"app": "prod"}},
+			"prod.team.com": {Selector: map[string]string{"app": "prod", "team": "foo"}},
+		}},
+	}, {
+		name: "no default domain",
+		data: map[string]string{
+			"prod.com": "selector:\n  app: prod",
+		},
+		want: &Domain{Domains: map[string]*LabelSelector{
+			"prod.com":          {Selector: map[string]string{"app": "prod"}},
+			"svc.cluster.local": {},
+		}},
+	}, {
+		name: "invalid selector",
+		data: map[string]string{
+			"prod.com": "selector: [app]",
+		},
+		wantErr: true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := NewDomainFromConfigMap(&corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: DomainConfigName},
+				Data:       test.data,
+			})
+			if (err != nil) != test.wantErr {
+				t.Fatalf("NewDomainFromConfigMap() = %v, want error: %v", err, test.wantErr)
+			}
+			if !cmp.Equal(got, test.want) {
+				t.Error("NewDomainFromConfigMap (-want, +got) =", cmp.Diff(test.want, got))
+			}
+		})
+	}
+}
+
+func TestLookupDomainForLabels(t *testing.T) {
+	d, err := NewDomainFromMap(map[string]string{
+		"default.com":   "",
+		"prod.com":      "selector:\n  app: prod",
+		"prod.team.com": "selector:\n  app: prod\n  team: foo",
+		"a-staging.com": "selector:\n  env: staging",
+		"b-staging.com": "selector:\n  stage: staging",
+	})
+	if err != nil {
+		t.Fatal("NewDomainFromMap() =", err)
+	}
+
+	tests := []struct {
+		name   string
+		labels map[string]string
+		want   string
+	}{{
+		name: "no labels",
+		want: "default.com",
+	}, {
+		name:   "unmatched labels",
+		labels: map[string]string{"app": "dev"},
+		want:   "default.com",
+	}, {
+		name:   "matching selector",
+		labels: map[string]string{"app": "prod", "team": "bar"},
+		want:   "prod.com",
+	}, {
+		name:   "most specific selector",
+		labels: map[string]string{"app": "prod", "team": "foo"},
+		want:   "prod.team.com",
+	}, {
+		name:   "overlapping selectors",
+		labels: map[string]string{"env": "staging", "stage": "staging"},
+		want:   "a-staging.com",
+	}, {
+		name:   "cluster-local",
+		labels: map[string]string{"app": "prod", networking.VisibilityLabelKey: VisibilityClusterLocal},
+		want:   "svc.cluster.local",
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := d.LookupDomainForLabels(test.labels); got != test.want {
+				t.Errorf("LookupDomainForLabels() = %q, want: %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestValidateDomain(t *testing.T) {
+	tests := []struct {
+		name string
+		data map[string]string
+		want []string
+	}{{
+		name: "valid",
+		data: map[string]string{
+			"default.com":   "",
+			"prod.com":      "selector:\n  app: prod",
+			"dev.com":       "selector:\n  app: dev",
+			"prod.team.com": "selector:\n  app: prod\n  team: foo",
+		},
+	}, {
+		name: "invalid domain",
+		data: map[string]string{
+			"Default_Domain": "",
+		},
+		want: []string{
+			`invalid domain "Default_Domain": a lowercase RFC 1123 subdomain must consist of lower case alphanumeric characters, '-' or '.', and must start and end with an alphanumeric character (e.g. 'example.com', regex used for validation is '[a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*')`,
+		},
+	}, {
+		name: "multiple defaults",
+		data: map[string]string{
+			"a.com": "",
+			"b.com": "selector: {}",
+		},
+		want: []string{
+			`the selectors of the domains "a.com" and "b.com" overlap: map[] and map[]`,
+		},
+	}, {
+		name: "overlapping selectors",
+		data: map[string]string{
+			"a.com": "selector:\n  app: prod",
+			"b.com": "selector:\n  team: foo",
+			"c.com": "selector:\n  app: dev",
+		},
+		want: []string{
+			`the selectors of the domains "a.com" and "b.com" overlap: map[app:prod] and map[team:foo]`,
+			`the selectors of the domains "b.com" and "c.com" overlap: map[team:foo] and map[app:dev]`,
+		},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var got []string
+			for _, err := range ValidateDomain(test.data) {
+				got = append(got, err.Error())
+			}
+			if !cmp.Equal(got, test.want) {
+				t.Error("ValidateDomain (-want, +got) =", cmp.Diff(test.want, got))
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"os"
+	"strings"
+)
+
+// EnvPrefix is the prefix of the environment variables overriding the
+// entries of the config-network ConfigMap, e.g. KNATIVE_NETWORKING_AUTO_TLS
+// overrides auto-tls.
+const EnvPrefix = "KNATIVE_NETWORKING_"
+
+// EnvKey returns the name of the environment variable overriding the
+// config-network entry key, e.g. for the data plane components which don't
+// mount the ConfigMap.
+func EnvKey(key string) string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(key, "-", "_"))
+}
+
+// WithEnvOverrides layers the environment variables of environ, in the
+// "NAME=value" form of os.Environ, on top of the data of the config-network
+// ConfigMap, which is left untouched, and returns the result. The precedence
+// is as follows:
+//   - an environment variable named after a key with EnvKey overrides the
+//     entry of the key, and hence its legacy equivalent as well;
+//   - the environment variables set to an empty value are ignored, so that
+//     the empty defaults of a deployment don't wipe the ConfigMap out;
+//   - the entries of data are kept otherwise.
+//
+// The environment variables only override the keys of the current form, the
+// legacy keys can't be overridden.
+func WithEnvOverrides(data map[string]string, environ []string) map[string]string {
+	out := make(map[string]string, len(data))
+	for k, v := range data {
+		out[k] = v
+	}
+	for _, kv := range environ {
+		name, val, ok := strings.Cut(kv, "=")
+		if !ok || val == "" || !strings.HasPrefix(name, EnvPrefix) {
+			continue
+		}
+		key := strings.ToLower(strings.ReplaceAll(strings.TrimPrefix(name, EnvPrefix), "_", "-"))
+		if key != "" {
+			out[key] = val
+		}
+	}
+	return out
+}
+
+// NewConfigFromEnv creates a Config from the supplied data of the
+// config-network ConfigMap, overridden by the environment variables of the
+// process as per WithEnvOverrides. data may be nil, e.g. for the binaries
+// configured by the environment alone.
+func NewConfigFromEnv(data map[string]string) (*Config, error) {
+	return NewConfigFromMap(WithEnvOverrides(data, os.Environ()))
+}
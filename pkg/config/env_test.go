@@ -0,0 +1,133 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestEnvKey(t *testing.T) {
+	for key, want := range map[string]string{
+		AutoTLSKey:                 "KNATIVE_NETWORKING_AUTO_TLS",
+		H2CMaxConcurrentStreamsKey: "KNATIVE_NETWORKING_H2C_MAX_CONCURRENT_STREAMS",
+	} {
+		if got := EnvKey(key); got != want {
+			t.Errorf("EnvKey(%q) = %q, want: %q", key, got, want)
+		}
+	}
+}
+
+func TestWithEnvOverrides(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    map[string]string
+		environ []string
+		want    map[string]string
+	}{{
+		name: "no overrides",
+		data: map[string]string{AutoTLSKey: "Enabled"},
+		environ: []string{
+			"HOME=/home/nonroot",
+			"AUTO_TLS=Disabled",
+		},
+		want: map[string]string{AutoTLSKey: "Enabled"},
+	}, {
+		name: "env takes precedence",
+		data: map[string]string{
+			AutoTLSKey:             "Enabled",
+			DefaultIngressClassKey: "foo",
+		},
+		environ: []string{
+			EnvKey(AutoTLSKey) + "=Disabled",
+			EnvKey(H2CIdleTimeoutKey) + "=1m",
+		},
+		want: map[string]string{
+			AutoTLSKey:             "Disabled",
+			DefaultIngressClassKey: "foo",
+			H2CIdleTimeoutKey:      "1m",
+		},
+	}, {
+		name: "empty values are ignored",
+		data: map[string]string{DefaultIngressClassKey: "foo"},
+		environ: []string{
+			EnvKey(DefaultIngressClassKey) + "=",
+			EnvKey(AutoTLSKey),
+			EnvPrefix + "=bar",
+		},
+		want: map[string]string{DefaultIngressClassKey: "foo"},
+	}, {
+		name: "values with equal signs",
+		environ: []string{
+			EnvKey(NamespaceWildcardCertSelectorKey) + "=matchLabels: {a: b=c}",
+		},
+		want: map[string]string{NamespaceWildcardCertSelectorKey: "matchLabels: {a: b=c}"},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := WithEnvOverrides(test.data, test.environ)
+			if !cmp.Equal(test.want, got) {
+				t.Error("WithEnvOverrides (-want, +got) =", cmp.Diff(test.want, got))
+			}
+		})
+	}
+}
+
+func TestWithEnvOverridesLeavesDataAlone(t *testing.T) {
+	data := map[string]string{AutoTLSKey: "Enabled"}
+	WithEnvOverrides(data, []string{EnvKey(AutoTLSKey) + "=Disabled"})
+	if want := map[string]string{AutoTLSKey: "Enabled"}; !cmp.Equal(want, data) {
+		t.Error("The data changed (-want, +got) =", cmp.Diff(want, data))
+	}
+}
+
+func TestNewConfigFromEnv(t *testing.T) {
+	t.Setenv(EnvKey(DefaultIngressClassKey), "env-ingress")
+	t.Setenv(EnvKey(H2CIdleTimeoutKey), "2m")
+
+	// The overrides take precedence over the legacy keys as well.
+	got, err := NewConfigFromEnv(map[string]string{
+		"ingress.class":        "legacy-ingress",
+		AutoTLSKey:             "Enabled",
+		H2CIdleTimeoutKey:      "1m",
+		RolloutDurationKey:     "10",
+		DefaultIngressClassKey: "cm-ingress",
+	})
+	if err != nil {
+		t.Fatal("NewConfigFromEnv() =", err)
+	}
+	if got, want := got.DefaultIngressClass, "env-ingress"; got != want {
+		t.Errorf("DefaultIngressClass = %q, want: %q", got, want)
+	}
+	if got, want := got.H2CIdleTimeout, 2*time.Minute; got != want {
+		t.Errorf("H2CIdleTimeout = %v, want: %v", got, want)
+	}
+	if !got.AutoTLS {
+		t.Error("AutoTLS = false, want: true")
+	}
+	if got, want := got.RolloutDurationSecs, 10; got != want {
+		t.Errorf("RolloutDurationSecs = %d, want: %d", got, want)
+	}
+
+	t.Setenv(EnvKey(RolloutStepPercentKey), "1000")
+	if _, err := NewConfigFromEnv(nil); err == nil {
+		t.Error("NewConfigFromEnv() = nil, wanted an error for the malformed override")
+	}
+}
@@ -0,0 +1,175 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package features holds the typed feature flags of the networking layer,
+// parsed from the network ConfigMap.
+package features
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	cm "knative.dev/pkg/configmap"
+)
+
+// Flag is a string value which can be either Enabled, Disabled, or Allowed.
+type Flag string
+
+const (
+	// Enabled turns on an optional behavior.
+	Enabled Flag = "Enabled"
+	// Disabled turns off an optional behavior.
+	Disabled Flag = "Disabled"
+	// Allowed neither enables nor disables an optional behavior, leaving
+	// the choice to the individual resources, e.g. through annotations.
+	Allowed Flag = "Allowed"
+)
+
+// Feature is the name of a networking feature, as used as key in the
+// network ConfigMap.
+type Feature string
+
+const (
+	// SystemInternalTLS enables TLS for the traffic between the system
+	// components of the data plane, e.g. between the activator and the
+	// queue-proxies.
+	SystemInternalTLS Feature = "system-internal-tls"
+
+	// DataplaneTrust enables verifying the identity of the data plane
+	// components to each other.
+	//
+	// Deprecated: use the dataplane-trust-level configuration entry instead,
+	// which takes precedence. Enabled is equivalent to its identity level.
+	DataplaneTrust Feature = "dataplane-trust"
+
+	// EndpointSlices makes the networking layer watch EndpointSlices
+	// rather than Endpoints.
+	EndpointSlices Feature = "endpointslices"
+
+	// ExternalBackends allows the backends of the Ingresses to route the
+	// traffic to hosts outside of the cluster.
+	ExternalBackends Feature = "external-backends"
+
+	// L4Rules allows the Ingresses to expose TCP and UDP ports forwarding
+	// the traffic to their backends as is, for non-HTTP workloads.
+	L4Rules Feature = "l4-rules"
+)
+
+// Features holds the networking feature flags.
+type Features struct {
+	SystemInternalTLS Flag
+	DataplaneTrust    Flag
+	EndpointSlices    Flag
+	ExternalBackends  Flag
+	L4Rules           Flag
+}
+
+func defaultFeatures() *Features {
+	return &Features{
+		SystemInternalTLS: Disabled,
+		DataplaneTrust:    Disabled,
+		EndpointSlices:    Disabled,
+		ExternalBackends:  Disabled,
+		L4Rules:           Disabled,
+	}
+}
+
+// NewFeaturesFromMap creates a Features from the supplied data.
+func NewFeaturesFromMap(data map[string]string) (*Features, error) {
+	nf := defaultFeatures()
+	if err := cm.Parse(data,
+		asFlag(SystemInternalTLS, &nf.SystemInternalTLS),
+		asFlag(DataplaneTrust, &nf.DataplaneTrust),
+		asFlag(EndpointSlices, &nf.EndpointSlices),
+		asFlag(ExternalBackends, &nf.ExternalBackends),
+		asFlag(L4Rules, &nf.L4Rules),
+	); err != nil {
+		return nil, err
+	}
+	return nf, nil
+}
+
+// NewFeaturesFromConfigMap creates a Features from the supplied ConfigMap.
+func NewFeaturesFromConfigMap(config *corev1.ConfigMap) (*Features, error) {
+	return NewFeaturesFromMap(config.Data)
+}
+
+// Flag returns the flag of the given feature, or Disabled for unknown features.
+func (f *Features) Flag(feature Feature) Flag {
+	switch feature {
+	case SystemInternalTLS:
+		return f.SystemInternalTLS
+	case DataplaneTrust:
+		return f.DataplaneTrust
+	case EndpointSlices:
+		return f.EndpointSlices
+	case ExternalBackends:
+		return f.ExternalBackends
+	case L4Rules:
+		return f.L4Rules
+	default:
+		return Disabled
+	}
+}
+
+// asFlag parses the value at key as a Flag into the target, if it exists.
+func asFlag(key Feature, target *Flag) cm.ParseFunc {
+	return func(data map[string]string) error {
+		raw, ok := data[string(key)]
+		if !ok {
+			return nil
+		}
+		for _, flag := range []Flag{Enabled, Disabled, Allowed} {
+			if strings.EqualFold(raw, string(flag)) {
+				*target = flag
+				return nil
+			}
+		}
+		return fmt.Errorf("%s must be one of %s, %s or %s, but was %q", key, Enabled, Disabled, Allowed, raw)
+	}
+}
+
+type featuresKey struct{}
+
+// ToContext attaches the provided Features to the provided context, returning the
+// new context with the Features attached.
+func ToContext(ctx context.Context, f *Features) context.Context {
+	return context.WithValue(ctx, featuresKey{}, f)
+}
+
+// FromContext extracts the Features from the provided context, or nil if none
+// is attached.
+func FromContext(ctx context.Context) *Features {
+	f, _ := ctx.Value(featuresKey{}).(*Features)
+	return f
+}
+
+// FromContextOrDefaults is like FromContext, but returns the default Features
+// when none is attached.
+func FromContextOrDefaults(ctx context.Context) *Features {
+	if f := FromContext(ctx); f != nil {
+		return f
+	}
+	return defaultFeatures()
+}
+
+// Enabled returns true if the feature is enabled in the Features attached to
+// the context, or by default if none is attached.
+func (f Feature) Enabled(ctx context.Context) bool {
+	return FromContextOrDefaults(ctx).Flag(f) == Enabled
+}
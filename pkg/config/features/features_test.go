@@ -0,0 +1,128 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package features
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"knative.dev/networking/pkg/config"
+	. "knative.dev/pkg/configmap/testing"
+)
+
+func TestOurFeatures(t *testing.T) {
+	cm, example := ConfigMapsFromTestFile(t, config.ConfigMapName)
+
+	if _, err := NewFeaturesFromConfigMap(cm); err != nil {
+		t.Error("NewFeaturesFromConfigMap(actual) =", err)
+	}
+	if got, err := NewFeaturesFromConfigMap(example); err != nil {
+		t.Error("NewFeaturesFromConfigMap(example) =", err)
+	} else if want := defaultFeatures(); !cmp.Equal(got, want) {
+		t.Errorf("Example does not match the default features: (-want,+got):\n%s", cmp.Diff(want, got))
+	}
+}
+
+func TestNewFeaturesFromMap(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    map[string]string
+		want    *Features
+		wantErr bool
+	}{{
+		name: "defaults",
+		want: defaultFeatures(),
+	}, {
+		name: "all set",
+		data: map[string]string{
+			string(SystemInternalTLS): "Enabled",
+			string(DataplaneTrust):    "Allowed",
+			string(EndpointSlices):    "Disabled",
+			string(ExternalBackends):  "Enabled",
+			string(L4Rules):           "Allowed",
+		},
+		want: &Features{
+			SystemInternalTLS: Enabled,
+			DataplaneTrust:    Allowed,
+			EndpointSlices:    Disabled,
+			ExternalBackends:  Enabled,
+			L4Rules:           Allowed,
+		},
+	}, {
+		name: "case insensitive",
+		data: map[string]string{
+			string(EndpointSlices): "enabled",
+		},
+		want: func() *Features {
+			f := defaultFeatures()
+			f.EndpointSlices = Enabled
+			return f
+		}(),
+	}, {
+		name: "invalid flag",
+		data: map[string]string{
+			string(SystemInternalTLS): "sure",
+		},
+		wantErr: true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := NewFeaturesFromMap(test.data)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("NewFeaturesFromMap() = %v, wantErr: %v", err, test.wantErr)
+			}
+			if !cmp.Equal(got, test.want) {
+				t.Error("NewFeaturesFromMap (-want, +got):", cmp.Diff(test.want, got))
+			}
+		})
+	}
+}
+
+func TestEnabled(t *testing.T) {
+	ctx := context.Background()
+	if FromContext(ctx) != nil {
+		t.Error("FromContext() returned features for an empty context")
+	}
+	for _, f := range []Feature{SystemInternalTLS, DataplaneTrust, EndpointSlices, ExternalBackends, L4Rules} {
+		if f.Enabled(ctx) {
+			t.Errorf("%s.Enabled() = true by default", f)
+		}
+	}
+
+	ctx = ToContext(ctx, &Features{
+		SystemInternalTLS: Enabled,
+		DataplaneTrust:    Allowed,
+		EndpointSlices:    Disabled,
+		ExternalBackends:  Enabled,
+		L4Rules:           Enabled,
+	})
+	for f, want := range map[Feature]bool{
+		SystemInternalTLS: true,
+		DataplaneTrust:    false,
+		EndpointSlices:    false,
+		ExternalBackends:  true,
+		L4Rules:           true,
+		"unknown":         false,
+	} {
+		if got := f.Enabled(ctx); got != want {
+			t.Errorf("%s.Enabled() = %v, want: %v", f, got, want)
+		}
+	}
+}
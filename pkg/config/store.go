@@ -0,0 +1,150 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"reflect"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+	"knative.dev/pkg/configmap"
+)
+
+// configKeys maps the fields of Config to the keys of the config-network
+// ConfigMap setting them.
+var configKeys = map[string]string{
+	"DefaultIngressClass":           DefaultIngressClassKey,
+	"AvailableIngressClasses":       AvailableIngressClassesKey,
+	"DomainTemplate":                DomainTemplateKey,
+	"TagTemplate":                   TagTemplateKey,
+	"AutoTLS":                       AutoTLSKey,
+	"HTTPProtocol":                  HTTPProtocolKey,
+	"DefaultCertificateClass":       DefaultCertificateClassKey,
+	"NamespaceWildcardCertSelector": NamespaceWildcardCertSelectorKey,
+	"RolloutDurationSecs":           RolloutDurationKey,
+	"RolloutStepPercent":            RolloutStepPercentKey,
+	"RolloutMinStepInterval":        RolloutMinStepIntervalKey,
+	"AutocreateClusterDomainClaims": AutocreateClusterDomainClaimsKey,
+	"EnableMeshPodAddressability":   EnableMeshPodAddressabilityKey,
+	"MeshCompatibilityMode":         MeshCompatibilityModeKey,
+	"DefaultExternalScheme":         DefaultExternalSchemeKey,
+	"InternalEncryption":            InternalEncryptionKey,
+	"TrustedProxyCIDRs":             TrustedProxyCIDRsKey,
+	"H2CMaxConcurrentStreams":       H2CMaxConcurrentStreamsKey,
+	"H2CIdleTimeout":                H2CIdleTimeoutKey,
+	"H2CPingInterval":               H2CPingIntervalKey,
+	"EnableOCSPStapling":            OCSPStaplingKey,
+	"OCSPRevocationCheck":           OCSPRevocationCheckKey,
+	"DataplaneTrust":                DataplaneTrustLevelKey,
+}
+
+// Diff returns the keys of the config-network ConfigMap, e.g.
+// DomainTemplateKey, whose values differ between the old and new Configs.
+// The values are compared once parsed, so that e.g. reformatting a value or
+// switching from a legacy key to the key superseding it isn't a change, and
+// empty lists and sets are equal to unset ones.
+func Diff(old, new *Config) sets.String {
+	changes := sets.NewString()
+	o, n := reflect.ValueOf(old).Elem(), reflect.ValueOf(new).Elem()
+	for i := 0; i < o.NumField(); i++ {
+		of, nf := o.Field(i), n.Field(i)
+		if isEmpty(of) && isEmpty(nf) {
+			continue
+		}
+		if !reflect.DeepEqual(of.Interface(), nf.Interface()) {
+			changes.Insert(configKeys[o.Type().Field(i).Name])
+		}
+	}
+	return changes
+}
+
+// isEmpty returns whether v is the zero value, or an empty map or slice.
+func isEmpty(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Map, reflect.Slice:
+		return v.Len() == 0
+	default:
+		return v.IsZero()
+	}
+}
+
+// Store is a typed wrapper around configmap.UntypedStore handling the
+// config-network ConfigMap, which notifies its subscribers of the changes of
+// the Config rather than of every update of the ConfigMap.
+// +k8s:deepcopy-gen=false
+type Store struct {
+	*configmap.UntypedStore
+
+	mu          sync.Mutex
+	last        *Config
+	subscribers []func(changes sets.String, old, new *Config)
+}
+
+// NewStore creates a new store of Configs and optionally calls functions when
+// the ConfigMap is updated.
+func NewStore(logger configmap.Logger, onAfterStore ...func(name string, value interface{})) *Store {
+	store := &Store{}
+	store.UntypedStore = configmap.NewUntypedStore(
+		"network",
+		logger,
+		configmap.Constructors{
+			ConfigMapName: NewConfigFromConfigMap,
+		},
+		append([]func(string, interface{}){store.notify}, onAfterStore...)...,
+	)
+	return store
+}
+
+// Load returns a snapshot of the current Config, which the caller owns and
+// may keep without seeing the later updates.
+func (s *Store) Load() *Config {
+	return s.UntypedLoad(ConfigMapName).(*Config).DeepCopy()
+}
+
+// Subscribe calls f each time an update of the ConfigMap changes the Config,
+// with the keys whose values changed, see Diff, and snapshots of the
+// previous and new Configs. This lets e.g. the reconcilers enqueue only the
+// objects depending on the keys which changed, instead of reconciling all of
+// them on every update. f isn't called for the initial Config, which Load
+// returns, and must not block, the subscribers being called in turn as the
+// ConfigMap is stored.
+func (s *Store) Subscribe(f func(changes sets.String, old, new *Config)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.subscribers = append(s.subscribers, f)
+}
+
+// notify calls the subscribers if the Config stored changed.
+func (s *Store) notify(_ string, value interface{}) {
+	cfg := value.(*Config)
+	s.mu.Lock()
+	old := s.last
+	s.last = cfg
+	subscribers := append([]func(sets.String, *Config, *Config){}, s.subscribers...)
+	s.mu.Unlock()
+
+	if old == nil {
+		return
+	}
+	changes := Diff(old, cfg)
+	if changes.Len() == 0 {
+		return
+	}
+	for _, f := range subscribers {
+		f(sets.NewString(changes.UnsortedList()...), old.DeepCopy(), cfg.DeepCopy())
+	}
+}
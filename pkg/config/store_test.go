@@ -0,0 +1,164 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package config
+
+import (
+	"net/netip"
+	"reflect"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	logtesting "knative.dev/pkg/logging/testing"
+)
+
+func configMap(data map[string]string) *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: ConfigMapName},
+		Data:       data,
+	}
+}
+
+func TestConfigKeysCoverAllFields(t *testing.T) {
+	typ := reflect.TypeOf(Config{})
+	for i := 0; i < typ.NumField(); i++ {
+		if _, ok := configKeys[typ.Field(i).Name]; !ok {
+			t.Errorf("Config.%s has no key in configKeys", typ.Field(i).Name)
+		}
+	}
+	if got, want := len(configKeys), typ.NumField(); got != want {
+		t.Errorf("len(configKeys) = %d, want: %d", got, want)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name     string
+		old, new map[string]string
+		want     sets.String
+	}{{
+		name: "none",
+		want: sets.NewString(),
+	}, {
+		name: "same values",
+		old:  map[string]string{DomainTemplateKey: "{{.Name}}.{{.Domain}}", AvailableIngressClassesKey: "a,b"},
+		new:  map[string]string{DomainTemplateKey: "{{.Name}}.{{.Domain}}", AvailableIngressClassesKey: "b, a"},
+		want: sets.NewString(),
+	}, {
+		name: "default value set explicitly",
+		new:  map[string]string{MeshCompatibilityModeKey: string(MeshCompatibilityModeAuto)},
+		want: sets.NewString(),
+	}, {
+		name: "empty set",
+		new:  map[string]string{AvailableIngressClassesKey: ""},
+		want: sets.NewString(),
+	}, {
+		name: "changes",
+		old:  map[string]string{MeshCompatibilityModeKey: string(MeshCompatibilityModeEnabled)},
+		new: map[string]string{
+			DomainTemplateKey:        "{{.Name}}.{{.Domain}}",
+			MeshCompatibilityModeKey: string(MeshCompatibilityModeDisabled),
+			TrustedProxyCIDRsKey:     "10.0.0.0/8",
+		},
+		want: sets.NewString(DomainTemplateKey, MeshCompatibilityModeKey, TrustedProxyCIDRsKey),
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			old, err := NewConfigFromMap(test.old)
+			if err != nil {
+				t.Fatal("NewConfigFromMap(old) =", err)
+			}
+			new, err := NewConfigFromMap(test.new)
+			if err != nil {
+				t.Fatal("NewConfigFromMap(new) =", err)
+			}
+			if got := Diff(old, new); !got.Equal(test.want) {
+				t.Errorf("Diff() = %v, want: %v", got.List(), test.want.List())
+			}
+		})
+	}
+}
+
+func TestStoreImmutableConfig(t *testing.T) {
+	store := NewStore(logtesting.TestLogger(t))
+	store.OnConfigChanged(configMap(nil))
+
+	config := store.Load()
+	config.DomainTemplate = "{{.Name}}"
+	config.TrustedProxyCIDRs = append(config.TrustedProxyCIDRs, netip.MustParsePrefix("10.0.0.0/8"))
+
+	if got := store.Load(); !cmp.Equal(got, defaultConfig()) {
+		t.Error("Config is not immutable (-want, +got):", cmp.Diff(defaultConfig(), got))
+	}
+}
+
+func TestStoreSubscribe(t *testing.T) {
+	type notification struct {
+		changes  sets.String
+		old, new *Config
+	}
+	var notifications []notification
+	store := NewStore(logtesting.TestLogger(t))
+	store.Subscribe(func(changes sets.String, old, new *Config) {
+		notifications = append(notifications, notification{changes, old, new})
+	})
+
+	// The initial config isn't notified.
+	store.OnConfigChanged(configMap(nil))
+	if len(notifications) != 0 {
+		t.Fatalf("Got %d notifications for the initial config, want none", len(notifications))
+	}
+
+	// Neither are the updates not changing the config.
+	store.OnConfigChanged(configMap(map[string]string{DomainTemplateKey: DefaultDomainTemplate}))
+	if len(notifications) != 0 {
+		t.Fatalf("Got %d notifications for a no-op update, want none", len(notifications))
+	}
+
+	store.OnConfigChanged(configMap(map[string]string{
+		DomainTemplateKey:        "{{.Name}}.{{.Domain}}",
+		MeshCompatibilityModeKey: string(MeshCompatibilityModeEnabled),
+	}))
+	if len(notifications) != 1 {
+		t.Fatalf("Got %d notifications, want: 1", len(notifications))
+	}
+	n := notifications[0]
+	if want := sets.NewString(DomainTemplateKey, MeshCompatibilityModeKey); !n.changes.Equal(want) {
+		t.Errorf("Changes = %v, want: %v", n.changes.List(), want.List())
+	}
+	if !cmp.Equal(n.old, defaultConfig()) {
+		t.Error("Old config (-want, +got):", cmp.Diff(defaultConfig(), n.old))
+	}
+	if got, want := n.new.MeshCompatibilityMode, MeshCompatibilityModeEnabled; got != want {
+		t.Errorf("New MeshCompatibilityMode = %v, want: %v", got, want)
+	}
+
+	// The notified configs are snapshots.
+	n.new.DomainTemplate = "{{.Name}}"
+	if got := store.Load().DomainTemplate; got != "{{.Name}}.{{.Domain}}" {
+		t.Errorf("DomainTemplate = %q, want the stored one", got)
+	}
+
+	// Invalid updates are ignored.
+	store.OnConfigChanged(configMap(map[string]string{DomainTemplateKey: "{{.Name"}))
+	if len(notifications) != 1 {
+		t.Errorf("Got %d notifications after an invalid update, want: 1", len(notifications))
+	}
+}
@@ -0,0 +1,101 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dns
+
+import (
+	"context"
+	"fmt"
+)
+
+// Provider is the interface of the DNS providers, e.g. the API of a cloud
+// DNS service, through which the records are reconciled.
+type Provider interface {
+	// Records returns the records managed through the provider, i.e. the ones
+	// it's allowed to change and delete. Providers sharing their zones with
+	// other writers must keep track of the records they own, e.g. with TXT
+	// records like external-dns does.
+	Records(ctx context.Context) ([]Record, error)
+
+	// Apply makes the changes to the records. The changes must be applied
+	// atomically when the provider supports it.
+	Apply(ctx context.Context, changes Changes) error
+}
+
+// Changes are the changes making a set of records match the desired one.
+type Changes struct {
+	// Create are the records to create.
+	Create []Record
+	// Update are the records to update, in their desired state.
+	Update []Record
+	// Delete are the records to delete.
+	Delete []Record
+}
+
+// Empty returns whether there are no changes.
+func (c Changes) Empty() bool {
+	return len(c.Create) == 0 && len(c.Update) == 0 && len(c.Delete) == 0
+}
+
+// Plan returns the changes making the current records match the desired
+// ones. The records are matched by name and type, which must be unique
+// within each of current and desired. Each list of changes is sorted by name
+// and type.
+func Plan(current, desired []Record) Changes {
+	byKey := make(map[key]Record, len(current))
+	for _, r := range current {
+		byKey[r.key()] = r
+	}
+
+	var changes Changes
+	for _, r := range desired {
+		k := r.key()
+		cur, ok := byKey[k]
+		switch {
+		case !ok:
+			changes.Create = append(changes.Create, r)
+		case !cur.equal(r):
+			changes.Update = append(changes.Update, r)
+		}
+		delete(byKey, k)
+	}
+	for _, r := range byKey {
+		changes.Delete = append(changes.Delete, r)
+	}
+
+	sortRecords(changes.Create)
+	sortRecords(changes.Update)
+	sortRecords(changes.Delete)
+	return changes
+}
+
+// Reconcile makes the records managed through the provider match the desired
+// ones, e.g. the DesiredRecords of all the Ingresses, and returns the changes
+// applied.
+func Reconcile(ctx context.Context, p Provider, desired []Record) (Changes, error) {
+	current, err := p.Records(ctx)
+	if err != nil {
+		return Changes{}, fmt.Errorf("failed to list the DNS records: %w", err)
+	}
+	changes := Plan(current, desired)
+	if changes.Empty() {
+		return changes, nil
+	}
+	if err := p.Apply(ctx, changes); err != nil {
+		return Changes{}, fmt.Errorf("failed to apply the DNS changes: %w", err)
+	}
+	return changes, nil
+}
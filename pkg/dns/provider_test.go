@@ -0,0 +1,144 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dns
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// fakeProvider is an in-memory Provider.
+type fakeProvider struct {
+	records  []Record
+	applied  []Changes
+	listErr  error
+	applyErr error
+}
+
+func (p *fakeProvider) Records(context.Context) ([]Record, error) {
+	return p.records, p.listErr
+}
+
+func (p *fakeProvider) Apply(_ context.Context, changes Changes) error {
+	if p.applyErr != nil {
+		return p.applyErr
+	}
+	p.applied = append(p.applied, changes)
+	byKey := make(map[key]Record, len(p.records))
+	for _, r := range p.records {
+		byKey[r.key()] = r
+	}
+	for _, r := range changes.Delete {
+		delete(byKey, r.key())
+	}
+	for _, r := range append(changes.Create, changes.Update...) {
+		byKey[r.key()] = r
+	}
+	p.records = p.records[:0]
+	for _, r := range byKey {
+		p.records = append(p.records, r)
+	}
+	return nil
+}
+
+func record(name string, typ RecordType, targets ...string) Record {
+	return Record{Zone: "example.com", Name: name, Type: typ, Targets: targets, TTL: time.Minute}
+}
+
+func TestPlan(t *testing.T) {
+	current := []Record{
+		record("a.example.com", RecordTypeA, "10.0.0.1"),
+		record("b.example.com", RecordTypeA, "10.0.0.1"),
+		record("c.example.com", RecordTypeCNAME, "lb.example.net"),
+		record("d.example.com", RecordTypeA, "10.0.0.1"),
+	}
+	desired := []Record{
+		// Unchanged.
+		record("a.example.com", RecordTypeA, "10.0.0.1"),
+		// New targets.
+		record("b.example.com", RecordTypeA, "10.0.0.1", "10.0.0.2"),
+		// New type.
+		record("c.example.com", RecordTypeA, "10.0.0.1"),
+		// New TTL.
+		{Zone: "example.com", Name: "d.example.com", Type: RecordTypeA, Targets: []string{"10.0.0.1"}, TTL: time.Hour},
+		// New name.
+		record("e.example.com", RecordTypeAAAA, "2001:db8::1"),
+	}
+
+	want := Changes{
+		Create: []Record{
+			record("c.example.com", RecordTypeA, "10.0.0.1"),
+			record("e.example.com", RecordTypeAAAA, "2001:db8::1"),
+		},
+		Update: []Record{
+			record("b.example.com", RecordTypeA, "10.0.0.1", "10.0.0.2"),
+			{Zone: "example.com", Name: "d.example.com", Type: RecordTypeA, Targets: []string{"10.0.0.1"}, TTL: time.Hour},
+		},
+		Delete: []Record{
+			record("c.example.com", RecordTypeCNAME, "lb.example.net"),
+		},
+	}
+	if got := Plan(current, desired); !cmp.Equal(got, want) {
+		t.Error("Plan (-want, +got) =", cmp.Diff(want, got))
+	}
+	if got := Plan(current, current); !got.Empty() {
+		t.Errorf("Plan() = %v, want no changes", got)
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	ctx := context.Background()
+	p := &fakeProvider{records: []Record{record("a.example.com", RecordTypeA, "10.0.0.1")}}
+
+	desired := []Record{record("a.example.com", RecordTypeA, "10.0.0.2")}
+	changes, err := Reconcile(ctx, p, desired)
+	if err != nil {
+		t.Fatal("Reconcile() =", err)
+	}
+	want := Changes{Update: desired}
+	if !cmp.Equal(changes, want) {
+		t.Error("Reconcile (-want, +got) =", cmp.Diff(want, changes))
+	}
+	if got := len(p.applied); got != 1 {
+		t.Fatalf("Applied %d changes, want: 1", got)
+	}
+	if !cmp.Equal(p.records, desired) {
+		t.Error("Records (-want, +got) =", cmp.Diff(desired, p.records))
+	}
+
+	// Nothing is applied when the records are up to date.
+	p.applied = nil
+	if changes, err := Reconcile(ctx, p, desired); err != nil || !changes.Empty() {
+		t.Errorf("Reconcile() = %v, %v, want no changes", changes, err)
+	}
+	if len(p.applied) != 0 {
+		t.Errorf("Applied %v, want nothing", p.applied)
+	}
+
+	p.listErr = errors.New("list failed")
+	if _, err := Reconcile(ctx, p, nil); !errors.Is(err, p.listErr) {
+		t.Errorf("Reconcile() = %v, want: %v", err, p.listErr)
+	}
+	p.listErr, p.applyErr = nil, errors.New("apply failed")
+	if _, err := Reconcile(ctx, p, nil); !errors.Is(err, p.applyErr) {
+		t.Errorf("Reconcile() = %v, want: %v", err, p.applyErr)
+	}
+}
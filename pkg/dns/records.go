@@ -0,0 +1,194 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package dns holds utilities to publish the hosts of the Ingresses in DNS
+// zones, computing the records pointing them to the load balancers of the
+// Ingresses and reconciling them through provider-agnostic interfaces, for
+// external-dns-like integrations.
+package dns
+
+import (
+	"net/netip"
+	"sort"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+	"knative.dev/networking/pkg/apis/networking/v1alpha1"
+	"knative.dev/networking/pkg/config"
+	"knative.dev/pkg/network"
+)
+
+// RecordType is the type of a DNS record.
+type RecordType string
+
+const (
+	// RecordTypeA maps a name to IPv4 addresses.
+	RecordTypeA RecordType = "A"
+	// RecordTypeAAAA maps a name to IPv6 addresses.
+	RecordTypeAAAA RecordType = "AAAA"
+	// RecordTypeCNAME maps a name to another one.
+	RecordTypeCNAME RecordType = "CNAME"
+)
+
+// Record is a DNS record.
+type Record struct {
+	// Zone is the domain suffix of config-domain the name belongs to.
+	Zone string
+	// Name is the fully qualified name, in lower case and without trailing
+	// dot, e.g. `hello.default.example.com`.
+	Name string
+	// Type is the type of the record.
+	Type RecordType
+	// Targets are the IP addresses or the name the record points to, sorted.
+	Targets []string
+	// TTL is how long the resolvers cache the record.
+	TTL time.Duration
+}
+
+// key identifies a record in a set of records.
+type key struct {
+	name string
+	typ  RecordType
+}
+
+func (r Record) key() key {
+	return key{name: r.Name, typ: r.Type}
+}
+
+// equal returns whether r and other are the same record.
+func (r Record) equal(other Record) bool {
+	if r.key() != other.key() || r.Zone != other.Zone || r.TTL != other.TTL || len(r.Targets) != len(other.Targets) {
+		return false
+	}
+	for i := range r.Targets {
+		if r.Targets[i] != other.Targets[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// DesiredRecords returns the records publishing the hosts of ing which
+// belong to the domains of config-domain, with the given TTL, sorted by name
+// and type. Each host points to the public load balancer of the Ingress:
+//   - A and AAAA records are created for the load balancer IP addresses,
+//   - otherwise a CNAME record points to the first load balancer domain.
+//
+// The cluster-local hosts, the hosts of the domains not in config-domain and
+// the mesh-only load balancers are ignored. No record is returned until the
+// Ingress has a public load balancer.
+func DesiredRecords(ing *v1alpha1.Ingress, domain *config.Domain, ttl time.Duration) []Record {
+	if ing.Status.PublicLoadBalancer == nil {
+		return nil
+	}
+	targets := recordTargets(ing.Status.PublicLoadBalancer.Ingress)
+	if len(targets) == 0 {
+		return nil
+	}
+
+	seen := sets.NewString()
+	var records []Record
+	for _, rule := range ing.Spec.Rules {
+		if rule.Visibility == v1alpha1.IngressVisibilityClusterLocal {
+			continue
+		}
+		for _, host := range rule.Hosts {
+			name := strings.TrimSuffix(strings.ToLower(host), ".")
+			zone := zoneOf(name, domain)
+			if zone == "" || seen.Has(name) {
+				continue
+			}
+			seen.Insert(name)
+			for _, typ := range []RecordType{RecordTypeA, RecordTypeAAAA, RecordTypeCNAME} {
+				if t := targets[typ]; len(t) > 0 {
+					records = append(records, Record{Zone: zone, Name: name, Type: typ, Targets: t, TTL: ttl})
+				}
+			}
+		}
+	}
+	sortRecords(records)
+	return records
+}
+
+// recordTargets returns the targets of the records pointing to the load
+// balancer ingress points lbs, per type of record.
+func recordTargets(lbs []v1alpha1.LoadBalancerIngressStatus) map[RecordType][]string {
+	ips := map[RecordType]sets.String{
+		RecordTypeA:    sets.NewString(),
+		RecordTypeAAAA: sets.NewString(),
+	}
+	var domains []string
+	for _, lb := range lbs {
+		if lb.MeshOnly {
+			continue
+		}
+		if lb.IP != "" {
+			ip, err := netip.ParseAddr(lb.IP)
+			if err != nil {
+				continue
+			}
+			if ip = ip.Unmap(); ip.Is4() {
+				ips[RecordTypeA].Insert(ip.String())
+			} else {
+				ips[RecordTypeAAAA].Insert(ip.String())
+			}
+		}
+		if lb.Domain != "" {
+			domains = append(domains, strings.TrimSuffix(strings.ToLower(lb.Domain), "."))
+		}
+	}
+
+	targets := make(map[RecordType][]string, 2)
+	for typ, s := range ips {
+		if s.Len() > 0 {
+			targets[typ] = s.List()
+		}
+	}
+	// A CNAME record can't coexist with other records, nor have several
+	// targets.
+	if len(targets) == 0 && len(domains) > 0 {
+		sort.Strings(domains)
+		targets[RecordTypeCNAME] = domains[:1]
+	}
+	return targets
+}
+
+// zoneOf returns the longest domain suffix of config-domain name belongs
+// to, or "" if none or the cluster-local one.
+func zoneOf(name string, domain *config.Domain) string {
+	if domain == nil || strings.HasSuffix(name, "."+network.GetClusterDomainName()) {
+		return ""
+	}
+	zone := ""
+	for suffix := range domain.Domains {
+		suffix = strings.TrimSuffix(strings.ToLower(suffix), ".")
+		if (name == suffix || strings.HasSuffix(name, "."+suffix)) && len(suffix) > len(zone) {
+			zone = suffix
+		}
+	}
+	return zone
+}
+
+// sortRecords sorts records by name and type.
+func sortRecords(records []Record) {
+	sort.Slice(records, func(i, j int) bool {
+		if records[i].Name != records[j].Name {
+			return records[i].Name < records[j].Name
+		}
+		return records[i].Type < records[j].Type
+	})
+}
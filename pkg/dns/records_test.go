@@ -0,0 +1,141 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dns
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"knative.dev/networking/pkg/apis/networking/v1alpha1"
+	"knative.dev/networking/pkg/config"
+)
+
+func TestDesiredRecords(t *testing.T) {
+	domain, err := config.NewDomainFromMap(map[string]string{
+		"example.com":     "",
+		"dev.example.com": "selector:\n  env: dev",
+	})
+	if err != nil {
+		t.Fatal("NewDomainFromMap() =", err)
+	}
+	rules := []v1alpha1.IngressRule{{
+		Hosts:      []string{"hello.default.example.com", "Hello.Default.dev.example.com."},
+		Visibility: v1alpha1.IngressVisibilityExternalIP,
+	}, {
+		Hosts:      []string{"hello.default", "hello.default.svc", "hello.default.svc.cluster.local"},
+		Visibility: v1alpha1.IngressVisibilityClusterLocal,
+	}, {
+		// Not in config-domain, nor cluster-local.
+		Hosts:      []string{"hello.example.org", "hello.default.svc.cluster.local"},
+		Visibility: v1alpha1.IngressVisibilityExternalIP,
+	}}
+	const ttl = time.Minute
+
+	tests := []struct {
+		name string
+		lbs  []v1alpha1.LoadBalancerIngressStatus
+		want []Record
+	}{{
+		name: "no load balancer",
+	}, {
+		name: "IP addresses",
+		lbs: []v1alpha1.LoadBalancerIngressStatus{
+			v1alpha1.LoadBalancerIngressIP("10.0.0.2"),
+			v1alpha1.LoadBalancerIngressIP("2001:db8::1"),
+			v1alpha1.LoadBalancerIngressIP("10.0.0.1"),
+			v1alpha1.LoadBalancerIngressIP("::ffff:10.0.0.1"),
+			v1alpha1.LoadBalancerIngressDomain("lb.example.net"),
+			v1alpha1.LoadBalancerIngressDomainInternal("gateway.istio-system.svc.cluster.local"),
+			{IP: "10.0.0.3", MeshOnly: true},
+		},
+		want: []Record{{
+			Zone:    "dev.example.com",
+			Name:    "hello.default.dev.example.com",
+			Type:    RecordTypeA,
+			Targets: []string{"10.0.0.1", "10.0.0.2"},
+			TTL:     ttl,
+		}, {
+			Zone:    "dev.example.com",
+			Name:    "hello.default.dev.example.com",
+			Type:    RecordTypeAAAA,
+			Targets: []string{"2001:db8::1"},
+			TTL:     ttl,
+		}, {
+			Zone:    "example.com",
+			Name:    "hello.default.example.com",
+			Type:    RecordTypeA,
+			Targets: []string{"10.0.0.1", "10.0.0.2"},
+			TTL:     ttl,
+		}, {
+			Zone:    "example.com",
+			Name:    "hello.default.example.com",
+			Type:    RecordTypeAAAA,
+			Targets: []string{"2001:db8::1"},
+			TTL:     ttl,
+		}},
+	}, {
+		name: "domains",
+		lbs: []v1alpha1.LoadBalancerIngressStatus{
+			v1alpha1.LoadBalancerIngressDomain("lb-b.example.net"),
+			v1alpha1.LoadBalancerIngressDomain("LB-A.example.net."),
+		},
+		want: []Record{{
+			Zone:    "dev.example.com",
+			Name:    "hello.default.dev.example.com",
+			Type:    RecordTypeCNAME,
+			Targets: []string{"lb-a.example.net"},
+			TTL:     ttl,
+		}, {
+			Zone:    "example.com",
+			Name:    "hello.default.example.com",
+			Type:    RecordTypeCNAME,
+			Targets: []string{"lb-a.example.net"},
+			TTL:     ttl,
+		}},
+	}, {
+		name: "only internal load balancers",
+		lbs: []v1alpha1.LoadBalancerIngressStatus{
+			v1alpha1.LoadBalancerIngressDomainInternal("gateway.istio-system.svc.cluster.local"),
+			{IP: "10.0.0.3", MeshOnly: true},
+		},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ing := &v1alpha1.Ingress{Spec: v1alpha1.IngressSpec{Rules: rules}}
+			if test.lbs != nil {
+				ing.Status.MarkLoadBalancerReady(test.lbs, nil)
+			}
+			if got := DesiredRecords(ing, domain, ttl); !cmp.Equal(got, test.want) {
+				t.Error("DesiredRecords (-want, +got) =", cmp.Diff(test.want, got))
+			}
+		})
+	}
+}
+
+func TestDesiredRecordsNoDomain(t *testing.T) {
+	ing := &v1alpha1.Ingress{Spec: v1alpha1.IngressSpec{Rules: []v1alpha1.IngressRule{{
+		Hosts:      []string{"hello.default.example.com"},
+		Visibility: v1alpha1.IngressVisibilityExternalIP,
+	}}}}
+	ing.Status.MarkLoadBalancerReady([]v1alpha1.LoadBalancerIngressStatus{v1alpha1.LoadBalancerIngressIP("10.0.0.1")}, nil)
+
+	if got := DesiredRecords(ing, nil, time.Minute); len(got) != 0 {
+		t.Errorf("DesiredRecords() = %v, want none", got)
+	}
+}
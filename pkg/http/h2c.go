@@ -0,0 +1,94 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package http
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"time"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"knative.dev/networking/pkg/config"
+	"knative.dev/pkg/network"
+)
+
+// H2COptions tunes the HTTP/2 connections of the h2c servers and transports.
+// The zero value uses the defaults of the HTTP/2 implementation.
+type H2COptions struct {
+	// MaxConcurrentStreams is the maximum number of concurrent streams a
+	// server accepts on each connection.
+	MaxConcurrentStreams uint32
+
+	// IdleTimeout is how long connections are kept open while idle.
+	IdleTimeout time.Duration
+
+	// PingInterval is how long a transport waits without receiving frames
+	// before pinging the connection, closing it if the ping isn't answered
+	// within PingTimeout.
+	PingInterval time.Duration
+
+	// PingTimeout is how long a transport waits for the answer to a ping.
+	// Only used along with PingInterval.
+	PingTimeout time.Duration
+}
+
+// H2COptionsFromConfig returns the H2COptions set in config-network.
+func H2COptionsFromConfig(cfg *config.Config) H2COptions {
+	return H2COptions{
+		MaxConcurrentStreams: cfg.H2CMaxConcurrentStreams,
+		IdleTimeout:          cfg.H2CIdleTimeout,
+		PingInterval:         cfg.H2CPingInterval,
+	}
+}
+
+// NewH2CServer is like network.NewServer, returning a server handling both
+// HTTP/1 and h2c requests, but tuned with opts.
+func NewH2CServer(addr string, h http.Handler, opts H2COptions) *http.Server {
+	return &http.Server{
+		Addr: addr,
+		Handler: h2c.NewHandler(h, &http2.Server{
+			MaxConcurrentStreams: opts.MaxConcurrentStreams,
+			IdleTimeout:          opts.IdleTimeout,
+		}),
+		// Also applies to the HTTP/1 keep-alive connections.
+		IdleTimeout: opts.IdleTimeout,
+	}
+}
+
+// NewH2CTransport is like network.NewH2CTransport, returning a transport
+// sending h2c requests, but tuned with opts.
+func NewH2CTransport(opts H2COptions) http.RoundTripper {
+	// The HTTP/2 transport only honors idle timeouts through a HTTP/1 one.
+	t2, err := http2.ConfigureTransports(&http.Transport{IdleConnTimeout: opts.IdleTimeout})
+	if err != nil {
+		// Can't happen, the HTTP/1 transport is brand new.
+		panic(err)
+	}
+	// Dial the connections ourselves rather than only reusing the ones of the
+	// HTTP/1 transport, which never negotiates HTTP/2 without TLS.
+	t2.ConnPool = nil
+	t2.AllowHTTP = true
+	t2.DialTLS = func(netw, addr string, _ *tls.Config) (net.Conn, error) {
+		return network.DialWithBackOff(context.Background(), netw, addr)
+	}
+	t2.ReadIdleTimeout = opts.PingInterval
+	t2.PingTimeout = opts.PingTimeout
+	return t2
+}
@@ -0,0 +1,131 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package http
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/atomic"
+	"golang.org/x/net/http2"
+	"knative.dev/networking/pkg/config"
+)
+
+func newH2CTestServer(t *testing.T, h http.Handler, opts H2COptions) *httptest.Server {
+	t.Helper()
+	s := NewH2CServer("", h, opts)
+	ts := httptest.NewUnstartedServer(s.Handler)
+	ts.Config = s
+	ts.Start()
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func TestH2C(t *testing.T) {
+	ts := newH2CTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor != 2 {
+			w.WriteHeader(http.StatusHTTPVersionNotSupported)
+		}
+	}), H2COptions{IdleTimeout: time.Minute, PingInterval: time.Minute, PingTimeout: time.Second})
+
+	resp, err := (&http.Client{Transport: NewH2CTransport(H2COptions{
+		IdleTimeout:  time.Minute,
+		PingInterval: time.Minute,
+		PingTimeout:  time.Second,
+	})}).Get(ts.URL)
+	if err != nil {
+		t.Fatal("Get() =", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("StatusCode = %d, want: %d", resp.StatusCode, http.StatusOK)
+	}
+
+	// HTTP/1 is still served.
+	resp, err = http.Get(ts.URL)
+	if err != nil {
+		t.Fatal("Get() =", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusHTTPVersionNotSupported {
+		t.Errorf("HTTP/1 StatusCode = %d, want: %d", resp.StatusCode, http.StatusHTTPVersionNotSupported)
+	}
+}
+
+func TestH2CMaxConcurrentStreams(t *testing.T) {
+	var inflight, maxInflight atomic.Int32
+	ts := newH2CTestServer(t, http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		n := inflight.Inc()
+		defer inflight.Dec()
+		for {
+			m := maxInflight.Load()
+			if n <= m || maxInflight.CAS(m, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+	}), H2COptions{MaxConcurrentStreams: 1})
+
+	// A client honoring the limit of the server on a single connection.
+	client := &http.Client{Transport: &http2.Transport{
+		AllowHTTP:                  true,
+		StrictMaxConcurrentStreams: true,
+		DialTLS: func(netw, addr string, _ *tls.Config) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(context.Background(), netw, addr)
+		},
+	}}
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := client.Get(ts.URL)
+			if err != nil {
+				t.Error("Get() =", err)
+				return
+			}
+			resp.Body.Close()
+		}()
+	}
+	wg.Wait()
+
+	if got := maxInflight.Load(); got != 1 {
+		t.Errorf("Max concurrent requests = %d, want: 1", got)
+	}
+}
+
+func TestH2COptionsFromConfig(t *testing.T) {
+	got := H2COptionsFromConfig(&config.Config{
+		H2CMaxConcurrentStreams: 100,
+		H2CIdleTimeout:          time.Minute,
+		H2CPingInterval:         10 * time.Second,
+	})
+	want := H2COptions{
+		MaxConcurrentStreams: 100,
+		IdleTimeout:          time.Minute,
+		PingInterval:         10 * time.Second,
+	}
+	if got != want {
+		t.Errorf("H2COptionsFromConfig() = %+v, want: %+v", got, want)
+	}
+}
@@ -0,0 +1,422 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package header
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/http/httpguts"
+)
+
+// Forwarding headers
+const (
+	// ForwardedKey is the name of the standard header describing the proxies
+	// a request went through, as defined by RFC 7239.
+	ForwardedKey = "Forwarded"
+
+	// XForwardedForKey is the name of the de-facto standard header listing
+	// the addresses of the client and of the proxies a request went through.
+	XForwardedForKey = "X-Forwarded-For"
+
+	// XForwardedHostKey is the name of the de-facto standard header holding
+	// the Host requested by the client.
+	XForwardedHostKey = "X-Forwarded-Host"
+
+	// XForwardedProtoKey is the name of the de-facto standard header holding
+	// the protocol used by the client.
+	XForwardedProtoKey = "X-Forwarded-Proto"
+)
+
+// ForwardedElement is an element of a Forwarded header, describing a hop of
+// a request through a proxy.
+type ForwardedElement struct {
+	// For identifies the client of the proxy: an IP address, IPv6 addresses
+	// being enclosed in brackets, optionally followed by a port, `unknown`,
+	// or an obfuscated identifier starting with `_`.
+	For string
+	// By identifies the interface of the proxy the request came in through,
+	// in the same format as For.
+	By string
+	// Host is the Host header received by the proxy.
+	Host string
+	// Proto is the protocol used by the client of the proxy, e.g. `https`.
+	Proto string
+	// Extensions are the other parameters, keyed by lowercase name.
+	Extensions map[string]string
+}
+
+// ParseForwarded parses the values of Forwarded headers, e.g.
+// `r.Header.Values(ForwardedKey)`, returning their elements from the
+// nearest to the client to the nearest to the receiver. An error is
+// returned if any of the values is malformed, or has an invalid for, by,
+// host or proto parameter.
+func ParseForwarded(values []string) ([]ForwardedElement, error) {
+	var elems []ForwardedElement
+	for _, v := range values {
+		var err error
+		if elems, err = parseForwarded(v, elems); err != nil {
+			return nil, fmt.Errorf("invalid Forwarded header %q: %w", v, err)
+		}
+	}
+	return elems, nil
+}
+
+// parseForwarded appends the elements of the Forwarded header value v to
+// elems.
+func parseForwarded(v string, elems []ForwardedElement) ([]ForwardedElement, error) {
+	var (
+		elem  ForwardedElement
+		empty = true
+	)
+	for i := 0; ; {
+		i = skipWhitespace(v, i)
+		if i == len(v) || v[i] == ',' {
+			// Empty list elements are allowed and ignored.
+			if !empty {
+				elems = append(elems, elem)
+			}
+			if i == len(v) {
+				return elems, nil
+			}
+			elem, empty = ForwardedElement{}, true
+			i++
+			continue
+		}
+		if v[i] == ';' {
+			i++
+			continue
+		}
+
+		j := i
+		for j < len(v) && httpguts.IsTokenRune(rune(v[j])) {
+			j++
+		}
+		if j == i || j == len(v) || v[j] != '=' {
+			return nil, fmt.Errorf("expected a parameter at offset %d", i)
+		}
+		name := strings.ToLower(v[i:j])
+		value, next, err := parseValue(v, j+1)
+		if err != nil {
+			return nil, err
+		}
+		if err := elem.set(name, value); err != nil {
+			return nil, err
+		}
+		empty = false
+
+		i = skipWhitespace(v, next)
+		if i < len(v) && v[i] != ',' && v[i] != ';' {
+			return nil, fmt.Errorf("unexpected character %q at offset %d", v[i], i)
+		}
+	}
+}
+
+// parseValue parses the token or quoted-string value starting at offset i
+// of v, returning it along with the offset following it.
+func parseValue(v string, i int) (string, int, error) {
+	if i < len(v) && v[i] == '"' {
+		var b strings.Builder
+		for j := i + 1; j < len(v); j++ {
+			switch c := v[j]; c {
+			case '"':
+				return b.String(), j + 1, nil
+			case '\\':
+				if j++; j == len(v) {
+					return "", 0, fmt.Errorf("unterminated quoted string at offset %d", i)
+				}
+				b.WriteByte(v[j])
+			default:
+				b.WriteByte(c)
+			}
+		}
+		return "", 0, fmt.Errorf("unterminated quoted string at offset %d", i)
+	}
+	j := i
+	for j < len(v) && httpguts.IsTokenRune(rune(v[j])) {
+		j++
+	}
+	if j == i {
+		return "", 0, fmt.Errorf("expected a value at offset %d", i)
+	}
+	return v[i:j], j, nil
+}
+
+func skipWhitespace(v string, i int) int {
+	for i < len(v) && (v[i] == ' ' || v[i] == '\t') {
+		i++
+	}
+	return i
+}
+
+// set sets the parameter called name of e to value, validating it.
+func (e *ForwardedElement) set(name, value string) error {
+	var target *string
+	switch name {
+	case "for":
+		target = &e.For
+	case "by":
+		target = &e.By
+	case "host":
+		target = &e.Host
+	case "proto":
+		target = &e.Proto
+	default:
+		if _, ok := e.Extensions[name]; ok {
+			return fmt.Errorf("duplicate parameter %q", name)
+		}
+		if e.Extensions == nil {
+			e.Extensions = make(map[string]string, 1)
+		}
+		e.Extensions[name] = value
+		return nil
+	}
+	if *target != "" {
+		return fmt.Errorf("duplicate parameter %q", name)
+	}
+	if err := validateParam(name, value); err != nil {
+		return err
+	}
+	*target = value
+	return nil
+}
+
+// validateParam checks the value of the for, by, host and proto parameters.
+func validateParam(name, value string) error {
+	var ok bool
+	switch name {
+	case "for", "by":
+		ok = validNode(value)
+	case "host":
+		ok = value != "" && httpguts.ValidHostHeader(value)
+	case "proto":
+		ok = validScheme(value)
+	default:
+		ok = true
+	}
+	if !ok {
+		return fmt.Errorf("invalid %s parameter %q", name, value)
+	}
+	return nil
+}
+
+// validNode returns whether s is a node as defined by RFC 7239 section 6.
+func validNode(s string) bool {
+	name, port := s, ""
+	if strings.HasPrefix(s, "[") {
+		end := strings.IndexByte(s, ']')
+		if end < 0 {
+			return false
+		}
+		ip, err := netip.ParseAddr(s[1:end])
+		if err != nil || !ip.Is6() {
+			return false
+		}
+		name, port = "", s[end+1:]
+	} else if i := strings.IndexByte(s, ':'); i >= 0 {
+		name, port = s[:i], s[i:]
+	}
+
+	if name != "" {
+		ip, err := netip.ParseAddr(name)
+		if !(err == nil && ip.Is4()) && !strings.EqualFold(name, "unknown") && !validObfuscated(name) {
+			return false
+		}
+	}
+	if port == "" {
+		return true
+	}
+	port = port[1:]
+	if validObfuscated(port) {
+		return true
+	}
+	if port == "" || len(port) > 5 {
+		return false
+	}
+	for _, c := range port {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// validObfuscated returns whether s is an obfuscated node name or port.
+func validObfuscated(s string) bool {
+	if len(s) < 2 || s[0] != '_' {
+		return false
+	}
+	for _, c := range s[1:] {
+		if !isAlphaNum(c) && c != '.' && c != '_' && c != '-' {
+			return false
+		}
+	}
+	return true
+}
+
+// validScheme returns whether s is a URI scheme, as defined by RFC 3986.
+func validScheme(s string) bool {
+	if s == "" || !isAlpha(rune(s[0])) {
+		return false
+	}
+	for _, c := range s {
+		if !isAlphaNum(c) && c != '+' && c != '-' && c != '.' {
+			return false
+		}
+	}
+	return true
+}
+
+func isAlpha(c rune) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isAlphaNum(c rune) bool {
+	return isAlpha(c) || (c >= '0' && c <= '9')
+}
+
+// String returns e formatted as an element of a Forwarded header, its
+// parameters being in the for, by, host, proto order, followed by the
+// extensions sorted by name, and quoted when needed.
+func (e ForwardedElement) String() string {
+	var pairs []string
+	for _, p := range []struct{ name, value string }{
+		{"for", e.For}, {"by", e.By}, {"host", e.Host}, {"proto", e.Proto},
+	} {
+		if p.value != "" {
+			pairs = append(pairs, p.name+"="+quoteIfNeeded(p.value))
+		}
+	}
+	names := make([]string, 0, len(e.Extensions))
+	for name := range e.Extensions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		pairs = append(pairs, name+"="+quoteIfNeeded(e.Extensions[name]))
+	}
+	return strings.Join(pairs, ";")
+}
+
+// quoteIfNeeded returns v as is if it is a token, as a quoted-string
+// otherwise, e.g. for IPv6 addresses or ports.
+func quoteIfNeeded(v string) string {
+	if v != "" && strings.IndexFunc(v, func(r rune) bool { return !httpguts.IsTokenRune(r) }) < 0 {
+		return v
+	}
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(v); i++ {
+		if v[i] == '"' || v[i] == '\\' {
+			b.WriteByte('\\')
+		}
+		b.WriteByte(v[i])
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// FormatForwarded returns elems formatted as the value of a Forwarded
+// header.
+func FormatForwarded(elems []ForwardedElement) string {
+	s := make([]string, len(elems))
+	for i, e := range elems {
+		s[i] = e.String()
+	}
+	return strings.Join(s, ", ")
+}
+
+// ForwardedNode returns addr, an IP address optionally followed by a port,
+// e.g. the RemoteAddr of a request or an entry of X-Forwarded-For, as the
+// node of a for or by parameter, without the port. `unknown` is returned if
+// addr isn't an IP address.
+func ForwardedNode(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		addr = host
+	}
+	ip, err := netip.ParseAddr(strings.TrimSuffix(strings.TrimPrefix(addr, "["), "]"))
+	if err != nil {
+		return "unknown"
+	}
+	if ip = ip.Unmap(); ip.Is6() {
+		return "[" + ip.String() + "]"
+	}
+	return ip.String()
+}
+
+// ForwardedFromXForwarded returns the elements of a Forwarded header
+// equivalent to the X-Forwarded-For, X-Forwarded-Host and X-Forwarded-Proto
+// headers of h, if any, e.g. to carry them over when a proxy starts sending
+// the Forwarded header. The host and protocol are the ones of the request
+// of the client, and so are set on the first element.
+func ForwardedFromXForwarded(h http.Header) []ForwardedElement {
+	var elems []ForwardedElement
+	for _, v := range h.Values(XForwardedForKey) {
+		for _, hop := range strings.Split(v, ",") {
+			if hop = strings.TrimSpace(hop); hop != "" {
+				elems = append(elems, ForwardedElement{For: ForwardedNode(hop)})
+			}
+		}
+	}
+	host := firstListValue(h.Get(XForwardedHostKey))
+	proto := firstListValue(h.Get(XForwardedProtoKey))
+	if host == "" && proto == "" {
+		return elems
+	}
+	if len(elems) == 0 {
+		elems = []ForwardedElement{{}}
+	}
+	if validateParam("host", host) == nil {
+		elems[0].Host = host
+	}
+	if validateParam("proto", proto) == nil {
+		elems[0].Proto = strings.ToLower(proto)
+	}
+	return elems
+}
+
+// firstListValue returns the first value of a comma-separated list.
+func firstListValue(v string) string {
+	first, _, _ := strings.Cut(v, ",")
+	return strings.TrimSpace(first)
+}
+
+// AppendForwarded appends elem, describing the hop of the request through
+// the calling proxy, to the Forwarded header of h, merging the values of the
+// header into a single one. If h has no Forwarded header, the hops described
+// by its X-Forwarded-* headers are carried over first, see
+// ForwardedFromXForwarded, so that the chain of proxies isn't lost. h is left
+// untouched if its Forwarded header is malformed, the caller deciding
+// whether to reject the request or to drop the header.
+func AppendForwarded(h http.Header, elem ForwardedElement) error {
+	values := h.Values(ForwardedKey)
+	var elems []ForwardedElement
+	if len(values) == 0 {
+		elems = ForwardedFromXForwarded(h)
+	} else {
+		var err error
+		if elems, err = ParseForwarded(values); err != nil {
+			return err
+		}
+	}
+	h.Set(ForwardedKey, FormatForwarded(append(elems, elem)))
+	return nil
+}
@@ -0,0 +1,241 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package header
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestParseForwarded(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []string
+		want   []ForwardedElement
+	}{{
+		name: "none",
+	}, {
+		name:   "rfc examples",
+		values: []string{`for="_gazonk"`, `For="[2001:db8:cafe::17]:4711"`, `for=192.0.2.60;proto=http;by=203.0.113.43`, `for=192.0.2.43, for=198.51.100.17`},
+		want: []ForwardedElement{
+			{For: "_gazonk"},
+			{For: "[2001:db8:cafe::17]:4711"},
+			{For: "192.0.2.60", By: "203.0.113.43", Proto: "http"},
+			{For: "192.0.2.43"},
+			{For: "198.51.100.17"},
+		},
+	}, {
+		name:   "host, unknown and obfuscated port",
+		values: []string{`for=unknown;host="example.com:8080", for="10.0.0.1:_port"`},
+		want: []ForwardedElement{
+			{For: "unknown", Host: "example.com:8080"},
+			{For: "10.0.0.1:_port"},
+		},
+	}, {
+		name:   "quoted separators and escapes",
+		values: []string{`for=10.0.0.1;ext="a,b;c=\"d\"" ,, ; for=10.0.0.2`},
+		want: []ForwardedElement{
+			{For: "10.0.0.1", Extensions: map[string]string{"ext": `a,b;c="d"`}},
+			{For: "10.0.0.2"},
+		},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := ParseForwarded(test.values)
+			if err != nil {
+				t.Fatal("ParseForwarded() =", err)
+			}
+			if !cmp.Equal(got, test.want) {
+				t.Error("ParseForwarded (-want, +got) =", cmp.Diff(test.want, got))
+			}
+		})
+	}
+}
+
+func TestParseForwardedErrors(t *testing.T) {
+	for _, value := range []string{
+		`for`,
+		`for=`,
+		`=10.0.0.1`,
+		`for = 10.0.0.1`,
+		`for=10.0.0.1 by=10.0.0.2`,
+		`for="10.0.0.1`,
+		`for=10.0.0.1;for=10.0.0.2`,
+		`ext=a;EXT=b`,
+		`for=2001:db8::1`,
+		`for="[10.0.0.1]"`,
+		`for="10.0.0.1:123456"`,
+		`for=example.com`,
+		`for=_`,
+		`by="[2001:db8::1"`,
+		`proto=1http`,
+		`host="a b"`,
+	} {
+		t.Run(value, func(t *testing.T) {
+			if got, err := ParseForwarded([]string{value}); err == nil {
+				t.Errorf("ParseForwarded() = %v, wanted an error", got)
+			}
+		})
+	}
+}
+
+func TestFormatForwarded(t *testing.T) {
+	elems := []ForwardedElement{{
+		For:        "[2001:db8::1]:4711",
+		By:         "_proxy",
+		Host:       "example.com",
+		Proto:      "https",
+		Extensions: map[string]string{"z": "1", "a": "b c"},
+	}, {
+		For: "10.0.0.1",
+	}}
+	const want = `for="[2001:db8::1]:4711";by=_proxy;host=example.com;proto=https;a="b c";z=1, for=10.0.0.1`
+	got := FormatForwarded(elems)
+	if got != want {
+		t.Errorf("FormatForwarded() = %s, want: %s", got, want)
+	}
+
+	// Formatting and parsing round-trip.
+	parsed, err := ParseForwarded([]string{got})
+	if err != nil {
+		t.Fatal("ParseForwarded() =", err)
+	}
+	if !cmp.Equal(parsed, elems) {
+		t.Error("ParseForwarded (-want, +got) =", cmp.Diff(elems, parsed))
+	}
+}
+
+func TestForwardedNode(t *testing.T) {
+	for addr, want := range map[string]string{
+		"10.0.0.1":            "10.0.0.1",
+		"10.0.0.1:4711":       "10.0.0.1",
+		"2001:db8::1":         "[2001:db8::1]",
+		"[2001:db8::1]:4711":  "[2001:db8::1]",
+		"[2001:db8::1]":       "[2001:db8::1]",
+		"::ffff:10.0.0.1":     "10.0.0.1",
+		"example.com":         "unknown",
+		"":                    "unknown",
+		"not an address:4711": "unknown",
+	} {
+		if got := ForwardedNode(addr); got != want {
+			t.Errorf("ForwardedNode(%q) = %s, want: %s", addr, got, want)
+		}
+	}
+}
+
+func TestForwardedFromXForwarded(t *testing.T) {
+	tests := []struct {
+		name string
+		in   http.Header
+		want []ForwardedElement
+	}{{
+		name: "none",
+		in:   http.Header{},
+	}, {
+		name: "all",
+		in: http.Header{
+			XForwardedForKey:   {"10.0.0.1, 2001:db8::1", "garbage"},
+			XForwardedHostKey:  {"example.com"},
+			XForwardedProtoKey: {"HTTPS, http"},
+		},
+		want: []ForwardedElement{
+			{For: "10.0.0.1", Host: "example.com", Proto: "https"},
+			{For: "[2001:db8::1]"},
+			{For: "unknown"},
+		},
+	}, {
+		name: "proto only",
+		in:   http.Header{XForwardedProtoKey: {"http"}},
+		want: []ForwardedElement{{Proto: "http"}},
+	}, {
+		name: "invalid host and proto",
+		in: http.Header{
+			XForwardedForKey:   {"10.0.0.1"},
+			XForwardedHostKey:  {"a b"},
+			XForwardedProtoKey: {"1http"},
+		},
+		want: []ForwardedElement{{For: "10.0.0.1"}},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := ForwardedFromXForwarded(test.in)
+			if !cmp.Equal(got, test.want) {
+				t.Error("ForwardedFromXForwarded (-want, +got) =", cmp.Diff(test.want, got))
+			}
+		})
+	}
+}
+
+func TestAppendForwarded(t *testing.T) {
+	hop := ForwardedElement{For: "10.0.0.9", Proto: "http"}
+	tests := []struct {
+		name    string
+		in      http.Header
+		want    http.Header
+		wantErr bool
+	}{{
+		name: "first hop",
+		in:   http.Header{},
+		want: http.Header{ForwardedKey: {"for=10.0.0.9;proto=http"}},
+	}, {
+		name: "merges the values",
+		in:   http.Header{ForwardedKey: {"for=10.0.0.1", "for=10.0.0.2, for=10.0.0.3"}},
+		want: http.Header{ForwardedKey: {"for=10.0.0.1, for=10.0.0.2, for=10.0.0.3, for=10.0.0.9;proto=http"}},
+	}, {
+		name: "carries over X-Forwarded",
+		in: http.Header{
+			XForwardedForKey:   {"10.0.0.1"},
+			XForwardedProtoKey: {"https"},
+		},
+		want: http.Header{
+			XForwardedForKey:   {"10.0.0.1"},
+			XForwardedProtoKey: {"https"},
+			ForwardedKey:       {"for=10.0.0.1;proto=https, for=10.0.0.9;proto=http"},
+		},
+	}, {
+		name: "Forwarded takes precedence over X-Forwarded",
+		in: http.Header{
+			XForwardedForKey: {"10.0.0.1"},
+			ForwardedKey:     {"for=10.0.0.2"},
+		},
+		want: http.Header{
+			XForwardedForKey: {"10.0.0.1"},
+			ForwardedKey:     {"for=10.0.0.2, for=10.0.0.9;proto=http"},
+		},
+	}, {
+		name:    "malformed",
+		in:      http.Header{ForwardedKey: {"for=2001:db8::1"}},
+		want:    http.Header{ForwardedKey: {"for=2001:db8::1"}},
+		wantErr: true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := AppendForwarded(test.in, hop)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("AppendForwarded() = %v, wantErr: %v", err, test.wantErr)
+			}
+			if !cmp.Equal(test.in, test.want) {
+				t.Error("Header (-want, +got) =", cmp.Diff(test.want, test.in))
+			}
+		})
+	}
+}
@@ -42,6 +42,18 @@ const (
 
 	// ProbeValue is the value used in 'K-Network-Probe'
 	ProbeValue = "probe"
+
+	// ProbeHopsKey is the name of the header in which each hop of the
+	// networking layer records its name when forwarding a probe request.
+	// The handler answering the probe echoes it in the response, so that
+	// probers can verify which hops the probe went through.
+	ProbeHopsKey = "K-Network-Probe-Hops"
+
+	// ProbeVersionKey is the name of the header negotiating the version of
+	// the probe protocol: the probers send the latest version they support,
+	// and the handlers answer with the version they answered with. Its
+	// absence means the first version of the protocol.
+	ProbeVersionKey = "K-Network-Probe-Version"
 )
 
 const (
@@ -73,6 +85,10 @@ const (
 	// load balancers to not load balance the respective request but to
 	// send it to the request's target directly.
 	PassthroughLoadbalancingKey = "K-Passthrough-Lb"
+
+	// ClientIPKey is the name of the header holding the IP address of the
+	// client, as determined from the headers set by the trusted proxies.
+	ClientIPKey = "K-Client-Ip"
 )
 
 // User Agent Key & Values
@@ -0,0 +1,97 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package header
+
+import (
+	"net/http"
+	"net/textproto"
+	"strings"
+
+	"golang.org/x/net/http/httpguts"
+)
+
+// HopByHopHeaders are the headers which only apply to a single transport-level
+// connection and must not be forwarded by proxies, as defined by RFC 7230
+// section 6.1, plus the non-standard ones commonly found in the wild.
+var HopByHopHeaders = []string{
+	"Connection",
+	"Proxy-Connection", // Non-standard, sent by some HTTP/1.0 clients.
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Te",      // Canonicalized version of "TE".
+	"Trailer", // Not "Trailers", see RFC 7230 errata.
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// RemoveHopByHop removes the hop-by-hop headers from h, including the ones
+// listed as connection options in the Connection header.
+func RemoveHopByHop(h http.Header) {
+	for _, v := range h["Connection"] {
+		for _, opt := range strings.Split(v, ",") {
+			if opt = textproto.TrimString(opt); opt != "" {
+				h.Del(opt)
+			}
+		}
+	}
+	for _, k := range HopByHopHeaders {
+		h.Del(k)
+	}
+}
+
+// RemoveRequestHopByHop removes the hop-by-hop headers from a request about to
+// be forwarded upstream. Unlike RemoveHopByHop, it keeps
+//   - the protocol upgrade requested by the client, if any, so that e.g.
+//     websockets can be proxied, and
+//   - "TE: trailers", so that upstreams relying on trailers (e.g. gRPC) still
+//     know the client supports them.
+func RemoveRequestHopByHop(r *http.Request) {
+	upgrade := upgradeType(r.Header)
+	trailers := httpguts.HeaderValuesContainsToken(r.Header["Te"], "trailers")
+	RemoveHopByHop(r.Header)
+	if upgrade != "" {
+		r.Header.Set("Connection", "Upgrade")
+		r.Header.Set("Upgrade", upgrade)
+	}
+	if trailers {
+		r.Header.Set("Te", "trailers")
+	}
+}
+
+// RemoveResponseHopByHop removes the hop-by-hop headers from a response about
+// to be forwarded downstream. The upgrade headers of a 101 Switching Protocols
+// response are kept, as the client needs them to complete the upgrade.
+func RemoveResponseHopByHop(resp *http.Response) {
+	upgrade := ""
+	if resp.StatusCode == http.StatusSwitchingProtocols {
+		upgrade = upgradeType(resp.Header)
+	}
+	RemoveHopByHop(resp.Header)
+	if upgrade != "" {
+		resp.Header.Set("Connection", "Upgrade")
+		resp.Header.Set("Upgrade", upgrade)
+	}
+}
+
+// upgradeType returns the protocol of the upgrade carried by h, if any.
+func upgradeType(h http.Header) string {
+	if !httpguts.HeaderValuesContainsToken(h["Connection"], "Upgrade") {
+		return ""
+	}
+	return h.Get("Upgrade")
+}
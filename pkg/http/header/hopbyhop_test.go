@@ -0,0 +1,238 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package header
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestRemoveHopByHop(t *testing.T) {
+	tests := []struct {
+		name string
+		in   http.Header
+		want http.Header
+	}{{
+		name: "empty",
+		in:   http.Header{},
+		want: http.Header{},
+	}, {
+		name: "end-to-end only",
+		in: http.Header{
+			"Content-Type":  {"text/plain"},
+			"Cache-Control": {"no-cache"},
+		},
+		want: http.Header{
+			"Content-Type":  {"text/plain"},
+			"Cache-Control": {"no-cache"},
+		},
+	}, {
+		name: "all standard hop-by-hop headers",
+		in: http.Header{
+			"Connection":          {"close"},
+			"Proxy-Connection":    {"keep-alive"},
+			"Keep-Alive":          {"timeout=5"},
+			"Proxy-Authenticate":  {"Basic"},
+			"Proxy-Authorization": {"Basic Zm9vOmJhcg=="},
+			"Te":                  {"gzip"},
+			"Trailer":             {"Expires"},
+			"Transfer-Encoding":   {"chunked"},
+			"Upgrade":             {"websocket"},
+			"Content-Type":        {"text/plain"},
+		},
+		want: http.Header{
+			"Content-Type": {"text/plain"},
+		},
+	}, {
+		name: "connection options",
+		in: http.Header{
+			"Connection":   {"X-Foo, x-bar", " X-Baz ,,"},
+			"X-Foo":        {"foo"},
+			"X-Bar":        {"bar"},
+			"X-Baz":        {"baz"},
+			"X-Qux":        {"qux"},
+			"Content-Type": {"text/plain"},
+		},
+		want: http.Header{
+			"X-Qux":        {"qux"},
+			"Content-Type": {"text/plain"},
+		},
+	}, {
+		name: "connection option naming a missing header",
+		in: http.Header{
+			"Connection": {"X-Missing"},
+			"X-Qux":      {"qux"},
+		},
+		want: http.Header{
+			"X-Qux": {"qux"},
+		},
+	}, {
+		name: "upgrade is removed",
+		in: http.Header{
+			"Connection": {"Upgrade"},
+			"Upgrade":    {"websocket"},
+		},
+		want: http.Header{},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			RemoveHopByHop(test.in)
+			if !cmp.Equal(test.in, test.want) {
+				t.Error("RemoveHopByHop (-want, +got) =", cmp.Diff(test.want, test.in))
+			}
+		})
+	}
+}
+
+func TestRemoveRequestHopByHop(t *testing.T) {
+	tests := []struct {
+		name string
+		in   http.Header
+		want http.Header
+	}{{
+		name: "plain",
+		in: http.Header{
+			"Connection": {"keep-alive, X-Foo"},
+			"Keep-Alive": {"timeout=5"},
+			"X-Foo":      {"foo"},
+			"Accept":     {"*/*"},
+		},
+		want: http.Header{
+			"Accept": {"*/*"},
+		},
+	}, {
+		name: "websocket upgrade",
+		in: http.Header{
+			"Connection":        {"keep-alive, Upgrade"},
+			"Upgrade":           {"websocket"},
+			"Sec-Websocket-Key": {"dGhlIHNhbXBsZSBub25jZQ=="},
+		},
+		want: http.Header{
+			"Connection":        {"Upgrade"},
+			"Upgrade":           {"websocket"},
+			"Sec-Websocket-Key": {"dGhlIHNhbXBsZSBub25jZQ=="},
+		},
+	}, {
+		name: "upgrade token is case insensitive",
+		in: http.Header{
+			"Connection": {"upgrade"},
+			"Upgrade":    {"h2c"},
+		},
+		want: http.Header{
+			"Connection": {"Upgrade"},
+			"Upgrade":    {"h2c"},
+		},
+	}, {
+		name: "upgrade header without connection option",
+		in: http.Header{
+			"Upgrade": {"websocket"},
+		},
+		want: http.Header{},
+	}, {
+		name: "trailers",
+		in: http.Header{
+			"Te":           {"gzip, trailers"},
+			"Content-Type": {"application/grpc"},
+		},
+		want: http.Header{
+			"Te":           {"trailers"},
+			"Content-Type": {"application/grpc"},
+		},
+	}, {
+		name: "te without trailers",
+		in: http.Header{
+			"Te": {"gzip"},
+		},
+		want: http.Header{},
+	}, {
+		name: "te listed as connection option",
+		in: http.Header{
+			"Connection": {"TE"},
+			"Te":         {"trailers"},
+		},
+		want: http.Header{
+			"Te": {"trailers"},
+		},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "http://example.com", nil)
+			r.Header = test.in
+			RemoveRequestHopByHop(r)
+			if !cmp.Equal(r.Header, test.want) {
+				t.Error("RemoveRequestHopByHop (-want, +got) =", cmp.Diff(test.want, r.Header))
+			}
+		})
+	}
+}
+
+func TestRemoveResponseHopByHop(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		in     http.Header
+		want   http.Header
+	}{{
+		name:   "plain",
+		status: http.StatusOK,
+		in: http.Header{
+			"Connection":        {"close"},
+			"Transfer-Encoding": {"chunked"},
+			"Trailer":           {"Grpc-Status"},
+			"Content-Type":      {"application/grpc"},
+		},
+		want: http.Header{
+			"Content-Type": {"application/grpc"},
+		},
+	}, {
+		name:   "switching protocols",
+		status: http.StatusSwitchingProtocols,
+		in: http.Header{
+			"Connection":           {"Upgrade"},
+			"Upgrade":              {"websocket"},
+			"Sec-Websocket-Accept": {"s3pPLMBiTxaQ9kYGzzhZRbK+xOo="},
+		},
+		want: http.Header{
+			"Connection":           {"Upgrade"},
+			"Upgrade":              {"websocket"},
+			"Sec-Websocket-Accept": {"s3pPLMBiTxaQ9kYGzzhZRbK+xOo="},
+		},
+	}, {
+		name:   "upgrade on a regular response",
+		status: http.StatusOK,
+		in: http.Header{
+			"Connection": {"Upgrade"},
+			"Upgrade":    {"TLS/1.2"},
+		},
+		want: http.Header{},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resp := &http.Response{StatusCode: test.status, Header: test.in}
+			RemoveResponseHopByHop(resp)
+			if !cmp.Equal(resp.Header, test.want) {
+				t.Error("RemoveResponseHopByHop (-want, +got) =", cmp.Diff(test.want, resp.Header))
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package header
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// ValidateRouteTag checks that tag is a valid route tag, i.e. an RFC 1123
+// label, as tags are used as the first label of the hosts of the tagged
+// routes.
+func ValidateRouteTag(tag string) error {
+	if msgs := validation.IsDNS1123Label(tag); len(msgs) > 0 {
+		return fmt.Errorf("invalid route tag %q: %s", tag, strings.Join(msgs, ", "))
+	}
+	return nil
+}
+
+// SetRouteTag sets the RouteTagKey header to tag, replacing any value set
+// before. It returns an error, leaving h unmodified, if the tag is invalid.
+func SetRouteTag(h http.Header, tag string) error {
+	if err := ValidateRouteTag(tag); err != nil {
+		return err
+	}
+	h.Set(RouteTagKey, tag)
+	return nil
+}
+
+// GetRouteTag returns the route tag of the RouteTagKey header, or "" if the
+// header isn't set. The header may be repeated as long as all its values
+// are the same: conflicting or invalid values are reported as an error.
+func GetRouteTag(h http.Header) (string, error) {
+	values := h.Values(RouteTagKey)
+	if len(values) == 0 {
+		return "", nil
+	}
+	tag := strings.TrimSpace(values[0])
+	for _, v := range values[1:] {
+		if v = strings.TrimSpace(v); v != tag {
+			return "", fmt.Errorf("conflicting values of the %s header: %q and %q", RouteTagKey, tag, v)
+		}
+	}
+	if err := ValidateRouteTag(tag); err != nil {
+		return "", err
+	}
+	return tag, nil
+}
+
+// SetDefaultRoute sets the DefaultRouteKey header, recording whether the
+// request is routed via the default route rather than a tagged one.
+func SetDefaultRoute(h http.Header, isDefault bool) {
+	h.Set(DefaultRouteKey, strconv.FormatBool(isDefault))
+}
+
+// GetDefaultRoute returns the value of the DefaultRouteKey header, and
+// whether it is set. Values other than "true" and "false" are reported as
+// an error.
+func GetDefaultRoute(h http.Header) (isDefault, ok bool, err error) {
+	v := h.Get(DefaultRouteKey)
+	switch v {
+	case "":
+		return false, false, nil
+	case "true":
+		return true, true, nil
+	case "false":
+		return false, true, nil
+	default:
+		return false, false, fmt.Errorf("invalid value of the %s header: %q", DefaultRouteKey, v)
+	}
+}
+
+// StripRouteTag removes the RouteTagKey and DefaultRouteKey headers, e.g.
+// so that the values sent by a client don't conflict with the ones set by
+// the Ingress when routing the request.
+func StripRouteTag(h http.Header) {
+	h.Del(RouteTagKey)
+	h.Del(DefaultRouteKey)
+}
@@ -0,0 +1,158 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package header
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestRouteTag(t *testing.T) {
+	tests := []struct {
+		name    string
+		values  []string
+		want    string
+		wantErr bool
+	}{{
+		name: "unset",
+	}, {
+		name:   "valid",
+		values: []string{"canary"},
+		want:   "canary",
+	}, {
+		name:   "surrounding spaces",
+		values: []string{" canary "},
+		want:   "canary",
+	}, {
+		name:   "repeated",
+		values: []string{"canary", "canary"},
+		want:   "canary",
+	}, {
+		name:    "conflicting",
+		values:  []string{"canary", "stable"},
+		wantErr: true,
+	}, {
+		name:    "uppercase",
+		values:  []string{"Canary"},
+		wantErr: true,
+	}, {
+		name:    "not a label",
+		values:  []string{"canary.v2"},
+		wantErr: true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			h := http.Header{}
+			for _, v := range test.values {
+				h.Add(RouteTagKey, v)
+			}
+			got, err := GetRouteTag(h)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("GetRouteTag() = %v, want error: %v", err, test.wantErr)
+			}
+			if got != test.want {
+				t.Errorf("GetRouteTag() = %q, want: %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestSetRouteTag(t *testing.T) {
+	h := http.Header{}
+	h.Add(RouteTagKey, "stable")
+	h.Add(RouteTagKey, "old")
+	if err := SetRouteTag(h, "canary"); err != nil {
+		t.Fatal("SetRouteTag() =", err)
+	}
+	if got, want := h.Values(RouteTagKey), []string{"canary"}; len(got) != 1 || got[0] != want[0] {
+		t.Errorf("Header[%s] = %q, want: %q", RouteTagKey, got, want)
+	}
+
+	if err := SetRouteTag(h, "-canary"); err == nil {
+		t.Error("SetRouteTag(-canary) succeeded, want error")
+	}
+	if got, want := h.Get(RouteTagKey), "canary"; got != want {
+		t.Errorf("Header[%s] = %q, want: %q", RouteTagKey, got, want)
+	}
+}
+
+func TestDefaultRoute(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    bool
+		wantOK  bool
+		wantErr bool
+	}{{
+		name: "unset",
+	}, {
+		name:   "true",
+		value:  "true",
+		want:   true,
+		wantOK: true,
+	}, {
+		name:   "false",
+		value:  "false",
+		wantOK: true,
+	}, {
+		name:    "invalid",
+		value:   "yes",
+		wantErr: true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			h := http.Header{}
+			if test.value != "" {
+				h.Set(DefaultRouteKey, test.value)
+			}
+			got, ok, err := GetDefaultRoute(h)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("GetDefaultRoute() = %v, want error: %v", err, test.wantErr)
+			}
+			if got != test.want || ok != test.wantOK {
+				t.Errorf("GetDefaultRoute() = (%v, %v), want: (%v, %v)", got, ok, test.want, test.wantOK)
+			}
+		})
+	}
+
+	// SetDefaultRoute round-trips.
+	for _, isDefault := range []bool{true, false} {
+		h := http.Header{}
+		SetDefaultRoute(h, isDefault)
+		if got, ok, err := GetDefaultRoute(h); got != isDefault || !ok || err != nil {
+			t.Errorf("GetDefaultRoute() = (%v, %v, %v), want: (%v, true, nil)", got, ok, err, isDefault)
+		}
+	}
+}
+
+func TestStripRouteTag(t *testing.T) {
+	h := http.Header{}
+	h.Set(RouteTagKey, "canary")
+	SetDefaultRoute(h, false)
+	h.Set("Foo", "bar")
+
+	StripRouteTag(h)
+
+	if got, want := len(h), 1; got != want {
+		t.Errorf("len(Header) = %d, want: %d: %v", got, want, h)
+	}
+	if got, want := h.Get("Foo"), "bar"; got != want {
+		t.Errorf("Header[Foo] = %q, want: %q", got, want)
+	}
+}
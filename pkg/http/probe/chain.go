@@ -0,0 +1,92 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package probe
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"knative.dev/networking/pkg/http/header"
+)
+
+// AddHop records the given hop in the probe request, if r is a probe request.
+func AddHop(r *http.Request, hop string) {
+	if r.Header.Get(header.ProbeKey) == header.ProbeValue {
+		r.Header.Add(header.ProbeHopsKey, hop)
+	}
+}
+
+// Hops returns the hops recorded in the given headers, in order.
+func Hops(h http.Header) []string {
+	var hops []string
+	for _, v := range h.Values(header.ProbeHopsKey) {
+		// Proxies may fold multiple values into a single comma separated one.
+		for _, hop := range strings.Split(v, ",") {
+			if hop = strings.TrimSpace(hop); hop != "" {
+				hops = append(hops, hop)
+			}
+		}
+	}
+	return hops
+}
+
+// NewHopHandler wraps a HTTP handler, recording the given hop in the probe
+// requests going through it before passing them to the provided handler.
+func NewHopHandler(hop string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		AddHop(r, hop)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Chain is the list of hops, e.g. ingress, activator and queue-proxy, a probe
+// is expected to go through, in order.
+type Chain []string
+
+// Options returns the functions preparing a probe with the given hash sent
+// through the chain, and verifying that it was answered with that hash after
+// going through all the hops, e.g. to be used as a prober.Preparer and a
+// prober.Verifier. They are plain functions so that the data plane can use
+// this package without depending on the prober.
+func (c Chain) Options(hash string) (prepare func(*http.Request) *http.Request, verify func(*http.Response, []byte) (bool, error)) {
+	client := NewClient(hash)
+	return client.Prepare, func(r *http.Response, b []byte) (bool, error) {
+		if ok, err := client.Verify(r, b); !ok {
+			return ok, err
+		}
+		return c.Verify(r, b)
+	}
+}
+
+// Verify checks that the probe response went through all the hops of the chain,
+// in order. The returned error names the first hop the probe didn't reach.
+func (c Chain) Verify(r *http.Response, _ []byte) (bool, error) {
+	got := Hops(r.Header)
+	for i, hop := range c {
+		if i >= len(got) {
+			return false, fmt.Errorf("probe did not reach hop %q, went through %v", hop, got)
+		}
+		if got[i] != hop {
+			return false, fmt.Errorf("probe went through hop %q instead of %q, went through %v", got[i], hop, got)
+		}
+	}
+	if len(got) > len(c) {
+		return false, fmt.Errorf("probe went through unexpected hops %v, want %v", got[len(c):], []string(c))
+	}
+	return true, nil
+}
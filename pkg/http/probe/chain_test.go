@@ -0,0 +1,99 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package probe
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"knative.dev/networking/pkg/http/header"
+	"knative.dev/networking/pkg/prober"
+	"knative.dev/pkg/network"
+)
+
+func TestChain(t *testing.T) {
+	user := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+	queueProxy := NewHopHandler("queue-proxy", NewHandler(user))
+
+	cases := []struct {
+		name    string
+		handler http.Handler
+		chain   Chain
+		wantErr string
+	}{{
+		name:    "all hops",
+		handler: NewHopHandler("ingress", NewHopHandler("activator", queueProxy)),
+		chain:   Chain{"ingress", "activator", "queue-proxy"},
+	}, {
+		name:    "activator skipped",
+		handler: NewHopHandler("ingress", queueProxy),
+		chain:   Chain{"ingress", "activator", "queue-proxy"},
+		wantErr: `probe went through hop "queue-proxy" instead of "activator"`,
+	}, {
+		name:    "answered before queue-proxy",
+		handler: NewHopHandler("ingress", NewHopHandler("activator", NewHandler(user))),
+		chain:   Chain{"ingress", "activator", "queue-proxy"},
+		wantErr: `probe did not reach hop "queue-proxy"`,
+	}, {
+		name:    "unexpected hop",
+		handler: NewHopHandler("ingress", NewHopHandler("activator", queueProxy)),
+		chain:   Chain{"ingress", "activator"},
+		wantErr: "probe went through unexpected hops [queue-proxy]",
+	}}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ts := httptest.NewServer(c.handler)
+			defer ts.Close()
+
+			prepare, verify := c.chain.Options("hash")
+			ok, err := prober.Do(context.Background(), network.AutoTransport, ts.URL, prober.Preparer(prepare), prober.Verifier(verify))
+			if c.wantErr == "" {
+				if !ok || err != nil {
+					t.Errorf("prober.Do() = %v, %v, want: true, nil", ok, err)
+				}
+				return
+			}
+			if ok || err == nil || !strings.Contains(err.Error(), c.wantErr) {
+				t.Errorf("prober.Do() = %v, %v, want: false, an error containing %q", ok, err, c.wantErr)
+			}
+		})
+	}
+}
+
+func TestAddHopIgnoresNonProbes(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "http://example.com", nil)
+	AddHop(r, "ingress")
+	if got := r.Header.Get(header.ProbeHopsKey); got != "" {
+		t.Errorf("%s = %q, want empty", header.ProbeHopsKey, got)
+	}
+}
+
+func TestHops(t *testing.T) {
+	h := http.Header{}
+	h.Add(header.ProbeHopsKey, "ingress, activator")
+	h.Add(header.ProbeHopsKey, "queue-proxy")
+	if got, want := Hops(h), []string{"ingress", "activator", "queue-proxy"}; !cmp.Equal(got, want) {
+		t.Errorf("Hops() = %v, want: %v", got, want)
+	}
+}
@@ -19,12 +19,14 @@ package probe
 import (
 	"fmt"
 	"net/http"
+	"strconv"
 
 	"knative.dev/networking/pkg/http/header"
 )
 
 type handler struct {
 	next http.Handler
+	hash string
 }
 
 // NewHandler wraps a HTTP handler handling probing requests around the provided HTTP handler
@@ -32,10 +34,18 @@ func NewHandler(next http.Handler) http.Handler {
 	return &handler{next: next}
 }
 
+// NewHashHandler is like NewHandler, but answers the probes with the hash of
+// the configuration served, e.g. by a gateway, rather than with the hash of
+// the probe, so that the probes for other configurations fail.
+func NewHashHandler(next http.Handler, hash string) http.Handler {
+	return &handler{next: next, hash: hash}
+}
+
 // ServeHTTP handles probing requests
 func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if ph := r.Header.Get(header.ProbeKey); ph != header.ProbeValue {
 		r.Header.Del(header.HashKey)
+		r.Header.Del(header.ProbeVersionKey)
 		h.next.ServeHTTP(w, r)
 		return
 	}
@@ -45,7 +55,21 @@ func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, fmt.Sprintf("a probe request must contain a non-empty %q header", header.HashKey), http.StatusBadRequest)
 		return
 	}
+	version, err := negotiate(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if h.hash != "" {
+		hh = h.hash
+	}
 
 	w.Header().Set(header.HashKey, hh)
+	if version >= Version2 {
+		w.Header().Set(header.ProbeVersionKey, strconv.Itoa(version))
+	}
+	if hops := r.Header.Values(header.ProbeHopsKey); len(hops) > 0 {
+		w.Header()[header.ProbeHopsKey] = hops
+	}
 	w.WriteHeader(http.StatusOK)
 }
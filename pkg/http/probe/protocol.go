@@ -0,0 +1,118 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package probe
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"knative.dev/networking/pkg/http/header"
+)
+
+// The versions of the probe protocol.
+const (
+	// Version1 is the original protocol, without negotiation: the probes
+	// carry the hash of the configuration being rolled out, and are answered
+	// with a hash, which matches once the configuration is served.
+	Version1 = 1
+	// Version2 adds the negotiation of the version. The handlers answering
+	// with the hash of the configuration they serve state the version, so
+	// that the clients can tell stale configurations from other failures.
+	Version2 = 2
+	// LatestVersion is the latest version of the protocol supported by the
+	// Client and the handlers.
+	LatestVersion = Version2
+)
+
+// ErrStaleHash is the class of probes answered with the hash of another
+// configuration than the one probed for, i.e. by a gateway which hasn't
+// been configured yet. It can be matched with errors.Is.
+var ErrStaleHash = errors.New("stale configuration")
+
+// Client is the prober side of the probe protocol, probing for the
+// configuration with the given hash.
+type Client struct {
+	hash string
+}
+
+// NewClient creates a Client probing for the configuration with the hash.
+func NewClient(hash string) *Client {
+	return &Client{hash: hash}
+}
+
+// Prepare sets the headers of a probe on r. It can be used as a
+// prober.Preparer, along with Verify as a prober.Verifier.
+func (c *Client) Prepare(r *http.Request) *http.Request {
+	r.Header.Set(header.ProbeKey, header.ProbeValue)
+	r.Header.Set(header.HashKey, c.hash)
+	r.Header.Set(header.ProbeVersionKey, strconv.Itoa(LatestVersion))
+	return r
+}
+
+// Verify checks that the probe was answered with the hash of the Client,
+// in a version of the protocol the Client supports. The probes answered
+// with another hash fail with ErrStaleHash, unless they were answered with
+// the first version of the protocol which can't tell such failures apart.
+// Precede it with prober.ExpectsStatusCodes for the probes answered with
+// another status than 200 to fail with prober.ErrBadStatus.
+func (c *Client) Verify(r *http.Response, _ []byte) (bool, error) {
+	if r.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("unexpected status code: want %v, got %v", []int{http.StatusOK}, r.StatusCode)
+	}
+	version, err := parseVersion(r.Header)
+	if err != nil {
+		return false, err
+	}
+	if version > LatestVersion {
+		return false, fmt.Errorf("probe answered with unsupported protocol version %d", version)
+	}
+	if got := r.Header.Get(header.HashKey); got != c.hash {
+		if version >= Version2 {
+			return false, fmt.Errorf("%w: want hash %q, got %q", ErrStaleHash, c.hash, got)
+		}
+		return false, fmt.Errorf("unexpected header %q: want %q, got %q", header.HashKey, c.hash, got)
+	}
+	return true, nil
+}
+
+// negotiate returns the version of the protocol to answer the probe
+// request with, i.e. the latest version supported by both sides.
+func negotiate(r *http.Request) (int, error) {
+	version, err := parseVersion(r.Header)
+	if err != nil {
+		return 0, err
+	}
+	if version > LatestVersion {
+		version = LatestVersion
+	}
+	return version, nil
+}
+
+// parseVersion returns the version of the protocol in h, Version1 if none.
+func parseVersion(h http.Header) (int, error) {
+	v := h.Get(header.ProbeVersionKey)
+	if v == "" {
+		return Version1, nil
+	}
+	version, err := strconv.Atoi(v)
+	if err != nil || version < Version1 {
+		return 0, fmt.Errorf("invalid %q header: %q", header.ProbeVersionKey, v)
+	}
+	return version, nil
+}
@@ -0,0 +1,164 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package probe
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"knative.dev/networking/pkg/http/header"
+	"knative.dev/networking/pkg/prober"
+	"knative.dev/pkg/network"
+)
+
+// legacyHandler answers the probes as the handlers predating the
+// negotiation of the version of the protocol.
+func legacyHandler(hash string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(header.HashKey, hash)
+	})
+}
+
+func TestClient(t *testing.T) {
+	user := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	cases := []struct {
+		name      string
+		handler   http.Handler
+		wantErr   string
+		wantStale bool
+	}{{
+		name:    "echoing handler",
+		handler: NewHandler(user),
+	}, {
+		name:    "hash handler",
+		handler: NewHashHandler(user, "hash"),
+	}, {
+		name:      "stale hash handler",
+		handler:   NewHashHandler(user, "old-hash"),
+		wantErr:   `stale configuration: want hash "hash", got "old-hash"`,
+		wantStale: true,
+	}, {
+		name:    "legacy handler",
+		handler: legacyHandler("hash"),
+	}, {
+		name:    "stale legacy handler",
+		handler: legacyHandler("old-hash"),
+		wantErr: `unexpected header "K-Network-Hash": want "hash", got "old-hash"`,
+	}, {
+		name: "unsupported version",
+		handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set(header.HashKey, "hash")
+			w.Header().Set(header.ProbeVersionKey, "3")
+		}),
+		wantErr: "probe answered with unsupported protocol version 3",
+	}, {
+		name: "not a probe handler",
+		handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+		}),
+		wantErr: "unexpected status code: want [200], got 404",
+	}}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ts := httptest.NewServer(c.handler)
+			defer ts.Close()
+
+			client := NewClient("hash")
+			ok, err := prober.Do(context.Background(), network.AutoTransport, ts.URL, prober.Preparer(client.Prepare), prober.Verifier(client.Verify))
+			if c.wantErr == "" {
+				if !ok || err != nil {
+					t.Errorf("Do() = %v, %v, want: true, nil", ok, err)
+				}
+				return
+			}
+			if ok || err == nil || !strings.Contains(err.Error(), c.wantErr) {
+				t.Errorf("Do() = %v, %v, want: false, an error containing %q", ok, err, c.wantErr)
+			}
+			if got := errors.Is(err, ErrStaleHash); got != c.wantStale {
+				t.Errorf("errors.Is(%v, ErrStaleHash) = %v, want: %v", err, got, c.wantStale)
+			}
+		})
+	}
+}
+
+func TestHandlerNegotiation(t *testing.T) {
+	h := NewHashHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), "hash")
+
+	cases := []struct {
+		name        string
+		version     string
+		wantStatus  int
+		wantVersion string
+	}{{
+		name:       "no version",
+		wantStatus: http.StatusOK,
+	}, {
+		name:       "version 1",
+		version:    "1",
+		wantStatus: http.StatusOK,
+	}, {
+		name:        "version 2",
+		version:     "2",
+		wantStatus:  http.StatusOK,
+		wantVersion: "2",
+	}, {
+		name:        "newer client",
+		version:     "42",
+		wantStatus:  http.StatusOK,
+		wantVersion: "2",
+	}, {
+		name:       "invalid version",
+		version:    "latest",
+		wantStatus: http.StatusBadRequest,
+	}, {
+		name:       "version 0",
+		version:    "0",
+		wantStatus: http.StatusBadRequest,
+	}}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "http://example.com", nil)
+			req.Header.Set(header.ProbeKey, header.ProbeValue)
+			req.Header.Set(header.HashKey, "other-hash")
+			if c.version != "" {
+				req.Header.Set(header.ProbeVersionKey, c.version)
+			}
+			resp := httptest.NewRecorder()
+			h.ServeHTTP(resp, req)
+
+			if resp.Code != c.wantStatus {
+				t.Errorf("Status = %d, want: %d", resp.Code, c.wantStatus)
+			}
+			if c.wantStatus != http.StatusOK {
+				return
+			}
+			if got := resp.Header().Get(header.HashKey); got != "hash" {
+				t.Errorf("Header[%q] = %q, want: %q", header.HashKey, got, "hash")
+			}
+			if got := resp.Header().Get(header.ProbeVersionKey); got != c.wantVersion {
+				t.Errorf("Header[%q] = %q, want: %q", header.ProbeVersionKey, got, c.wantVersion)
+			}
+		})
+	}
+}
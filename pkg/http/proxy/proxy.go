@@ -0,0 +1,100 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxy
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+
+	"go.uber.org/zap"
+	pkgnet "knative.dev/pkg/network"
+)
+
+const (
+	// defaultMaxIdleConns is the default maximum number of idle upstream
+	// connections kept by the proxy transport.
+	defaultMaxIdleConns = 1000
+	// defaultMaxIdleConnsPerHost is the default maximum number of idle
+	// upstream connections kept per host by the proxy transport.
+	defaultMaxIdleConnsPerHost = 100
+)
+
+// Option customizes the reverse proxy built by NewReverseProxy.
+type Option func(*httputil.ReverseProxy)
+
+// WithTransport sets the transport used to reach the upstream.
+func WithTransport(rt http.RoundTripper) Option {
+	return func(p *httputil.ReverseProxy) {
+		p.Transport = rt
+	}
+}
+
+// WithH2CUpstream makes the proxy speak h2c (HTTP/2 without TLS) to the
+// upstream, regardless of the protocol of the incoming request.
+func WithH2CUpstream() Option {
+	return WithTransport(pkgnet.NewH2CTransport())
+}
+
+// WithBufferPool sets the pool providing the buffers used to copy response bodies.
+// This allows multiple proxies to share a single pool.
+func WithBufferPool(pool httputil.BufferPool) Option {
+	return func(p *httputil.ReverseProxy) {
+		p.BufferPool = pool
+	}
+}
+
+// WithErrorHandler sets the handler invoked when the upstream cannot be reached.
+func WithErrorHandler(h func(http.ResponseWriter, *http.Request, error)) Option {
+	return func(p *httputil.ReverseProxy) {
+		p.ErrorHandler = h
+	}
+}
+
+// NewReverseProxy creates a reverse proxy forwarding requests to target.
+//
+// By default, the proxy uses a pooled buffer, flushes according to FlushInterval,
+// reports upstream errors through ErrorHandler and picks HTTP/1 or h2c for the
+// upstream based on the protocol of the incoming request.
+func NewReverseProxy(target *url.URL, logger *zap.SugaredLogger, opts ...Option) *httputil.ReverseProxy {
+	p := httputil.NewSingleHostReverseProxy(target)
+	p.Transport = pkgnet.NewProxyAutoTransport(defaultMaxIdleConns, defaultMaxIdleConnsPerHost)
+	p.BufferPool = NewBufferPool()
+	p.FlushInterval = FlushInterval
+	p.ErrorHandler = ErrorHandler(logger)
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// ErrorHandler returns a handler suitable for the ErrorHandler field of
+// httputil.ReverseProxy. It logs the error and responds with a 502 Bad Gateway.
+// Requests cancelled by the client are only logged at debug level, as they are
+// expected during normal operation.
+func ErrorHandler(logger *zap.SugaredLogger) func(http.ResponseWriter, *http.Request, error) {
+	return func(w http.ResponseWriter, r *http.Request, err error) {
+		if errors.Is(err, context.Canceled) {
+			logger.Debugw("Request cancelled while reverse proxying", zap.String("url", r.URL.String()), zap.Error(err))
+		} else {
+			logger.Errorw("Error reverse proxying request", zap.String("url", r.URL.String()), zap.Error(err))
+		}
+		w.WriteHeader(http.StatusBadGateway)
+	}
+}
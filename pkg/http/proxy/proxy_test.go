@@ -0,0 +1,148 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxy
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	pkgnet "knative.dev/pkg/network"
+)
+
+func TestNewReverseProxy(t *testing.T) {
+	const body = "Hello from upstream"
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/path" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(body))
+	}))
+	defer upstream.Close()
+
+	target, err := url.Parse(upstream.URL)
+	if err != nil {
+		t.Fatal("Failed to parse URL:", err)
+	}
+	p := NewReverseProxy(target, zap.NewNop().Sugar())
+	if p.BufferPool == nil {
+		t.Error("BufferPool is not set")
+	}
+
+	resp := httptest.NewRecorder()
+	p.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "http://example.com/path", nil))
+	if got, want := resp.Code, http.StatusOK; got != want {
+		t.Errorf("StatusCode = %d, want: %d", got, want)
+	}
+	if got := resp.Body.String(); got != body {
+		t.Errorf("Body = %q, want: %q", got, body)
+	}
+}
+
+func TestNewReverseProxyH2CUpstream(t *testing.T) {
+	upstream := httptest.NewServer(h2c.NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Proto))
+	}), &http2.Server{}))
+	defer upstream.Close()
+
+	target, err := url.Parse(upstream.URL)
+	if err != nil {
+		t.Fatal("Failed to parse URL:", err)
+	}
+	p := NewReverseProxy(target, zap.NewNop().Sugar(), WithH2CUpstream())
+
+	resp := httptest.NewRecorder()
+	p.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "http://example.com", nil))
+	if got, want := resp.Body.String(), "HTTP/2.0"; got != want {
+		t.Errorf("Upstream protocol = %q, want: %q", got, want)
+	}
+}
+
+func TestNewReverseProxyOptions(t *testing.T) {
+	pool := NewBufferPool()
+	var handlerErr error
+	p := NewReverseProxy(&url.URL{Scheme: "http", Host: "example.com"}, zap.NewNop().Sugar(),
+		WithBufferPool(pool),
+		WithTransport(pkgnet.RoundTripperFunc(func(*http.Request) (*http.Response, error) {
+			return nil, errors.New("upstream is gone")
+		})),
+		WithErrorHandler(func(w http.ResponseWriter, r *http.Request, err error) {
+			handlerErr = err
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+
+	if p.BufferPool != pool {
+		t.Error("BufferPool was not overridden")
+	}
+	resp := httptest.NewRecorder()
+	p.ServeHTTP(resp, httptest.NewRequest(http.MethodGet, "http://example.com", nil))
+	if got, want := resp.Code, http.StatusServiceUnavailable; got != want {
+		t.Errorf("StatusCode = %d, want: %d", got, want)
+	}
+	if handlerErr == nil {
+		t.Error("Error handler was not invoked")
+	}
+}
+
+func TestErrorHandler(t *testing.T) {
+	for _, err := range []error{errors.New("connection refused"), context.Canceled} {
+		t.Run(err.Error(), func(t *testing.T) {
+			resp := httptest.NewRecorder()
+			ErrorHandler(zap.NewNop().Sugar())(resp, httptest.NewRequest(http.MethodGet, "http://example.com", nil), err)
+			if got, want := resp.Code, http.StatusBadGateway; got != want {
+				t.Errorf("StatusCode = %d, want: %d", got, want)
+			}
+		})
+	}
+}
+
+func BenchmarkReverseProxy(b *testing.B) {
+	body := strings.Repeat("a", 64*1024)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(body))
+	}))
+	defer upstream.Close()
+	target, err := url.Parse(upstream.URL)
+	if err != nil {
+		b.Fatal("Failed to parse URL:", err)
+	}
+	front := httptest.NewServer(NewReverseProxy(target, zap.NewNop().Sugar()))
+	defer front.Close()
+
+	client := front.Client()
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			resp, err := client.Get(front.URL)
+			if err != nil {
+				b.Fatal("Get() =", err)
+			}
+			ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+		}
+	})
+}
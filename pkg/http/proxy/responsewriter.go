@@ -0,0 +1,199 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxy
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+)
+
+// ResponseBufferSize is the size of the buffers of the BufferedResponseWriters.
+// Writes not fitting in the remaining space of the buffer flush it, and the
+// ones larger than the buffer go straight to the wrapped writer.
+const ResponseBufferSize = 4 * 1024
+
+// errNotHijacker is returned by Hijack when the wrapped writer doesn't
+// implement http.Hijacker.
+var errNotHijacker = errors.New("the response writer doesn't support hijacking")
+
+// responseWriterPool holds the released BufferedResponseWriters, along with
+// their buffers, so that serving a request doesn't allocate any.
+var responseWriterPool = sync.Pool{
+	New: func() interface{} {
+		return &BufferedResponseWriter{buf: make([]byte, 0, ResponseBufferSize)}
+	},
+}
+
+// BufferedResponseWriter is an http.ResponseWriter coalescing the small writes
+// of the handlers, e.g. the ones of the reverse proxies copying the bodies of
+// streamed responses, into writes of up to ResponseBufferSize bytes to the
+// wrapped writer.
+//
+// The status code is only sent with the first write to the wrapped writer, so
+// the headers can still be changed as long as nothing was flushed.
+// The BufferedResponseWriters come from a pool: they are acquired with
+// NewBufferedResponseWriter and must be released with Close once the handler
+// returned, after which they must not be used anymore.
+type BufferedResponseWriter struct {
+	w   http.ResponseWriter
+	buf []byte
+
+	status    int
+	committed bool
+	size      int64
+}
+
+var (
+	_ http.ResponseWriter = (*BufferedResponseWriter)(nil)
+	_ http.Flusher        = (*BufferedResponseWriter)(nil)
+	_ http.Hijacker       = (*BufferedResponseWriter)(nil)
+)
+
+// NewBufferedResponseWriter returns a BufferedResponseWriter from the pool,
+// wrapping w.
+func NewBufferedResponseWriter(w http.ResponseWriter) *BufferedResponseWriter {
+	b := responseWriterPool.Get().(*BufferedResponseWriter)
+	b.w = w
+	return b
+}
+
+// Header implements http.ResponseWriter.
+func (b *BufferedResponseWriter) Header() http.Header {
+	return b.w.Header()
+}
+
+// WriteHeader implements http.ResponseWriter. The informational status codes
+// are sent right away, the others with the first flush.
+func (b *BufferedResponseWriter) WriteHeader(code int) {
+	if b.status != 0 {
+		return
+	}
+	if code >= 100 && code < 200 && code != http.StatusSwitchingProtocols {
+		b.w.WriteHeader(code)
+		return
+	}
+	b.status = code
+}
+
+// Write implements http.ResponseWriter.
+func (b *BufferedResponseWriter) Write(p []byte) (int, error) {
+	if b.status == 0 {
+		b.WriteHeader(http.StatusOK)
+	}
+	if len(b.buf)+len(p) > cap(b.buf) {
+		if err := b.flushBuffer(); err != nil {
+			return 0, err
+		}
+		if len(p) > cap(b.buf) {
+			n, err := b.w.Write(p)
+			b.size += int64(n)
+			return n, err
+		}
+	}
+	b.buf = append(b.buf, p...)
+	b.size += int64(len(p))
+	return len(p), nil
+}
+
+// Flush implements http.Flusher. It writes the buffered data to the wrapped
+// writer, and flushes it if it implements http.Flusher. Like for the writers
+// of net/http, flushing before writing the status code sends a 200, after
+// which the status code can't be changed anymore.
+func (b *BufferedResponseWriter) Flush() {
+	if b.status == 0 {
+		b.WriteHeader(http.StatusOK)
+	}
+	if err := b.flushBuffer(); err != nil {
+		return
+	}
+	if f, ok := b.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack implements http.Hijacker. The buffered data is written to the
+// wrapped writer before its connection is hijacked.
+func (b *BufferedResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := b.w.(http.Hijacker)
+	if !ok {
+		return nil, nil, errNotHijacker
+	}
+	if err := b.flushBuffer(); err != nil {
+		return nil, nil, err
+	}
+	return h.Hijack()
+}
+
+// Unwrap returns the wrapped writer, for http.ResponseController.
+func (b *BufferedResponseWriter) Unwrap() http.ResponseWriter {
+	return b.w
+}
+
+// Status returns the status code of the response, or 0 if it wasn't written yet.
+func (b *BufferedResponseWriter) Status() int {
+	return b.status
+}
+
+// Size returns the number of bytes of the body written so far, buffered or not.
+func (b *BufferedResponseWriter) Size() int64 {
+	return b.size
+}
+
+// Buffered returns the number of bytes buffered and not flushed yet.
+func (b *BufferedResponseWriter) Buffered() int {
+	return len(b.buf)
+}
+
+// Close writes the buffered data to the wrapped writer and returns b to the
+// pool.
+func (b *BufferedResponseWriter) Close() error {
+	err := b.flushBuffer()
+	b.w = nil
+	b.buf = b.buf[:0]
+	b.status = 0
+	b.committed = false
+	b.size = 0
+	responseWriterPool.Put(b)
+	return err
+}
+
+// flushBuffer sends the status code, if not sent yet, and the buffered data to
+// the wrapped writer.
+func (b *BufferedResponseWriter) flushBuffer() error {
+	if !b.committed && b.status != 0 {
+		b.w.WriteHeader(b.status)
+		b.committed = true
+	}
+	if len(b.buf) == 0 {
+		return nil
+	}
+	n, err := b.w.Write(b.buf)
+	if err == nil && n < len(b.buf) {
+		err = io.ErrShortWrite
+	}
+	if err != nil {
+		// Keep what wasn't written, so that it isn't silently dropped.
+		b.buf = b.buf[:copy(b.buf, b.buf[n:])]
+		return err
+	}
+	b.buf = b.buf[:0]
+	return nil
+}
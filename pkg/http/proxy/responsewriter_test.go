@@ -0,0 +1,231 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package proxy
+
+import (
+	"bufio"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestBufferedResponseWriter(t *testing.T) {
+	rec := httptest.NewRecorder()
+	w := NewBufferedResponseWriter(rec)
+
+	w.WriteHeader(http.StatusCreated)
+	io.WriteString(w, "hello, ")
+	io.WriteString(w, "world")
+	// Nothing was sent yet, so the headers can still be changed.
+	w.Header().Set("Content-Type", "text/plain")
+	if rec.Body.Len() != 0 || rec.Flushed {
+		t.Errorf("Body = %q, Flushed = %v, want nothing sent before Close", rec.Body.String(), rec.Flushed)
+	}
+	if got, want := w.Buffered(), len("hello, world"); got != want {
+		t.Errorf("Buffered() = %d, want: %d", got, want)
+	}
+	if got, want := w.Status(), http.StatusCreated; got != want {
+		t.Errorf("Status() = %d, want: %d", got, want)
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal("Close() =", err)
+	}
+	if got, want := rec.Code, http.StatusCreated; got != want {
+		t.Errorf("Code = %d, want: %d", got, want)
+	}
+	if got, want := rec.Body.String(), "hello, world"; got != want {
+		t.Errorf("Body = %q, want: %q", got, want)
+	}
+	if got, want := rec.Result().Header.Get("Content-Type"), "text/plain"; got != want {
+		t.Errorf("Content-Type = %q, want: %q", got, want)
+	}
+}
+
+func TestBufferedResponseWriterStatusOnly(t *testing.T) {
+	rec := httptest.NewRecorder()
+	w := NewBufferedResponseWriter(rec)
+	w.WriteHeader(http.StatusNoContent)
+	w.WriteHeader(http.StatusInternalServerError)
+	w.Close()
+
+	if got, want := rec.Code, http.StatusNoContent; got != want {
+		t.Errorf("Code = %d, want: %d", got, want)
+	}
+}
+
+func TestBufferedResponseWriterSize(t *testing.T) {
+	rec := httptest.NewRecorder()
+	w := NewBufferedResponseWriter(rec)
+
+	small := strings.Repeat("a", ResponseBufferSize/2+1)
+	large := strings.Repeat("b", 2*ResponseBufferSize)
+	io.WriteString(w, small)
+	// Doesn't fit in the remaining space, the buffer is flushed first.
+	io.WriteString(w, small)
+	if got, want := rec.Body.Len(), len(small); got != want {
+		t.Errorf("Body.Len() = %d, want: %d", got, want)
+	}
+	// Larger than the buffer, written straight through.
+	io.WriteString(w, large)
+	if got, want := rec.Body.Len(), 2*len(small)+len(large); got != want {
+		t.Errorf("Body.Len() = %d, want: %d", got, want)
+	}
+	if got, want := w.Buffered(), 0; got != want {
+		t.Errorf("Buffered() = %d, want: %d", got, want)
+	}
+	if got, want := w.Size(), int64(2*len(small)+len(large)); got != want {
+		t.Errorf("Size() = %d, want: %d", got, want)
+	}
+	if got, want := rec.Code, http.StatusOK; got != want {
+		t.Errorf("Code = %d, want: %d", got, want)
+	}
+	w.Close()
+
+	if got, want := rec.Body.String(), small+small+large; got != want {
+		t.Error("Body doesn't match what was written")
+	}
+}
+
+func TestBufferedResponseWriterFlush(t *testing.T) {
+	rec := httptest.NewRecorder()
+	w := NewBufferedResponseWriter(rec)
+	defer w.Close()
+
+	io.WriteString(w, "event: ping\n\n")
+	w.Flush()
+	if !rec.Flushed {
+		t.Error("Flush() didn't flush the wrapped writer")
+	}
+	if got, want := rec.Body.String(), "event: ping\n\n"; got != want {
+		t.Errorf("Body = %q, want: %q", got, want)
+	}
+	if got, want := w.Buffered(), 0; got != want {
+		t.Errorf("Buffered() = %d, want: %d", got, want)
+	}
+}
+
+func TestBufferedResponseWriterFlushWithoutStatus(t *testing.T) {
+	rec := httptest.NewRecorder()
+	w := NewBufferedResponseWriter(rec)
+	defer w.Close()
+
+	w.Flush()
+	w.WriteHeader(http.StatusInternalServerError)
+	if got, want := rec.Code, http.StatusOK; got != want || !rec.Flushed {
+		t.Errorf("Code = %d, Flushed = %v, want: %d, true", got, rec.Flushed, want)
+	}
+	if got, want := w.Status(), http.StatusOK; got != want {
+		t.Errorf("Status() = %d, want: %d", got, want)
+	}
+}
+
+func TestBufferedResponseWriterHijack(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		w := NewBufferedResponseWriter(rw)
+		defer w.Close()
+
+		conn, bufrw, err := w.Hijack()
+		if err != nil {
+			t.Error("Hijack() =", err)
+			return
+		}
+		defer conn.Close()
+		bufrw.WriteString("HTTP/1.1 200 OK\r\nContent-Length: 8\r\n\r\nhijacked")
+		bufrw.Flush()
+	}))
+	defer ts.Close()
+
+	resp, err := ts.Client().Get(ts.URL)
+	if err != nil {
+		t.Fatal("Get() =", err)
+	}
+	defer resp.Body.Close()
+	if body, _ := ioutil.ReadAll(resp.Body); string(body) != "hijacked" {
+		t.Errorf("Body = %q, want: %q", body, "hijacked")
+	}
+
+	// The recorder doesn't support hijacking.
+	w := NewBufferedResponseWriter(httptest.NewRecorder())
+	defer w.Close()
+	if _, _, err := w.Hijack(); err == nil {
+		t.Error("Hijack() = nil, want an error")
+	}
+}
+
+func TestBufferedResponseWriterReuse(t *testing.T) {
+	for i := 0; i < 10; i++ {
+		rec := httptest.NewRecorder()
+		w := NewBufferedResponseWriter(rec)
+		if w.Buffered() != 0 || w.Size() != 0 || w.Status() != 0 {
+			t.Fatalf("Pooled writer not reset: Buffered() = %d, Size() = %d, Status() = %d",
+				w.Buffered(), w.Size(), w.Status())
+		}
+		io.WriteString(w, "request")
+		w.Close()
+		if got, want := rec.Body.String(), "request"; got != want {
+			t.Errorf("Body = %q, want: %q", got, want)
+		}
+	}
+}
+
+// discardResponseWriter is an allocation free http.ResponseWriter.
+type discardResponseWriter struct {
+	header http.Header
+}
+
+func (w *discardResponseWriter) Header() http.Header         { return w.header }
+func (w *discardResponseWriter) WriteHeader(int)             {}
+func (w *discardResponseWriter) Write(p []byte) (int, error) { return len(p), nil }
+
+// BenchmarkBufferedResponseWriter compares buffering the small writes of the
+// handlers with a pooled writer and with a writer allocated per request.
+func BenchmarkBufferedResponseWriter(b *testing.B) {
+	chunk := []byte(strings.Repeat("a", 128))
+	serve := func(w io.Writer) {
+		for i := 0; i < 64; i++ {
+			w.Write(chunk)
+		}
+	}
+
+	b.Run("pooled", func(b *testing.B) {
+		b.ReportAllocs()
+		b.RunParallel(func(pb *testing.PB) {
+			rw := &discardResponseWriter{header: make(http.Header)}
+			for pb.Next() {
+				w := NewBufferedResponseWriter(rw)
+				serve(w)
+				w.Close()
+			}
+		})
+	})
+
+	b.Run("unpooled", func(b *testing.B) {
+		b.ReportAllocs()
+		b.RunParallel(func(pb *testing.PB) {
+			rw := &discardResponseWriter{header: make(http.Header)}
+			for pb.Next() {
+				w := bufio.NewWriterSize(rw, ResponseBufferSize)
+				serve(w)
+				w.Flush()
+			}
+		})
+	})
+}
@@ -0,0 +1,134 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package realip determines the IP address of the client of a request
+// which went through proxies.
+package realip
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"knative.dev/networking/pkg/config"
+	"knative.dev/networking/pkg/http/header"
+)
+
+type handler struct {
+	next    http.Handler
+	trusted config.CIDRs
+}
+
+// NewHandler wraps a HTTP handler setting the header.ClientIPKey header of
+// the requests to the IP address of their client before passing them to the
+// provided HTTP handler.
+//
+// The X-Forwarded-For and Forwarded headers are only honored when set by
+// peers in the trusted CIDRs, e.g. config.Config.TrustedProxyCIDRs: walking
+// the chain of proxies from the nearest one, the client is the first address
+// which is not trusted. Any ClientIPKey header sent by the client is
+// overwritten.
+func NewHandler(next http.Handler, trusted config.CIDRs) http.Handler {
+	return &handler{next: next, trusted: trusted}
+}
+
+// ServeHTTP sets the client IP header of the request.
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if ip, ok := h.clientIP(r); ok {
+		r.Header.Set(header.ClientIPKey, ip.String())
+	} else {
+		r.Header.Del(header.ClientIPKey)
+	}
+	h.next.ServeHTTP(w, r)
+}
+
+// clientIP returns the IP address of the client of r.
+func (h *handler) clientIP(r *http.Request) (netip.Addr, bool) {
+	peer, ok := parseAddr(r.RemoteAddr)
+	if !ok {
+		return netip.Addr{}, false
+	}
+	if !h.trusted.Contains(peer) {
+		return peer, true
+	}
+
+	// The Forwarded header supersedes X-Forwarded-For, only fall back to the
+	// latter when the former is missing.
+	var hops []string
+	if values := r.Header.Values(header.ForwardedKey); len(values) > 0 {
+		hops = forwardedFor(values)
+	} else {
+		hops = xForwardedFor(r.Header.Values(header.XForwardedForKey))
+	}
+
+	ip := peer
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, ok := parseAddr(hops[i])
+		if !ok {
+			// Obfuscated or malformed, the chain can't be followed further
+			// than the last trusted proxy.
+			break
+		}
+		ip = hop
+		if !h.trusted.Contains(hop) {
+			break
+		}
+	}
+	return ip, true
+}
+
+// xForwardedFor returns the addresses listed in X-Forwarded-For headers, from
+// the farthest to the nearest hop.
+func xForwardedFor(values []string) []string {
+	var hops []string
+	for _, v := range values {
+		for _, hop := range strings.Split(v, ",") {
+			hops = append(hops, strings.TrimSpace(hop))
+		}
+	}
+	return hops
+}
+
+// forwardedFor returns the `for` parameters of the elements of Forwarded
+// headers, as defined by RFC 7239, from the farthest to the nearest hop.
+// Elements without a `for` parameter are reported as empty strings, and
+// malformed headers as a single empty hop, so that the chain isn't followed
+// further than the nearest proxy.
+func forwardedFor(values []string) []string {
+	elems, err := header.ParseForwarded(values)
+	if err != nil {
+		return []string{""}
+	}
+	hops := make([]string, 0, len(elems))
+	for _, elem := range elems {
+		hops = append(hops, elem.For)
+	}
+	return hops
+}
+
+// parseAddr parses an IP address, optionally followed by a port, IPv6
+// addresses being then enclosed in brackets.
+func parseAddr(s string) (netip.Addr, bool) {
+	if host, _, err := net.SplitHostPort(s); err == nil {
+		s = host
+	}
+	ip, err := netip.ParseAddr(strings.TrimSuffix(strings.TrimPrefix(s, "["), "]"))
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return ip.Unmap(), true
+}
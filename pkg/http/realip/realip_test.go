@@ -0,0 +1,161 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package realip
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"knative.dev/networking/pkg/config"
+	"knative.dev/networking/pkg/http/header"
+)
+
+func TestHandler(t *testing.T) {
+	trusted := config.CIDRs{
+		netip.MustParsePrefix("10.0.0.0/8"),
+		netip.MustParsePrefix("fd00::/8"),
+	}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		header     http.Header
+		want       string
+	}{{
+		name:       "direct client",
+		remoteAddr: "203.0.113.1:1234",
+		want:       "203.0.113.1",
+	}, {
+		name:       "untrusted peer",
+		remoteAddr: "203.0.113.1:1234",
+		header: http.Header{
+			"X-Forwarded-For": {"198.51.100.1"},
+		},
+		want: "203.0.113.1",
+	}, {
+		name:       "spoofed client ip header",
+		remoteAddr: "203.0.113.1:1234",
+		header: http.Header{
+			header.ClientIPKey: {"198.51.100.1"},
+		},
+		want: "203.0.113.1",
+	}, {
+		name:       "trusted peer without forwarding headers",
+		remoteAddr: "10.0.0.1:1234",
+		want:       "10.0.0.1",
+	}, {
+		name:       "trusted peer",
+		remoteAddr: "10.0.0.1:1234",
+		header: http.Header{
+			"X-Forwarded-For": {"198.51.100.1"},
+		},
+		want: "198.51.100.1",
+	}, {
+		name:       "chain of trusted proxies",
+		remoteAddr: "10.0.0.1:1234",
+		header: http.Header{
+			"X-Forwarded-For": {"192.0.2.1, 198.51.100.1", "10.0.0.3,10.0.0.2"},
+		},
+		want: "198.51.100.1",
+	}, {
+		name:       "only trusted proxies",
+		remoteAddr: "10.0.0.1:1234",
+		header: http.Header{
+			"X-Forwarded-For": {"10.0.0.3, 10.0.0.2"},
+		},
+		want: "10.0.0.3",
+	}, {
+		name:       "malformed hop",
+		remoteAddr: "10.0.0.1:1234",
+		header: http.Header{
+			"X-Forwarded-For": {"198.51.100.1, garbage, 10.0.0.2"},
+		},
+		want: "10.0.0.2",
+	}, {
+		name:       "forwarded",
+		remoteAddr: "10.0.0.1:1234",
+		header: http.Header{
+			"Forwarded": {`for=192.0.2.43, For="[2001:db8:cafe::17]:4711";proto=https, for=10.0.0.2;by=10.0.0.1`},
+		},
+		want: "2001:db8:cafe::17",
+	}, {
+		name:       "forwarded takes precedence",
+		remoteAddr: "10.0.0.1:1234",
+		header: http.Header{
+			"Forwarded":       {"for=192.0.2.43"},
+			"X-Forwarded-For": {"198.51.100.1"},
+		},
+		want: "192.0.2.43",
+	}, {
+		name:       "obfuscated forwarded identifier",
+		remoteAddr: "10.0.0.1:1234",
+		header: http.Header{
+			"Forwarded": {"for=_hidden, for=10.0.0.2"},
+		},
+		want: "10.0.0.2",
+	}, {
+		name:       "quoted forwarded parameters",
+		remoteAddr: "10.0.0.1:1234",
+		header: http.Header{
+			"Forwarded": {`for=192.0.2.43;ext="a, for=10.0.0.3; b", for=10.0.0.2`},
+		},
+		want: "192.0.2.43",
+	}, {
+		name:       "malformed forwarded",
+		remoteAddr: "10.0.0.1:1234",
+		header: http.Header{
+			"Forwarded":       {"for=192.0.2.43, garbage"},
+			"X-Forwarded-For": {"198.51.100.1"},
+		},
+		want: "10.0.0.1",
+	}, {
+		name:       "ipv6 trusted peer",
+		remoteAddr: "[fd00::1]:1234",
+		header: http.Header{
+			"X-Forwarded-For": {"::ffff:198.51.100.1"},
+		},
+		want: "198.51.100.1",
+	}, {
+		name:       "malformed remote address",
+		remoteAddr: "pipe",
+		header: http.Header{
+			header.ClientIPKey: {"198.51.100.1"},
+		},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var got string
+			h := NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = r.Header.Get(header.ClientIPKey)
+			}), trusted)
+
+			req := httptest.NewRequest(http.MethodGet, "http://example.com", nil)
+			req.RemoteAddr = test.remoteAddr
+			for k, v := range test.header {
+				req.Header[k] = v
+			}
+			h.ServeHTTP(httptest.NewRecorder(), req)
+
+			if got != test.want {
+				t.Errorf("%s = %q, want: %q", header.ClientIPKey, got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,229 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package sse holds a minimal Server-Sent Events server and client, to verify
+// that the streaming responses go through the networking layer event by event
+// rather than being buffered.
+package sse
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ContentType is the media type of the Server-Sent Events streams.
+const ContentType = "text/event-stream"
+
+// Event is a Server-Sent Event.
+type Event struct {
+	// ID is the id of the event, if any.
+	ID string
+	// Type is the type of the event, "message" if empty.
+	Type string
+	// Data is the data of the event, whose lines are sent as separate data
+	// fields.
+	Data string
+	// Retry is the reconnection time the event sets, if any.
+	Retry time.Duration
+}
+
+// Write writes the event to w, flushing it if w is an http.Flusher so that it
+// is sent right away.
+func Write(w io.Writer, e Event) error {
+	var b strings.Builder
+	if e.ID != "" {
+		fmt.Fprintf(&b, "id: %s\n", e.ID)
+	}
+	if e.Type != "" {
+		fmt.Fprintf(&b, "event: %s\n", e.Type)
+	}
+	if e.Retry > 0 {
+		fmt.Fprintf(&b, "retry: %d\n", e.Retry.Milliseconds())
+	}
+	for _, line := range strings.Split(e.Data, "\n") {
+		fmt.Fprintf(&b, "data: %s\n", line)
+	}
+	b.WriteString("\n")
+	if _, err := io.WriteString(w, b.String()); err != nil {
+		return err
+	}
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+	return nil
+}
+
+// Stream answers the request with a stream of count events, whose ids and
+// data are their index starting at 1, sent every interval, the first one
+// right away. It returns early if the request is cancelled.
+func Stream(w http.ResponseWriter, r *http.Request, count int, interval time.Duration) error {
+	w.Header().Set("Content-Type", ContentType)
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for i := 1; i <= count; i++ {
+		if i > 1 {
+			select {
+			case <-r.Context().Done():
+				return r.Context().Err()
+			case <-ticker.C:
+			}
+		}
+		id := strconv.Itoa(i)
+		if err := Write(w, Event{ID: id, Data: id}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Reader reads the events of a Server-Sent Events stream.
+type Reader struct {
+	scanner *bufio.Scanner
+}
+
+// NewReader creates a Reader reading the events from r.
+func NewReader(r io.Reader) *Reader {
+	scanner := bufio.NewScanner(r)
+	scanner.Split(scanLines)
+	return &Reader{scanner: scanner}
+}
+
+// Next returns the next event of the stream, as soon as it is complete, or
+// io.EOF once the stream ended. An incomplete event at the end of the stream
+// is discarded.
+func (r *Reader) Next() (Event, error) {
+	var (
+		e    Event
+		data []string
+		seen bool
+	)
+	for r.scanner.Scan() {
+		line := r.scanner.Text()
+		if line == "" {
+			if !seen {
+				continue
+			}
+			e.Data = strings.Join(data, "\n")
+			return e, nil
+		}
+		if strings.HasPrefix(line, ":") {
+			// A comment.
+			continue
+		}
+		field, value := line, ""
+		if i := strings.IndexByte(line, ':'); i >= 0 {
+			field, value = line[:i], strings.TrimPrefix(line[i+1:], " ")
+		}
+		switch field {
+		case "id":
+			e.ID = value
+		case "event":
+			e.Type = value
+		case "data":
+			data = append(data, value)
+		case "retry":
+			if ms, err := strconv.Atoi(value); err == nil {
+				e.Retry = time.Duration(ms) * time.Millisecond
+			}
+		default:
+			// Unknown fields are ignored.
+			continue
+		}
+		seen = true
+	}
+	if err := r.scanner.Err(); err != nil {
+		return Event{}, err
+	}
+	return Event{}, io.EOF
+}
+
+// scanLines splits the stream into lines ending with either CRLF, LF or CR,
+// as the Server-Sent Events streams do.
+func scanLines(data []byte, atEOF bool) (int, []byte, error) {
+	for i, c := range data {
+		switch c {
+		case '\n':
+			return i + 1, data[:i], nil
+		case '\r':
+			if i+1 < len(data) {
+				if data[i+1] == '\n' {
+					return i + 2, data[:i], nil
+				}
+				return i + 1, data[:i], nil
+			}
+			if atEOF {
+				return i + 1, data[:i], nil
+			}
+			// Wait for the next byte, which may be the LF of a CRLF.
+			return 0, nil, nil
+		}
+	}
+	if atEOF && len(data) > 0 {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
+
+// Received is an event along with the time it was received.
+type Received struct {
+	Event
+	At time.Time
+}
+
+// ReadAll reads the events of the stream until it ends, recording the time
+// each of them was received.
+func ReadAll(r io.Reader) ([]Received, error) {
+	reader := NewReader(r)
+	var events []Received
+	for {
+		e, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			return events, nil
+		}
+		if err != nil {
+			return events, err
+		}
+		events = append(events, Received{Event: e, At: time.Now()})
+	}
+}
+
+// VerifyStreamed checks that the events, sent every interval as Stream does,
+// were received one by one rather than buffered, i.e. that each of them was
+// received at least half of its delay since the first one after it, which
+// leaves room for the jitter of the network while catching the responses
+// buffered in full or in chunks.
+func VerifyStreamed(events []Received, interval time.Duration) error {
+	if len(events) == 0 {
+		return errors.New("no event was received")
+	}
+	first := events[0].At
+	for i, e := range events[1:] {
+		want := time.Duration(i+1) * interval / 2
+		if got := e.At.Sub(first); got < want {
+			return fmt.Errorf("event %d was received %v after the first one, want at least %v: the response is buffered", i+2, got, want)
+		}
+	}
+	return nil
+}
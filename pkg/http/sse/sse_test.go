@@ -0,0 +1,159 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sse
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestReader(t *testing.T) {
+	const stream = ": a comment\n" +
+		"id: 1\nevent: greeting\ndata: hello\ndata:  world\n\n" +
+		"retry: 1500\r\ndata\r\n\r\n" +
+		"unknown: field\n\n" +
+		"data:no space\rid: 3\r\r" +
+		"data: incomplete"
+
+	want := []Event{
+		{ID: "1", Type: "greeting", Data: "hello\n world"},
+		{Retry: 1500 * time.Millisecond},
+		{ID: "3", Data: "no space"},
+	}
+	var got []Event
+	r := NewReader(strings.NewReader(stream))
+	for {
+		e, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatal("Next() =", err)
+		}
+		got = append(got, e)
+	}
+	if !cmp.Equal(got, want) {
+		t.Error("Events (-want, +got):", cmp.Diff(want, got))
+	}
+}
+
+func TestWriteRead(t *testing.T) {
+	events := []Event{
+		{Data: "single"},
+		{ID: "2", Type: "update", Data: "multi\nline", Retry: time.Second},
+	}
+	var b bytes.Buffer
+	for _, e := range events {
+		if err := Write(&b, e); err != nil {
+			t.Fatal("Write() =", err)
+		}
+	}
+	received, err := ReadAll(&b)
+	if err != nil {
+		t.Fatal("ReadAll() =", err)
+	}
+	got := make([]Event, 0, len(received))
+	for _, r := range received {
+		got = append(got, r.Event)
+	}
+	if !cmp.Equal(got, events) {
+		t.Error("Events (-want, +got):", cmp.Diff(events, got))
+	}
+}
+
+// bufferingWriter buffers the whole response, like a proxy buffering the
+// responses would.
+type bufferingWriter struct {
+	http.ResponseWriter
+	buf bytes.Buffer
+}
+
+func (w *bufferingWriter) Write(b []byte) (int, error) {
+	return w.buf.Write(b)
+}
+
+func TestStream(t *testing.T) {
+	const (
+		count    = 4
+		interval = 50 * time.Millisecond
+	)
+	tests := []struct {
+		name     string
+		buffered bool
+	}{{
+		name: "streamed",
+	}, {
+		name:     "buffered",
+		buffered: true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if !test.buffered {
+					Stream(w, r, count, interval)
+					return
+				}
+				bw := &bufferingWriter{ResponseWriter: w}
+				Stream(bw, r, count, interval)
+				w.Write(bw.buf.Bytes())
+			}))
+			defer ts.Close()
+
+			resp, err := ts.Client().Get(ts.URL)
+			if err != nil {
+				t.Fatal("Get() =", err)
+			}
+			defer resp.Body.Close()
+			if got := resp.Header.Get("Content-Type"); got != ContentType {
+				t.Errorf("Content-Type = %q, want: %q", got, ContentType)
+			}
+			events, err := ReadAll(resp.Body)
+			if err != nil {
+				t.Fatal("ReadAll() =", err)
+			}
+			if len(events) != count {
+				t.Fatalf("Got %d events, want: %d", len(events), count)
+			}
+			for i, e := range events {
+				if want := string(rune('1' + i)); e.ID != want || e.Data != want {
+					t.Errorf("Event %d = %+v, want id and data %q", i, e.Event, want)
+				}
+			}
+			err = VerifyStreamed(events, interval)
+			if test.buffered && err == nil {
+				t.Error("VerifyStreamed() = nil for a buffered response")
+			} else if !test.buffered && err != nil {
+				t.Error("VerifyStreamed() =", err)
+			}
+		})
+	}
+}
+
+func TestVerifyStreamedNoEvents(t *testing.T) {
+	if err := VerifyStreamed(nil, time.Second); err == nil {
+		t.Error("VerifyStreamed() = nil without events")
+	}
+}
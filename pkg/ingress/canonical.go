@@ -0,0 +1,83 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"sort"
+
+	"knative.dev/networking/pkg/apis/networking/v1alpha1"
+)
+
+// Canonicalize rewrites the spec of the Ingress in its canonical form, so
+// that the specs differing only in ways which don't matter to the data plane
+// compare and hash the same: the defaults are filled in, the hosts are
+// lowercased and sorted, the header names are canonicalized and the removed
+// headers are sorted. The order of the rules, paths and splits is kept, as it
+// may matter.
+func Canonicalize(ctx context.Context, ing *v1alpha1.Ingress) {
+	ing.SetDefaults(ctx)
+	spec := &ing.Spec
+	for i := range spec.TLS {
+		sort.Strings(spec.TLS[i].Hosts)
+	}
+	for i := range spec.Rules {
+		rule := &spec.Rules[i]
+		sort.Strings(rule.Hosts)
+		if rule.HTTP == nil {
+			continue
+		}
+		for j := range rule.HTTP.Paths {
+			path := &rule.HTTP.Paths[j]
+			sort.Strings(path.RemoveHeaders)
+			for k := range path.Splits {
+				sort.Strings(path.Splits[k].RemoveHeaders)
+			}
+		}
+	}
+}
+
+// SemanticEqual returns whether the specs of the Ingresses are the same once
+// canonicalized with Canonicalize, e.g. for the controllers to skip the
+// updates of the Ingresses they generate, and the probing of the Ingresses
+// following them, when only the order of the hosts changed. The empty and
+// missing fields are equal. The Ingresses are not modified, and their
+// metadata and status are not compared.
+func SemanticEqual(a, b *v1alpha1.Ingress) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	ab, err := canonicalJSON(a)
+	if err != nil {
+		return false
+	}
+	bb, err := canonicalJSON(b)
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(ab, bb)
+}
+
+// canonicalJSON returns the JSON serialization of the canonical spec of the
+// Ingress, where the maps are sorted by key and the empty fields omitted.
+func canonicalJSON(ing *v1alpha1.Ingress) ([]byte, error) {
+	ing = ing.DeepCopy()
+	Canonicalize(context.Background(), ing)
+	return json.Marshal(ing.Spec)
+}
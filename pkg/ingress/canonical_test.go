@@ -0,0 +1,158 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"knative.dev/networking/pkg/apis/networking/v1alpha1"
+)
+
+func canonicalTestIngress() *v1alpha1.Ingress {
+	return &v1alpha1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "ing"},
+		Spec: v1alpha1.IngressSpec{
+			TLS: []v1alpha1.IngressTLS{{
+				Hosts:      []string{"foo.example.com", "bar.example.com"},
+				SecretName: "secret",
+			}},
+			Rules: []v1alpha1.IngressRule{{
+				Hosts: []string{"foo.example.com", "bar.example.com"},
+				HTTP: &v1alpha1.HTTPIngressRuleValue{
+					Paths: []v1alpha1.HTTPIngressPath{{
+						AppendHeaders: map[string]string{"x-foo": "bar"},
+						RemoveHeaders: []string{"x-b", "X-A"},
+						Splits: []v1alpha1.IngressBackendSplit{{
+							IngressBackend: v1alpha1.IngressBackend{
+								ServiceName:      "svc",
+								ServiceNamespace: "default",
+								ServicePort:      intstr.FromInt(80),
+							},
+							RemoveHeaders: []string{"x-d", "x-c"},
+						}},
+					}},
+				},
+			}},
+		},
+	}
+}
+
+func TestCanonicalize(t *testing.T) {
+	ing := canonicalTestIngress()
+	Canonicalize(context.Background(), ing)
+
+	want := canonicalTestIngress()
+	want.Spec.TLS[0].Hosts = []string{"bar.example.com", "foo.example.com"}
+	want.Spec.Rules[0].Hosts = []string{"bar.example.com", "foo.example.com"}
+	want.Spec.Rules[0].Visibility = v1alpha1.IngressVisibilityExternalIP
+	path := &want.Spec.Rules[0].HTTP.Paths[0]
+	path.AppendHeaders = map[string]string{"X-Foo": "bar"}
+	path.RemoveHeaders = []string{"X-A", "X-B"}
+	path.Splits[0].Percent = 100
+	path.Splits[0].RemoveHeaders = []string{"X-C", "X-D"}
+	if !cmp.Equal(want, ing) {
+		t.Error("Canonicalize (-want, +got) =", cmp.Diff(want, ing))
+	}
+}
+
+func TestSemanticEqual(t *testing.T) {
+	tests := []struct {
+		name   string
+		mutate func(*v1alpha1.Ingress)
+		want   bool
+	}{{
+		name:   "identical",
+		mutate: func(*v1alpha1.Ingress) {},
+		want:   true,
+	}, {
+		name: "hosts reordered",
+		mutate: func(ing *v1alpha1.Ingress) {
+			ing.Spec.Rules[0].Hosts = []string{"bar.example.com", "FOO.example.com"}
+			ing.Spec.TLS[0].Hosts = []string{"bar.example.com", "foo.example.com"}
+		},
+		want: true,
+	}, {
+		name: "header names in another case",
+		mutate: func(ing *v1alpha1.Ingress) {
+			path := &ing.Spec.Rules[0].HTTP.Paths[0]
+			path.AppendHeaders = map[string]string{"X-Foo": "bar"}
+			path.RemoveHeaders = []string{"x-a", "X-B"}
+		},
+		want: true,
+	}, {
+		name: "defaults filled",
+		mutate: func(ing *v1alpha1.Ingress) {
+			ing.Spec.Rules[0].Visibility = v1alpha1.IngressVisibilityExternalIP
+			ing.Spec.Rules[0].HTTP.Paths[0].Splits[0].Percent = 100
+		},
+		want: true,
+	}, {
+		name: "empty fields",
+		mutate: func(ing *v1alpha1.Ingress) {
+			ing.Spec.Rules[0].HTTP.Paths[0].SetHeaders = map[string]string{}
+			ing.Spec.Rules[0].Redirects = []v1alpha1.HTTPRedirect{}
+		},
+		want: true,
+	}, {
+		name: "metadata and status",
+		mutate: func(ing *v1alpha1.Ingress) {
+			ing.Labels = map[string]string{"foo": "bar"}
+			ing.Status.MarkNetworkConfigured()
+		},
+		want: true,
+	}, {
+		name: "host changed",
+		mutate: func(ing *v1alpha1.Ingress) {
+			ing.Spec.Rules[0].Hosts = []string{"baz.example.com", "foo.example.com"}
+		},
+	}, {
+		name: "visibility changed",
+		mutate: func(ing *v1alpha1.Ingress) {
+			ing.Spec.Rules[0].Visibility = v1alpha1.IngressVisibilityClusterLocal
+		},
+	}, {
+		name: "header value changed",
+		mutate: func(ing *v1alpha1.Ingress) {
+			ing.Spec.Rules[0].HTTP.Paths[0].AppendHeaders = map[string]string{"x-foo": "baz"}
+		},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			a, b := canonicalTestIngress(), canonicalTestIngress()
+			test.mutate(b)
+			orig := b.DeepCopy()
+			if got := SemanticEqual(a, b); got != test.want {
+				t.Errorf("SemanticEqual() = %t, want: %t", got, test.want)
+			}
+			if got := SemanticEqual(b, a); got != test.want {
+				t.Errorf("SemanticEqual() reversed = %t, want: %t", got, test.want)
+			}
+			if !cmp.Equal(orig, b) {
+				t.Error("SemanticEqual modified the Ingress (-want, +got) =", cmp.Diff(orig, b))
+			}
+		})
+	}
+
+	if !SemanticEqual(nil, nil) || SemanticEqual(canonicalTestIngress(), nil) {
+		t.Error("SemanticEqual() mishandles nil Ingresses")
+	}
+}
@@ -0,0 +1,25 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package gatewayapi translates the Knative Ingresses into the Gateway API
+// objects, i.e. HTTPRoutes and the listeners of the Gateways they attach to,
+// and maps the status of these objects back to the Ingresses, so that the
+// Ingress implementations built on the Gateway API share their translation.
+//
+// The Gateway API objects are handled as unstructured objects, to avoid
+// tying the users of this package to a specific version of the Gateway API
+// clients.
+package gatewayapi
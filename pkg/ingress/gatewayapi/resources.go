@@ -0,0 +1,457 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gatewayapi
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"knative.dev/networking/pkg/apis/networking"
+	"knative.dev/networking/pkg/apis/networking/v1alpha1"
+	"knative.dev/pkg/kmap"
+	"knative.dev/pkg/kmeta"
+)
+
+// GroupName is the API group of the Gateway API.
+const GroupName = "gateway.networking.k8s.io"
+
+var (
+	// HTTPRouteGVR is the resource of the HTTPRoutes.
+	HTTPRouteGVR = schema.GroupVersionResource{
+		Group:    GroupName,
+		Version:  "v1beta1",
+		Resource: "httproutes",
+	}
+
+	// HTTPRouteGVK is the kind of the HTTPRoutes.
+	HTTPRouteGVK = HTTPRouteGVR.GroupVersion().WithKind("HTTPRoute")
+
+	// GatewayGVR is the resource of the Gateways.
+	GatewayGVR = schema.GroupVersionResource{
+		Group:    GroupName,
+		Version:  "v1beta1",
+		Resource: "gateways",
+	}
+
+	// GatewayGVK is the kind of the Gateways.
+	GatewayGVK = GatewayGVR.GroupVersion().WithKind("Gateway")
+)
+
+// GatewayRef identifies a Gateway.
+type GatewayRef struct {
+	Namespace string
+	Name      string
+}
+
+// Gateways maps the visibilities of the rules of the Ingresses to the
+// Gateways exposing them.
+type Gateways map[v1alpha1.IngressVisibility]GatewayRef
+
+// RouteName returns the name of the HTTPRoute translating the rule of the
+// Ingress at the given index.
+func RouteName(ing *v1alpha1.Ingress, idx int) string {
+	return kmeta.ChildName(ing.Name, "-"+strconv.Itoa(idx))
+}
+
+// MakeHTTPRoutes creates the HTTPRoutes translating the rules of the Ingress,
+// one per rule, attached to the Gateway of the visibility of the rule. They
+// have the namespace and labels of the Ingress, which controls them.
+//
+// The features of the Ingress without an equivalent in the Gateway API are
+// rejected rather than ignored, e.g. the header matches or the upstream TLS of
+// the splits, the source IP policies or the redirects to HTTPS of the
+// HTTPOption. Only the extensions are ignored, as the implementations must
+// ignore the ones they don't know.
+func MakeHTTPRoutes(ing *v1alpha1.Ingress, gateways Gateways) ([]*unstructured.Unstructured, error) {
+	if len(ing.Spec.L4Rules) > 0 {
+		return nil, errors.New("l4Rules are not supported")
+	}
+	if ing.Spec.LoadBalancer != nil {
+		return nil, errors.New("loadBalancer is not supported")
+	}
+	// The HTTPRoutes can't secure the internal hops beyond the edge.
+	if trust := ing.Spec.DataplaneTrust; trust != "" && trust != networking.DataplaneTrustEdge {
+		return nil, fmt.Errorf("dataplaneTrust %s is not supported", trust)
+	}
+	for idx := range ing.Spec.TLS {
+		if ing.Spec.TLS[idx].CanonicalHost != "" {
+			return nil, fmt.Errorf("tls[%d]: canonicalHost is not supported", idx)
+		}
+	}
+	routes := make([]*unstructured.Unstructured, 0, len(ing.Spec.Rules))
+	for idx := range ing.Spec.Rules {
+		rule := &ing.Spec.Rules[idx]
+		route, err := makeHTTPRoute(ing, idx, rule, gateways)
+		if err != nil {
+			return nil, fmt.Errorf("rules[%d]: %w", idx, err)
+		}
+		routes = append(routes, route)
+	}
+	return routes, nil
+}
+
+// makeHTTPRoute creates the HTTPRoute translating the rule of the Ingress at
+// the given index.
+func makeHTTPRoute(ing *v1alpha1.Ingress, idx int, rule *v1alpha1.IngressRule, gateways Gateways) (*unstructured.Unstructured, error) {
+	gateway, ok := gateways[rule.Visibility]
+	if !ok {
+		return nil, fmt.Errorf("no Gateway for the visibility %q", rule.Visibility)
+	}
+	switch {
+	case rule.SourceIPPolicy != nil:
+		return nil, errors.New("sourceIPPolicy is not supported")
+	case len(rule.ErrorPages) > 0:
+		return nil, errors.New("errorPages are not supported")
+	case rule.HTTPOption == v1alpha1.HTTPOptionRedirected ||
+		(rule.HTTPOption == "" && ing.Spec.HTTPOption == v1alpha1.HTTPOptionRedirected):
+		return nil, fmt.Errorf("httpOption %s is not supported", v1alpha1.HTTPOptionRedirected)
+	}
+
+	hostnames := make([]interface{}, 0, len(rule.Hosts))
+	for _, host := range rule.Hosts {
+		hostnames = append(hostnames, host)
+	}
+	// The redirects take precedence over the paths.
+	rules := make([]interface{}, 0, len(rule.Redirects))
+	for ridx := range rule.Redirects {
+		r, err := makeRedirectRule(&rule.Redirects[ridx])
+		if err != nil {
+			return nil, fmt.Errorf("redirects[%d]: %w", ridx, err)
+		}
+		rules = append(rules, r)
+	}
+	if rule.HTTP != nil {
+		for pidx := range rule.HTTP.Paths {
+			r, err := makePathRule(&rule.HTTP.Paths[pidx])
+			if err != nil {
+				return nil, fmt.Errorf("http.paths[%d]: %w", pidx, err)
+			}
+			rules = append(rules, r)
+		}
+	}
+
+	u := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{
+			"parentRefs": []interface{}{map[string]interface{}{
+				"group":     GroupName,
+				"kind":      GatewayGVK.Kind,
+				"namespace": gateway.Namespace,
+				"name":      gateway.Name,
+			}},
+			"hostnames": hostnames,
+			"rules":     rules,
+		},
+	}}
+	u.SetGroupVersionKind(HTTPRouteGVK)
+	u.SetName(RouteName(ing, idx))
+	u.SetNamespace(ing.Namespace)
+	u.SetLabels(kmap.Copy(ing.Labels))
+	u.SetOwnerReferences([]metav1.OwnerReference{*kmeta.NewControllerRef(ing)})
+	return u, nil
+}
+
+// makeRedirectRule translates the redirect into an HTTPRoute rule.
+func makeRedirectRule(redirect *v1alpha1.HTTPRedirect) (map[string]interface{}, error) {
+	if redirect.StripQuery {
+		return nil, errors.New("stripQuery is not supported")
+	}
+	filter := map[string]interface{}{}
+	switch redirect.StatusCode {
+	case 0:
+	case http.StatusMovedPermanently, http.StatusFound:
+		filter["statusCode"] = int64(redirect.StatusCode)
+	default:
+		return nil, fmt.Errorf("statusCode %d is not supported", redirect.StatusCode)
+	}
+	if redirect.Scheme != "" {
+		filter["scheme"] = redirect.Scheme
+	}
+	if redirect.Host != "" {
+		filter["hostname"] = redirect.Host
+	}
+	if redirect.ReplacePrefix != "" {
+		filter["path"] = map[string]interface{}{
+			"type":               "ReplacePrefixMatch",
+			"replacePrefixMatch": redirect.ReplacePrefix,
+		}
+	}
+	return map[string]interface{}{
+		"matches": []interface{}{map[string]interface{}{
+			"path": makePathMatch(redirect.Path),
+		}},
+		"filters": []interface{}{map[string]interface{}{
+			"type":            "RequestRedirect",
+			"requestRedirect": filter,
+		}},
+	}, nil
+}
+
+// makePathRule translates the path into an HTTPRoute rule.
+func makePathRule(path *v1alpha1.HTTPIngressPath) (map[string]interface{}, error) {
+	if path.MaxRequestBodyBytes != nil {
+		return nil, errors.New("maxRequestBodyBytes is not supported")
+	}
+	if path.IdleTimeout != nil {
+		return nil, errors.New("idleTimeout is not supported")
+	}
+	if path.ConnectTimeout != nil {
+		return nil, errors.New("connectTimeout is not supported")
+	}
+	if path.Compression != nil {
+		return nil, errors.New("compression is not supported")
+	}
+	match := map[string]interface{}{
+		"path": makePathMatch(path.Path),
+	}
+	if len(path.Headers) > 0 {
+		match["headers"] = makeHeaderMatches(path.Headers)
+	}
+
+	var filters []interface{}
+	if path.RewriteHost != "" {
+		filters = append(filters, map[string]interface{}{
+			"type": "URLRewrite",
+			"urlRewrite": map[string]interface{}{
+				"hostname": path.RewriteHost,
+			},
+		})
+	}
+	if modifier := makeHeaderModifier(path.AppendHeaders, path.SetHeaders, path.RemoveHeaders); modifier != nil {
+		filters = append(filters, modifier)
+	}
+
+	backendRefs := make([]interface{}, 0, len(path.Splits))
+	for sidx := range path.Splits {
+		ref, err := makeBackendRef(&path.Splits[sidx])
+		if err != nil {
+			return nil, fmt.Errorf("splits[%d]: %w", sidx, err)
+		}
+		backendRefs = append(backendRefs, ref)
+	}
+
+	r := map[string]interface{}{
+		"matches":     []interface{}{match},
+		"backendRefs": backendRefs,
+	}
+	if len(filters) > 0 {
+		r["filters"] = filters
+	}
+	return r, nil
+}
+
+// makeBackendRef translates the split into an HTTPRoute backend reference.
+func makeBackendRef(split *v1alpha1.IngressBackendSplit) (map[string]interface{}, error) {
+	if len(split.Headers) > 0 {
+		return nil, errors.New("headers are not supported")
+	}
+	if split.External != nil {
+		return nil, errors.New("external is not supported")
+	}
+	if split.LoadBalancerPolicy != nil {
+		return nil, errors.New("loadBalancerPolicy is not supported")
+	}
+	if split.UpstreamTLS != nil {
+		// The traffic would be sent in plaintext to the backend.
+		return nil, errors.New("upstreamTLS is not supported")
+	}
+	if split.OutlierDetection != nil {
+		return nil, errors.New("outlierDetection is not supported")
+	}
+	if split.HealthCheck != nil {
+		return nil, errors.New("healthCheck is not supported")
+	}
+	if split.ServicePort.Type != intstr.Int {
+		// The Gateway API only references the Service ports by number.
+		return nil, fmt.Errorf("servicePort %q is not a number", split.ServicePort.String())
+	}
+	ref := map[string]interface{}{
+		"group":     "",
+		"kind":      "Service",
+		"namespace": split.ServiceNamespace,
+		"name":      split.ServiceName,
+		"port":      int64(split.ServicePort.IntVal),
+		"weight":    int64(split.Percent),
+	}
+	if modifier := makeHeaderModifier(split.AppendHeaders, split.SetHeaders, split.RemoveHeaders); modifier != nil {
+		ref["filters"] = []interface{}{modifier}
+	}
+	return ref, nil
+}
+
+// makePathMatch matches the paths starting with the given path, all the
+// paths if it is empty.
+func makePathMatch(path string) map[string]interface{} {
+	if path == "" {
+		path = "/"
+	}
+	return map[string]interface{}{
+		"type":  "PathPrefix",
+		"value": path,
+	}
+}
+
+// makeHeaderMatches matches the headers with the given values, sorted by
+// name for the HTTPRoutes to be stable.
+func makeHeaderMatches(headers map[string]v1alpha1.HeaderMatch) []interface{} {
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	matches := make([]interface{}, 0, len(names))
+	for _, name := range names {
+		matches = append(matches, map[string]interface{}{
+			"type":  "Exact",
+			"name":  name,
+			"value": headers[name].Exact,
+		})
+	}
+	return matches
+}
+
+// makeHeaderModifier sets the appended and set headers of the requests, and
+// removes the removed ones, sorted by name for the HTTPRoutes to be stable.
+// It returns nil if no header is modified.
+func makeHeaderModifier(appendHeaders, setHeaders map[string]string, removeHeaders []string) map[string]interface{} {
+	headers := make(map[string]string, len(appendHeaders)+len(setHeaders))
+	for name, value := range appendHeaders {
+		headers[name] = value
+	}
+	for name, value := range setHeaders {
+		headers[name] = value
+	}
+	if len(headers) == 0 && len(removeHeaders) == 0 {
+		return nil
+	}
+
+	modifier := map[string]interface{}{}
+	if len(headers) > 0 {
+		names := make([]string, 0, len(headers))
+		for name := range headers {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		set := make([]interface{}, 0, len(names))
+		for _, name := range names {
+			set = append(set, map[string]interface{}{
+				"name":  name,
+				"value": headers[name],
+			})
+		}
+		modifier["set"] = set
+	}
+	if len(removeHeaders) > 0 {
+		names := append([]string(nil), removeHeaders...)
+		sort.Strings(names)
+		remove := make([]interface{}, 0, len(names))
+		for _, name := range names {
+			remove = append(remove, name)
+		}
+		modifier["remove"] = remove
+	}
+	return map[string]interface{}{
+		"type":                  "RequestHeaderModifier",
+		"requestHeaderModifier": modifier,
+	}
+}
+
+// MakeListeners creates the HTTPS listeners of the Gateway of the given
+// visibility terminating the TLS of the Ingress, one per host. They are
+// meant to be added to the listeners of the Gateway, e.g. with
+// SetListeners. The Secrets of other namespaces than the one of the Gateway
+// must be granted to it with ReferenceGrants.
+func MakeListeners(ing *v1alpha1.Ingress, visibility v1alpha1.IngressVisibility) []interface{} {
+	var listeners []interface{}
+	for _, tls := range ing.Spec.TLS {
+		if tls.Visibility != "" && tls.Visibility != visibility {
+			continue
+		}
+		for _, host := range tls.Hosts {
+			listeners = append(listeners, map[string]interface{}{
+				"name":     ListenerName(ing, host),
+				"hostname": host,
+				"port":     int64(443),
+				"protocol": "HTTPS",
+				"tls": map[string]interface{}{
+					"mode": "Terminate",
+					"certificateRefs": []interface{}{map[string]interface{}{
+						"group":     "",
+						"kind":      "Secret",
+						"namespace": tls.SecretNamespace,
+						"name":      tls.SecretName,
+					}},
+				},
+				"allowedRoutes": map[string]interface{}{
+					"namespaces": map[string]interface{}{
+						"from": "All",
+					},
+				},
+			})
+		}
+	}
+	return listeners
+}
+
+// ListenerName returns the name of the listener of the Gateways terminating
+// the TLS of the Ingress for host. The names are hashed, as the names of the
+// listeners are shorter than the hosts prefixed with the Ingress key can be.
+func ListenerName(ing *v1alpha1.Ingress, host string) string {
+	return listenerPrefix(ing) + shortHash(host)
+}
+
+// listenerPrefix returns the prefix of the names of the listeners of the
+// Ingress.
+func listenerPrefix(ing *v1alpha1.Ingress) string {
+	return "kni-" + shortHash(ing.Namespace+"/"+ing.Name) + "-"
+}
+
+// shortHash returns a hash of s short enough to be part of names.
+func shortHash(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:8])
+}
+
+// SetListeners replaces the listeners of the Gateway created for the Ingress,
+// as returned by MakeListeners, keeping the other ones. Passing no listeners
+// removes the ones of the Ingress, e.g. when it is deleted.
+func SetListeners(gateway *unstructured.Unstructured, ing *v1alpha1.Ingress, listeners []interface{}) error {
+	existing, _, err := unstructured.NestedSlice(gateway.Object, "spec", "listeners")
+	if err != nil {
+		return err
+	}
+	prefix := listenerPrefix(ing)
+	kept := make([]interface{}, 0, len(existing)+len(listeners))
+	for _, l := range existing {
+		if m, ok := l.(map[string]interface{}); ok {
+			if name, _ := m["name"].(string); strings.HasPrefix(name, prefix) {
+				continue
+			}
+		}
+		kept = append(kept, l)
+	}
+	return unstructured.SetNestedSlice(gateway.Object, append(kept, listeners...), "spec", "listeners")
+}
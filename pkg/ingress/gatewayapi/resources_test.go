@@ -0,0 +1,516 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gatewayapi
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"knative.dev/networking/pkg/apis/networking"
+	"knative.dev/networking/pkg/apis/networking/v1alpha1"
+	"knative.dev/pkg/ptr"
+)
+
+var gateways = Gateways{
+	v1alpha1.IngressVisibilityExternalIP:   {Namespace: "knative-serving", Name: "external"},
+	v1alpha1.IngressVisibilityClusterLocal: {Namespace: "knative-serving", Name: "internal"},
+}
+
+func backend(name string, percent int) v1alpha1.IngressBackendSplit {
+	return v1alpha1.IngressBackendSplit{
+		IngressBackend: v1alpha1.IngressBackend{
+			ServiceNamespace: "default",
+			ServiceName:      name,
+			ServicePort:      intstr.FromInt(80),
+		},
+		Percent: percent,
+	}
+}
+
+func TestMakeHTTPRoutes(t *testing.T) {
+	ing := &v1alpha1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "route",
+			Namespace: "default",
+			UID:       "1234",
+			Labels:    map[string]string{"serving.knative.dev/route": "route"},
+		},
+		Spec: v1alpha1.IngressSpec{
+			Rules: []v1alpha1.IngressRule{{
+				Hosts:      []string{"route.default.example.com"},
+				Visibility: v1alpha1.IngressVisibilityExternalIP,
+				Redirects: []v1alpha1.HTTPRedirect{{
+					Path:          "/v1/",
+					ReplacePrefix: "/v2/",
+					StatusCode:    http.StatusFound,
+				}},
+				HTTP: &v1alpha1.HTTPIngressRuleValue{
+					Paths: []v1alpha1.HTTPIngressPath{{
+						Path:          "/tagged",
+						Headers:       map[string]v1alpha1.HeaderMatch{"Knative-Serving-Tag": {Exact: "latest"}},
+						RewriteHost:   "latest.route.default.svc.cluster.local",
+						AppendHeaders: map[string]string{"B": "b", "A": "a"},
+						SetHeaders:    map[string]string{"C": "c"},
+						RemoveHeaders: []string{"Z-Internal", "X-Internal"},
+						Splits:        []v1alpha1.IngressBackendSplit{backend("latest", 100)},
+					}, {
+						Splits: func() []v1alpha1.IngressBackendSplit {
+							split := backend("blue", 10)
+							split.AppendHeaders = map[string]string{"Knative-Serving-Revision": "blue"}
+							split.RemoveHeaders = []string{"X-Internal"}
+							return []v1alpha1.IngressBackendSplit{split, backend("green", 90)}
+						}(),
+					}},
+				},
+			}, {
+				Hosts:      []string{"route.default", "route.default.svc.cluster.local"},
+				Visibility: v1alpha1.IngressVisibilityClusterLocal,
+				HTTP: &v1alpha1.HTTPIngressRuleValue{
+					Paths: []v1alpha1.HTTPIngressPath{{
+						Splits: []v1alpha1.IngressBackendSplit{backend("green", 100)},
+					}},
+				},
+			}},
+		},
+	}
+
+	route := func(name, gateway string, hostnames []interface{}, rules ...interface{}) *unstructured.Unstructured {
+		return &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "gateway.networking.k8s.io/v1beta1",
+			"kind":       "HTTPRoute",
+			"metadata": map[string]interface{}{
+				"name":      name,
+				"namespace": "default",
+				"labels":    map[string]interface{}{"serving.knative.dev/route": "route"},
+				"ownerReferences": []interface{}{map[string]interface{}{
+					"apiVersion":         "networking.internal.knative.dev/v1alpha1",
+					"kind":               "Ingress",
+					"name":               "route",
+					"uid":                "1234",
+					"controller":         true,
+					"blockOwnerDeletion": true,
+				}},
+			},
+			"spec": map[string]interface{}{
+				"parentRefs": []interface{}{map[string]interface{}{
+					"group":     "gateway.networking.k8s.io",
+					"kind":      "Gateway",
+					"namespace": "knative-serving",
+					"name":      gateway,
+				}},
+				"hostnames": hostnames,
+				"rules":     rules,
+			},
+		}}
+	}
+	backendRef := func(name string, weight int64) map[string]interface{} {
+		return map[string]interface{}{
+			"group":     "",
+			"kind":      "Service",
+			"namespace": "default",
+			"name":      name,
+			"port":      int64(80),
+			"weight":    weight,
+		}
+	}
+	want := []*unstructured.Unstructured{
+		route("route-0", "external", []interface{}{"route.default.example.com"}, map[string]interface{}{
+			"matches": []interface{}{map[string]interface{}{
+				"path": map[string]interface{}{"type": "PathPrefix", "value": "/v1/"},
+			}},
+			"filters": []interface{}{map[string]interface{}{
+				"type": "RequestRedirect",
+				"requestRedirect": map[string]interface{}{
+					"statusCode": int64(302),
+					"path": map[string]interface{}{
+						"type":               "ReplacePrefixMatch",
+						"replacePrefixMatch": "/v2/",
+					},
+				},
+			}},
+		}, map[string]interface{}{
+			"matches": []interface{}{map[string]interface{}{
+				"path": map[string]interface{}{"type": "PathPrefix", "value": "/tagged"},
+				"headers": []interface{}{map[string]interface{}{
+					"type": "Exact", "name": "Knative-Serving-Tag", "value": "latest",
+				}},
+			}},
+			"filters": []interface{}{map[string]interface{}{
+				"type":       "URLRewrite",
+				"urlRewrite": map[string]interface{}{"hostname": "latest.route.default.svc.cluster.local"},
+			}, map[string]interface{}{
+				"type": "RequestHeaderModifier",
+				"requestHeaderModifier": map[string]interface{}{
+					"set": []interface{}{
+						map[string]interface{}{"name": "A", "value": "a"},
+						map[string]interface{}{"name": "B", "value": "b"},
+						map[string]interface{}{"name": "C", "value": "c"},
+					},
+					"remove": []interface{}{"X-Internal", "Z-Internal"},
+				},
+			}},
+			"backendRefs": []interface{}{backendRef("latest", 100)},
+		}, map[string]interface{}{
+			"matches": []interface{}{map[string]interface{}{
+				"path": map[string]interface{}{"type": "PathPrefix", "value": "/"},
+			}},
+			"backendRefs": []interface{}{
+				func() map[string]interface{} {
+					ref := backendRef("blue", 10)
+					ref["filters"] = []interface{}{map[string]interface{}{
+						"type": "RequestHeaderModifier",
+						"requestHeaderModifier": map[string]interface{}{
+							"set": []interface{}{
+								map[string]interface{}{"name": "Knative-Serving-Revision", "value": "blue"},
+							},
+							"remove": []interface{}{"X-Internal"},
+						},
+					}}
+					return ref
+				}(),
+				backendRef("green", 90),
+			},
+		}),
+		route("route-1", "internal", []interface{}{"route.default", "route.default.svc.cluster.local"}, map[string]interface{}{
+			"matches": []interface{}{map[string]interface{}{
+				"path": map[string]interface{}{"type": "PathPrefix", "value": "/"},
+			}},
+			"backendRefs": []interface{}{backendRef("green", 100)},
+		}),
+	}
+
+	got, err := MakeHTTPRoutes(ing, gateways)
+	if err != nil {
+		t.Fatal("MakeHTTPRoutes() =", err)
+	}
+	if !cmp.Equal(got, want) {
+		t.Error("MakeHTTPRoutes (-want, +got) =", cmp.Diff(want, got))
+	}
+	// The routes must be valid unstructured objects.
+	for _, r := range got {
+		r.DeepCopy()
+	}
+}
+
+func TestMakeHTTPRoutesUnsupported(t *testing.T) {
+	rule := func(mutate func(*v1alpha1.IngressRule)) *v1alpha1.Ingress {
+		r := v1alpha1.IngressRule{
+			Hosts:      []string{"route.default.example.com"},
+			Visibility: v1alpha1.IngressVisibilityExternalIP,
+			HTTP: &v1alpha1.HTTPIngressRuleValue{
+				Paths: []v1alpha1.HTTPIngressPath{{
+					Splits: []v1alpha1.IngressBackendSplit{backend("green", 100)},
+				}},
+			},
+		}
+		mutate(&r)
+		return &v1alpha1.Ingress{
+			ObjectMeta: metav1.ObjectMeta{Name: "route", Namespace: "default"},
+			Spec:       v1alpha1.IngressSpec{Rules: []v1alpha1.IngressRule{r}},
+		}
+	}
+
+	tests := []struct {
+		name string
+		ing  *v1alpha1.Ingress
+		want string
+	}{{
+		name: "unknown visibility",
+		ing:  rule(func(r *v1alpha1.IngressRule) { r.Visibility = "Public" }),
+		want: `rules[0]: no Gateway for the visibility "Public"`,
+	}, {
+		name: "source IP policy",
+		ing: rule(func(r *v1alpha1.IngressRule) {
+			r.SourceIPPolicy = &v1alpha1.SourceIPPolicy{Allow: []string{"10.0.0.0/8"}}
+		}),
+		want: "rules[0]: sourceIPPolicy is not supported",
+	}, {
+		name: "HTTPS redirect",
+		ing:  rule(func(r *v1alpha1.IngressRule) { r.HTTPOption = v1alpha1.HTTPOptionRedirected }),
+		want: "rules[0]: httpOption Redirected is not supported",
+	}, {
+		name: "permanent redirect",
+		ing: rule(func(r *v1alpha1.IngressRule) {
+			r.Redirects = []v1alpha1.HTTPRedirect{{Scheme: "https", StatusCode: http.StatusPermanentRedirect}}
+		}),
+		want: "rules[0]: redirects[0]: statusCode 308 is not supported",
+	}, {
+		name: "canonical host",
+		ing: func() *v1alpha1.Ingress {
+			ing := rule(func(r *v1alpha1.IngressRule) { r.Hosts = append(r.Hosts, "www.route.default.example.com") })
+			ing.Spec.TLS = []v1alpha1.IngressTLS{{
+				Hosts:         ing.Spec.Rules[0].Hosts,
+				CanonicalHost: "route.default.example.com",
+			}}
+			return ing
+		}(),
+		want: "tls[0]: canonicalHost is not supported",
+	}, {
+		name: "L4 rules",
+		ing: func() *v1alpha1.Ingress {
+			ing := rule(func(*v1alpha1.IngressRule) {})
+			ing.Spec.L4Rules = []v1alpha1.IngressL4Rule{{Port: 1883, Backend: backend("mqtt", 100).IngressBackend}}
+			return ing
+		}(),
+		want: "l4Rules are not supported",
+	}, {
+		name: "dataplane trust",
+		ing: func() *v1alpha1.Ingress {
+			ing := rule(func(*v1alpha1.IngressRule) {})
+			ing.Spec.DataplaneTrust = networking.DataplaneTrustMutual
+			return ing
+		}(),
+		want: "dataplaneTrust mutual is not supported",
+	}, {
+		name: "load balancer",
+		ing: func() *v1alpha1.Ingress {
+			ing := rule(func(*v1alpha1.IngressRule) {})
+			ing.Spec.LoadBalancer = &v1alpha1.LoadBalancerOptions{Internal: true}
+			return ing
+		}(),
+		want: "loadBalancer is not supported",
+	}, {
+		name: "error pages",
+		ing: rule(func(r *v1alpha1.IngressRule) {
+			errors := backend("errors", 100)
+			r.ErrorPages = []v1alpha1.ErrorPage{{StatusCodes: []string{"5xx"}, Backend: &errors.IngressBackend}}
+		}),
+		want: "rules[0]: errorPages are not supported",
+	}, {
+		name: "strip query",
+		ing: rule(func(r *v1alpha1.IngressRule) {
+			r.Redirects = []v1alpha1.HTTPRedirect{{Scheme: "https", StripQuery: true}}
+		}),
+		want: "rules[0]: redirects[0]: stripQuery is not supported",
+	}, {
+		name: "load balancer policy",
+		ing: rule(func(r *v1alpha1.IngressRule) {
+			r.HTTP.Paths[0].Splits[0].LoadBalancerPolicy = &v1alpha1.LoadBalancerPolicy{Type: v1alpha1.LoadBalancerPolicyLeastRequest}
+		}),
+		want: "rules[0]: http.paths[0]: splits[0]: loadBalancerPolicy is not supported",
+	}, {
+		name: "upstream TLS",
+		ing: rule(func(r *v1alpha1.IngressRule) {
+			r.HTTP.Paths[0].Splits[0].UpstreamTLS = &v1alpha1.UpstreamTLS{CASecretName: "ca", CASecretNamespace: "default"}
+		}),
+		want: "rules[0]: http.paths[0]: splits[0]: upstreamTLS is not supported",
+	}, {
+		name: "split headers",
+		ing: rule(func(r *v1alpha1.IngressRule) {
+			r.HTTP.Paths[0].Splits[0].Headers = map[string]v1alpha1.HeaderMatch{"Foo": {Exact: "bar"}}
+		}),
+		want: "rules[0]: http.paths[0]: splits[0]: headers are not supported",
+	}, {
+		name: "named port",
+		ing: rule(func(r *v1alpha1.IngressRule) {
+			r.HTTP.Paths[0].Splits[0].ServicePort = intstr.FromString("http")
+		}),
+		want: `rules[0]: http.paths[0]: splits[0]: servicePort "http" is not a number`,
+	}, {
+		name: "max request body",
+		ing: rule(func(r *v1alpha1.IngressRule) {
+			r.HTTP.Paths[0].MaxRequestBodyBytes = ptr.Int64(1024)
+		}),
+		want: "rules[0]: http.paths[0]: maxRequestBodyBytes is not supported",
+	}, {
+		name: "idle timeout",
+		ing: rule(func(r *v1alpha1.IngressRule) {
+			r.HTTP.Paths[0].IdleTimeout = &metav1.Duration{Duration: time.Hour}
+		}),
+		want: "rules[0]: http.paths[0]: idleTimeout is not supported",
+	}, {
+		name: "connect timeout",
+		ing: rule(func(r *v1alpha1.IngressRule) {
+			r.HTTP.Paths[0].ConnectTimeout = &metav1.Duration{Duration: time.Second}
+		}),
+		want: "rules[0]: http.paths[0]: connectTimeout is not supported",
+	}, {
+		name: "compression",
+		ing: rule(func(r *v1alpha1.IngressRule) {
+			r.HTTP.Paths[0].Compression = &v1alpha1.Compression{Mode: v1alpha1.CompressionEnabled}
+		}),
+		want: "rules[0]: http.paths[0]: compression is not supported",
+	}, {
+		name: "outlier detection",
+		ing: rule(func(r *v1alpha1.IngressRule) {
+			r.HTTP.Paths[0].Splits[0].OutlierDetection = &v1alpha1.OutlierDetection{ConsecutiveErrors: 5}
+		}),
+		want: "rules[0]: http.paths[0]: splits[0]: outlierDetection is not supported",
+	}, {
+		name: "health check",
+		ing: rule(func(r *v1alpha1.IngressRule) {
+			r.HTTP.Paths[0].Splits[0].HealthCheck = &v1alpha1.HealthCheck{Path: "/healthz"}
+		}),
+		want: "rules[0]: http.paths[0]: splits[0]: healthCheck is not supported",
+	}, {
+		name: "external backend",
+		ing: rule(func(r *v1alpha1.IngressRule) {
+			r.HTTP.Paths[0].Splits[0].IngressBackend = v1alpha1.IngressBackend{
+				External: &v1alpha1.ExternalBackend{Host: "example.org", Port: 443},
+			}
+		}),
+		want: "rules[0]: http.paths[0]: splits[0]: external is not supported",
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := MakeHTTPRoutes(test.ing, gateways); err == nil || err.Error() != test.want {
+				t.Errorf("MakeHTTPRoutes() = %v, want %s", err, test.want)
+			}
+		})
+	}
+}
+
+func TestMakeHTTPRoutesFields(t *testing.T) {
+	// The fields of the IngressSpec translated into HTTPRoutes or listeners.
+	translated := sets.NewString(
+		"httpOption",
+		"tls", "tls.hosts", "tls.secretName", "tls.secretNamespace", "tls.visibility",
+		"rules", "rules.hosts", "rules.visibility", "rules.httpOption",
+		"rules.redirects", "rules.redirects.path", "rules.redirects.statusCode",
+		"rules.redirects.scheme", "rules.redirects.host", "rules.redirects.replacePrefix",
+		"rules.http", "rules.http.paths", "rules.http.paths.path", "rules.http.paths.rewriteHost",
+		"rules.http.paths.headers", "rules.http.paths.headers.exact",
+		"rules.http.paths.appendHeaders", "rules.http.paths.setHeaders", "rules.http.paths.removeHeaders",
+		"rules.http.paths.splits", "rules.http.paths.splits.serviceNamespace",
+		"rules.http.paths.splits.serviceName", "rules.http.paths.splits.servicePort",
+		"rules.http.paths.splits.percent", "rules.http.paths.splits.appendHeaders",
+		"rules.http.paths.splits.setHeaders", "rules.http.paths.splits.removeHeaders",
+	)
+	// The fields rejected by MakeHTTPRoutes, see TestMakeHTTPRoutesUnsupported,
+	// with all their subfields.
+	rejected := sets.NewString(
+		"l4Rules", "loadBalancer", "dataplaneTrust", "tls.canonicalHost",
+		"rules.sourceIPPolicy", "rules.errorPages", "rules.redirects.stripQuery",
+		"rules.http.paths.maxRequestBodyBytes", "rules.http.paths.idleTimeout",
+		"rules.http.paths.connectTimeout", "rules.http.paths.compression",
+		"rules.http.paths.splits.headers", "rules.http.paths.splits.external",
+		"rules.http.paths.splits.loadBalancerPolicy", "rules.http.paths.splits.upstreamTLS",
+		"rules.http.paths.splits.outlierDetection", "rules.http.paths.splits.healthCheck",
+	)
+	// The fields ignored by contract.
+	ignored := sets.NewString("extensions")
+
+	pkg := reflect.TypeOf(v1alpha1.IngressSpec{}).PkgPath()
+	var walk func(prefix string, typ reflect.Type)
+	walk = func(prefix string, typ reflect.Type) {
+		for i := 0; i < typ.NumField(); i++ {
+			f := typ.Field(i)
+			name := strings.Split(f.Tag.Get("json"), ",")[0]
+			path := name
+			if prefix != "" {
+				path = prefix + "." + name
+			}
+			ft := f.Type
+			for ft.Kind() == reflect.Ptr || ft.Kind() == reflect.Slice || ft.Kind() == reflect.Map {
+				ft = ft.Elem()
+			}
+			switch {
+			case name == "" && f.Anonymous:
+				// Inlined.
+				walk(prefix, ft)
+			case rejected.Has(path) || ignored.Has(path):
+			case !translated.Has(path):
+				t.Errorf("Field %s is neither translated nor rejected", path)
+			case ft.Kind() == reflect.Struct && ft.PkgPath() == pkg:
+				walk(path, ft)
+			}
+		}
+	}
+	walk("", reflect.TypeOf(v1alpha1.IngressSpec{}))
+}
+
+func TestListeners(t *testing.T) {
+	ing := &v1alpha1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{Name: "route", Namespace: "default"},
+		Spec: v1alpha1.IngressSpec{
+			TLS: []v1alpha1.IngressTLS{{
+				Hosts:           []string{"route.default.example.com"},
+				SecretNamespace: "default",
+				SecretName:      "route-cert",
+			}, {
+				Hosts:           []string{"route.default.svc.cluster.local"},
+				SecretNamespace: "default",
+				SecretName:      "route-internal-cert",
+				Visibility:      v1alpha1.IngressVisibilityClusterLocal,
+			}},
+		},
+	}
+	name := ListenerName(ing, "route.default.example.com")
+	if !strings.HasPrefix(name, "kni-") || len(name) > validation.DNS1123LabelMaxLength {
+		t.Errorf("ListenerName() = %q, want a short name starting with kni-", name)
+	}
+
+	listeners := MakeListeners(ing, v1alpha1.IngressVisibilityExternalIP)
+	want := []interface{}{map[string]interface{}{
+		"name":     name,
+		"hostname": "route.default.example.com",
+		"port":     int64(443),
+		"protocol": "HTTPS",
+		"tls": map[string]interface{}{
+			"mode": "Terminate",
+			"certificateRefs": []interface{}{map[string]interface{}{
+				"group":     "",
+				"kind":      "Secret",
+				"namespace": "default",
+				"name":      "route-cert",
+			}},
+		},
+		"allowedRoutes": map[string]interface{}{
+			"namespaces": map[string]interface{}{"from": "All"},
+		},
+	}}
+	if !cmp.Equal(listeners, want) {
+		t.Error("MakeListeners (-want, +got) =", cmp.Diff(want, listeners))
+	}
+
+	http := map[string]interface{}{"name": "http", "port": int64(80), "protocol": "HTTP"}
+	gateway := &unstructured.Unstructured{Object: map[string]interface{}{
+		"spec": map[string]interface{}{
+			"listeners": []interface{}{
+				http,
+				// A stale listener of the Ingress, e.g. of a removed host.
+				map[string]interface{}{"name": ListenerName(ing, "old.example.com")},
+			},
+		},
+	}}
+	if err := SetListeners(gateway, ing, listeners); err != nil {
+		t.Fatal("SetListeners() =", err)
+	}
+	got, _, _ := unstructured.NestedSlice(gateway.Object, "spec", "listeners")
+	if want := append([]interface{}{http}, listeners...); !cmp.Equal(got, want) {
+		t.Error("SetListeners (-want, +got) =", cmp.Diff(want, got))
+	}
+
+	// Removing the listeners of the Ingress keeps the other ones.
+	if err := SetListeners(gateway, ing, nil); err != nil {
+		t.Fatal("SetListeners() =", err)
+	}
+	got, _, _ = unstructured.NestedSlice(gateway.Object, "spec", "listeners")
+	if want := []interface{}{http}; !cmp.Equal(got, want) {
+		t.Error("SetListeners (-want, +got) =", cmp.Diff(want, got))
+	}
+}
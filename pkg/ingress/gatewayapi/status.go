@@ -0,0 +1,147 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gatewayapi
+
+import (
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"knative.dev/networking/pkg/apis/networking/v1alpha1"
+)
+
+// The conditions of the parents of the HTTPRoutes.
+const (
+	conditionAccepted     = "Accepted"
+	conditionResolvedRefs = "ResolvedRefs"
+)
+
+// The types of the addresses of the Gateways.
+const (
+	addressTypeIP       = "IPAddress"
+	addressTypeHostname = "Hostname"
+)
+
+// PropagateRouteStatus reflects the status of the HTTPRoutes of the Ingress,
+// as created by MakeHTTPRoutes, in the status of the Ingress, returning
+// whether they have all been accepted by their Gateway. The network of the
+// Ingress is then marked as configured, the caller remaining responsible for
+// its load balancers, e.g. with LoadBalancerIngresses.
+func PropagateRouteStatus(ing *v1alpha1.Ingress, routes []*unstructured.Unstructured) bool {
+	for _, route := range routes {
+		parent := routeParentStatus(route)
+		if parent == nil {
+			ing.Status.MarkIngressNotReady("HTTPRouteNotReady",
+				fmt.Sprintf("Waiting for HTTPRoute %q to be accepted by its Gateway", route.GetName()))
+			return false
+		}
+		for _, conditionType := range []string{conditionAccepted, conditionResolvedRefs} {
+			c := getCondition(parent, conditionType)
+			switch {
+			case c == nil && conditionType == conditionResolvedRefs:
+				// Not all implementations report the resolution of the references.
+			case c == nil || c.status == string(metav1.ConditionUnknown) ||
+				(c.observedGeneration != 0 && c.observedGeneration < route.GetGeneration()):
+				ing.Status.MarkIngressNotReady("HTTPRouteNotReady",
+					fmt.Sprintf("Waiting for HTTPRoute %q to be accepted by its Gateway", route.GetName()))
+				return false
+			case c.status == string(metav1.ConditionFalse):
+				ing.Status.MarkLoadBalancerFailed(c.reason,
+					fmt.Sprintf("HTTPRoute %q: %s", route.GetName(), c.message))
+				return false
+			}
+		}
+	}
+	ing.Status.MarkNetworkConfigured()
+	return true
+}
+
+// routeParentStatus returns the status of the HTTPRoute for the Gateway it
+// references, or nil if the Gateway didn't report any yet.
+func routeParentStatus(route *unstructured.Unstructured) map[string]interface{} {
+	refs, _, _ := unstructured.NestedSlice(route.Object, "spec", "parentRefs")
+	if len(refs) == 0 {
+		return nil
+	}
+	ref, ok := refs[0].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	parents, _, _ := unstructured.NestedSlice(route.Object, "status", "parents")
+	for _, p := range parents {
+		parent, ok := p.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name, _, _ := unstructured.NestedString(parent, "parentRef", "name")
+		namespace, _, _ := unstructured.NestedString(parent, "parentRef", "namespace")
+		if name == ref["name"] && namespace == ref["namespace"] {
+			return parent
+		}
+	}
+	return nil
+}
+
+// condition is the subset of the conditions of the Gateway API objects the
+// Ingresses rely on.
+type condition struct {
+	status             string
+	reason             string
+	message            string
+	observedGeneration int64
+}
+
+// getCondition returns the condition of the given type of the status, or nil
+// if it isn't set.
+func getCondition(status map[string]interface{}, conditionType string) *condition {
+	conditions, _, _ := unstructured.NestedSlice(status, "conditions")
+	for _, c := range conditions {
+		m, ok := c.(map[string]interface{})
+		if !ok || m["type"] != conditionType {
+			continue
+		}
+		s, _, _ := unstructured.NestedString(m, "status")
+		reason, _, _ := unstructured.NestedString(m, "reason")
+		message, _, _ := unstructured.NestedString(m, "message")
+		generation, _, _ := unstructured.NestedInt64(m, "observedGeneration")
+		return &condition{status: s, reason: reason, message: message, observedGeneration: generation}
+	}
+	return nil
+}
+
+// LoadBalancerIngresses returns the load balancer ingresses of the addresses
+// of the Gateway, to mark the load balancers of the Ingresses it exposes as
+// ready.
+func LoadBalancerIngresses(gateway *unstructured.Unstructured) []v1alpha1.LoadBalancerIngressStatus {
+	addresses, _, _ := unstructured.NestedSlice(gateway.Object, "status", "addresses")
+	lbs := make([]v1alpha1.LoadBalancerIngressStatus, 0, len(addresses))
+	for _, a := range addresses {
+		m, ok := a.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		addressType, _, _ := unstructured.NestedString(m, "type")
+		value, _, _ := unstructured.NestedString(m, "value")
+		switch addressType {
+		case addressTypeIP, "":
+			lbs = append(lbs, v1alpha1.LoadBalancerIngressIP(value))
+		case addressTypeHostname:
+			lbs = append(lbs, v1alpha1.LoadBalancerIngressDomain(value))
+		}
+	}
+	return lbs
+}
@@ -0,0 +1,174 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gatewayapi
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"knative.dev/networking/pkg/apis/networking/v1alpha1"
+	"knative.dev/pkg/apis"
+)
+
+func TestPropagateRouteStatus(t *testing.T) {
+	route := func(parents ...interface{}) *unstructured.Unstructured {
+		u := &unstructured.Unstructured{Object: map[string]interface{}{
+			"spec": map[string]interface{}{
+				"parentRefs": []interface{}{map[string]interface{}{
+					"group":     "gateway.networking.k8s.io",
+					"kind":      "Gateway",
+					"namespace": "knative-serving",
+					"name":      "external",
+				}},
+			},
+			"status": map[string]interface{}{
+				"parents": parents,
+			},
+		}}
+		u.SetName("route-0")
+		u.SetGeneration(2)
+		return u
+	}
+	parent := func(gateway string, conditions ...interface{}) map[string]interface{} {
+		return map[string]interface{}{
+			"parentRef": map[string]interface{}{
+				"namespace": "knative-serving",
+				"name":      gateway,
+			},
+			"conditions": conditions,
+		}
+	}
+	cond := func(conditionType, status, reason string, generation int64) map[string]interface{} {
+		return map[string]interface{}{
+			"type":               conditionType,
+			"status":             status,
+			"reason":             reason,
+			"message":            reason + " message",
+			"observedGeneration": generation,
+		}
+	}
+
+	tests := []struct {
+		name      string
+		route     *unstructured.Unstructured
+		wantReady bool
+		want      *apis.Condition
+	}{{
+		name:      "accepted",
+		route:     route(parent("external", cond("Accepted", "True", "Accepted", 2), cond("ResolvedRefs", "True", "ResolvedRefs", 2))),
+		wantReady: true,
+		want: &apis.Condition{
+			Type:   v1alpha1.IngressConditionNetworkConfigured,
+			Status: corev1.ConditionTrue,
+		},
+	}, {
+		name:      "accepted without resolved refs",
+		route:     route(parent("external", cond("Accepted", "True", "Accepted", 0))),
+		wantReady: true,
+		want: &apis.Condition{
+			Type:   v1alpha1.IngressConditionNetworkConfigured,
+			Status: corev1.ConditionTrue,
+		},
+	}, {
+		name:  "no status",
+		route: route(),
+		want: &apis.Condition{
+			Type:    v1alpha1.IngressConditionReady,
+			Status:  corev1.ConditionUnknown,
+			Reason:  "HTTPRouteNotReady",
+			Message: `Waiting for HTTPRoute "route-0" to be accepted by its Gateway`,
+		},
+	}, {
+		name:  "status of another gateway",
+		route: route(parent("internal", cond("Accepted", "True", "Accepted", 2))),
+		want: &apis.Condition{
+			Type:    v1alpha1.IngressConditionReady,
+			Status:  corev1.ConditionUnknown,
+			Reason:  "HTTPRouteNotReady",
+			Message: `Waiting for HTTPRoute "route-0" to be accepted by its Gateway`,
+		},
+	}, {
+		name:  "outdated status",
+		route: route(parent("external", cond("Accepted", "True", "Accepted", 1))),
+		want: &apis.Condition{
+			Type:    v1alpha1.IngressConditionReady,
+			Status:  corev1.ConditionUnknown,
+			Reason:  "HTTPRouteNotReady",
+			Message: `Waiting for HTTPRoute "route-0" to be accepted by its Gateway`,
+		},
+	}, {
+		name:  "not accepted",
+		route: route(parent("external", cond("Accepted", "False", "NotAllowedByListeners", 2))),
+		want: &apis.Condition{
+			Type:    v1alpha1.IngressConditionLoadBalancerReady,
+			Status:  corev1.ConditionFalse,
+			Reason:  "NotAllowedByListeners",
+			Message: `HTTPRoute "route-0": NotAllowedByListeners message`,
+		},
+	}, {
+		name:  "unresolved refs",
+		route: route(parent("external", cond("Accepted", "True", "Accepted", 2), cond("ResolvedRefs", "False", "BackendNotFound", 2))),
+		want: &apis.Condition{
+			Type:    v1alpha1.IngressConditionLoadBalancerReady,
+			Status:  corev1.ConditionFalse,
+			Reason:  "BackendNotFound",
+			Message: `HTTPRoute "route-0": BackendNotFound message`,
+		},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ing := &v1alpha1.Ingress{}
+			ing.Status.InitializeConditions()
+			if got := PropagateRouteStatus(ing, []*unstructured.Unstructured{test.route}); got != test.wantReady {
+				t.Errorf("PropagateRouteStatus() = %t, want %t", got, test.wantReady)
+			}
+			got := ing.Status.GetCondition(test.want.Type)
+			if got == nil {
+				t.Fatalf("Condition %s not set", test.want.Type)
+			}
+			got = got.DeepCopy()
+			got.LastTransitionTime = apis.VolatileTime{}
+			if !cmp.Equal(got, test.want) {
+				t.Error("Condition (-want, +got) =", cmp.Diff(test.want, got))
+			}
+		})
+	}
+}
+
+func TestLoadBalancerIngresses(t *testing.T) {
+	gateway := &unstructured.Unstructured{Object: map[string]interface{}{
+		"status": map[string]interface{}{
+			"addresses": []interface{}{
+				map[string]interface{}{"type": "IPAddress", "value": "1.2.3.4"},
+				map[string]interface{}{"value": "5.6.7.8"},
+				map[string]interface{}{"type": "Hostname", "value": "lb.example.com"},
+				map[string]interface{}{"type": "example.com/custom", "value": "ignored"},
+			},
+		},
+	}}
+	want := []v1alpha1.LoadBalancerIngressStatus{
+		v1alpha1.LoadBalancerIngressIP("1.2.3.4"),
+		v1alpha1.LoadBalancerIngressIP("5.6.7.8"),
+		v1alpha1.LoadBalancerIngressDomain("lb.example.com"),
+	}
+	if got := LoadBalancerIngresses(gateway); !cmp.Equal(got, want) {
+		t.Error("LoadBalancerIngresses (-want, +got) =", cmp.Diff(want, got))
+	}
+}
@@ -39,18 +39,30 @@ func ComputeHash(ing *v1alpha1.Ingress) ([sha256.Size]byte, error) {
 	return sha256.Sum256(bytes), nil
 }
 
+// SpecHash returns the hex encoding of ComputeHash, as found in the probe
+// headers added by InsertProbe and in the Status.ProgrammedHash of the
+// Ingresses.
+func SpecHash(ing *v1alpha1.Ingress) (string, error) {
+	bytes, err := ComputeHash(ing)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", bytes), nil
+}
+
 // InsertProbe adds a AppendHeader rule so that any request going through a Gateway is tagged with
-// the version of the Ingress currently deployed on the Gateway.
+// the version of the Ingress currently deployed on the Gateway. The rules only
+// having redirects are left as is, their hosts aren't probed, see
+// HostsPerVisibility.
 func InsertProbe(ing *v1alpha1.Ingress) (string, error) {
-	bytes, err := ComputeHash(ing)
+	hash, err := SpecHash(ing)
 	if err != nil {
 		return "", fmt.Errorf("failed to compute the hash of the Ingress: %w", err)
 	}
-	hash := fmt.Sprintf("%x", bytes)
 
 	for _, rule := range ing.Spec.Rules {
 		if rule.HTTP == nil {
-			return "", fmt.Errorf("rule is missing HTTP block: %+v", rule)
+			continue
 		}
 		probePaths := make([]v1alpha1.HTTPIngressPath, 0, len(rule.HTTP.Paths))
 		for i := range rule.HTTP.Paths {
@@ -71,11 +83,48 @@ func InsertProbe(ing *v1alpha1.Ingress) (string, error) {
 	return hash, nil
 }
 
+// RemoveProbe undoes InsertProbe, removing the probe paths it added to the rules
+// of the Ingress. It returns the hash the probe paths were tagged with, or an
+// empty string if the Ingress had none.
+func RemoveProbe(ing *v1alpha1.Ingress) string {
+	var hash string
+	for _, rule := range ing.Spec.Rules {
+		if rule.HTTP == nil {
+			continue
+		}
+		paths := make([]v1alpha1.HTTPIngressPath, 0, len(rule.HTTP.Paths))
+		for _, path := range rule.HTTP.Paths {
+			if h, ok := probeHash(path); ok {
+				hash = h
+				continue
+			}
+			paths = append(paths, path)
+		}
+		rule.HTTP.Paths = paths
+	}
+	return hash
+}
+
+// probeHash returns the hash a probe path inserted by InsertProbe is tagged
+// with, and whether the path is such a probe path.
+func probeHash(path v1alpha1.HTTPIngressPath) (string, bool) {
+	if path.Headers[header.HashKey] != (v1alpha1.HeaderMatch{Exact: header.HashValueOverride}) {
+		return "", false
+	}
+	hash, ok := path.AppendHeaders[header.HashKey]
+	return hash, ok
+}
+
 // HostsPerVisibility takes an Ingress and a map from visibility levels to a set of string keys,
 // it then returns a map from that key space to the hosts under that visibility.
+// The hosts of the rules only having redirects are left out, as the probes of
+// these hosts are only ever redirected.
 func HostsPerVisibility(ing *v1alpha1.Ingress, visibilityToKey map[v1alpha1.IngressVisibility]sets.String) map[string]sets.String {
 	output := make(map[string]sets.String, 2) // We currently have public and internal.
 	for _, rule := range ing.Spec.Rules {
+		if rule.HTTP == nil {
+			continue
+		}
 		for host := range ExpandedHosts(sets.NewString(rule.Hosts...)) {
 			for key := range visibilityToKey[rule.Visibility] {
 				if _, ok := output[key]; !ok {
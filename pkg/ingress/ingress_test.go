@@ -17,10 +17,12 @@ limitations under the License.
 package ingress
 
 import (
+	"fmt"
 	"strings"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/sets"
 	"knative.dev/networking/pkg/apis/networking/v1alpha1"
 )
@@ -99,7 +101,6 @@ func TestInsertProbe(t *testing.T) {
 		name    string
 		ingress *v1alpha1.Ingress
 		want    string
-		wantErr bool
 	}{{
 		name: "with rules, no append header",
 		ingress: &v1alpha1.Ingress{
@@ -120,7 +121,7 @@ func TestInsertProbe(t *testing.T) {
 				}},
 			},
 		},
-		want: "a25000a350642c8abef53078b329bd043e18758f6063c1172d53b04e14fcf5c1",
+		want: "f988d74e91a2dfb0312c91d069c70203b4676889203682fa75e755f251b52843",
 	}, {
 		name: "with rules, with append header",
 		ingress: &v1alpha1.Ingress{
@@ -144,30 +145,15 @@ func TestInsertProbe(t *testing.T) {
 				}},
 			},
 		},
-		want: "6b652c7abed871354affd4a9cb699d33816f24541fac942149b91ad872fe63ca",
-	}, {
-		name: "rule missing HTTP block",
-		ingress: &v1alpha1.Ingress{
-			Spec: v1alpha1.IngressSpec{
-				Rules: []v1alpha1.IngressRule{{
-					Hosts: []string{
-						"example.com",
-					},
-				}},
-			},
-		},
-		wantErr: true,
+		want: "30f532a67fc94327488213d84596ea84af191fc2fb67175f9599dabd83b56436",
 	}}
 
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
 			ingress := test.ingress.DeepCopy()
 			got, err := InsertProbe(test.ingress)
-			if test.wantErr == (err == nil) {
-				t.Errorf("InsertProbe() err = %v, wantErr = %t", err, test.wantErr)
-			}
 			if err != nil {
-				return
+				t.Fatal("InsertProbe() =", err)
 			}
 			beforePaths := len(ingress.Spec.Rules[0].HTTP.Paths)
 			beforeAppHdr := len(ingress.Spec.Rules[0].HTTP.Paths[0].AppendHeaders)
@@ -204,6 +190,126 @@ func TestInsertProbe(t *testing.T) {
 	}
 }
 
+func TestRemoveProbe(t *testing.T) {
+	split := v1alpha1.IngressBackendSplit{
+		IngressBackend: v1alpha1.IngressBackend{
+			ServiceName: "blah",
+		},
+	}
+	tests := []struct {
+		name    string
+		ingress *v1alpha1.Ingress
+	}{{
+		name: "single path",
+		ingress: &v1alpha1.Ingress{
+			Spec: v1alpha1.IngressSpec{
+				Rules: []v1alpha1.IngressRule{{
+					Hosts: []string{"example.com"},
+					HTTP: &v1alpha1.HTTPIngressRuleValue{
+						Paths: []v1alpha1.HTTPIngressPath{{
+							Splits: []v1alpha1.IngressBackendSplit{split},
+						}},
+					},
+				}},
+			},
+		},
+	}, {
+		name: "multiple rules and paths with headers",
+		ingress: &v1alpha1.Ingress{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "ns",
+				Name:      "name",
+			},
+			Spec: v1alpha1.IngressSpec{
+				Rules: []v1alpha1.IngressRule{{
+					Hosts: []string{"example.com"},
+					HTTP: &v1alpha1.HTTPIngressRuleValue{
+						Paths: []v1alpha1.HTTPIngressPath{{
+							Path:   "/foo",
+							Splits: []v1alpha1.IngressBackendSplit{split},
+							AppendHeaders: map[string]string{
+								"Foo": "bar",
+							},
+						}, {
+							Splits: []v1alpha1.IngressBackendSplit{split},
+							Headers: map[string]v1alpha1.HeaderMatch{
+								"Baz": {Exact: "qux"},
+							},
+						}},
+					},
+				}, {
+					Hosts:      []string{"name.ns.svc.cluster.local"},
+					Visibility: v1alpha1.IngressVisibilityClusterLocal,
+					HTTP: &v1alpha1.HTTPIngressRuleValue{
+						Paths: []v1alpha1.HTTPIngressPath{{
+							Splits: []v1alpha1.IngressBackendSplit{split},
+						}},
+					},
+				}},
+			},
+		},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ing := test.ingress.DeepCopy()
+			hash, err := InsertProbe(ing)
+			if err != nil {
+				t.Fatal("InsertProbe() =", err)
+			}
+			if got := RemoveProbe(ing); got != hash {
+				t.Errorf("RemoveProbe() = %s, want: %s", got, hash)
+			}
+			if !cmp.Equal(ing, test.ingress) {
+				t.Error("Round trip (-want, +got) =", cmp.Diff(test.ingress, ing))
+			}
+			// The hash is the one of the original Ingress.
+			if sum, err := ComputeHash(ing); err != nil {
+				t.Error("ComputeHash() =", err)
+			} else if got := fmt.Sprintf("%x", sum); got != hash {
+				t.Errorf("ComputeHash() = %s, want: %s", got, hash)
+			}
+			// Nothing is left to remove.
+			if got := RemoveProbe(ing); got != "" {
+				t.Errorf("Second RemoveProbe() = %s, want: empty", got)
+			}
+		})
+	}
+}
+
+func TestInsertProbeRedirectRule(t *testing.T) {
+	ing := &v1alpha1.Ingress{
+		Spec: v1alpha1.IngressSpec{
+			Rules: []v1alpha1.IngressRule{{
+				Hosts:     []string{"old.example.com"},
+				Redirects: []v1alpha1.HTTPRedirect{{Host: "example.com"}},
+			}, {
+				Hosts: []string{"example.com"},
+				HTTP: &v1alpha1.HTTPIngressRuleValue{
+					Paths: []v1alpha1.HTTPIngressPath{{
+						Splits: []v1alpha1.IngressBackendSplit{{
+							IngressBackend: v1alpha1.IngressBackend{
+								ServiceName: "blah",
+							},
+						}},
+					}},
+				},
+			}},
+		},
+	}
+	want := ing.DeepCopy()
+
+	if _, err := InsertProbe(ing); err != nil {
+		t.Fatal("InsertProbe() =", err)
+	}
+	if !cmp.Equal(ing.Spec.Rules[0], want.Spec.Rules[0]) {
+		t.Error("Redirect rule (-want, +got) =", cmp.Diff(want.Spec.Rules[0], ing.Spec.Rules[0]))
+	}
+	if got, want := len(ing.Spec.Rules[1].HTTP.Paths), 2; got != want {
+		t.Errorf("#paths = %d, want: %d", got, want)
+	}
+}
+
 func TestHostsPerVisibility(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -287,6 +393,22 @@ func TestHostsPerVisibility(t *testing.T) {
 				"foo.bar",
 			),
 		},
+	}, {
+		name: "redirect rule",
+		ingress: &v1alpha1.Ingress{
+			Spec: v1alpha1.IngressSpec{
+				Rules: []v1alpha1.IngressRule{{
+					Hosts:      []string{"old.example.com"},
+					Redirects:  []v1alpha1.HTTPRedirect{{Host: "example.com"}},
+					Visibility: v1alpha1.IngressVisibilityExternalIP,
+				}},
+			},
+		},
+		in: map[v1alpha1.IngressVisibility]sets.String{
+			v1alpha1.IngressVisibilityExternalIP:   sets.NewString("foo"),
+			v1alpha1.IngressVisibilityClusterLocal: sets.NewString("bar", "baz"),
+		},
+		want: map[string]sets.String{},
 	}}
 
 	for _, test := range tests {
@@ -298,3 +420,53 @@ func TestHostsPerVisibility(t *testing.T) {
 		})
 	}
 }
+
+func TestSpecHash(t *testing.T) {
+	ing := &v1alpha1.Ingress{
+		Spec: v1alpha1.IngressSpec{
+			Rules: []v1alpha1.IngressRule{{
+				Hosts: []string{"example.com"},
+				HTTP: &v1alpha1.HTTPIngressRuleValue{
+					Paths: []v1alpha1.HTTPIngressPath{{
+						Splits: []v1alpha1.IngressBackendSplit{{
+							IngressBackend: v1alpha1.IngressBackend{
+								ServiceName: "blah",
+							},
+						}},
+					}},
+				},
+			}},
+		},
+	}
+
+	hash, err := SpecHash(ing)
+	if err != nil {
+		t.Fatal("SpecHash() =", err)
+	}
+	// The same as the probe headers inserted.
+	if want := "f988d74e91a2dfb0312c91d069c70203b4676889203682fa75e755f251b52843"; hash != want {
+		t.Errorf("SpecHash() = %s, want: %s", hash, want)
+	}
+
+	ing.Generation = 1
+	ing.Status.ObservedGeneration = 1
+	ing.Status.MarkProgrammed(hash)
+	if !ing.IsProgrammed(hash) {
+		t.Error("IsProgrammed() = false, want: true")
+	}
+
+	// Changing the spec changes the hash.
+	ing.Spec.Rules[0].Hosts = []string{"example.org"}
+	ing.Generation = 2
+	ing.Status.ObservedGeneration = 2
+	newHash, err := SpecHash(ing)
+	if err != nil {
+		t.Fatal("SpecHash() =", err)
+	}
+	if newHash == hash {
+		t.Error("SpecHash() didn't change with the spec")
+	}
+	if ing.IsProgrammed(newHash) {
+		t.Error("IsProgrammed() = true before programming the new spec")
+	}
+}
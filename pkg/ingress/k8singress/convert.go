@@ -0,0 +1,364 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package k8singress converts the Knative Ingresses from and to the standard
+// Kubernetes networking.k8s.io/v1 Ingresses, e.g. to migrate existing
+// workloads or to fall back on the vanilla ingress controllers. The
+// conversions are lossy, the parts of the Ingresses they don't preserve
+// being reported to the callers.
+package k8singress
+
+import (
+	"fmt"
+	"strings"
+
+	netv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"knative.dev/networking/pkg/apis/networking"
+	"knative.dev/networking/pkg/apis/networking/v1alpha1"
+	"knative.dev/pkg/kmap"
+)
+
+// Loss is a part of an Ingress that a conversion didn't preserve.
+type Loss struct {
+	// Field is the path of the field of the converted Ingress, e.g.
+	// `spec.rules[0].redirects`.
+	Field string
+
+	// Reason explains how the field was altered or why it was dropped.
+	Reason string
+}
+
+// String implements fmt.Stringer.
+func (l Loss) String() string {
+	return l.Field + ": " + l.Reason
+}
+
+// report accumulates the Losses of a conversion.
+type report []Loss
+
+// add records the loss of the field.
+func (r *report) add(field, reason string, args ...interface{}) {
+	*r = append(*r, Loss{Field: field, Reason: fmt.Sprintf(reason, args...)})
+}
+
+// ToKubernetes converts the Knative Ingress into a Kubernetes Ingress of the
+// given class, if any, reporting the parts of the Ingress not preserved.
+//
+// The Kubernetes Ingresses only expose the hosts of the rules publicly and
+// route each path to a single Service of their namespace: the cluster-local
+// rules are dropped, and the paths split across multiple Services are routed
+// to the Service receiving the largest share of the traffic. The features
+// without an equivalent, e.g. the header matches or the redirects, are
+// dropped.
+func ToKubernetes(ing *v1alpha1.Ingress, className string) (*netv1.Ingress, []Loss) {
+	var losses report
+	out := &netv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        ing.Name,
+			Namespace:   ing.Namespace,
+			Labels:      copyMap(ing.Labels),
+			Annotations: copyMap(ing.Annotations),
+		},
+	}
+	if className != "" {
+		out.Spec.IngressClassName = &className
+	}
+
+	for i, tls := range ing.Spec.TLS {
+		field := fmt.Sprintf("spec.tls[%d]", i)
+		if tls.Visibility == v1alpha1.IngressVisibilityClusterLocal {
+			losses.add(field, "cluster-local TLS is not supported")
+			continue
+		}
+		if tls.CanonicalHost != "" {
+			losses.add(field+".canonicalHost", "canonical host redirects are not supported")
+		}
+		if tls.SecretNamespace != ing.Namespace {
+			losses.add(field+".secretNamespace", "secrets of other namespaces than %q are not supported", ing.Namespace)
+			continue
+		}
+		out.Spec.TLS = append(out.Spec.TLS, netv1.IngressTLS{
+			Hosts:      append([]string(nil), tls.Hosts...),
+			SecretName: tls.SecretName,
+		})
+	}
+	if ing.Spec.HTTPOption == v1alpha1.HTTPOptionRedirected {
+		losses.add("spec.httpOption", "redirecting HTTP to HTTPS is not supported")
+	}
+	if len(ing.Spec.Extensions) > 0 {
+		losses.add("spec.extensions", "extensions are not supported")
+	}
+	if len(ing.Spec.L4Rules) > 0 {
+		losses.add("spec.l4Rules", "L4 rules are not supported")
+	}
+	if ing.Spec.LoadBalancer != nil {
+		losses.add("spec.loadBalancer", "load balancer options are not supported")
+	}
+	if trust := ing.Spec.DataplaneTrust; trust != "" && trust != networking.DataplaneTrustEdge {
+		losses.add("spec.dataplaneTrust", "securing the internal hops is not supported, they are unencrypted")
+	}
+
+	for i := range ing.Spec.Rules {
+		rule := &ing.Spec.Rules[i]
+		field := fmt.Sprintf("spec.rules[%d]", i)
+		if rule.Visibility == v1alpha1.IngressVisibilityClusterLocal {
+			losses.add(field, "cluster-local rules are not supported")
+			continue
+		}
+		if rule.HTTPOption == v1alpha1.HTTPOptionRedirected {
+			losses.add(field+".httpOption", "redirecting HTTP to HTTPS is not supported")
+		}
+		if len(rule.Redirects) > 0 {
+			losses.add(field+".redirects", "redirects are not supported")
+		}
+		if rule.SourceIPPolicy != nil {
+			losses.add(field+".sourceIPPolicy", "source IP policies are not supported")
+		}
+		if len(rule.ErrorPages) > 0 {
+			losses.add(field+".errorPages", "error pages are not supported")
+		}
+		if rule.HTTP == nil {
+			continue
+		}
+
+		var paths []netv1.HTTPIngressPath
+		for j := range rule.HTTP.Paths {
+			if path, ok := toKubernetesPath(ing.Namespace, &rule.HTTP.Paths[j], fmt.Sprintf("%s.http.paths[%d]", field, j), &losses); ok {
+				paths = append(paths, path)
+			}
+		}
+		if len(paths) == 0 {
+			continue
+		}
+		// The rules of the Kubernetes Ingresses have a single host.
+		for _, host := range rule.Hosts {
+			out.Spec.Rules = append(out.Spec.Rules, netv1.IngressRule{
+				Host: host,
+				IngressRuleValue: netv1.IngressRuleValue{
+					HTTP: &netv1.HTTPIngressRuleValue{
+						Paths: append([]netv1.HTTPIngressPath(nil), paths...),
+					},
+				},
+			})
+		}
+	}
+	return out, losses
+}
+
+// toKubernetesPath converts the Knative path, returning false if it can't
+// be converted.
+func toKubernetesPath(namespace string, path *v1alpha1.HTTPIngressPath, field string, losses *report) (netv1.HTTPIngressPath, bool) {
+	if len(path.Headers) > 0 {
+		losses.add(field+".headers", "header matches are not supported")
+	}
+	if path.RewriteHost != "" {
+		losses.add(field+".rewriteHost", "host rewrites are not supported")
+	}
+	if len(path.AppendHeaders) > 0 {
+		losses.add(field+".appendHeaders", "appending headers is not supported")
+	}
+	if len(path.SetHeaders) > 0 {
+		losses.add(field+".setHeaders", "setting headers is not supported")
+	}
+	if len(path.RemoveHeaders) > 0 {
+		losses.add(field+".removeHeaders", "removing headers is not supported")
+	}
+	if path.MaxRequestBodyBytes != nil {
+		losses.add(field+".maxRequestBodyBytes", "request body limits are not supported")
+	}
+	if path.IdleTimeout != nil {
+		losses.add(field+".idleTimeout", "idle timeouts are not supported")
+	}
+	if path.ConnectTimeout != nil {
+		losses.add(field+".connectTimeout", "connect timeouts are not supported")
+	}
+	if path.Compression != nil {
+		losses.add(field+".compression", "compression is not supported")
+	}
+
+	// Only one Service receives the traffic, the one with the largest share.
+	var split *v1alpha1.IngressBackendSplit
+	for i := range path.Splits {
+		s := &path.Splits[i]
+		if len(s.Headers) > 0 || len(s.AppendHeaders) > 0 || len(s.SetHeaders) > 0 || len(s.RemoveHeaders) > 0 {
+			losses.add(fmt.Sprintf("%s.splits[%d]", field, i), "header matches and modifying headers are not supported")
+		}
+		if s.LoadBalancerPolicy != nil {
+			losses.add(fmt.Sprintf("%s.splits[%d].loadBalancerPolicy", field, i), "load balancer policies are not supported")
+		}
+		if s.UpstreamTLS != nil {
+			losses.add(fmt.Sprintf("%s.splits[%d].upstreamTLS", field, i), "upstream TLS is not supported, the traffic is sent in plaintext")
+		}
+		if s.OutlierDetection != nil {
+			losses.add(fmt.Sprintf("%s.splits[%d].outlierDetection", field, i), "outlier detection is not supported")
+		}
+		if s.HealthCheck != nil {
+			losses.add(fmt.Sprintf("%s.splits[%d].healthCheck", field, i), "active health checks are not supported")
+		}
+		if s.External != nil {
+			losses.add(fmt.Sprintf("%s.splits[%d].external", field, i), "external backends are not supported")
+			continue
+		}
+		if split == nil || s.Percent > split.Percent {
+			split = s
+		}
+	}
+	if split == nil {
+		return netv1.HTTPIngressPath{}, false
+	}
+	if len(path.Splits) > 1 {
+		losses.add(field+".splits", "traffic splits are not supported, all the traffic is routed to %s", split.ServiceName)
+	}
+	if split.ServiceNamespace != namespace {
+		losses.add(field+".splits", "services of other namespaces than %q are not supported", namespace)
+		return netv1.HTTPIngressPath{}, false
+	}
+
+	// The Knative paths are literal prefixes, while the Kubernetes prefixes
+	// match whole path elements.
+	pathType := netv1.PathTypePrefix
+	p := path.Path
+	switch {
+	case p == "":
+		p = "/"
+	case !strings.HasSuffix(p, "/"):
+		pathType = netv1.PathTypeImplementationSpecific
+		losses.add(field+".path", "literal prefixes are matched as %s paths", pathType)
+	}
+	return netv1.HTTPIngressPath{
+		Path:     p,
+		PathType: &pathType,
+		Backend: netv1.IngressBackend{
+			Service: &netv1.IngressServiceBackend{
+				Name: split.ServiceName,
+				Port: toServiceBackendPort(split.ServicePort),
+			},
+		},
+	}, true
+}
+
+// toServiceBackendPort converts the port of a Knative backend.
+func toServiceBackendPort(port intstr.IntOrString) netv1.ServiceBackendPort {
+	if port.Type == intstr.String {
+		return netv1.ServiceBackendPort{Name: port.StrVal}
+	}
+	return netv1.ServiceBackendPort{Number: port.IntVal}
+}
+
+// FromKubernetes converts the Kubernetes Ingress into a Knative Ingress
+// exposing its rules publicly, reporting the parts of the Ingress not
+// preserved. The class of the Knative Ingress is left for the caller to set.
+//
+// The Knative Ingresses have no default backend, and match the paths as
+// literal prefixes: the exact paths and the prefixes not ending with a '/'
+// match more requests once converted.
+func FromKubernetes(ing *netv1.Ingress) (*v1alpha1.Ingress, []Loss) {
+	var losses report
+	out := &v1alpha1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        ing.Name,
+			Namespace:   ing.Namespace,
+			Labels:      copyMap(ing.Labels),
+			Annotations: copyMap(ing.Annotations),
+		},
+	}
+	if ing.Spec.IngressClassName != nil {
+		losses.add("spec.ingressClassName", "the Kubernetes Ingress classes don't apply to Knative Ingresses")
+	}
+	if ing.Spec.DefaultBackend != nil {
+		losses.add("spec.defaultBackend", "default backends are not supported")
+	}
+
+	for i, tls := range ing.Spec.TLS {
+		if len(tls.Hosts) == 0 {
+			losses.add(fmt.Sprintf("spec.tls[%d].hosts", i), "TLS without hosts is not supported")
+			continue
+		}
+		out.Spec.TLS = append(out.Spec.TLS, v1alpha1.IngressTLS{
+			Hosts:           append([]string(nil), tls.Hosts...),
+			SecretName:      tls.SecretName,
+			SecretNamespace: ing.Namespace,
+			Visibility:      v1alpha1.IngressVisibilityExternalIP,
+		})
+	}
+
+	for i, rule := range ing.Spec.Rules {
+		field := fmt.Sprintf("spec.rules[%d]", i)
+		if rule.Host == "" {
+			losses.add(field+".host", "rules without host are not supported")
+			continue
+		}
+		if rule.HTTP == nil {
+			continue
+		}
+		var paths []v1alpha1.HTTPIngressPath
+		for j, path := range rule.HTTP.Paths {
+			pathField := fmt.Sprintf("%s.http.paths[%d]", field, j)
+			if path.Backend.Service == nil {
+				losses.add(pathField+".backend", "only Service backends are supported")
+				continue
+			}
+			if path.PathType != nil && *path.PathType == netv1.PathTypeExact {
+				losses.add(pathField+".pathType", "exact paths are matched as literal prefixes")
+			} else if path.Path != "" && !strings.HasSuffix(path.Path, "/") {
+				losses.add(pathField+".path", "paths not ending with a '/' are matched as literal prefixes")
+			}
+			p := path.Path
+			if p == "/" {
+				p = ""
+			}
+			paths = append(paths, v1alpha1.HTTPIngressPath{
+				Path: p,
+				Splits: []v1alpha1.IngressBackendSplit{{
+					IngressBackend: v1alpha1.IngressBackend{
+						ServiceNamespace: ing.Namespace,
+						ServiceName:      path.Backend.Service.Name,
+						ServicePort:      fromServiceBackendPort(path.Backend.Service.Port),
+					},
+					Percent: 100,
+				}},
+			})
+		}
+		if len(paths) == 0 {
+			continue
+		}
+		out.Spec.Rules = append(out.Spec.Rules, v1alpha1.IngressRule{
+			Hosts:      []string{rule.Host},
+			Visibility: v1alpha1.IngressVisibilityExternalIP,
+			HTTP:       &v1alpha1.HTTPIngressRuleValue{Paths: paths},
+		})
+	}
+	return out, losses
+}
+
+// fromServiceBackendPort converts the port of a Kubernetes Service backend.
+func fromServiceBackendPort(port netv1.ServiceBackendPort) intstr.IntOrString {
+	if port.Name != "" {
+		return intstr.FromString(port.Name)
+	}
+	return intstr.FromInt(int(port.Number))
+}
+
+// copyMap copies the labels or annotations of the converted Ingress, keeping
+// them unset if they are.
+func copyMap(m map[string]string) map[string]string {
+	if m == nil {
+		return nil
+	}
+	return kmap.Copy(m)
+}
@@ -0,0 +1,354 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package k8singress
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	netv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"knative.dev/networking/pkg/apis/networking"
+	"knative.dev/networking/pkg/apis/networking/v1alpha1"
+	"knative.dev/pkg/ptr"
+)
+
+func pathType(t netv1.PathType) *netv1.PathType {
+	return &t
+}
+
+func split(namespace, name string, port intstr.IntOrString, percent int) v1alpha1.IngressBackendSplit {
+	return v1alpha1.IngressBackendSplit{
+		IngressBackend: v1alpha1.IngressBackend{
+			ServiceNamespace: namespace,
+			ServiceName:      name,
+			ServicePort:      port,
+		},
+		Percent: percent,
+	}
+}
+
+func serviceBackend(name string, port netv1.ServiceBackendPort) netv1.IngressBackend {
+	return netv1.IngressBackend{
+		Service: &netv1.IngressServiceBackend{Name: name, Port: port},
+	}
+}
+
+func TestToKubernetes(t *testing.T) {
+	ing := &v1alpha1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "route",
+			Namespace:   "default",
+			Labels:      map[string]string{"serving.knative.dev/route": "route"},
+			Annotations: map[string]string{"foo": "bar"},
+		},
+		Spec: v1alpha1.IngressSpec{
+			TLS: []v1alpha1.IngressTLS{{
+				Hosts:           []string{"route.example.com"},
+				SecretNamespace: "default",
+				SecretName:      "route-cert",
+				CanonicalHost:   "route.example.com",
+			}, {
+				Hosts:           []string{"other.example.com"},
+				SecretNamespace: "knative-serving",
+				SecretName:      "wildcard-cert",
+			}},
+			L4Rules: []v1alpha1.IngressL4Rule{{
+				Port:    1883,
+				Backend: split("default", "mqtt", intstr.FromInt(1883), 100).IngressBackend,
+			}},
+			LoadBalancer:   &v1alpha1.LoadBalancerOptions{Internal: true},
+			DataplaneTrust: networking.DataplaneTrustIdentity,
+			Rules: []v1alpha1.IngressRule{{
+				Hosts:      []string{"route.example.com", "www.route.example.com"},
+				Visibility: v1alpha1.IngressVisibilityExternalIP,
+				Redirects:  []v1alpha1.HTTPRedirect{{Path: "/old", ReplacePrefix: "/new"}},
+				HTTP: &v1alpha1.HTTPIngressRuleValue{
+					Paths: []v1alpha1.HTTPIngressPath{{
+						Path:          "/api/",
+						Headers:       map[string]v1alpha1.HeaderMatch{"Foo": {Exact: "bar"}},
+						RemoveHeaders: []string{"X-Internal"},
+						Splits:        []v1alpha1.IngressBackendSplit{split("default", "api", intstr.FromString("http"), 100)},
+					}, {
+						Path: "/static",
+						Splits: []v1alpha1.IngressBackendSplit{
+							split("default", "blue", intstr.FromInt(80), 10),
+							split("default", "green", intstr.FromInt(80), 90),
+						},
+					}, {
+						Splits: []v1alpha1.IngressBackendSplit{split("other", "elsewhere", intstr.FromInt(80), 100)},
+					}, {
+						Splits: []v1alpha1.IngressBackendSplit{
+							func() v1alpha1.IngressBackendSplit {
+								s := split("default", "green", intstr.FromInt(8080), 90)
+								s.LoadBalancerPolicy = &v1alpha1.LoadBalancerPolicy{Type: v1alpha1.LoadBalancerPolicyLeastRequest}
+								s.UpstreamTLS = &v1alpha1.UpstreamTLS{CASecretName: "ca", CASecretNamespace: "default"}
+								s.OutlierDetection = &v1alpha1.OutlierDetection{ConsecutiveErrors: 5}
+								s.HealthCheck = &v1alpha1.HealthCheck{Path: "/healthz"}
+								return s
+							}(),
+							{
+								IngressBackend: v1alpha1.IngressBackend{
+									External: &v1alpha1.ExternalBackend{Host: "example.org", Port: 443},
+								},
+								Percent: 10,
+							},
+						},
+						MaxRequestBodyBytes: ptr.Int64(1024),
+						IdleTimeout:         &metav1.Duration{Duration: time.Hour},
+						ConnectTimeout:      &metav1.Duration{Duration: time.Second},
+						Compression:         &v1alpha1.Compression{Mode: v1alpha1.CompressionEnabled},
+					}},
+				},
+			}, {
+				Hosts:      []string{"route.default.svc.cluster.local"},
+				Visibility: v1alpha1.IngressVisibilityClusterLocal,
+				HTTP: &v1alpha1.HTTPIngressRuleValue{
+					Paths: []v1alpha1.HTTPIngressPath{{
+						Splits: []v1alpha1.IngressBackendSplit{split("default", "green", intstr.FromInt(80), 100)},
+					}},
+				},
+			}},
+		},
+	}
+
+	paths := []netv1.HTTPIngressPath{{
+		Path:     "/api/",
+		PathType: pathType(netv1.PathTypePrefix),
+		Backend:  serviceBackend("api", netv1.ServiceBackendPort{Name: "http"}),
+	}, {
+		Path:     "/static",
+		PathType: pathType(netv1.PathTypeImplementationSpecific),
+		Backend:  serviceBackend("green", netv1.ServiceBackendPort{Number: 80}),
+	}, {
+		Path:     "/",
+		PathType: pathType(netv1.PathTypePrefix),
+		Backend:  serviceBackend("green", netv1.ServiceBackendPort{Number: 8080}),
+	}}
+	want := &netv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "route",
+			Namespace:   "default",
+			Labels:      map[string]string{"serving.knative.dev/route": "route"},
+			Annotations: map[string]string{"foo": "bar"},
+		},
+		Spec: netv1.IngressSpec{
+			IngressClassName: ptr.String("nginx"),
+			TLS: []netv1.IngressTLS{{
+				Hosts:      []string{"route.example.com"},
+				SecretName: "route-cert",
+			}},
+			Rules: []netv1.IngressRule{{
+				Host: "route.example.com",
+				IngressRuleValue: netv1.IngressRuleValue{
+					HTTP: &netv1.HTTPIngressRuleValue{Paths: paths},
+				},
+			}, {
+				Host: "www.route.example.com",
+				IngressRuleValue: netv1.IngressRuleValue{
+					HTTP: &netv1.HTTPIngressRuleValue{Paths: paths},
+				},
+			}},
+		},
+	}
+	wantLosses := []Loss{
+		{"spec.tls[0].canonicalHost", "canonical host redirects are not supported"},
+		{"spec.tls[1].secretNamespace", `secrets of other namespaces than "default" are not supported`},
+		{"spec.l4Rules", "L4 rules are not supported"},
+		{"spec.loadBalancer", "load balancer options are not supported"},
+		{"spec.dataplaneTrust", "securing the internal hops is not supported, they are unencrypted"},
+		{"spec.rules[0].redirects", "redirects are not supported"},
+		{"spec.rules[0].http.paths[0].headers", "header matches are not supported"},
+		{"spec.rules[0].http.paths[0].removeHeaders", "removing headers is not supported"},
+		{"spec.rules[0].http.paths[1].splits", "traffic splits are not supported, all the traffic is routed to green"},
+		{"spec.rules[0].http.paths[1].path", "literal prefixes are matched as ImplementationSpecific paths"},
+		{"spec.rules[0].http.paths[2].splits", `services of other namespaces than "default" are not supported`},
+		{"spec.rules[0].http.paths[3].maxRequestBodyBytes", "request body limits are not supported"},
+		{"spec.rules[0].http.paths[3].idleTimeout", "idle timeouts are not supported"},
+		{"spec.rules[0].http.paths[3].connectTimeout", "connect timeouts are not supported"},
+		{"spec.rules[0].http.paths[3].compression", "compression is not supported"},
+		{"spec.rules[0].http.paths[3].splits[0].loadBalancerPolicy", "load balancer policies are not supported"},
+		{"spec.rules[0].http.paths[3].splits[0].upstreamTLS", "upstream TLS is not supported, the traffic is sent in plaintext"},
+		{"spec.rules[0].http.paths[3].splits[0].outlierDetection", "outlier detection is not supported"},
+		{"spec.rules[0].http.paths[3].splits[0].healthCheck", "active health checks are not supported"},
+		{"spec.rules[0].http.paths[3].splits[1].external", "external backends are not supported"},
+		{"spec.rules[0].http.paths[3].splits", "traffic splits are not supported, all the traffic is routed to green"},
+		{"spec.rules[1]", "cluster-local rules are not supported"},
+	}
+
+	got, losses := ToKubernetes(ing, "nginx")
+	if !cmp.Equal(got, want) {
+		t.Error("ToKubernetes (-want, +got) =", cmp.Diff(want, got))
+	}
+	if !cmp.Equal(losses, wantLosses) {
+		t.Error("Losses (-want, +got) =", cmp.Diff(wantLosses, losses))
+	}
+}
+
+func TestFromKubernetes(t *testing.T) {
+	ing := &netv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "app",
+			Namespace: "default",
+			Labels:    map[string]string{"app": "app"},
+		},
+		Spec: netv1.IngressSpec{
+			IngressClassName: ptr.String("nginx"),
+			DefaultBackend:   &netv1.IngressBackend{Service: &netv1.IngressServiceBackend{Name: "default"}},
+			TLS: []netv1.IngressTLS{{
+				Hosts:      []string{"app.example.com"},
+				SecretName: "app-cert",
+			}, {
+				SecretName: "default-cert",
+			}},
+			Rules: []netv1.IngressRule{{
+				Host: "app.example.com",
+				IngressRuleValue: netv1.IngressRuleValue{
+					HTTP: &netv1.HTTPIngressRuleValue{
+						Paths: []netv1.HTTPIngressPath{{
+							Path:     "/api/",
+							PathType: pathType(netv1.PathTypePrefix),
+							Backend:  serviceBackend("api", netv1.ServiceBackendPort{Name: "http"}),
+						}, {
+							Path:     "/healthz",
+							PathType: pathType(netv1.PathTypeExact),
+							Backend:  serviceBackend("app", netv1.ServiceBackendPort{Number: 8080}),
+						}, {
+							Path:     "/assets",
+							PathType: pathType(netv1.PathTypePrefix),
+							Backend: netv1.IngressBackend{
+								Resource: &corev1.TypedLocalObjectReference{Kind: "Bucket", Name: "assets"},
+							},
+						}, {
+							Path:     "/",
+							PathType: pathType(netv1.PathTypePrefix),
+							Backend:  serviceBackend("app", netv1.ServiceBackendPort{Number: 80}),
+						}},
+					},
+				},
+			}, {
+				IngressRuleValue: netv1.IngressRuleValue{
+					HTTP: &netv1.HTTPIngressRuleValue{
+						Paths: []netv1.HTTPIngressPath{{
+							Path:     "/",
+							PathType: pathType(netv1.PathTypePrefix),
+							Backend:  serviceBackend("app", netv1.ServiceBackendPort{Number: 80}),
+						}},
+					},
+				},
+			}},
+		},
+	}
+
+	backend := func(name string, port intstr.IntOrString) []v1alpha1.IngressBackendSplit {
+		return []v1alpha1.IngressBackendSplit{split("default", name, port, 100)}
+	}
+	want := &v1alpha1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "app",
+			Namespace: "default",
+			Labels:    map[string]string{"app": "app"},
+		},
+		Spec: v1alpha1.IngressSpec{
+			TLS: []v1alpha1.IngressTLS{{
+				Hosts:           []string{"app.example.com"},
+				SecretName:      "app-cert",
+				SecretNamespace: "default",
+				Visibility:      v1alpha1.IngressVisibilityExternalIP,
+			}},
+			Rules: []v1alpha1.IngressRule{{
+				Hosts:      []string{"app.example.com"},
+				Visibility: v1alpha1.IngressVisibilityExternalIP,
+				HTTP: &v1alpha1.HTTPIngressRuleValue{
+					Paths: []v1alpha1.HTTPIngressPath{{
+						Path:   "/api/",
+						Splits: backend("api", intstr.FromString("http")),
+					}, {
+						Path:   "/healthz",
+						Splits: backend("app", intstr.FromInt(8080)),
+					}, {
+						Splits: backend("app", intstr.FromInt(80)),
+					}},
+				},
+			}},
+		},
+	}
+	wantLosses := []Loss{
+		{"spec.ingressClassName", "the Kubernetes Ingress classes don't apply to Knative Ingresses"},
+		{"spec.defaultBackend", "default backends are not supported"},
+		{"spec.tls[1].hosts", "TLS without hosts is not supported"},
+		{"spec.rules[0].http.paths[1].pathType", "exact paths are matched as literal prefixes"},
+		{"spec.rules[0].http.paths[2].backend", "only Service backends are supported"},
+		{"spec.rules[1].host", "rules without host are not supported"},
+	}
+
+	got, losses := FromKubernetes(ing)
+	if !cmp.Equal(got, want) {
+		t.Error("FromKubernetes (-want, +got) =", cmp.Diff(want, got))
+	}
+	if !cmp.Equal(losses, wantLosses) {
+		t.Error("Losses (-want, +got) =", cmp.Diff(wantLosses, losses))
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	ing := &v1alpha1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{Name: "route", Namespace: "default"},
+		Spec: v1alpha1.IngressSpec{
+			TLS: []v1alpha1.IngressTLS{{
+				Hosts:           []string{"route.example.com"},
+				SecretNamespace: "default",
+				SecretName:      "route-cert",
+				Visibility:      v1alpha1.IngressVisibilityExternalIP,
+			}},
+			Rules: []v1alpha1.IngressRule{{
+				Hosts:      []string{"route.example.com"},
+				Visibility: v1alpha1.IngressVisibilityExternalIP,
+				HTTP: &v1alpha1.HTTPIngressRuleValue{
+					Paths: []v1alpha1.HTTPIngressPath{{
+						Path:   "/api/",
+						Splits: []v1alpha1.IngressBackendSplit{split("default", "api", intstr.FromInt(80), 100)},
+					}, {
+						Splits: []v1alpha1.IngressBackendSplit{split("default", "app", intstr.FromString("http"), 100)},
+					}},
+				},
+			}},
+		},
+	}
+
+	k8s, losses := ToKubernetes(ing, "")
+	if len(losses) > 0 {
+		t.Error("ToKubernetes() losses =", losses)
+	}
+	got, losses := FromKubernetes(k8s)
+	if len(losses) > 0 {
+		t.Error("FromKubernetes() losses =", losses)
+	}
+	if !cmp.Equal(got, ing) {
+		t.Error("Round trip (-want, +got) =", cmp.Diff(ing, got))
+	}
+}
+
+func TestLossString(t *testing.T) {
+	l := Loss{Field: "spec.rules[0].redirects", Reason: "redirects are not supported"}
+	if got, want := l.String(), "spec.rules[0].redirects: redirects are not supported"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
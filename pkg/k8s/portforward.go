@@ -0,0 +1,283 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package k8s
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	// portForwardProtocol is the websocket subprotocol of the port-forward
+	// subresource: the messages are prefixed with the channel they belong
+	// to, each forwarded port having a data and an error channel.
+	portForwardProtocol = "v4.channel.k8s.io"
+
+	// The channels of the single port forwarded by a connection.
+	portForwardDataChannel  = 0
+	portForwardErrorChannel = 1
+)
+
+// PodResolver returns the Pod having the given IP, e.g. from a Pod lister
+// indexed by IP.
+type PodResolver func(ip string) (types.NamespacedName, error)
+
+// PortForwardDialer dials the ports of Pods through the port-forward
+// subresource of the API server, so that controllers running outside of the
+// cluster, e.g. in development or in some hosted control planes, can still
+// reach the Pods directly. Each connection is tunneled through its own
+// websocket, using the same credentials as the clients of the config.
+type PortForwardDialer struct {
+	config  *rest.Config
+	host    *url.URL
+	dialer  *websocket.Dialer
+	resolve PodResolver
+}
+
+// NewPortForwardDialer creates a PortForwardDialer reaching the API server
+// of the config, and looking up the Pods of the dialed IPs with resolve.
+func NewPortForwardDialer(config *rest.Config, resolve PodResolver) (*PortForwardDialer, error) {
+	host, _, err := rest.DefaultServerURL(config.Host, config.APIPath, corev1.SchemeGroupVersion, rest.IsConfigTransportTLS(*config))
+	if err != nil {
+		return nil, fmt.Errorf("invalid API server host %q: %w", config.Host, err)
+	}
+	tlsConfig, err := rest.TLSConfigFor(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build the TLS config: %w", err)
+	}
+	proxy := config.Proxy
+	if proxy == nil {
+		proxy = http.ProxyFromEnvironment
+	}
+	return &PortForwardDialer{
+		config: config,
+		host:   host,
+		dialer: &websocket.Dialer{
+			NetDialContext:  config.Dial,
+			Proxy:           proxy,
+			TLSClientConfig: tlsConfig,
+			Subprotocols:    []string{portForwardProtocol},
+		},
+		resolve: resolve,
+	}, nil
+}
+
+// DialContext connects to the port of the Pod having the IP of address,
+// which must be a TCP address.
+func (d *PortForwardDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	switch network {
+	case "tcp", "tcp4", "tcp6":
+	default:
+		return nil, fmt.Errorf("unsupported network %q, only TCP can be port-forwarded", network)
+	}
+	ip, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	portNum, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid port %q: %w", port, err)
+	}
+	pod, err := d.resolve(ip)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find the Pod of %s: %w", ip, err)
+	}
+
+	u := *d.host
+	switch u.Scheme {
+	case "https":
+		u.Scheme = "wss"
+	default:
+		u.Scheme = "ws"
+	}
+	u.Path = path.Join(u.Path, "api/v1/namespaces", pod.Namespace, "pods", pod.Name, "portforward")
+	u.RawQuery = url.Values{"ports": {port}}.Encode()
+
+	// The wrappers of the config authenticate the handshake.
+	upgrader := &websocketUpgrader{dialer: d.dialer}
+	rt, err := rest.HTTPWrappersForConfig(d.config, upgrader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build the API server transport: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := rt.RoundTrip(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to port-forward to %s/%s: %w", pod.Namespace, pod.Name, err)
+	}
+	resp.Body.Close()
+
+	return &portForwardConn{
+		ws:     upgrader.conn,
+		remote: &net.TCPAddr{IP: net.ParseIP(ip), Port: int(portNum)},
+	}, nil
+}
+
+// websocketUpgrader is an http.RoundTripper performing the websocket
+// handshake of the requests, keeping the established connection.
+type websocketUpgrader struct {
+	dialer *websocket.Dialer
+	conn   *websocket.Conn
+}
+
+// RoundTrip implements http.RoundTripper.
+func (u *websocketUpgrader) RoundTrip(req *http.Request) (*http.Response, error) {
+	conn, resp, err := u.dialer.DialContext(req.Context(), req.URL.String(), req.Header)
+	if err != nil {
+		if resp != nil {
+			return nil, fmt.Errorf("%w (status: %d)", err, resp.StatusCode)
+		}
+		return nil, err
+	}
+	u.conn = conn
+	return resp, nil
+}
+
+// portForwardConn is a connection to a Pod port tunneled through the
+// websocket of a port-forward. Only the data channel is written to.
+type portForwardConn struct {
+	ws     *websocket.Conn
+	remote net.Addr
+
+	// readMu guards the fields below, only used by Read.
+	readMu sync.Mutex
+	// pending is the data of the current message not read yet.
+	pending []byte
+	// prefixed records the channels whose port prefix, sent first by the
+	// server, has been skipped.
+	prefixed [2]bool
+
+	// writeMu serializes the writes, the websocket supporting only one
+	// concurrent writer.
+	writeMu sync.Mutex
+}
+
+var _ net.Conn = (*portForwardConn)(nil)
+
+// Read implements net.Conn.
+func (c *portForwardConn) Read(b []byte) (int, error) {
+	c.readMu.Lock()
+	defer c.readMu.Unlock()
+	for len(c.pending) == 0 {
+		_, msg, err := c.ws.ReadMessage()
+		if err != nil {
+			if websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+				return 0, io.EOF
+			}
+			return 0, err
+		}
+		if len(msg) == 0 {
+			continue
+		}
+		channel, data := msg[0], msg[1:]
+		if channel > portForwardErrorChannel {
+			return 0, fmt.Errorf("unexpected port-forward channel %d", channel)
+		}
+		if !c.prefixed[channel] {
+			// Each channel starts with the port it forwards.
+			if len(data) < 2 {
+				return 0, errors.New("truncated port-forward channel prefix")
+			}
+			data, c.prefixed[channel] = data[2:], true
+		}
+		if channel == portForwardErrorChannel {
+			if len(data) > 0 {
+				return 0, fmt.Errorf("port-forward error: %s", data)
+			}
+			continue
+		}
+		c.pending = data
+	}
+	n := copy(b, c.pending)
+	c.pending = c.pending[n:]
+	return n, nil
+}
+
+// Write implements net.Conn.
+func (c *portForwardConn) Write(b []byte) (int, error) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	msg := make([]byte, 0, len(b)+1)
+	msg = append(append(msg, portForwardDataChannel), b...)
+	if err := c.ws.WriteMessage(websocket.BinaryMessage, msg); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// Close implements net.Conn.
+func (c *portForwardConn) Close() error {
+	return c.ws.Close()
+}
+
+// LocalAddr implements net.Conn.
+func (c *portForwardConn) LocalAddr() net.Addr {
+	return c.ws.LocalAddr()
+}
+
+// RemoteAddr implements net.Conn, returning the address of the Pod.
+func (c *portForwardConn) RemoteAddr() net.Addr {
+	return c.remote
+}
+
+// SetDeadline implements net.Conn.
+func (c *portForwardConn) SetDeadline(t time.Time) error {
+	if err := c.ws.SetReadDeadline(t); err != nil {
+		return err
+	}
+	return c.ws.SetWriteDeadline(t)
+}
+
+// SetReadDeadline implements net.Conn.
+func (c *portForwardConn) SetReadDeadline(t time.Time) error {
+	return c.ws.SetReadDeadline(t)
+}
+
+// SetWriteDeadline implements net.Conn.
+func (c *portForwardConn) SetWriteDeadline(t time.Time) error {
+	return c.ws.SetWriteDeadline(t)
+}
+
+// NewPortForwardTransport returns a transport reaching the Pod IPs of the
+// requests through the port-forward subresource, e.g. for the probes sent
+// directly to Pods by controllers running outside of the cluster. Like the
+// prober transports, it doesn't keep the connections alive. Probes setting
+// their own dial options should use prober.WithDialContext(d.DialContext)
+// instead.
+func NewPortForwardTransport(d *PortForwardDialer) http.RoundTripper {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = nil
+	t.DialContext = d.DialContext
+	t.DisableKeepAlives = true
+	return t
+}
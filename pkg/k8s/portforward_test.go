@@ -0,0 +1,195 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package k8s
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/rest"
+)
+
+const testToken = "s3cr3t"
+
+// fakePortForward serves the port-forward subresource of the Pod
+// "ns/pod", tunneling the connections to backend. With errMsg set, it
+// reports the given error on the error channel instead.
+func fakePortForward(t *testing.T, backend, errMsg string) *httptest.Server {
+	upgrader := websocket.Upgrader{Subprotocols: []string{portForwardProtocol}}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got, want := r.URL.Path, "/api/v1/namespaces/ns/pods/pod/portforward"; got != want {
+			http.Error(w, "unexpected path "+got, http.StatusNotFound)
+			return
+		}
+		if got, want := r.Header.Get("Authorization"), "Bearer "+testToken; got != want {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		port, err := strconv.ParseUint(r.URL.Query().Get("ports"), 10, 16)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		ws, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Error("Upgrade() =", err)
+			return
+		}
+		defer ws.Close()
+
+		// Each channel starts with the forwarded port.
+		prefix := make([]byte, 2)
+		binary.LittleEndian.PutUint16(prefix, uint16(port))
+		for _, channel := range []byte{portForwardDataChannel, portForwardErrorChannel} {
+			ws.WriteMessage(websocket.BinaryMessage, append([]byte{channel}, prefix...))
+		}
+		if errMsg != "" {
+			ws.WriteMessage(websocket.BinaryMessage, append([]byte{portForwardErrorChannel}, errMsg...))
+			return
+		}
+
+		conn, err := net.Dial("tcp", backend)
+		if err != nil {
+			t.Error("Dial() =", err)
+			return
+		}
+		defer conn.Close()
+		go func() {
+			buf := make([]byte, 32*1024)
+			for {
+				n, err := conn.Read(buf)
+				if n > 0 {
+					ws.WriteMessage(websocket.BinaryMessage, append([]byte{portForwardDataChannel}, buf[:n]...))
+				}
+				if err != nil {
+					ws.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+					return
+				}
+			}
+		}()
+		for {
+			_, msg, err := ws.ReadMessage()
+			if err != nil {
+				return
+			}
+			if len(msg) > 0 && msg[0] == portForwardDataChannel {
+				conn.Write(msg[1:])
+			}
+		}
+	}))
+}
+
+func TestPortForwardTransport(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello from " + r.Host))
+	}))
+	defer backend.Close()
+	backendURL, _ := url.Parse(backend.URL)
+	_, backendPort, _ := net.SplitHostPort(backendURL.Host)
+
+	resolver := func(ip string) (types.NamespacedName, error) {
+		if ip != "10.0.0.1" {
+			return types.NamespacedName{}, errors.New("no Pod with IP " + ip)
+		}
+		return types.NamespacedName{Namespace: "ns", Name: "pod"}, nil
+	}
+
+	tests := []struct {
+		name    string
+		url     string
+		token   string
+		errMsg  string
+		want    string
+		wantErr string
+	}{{
+		name:  "forwarded",
+		url:   "http://10.0.0.1:" + backendPort,
+		token: testToken,
+		want:  "hello from 10.0.0.1:" + backendPort,
+	}, {
+		name:    "unknown pod",
+		url:     "http://10.0.0.2:" + backendPort,
+		token:   testToken,
+		wantErr: "no Pod with IP 10.0.0.2",
+	}, {
+		name:    "unauthorized",
+		url:     "http://10.0.0.1:" + backendPort,
+		token:   "wrong",
+		wantErr: "status: 401",
+	}, {
+		name:    "port-forward error",
+		url:     "http://10.0.0.1:" + backendPort,
+		token:   testToken,
+		errMsg:  "connection refused",
+		wantErr: "port-forward error: connection refused",
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			apiServer := fakePortForward(t, backendURL.Host, test.errMsg)
+			defer apiServer.Close()
+
+			d, err := NewPortForwardDialer(&rest.Config{
+				Host:        apiServer.URL,
+				BearerToken: test.token,
+			}, resolver)
+			if err != nil {
+				t.Fatal("NewPortForwardDialer() =", err)
+			}
+			client := &http.Client{Transport: NewPortForwardTransport(d)}
+			resp, err := client.Get(test.url)
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("Get() = %v, want error containing %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal("Get() =", err)
+			}
+			defer resp.Body.Close()
+			body, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatal("ReadAll() =", err)
+			}
+			if got := string(body); got != test.want {
+				t.Errorf("Body = %q, want: %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestPortForwardDialerNetwork(t *testing.T) {
+	d, err := NewPortForwardDialer(&rest.Config{Host: "https://127.0.0.1:6443"}, nil)
+	if err != nil {
+		t.Fatal("NewPortForwardDialer() =", err)
+	}
+	if _, err := d.DialContext(context.Background(), "udp", "10.0.0.1:53"); err == nil {
+		t.Error("DialContext(udp) succeeded, want error")
+	}
+}
@@ -0,0 +1,198 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package k8s
+
+import (
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/tools/cache"
+)
+
+// endpointsSource is the source key of the addresses reported by the Endpoints
+// object of a service.
+const endpointsSource = "endpoints"
+
+// ReadyAddressesChange describes a change of the ready addresses of a service.
+type ReadyAddressesChange struct {
+	// Service is the service whose ready addresses changed.
+	Service types.NamespacedName
+	// Ready is the set of ready addresses after the change.
+	Ready sets.String
+	// Added is the set of addresses which became ready.
+	Added sets.String
+	// Removed is the set of addresses which are not ready anymore.
+	Removed sets.String
+}
+
+// ReadyAddressesCallback is invoked when the ready addresses of a watched service change.
+type ReadyAddressesCallback func(ReadyAddressesChange)
+
+// ReadyAddressWatcher tracks the ready addresses of services from their Endpoints
+// and EndpointSlices, and notifies the callbacks registered for a service when they
+// change. It implements cache.ResourceEventHandler, so it can be registered on
+// Endpoints and EndpointSlice informers.
+type ReadyAddressWatcher struct {
+	// mu guards sources and callbacks.
+	mu sync.Mutex
+	// sources holds, per service, the ready addresses reported by each of its
+	// Endpoints and EndpointSlice objects.
+	sources   map[types.NamespacedName]map[string]sets.String
+	callbacks map[types.NamespacedName]ReadyAddressesCallback
+}
+
+var _ cache.ResourceEventHandler = (*ReadyAddressWatcher)(nil)
+
+// NewReadyAddressWatcher creates a new ReadyAddressWatcher.
+func NewReadyAddressWatcher() *ReadyAddressWatcher {
+	return &ReadyAddressWatcher{
+		sources:   make(map[types.NamespacedName]map[string]sets.String),
+		callbacks: make(map[types.NamespacedName]ReadyAddressesCallback),
+	}
+}
+
+// Watch registers the callback for the given service, replacing any previous one.
+// If the service already has ready addresses, the callback is invoked right away
+// with all of them as added.
+func (w *ReadyAddressWatcher) Watch(svc types.NamespacedName, cb ReadyAddressesCallback) {
+	w.mu.Lock()
+	w.callbacks[svc] = cb
+	ready := w.readyLocked(svc)
+	w.mu.Unlock()
+
+	if ready.Len() > 0 {
+		cb(ReadyAddressesChange{
+			Service: svc,
+			Ready:   ready,
+			Added:   ready,
+			Removed: sets.NewString(),
+		})
+	}
+}
+
+// Unwatch unregisters the callback of the given service.
+func (w *ReadyAddressWatcher) Unwatch(svc types.NamespacedName) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	delete(w.callbacks, svc)
+}
+
+// ReadyAddresses returns the current ready addresses of the given service.
+func (w *ReadyAddressWatcher) ReadyAddresses(svc types.NamespacedName) sets.String {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.readyLocked(svc)
+}
+
+// OnAdd implements cache.ResourceEventHandler.
+func (w *ReadyAddressWatcher) OnAdd(obj interface{}) {
+	if svc, source, addrs, ok := readyAddresses(obj); ok {
+		w.update(svc, source, addrs)
+	}
+}
+
+// OnUpdate implements cache.ResourceEventHandler.
+func (w *ReadyAddressWatcher) OnUpdate(_, obj interface{}) {
+	w.OnAdd(obj)
+}
+
+// OnDelete implements cache.ResourceEventHandler.
+func (w *ReadyAddressWatcher) OnDelete(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	if svc, source, _, ok := readyAddresses(obj); ok {
+		w.update(svc, source, nil)
+	}
+}
+
+// update records the ready addresses reported by the given source of the
+// service, a nil set meaning that the source is gone, and invokes the
+// callback of the service if its ready addresses changed.
+func (w *ReadyAddressWatcher) update(svc types.NamespacedName, source string, addrs sets.String) {
+	w.mu.Lock()
+	before := w.readyLocked(svc)
+	sources := w.sources[svc]
+	if addrs == nil {
+		delete(sources, source)
+		if len(sources) == 0 {
+			delete(w.sources, svc)
+		}
+	} else {
+		if sources == nil {
+			sources = make(map[string]sets.String, 1)
+			w.sources[svc] = sources
+		}
+		sources[source] = addrs
+	}
+	after := w.readyLocked(svc)
+	cb := w.callbacks[svc]
+	w.mu.Unlock()
+
+	if cb == nil || before.Equal(after) {
+		return
+	}
+	// Invoke the callback without holding the lock, so it can call back into the watcher.
+	cb(ReadyAddressesChange{
+		Service: svc,
+		Ready:   after,
+		Added:   after.Difference(before),
+		Removed: before.Difference(after),
+	})
+}
+
+// readyLocked returns the union of the ready addresses reported by all the sources of svc.
+// mu must be held.
+func (w *ReadyAddressWatcher) readyLocked(svc types.NamespacedName) sets.String {
+	ready := sets.NewString()
+	for _, addrs := range w.sources[svc] {
+		ready = ready.Union(addrs)
+	}
+	return ready
+}
+
+// readyAddresses extracts the service, the source key and the ready addresses
+// of Endpoints and EndpointSlice objects. ok is false for other objects.
+func readyAddresses(obj interface{}) (svc types.NamespacedName, source string, addrs sets.String, ok bool) {
+	switch o := obj.(type) {
+	case *corev1.Endpoints:
+		addrs = sets.NewString()
+		for _, subset := range o.Subsets {
+			for _, addr := range subset.Addresses {
+				addrs.Insert(addr.IP)
+			}
+		}
+		return types.NamespacedName{Namespace: o.Namespace, Name: o.Name}, endpointsSource, addrs, true
+	case *discoveryv1.EndpointSlice:
+		name, has := o.Labels[discoveryv1.LabelServiceName]
+		if !has {
+			return svc, "", nil, false
+		}
+		addrs = sets.NewString()
+		for _, ep := range o.Endpoints {
+			// A nil Ready condition must be interpreted as ready.
+			if ep.Conditions.Ready == nil || *ep.Conditions.Ready {
+				addrs.Insert(ep.Addresses...)
+			}
+		}
+		return types.NamespacedName{Namespace: o.Namespace, Name: name}, "endpointslice/" + o.Name, addrs, true
+	}
+	return svc, "", nil, false
+}
@@ -0,0 +1,191 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package k8s
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/tools/cache"
+)
+
+var (
+	svcKey = types.NamespacedName{Namespace: "ns", Name: "gateway"}
+
+	readyTrue, readyFalse = true, false
+)
+
+func endpoints(ready, notReady []string) *corev1.Endpoints {
+	ep := &corev1.Endpoints{
+		ObjectMeta: metav1.ObjectMeta{Namespace: svcKey.Namespace, Name: svcKey.Name},
+		Subsets:    []corev1.EndpointSubset{{}},
+	}
+	for _, ip := range ready {
+		ep.Subsets[0].Addresses = append(ep.Subsets[0].Addresses, corev1.EndpointAddress{IP: ip})
+	}
+	for _, ip := range notReady {
+		ep.Subsets[0].NotReadyAddresses = append(ep.Subsets[0].NotReadyAddresses, corev1.EndpointAddress{IP: ip})
+	}
+	return ep
+}
+
+func endpointSlice(name string, ready map[string]*bool) *discoveryv1.EndpointSlice {
+	es := &discoveryv1.EndpointSlice{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: svcKey.Namespace,
+			Name:      name,
+			Labels:    map[string]string{discoveryv1.LabelServiceName: svcKey.Name},
+		},
+	}
+	for ip, r := range ready {
+		es.Endpoints = append(es.Endpoints, discoveryv1.Endpoint{
+			Addresses:  []string{ip},
+			Conditions: discoveryv1.EndpointConditions{Ready: r},
+		})
+	}
+	return es
+}
+
+// recorder is a fake callback recording the changes it is given.
+type recorder struct {
+	changes []ReadyAddressesChange
+}
+
+func (r *recorder) callback(c ReadyAddressesChange) {
+	r.changes = append(r.changes, c)
+}
+
+func change(ready, added, removed []string) ReadyAddressesChange {
+	return ReadyAddressesChange{
+		Service: svcKey,
+		Ready:   sets.NewString(ready...),
+		Added:   sets.NewString(added...),
+		Removed: sets.NewString(removed...),
+	}
+}
+
+func TestReadyAddressWatcherEndpoints(t *testing.T) {
+	w := NewReadyAddressWatcher()
+	r := &recorder{}
+	w.Watch(svcKey, r.callback)
+
+	w.OnAdd(endpoints([]string{"10.0.0.1"}, []string{"10.0.0.2"}))
+	w.OnUpdate(nil, endpoints([]string{"10.0.0.1", "10.0.0.2"}, nil))
+	// No change, no callback.
+	w.OnUpdate(nil, endpoints([]string{"10.0.0.2", "10.0.0.1"}, nil))
+	w.OnUpdate(nil, endpoints([]string{"10.0.0.2"}, []string{"10.0.0.1"}))
+	w.OnDelete(cache.DeletedFinalStateUnknown{Obj: endpoints([]string{"10.0.0.2"}, nil)})
+
+	want := []ReadyAddressesChange{
+		change([]string{"10.0.0.1"}, []string{"10.0.0.1"}, nil),
+		change([]string{"10.0.0.1", "10.0.0.2"}, []string{"10.0.0.2"}, nil),
+		change([]string{"10.0.0.2"}, nil, []string{"10.0.0.1"}),
+		change(nil, nil, []string{"10.0.0.2"}),
+	}
+	if !cmp.Equal(r.changes, want) {
+		t.Error("Changes (-want, +got):", cmp.Diff(want, r.changes))
+	}
+}
+
+func TestReadyAddressWatcherEndpointSlices(t *testing.T) {
+	w := NewReadyAddressWatcher()
+	r := &recorder{}
+	w.Watch(svcKey, r.callback)
+
+	w.OnAdd(endpointSlice("gateway-a", map[string]*bool{
+		"10.0.0.1": nil,
+		"10.0.0.2": &readyFalse,
+	}))
+	w.OnAdd(endpointSlice("gateway-b", map[string]*bool{
+		"10.0.0.3": &readyTrue,
+	}))
+	// The same address reported by another object doesn't change the ready set.
+	w.OnAdd(endpoints([]string{"10.0.0.3"}, nil))
+	w.OnDelete(endpointSlice("gateway-b", nil))
+	w.OnDelete(endpoints(nil, nil))
+	// Slices not owned by a service are ignored.
+	w.OnAdd(&discoveryv1.EndpointSlice{
+		ObjectMeta: metav1.ObjectMeta{Namespace: svcKey.Namespace, Name: "orphan"},
+		Endpoints:  []discoveryv1.Endpoint{{Addresses: []string{"10.0.0.9"}}},
+	})
+
+	want := []ReadyAddressesChange{
+		change([]string{"10.0.0.1"}, []string{"10.0.0.1"}, nil),
+		change([]string{"10.0.0.1", "10.0.0.3"}, []string{"10.0.0.3"}, nil),
+		change([]string{"10.0.0.1"}, nil, []string{"10.0.0.3"}),
+	}
+	if !cmp.Equal(r.changes, want) {
+		t.Error("Changes (-want, +got):", cmp.Diff(want, r.changes))
+	}
+	if got, want := w.ReadyAddresses(svcKey), sets.NewString("10.0.0.1"); !got.Equal(want) {
+		t.Errorf("ReadyAddresses = %v, want: %v", got.List(), want.List())
+	}
+}
+
+func TestReadyAddressWatcherWatch(t *testing.T) {
+	w := NewReadyAddressWatcher()
+	other := types.NamespacedName{Namespace: "ns", Name: "other"}
+	otherEndpoints := endpoints([]string{"10.0.1.1"}, nil)
+	otherEndpoints.Name = other.Name
+
+	// Events are tracked before any callback is registered.
+	w.OnAdd(endpoints([]string{"10.0.0.1"}, nil))
+	w.OnAdd(otherEndpoints)
+
+	r := &recorder{}
+	w.Watch(svcKey, r.callback)
+	want := []ReadyAddressesChange{
+		change([]string{"10.0.0.1"}, []string{"10.0.0.1"}, nil),
+	}
+	if !cmp.Equal(r.changes, want) {
+		t.Error("Changes after Watch (-want, +got):", cmp.Diff(want, r.changes))
+	}
+
+	// Changes to other services are not reported.
+	otherEndpoints = otherEndpoints.DeepCopy()
+	otherEndpoints.Subsets = nil
+	w.OnUpdate(nil, otherEndpoints)
+
+	w.Unwatch(svcKey)
+	w.OnUpdate(nil, endpoints(nil, nil))
+	if !cmp.Equal(r.changes, want) {
+		t.Error("Changes after Unwatch (-want, +got):", cmp.Diff(want, r.changes))
+	}
+}
+
+func TestReadyAddressWatcherReentrantCallback(t *testing.T) {
+	w := NewReadyAddressWatcher()
+	calls := 0
+	w.Watch(svcKey, func(c ReadyAddressesChange) {
+		calls++
+		// Calling back into the watcher must not deadlock.
+		if got := w.ReadyAddresses(c.Service); !got.Equal(c.Ready) {
+			t.Errorf("ReadyAddresses = %v, want: %v", got.List(), c.Ready.List())
+		}
+		w.Unwatch(c.Service)
+	})
+	w.OnAdd(endpoints([]string{"10.0.0.1"}, nil))
+	w.OnAdd(endpoints([]string{"10.0.0.2"}, nil))
+	if calls != 1 {
+		t.Errorf("Callback was invoked %d times, want: 1", calls)
+	}
+}
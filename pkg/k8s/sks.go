@@ -0,0 +1,115 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package k8s
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"knative.dev/networking/pkg/apis/networking"
+	"knative.dev/networking/pkg/apis/networking/v1alpha1"
+	"knative.dev/pkg/kmeta"
+)
+
+// PublicServiceName returns the name of the public K8s Service of the
+// ServerlessService with the given name, which is also the name of its
+// Endpoints.
+func PublicServiceName(sksName string) string {
+	return sksName
+}
+
+// PrivateServiceName returns the name of the private K8s Service of the
+// ServerlessService with the given name, which is also the name of its
+// Endpoints.
+func PrivateServiceName(sksName string) string {
+	return kmeta.ChildName(sksName, "-private")
+}
+
+// ServiceName returns the name of the K8s Service of the given type of the
+// ServerlessService with the given name.
+func ServiceName(sksName string, typ networking.ServiceType) string {
+	if typ == networking.ServiceTypePrivate {
+		return PrivateServiceName(sksName)
+	}
+	return PublicServiceName(sksName)
+}
+
+// SKSForService returns the name of the ServerlessService owning the K8s
+// Service or Endpoints with the given labels, and their type, or false if they
+// don't belong to a ServerlessService. The labels are used rather than the
+// name, which can't be reversed when it's shortened.
+func SKSForService(lbls map[string]string) (string, networking.ServiceType, bool) {
+	name := lbls[networking.SKSLabelKey]
+	typ := networking.ServiceType(lbls[networking.ServiceTypeKey])
+	if name == "" || (typ != networking.ServiceTypePrivate && typ != networking.ServiceTypePublic) {
+		return "", "", false
+	}
+	return name, typ, true
+}
+
+// ServiceLabels returns the labels of the K8s Service, and Endpoints, of the
+// given type of the ServerlessService: the labels of the ServerlessService,
+// along with the ones telling their owner and type.
+func ServiceLabels(sks *v1alpha1.ServerlessService, typ networking.ServiceType) map[string]string {
+	return kmeta.UnionMaps(sks.GetLabels(), map[string]string{
+		networking.SKSLabelKey:    sks.Name,
+		networking.ServiceTypeKey: string(typ),
+	})
+}
+
+// ServiceSelector selects the K8s Services, or Endpoints, of the given type of
+// the ServerlessService with the given name.
+func ServiceSelector(sksName string, typ networking.ServiceType) labels.Selector {
+	return labels.SelectorFromSet(labels.Set{
+		networking.SKSLabelKey:    sksName,
+		networking.ServiceTypeKey: string(typ),
+	})
+}
+
+// PublicServicePorts returns the ports of the public K8s Service of a
+// ServerlessService with the given protocol, forwarding to the backend port
+// of the protocol.
+func PublicServicePorts(proto networking.ProtocolType) []corev1.ServicePort {
+	return []corev1.ServicePort{{
+		Name:       networking.ServicePortName(proto),
+		Protocol:   corev1.ProtocolTCP,
+		Port:       int32(networking.ServicePort(proto)),
+		TargetPort: intstr.FromInt(networking.BackendPort(proto)),
+	}}
+}
+
+// PrivateServicePorts returns the ports of the private K8s Service of a
+// ServerlessService with the given protocol: the ones of the public K8s
+// Service, along with the admin and metrics ports of the queue-proxy.
+func PrivateServicePorts(proto networking.ProtocolType) []corev1.ServicePort {
+	return append(PublicServicePorts(proto), corev1.ServicePort{
+		Name:       networking.QueueAdminPortName,
+		Protocol:   corev1.ProtocolTCP,
+		Port:       networking.QueueAdminPort,
+		TargetPort: intstr.FromInt(networking.QueueAdminPort),
+	}, corev1.ServicePort{
+		Name:       networking.AutoscalingQueueMetricsPortName,
+		Protocol:   corev1.ProtocolTCP,
+		Port:       networking.AutoscalingQueueMetricsPort,
+		TargetPort: intstr.FromInt(networking.AutoscalingQueueMetricsPort),
+	}, corev1.ServicePort{
+		Name:       networking.UserQueueMetricsPortName,
+		Protocol:   corev1.ProtocolTCP,
+		Port:       networking.UserQueueMetricsPort,
+		TargetPort: intstr.FromInt(networking.UserQueueMetricsPort),
+	})
+}
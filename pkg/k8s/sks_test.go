@@ -0,0 +1,191 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package k8s
+
+import (
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"knative.dev/networking/pkg/apis/networking"
+	"knative.dev/networking/pkg/apis/networking/v1alpha1"
+	"sigs.k8s.io/yaml"
+)
+
+var update = flag.Bool("update", false, "update the golden files of the tests")
+
+// sksServices is the golden summary of the K8s Services of a ServerlessService.
+type sksServices struct {
+	Public  sksService `json:"public"`
+	Private sksService `json:"private"`
+}
+
+type sksService struct {
+	Name   string               `json:"name"`
+	Labels map[string]string    `json:"labels"`
+	Ports  []corev1.ServicePort `json:"ports"`
+}
+
+func TestSKSServicesGolden(t *testing.T) {
+	tests := []struct {
+		name   string
+		sks    string
+		proto  networking.ProtocolType
+		golden string
+	}{{
+		name:   "http1",
+		sks:    "hello-00001",
+		proto:  networking.ProtocolHTTP1,
+		golden: "sks-http1.yaml",
+	}, {
+		name:   "h2c",
+		sks:    "grpc-00001",
+		proto:  networking.ProtocolH2C,
+		golden: "sks-h2c.yaml",
+	}, {
+		name:   "long name",
+		sks:    strings.Repeat("a", 63),
+		proto:  networking.ProtocolHTTP1,
+		golden: "sks-long-name.yaml",
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sks := &v1alpha1.ServerlessService{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      test.sks,
+					Namespace: "default",
+					Labels:    map[string]string{"serving.knative.dev/revision": test.sks},
+				},
+			}
+			got, err := yaml.Marshal(sksServices{
+				Public: sksService{
+					Name:   PublicServiceName(sks.Name),
+					Labels: ServiceLabels(sks, networking.ServiceTypePublic),
+					Ports:  PublicServicePorts(test.proto),
+				},
+				Private: sksService{
+					Name:   PrivateServiceName(sks.Name),
+					Labels: ServiceLabels(sks, networking.ServiceTypePrivate),
+					Ports:  PrivateServicePorts(test.proto),
+				},
+			})
+			if err != nil {
+				t.Fatal("yaml.Marshal() =", err)
+			}
+
+			golden := filepath.Join("testdata", test.golden)
+			if *update {
+				if err := ioutil.WriteFile(golden, got, 0644); err != nil {
+					t.Fatal("Error updating the golden file:", err)
+				}
+			}
+			want, err := ioutil.ReadFile(golden)
+			if err != nil {
+				t.Fatal("Error reading the golden file:", err)
+			}
+			if diff := cmp.Diff(string(want), string(got)); diff != "" {
+				t.Errorf("Services (-want, +got), run with -update to accept the changes: %s", diff)
+			}
+		})
+	}
+}
+
+func TestServiceName(t *testing.T) {
+	if got, want := ServiceName("foo", networking.ServiceTypePublic), "foo"; got != want {
+		t.Errorf("ServiceName(Public) = %q, want: %q", got, want)
+	}
+	if got, want := ServiceName("foo", networking.ServiceTypePrivate), "foo-private"; got != want {
+		t.Errorf("ServiceName(Private) = %q, want: %q", got, want)
+	}
+}
+
+func TestSKSForService(t *testing.T) {
+	tests := []struct {
+		name     string
+		labels   map[string]string
+		wantName string
+		wantType networking.ServiceType
+		wantOK   bool
+	}{{
+		name: "public",
+		labels: map[string]string{
+			networking.SKSLabelKey:    "foo",
+			networking.ServiceTypeKey: string(networking.ServiceTypePublic),
+		},
+		wantName: "foo",
+		wantType: networking.ServiceTypePublic,
+		wantOK:   true,
+	}, {
+		name: "private",
+		labels: ServiceLabels(&v1alpha1.ServerlessService{ObjectMeta: metav1.ObjectMeta{Name: "foo"}},
+			networking.ServiceTypePrivate),
+		wantName: "foo",
+		wantType: networking.ServiceTypePrivate,
+		wantOK:   true,
+	}, {
+		name: "no owner",
+		labels: map[string]string{
+			networking.ServiceTypeKey: string(networking.ServiceTypePublic),
+		},
+	}, {
+		name: "unknown type",
+		labels: map[string]string{
+			networking.SKSLabelKey:    "foo",
+			networking.ServiceTypeKey: "Other",
+		},
+	}, {
+		name: "no labels",
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			name, typ, ok := SKSForService(test.labels)
+			if name != test.wantName || typ != test.wantType || ok != test.wantOK {
+				t.Errorf("SKSForService() = %q, %q, %v, want: %q, %q, %v",
+					name, typ, ok, test.wantName, test.wantType, test.wantOK)
+			}
+		})
+	}
+}
+
+func TestServiceSelector(t *testing.T) {
+	sks := &v1alpha1.ServerlessService{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   "foo",
+			Labels: map[string]string{"app": "foo"},
+		},
+	}
+	public := labels.Set(ServiceLabels(sks, networking.ServiceTypePublic))
+	private := labels.Set(ServiceLabels(sks, networking.ServiceTypePrivate))
+
+	if sel := ServiceSelector("foo", networking.ServiceTypePublic); !sel.Matches(public) || sel.Matches(private) {
+		t.Errorf("Public selector %v must only match the public labels", sel)
+	}
+	if sel := ServiceSelector("foo", networking.ServiceTypePrivate); !sel.Matches(private) || sel.Matches(public) {
+		t.Errorf("Private selector %v must only match the private labels", sel)
+	}
+	if sel := ServiceSelector("bar", networking.ServiceTypePublic); sel.Matches(public) {
+		t.Errorf("Selector %v must not match the labels of another ServerlessService", sel)
+	}
+}
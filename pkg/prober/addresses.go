@@ -0,0 +1,175 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package prober
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+)
+
+// WithProbeAllAddresses sends the probe to every address the host of the
+// target resolves to, i.e. all its A and AAAA records, rather than to the
+// first one answering, and requires all of them to succeed. This catches
+// e.g. the replicas of a gateway behind a single DNS name which aren't
+// programmed yet, which a single probe would only hit by chance. The
+// addresses set with WithResolveTo are probed instead of resolving the host,
+// if any. The host is resolved with the resolver set with WithResolver if
+// any, and the probe fails with an error of class ErrDNS if it doesn't
+// resolve.
+func WithProbeAllAddresses() DialOption {
+	return func(c *dialConfig) {
+		if c.allAddresses == nil {
+			c.allAddresses = &allAddressesConfig{}
+		}
+	}
+}
+
+// WithAddressQuorum is like WithProbeAllAddresses, but only requires the
+// probes of quorum addresses to succeed, e.g. so that a single unhealthy
+// replica doesn't block the rollouts. The probe fails if the host resolves to
+// fewer addresses than quorum. A quorum of zero or less requires all of them.
+func WithAddressQuorum(quorum int) DialOption {
+	return func(c *dialConfig) {
+		c.allAddresses = &allAddressesConfig{quorum: quorum}
+	}
+}
+
+// allAddressesConfig is set by WithProbeAllAddresses and WithAddressQuorum.
+type allAddressesConfig struct {
+	quorum int
+}
+
+// allAddresses sends a probe to all the addresses of its target.
+type allAddresses struct {
+	// dc is the config of the connections of the probe.
+	dc *dialConfig
+	// transport is the transport the probe was built with, which the
+	// transports dialing each address are based on.
+	transport http.RoundTripper
+}
+
+// addressResult is the outcome of the probe sent to an address.
+type addressResult struct {
+	addr string
+	res  probeResult
+	err  error
+}
+
+// send sends the probe p to all the addresses of its target, and succeeds if
+// enough of them succeeded. The failure returned is the one of the first
+// address failing.
+func (a *allAddresses) send(p probe) (probeResult, error) {
+	addrs, err := a.dc.addresses(p.req.Context(), p.req.URL)
+	if err != nil {
+		return probeResult{}, err
+	}
+	quorum := a.dc.allAddresses.quorum
+	if quorum <= 0 {
+		quorum = len(addrs)
+	}
+	if len(addrs) < quorum {
+		return probeResult{}, fmt.Errorf("%s resolves to %d addresses, fewer than the quorum of %d",
+			p.req.URL.Hostname(), len(addrs), quorum)
+	}
+
+	results := make([]addressResult, len(addrs))
+	var wg sync.WaitGroup
+	for i, addr := range addrs {
+		wg.Add(1)
+		go func(i int, addr string) {
+			defer wg.Done()
+			dc := *a.dc
+			dc.resolveTo = []string{addr}
+			dc.allAddresses = nil
+			one := p
+			one.transport = dc.transport(a.transport)
+			// The host has just been resolved.
+			one.dnsCheck = nil
+			one.all = nil
+			res, err := one.send()
+			results[i] = addressResult{addr: addr, res: res, err: err}
+		}(i, addr)
+	}
+	wg.Wait()
+
+	var (
+		successes int
+		succeeded *addressResult
+		failed    *addressResult
+	)
+	for i := range results {
+		r := &results[i]
+		if r.err == nil && r.res.ok {
+			successes++
+			if succeeded == nil {
+				succeeded = r
+			}
+		} else if failed == nil || (failed.err == nil && r.err != nil) {
+			failed = r
+		}
+	}
+	if successes >= quorum {
+		return succeeded.res, nil
+	}
+	if failed.err == nil {
+		return failed.res, nil
+	}
+	return failed.res, fmt.Errorf("%d of %d addresses failed, %s: %w",
+		len(addrs)-successes, len(addrs), failed.addr, failed.err)
+}
+
+// addresses returns the host:port addresses the probes to u are sent to:
+// the addresses set with WithResolveTo if any, or the ones its host resolves
+// to.
+func (c *dialConfig) addresses(ctx context.Context, u *url.URL) ([]string, error) {
+	port := u.Port()
+	if port == "" {
+		port = "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+	}
+	if len(c.resolveTo) > 0 {
+		addrs := make([]string, 0, len(c.resolveTo))
+		for _, a := range c.resolveTo {
+			addrs = append(addrs, withDefaultPort(a, port))
+		}
+		return addrs, nil
+	}
+
+	host := u.Hostname()
+	if net.ParseIP(host) != nil {
+		return []string{net.JoinHostPort(host, port)}, nil
+	}
+	r := c.dialer.Resolver
+	if r == nil {
+		r = net.DefaultResolver
+	}
+	hosts, err := r.LookupHost(ctx, host)
+	if err != nil {
+		return nil, fmt.Errorf("error resolving %s: %w", host, classifyRoundTripError(err))
+	}
+	addrs := make([]string, 0, len(hosts))
+	for _, h := range hosts {
+		addrs = append(addrs, net.JoinHostPort(h, port))
+	}
+	return addrs, nil
+}
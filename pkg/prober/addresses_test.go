@@ -0,0 +1,159 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package prober
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+
+	"go.uber.org/atomic"
+	"knative.dev/pkg/network"
+)
+
+func TestWithProbeAllAddresses(t *testing.T) {
+	var healthyRequests, brokenRequests atomic.Int32
+	healthy := newServerOn(t, "127.0.0.1:0", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		healthyRequests.Inc()
+	}))
+	otherHealthy := newServerOn(t, "127.0.0.1:0", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		healthyRequests.Inc()
+	}))
+	broken := newServerOn(t, "127.0.0.1:0", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		brokenRequests.Inc()
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	healthyAddr := healthy.Listener.Addr().String()
+	otherHealthyAddr := otherHealthy.Listener.Addr().String()
+	brokenAddr := broken.Listener.Addr().String()
+
+	tests := []struct {
+		name      string
+		resolveTo []string
+		option    DialOption
+		want      bool
+		wantErr   string
+		// wantHealthy and wantBroken are the number of requests expected by
+		// the healthy and broken servers.
+		wantHealthy, wantBroken int32
+	}{{
+		name:        "all healthy",
+		resolveTo:   []string{healthyAddr, otherHealthyAddr},
+		option:      WithProbeAllAddresses(),
+		want:        true,
+		wantHealthy: 2,
+	}, {
+		name:        "one broken",
+		resolveTo:   []string{healthyAddr, brokenAddr},
+		option:      WithProbeAllAddresses(),
+		wantErr:     "1 of 2 addresses failed, " + brokenAddr,
+		wantHealthy: 1,
+		wantBroken:  1,
+	}, {
+		name:        "quorum reached",
+		resolveTo:   []string{healthyAddr, otherHealthyAddr, brokenAddr},
+		option:      WithAddressQuorum(2),
+		want:        true,
+		wantHealthy: 2,
+		wantBroken:  1,
+	}, {
+		name:        "quorum not reached",
+		resolveTo:   []string{healthyAddr, brokenAddr},
+		option:      WithAddressQuorum(2),
+		wantErr:     "1 of 2 addresses failed",
+		wantHealthy: 1,
+		wantBroken:  1,
+	}, {
+		name:        "zero quorum requires all",
+		resolveTo:   []string{healthyAddr, brokenAddr},
+		option:      WithAddressQuorum(0),
+		wantErr:     "1 of 2 addresses failed",
+		wantHealthy: 1,
+		wantBroken:  1,
+	}, {
+		name:      "fewer addresses than the quorum",
+		resolveTo: []string{healthyAddr},
+		option:    WithAddressQuorum(2),
+		wantErr:   "resolves to 1 addresses, fewer than the quorum of 2",
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			healthyRequests.Store(0)
+			brokenRequests.Store(0)
+
+			ok, err := Do(context.Background(), network.NewProberTransport(), "http://gateway.example.com",
+				WithResolveTo(test.resolveTo...), test.option, ExpectsStatusCodes([]int{http.StatusOK}))
+			if ok != test.want {
+				t.Errorf("Do() = %v, want: %v", ok, test.want)
+			}
+			switch {
+			case test.wantErr == "" && err != nil:
+				t.Error("Do() =", err)
+			case test.wantErr != "" && (err == nil || !strings.Contains(err.Error(), test.wantErr)):
+				t.Errorf("Do() = %v, want an error containing %q", err, test.wantErr)
+			}
+			if got := healthyRequests.Load(); got != test.wantHealthy {
+				t.Errorf("Healthy servers got %d requests, want: %d", got, test.wantHealthy)
+			}
+			if got := brokenRequests.Load(); got != test.wantBroken {
+				t.Errorf("Broken server got %d requests, want: %d", got, test.wantBroken)
+			}
+		})
+	}
+}
+
+func TestWithProbeAllAddressesClass(t *testing.T) {
+	broken := newServerOn(t, "127.0.0.1:0", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	_, err := Do(context.Background(), network.NewProberTransport(), "http://gateway.example.com",
+		WithResolveTo(broken.Listener.Addr().String()), WithProbeAllAddresses(), ExpectsStatusCodes([]int{http.StatusOK}))
+	if !errors.Is(err, ErrBadStatus) {
+		t.Errorf("Do() = %v, want an error of class %v", err, ErrBadStatus)
+	}
+
+	r := &net.Resolver{
+		PreferGo: true,
+		Dial: func(context.Context, string, string) (net.Conn, error) {
+			return nil, errors.New("no DNS today")
+		},
+	}
+	ok, err := Do(context.Background(), network.NewProberTransport(), "http://not-propagated.example.com",
+		WithResolver(r), WithProbeAllAddresses(), ExpectsStatusCodes([]int{http.StatusOK}))
+	if ok || !errors.Is(err, ErrDNS) {
+		t.Errorf("Do() = %v, %v, want: false, an error of class %v", ok, err, ErrDNS)
+	}
+}
+
+func TestWithProbeAllAddressesIPLiteral(t *testing.T) {
+	ts := newServerOn(t, "127.0.0.1:0", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	// IP literals don't need resolving, even with a broken resolver.
+	r := &net.Resolver{
+		PreferGo: true,
+		Dial: func(context.Context, string, string) (net.Conn, error) {
+			return nil, errors.New("no DNS today")
+		},
+	}
+	if ok, err := Do(context.Background(), network.NewProberTransport(), ts.URL,
+		WithResolver(r), WithProbeAllAddresses(), ExpectsStatusCodes([]int{http.StatusOK})); !ok || err != nil {
+		t.Errorf("Do(%s) = %v, %v, want: true, nil", ts.URL, ok, err)
+	}
+}
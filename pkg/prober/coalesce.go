@@ -0,0 +1,79 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package prober
+
+import (
+	"strings"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// Coalescer coalesces the identical probes sent concurrently, so that only
+// one of them is in flight and its result is shared by all the callers,
+// protecting small backends from being flooded with probes during bursts,
+// e.g. when many Ingresses sharing a gateway are reconciled at once.
+// The zero value is ready to use.
+type Coalescer struct {
+	group singleflight.Group
+}
+
+// CoalesceOption is a way for the caller to share the result of a probe with
+// the identical probes sent concurrently.
+type CoalesceOption func(*coalesceConfig)
+
+// coalesceConfig is set by WithCoalescer.
+type coalesceConfig struct {
+	coalescer *Coalescer
+	key       string
+}
+
+// WithCoalescer coalesces the probe with the identical probes sent through c
+// at the same time. As options can't be compared, the probes are considered
+// identical if they have the same method, URL and Host, and the same key,
+// which the callers must derive from the other options of the probe, e.g.
+// the Verifiers, so that different expectations don't share a result.
+//
+// A coalesced probe is sent with the context of the first caller: if it is
+// cancelled, all the callers sharing the probe get the cancellation error.
+// The callers whose own context is cancelled return without waiting for the
+// shared result.
+func WithCoalescer(c *Coalescer, key string) CoalesceOption {
+	return func(cc *coalesceConfig) {
+		cc.coalescer = c
+		cc.key = key
+	}
+}
+
+// identityKey returns the key identifying the probes identical to p, given
+// the key derived by the caller from the other options of p.
+func identityKey(p probe, key string) string {
+	return strings.Join([]string{p.req.Method, p.req.URL.String(), p.req.Host, key}, " ")
+}
+
+// do sends the probe p, or waits for the result of the identical probe in
+// flight.
+func (c *coalesceConfig) do(p probe) (probeResult, error) {
+	ch := c.coalescer.group.DoChan(identityKey(p, c.key), func() (interface{}, error) {
+		return p.sendHedged()
+	})
+	select {
+	case res := <-ch:
+		return res.Val.(probeResult), res.Err
+	case <-p.req.Context().Done():
+		return probeResult{}, p.req.Context().Err()
+	}
+}
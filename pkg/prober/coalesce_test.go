@@ -0,0 +1,135 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package prober
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/atomic"
+	"knative.dev/pkg/network"
+)
+
+func TestWithCoalescer(t *testing.T) {
+	const callers = 5
+
+	tests := []struct {
+		name         string
+		keys         []string
+		paths        []string
+		wantRequests int32
+	}{{
+		name:         "identical probes",
+		keys:         []string{"a", "a", "a", "a", "a"},
+		paths:        []string{"/", "/", "/", "/", "/"},
+		wantRequests: 1,
+	}, {
+		name:         "different keys",
+		keys:         []string{"a", "b", "a", "b", "a"},
+		paths:        []string{"/", "/", "/", "/", "/"},
+		wantRequests: 2,
+	}, {
+		name:         "different paths",
+		keys:         []string{"a", "a", "a", "a", "a"},
+		paths:        []string{"/", "/foo", "/", "/foo", "/bar"},
+		wantRequests: 3,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var requests atomic.Int32
+			release := make(chan struct{})
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests.Inc()
+				<-release
+				w.Write([]byte(r.URL.Path))
+			}))
+			defer ts.Close()
+			c := &Coalescer{}
+
+			var prepared, wg sync.WaitGroup
+			prepared.Add(callers)
+			wg.Add(callers)
+			for i := 0; i < callers; i++ {
+				i := i
+				go func() {
+					defer wg.Done()
+					ok, err := Do(context.Background(), network.NewProberTransport(), ts.URL+test.paths[i],
+						WithCoalescer(c, test.keys[i]),
+						Preparer(func(r *http.Request) *http.Request {
+							prepared.Done()
+							return r
+						}),
+						ExpectsBody(test.paths[i]))
+					if !ok || err != nil {
+						t.Errorf("Do() = %v, %v, want: true, nil", ok, err)
+					}
+				}()
+			}
+			// Give the callers which were prepared the time to join the
+			// probes in flight.
+			prepared.Wait()
+			time.Sleep(50 * time.Millisecond)
+			close(release)
+			wg.Wait()
+
+			if got := requests.Load(); got != test.wantRequests {
+				t.Errorf("Requests = %d, want: %d", got, test.wantRequests)
+			}
+		})
+	}
+}
+
+func TestWithCoalescerCancelled(t *testing.T) {
+	release := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer ts.Close()
+	defer close(release)
+
+	c := &Coalescer{}
+	inFlight := make(chan struct{})
+	go Do(context.Background(), network.NewProberTransport(), ts.URL, WithCoalescer(c, ""),
+		Preparer(func(r *http.Request) *http.Request {
+			close(inFlight)
+			return r
+		}))
+	<-inFlight
+
+	// A caller whose context is cancelled doesn't wait for the shared result.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if ok, err := Do(ctx, network.NewProberTransport(), ts.URL, WithCoalescer(c, "")); ok || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Do() = %v, %v, want: false, %v", ok, err, context.DeadlineExceeded)
+	}
+}
+
+func TestWithCoalescerNil(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+
+	// A nil Coalescer doesn't coalesce the probes.
+	if ok, err := Do(context.Background(), network.NewProberTransport(), ts.URL, WithCoalescer(nil, "")); !ok || err != nil {
+		t.Errorf("Do() = %v, %v, want: true, nil", ok, err)
+	}
+}
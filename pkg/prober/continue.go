@@ -0,0 +1,100 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package prober
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptrace"
+	"time"
+
+	"go.uber.org/atomic"
+)
+
+// expectContinue is the request body sent along an `Expect: 100-continue`
+// header, and how long to wait for the interim response before sending it.
+type expectContinue struct {
+	body    []byte
+	timeout time.Duration
+}
+
+// continueTraceKey is the context key of the continueTrace of a probe.
+type continueTraceKey struct{}
+
+// continueTrace records whether a 100 Continue was received by the current
+// attempt of a probe.
+type continueTrace struct {
+	got atomic.Bool
+}
+
+// WithExpectContinue sends body with an `Expect: 100-continue` header,
+// waiting up to timeout for the 100 Continue interim response before
+// sending the body anyway, as a client would. The probe is sent as a POST
+// unless another method is set with WithMethod. Use ExpectsContinue to
+// verify the interim response was forwarded.
+//
+// Only HTTP/1.1 probes wait for the interim response.
+func WithExpectContinue(body []byte, timeout time.Duration) DialOption {
+	return func(c *dialConfig) {
+		c.expectContinue = &expectContinue{body: body, timeout: timeout}
+	}
+}
+
+// ExpectsContinue validates that a 100 Continue interim response was
+// received before the final response of a probe sent with WithExpectContinue.
+func ExpectsContinue() Verifier {
+	return func(r *http.Response, _ []byte) (bool, error) {
+		ct, ok := r.Request.Context().Value(continueTraceKey{}).(*continueTrace)
+		if !ok {
+			return false, errors.New("the probe was not sent with WithExpectContinue")
+		}
+		if !ct.got.Load() {
+			return false, errors.New("no 100 Continue received before the response")
+		}
+		return true, nil
+	}
+}
+
+// prepare sets up r to send the body with an `Expect: 100-continue` header,
+// tracing whether the interim response is received.
+func (e *expectContinue) prepare(r *http.Request) *http.Request {
+	ct := &continueTrace{}
+	ctx := httptrace.WithClientTrace(r.Context(), &httptrace.ClientTrace{
+		// Reset for every attempt, as the request may be sent multiple times.
+		WroteHeaders: func() {
+			ct.got.Store(false)
+		},
+		Got100Continue: func() {
+			ct.got.Store(true)
+		},
+	})
+	r = r.WithContext(context.WithValue(ctx, continueTraceKey{}, ct))
+	if r.Method == http.MethodGet {
+		r.Method = http.MethodPost
+	}
+	r.Header.Set("Expect", "100-continue")
+	r.ContentLength = int64(len(e.body))
+	r.GetBody = func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(e.body)), nil
+	}
+	r.Body, _ = r.GetBody()
+	return r
+}
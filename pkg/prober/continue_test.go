@@ -0,0 +1,104 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package prober
+
+import (
+	"bufio"
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"knative.dev/pkg/network"
+)
+
+func TestWithExpectContinue(t *testing.T) {
+	const body = "probe body"
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Expect") != "100-continue" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		// The server sends the 100 Continue when the body is first read.
+		b, _ := ioutil.ReadAll(r.Body)
+		w.Write(b)
+	}))
+	defer ts.Close()
+
+	p, err := newProbe(context.Background(), network.NewProberTransport(), ts.URL, []interface{}{
+		WithExpectContinue([]byte(body), time.Minute), ExpectsContinue(),
+		ExpectsStatusCodes([]int{http.StatusOK}), ExpectsBody(body),
+	})
+	if err != nil {
+		t.Fatal("newProbe() =", err)
+	}
+	// Probe twice, to check the body is sent again.
+	for i := 0; i < 2; i++ {
+		if ok, err := p.do(); !ok {
+			t.Fatalf("do() = %v, %v, want: true", ok, err)
+		}
+	}
+}
+
+func TestWithExpectContinueNotForwarded(t *testing.T) {
+	// Mimic a proxy swallowing the interim response: the body is only received
+	// once the client gives up waiting for the 100 Continue.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal("Listen() =", err)
+	}
+	defer l.Close()
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer c.Close()
+				req, err := http.ReadRequest(bufio.NewReader(c))
+				if err != nil {
+					return
+				}
+				ioutil.ReadAll(req.Body)
+				c.Write([]byte("HTTP/1.1 200 OK\r\nContent-Length: 0\r\nConnection: close\r\n\r\n"))
+			}()
+		}
+	}()
+
+	start := time.Now()
+	ok, err := Do(context.Background(), network.NewProberTransport(), "http://"+l.Addr().String(),
+		WithExpectContinue([]byte("probe body"), 100*time.Millisecond), ExpectsContinue())
+	if ok || err == nil {
+		t.Errorf("Do() = %v, %v, want: false, error", ok, err)
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("Do() took %v, want at least the continue timeout", elapsed)
+	}
+}
+
+func TestExpectsContinueWithoutOption(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer ts.Close()
+
+	if ok, err := Do(context.Background(), network.NewProberTransport(), ts.URL, ExpectsContinue()); ok || err == nil {
+		t.Errorf("Do() = %v, %v, want: false, error", ok, err)
+	}
+}
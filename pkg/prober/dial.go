@@ -0,0 +1,348 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package prober
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/net/http2"
+	"knative.dev/pkg/network"
+)
+
+// defaultFallbackDelay mirrors the delay used by net.Dialer before racing
+// the fallback address family, as recommended by RFC 6555.
+const defaultFallbackDelay = 300 * time.Millisecond
+
+// DialOption is a way for the caller to control how the probe connection is established.
+//
+// Dial options need a dedicated transport: if the transport passed to Do is an
+// *http.Transport it is cloned, otherwise a transport equivalent to
+// network.NewProberTransport is used for the probe.
+type DialOption func(*dialConfig)
+
+// dialConfig accumulates the DialOptions of a single probe.
+type dialConfig struct {
+	dialer net.Dialer
+
+	// dial overrides the dialer, if set.
+	dial func(ctx context.Context, network, address string) (net.Conn, error)
+
+	// resolveTo overrides the addresses dialed for the probe target.
+	resolveTo []string
+
+	// expectContinue is set by WithExpectContinue.
+	expectContinue *expectContinue
+
+	// proxy is the proxy the connections are tunneled through, if set.
+	proxy *url.URL
+
+	// dnsCheck is set by WithDNSCheck.
+	dnsCheck bool
+
+	// proxyProtocol is set by WithProxyProtocol and WithProxyProtocolSource.
+	proxyProtocol *proxyProtocol
+
+	// allAddresses is set by WithProbeAllAddresses and WithAddressQuorum.
+	allAddresses *allAddressesConfig
+}
+
+// WithResolveTo dials the given addresses instead of resolving the host of the
+// probe target. The target URL is still used for the Host header and the TLS
+// server name. Addresses can be IPv4 or IPv6 literals, optionally bracketed and
+// optionally carrying a port; the port of the target is used if none is given.
+// When addresses of both families are given, they are raced Happy Eyeballs style
+// (RFC 6555), preferring the family of the first address.
+func WithResolveTo(addrs ...string) DialOption {
+	return func(c *dialConfig) {
+		c.resolveTo = append(c.resolveTo, addrs...)
+	}
+}
+
+// WithResolver uses the given resolver to look up the host of the probe target,
+// instead of the system resolver. This allows e.g. checking that a custom domain
+// is resolvable through a specific DNS server before probing it.
+func WithResolver(r *net.Resolver) DialOption {
+	return func(c *dialConfig) {
+		c.dialer.Resolver = r
+	}
+}
+
+// WithDNSCheck resolves the host of the probe target before each attempt,
+// with the resolver set with WithResolver if any, and fails the attempt with
+// an error of class ErrDNS without sending it if the host doesn't resolve.
+// This tells e.g. a custom domain whose records haven't propagated yet apart
+// from a gateway not programmed yet. The host is resolved even if the probe
+// dials the addresses set with WithResolveTo, or is tunneled through a proxy,
+// while the targets whose host is an IP address are not checked.
+func WithDNSCheck() DialOption {
+	return func(c *dialConfig) {
+		c.dnsCheck = true
+	}
+}
+
+// checkDNS returns an error if host doesn't resolve.
+func (c *dialConfig) checkDNS(ctx context.Context, host string) error {
+	if net.ParseIP(host) != nil {
+		return nil
+	}
+	r := c.dialer.Resolver
+	if r == nil {
+		r = net.DefaultResolver
+	}
+	if _, err := r.LookupHost(ctx, host); err != nil {
+		return fmt.Errorf("error resolving %s: %w", host, classifyRoundTripError(err))
+	}
+	return nil
+}
+
+// WithLocalAddr makes the probe originate from the given local IP address, e.g. the
+// address of a specific network interface, so that it follows the same path as the
+// traffic it validates.
+func WithLocalAddr(ip net.IP) DialOption {
+	return func(c *dialConfig) {
+		c.dialer.LocalAddr = &net.TCPAddr{IP: ip}
+	}
+}
+
+// WithDialContext establishes the probe connections with the given function
+// instead of dialing them directly, e.g. to tunnel them through the API server
+// when the pods are not reachable. It still dials the addresses set with
+// WithResolveTo, if any.
+func WithDialContext(dial func(ctx context.Context, network, address string) (net.Conn, error)) DialOption {
+	return func(c *dialConfig) {
+		c.dial = dial
+	}
+}
+
+// transport returns a RoundTripper based on rt which dials using the config.
+func (c *dialConfig) transport(rt http.RoundTripper) http.RoundTripper {
+	if t, ok := rt.(*http.Transport); ok {
+		t = t.Clone()
+		t.DialContext = c.dialContext
+		c.configureProxy(t)
+		// The transport is discarded after the probe, so don't pool connections.
+		t.DisableKeepAlives = true
+		c.configureExpectContinue(t)
+		return t
+	}
+
+	h1 := http.DefaultTransport.(*http.Transport).Clone()
+	h1.DialContext = c.dialContext
+	h1.DisableKeepAlives = true
+	h1.ForceAttemptHTTP2 = false
+	c.configureProxy(h1)
+	c.configureExpectContinue(h1)
+	h2c := &http2.Transport{
+		AllowHTTP: true,
+		DialTLS: func(netw, addr string, _ *tls.Config) (net.Conn, error) {
+			return c.dialContext(context.Background(), netw, addr)
+		},
+	}
+	return network.RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		if r.ProtoMajor == 2 {
+			return h2c.RoundTrip(r)
+		}
+		return h1.RoundTrip(r)
+	})
+}
+
+// configureExpectContinue sets how long t waits for a 100 Continue.
+func (c *dialConfig) configureExpectContinue(t *http.Transport) {
+	if c.expectContinue != nil {
+		t.ExpectContinueTimeout = c.expectContinue.timeout
+	}
+}
+
+// configureProxy keeps t from also sending the probes through the proxies of
+// the environment when they are tunneled through a proxy.
+func (c *dialConfig) configureProxy(t *http.Transport) {
+	if c.proxy != nil {
+		t.Proxy = nil
+	}
+}
+
+// dialContext dials address, or the overridden addresses if any.
+func (c *dialConfig) dialContext(ctx context.Context, netw, address string) (net.Conn, error) {
+	if len(c.resolveTo) == 0 {
+		return c.dialOne(ctx, netw, address)
+	}
+	_, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	addrs := make([]string, 0, len(c.resolveTo))
+	for _, a := range c.resolveTo {
+		addrs = append(addrs, withDefaultPort(a, port))
+	}
+	primaries, fallbacks := partitionByFamily(addrs)
+	return c.dialParallel(ctx, netw, primaries, fallbacks)
+}
+
+// dialParallel races the primaries against the fallbacks, giving the
+// primaries a head start of the dialer's fallback delay.
+func (c *dialConfig) dialParallel(ctx context.Context, netw string, primaries, fallbacks []string) (net.Conn, error) {
+	if len(fallbacks) == 0 || c.dialer.FallbackDelay < 0 {
+		return c.dialSerial(ctx, netw, append(primaries, fallbacks...))
+	}
+
+	type result struct {
+		conn    net.Conn
+		err     error
+		primary bool
+	}
+	results := make(chan result)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	race := func(addrs []string, primary bool) {
+		conn, err := c.dialSerial(ctx, netw, addrs)
+		select {
+		case results <- result{conn: conn, err: err, primary: primary}:
+		case <-ctx.Done():
+			if conn != nil {
+				conn.Close()
+			}
+		}
+	}
+	go race(primaries, true)
+
+	delay := c.dialer.FallbackDelay
+	if delay == 0 {
+		delay = defaultFallbackDelay
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	var primaryErr error
+	fallbackStarted, pending := false, 1
+	for {
+		select {
+		case <-timer.C:
+			if !fallbackStarted {
+				fallbackStarted = true
+				pending++
+				go race(fallbacks, false)
+			}
+		case res := <-results:
+			pending--
+			if res.err == nil {
+				return res.conn, nil
+			}
+			if res.primary {
+				primaryErr = res.err
+			}
+			if !fallbackStarted {
+				// The primaries failed before the delay expired, no need to wait.
+				fallbackStarted = true
+				pending++
+				go race(fallbacks, false)
+			}
+			if pending == 0 {
+				return nil, primaryErr
+			}
+		}
+	}
+}
+
+// dialSerial dials the addresses in order, returning the first connection established.
+func (c *dialConfig) dialSerial(ctx context.Context, netw string, addrs []string) (net.Conn, error) {
+	var firstErr error
+	for _, addr := range addrs {
+		conn, err := c.dialOne(ctx, netw, addr)
+		if err == nil {
+			return conn, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	if firstErr == nil {
+		firstErr = errors.New("no address to dial")
+	}
+	return nil, firstErr
+}
+
+// dialOne dials a single address, through the proxy if any, prepending a
+// PROXY protocol header if asked for.
+func (c *dialConfig) dialOne(ctx context.Context, netw, address string) (net.Conn, error) {
+	var (
+		conn net.Conn
+		err  error
+	)
+	if c.proxy != nil {
+		conn, err = c.dialProxy(ctx, netw, address)
+	} else {
+		conn, err = c.dialDirect(ctx, netw, address)
+	}
+	if err != nil || c.proxyProtocol == nil {
+		return conn, err
+	}
+	if err := c.proxyProtocol.write(ctx, conn, address); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to send the PROXY protocol header to %s: %w", address, err)
+	}
+	return conn, nil
+}
+
+// dialDirect dials a single address without any proxy.
+func (c *dialConfig) dialDirect(ctx context.Context, netw, address string) (net.Conn, error) {
+	if c.dial != nil {
+		return c.dial(ctx, netw, address)
+	}
+	return c.dialer.DialContext(ctx, netw, address)
+}
+
+// withDefaultPort returns addr as a host:port pair, using port if addr doesn't carry one.
+// Bare and bracketed IPv6 literals are both accepted.
+func withDefaultPort(addr, port string) string {
+	if host, p, err := net.SplitHostPort(addr); err == nil {
+		return net.JoinHostPort(host, p)
+	}
+	return net.JoinHostPort(strings.TrimSuffix(strings.TrimPrefix(addr, "["), "]"), port)
+}
+
+// partitionByFamily splits the host:port addresses into the ones sharing the
+// address family of the first one and the others.
+func partitionByFamily(addrs []string) (primaries, fallbacks []string) {
+	isV4 := func(addr string) bool {
+		host, _, _ := net.SplitHostPort(addr)
+		ip := net.ParseIP(host)
+		return ip != nil && ip.To4() != nil
+	}
+	if len(addrs) == 0 {
+		return nil, nil
+	}
+	primaryV4 := isV4(addrs[0])
+	for _, addr := range addrs {
+		if isV4(addr) == primaryV4 {
+			primaries = append(primaries, addr)
+		} else {
+			fallbacks = append(fallbacks, addr)
+		}
+	}
+	return primaries, fallbacks
+}
@@ -0,0 +1,322 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package prober
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"go.uber.org/atomic"
+	"knative.dev/pkg/network"
+)
+
+// newServerOn starts a test server listening on the given loopback address,
+// skipping the test if the address family is not available.
+func newServerOn(t *testing.T, addr string, h http.Handler) *httptest.Server {
+	t.Helper()
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		t.Skipf("Unable to listen on %s: %v", addr, err)
+	}
+	ts := &httptest.Server{
+		Listener: l,
+		Config:   &http.Server{Handler: h},
+	}
+	ts.Start()
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func TestWithResolveTo(t *testing.T) {
+	const host = "gone.fishing.svc.cluster.local"
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The Host header carries the port of the target, if any.
+		if h, _, err := net.SplitHostPort(r.Host); r.Host != host && (err != nil || h != host) {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+	v4 := newServerOn(t, "127.0.0.1:0", handler)
+	_, v4Port, _ := net.SplitHostPort(v4.Listener.Addr().String())
+
+	tests := []struct {
+		name      string
+		target    string
+		resolveTo []string
+		transport http.RoundTripper
+	}{{
+		name:      "ipv4",
+		target:    "http://" + net.JoinHostPort(host, v4Port),
+		resolveTo: []string{"127.0.0.1"},
+		transport: network.NewProberTransport(),
+	}, {
+		name:      "ipv4 with port",
+		target:    "http://" + host,
+		resolveTo: []string{v4.Listener.Addr().String()},
+		transport: network.NewProberTransport(),
+	}, {
+		name:      "http.Transport is cloned",
+		target:    "http://" + net.JoinHostPort(host, v4Port),
+		resolveTo: []string{"127.0.0.1"},
+		transport: &http.Transport{},
+	}, {
+		name:   "dual-stack falls back to the working family",
+		target: "http://" + net.JoinHostPort(host, v4Port),
+		// Nothing listens on the IPv6 loopback, so the IPv4 fallback must win.
+		resolveTo: []string{"::1", "127.0.0.1"},
+		transport: network.NewProberTransport(),
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ok, err := Do(context.Background(), test.transport, test.target,
+				WithResolveTo(test.resolveTo...), ExpectsStatusCodes([]int{http.StatusOK}))
+			if !ok || err != nil {
+				t.Errorf("Do() = %v, %v, want: true, nil", ok, err)
+			}
+		})
+	}
+}
+
+func TestWithResolveToIPv6(t *testing.T) {
+	ts := newServerOn(t, "[::1]:0", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	u, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal("Failed to parse URL:", err)
+	}
+
+	for _, resolveTo := range []string{"::1", "[::1]", "[::1]:" + u.Port()} {
+		t.Run(resolveTo, func(t *testing.T) {
+			ok, err := Do(context.Background(), network.NewProberTransport(), "http://example.com:"+u.Port(),
+				WithResolveTo(resolveTo), ExpectsStatusCodes([]int{http.StatusOK}))
+			if !ok || err != nil {
+				t.Errorf("Do() = %v, %v, want: true, nil", ok, err)
+			}
+		})
+	}
+
+	// Bracketed IPv6 targets work without any override too.
+	if ok, err := Do(context.Background(), network.NewProberTransport(), ts.URL,
+		ExpectsStatusCodes([]int{http.StatusOK})); !ok || err != nil {
+		t.Errorf("Do(%s) = %v, %v, want: true, nil", ts.URL, ok, err)
+	}
+}
+
+func TestWithResolveToUnreachable(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal("Failed to listen:", err)
+	}
+	addr := l.Addr().String()
+	// Close right away so that nothing is listening on the port anymore.
+	l.Close()
+
+	ok, err := Do(context.Background(), network.NewProberTransport(), "http://example.com",
+		WithResolveTo(addr), ExpectsStatusCodes([]int{http.StatusOK}))
+	if ok || err == nil {
+		t.Errorf("Do() = %v, %v, want: false, an error", ok, err)
+	}
+}
+
+func TestWithDialContext(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer ts.Close()
+
+	var dialed []string
+	dial := func(ctx context.Context, netw, addr string) (net.Conn, error) {
+		dialed = append(dialed, addr)
+		return (&net.Dialer{}).DialContext(ctx, netw, ts.Listener.Addr().String())
+	}
+
+	for _, test := range []struct {
+		name string
+		ops  []interface{}
+		want []string
+	}{{
+		name: "target",
+		ops:  []interface{}{WithDialContext(dial)},
+		want: []string{"example.com:80"},
+	}, {
+		name: "resolved addresses",
+		ops:  []interface{}{WithDialContext(dial), WithResolveTo("10.0.0.1:8080")},
+		want: []string{"10.0.0.1:8080"},
+	}} {
+		t.Run(test.name, func(t *testing.T) {
+			dialed = nil
+			ok, err := Do(context.Background(), network.NewProberTransport(), "http://example.com",
+				append(test.ops, ExpectsStatusCodes([]int{http.StatusOK}))...)
+			if !ok || err != nil {
+				t.Errorf("Do() = %v, %v, want: true, nil", ok, err)
+			}
+			if !cmp.Equal(dialed, test.want) {
+				t.Error("Dialed addresses (-want, +got) =", cmp.Diff(test.want, dialed))
+			}
+		})
+	}
+}
+
+func TestWithResolver(t *testing.T) {
+	var used atomic.Bool
+	r := &net.Resolver{
+		PreferGo: true,
+		Dial: func(context.Context, string, string) (net.Conn, error) {
+			used.Store(true)
+			return nil, errors.New("no DNS today")
+		},
+	}
+
+	ok, err := Do(context.Background(), network.NewProberTransport(), "http://gone.fishing.invalid",
+		WithResolver(r), ExpectsStatusCodes([]int{http.StatusOK}))
+	if ok || err == nil {
+		t.Errorf("Do() = %v, %v, want: false, an error", ok, err)
+	}
+	if !used.Load() {
+		t.Error("The custom resolver was not used")
+	}
+
+	// IP literals don't need resolving.
+	ts := newServerOn(t, "127.0.0.1:0", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	if ok, err := Do(context.Background(), network.NewProberTransport(), ts.URL,
+		WithResolver(r), ExpectsStatusCodes([]int{http.StatusOK})); !ok || err != nil {
+		t.Errorf("Do(%s) = %v, %v, want: true, nil", ts.URL, ok, err)
+	}
+}
+
+func TestWithDNSCheck(t *testing.T) {
+	var requests atomic.Int32
+	ts := newServerOn(t, "127.0.0.1:0", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Inc()
+	}))
+	_, port, _ := net.SplitHostPort(ts.Listener.Addr().String())
+	r := &net.Resolver{
+		PreferGo: true,
+		Dial: func(context.Context, string, string) (net.Conn, error) {
+			return nil, errors.New("no DNS today")
+		},
+	}
+	target := "http://not-propagated.example.com:" + port
+
+	// The probe would succeed without the check, as it dials the server directly.
+	if ok, err := Do(context.Background(), network.NewProberTransport(), target,
+		WithResolver(r), WithResolveTo(ts.Listener.Addr().String()), ExpectsStatusCodes([]int{http.StatusOK})); !ok || err != nil {
+		t.Fatalf("Do() = %v, %v, want: true, nil", ok, err)
+	}
+	requests.Store(0)
+
+	ok, err := Do(context.Background(), network.NewProberTransport(), target,
+		WithDNSCheck(), WithResolver(r), WithResolveTo(ts.Listener.Addr().String()), ExpectsStatusCodes([]int{http.StatusOK}))
+	if ok || !errors.Is(err, ErrDNS) {
+		t.Errorf("Do() = %v, %v, want: false, an error of class %v", ok, err, ErrDNS)
+	}
+	if got := requests.Load(); got != 0 {
+		t.Errorf("Got %d requests, want none after the DNS check failed", got)
+	}
+
+	// The hosts resolving and the IP addresses pass the check.
+	for _, target := range []string{"http://localhost:" + port, ts.URL} {
+		if ok, err := Do(context.Background(), network.NewProberTransport(), target,
+			WithDNSCheck(), ExpectsStatusCodes([]int{http.StatusOK})); !ok || err != nil {
+			t.Errorf("Do(%s) = %v, %v, want: true, nil", target, ok, err)
+		}
+	}
+}
+
+func TestWithLocalAddr(t *testing.T) {
+	local := net.ParseIP("127.0.0.2")
+	ts := newServerOn(t, "127.0.0.1:0", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if host, _, _ := net.SplitHostPort(r.RemoteAddr); host != local.String() {
+			w.WriteHeader(http.StatusForbidden)
+		}
+	}))
+	if l, err := net.Listen("tcp", net.JoinHostPort(local.String(), "0")); err != nil {
+		t.Skipf("Unable to use %s: %v", local, err)
+	} else {
+		l.Close()
+	}
+
+	if ok, err := Do(context.Background(), network.NewProberTransport(), ts.URL,
+		WithLocalAddr(local), ExpectsStatusCodes([]int{http.StatusOK})); !ok || err != nil {
+		t.Errorf("Do() = %v, %v, want: true, nil", ok, err)
+	}
+	// Without the option the probe comes from the default address.
+	if ok, err := Do(context.Background(), network.NewProberTransport(), ts.URL,
+		ExpectsStatusCodes([]int{http.StatusOK})); ok || err == nil {
+		t.Errorf("Do() = %v, %v, want: false, an error", ok, err)
+	}
+	// The address must belong to the host.
+	if ok, err := Do(context.Background(), network.NewProberTransport(), ts.URL,
+		WithLocalAddr(net.ParseIP("192.0.2.1")), ExpectsStatusCodes([]int{http.StatusOK})); ok || err == nil {
+		t.Errorf("Do() = %v, %v, want: false, an error", ok, err)
+	}
+}
+
+func TestPartitionByFamily(t *testing.T) {
+	tests := []struct {
+		name          string
+		addrs         []string
+		wantPrimaries []string
+		wantFallbacks []string
+	}{{
+		name: "empty",
+	}, {
+		name:          "single family",
+		addrs:         []string{"10.0.0.1:80", "10.0.0.2:80"},
+		wantPrimaries: []string{"10.0.0.1:80", "10.0.0.2:80"},
+	}, {
+		name:          "ipv6 first",
+		addrs:         []string{"[fd00::1]:80", "10.0.0.1:80", "[fd00::2]:80"},
+		wantPrimaries: []string{"[fd00::1]:80", "[fd00::2]:80"},
+		wantFallbacks: []string{"10.0.0.1:80"},
+	}, {
+		name:          "ipv4 first",
+		addrs:         []string{"10.0.0.1:80", "[fd00::1]:80"},
+		wantPrimaries: []string{"10.0.0.1:80"},
+		wantFallbacks: []string{"[fd00::1]:80"},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			primaries, fallbacks := partitionByFamily(test.addrs)
+			if !cmp.Equal(primaries, test.wantPrimaries) {
+				t.Errorf("Primaries = %v, want: %v", primaries, test.wantPrimaries)
+			}
+			if !cmp.Equal(fallbacks, test.wantFallbacks) {
+				t.Errorf("Fallbacks = %v, want: %v", fallbacks, test.wantFallbacks)
+			}
+		})
+	}
+}
+
+func TestWithDefaultPort(t *testing.T) {
+	for addr, want := range map[string]string{
+		"10.0.0.1":         "10.0.0.1:80",
+		"10.0.0.1:8080":    "10.0.0.1:8080",
+		"fd00::1":          "[fd00::1]:80",
+		"[fd00::1]":        "[fd00::1]:80",
+		"[fd00::1]:8080":   "[fd00::1]:8080",
+		"gateway.internal": "gateway.internal:80",
+	} {
+		if got := withDefaultPort(addr, "80"); got != want {
+			t.Errorf("withDefaultPort(%q) = %q, want: %q", addr, got, want)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package prober
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// identityEncoding is the content coding of the uncompressed bodies.
+const identityEncoding = "identity"
+
+// gzipMagic starts the gzip streams.
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// WithAcceptEncoding sets the Accept-Encoding header of the probe request to
+// the given content codings, in order of preference, e.g. "gzip". The
+// transport then leaves the responses as they were sent, for
+// ExpectsContentEncoding to check.
+func WithAcceptEncoding(encodings ...string) Preparer {
+	return func(r *http.Request) *http.Request {
+		r.Header.Set("Accept-Encoding", strings.Join(encodings, ", "))
+		return r
+	}
+}
+
+// WithCompressedBody sends body as the body of the probe request, compressed
+// with the given content coding, "gzip" or "deflate", along with the matching
+// Content-Encoding header, e.g. to probe the endpoints requiring compressed
+// payloads or to verify that the backends decode them, checking the response
+// with ExpectsBody or ExpectsStatusCodes. The backends not supporting the
+// coding are expected to answer 415 Unsupported Media Type. GET probes are
+// turned into POST ones. The probes fail if the coding isn't supported.
+func WithCompressedBody(encoding string, body []byte) Preparer {
+	encoding = strings.ToLower(encoding)
+	compressed, err := compress(encoding, body)
+	return func(r *http.Request) *http.Request {
+		if r.Method == http.MethodGet {
+			r.Method = http.MethodPost
+		}
+		r.Header.Set("Content-Encoding", encoding)
+		r.ContentLength = int64(len(compressed))
+		r.GetBody = func() (io.ReadCloser, error) {
+			if err != nil {
+				return nil, err
+			}
+			return io.NopCloser(bytes.NewReader(compressed)), nil
+		}
+		// The body is taken from GetBody by each attempt.
+		r.Body = http.NoBody
+		return r
+	}
+}
+
+// compress compresses b with encoding.
+func compress(encoding string, b []byte) ([]byte, error) {
+	var (
+		buf bytes.Buffer
+		zw  io.WriteCloser
+	)
+	switch encoding {
+	case "gzip", "x-gzip":
+		zw = gzip.NewWriter(&buf)
+	case "deflate":
+		zw = zlib.NewWriter(&buf)
+	default:
+		return nil, fmt.Errorf("unsupported content encoding of the probe body: %q", encoding)
+	}
+	if _, err := zw.Write(b); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// ExpectsContentEncoding validates that the body of the probe response is
+// compressed with the given content coding, e.g. "gzip", or not compressed
+// if it's empty or "identity", allowing to verify that the probed path
+// neither strips nor double-compresses the responses.
+//
+// Besides the Content-Encoding header, the body is checked to decode once,
+// and only once, for the gzip and deflate codings, and to not be a gzip
+// stream when uncompressed. The body is not checked for HEAD probes.
+// The transports decompressing the responses by themselves report them as
+// gzip encoded, see http.Response.Uncompressed.
+func ExpectsContentEncoding(encoding string) Verifier {
+	if encoding == "" {
+		encoding = identityEncoding
+	}
+	return func(r *http.Response, b []byte) (bool, error) {
+		got := r.Header.Get("Content-Encoding")
+		switch {
+		case r.Uncompressed:
+			got = "gzip"
+		case got == "":
+			got = identityEncoding
+		}
+		if !strings.EqualFold(got, encoding) {
+			return false, fmt.Errorf("unexpected content encoding: want %q, got %q", encoding, got)
+		}
+		if IsHeadProbe(r) || r.Uncompressed || len(b) == 0 {
+			return true, nil
+		}
+		if err := checkEncodedBody(strings.ToLower(encoding), b); err != nil {
+			return false, fmt.Errorf("%w: %v", ErrBodyMismatch, err)
+		}
+		return true, nil
+	}
+}
+
+// checkEncodedBody checks that b decodes once with encoding, and doesn't
+// decode again as a gzip stream.
+func checkEncodedBody(encoding string, b []byte) error {
+	var decoded []byte
+	switch encoding {
+	case identityEncoding:
+		decoded = b
+	case "gzip", "x-gzip":
+		zr, err := gzip.NewReader(bytes.NewReader(b))
+		if err != nil {
+			return fmt.Errorf("the body is not a gzip stream: %w", err)
+		}
+		if decoded, err = io.ReadAll(zr); err != nil {
+			return fmt.Errorf("the body is not a gzip stream: %w", err)
+		}
+	case "deflate":
+		zr, err := zlib.NewReader(bytes.NewReader(b))
+		if err != nil {
+			return fmt.Errorf("the body is not a deflate stream: %w", err)
+		}
+		if decoded, err = io.ReadAll(zr); err != nil {
+			return fmt.Errorf("the body is not a deflate stream: %w", err)
+		}
+	default:
+		// The other codings, e.g. br, aren't decoded.
+		return nil
+	}
+	if bytes.HasPrefix(decoded, gzipMagic) {
+		if encoding == identityEncoding {
+			return errors.New("the body is a gzip stream, without a Content-Encoding")
+		}
+		return errors.New("the body is compressed twice")
+	}
+	return nil
+}
@@ -0,0 +1,244 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package prober
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"knative.dev/pkg/network"
+)
+
+func gzipped(t *testing.T, b []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(b); err != nil {
+		t.Fatal("Write() =", err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal("Close() =", err)
+	}
+	return buf.Bytes()
+}
+
+func deflated(t *testing.T, b []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	if _, err := zw.Write(b); err != nil {
+		t.Fatal("Write() =", err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal("Close() =", err)
+	}
+	return buf.Bytes()
+}
+
+func TestExpectsContentEncoding(t *testing.T) {
+	body := []byte("hello, world")
+
+	tests := []struct {
+		name     string
+		method   string
+		encoding string
+		body     []byte
+		want     string
+		wantOK   bool
+	}{{
+		name:     "gzip",
+		encoding: "gzip",
+		body:     gzipped(t, body),
+		want:     "gzip",
+		wantOK:   true,
+	}, {
+		name:     "deflate",
+		encoding: "deflate",
+		body:     deflated(t, body),
+		want:     "deflate",
+		wantOK:   true,
+	}, {
+		name:   "identity",
+		body:   body,
+		wantOK: true,
+	}, {
+		name:   "explicit identity",
+		body:   body,
+		want:   "identity",
+		wantOK: true,
+	}, {
+		name:     "encoding not decoded",
+		encoding: "br",
+		body:     body,
+		want:     "br",
+		wantOK:   true,
+	}, {
+		name:     "unexpected encoding",
+		encoding: "br",
+		body:     body,
+		want:     "gzip",
+	}, {
+		name: "stripped compression",
+		body: body,
+		want: "gzip",
+	}, {
+		name: "stripped header",
+		body: gzipped(t, body),
+	}, {
+		name:     "double compression",
+		encoding: "gzip",
+		body:     gzipped(t, gzipped(t, body)),
+		want:     "gzip",
+	}, {
+		name:     "mislabeled compression",
+		encoding: "gzip",
+		body:     deflated(t, body),
+		want:     "gzip",
+	}, {
+		name:     "HEAD probe",
+		method:   http.MethodHead,
+		encoding: "gzip",
+		body:     gzipped(t, gzipped(t, body)),
+		want:     "gzip",
+		wantOK:   true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if got, want := r.Header.Get("Accept-Encoding"), "gzip, deflate"; got != want {
+					t.Errorf("Accept-Encoding = %q, want: %q", got, want)
+				}
+				if test.encoding != "" {
+					w.Header().Set("Content-Encoding", test.encoding)
+				}
+				w.Write(test.body)
+			}))
+			defer ts.Close()
+
+			ops := []interface{}{WithAcceptEncoding("gzip", "deflate"), ExpectsContentEncoding(test.want)}
+			if test.method != "" {
+				ops = append(ops, WithMethod(test.method))
+			}
+			ok, err := Do(context.Background(), network.NewProberTransport(), ts.URL, ops...)
+			if ok != test.wantOK {
+				t.Errorf("Do() = %v, %v, want: %v", ok, err, test.wantOK)
+			}
+			if !test.wantOK && err == nil {
+				t.Error("Do() = nil, want an error")
+			}
+		})
+	}
+}
+
+func TestExpectsContentEncodingUncompressed(t *testing.T) {
+	body := []byte("hello, world")
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Write(gzipped(t, body))
+	}))
+	defer ts.Close()
+
+	// The default transport asks for and decompresses gzip responses by
+	// itself, dropping their Content-Encoding.
+	ok, err := Do(context.Background(), http.DefaultTransport, ts.URL,
+		ExpectsContentEncoding("gzip"), ExpectsBody(string(body)))
+	if !ok || err != nil {
+		t.Errorf("Do() = %v, %v, want: true, nil", ok, err)
+	}
+
+	ok, err = Do(context.Background(), http.DefaultTransport, ts.URL, ExpectsContentEncoding(""))
+	if ok || err == nil {
+		t.Errorf("Do() = %v, %v, want: false, an error", ok, err)
+	}
+}
+
+func TestExpectsContentEncodingBodyMismatch(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Write(gzipped(t, gzipped(t, []byte("hello, world"))))
+	}))
+	defer ts.Close()
+
+	_, err := Do(context.Background(), network.NewProberTransport(), ts.URL,
+		WithAcceptEncoding("gzip"), ExpectsContentEncoding("gzip"))
+	if !errors.Is(err, ErrBodyMismatch) {
+		t.Errorf("Do() = %v, want an ErrBodyMismatch", err)
+	}
+}
+
+func TestWithCompressedBody(t *testing.T) {
+	body := []byte("hello, world")
+
+	// The backend decodes the bodies and echoes them.
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		var (
+			zr  io.Reader
+			err error
+		)
+		switch r.Header.Get("Content-Encoding") {
+		case "gzip":
+			zr, err = gzip.NewReader(r.Body)
+		case "deflate":
+			zr, err = zlib.NewReader(r.Body)
+		default:
+			w.WriteHeader(http.StatusUnsupportedMediaType)
+			return
+		}
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		b, err := io.ReadAll(zr)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Write(b)
+	}))
+	defer ts.Close()
+
+	for _, encoding := range []string{"gzip", "deflate", "GZIP"} {
+		t.Run(encoding, func(t *testing.T) {
+			ok, err := Do(context.Background(), network.NewProberTransport(), ts.URL,
+				WithCompressedBody(encoding, body), ExpectsStatusCodes([]int{http.StatusOK}), ExpectsBody(string(body)))
+			if !ok || err != nil {
+				t.Errorf("Do() = %t, %v, want: true, nil", ok, err)
+			}
+		})
+	}
+
+	t.Run("unsupported encoding", func(t *testing.T) {
+		_, err := Do(context.Background(), network.NewProberTransport(), ts.URL,
+			WithCompressedBody("br", body), ExpectsStatusCodes([]int{http.StatusOK}))
+		if err == nil || !strings.Contains(err.Error(), `unsupported content encoding of the probe body: "br"`) {
+			t.Errorf("Do() = %v, want an unsupported content encoding error", err)
+		}
+	})
+}
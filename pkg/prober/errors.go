@@ -0,0 +1,216 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package prober
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+
+	"knative.dev/networking/pkg/http/header"
+)
+
+// The classes of probe failures. The errors returned by Do and the Manager
+// can be matched against them with errors.Is, e.g. to retry differently per
+// class of failure.
+var (
+	// ErrDNS is the class of failures to resolve the probed host.
+	ErrDNS = errors.New("DNS resolution failed")
+	// ErrConnRefused is the class of failures to connect to the probed host.
+	ErrConnRefused = errors.New("connection refused")
+	// ErrTLSHandshake is the class of failures to complete the TLS handshake,
+	// e.g. because of an untrusted or mismatching certificate.
+	ErrTLSHandshake = errors.New("TLS handshake failed")
+	// ErrTimeout is the class of probes not answered in time.
+	ErrTimeout = errors.New("timeout")
+	// ErrBadStatus is the class of responses with an unexpected status code.
+	ErrBadStatus = errors.New("unexpected status code")
+	// ErrBodyMismatch is the class of responses with an unexpected body.
+	ErrBodyMismatch = errors.New("unexpected body")
+)
+
+// reasons are the reasons of the classes of probe failures, in the
+// CamelCase form used by conditions and events.
+var reasons = []struct {
+	class  error
+	reason string
+}{
+	{ErrDNS, "DNSError"},
+	{ErrConnRefused, "ConnRefused"},
+	{ErrTLSHandshake, "TLSHandshake"},
+	{ErrTimeout, "Timeout"},
+	{ErrBadStatus, "BadStatus"},
+	{ErrBodyMismatch, "BodyMismatch"},
+}
+
+// ErrorReason returns the reason of the class of failure of err, e.g.
+// `DNSError` or `BadStatus`, suitable for conditions and events. It returns
+// `Unknown` if err doesn't belong to any class, and "" if err is nil.
+func ErrorReason(err error) string {
+	if err == nil {
+		return ""
+	}
+	for _, r := range reasons {
+		if errors.Is(err, r.class) {
+			return r.reason
+		}
+	}
+	return "Unknown"
+}
+
+// classifiedError is an error of one of the classes of probe failures.
+type classifiedError struct {
+	class error
+	err   error
+}
+
+// Error implements error.
+func (e *classifiedError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns the original error.
+func (e *classifiedError) Unwrap() error {
+	return e.err
+}
+
+// Is reports whether target is the class of the error.
+func (e *classifiedError) Is(target error) bool {
+	return target == e.class
+}
+
+// classifyRoundTripError attaches the class of failure to an error returned
+// by a transport, if it can be told.
+func classifyRoundTripError(err error) error {
+	var (
+		dnsErr      *net.DNSError
+		netErr      net.Error
+		opErr       *net.OpError
+		recordErr   tls.RecordHeaderError
+		authErr     x509.UnknownAuthorityError
+		invalidErr  x509.CertificateInvalidError
+		hostnameErr x509.HostnameError
+	)
+	var class error
+	switch {
+	case errors.As(err, &dnsErr):
+		class = ErrDNS
+	case errors.Is(err, context.DeadlineExceeded),
+		errors.As(err, &netErr) && netErr.Timeout():
+		class = ErrTimeout
+	case errors.Is(err, syscall.ECONNREFUSED):
+		class = ErrConnRefused
+	case errors.As(err, &recordErr), errors.As(err, &authErr),
+		errors.As(err, &invalidErr), errors.As(err, &hostnameErr),
+		// The alerts sent by the server during the handshake.
+		errors.As(err, &opErr) && opErr.Op == "remote error":
+		class = ErrTLSHandshake
+	default:
+		return err
+	}
+	return &classifiedError{class: class, err: err}
+}
+
+// maxErrorBodySize is the maximum number of bytes of the response body
+// kept by a ResponseError.
+const maxErrorBodySize = 1024
+
+// errorHeaders are the response headers worth reporting when a probe fails.
+var errorHeaders = []string{
+	"Content-Type",
+	"Location",
+	"Retry-After",
+	"Server",
+	header.HashKey,
+	header.ProbeHopsKey,
+}
+
+// ResponseError is returned when a probe response does not meet the
+// expectations of a Verifier. Besides the error of the Verifier, it captures
+// the response so that failing probes can be debugged from the error alone.
+type ResponseError struct {
+	err        error
+	statusCode int
+	header     http.Header
+	body       []byte
+	truncated  bool
+}
+
+var _ error = (*ResponseError)(nil)
+
+// newResponseError captures the response which failed with err.
+func newResponseError(err error, resp *http.Response, body []byte) *ResponseError {
+	e := &ResponseError{
+		err:        err,
+		statusCode: resp.StatusCode,
+		header:     resp.Header.Clone(),
+	}
+	if len(body) > maxErrorBodySize {
+		body, e.truncated = body[:maxErrorBodySize], true
+	}
+	// The body is only valid during the verification, keep a copy.
+	e.body = append([]byte(nil), body...)
+	return e
+}
+
+// Error implements error.
+func (e *ResponseError) Error() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%v (status: %d", e.err, e.statusCode)
+	for _, k := range errorHeaders {
+		if v := e.header.Get(k); v != "" {
+			fmt.Fprintf(&sb, ", %s: %q", k, v)
+		}
+	}
+	fmt.Fprintf(&sb, ", body: %q", e.body)
+	if e.truncated {
+		sb.WriteString(" (truncated)")
+	}
+	sb.WriteString(")")
+	return sb.String()
+}
+
+// Unwrap returns the error of the Verifier.
+func (e *ResponseError) Unwrap() error {
+	return e.err
+}
+
+// StatusCode returns the status code of the response.
+func (e *ResponseError) StatusCode() int {
+	return e.statusCode
+}
+
+// Header returns the headers of the response.
+func (e *ResponseError) Header() http.Header {
+	return e.header
+}
+
+// Body returns the body of the response, truncated to its first kilobyte.
+func (e *ResponseError) Body() []byte {
+	return e.body
+}
+
+// Truncated returns whether the body returned by Body was truncated.
+func (e *ResponseError) Truncated() bool {
+	return e.truncated
+}
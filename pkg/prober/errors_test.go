@@ -0,0 +1,215 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package prober
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"knative.dev/networking/pkg/http/header"
+	"knative.dev/pkg/network"
+)
+
+func TestResponseError(t *testing.T) {
+	tests := []struct {
+		name          string
+		body          string
+		wantBody      string
+		wantTruncated bool
+		wantError     string
+	}{{
+		name:      "short body",
+		body:      "upstream connect error",
+		wantBody:  "upstream connect error",
+		wantError: `unexpected status code: want [200], got 503 (status: 503, Content-Type: "text/plain", Server: "envoy", K-Network-Hash: "abc", body: "upstream connect error")`,
+	}, {
+		name:          "long body",
+		body:          strings.Repeat("a", 2*maxErrorBodySize),
+		wantBody:      strings.Repeat("a", maxErrorBodySize),
+		wantTruncated: true,
+		wantError: `unexpected status code: want [200], got 503 (status: 503, Content-Type: "text/plain", Server: "envoy", K-Network-Hash: "abc", body: "` +
+			strings.Repeat("a", maxErrorBodySize) + `" (truncated))`,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/plain")
+				w.Header().Set("Server", "envoy")
+				w.Header().Set(header.HashKey, "abc")
+				w.Header().Set("X-Irrelevant", "foo")
+				w.WriteHeader(http.StatusServiceUnavailable)
+				w.Write([]byte(test.body))
+			}))
+			defer ts.Close()
+
+			ok, err := Do(context.Background(), network.NewProberTransport(), ts.URL, ExpectsStatusCodes([]int{http.StatusOK}))
+			if ok {
+				t.Fatal("Do() = true")
+			}
+			var re *ResponseError
+			if !errors.As(err, &re) {
+				t.Fatalf("Do() = %v, want a ResponseError", err)
+			}
+			if got, want := re.StatusCode(), http.StatusServiceUnavailable; got != want {
+				t.Errorf("StatusCode() = %d, want: %d", got, want)
+			}
+			if got, want := re.Header().Get("X-Irrelevant"), "foo"; got != want {
+				t.Errorf("Header().Get(X-Irrelevant) = %q, want: %q", got, want)
+			}
+			if got := string(re.Body()); got != test.wantBody {
+				t.Errorf("Body() = %q, want: %q", got, test.wantBody)
+			}
+			if got := re.Truncated(); got != test.wantTruncated {
+				t.Errorf("Truncated() = %v, want: %v", got, test.wantTruncated)
+			}
+			if got := err.Error(); got != test.wantError {
+				t.Errorf("Error() = %s, want: %s", got, test.wantError)
+			}
+			if errors.Unwrap(re) == nil {
+				t.Error("Unwrap() = nil")
+			}
+		})
+	}
+}
+
+func TestResponseErrorNotOnRoundTripFailure(t *testing.T) {
+	transport := network.RoundTripperFunc(func(*http.Request) (*http.Response, error) {
+		return nil, errors.New("connection refused")
+	})
+	_, err := Do(context.Background(), transport, "http://example.com", ExpectsStatusCodes([]int{http.StatusOK}))
+	var re *ResponseError
+	if err == nil || errors.As(err, &re) {
+		t.Errorf("Do() = %v, want a non-ResponseError error", err)
+	}
+}
+
+func TestErrorClasses(t *testing.T) {
+	ok := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer ok.Close()
+	tlsServer := httptest.NewTLSServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer tlsServer.Close()
+	blackHole := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer blackHole.Close()
+
+	// A closed listener refuses the connections.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal("Listen() =", err)
+	}
+	refused := "http://" + l.Addr().String()
+	l.Close()
+
+	dnsFailure := network.RoundTripperFunc(func(*http.Request) (*http.Response, error) {
+		return nil, &net.OpError{Op: "dial", Net: "tcp", Err: &net.DNSError{Err: "no such host", Name: "example.com", IsNotFound: true}}
+	})
+
+	tests := []struct {
+		name      string
+		transport http.RoundTripper
+		target    string
+		timeout   time.Duration
+		ops       []interface{}
+		want      error
+		reason    string
+	}{{
+		name:      "dns",
+		transport: dnsFailure,
+		target:    "http://example.com",
+		want:      ErrDNS,
+		reason:    "DNSError",
+	}, {
+		name:      "connection refused",
+		transport: network.NewProberTransport(),
+		target:    refused,
+		want:      ErrConnRefused,
+		reason:    "ConnRefused",
+	}, {
+		name:      "tls handshake",
+		transport: http.DefaultTransport,
+		target:    tlsServer.URL,
+		want:      ErrTLSHandshake,
+		reason:    "TLSHandshake",
+	}, {
+		name:      "timeout",
+		transport: network.NewProberTransport(),
+		target:    blackHole.URL,
+		timeout:   100 * time.Millisecond,
+		want:      ErrTimeout,
+		reason:    "Timeout",
+	}, {
+		name:      "bad status",
+		transport: network.NewProberTransport(),
+		target:    ok.URL,
+		ops:       []interface{}{ExpectsStatusCodes([]int{http.StatusAccepted})},
+		want:      ErrBadStatus,
+		reason:    "BadStatus",
+	}, {
+		name:      "body mismatch",
+		transport: network.NewProberTransport(),
+		target:    ok.URL,
+		ops:       []interface{}{ExpectsBody("not ok")},
+		want:      ErrBodyMismatch,
+		reason:    "BodyMismatch",
+	}, {
+		name:      "unclassified",
+		transport: network.NewProberTransport(),
+		target:    ok.URL,
+		ops:       []interface{}{ExpectsHeader("Foo", "Bar")},
+		reason:    "Unknown",
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := context.Background()
+			if test.timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, test.timeout)
+				defer cancel()
+			}
+			_, err := Do(ctx, test.transport, test.target, test.ops...)
+			if err == nil {
+				t.Fatal("Do() = nil, wanted an error")
+			}
+			if test.want != nil && !errors.Is(err, test.want) {
+				t.Errorf("Do() = %v, want an error matching %v", err, test.want)
+			}
+			for _, r := range reasons {
+				if r.class != test.want && errors.Is(err, r.class) {
+					t.Errorf("Do() = %v, unexpectedly matching %v", err, r.class)
+				}
+			}
+			if got := ErrorReason(err); got != test.reason {
+				t.Errorf("ErrorReason() = %q, want: %q", got, test.reason)
+			}
+		})
+	}
+
+	if got := ErrorReason(nil); got != "" {
+		t.Errorf("ErrorReason(nil) = %q, want empty", got)
+	}
+}
@@ -0,0 +1,101 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package prober
+
+import (
+	"context"
+	"time"
+)
+
+// HedgeOption is a way for the caller to send additional attempts of a probe
+// whose first attempt is slow to respond.
+type HedgeOption func(*hedgeConfig)
+
+// hedgeConfig is set by WithHedging.
+type hedgeConfig struct {
+	delay     time.Duration
+	maxHedges int
+}
+
+// WithHedging sends another attempt of the probe each time delay elapses
+// without any attempt having succeeded, up to maxHedges attempts on top of
+// the first one, and returns as soon as one of them succeeds, cancelling the
+// others. This cuts the latency of the rollouts when a small fraction of the
+// endpoints behind the probed address, e.g. gateway Pods behind a Service,
+// are slow, at the price of sending more probes to the healthy ones.
+//
+// Failed attempts don't trigger new ones: hedging is not retrying. If all
+// the attempts sent fail, the probe returns the outcome of the first one
+// that completed.
+func WithHedging(delay time.Duration, maxHedges int) HedgeOption {
+	return func(hc *hedgeConfig) {
+		hc.delay = delay
+		hc.maxHedges = maxHedges
+	}
+}
+
+// hedgedResult is the outcome of one attempt of a hedged probe.
+type hedgedResult struct {
+	res probeResult
+	err error
+}
+
+// send sends the attempts of the probe p, returning the first success.
+func (hc *hedgeConfig) send(p probe) (probeResult, error) {
+	ctx, cancel := context.WithCancel(p.req.Context())
+	defer cancel()
+	attempt := p
+	attempt.req = p.req.WithContext(ctx)
+
+	// Buffered so that the attempts still in flight when a success is
+	// returned don't block.
+	results := make(chan hedgedResult, hc.maxHedges+1)
+	launch := func() {
+		go func() {
+			res, err := attempt.send()
+			results <- hedgedResult{res: res, err: err}
+		}()
+	}
+	launch()
+	inFlight, hedges := 1, 0
+
+	timer := time.NewTimer(hc.delay)
+	defer timer.Stop()
+	var first *hedgedResult
+	for {
+		select {
+		case r := <-results:
+			inFlight--
+			if r.err == nil && r.res.ok {
+				return r.res, nil
+			}
+			if first == nil {
+				first = &r
+			}
+			if inFlight == 0 {
+				return first.res, first.err
+			}
+		case <-timer.C:
+			if hedges < hc.maxHedges {
+				hedges++
+				inFlight++
+				launch()
+				timer.Reset(hc.delay)
+			}
+		}
+	}
+}
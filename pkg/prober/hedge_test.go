@@ -0,0 +1,135 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package prober
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.uber.org/atomic"
+	"knative.dev/pkg/network"
+)
+
+func TestWithHedging(t *testing.T) {
+	const delay = 20 * time.Millisecond
+
+	tests := []struct {
+		name string
+		// slow is the number of the first requests stalling until cancelled
+		// or for a second.
+		slow      int
+		status    int
+		maxHedges int
+		wantOK    bool
+		wantSent  int32
+	}{{
+		name:      "fast first attempt",
+		status:    http.StatusOK,
+		maxHedges: 2,
+		wantOK:    true,
+		wantSent:  1,
+	}, {
+		name:      "slow first attempt",
+		slow:      1,
+		status:    http.StatusOK,
+		maxHedges: 2,
+		wantOK:    true,
+		wantSent:  2,
+	}, {
+		name:      "slow hedge",
+		slow:      2,
+		status:    http.StatusOK,
+		maxHedges: 2,
+		wantOK:    true,
+		wantSent:  3,
+	}, {
+		name:      "failures don't trigger hedges",
+		status:    http.StatusServiceUnavailable,
+		maxHedges: 2,
+		wantSent:  1,
+	}, {
+		name:      "all attempts slow",
+		slow:      10,
+		status:    http.StatusOK,
+		maxHedges: 2,
+		wantOK:    true,
+		wantSent:  3,
+	}, {
+		name:      "hedging disabled",
+		slow:      1,
+		status:    http.StatusOK,
+		maxHedges: 0,
+		wantOK:    true,
+		wantSent:  1,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var sent, cancelled atomic.Int32
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if int(sent.Inc()) <= test.slow {
+					select {
+					case <-r.Context().Done():
+						cancelled.Inc()
+						return
+					case <-time.After(time.Second):
+					}
+				}
+				w.WriteHeader(test.status)
+			}))
+			defer ts.Close()
+
+			ok, _ := Do(context.Background(), network.NewProberTransport(), ts.URL,
+				WithHedging(delay, test.maxHedges), ExpectsStatusCodes([]int{http.StatusOK}))
+			if ok != test.wantOK {
+				t.Errorf("Do() = %v, want: %v", ok, test.wantOK)
+			}
+			// Closing the server waits for the handlers in flight.
+			ts.Close()
+			if got := sent.Load(); got != test.wantSent {
+				t.Errorf("Sent %d attempts, want: %d", got, test.wantSent)
+			}
+			if test.slow > 0 && test.slow < int(test.wantSent) && cancelled.Load() == 0 {
+				t.Error("The slow attempts were not cancelled")
+			}
+		})
+	}
+}
+
+func TestWithHedgingCancelled(t *testing.T) {
+	var sent atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sent.Inc()
+		<-r.Context().Done()
+	}))
+	defer ts.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	ok, err := Do(ctx, network.NewProberTransport(), ts.URL,
+		WithHedging(10*time.Millisecond, 3), ExpectsStatusCodes([]int{http.StatusOK}))
+	if ok || err == nil {
+		t.Errorf("Do() = %v, %v, want: false, an error", ok, err)
+	}
+	ts.Close()
+	if got, want := sent.Load(), int32(4); got != want {
+		t.Errorf("Sent %d attempts, want: %d", got, want)
+	}
+}
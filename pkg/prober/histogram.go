@@ -0,0 +1,192 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package prober
+
+import (
+	"context"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"k8s.io/utils/lru"
+	"knative.dev/pkg/metrics"
+)
+
+var (
+	// DefaultLatencyBounds are the bounds, in seconds, of the latency
+	// histograms, see WithLatencyHistograms.
+	DefaultLatencyBounds = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+	// attemptsBounds are the bounds of the attempts histograms.
+	attemptsBounds = []float64{1, 2, 3, 5, 10, 20, 50, 100}
+)
+
+// Histogram counts observations in buckets, like a Prometheus histogram.
+type Histogram struct {
+	// Bounds are the inclusive upper bounds of the buckets, in increasing
+	// order.
+	Bounds []float64
+	// Counts are the number of observations in each bucket, the last one
+	// counting the observations above the last bound.
+	Counts []uint64
+	// Count is the number of observations.
+	Count uint64
+	// Sum is the sum of the observations.
+	Sum float64
+}
+
+func newHistogram(bounds []float64) *Histogram {
+	return &Histogram{Bounds: bounds, Counts: make([]uint64, len(bounds)+1)}
+}
+
+// observe adds v to the histogram.
+func (h *Histogram) observe(v float64) {
+	h.Counts[sort.SearchFloat64s(h.Bounds, v)]++
+	h.Count++
+	h.Sum += v
+}
+
+// Mean returns the mean of the observations, or 0 if none.
+func (h *Histogram) Mean() float64 {
+	if h.Count == 0 {
+		return 0
+	}
+	return h.Sum / float64(h.Count)
+}
+
+// Quantile returns the upper bound of the bucket of the q-quantile of the
+// observations, e.g. 0.99 for the 99th percentile, +Inf if it is above the
+// last bound, or 0 if there are no observations.
+func (h *Histogram) Quantile(q float64) float64 {
+	if h.Count == 0 {
+		return 0
+	}
+	rank := uint64(math.Ceil(q * float64(h.Count)))
+	if rank < 1 {
+		rank = 1
+	}
+	var seen uint64
+	for i, c := range h.Counts[:len(h.Bounds)] {
+		seen += c
+		if seen >= rank {
+			return h.Bounds[i]
+		}
+	}
+	return math.Inf(1)
+}
+
+// DeepCopy copies the receiver, creating a new Histogram.
+func (h *Histogram) DeepCopy() *Histogram {
+	out := *h
+	out.Counts = append([]uint64(nil), h.Counts...)
+	return &out
+}
+
+// TargetHistograms are the histograms of the async probes of a target, see
+// WithLatencyHistograms.
+type TargetHistograms struct {
+	// Latency is the histogram of the latency of the probe requests, in
+	// seconds, whatever their outcome.
+	Latency *Histogram
+	// Attempts is the histogram of the number of requests sent by the
+	// async probes until they finished.
+	Attempts *Histogram
+}
+
+// DeepCopy copies the receiver, creating a new TargetHistograms.
+func (th *TargetHistograms) DeepCopy() *TargetHistograms {
+	return &TargetHistograms{Latency: th.Latency.DeepCopy(), Attempts: th.Attempts.DeepCopy()}
+}
+
+// histograms holds the TargetHistograms of the most recently probed targets.
+type histograms struct {
+	bounds []float64
+
+	// mu serializes the observations, the cache only guarding its entries.
+	mu      sync.Mutex
+	targets *lru.Cache
+}
+
+// WithLatencyHistograms makes the Manager record the latency of the requests
+// and the number of attempts of the async probes of each target, exposed by
+// Histograms and Snapshot, e.g. so that controllers can warn about Ingresses
+// which are programmed but slow to answer. They are also recorded, across
+// all the targets to bound the cardinality, as the probe_latencies and
+// probe_attempts metrics. The latencies are counted in buckets with the
+// given bounds in seconds, DefaultLatencyBounds if none, and the histograms
+// of at most maxTargets targets are kept, the least recently probed ones
+// being dropped first, zero meaning no limit.
+func WithLatencyHistograms(maxTargets int, bounds ...float64) ManagerOption {
+	if len(bounds) == 0 {
+		bounds = DefaultLatencyBounds
+	}
+	bounds = append([]float64(nil), bounds...)
+	sort.Float64s(bounds)
+	return func(m *Manager) {
+		m.histograms = &histograms{bounds: bounds, targets: lru.New(maxTargets)}
+	}
+}
+
+// get returns the histograms of target, creating them if needed. h.mu must
+// be held.
+func (h *histograms) get(target string) *TargetHistograms {
+	if th, ok := h.targets.Get(target); ok {
+		return th.(*TargetHistograms)
+	}
+	th := &TargetHistograms{Latency: newHistogram(h.bounds), Attempts: newHistogram(attemptsBounds)}
+	h.targets.Add(target, th)
+	return th
+}
+
+// observeLatency records the latency of a probe request to target.
+func (m *Manager) observeLatency(ctx context.Context, target string, latency time.Duration) {
+	if m.histograms == nil {
+		return
+	}
+	m.histograms.mu.Lock()
+	m.histograms.get(target).Latency.observe(latency.Seconds())
+	m.histograms.mu.Unlock()
+	metrics.Record(ctx, probeLatenciesM.M(float64(latency.Milliseconds())))
+}
+
+// observeAttempts records the number of requests sent by an async probe of
+// target.
+func (m *Manager) observeAttempts(ctx context.Context, target string, attempts int) {
+	if m.histograms == nil || attempts == 0 {
+		return
+	}
+	m.histograms.mu.Lock()
+	m.histograms.get(target).Attempts.observe(float64(attempts))
+	m.histograms.mu.Unlock()
+	metrics.Record(ctx, probeAttemptsM.M(int64(attempts)))
+}
+
+// Histograms returns a copy of the histograms of target, or nil if the
+// Manager doesn't record them or target wasn't probed recently.
+func (m *Manager) Histograms(target string) *TargetHistograms {
+	if m.histograms == nil {
+		return nil
+	}
+	m.histograms.mu.Lock()
+	defer m.histograms.mu.Unlock()
+	th, ok := m.histograms.targets.Get(target)
+	if !ok {
+		return nil
+	}
+	return th.(*TargetHistograms).DeepCopy()
+}
@@ -0,0 +1,95 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package prober
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"knative.dev/pkg/network"
+)
+
+func TestHistogram(t *testing.T) {
+	h := newHistogram([]float64{1, 2, 5})
+	if got := h.Quantile(0.5); got != 0 {
+		t.Errorf("Quantile(0.5) of no observations = %v, want: 0", got)
+	}
+	for _, v := range []float64{0.5, 1, 1.5, 4, 10} {
+		h.observe(v)
+	}
+	if got, want := h.Counts, []uint64{2, 1, 1, 1}; !cmp.Equal(got, want) {
+		t.Errorf("Counts = %v, want: %v", got, want)
+	}
+	if got, want := h.Mean(), 17.0/5; got != want {
+		t.Errorf("Mean() = %v, want: %v", got, want)
+	}
+	for q, want := range map[float64]float64{0: 1, 0.4: 1, 0.5: 2, 0.8: 5, 0.99: math.Inf(1)} {
+		if got := h.Quantile(q); got != want {
+			t.Errorf("Quantile(%v) = %v, want: %v", q, got, want)
+		}
+	}
+
+	cp := h.DeepCopy()
+	h.observe(1)
+	if cp.Count != 5 || cp.Counts[0] != 2 {
+		t.Errorf("DeepCopy() = %+v, changed along with the original", cp)
+	}
+}
+
+func TestLatencyHistograms(t *testing.T) {
+	c := &thirdTimesTheCharmProber{}
+	ts := httptest.NewServer(c)
+	defer ts.Close()
+
+	wch := make(chan struct{})
+	m := New(func(interface{}, bool, error) {
+		close(wch)
+	}, network.NewProberTransport(), WithLatencyHistograms(10))
+	if got := m.Histograms(ts.URL); got != nil {
+		t.Errorf("Histograms() = %+v before probing, want: nil", got)
+	}
+	m.Offer(context.Background(), ts.URL, 42, probeInterval, probeTimeout, ExpectsStatusCodes([]int{http.StatusOK}))
+	<-wch
+
+	th := m.Histograms(ts.URL)
+	if th == nil {
+		t.Fatal("Histograms() = nil")
+	}
+	if got, want := th.Latency.Count, uint64(3); got != want {
+		t.Errorf("Latency.Count = %d, want: %d", got, want)
+	}
+	if got, want := th.Attempts.Count, uint64(1); got != want {
+		t.Errorf("Attempts.Count = %d, want: %d", got, want)
+	}
+	if got, want := th.Attempts.Sum, 3.0; got != want {
+		t.Errorf("Attempts.Sum = %v, want: %v", got, want)
+	}
+	if got, want := th.Latency.Bounds, DefaultLatencyBounds; !cmp.Equal(got, want) {
+		t.Errorf("Latency.Bounds = %v, want: %v", got, want)
+	}
+}
+
+func TestLatencyHistogramsDisabled(t *testing.T) {
+	m := New(func(interface{}, bool, error) {}, network.NewProberTransport())
+	if got := m.Histograms("http://example.com"); got != nil {
+		t.Errorf("Histograms() = %+v, want: nil", got)
+	}
+}
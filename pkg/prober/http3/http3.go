@@ -0,0 +1,89 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package http3 probes the implementations exposing QUIC listeners over
+// HTTP/3, through the same API as the HTTP/1 and h2c probes of the prober
+// package. It is a module of its own, so that only its users depend on
+// quic-go, and requires Go 1.26 unlike the rest of knative.dev/networking.
+package http3
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+
+	"github.com/quic-go/quic-go"
+	qhttp3 "github.com/quic-go/quic-go/http3"
+	"knative.dev/networking/pkg/prober"
+)
+
+// NextProto is the application protocol negotiated through ALPN for HTTP/3.
+const NextProto = qhttp3.NextProtoH3
+
+// TransportOption is a way for the caller to configure the HTTP/3 transport.
+type TransportOption func(*qhttp3.Transport)
+
+// WithAddress dials address, e.g. the one of the load balancer of an Ingress,
+// instead of the host of the probed URLs, which is still sent as the server
+// name and the authority of the requests.
+func WithAddress(address string) TransportOption {
+	return func(t *qhttp3.Transport) {
+		t.Dial = func(ctx context.Context, _ string, tlsConf *tls.Config, cfg *quic.Config) (*quic.Conn, error) {
+			return quic.DialAddrEarly(ctx, address, tlsConf, cfg)
+		}
+	}
+}
+
+// NewTransport returns an HTTP/3 transport, offering only NextProto through
+// ALPN, with a copy of tlsConf, which may be nil. Unlike the transports of
+// the HTTP/1 and h2c probes, the QUIC connections are kept alive, so the
+// transport must be closed once done with.
+func NewTransport(tlsConf *tls.Config, opts ...TransportOption) *qhttp3.Transport {
+	if tlsConf == nil {
+		tlsConf = &tls.Config{}
+	}
+	tlsConf = tlsConf.Clone()
+	tlsConf.NextProtos = []string{NextProto}
+	t := &qhttp3.Transport{
+		TLSClientConfig: tlsConf,
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
+}
+
+// ExpectsHTTP3 validates that the probe response was served over HTTP/3,
+// negotiated through ALPN.
+func ExpectsHTTP3() prober.Verifier {
+	proto, alpn := prober.ExpectsProto("HTTP/3.0"), prober.ExpectsALPN(NextProto)
+	return func(r *http.Response, b []byte) (bool, error) {
+		if ok, err := proto(r, b); !ok {
+			return ok, err
+		}
+		return alpn(r, b)
+	}
+}
+
+// Do is prober.Do over HTTP/3, with a transport created from tlsConf and
+// opts, and closed once done. The probes not served over HTTP/3 fail, see
+// ExpectsHTTP3. The prober.DialOptions are not supported, as they replace the
+// transport: use the TransportOptions instead.
+func Do(ctx context.Context, tlsConf *tls.Config, target string, opts []TransportOption, ops ...interface{}) (bool, error) {
+	t := NewTransport(tlsConf, opts...)
+	defer t.Close()
+	return prober.Do(ctx, t, target, append(ops, ExpectsHTTP3())...)
+}
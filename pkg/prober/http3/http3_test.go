@@ -0,0 +1,154 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package http3
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	qhttp3 "github.com/quic-go/quic-go/http3"
+	"knative.dev/networking/pkg/prober"
+)
+
+const testHost = "example.com"
+
+// newTestCert returns a self-signed certificate for testHost, along with a
+// pool trusting it.
+func newTestCert(t *testing.T) (tls.Certificate, *x509.CertPool) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal("GenerateKey() =", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: testHost},
+		DNSNames:     []string{testHost},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal("CreateCertificate() =", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal("ParseCertificate() =", err)
+	}
+	roots := x509.NewCertPool()
+	roots.AddCert(cert)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, roots
+}
+
+// newTestServer serves handler over HTTP/3 on a local UDP port, and returns
+// its address along with a pool trusting its certificate.
+func newTestServer(t *testing.T, handler http.Handler) (string, *x509.CertPool) {
+	t.Helper()
+	cert, roots := newTestCert(t)
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal("ListenPacket() =", err)
+	}
+	s := &qhttp3.Server{
+		Handler:   handler,
+		TLSConfig: qhttp3.ConfigureTLSConfig(&tls.Config{Certificates: []tls.Certificate{cert}}),
+	}
+	go s.Serve(conn)
+	t.Cleanup(func() {
+		s.Close()
+		conn.Close()
+	})
+	return conn.LocalAddr().String(), roots
+}
+
+func TestDo(t *testing.T) {
+	address, roots := newTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Host != testHost {
+			w.WriteHeader(http.StatusMisdirectedRequest)
+			return
+		}
+		fmt.Fprint(w, r.Header.Get("Probe"))
+	}))
+
+	tests := []struct {
+		name   string
+		target string
+		opts   []TransportOption
+		want   bool
+	}{{
+		name:   "address",
+		target: "https://" + testHost,
+		opts:   []TransportOption{WithAddress(address)},
+		want:   true,
+	}, {
+		name:   "address of the target",
+		target: "https://" + address,
+	}, {
+		name:   "no listener",
+		target: "https://" + testHost,
+		opts:   []TransportOption{WithAddress(net.JoinHostPort("127.0.0.1", "1"))},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+			ok, err := Do(ctx, &tls.Config{RootCAs: roots}, test.target, test.opts,
+				prober.WithHeader("Probe", "h3"),
+				prober.ExpectsStatusCodes([]int{http.StatusOK}),
+				prober.ExpectsBody("h3"))
+			if ok != test.want || (err == nil) != test.want {
+				t.Errorf("Do() = %v, %v, want success: %v", ok, err, test.want)
+			}
+		})
+	}
+}
+
+func TestExpectsHTTP3(t *testing.T) {
+	h2 := httptest.NewUnstartedServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	h2.EnableHTTP2 = true
+	h2.StartTLS()
+	defer h2.Close()
+
+	if ok, err := prober.Do(context.Background(), h2.Client().Transport, h2.URL, ExpectsHTTP3()); ok || err == nil {
+		t.Errorf("Do() = %v, %v, want failure over HTTP/2", ok, err)
+	}
+
+	// A response claiming HTTP/3 over a connection negotiating another
+	// protocol.
+	r := &http.Response{Proto: "HTTP/3.0", ProtoMajor: 3, TLS: &tls.ConnectionState{NegotiatedProtocol: "h2"}}
+	if ok, err := ExpectsHTTP3()(r, nil); ok || err == nil {
+		t.Errorf("ExpectsHTTP3() = %v, %v, want failure", ok, err)
+	}
+	r.TLS.NegotiatedProtocol = NextProto
+	if ok, err := ExpectsHTTP3()(r, nil); !ok || err != nil {
+		t.Errorf("ExpectsHTTP3() = %v, %v, want: true, nil", ok, err)
+	}
+}
@@ -0,0 +1,186 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package prober
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"knative.dev/pkg/reconciler"
+)
+
+// LeaderAwareManager wraps a Manager to only run the probes of the objects
+// whose bucket the controller is the leader of, so that the replicas of a
+// highly available controller don't all probe the same targets.
+//
+// Probes offered for objects of other buckets are kept until the leadership
+// of their bucket is acquired, and then started. Probes running when the
+// leadership of their bucket is lost are stopped, without invoking the
+// callback, and restarted on the next acquisition.
+type LeaderAwareManager struct {
+	reconciler.LeaderAwareFuncs
+
+	cb Done
+	// metadataCB replaces cb if set, see WithMetadataCallback.
+	metadataCB MetadataDone
+	manager    *Manager
+
+	// mu guards offers and the state of its entries.
+	mu sync.Mutex
+	// offers are the offered probes not completed yet, by target.
+	offers map[string]*leaderOffer
+}
+
+var _ reconciler.LeaderAware = (*LeaderAwareManager)(nil)
+
+// leaderOffer is a probe offered to a LeaderAwareManager.
+type leaderOffer struct {
+	key             types.NamespacedName
+	ctx             context.Context
+	target          string
+	arg             interface{}
+	period, timeout time.Duration
+	ops             []interface{}
+
+	// cancel stops the probe, nil when it isn't running.
+	cancel context.CancelFunc
+	// interrupted is set when the running probe is stopped by a demotion.
+	interrupted bool
+}
+
+// EventObject implements EventSubject, forwarding to the arg of the offer.
+func (o *leaderOffer) EventObject() runtime.Object {
+	if subject, ok := o.arg.(EventSubject); ok {
+		return subject.EventObject()
+	}
+	return nil
+}
+
+// NewLeaderAware creates a new LeaderAwareManager, the arguments being the
+// ones of New. The LeaderAwareManager must be promoted and demoted along with
+// the reconciler offering the probes.
+func NewLeaderAware(cb Done, transport http.RoundTripper, ops ...interface{}) *LeaderAwareManager {
+	l := &LeaderAwareManager{
+		cb:     cb,
+		offers: make(map[string]*leaderOffer),
+	}
+	l.manager = New(nil, transport, ops...)
+	// The wrapped Manager reports to l, which reports to the callbacks.
+	l.metadataCB, l.manager.metadataCB = l.manager.metadataCB, l.done
+	l.PromoteFunc = l.promote
+	l.DemoteFunc = l.demote
+	return l
+}
+
+// Offer is like Manager.Offer, key being the key of the object the probe is
+// for, whose bucket determines whether the probe runs. If a probe for the same
+// target is already offered, running or not, Offer returns OfferCoalesced and
+// the call is discarded. Otherwise it returns OfferAccepted, even when the
+// probe doesn't run because this replica isn't the leader for key.
+func (l *LeaderAwareManager) Offer(ctx context.Context, key types.NamespacedName, target string, arg interface{}, period, timeout time.Duration, ops ...interface{}) OfferResult {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, ok := l.offers[target]; ok {
+		return OfferCoalesced
+	}
+	o := &leaderOffer{
+		key:     key,
+		ctx:     ctx,
+		target:  target,
+		arg:     arg,
+		period:  period,
+		timeout: timeout,
+		ops:     ops,
+	}
+	l.offers[target] = o
+	if l.IsLeaderFor(key) {
+		l.start(o)
+	}
+	return OfferAccepted
+}
+
+// Pending returns whether a probe for target is offered, running or not.
+func (l *LeaderAwareManager) Pending(target string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, ok := l.offers[target]
+	return ok
+}
+
+// start runs the probe of o. l.mu must be held.
+func (l *LeaderAwareManager) start(o *leaderOffer) {
+	ctx, cancel := context.WithCancel(o.ctx)
+	switch l.manager.Offer(ctx, o.target, o, o.period, o.timeout, o.ops...) {
+	case OfferAccepted, OfferQueueFull:
+		// The callback is invoked for the shed probes as well.
+		o.cancel = cancel
+	default:
+		// Not expected, as the targets are coalesced here already.
+		cancel()
+	}
+}
+
+// promote starts the probes of the objects in b.
+func (l *LeaderAwareManager) promote(b reconciler.Bucket, _ func(reconciler.Bucket, types.NamespacedName)) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, o := range l.offers {
+		if o.cancel == nil && b.Has(o.key) {
+			l.start(o)
+		}
+	}
+	return nil
+}
+
+// demote stops the probes of the objects in b.
+func (l *LeaderAwareManager) demote(b reconciler.Bucket) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, o := range l.offers {
+		if o.cancel != nil && !o.interrupted && b.Has(o.key) {
+			o.interrupted = true
+			o.cancel()
+		}
+	}
+}
+
+// done is the callback of the wrapped Manager.
+func (l *LeaderAwareManager) done(arg interface{}, md Metadata, success bool, err error) {
+	o := arg.(*leaderOffer)
+	l.mu.Lock()
+	o.cancel = nil
+	if o.interrupted {
+		o.interrupted = false
+		// The leadership may have been acquired again in the meantime.
+		if l.IsLeaderFor(o.key) {
+			l.start(o)
+		}
+		l.mu.Unlock()
+		return
+	}
+	delete(l.offers, o.target)
+	l.mu.Unlock()
+	if l.metadataCB != nil {
+		l.metadataCB(o.arg, md, success, err)
+	} else {
+		l.cb(o.arg, success, err)
+	}
+}
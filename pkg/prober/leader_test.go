@@ -0,0 +1,193 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package prober
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.uber.org/atomic"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"knative.dev/pkg/network"
+	"knative.dev/pkg/reconciler"
+)
+
+// keyBucket is a Bucket holding a single key.
+type keyBucket struct {
+	key types.NamespacedName
+}
+
+func (b keyBucket) Name() string                      { return b.key.String() }
+func (b keyBucket) Has(key types.NamespacedName) bool { return key == b.key }
+
+func enqueueNothing(reconciler.Bucket, types.NamespacedName) {}
+
+func TestLeaderAwareOfferBeforePromotion(t *testing.T) {
+	var requests atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Inc()
+	}))
+	defer ts.Close()
+
+	doneCh := make(chan bool, 1)
+	m := NewLeaderAware(func(arg interface{}, success bool, err error) {
+		if got := arg.(string); got != "arg" {
+			t.Errorf("arg = %q, want: arg", got)
+		}
+		doneCh <- success
+	}, network.NewProberTransport())
+
+	key := types.NamespacedName{Namespace: "ns", Name: "ing"}
+	if got := m.Offer(context.Background(), key, ts.URL, "arg", probeInterval, probeTimeout); got != OfferAccepted {
+		t.Fatalf("Offer() = %v, want: %v", got, OfferAccepted)
+	}
+	if got := m.Offer(context.Background(), key, ts.URL, "arg", probeInterval, probeTimeout); got != OfferCoalesced {
+		t.Errorf("Second Offer() = %v, want: %v", got, OfferCoalesced)
+	}
+	if !m.Pending(ts.URL) {
+		t.Error("Pending() = false, want: true")
+	}
+
+	// Not leader: the probe must not run.
+	time.Sleep(3 * probeInterval)
+	if got := requests.Load(); got != 0 {
+		t.Fatalf("Got %d probe requests before promotion, want: 0", got)
+	}
+
+	// A bucket not holding the key doesn't start the probe either.
+	if err := m.Promote(keyBucket{types.NamespacedName{Namespace: "ns", Name: "other"}}, enqueueNothing); err != nil {
+		t.Fatal("Promote() =", err)
+	}
+	time.Sleep(3 * probeInterval)
+	if got := requests.Load(); got != 0 {
+		t.Fatalf("Got %d probe requests after promotion for another bucket, want: 0", got)
+	}
+
+	if err := m.Promote(keyBucket{key}, enqueueNothing); err != nil {
+		t.Fatal("Promote() =", err)
+	}
+	select {
+	case success := <-doneCh:
+		if !success {
+			t.Error("Probe failed, want success")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the probe")
+	}
+
+	// Completed probes can be offered again.
+	if m.Pending(ts.URL) {
+		t.Error("Pending() after completion = true, want: false")
+	}
+	if got := m.Offer(context.Background(), key, ts.URL, "arg", probeInterval, probeTimeout); got != OfferAccepted {
+		t.Errorf("Offer() after completion = %v, want: %v", got, OfferAccepted)
+	}
+	<-doneCh
+}
+
+func TestLeaderAwareDemotion(t *testing.T) {
+	var (
+		ready    atomic.Bool
+		requests atomic.Int32
+	)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Inc()
+		if !ready.Load() {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	doneCh := make(chan bool, 1)
+	m := NewLeaderAware(func(_ interface{}, success bool, _ error) {
+		doneCh <- success
+	}, network.NewProberTransport())
+	if err := m.Promote(reconciler.UniversalBucket(), enqueueNothing); err != nil {
+		t.Fatal("Promote() =", err)
+	}
+
+	key := types.NamespacedName{Namespace: "ns", Name: "ing"}
+	m.Offer(context.Background(), key, ts.URL, "arg", probeInterval, time.Minute, ExpectsStatusCodes([]int{http.StatusOK}))
+	if err := wait.PollImmediate(probeInterval, 5*time.Second, func() (bool, error) {
+		return requests.Load() > 0, nil
+	}); err != nil {
+		t.Fatal("The probe did not start:", err)
+	}
+
+	m.Demote(reconciler.UniversalBucket())
+	// Let the probe wind down, it must then stop sending requests.
+	time.Sleep(3 * probeInterval)
+	stopped := requests.Load()
+	time.Sleep(3 * probeInterval)
+	if got := requests.Load(); got != stopped {
+		t.Errorf("Got %d probe requests after demotion, want: %d", got, stopped)
+	}
+	select {
+	case <-doneCh:
+		t.Fatal("The callback was invoked on demotion")
+	default:
+	}
+
+	ready.Store(true)
+	if err := m.Promote(reconciler.UniversalBucket(), enqueueNothing); err != nil {
+		t.Fatal("Promote() =", err)
+	}
+	select {
+	case success := <-doneCh:
+		if !success {
+			t.Error("Probe failed, want success")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the restarted probe")
+	}
+}
+
+func TestLeaderAwareMetadata(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+
+	mdCh := make(chan Metadata, 1)
+	m := NewLeaderAware(nil, network.NewProberTransport(),
+		WithMetadataCallback(func(arg interface{}, md Metadata, success bool, err error) {
+			if got := arg.(string); got != "arg" {
+				t.Errorf("arg = %q, want: arg", got)
+			}
+			if !success || err != nil {
+				t.Errorf("Probe = %v, %v, want success", success, err)
+			}
+			mdCh <- md
+		}))
+	if err := m.Promote(reconciler.UniversalBucket(), enqueueNothing); err != nil {
+		t.Fatal("Promote() =", err)
+	}
+
+	key := types.NamespacedName{Namespace: "ns", Name: "ing"}
+	m.Offer(context.Background(), key, ts.URL, "arg", probeInterval, probeTimeout,
+		WithMetadata(Metadata{"generation": "3"}))
+	select {
+	case md := <-mdCh:
+		if got, want := md["generation"], "3"; got != want {
+			t.Errorf("Metadata[generation] = %q, want: %q", got, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the probe")
+	}
+}
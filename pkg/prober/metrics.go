@@ -0,0 +1,56 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package prober
+
+import (
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"knative.dev/pkg/metrics"
+)
+
+var (
+	shedProbesM = stats.Int64(
+		"shed_probe_count",
+		"Number of async probes shed because the timeout budget of the prober was exhausted",
+		stats.UnitDimensionless)
+	probeLatenciesM = stats.Float64(
+		"probe_latencies",
+		"The latency of the probe requests, recorded when the prober keeps latency histograms",
+		stats.UnitMilliseconds)
+	probeAttemptsM = stats.Int64(
+		"probe_attempts",
+		"The number of requests sent by the async probes until they finished, recorded when the prober keeps latency histograms",
+		stats.UnitDimensionless)
+)
+
+func init() {
+	if err := metrics.RegisterResourceView(&view.View{
+		Description: shedProbesM.Description(),
+		Measure:     shedProbesM,
+		Aggregation: view.Count(),
+	}, &view.View{
+		Description: probeLatenciesM.Description(),
+		Measure:     probeLatenciesM,
+		Aggregation: view.Distribution(5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000, 10000),
+	}, &view.View{
+		Description: probeAttemptsM.Description(),
+		Measure:     probeAttemptsM,
+		Aggregation: view.Distribution(1, 2, 3, 5, 10, 20, 50, 100),
+	}); err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,132 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package prober
+
+import (
+	"sync"
+	"time"
+
+	"go.uber.org/atomic"
+)
+
+// NegativeCache caches the failures of the probes answered with a 3xx or 4xx
+// status code for a TTL, so that callers probing in tight loops, e.g.
+// misbehaving reconcilers, don't probe the endpoints known to be bad hundreds
+// of times per second. Other failures, e.g. 5xx responses or connection
+// errors, are usually transient and never cached, and neither are successes.
+type NegativeCache struct {
+	ttl time.Duration
+	now func() time.Time
+
+	hits   atomic.Uint64
+	misses atomic.Uint64
+
+	// mu guards entries and nextSweep.
+	mu      sync.Mutex
+	entries map[string]negativeEntry
+	// nextSweep is when the expired entries are removed next.
+	nextSweep time.Time
+}
+
+// negativeEntry is a cached probe failure.
+type negativeEntry struct {
+	err     error
+	expires time.Time
+}
+
+// NewNegativeCache creates a NegativeCache keeping the failures for ttl.
+func NewNegativeCache(ttl time.Duration) *NegativeCache {
+	return &NegativeCache{
+		ttl:     ttl,
+		now:     time.Now,
+		entries: make(map[string]negativeEntry),
+	}
+}
+
+// Hits returns the number of probes answered from the cache.
+func (c *NegativeCache) Hits() uint64 {
+	return c.hits.Load()
+}
+
+// Misses returns the number of probes sent because no failure was cached.
+func (c *NegativeCache) Misses() uint64 {
+	return c.misses.Load()
+}
+
+// NegativeCacheOption is a way for the caller to reuse the recent failure of
+// an identical probe rather than sending the probe again.
+type NegativeCacheOption func(*negativeCacheConfig)
+
+// negativeCacheConfig is set by WithNegativeCache.
+type negativeCacheConfig struct {
+	cache *NegativeCache
+	key   string
+}
+
+// WithNegativeCache fails the probe without sending it if an identical probe
+// failed with a 3xx or 4xx status code within the TTL of c, and caches the
+// failure of the probe otherwise. As for WithCoalescer, the probes are
+// considered identical if they have the same method, URL and Host, and the
+// same key, which the callers must derive from the other options of the
+// probe. The async probes of a Manager with a period shorter than the TTL
+// are effectively sent once per TTL while they fail.
+func WithNegativeCache(c *NegativeCache, key string) NegativeCacheOption {
+	return func(nc *negativeCacheConfig) {
+		nc.cache = c
+		nc.key = key
+	}
+}
+
+// lookup returns whether a failure of the probes identical to p is cached,
+// and its error.
+func (nc *negativeCacheConfig) lookup(p probe) (bool, error) {
+	c := nc.cache
+	key := identityKey(p, nc.key)
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	if ok && !c.now().Before(entry.expires) {
+		delete(c.entries, key)
+		ok = false
+	}
+	c.mu.Unlock()
+	if !ok {
+		c.misses.Inc()
+		return false, nil
+	}
+	c.hits.Inc()
+	return true, entry.err
+}
+
+// store caches the outcome of the probe p if it is a cacheable failure.
+func (nc *negativeCacheConfig) store(p probe, res probeResult, err error) {
+	if res.ok || res.status < 300 || res.status >= 500 {
+		return
+	}
+	c := nc.cache
+	now := c.now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !now.Before(c.nextSweep) {
+		for k, e := range c.entries {
+			if !now.Before(e.expires) {
+				delete(c.entries, k)
+			}
+		}
+		c.nextSweep = now.Add(c.ttl)
+	}
+	c.entries[identityKey(p, nc.key)] = negativeEntry{err: err, expires: now.Add(c.ttl)}
+}
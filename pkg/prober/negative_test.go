@@ -0,0 +1,151 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package prober
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.uber.org/atomic"
+	"knative.dev/pkg/network"
+)
+
+func TestWithNegativeCache(t *testing.T) {
+	tests := []struct {
+		name         string
+		status       int
+		wantRequests int32
+		wantHits     uint64
+	}{{
+		name:         "success",
+		status:       http.StatusOK,
+		wantRequests: 3,
+	}, {
+		name:         "redirect",
+		status:       http.StatusFound,
+		wantRequests: 1,
+		wantHits:     2,
+	}, {
+		name:         "not found",
+		status:       http.StatusNotFound,
+		wantRequests: 1,
+		wantHits:     2,
+	}, {
+		name:         "unavailable",
+		status:       http.StatusServiceUnavailable,
+		wantRequests: 3,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var requests atomic.Int32
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests.Inc()
+				w.WriteHeader(test.status)
+			}))
+			defer ts.Close()
+
+			c := NewNegativeCache(time.Hour)
+			for i := 0; i < 3; i++ {
+				ok, err := Do(context.Background(), network.NewProberTransport(), ts.URL,
+					ExpectsStatusCodes([]int{http.StatusOK}), WithNegativeCache(c, "key"))
+				if got, want := ok, test.status == http.StatusOK; got != want {
+					t.Errorf("Do() = %t, want %t", got, want)
+				}
+				if test.status != http.StatusOK && !errors.Is(err, ErrBadStatus) {
+					t.Errorf("Do() = %v, want %v", err, ErrBadStatus)
+				}
+			}
+			if got := requests.Load(); got != test.wantRequests {
+				t.Errorf("Requests = %d, want %d", got, test.wantRequests)
+			}
+			if got, want := c.Hits(), test.wantHits; got != want {
+				t.Errorf("Hits() = %d, want %d", got, want)
+			}
+			if got, want := c.Misses(), 3-test.wantHits; got != want {
+				t.Errorf("Misses() = %d, want %d", got, want)
+			}
+		})
+	}
+}
+
+func TestNegativeCacheExpiry(t *testing.T) {
+	var requests atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Inc()
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer ts.Close()
+
+	now := time.Now()
+	c := NewNegativeCache(time.Minute)
+	c.now = func() time.Time { return now }
+	probe := func(key, path string) {
+		t.Helper()
+		if ok, _ := Do(context.Background(), network.NewProberTransport(), ts.URL+path,
+			ExpectsStatusCodes([]int{http.StatusOK}), WithNegativeCache(c, key)); ok {
+			t.Error("Do() = true, want false")
+		}
+	}
+
+	probe("a", "/")
+	probe("a", "/")
+	if got, want := requests.Load(), int32(1); got != want {
+		t.Errorf("Requests = %d, want %d", got, want)
+	}
+
+	// Probes differing by their key or URL are not identical.
+	probe("b", "/")
+	probe("a", "/other")
+	if got, want := requests.Load(), int32(3); got != want {
+		t.Errorf("Requests = %d, want %d", got, want)
+	}
+
+	now = now.Add(time.Minute)
+	probe("a", "/")
+	if got, want := requests.Load(), int32(4); got != want {
+		t.Errorf("Requests after the TTL = %d, want %d", got, want)
+	}
+	// The other expired entries are swept.
+	c.mu.Lock()
+	entries := len(c.entries)
+	c.mu.Unlock()
+	if entries != 1 {
+		t.Errorf("Entries = %d, want 1", entries)
+	}
+}
+
+func TestNilNegativeCache(t *testing.T) {
+	var requests atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Inc()
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer ts.Close()
+
+	for i := 0; i < 2; i++ {
+		Do(context.Background(), network.NewProberTransport(), ts.URL,
+			ExpectsStatusCodes([]int{http.StatusOK}), WithNegativeCache(nil, "key"))
+	}
+	if got, want := requests.Load(), int32(2); got != want {
+		t.Errorf("Requests = %d, want %d", got, want)
+	}
+}
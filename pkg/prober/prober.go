@@ -17,23 +17,41 @@ limitations under the License.
 package prober
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
 	"fmt"
-	"io/ioutil"
+	"math/rand"
 	"net/http"
+	"net/http/httptrace"
+	"reflect"
+	"sort"
+	"strconv"
 	"sync"
 	"time"
 
+	"go.uber.org/atomic"
 	"go.uber.org/zap"
-	"k8s.io/apimachinery/pkg/util/sets"
+	"google.golang.org/grpc/codes"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/clock"
 	"knative.dev/pkg/logging"
+	"knative.dev/pkg/metrics"
 )
 
 // Preparer is a way for the caller to modify the HTTP request before it goes out.
 type Preparer func(r *http.Request) *http.Request
 
 // Verifier is a way for the caller to validate the HTTP response after it comes back.
+// The body is only valid for the duration of the call and must not be retained.
+// Responses to HEAD probes carry no body, so verifiers inspecting it should let
+// them pass, see IsHeadProbe.
 type Verifier func(r *http.Response, b []byte) (bool, error)
 
 // WithHeader sets a header in the probe request.
@@ -60,13 +78,116 @@ func WithPath(path string) Preparer {
 	}
 }
 
+// WithMethod sets the method of the probe request, GET by default. HEAD and
+// OPTIONS probes allow cheaply checking the existence of large resources
+// without transferring their bodies; body expectations are skipped for HEAD.
+func WithMethod(method string) Preparer {
+	return func(r *http.Request) *http.Request {
+		r.Method = method
+		return r
+	}
+}
+
+// WithConnectionClose asks for the connection of the probe to be closed once
+// the probe is answered, with a `Connection: close` header, so that each
+// attempt goes over a new connection rather than a pooled one. This allows
+// diagnosing the traffic still reaching old Pods through keep-alive
+// connections during rollouts, along with ExpectsConnectionClose. It has no
+// effect on HTTP/2 probes, whose connections are multiplexed.
+func WithConnectionClose() Preparer {
+	return func(r *http.Request) *http.Request {
+		r.Close = true
+		return r
+	}
+}
+
+// WithBearerToken authenticates the probe request with the given token, in an
+// `Authorization: Bearer` header, e.g. to probe the admin endpoints of the
+// gateways behind authentication.
+func WithBearerToken(token string) Preparer {
+	return func(r *http.Request) *http.Request {
+		r.Header.Set("Authorization", "Bearer "+token)
+		return r
+	}
+}
+
+// WithBasicAuth authenticates the probe request with the given user name and
+// password, with HTTP Basic Authentication.
+func WithBasicAuth(username, password string) Preparer {
+	return func(r *http.Request) *http.Request {
+		r.SetBasicAuth(username, password)
+		return r
+	}
+}
+
+// RequestMutator is a way for the caller to modify each request sent by a
+// probe, unlike the Preparers which modify the probe request once.
+type RequestMutator func(r *http.Request, attempt int)
+
+// WithRequestMutator calls f with each request sent by the probe, right before
+// it is sent, along with the 1-based number of the attempt it belongs to,
+// e.g. to set a nonce header or an attempt counter correlating the attempts
+// in the access logs of the gateways. The attempts are counted across the
+// retries of the async probes of the Manager, and each hedged request or
+// request to one of the addresses of the target counts as an attempt. f may
+// be called concurrently by such requests.
+func WithRequestMutator(f func(r *http.Request, attempt int)) RequestMutator {
+	return RequestMutator(f)
+}
+
+// IsHeadProbe returns whether r answers a HEAD probe, and hence has no body.
+func IsHeadProbe(r *http.Response) bool {
+	return r.Request != nil && r.Request.Method == http.MethodHead
+}
+
 // ExpectsBody validates that the body of the probe response matches the provided string.
+// The body is not checked for HEAD probes.
 func ExpectsBody(body string) Verifier {
 	return func(r *http.Response, b []byte) (bool, error) {
-		if string(b) == body {
+		if IsHeadProbe(r) || string(b) == body {
+			return true, nil
+		}
+		return false, fmt.Errorf("%w: want %q, got %q", ErrBodyMismatch, body, string(b))
+	}
+}
+
+// ExpectsBodySHA256 validates that the SHA-256 digest of the body of the probe
+// response matches the provided hex encoded digest, e.g. as computed by
+// sha256sum. This allows verifying large payloads, e.g. static assets served
+// through the Ingress, without holding the expected body. The body is not
+// checked for HEAD probes.
+func ExpectsBodySHA256(digest string) Verifier {
+	want, err := hex.DecodeString(digest)
+	if err == nil && len(want) != sha256.Size {
+		err = fmt.Errorf("want %d bytes, got %d", sha256.Size, len(want))
+	}
+	return func(r *http.Response, b []byte) (bool, error) {
+		if err != nil {
+			return false, fmt.Errorf("invalid SHA-256 digest %q: %w", digest, err)
+		}
+		if IsHeadProbe(r) {
 			return true, nil
 		}
-		return false, fmt.Errorf("unexpected body: want %q, got %q", body, string(b))
+		if got := sha256.Sum256(b); !bytes.Equal(got[:], want) {
+			return false, fmt.Errorf("%w: want SHA-256 %x, got %x", ErrBodyMismatch, want, got)
+		}
+		return true, nil
+	}
+}
+
+// ExpectsResponse validates the probe response and its body with f, which
+// returns why the response isn't the expected one, if it isn't. It is meant
+// for the checks combining e.g. the status code, the headers and the body of
+// the response, which would otherwise need many options failing with errors
+// hiding which part of the check failed. The errors of f are reported as is,
+// so that their class can still be told with errors.Is, e.g. when wrapping
+// ErrBadStatus.
+func ExpectsResponse(f func(r *http.Response, body []byte) error) Verifier {
+	return func(r *http.Response, b []byte) (bool, error) {
+		if err := f(r, b); err != nil {
+			return false, err
+		}
+		return true, nil
 	}
 }
 
@@ -80,6 +201,196 @@ func ExpectsHeader(name, value string) Verifier {
 	}
 }
 
+// ExpectsTrailers validates that the given trailers of the probe response match the
+// provided strings. Trailers are only available once the whole body has been read,
+// which is the case by the time verifiers run.
+func ExpectsTrailers(trailers map[string]string) Verifier {
+	return func(r *http.Response, _ []byte) (bool, error) {
+		for name, value := range trailers {
+			if got := r.Trailer.Get(name); got != value {
+				return false, fmt.Errorf("unexpected trailer %q: want %q, got %q", name, value, got)
+			}
+		}
+		return true, nil
+	}
+}
+
+// ExpectsGRPCStatus validates that the gRPC status of the probe response matches the
+// provided code. The status is read from the `grpc-status` trailer, or from the headers
+// for trailers-only responses.
+func ExpectsGRPCStatus(code codes.Code) Verifier {
+	return func(r *http.Response, _ []byte) (bool, error) {
+		status, message := r.Trailer.Get(grpcStatusKey), r.Trailer.Get(grpcMessageKey)
+		if status == "" {
+			status, message = r.Header.Get(grpcStatusKey), r.Header.Get(grpcMessageKey)
+		}
+		if status == "" {
+			return false, fmt.Errorf("missing gRPC status: want %v", code)
+		}
+		if status != strconv.Itoa(int(code)) {
+			return false, fmt.Errorf("unexpected gRPC status: want %v, got %s (message: %q)", code, status, message)
+		}
+		return true, nil
+	}
+}
+
+// ExpectsCertificateFor validates that the certificate served for the probe covers
+// the given DNS name. The probe target must be an HTTPS URL.
+func ExpectsCertificateFor(dnsName string) Verifier {
+	return func(r *http.Response, _ []byte) (bool, error) {
+		if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+			return false, errors.New("no certificate was served")
+		}
+		if err := r.TLS.PeerCertificates[0].VerifyHostname(dnsName); err != nil {
+			return false, fmt.Errorf("unexpected certificate: %w", err)
+		}
+		return true, nil
+	}
+}
+
+// ExpectsCertIssuedBy validates that the certificate served for the probe chains up
+// to one of the CAs of the given pool. The probe target must be an HTTPS URL.
+// This allows verifying the served certificate even when the transport is
+// configured to skip TLS verification.
+func ExpectsCertIssuedBy(roots *x509.CertPool) Verifier {
+	return func(r *http.Response, _ []byte) (bool, error) {
+		if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+			return false, errors.New("no certificate was served")
+		}
+		certs := r.TLS.PeerCertificates
+		intermediates := x509.NewCertPool()
+		for _, c := range certs[1:] {
+			intermediates.AddCert(c)
+		}
+		if _, err := certs[0].Verify(x509.VerifyOptions{
+			Roots:         roots,
+			Intermediates: intermediates,
+		}); err != nil {
+			return false, fmt.Errorf("unexpected certificate issuer: %w", err)
+		}
+		return true, nil
+	}
+}
+
+// ExpectsSPIFFEID validates that the certificate served for the probe is an X.509
+// SVID for the given SPIFFE ID, e.g. `spiffe://cluster.local/ns/default/sa/default`.
+// This allows verifying that a probe going through a mesh using mTLS reached the
+// expected workload. The probe target must be an HTTPS URL.
+func ExpectsSPIFFEID(id string) Verifier {
+	return func(r *http.Response, _ []byte) (bool, error) {
+		if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+			return false, errors.New("no certificate was served")
+		}
+		var ids []string
+		for _, uri := range r.TLS.PeerCertificates[0].URIs {
+			if uri.Scheme == "spiffe" {
+				ids = append(ids, uri.String())
+			}
+		}
+		switch {
+		case len(ids) == 0:
+			return false, fmt.Errorf("unexpected certificate: want SPIFFE ID %q, got none", id)
+		case len(ids) > 1:
+			// An SVID must carry exactly one SPIFFE ID.
+			return false, fmt.Errorf("unexpected certificate: want SPIFFE ID %q, got multiple: %v", id, ids)
+		case ids[0] != id:
+			return false, fmt.Errorf("unexpected certificate: want SPIFFE ID %q, got %q", id, ids[0])
+		}
+		return true, nil
+	}
+}
+
+// ExpectsContentLength validates that the probe response announces a body of the given
+// length. Unlike ExpectsBody, this also applies to HEAD probes, as their responses
+// carry the Content-Length the corresponding GET would have.
+func ExpectsContentLength(length int64) Verifier {
+	return func(r *http.Response, _ []byte) (bool, error) {
+		if r.ContentLength == length {
+			return true, nil
+		}
+		return false, fmt.Errorf("unexpected content length: want %d, got %d", length, r.ContentLength)
+	}
+}
+
+// ExpectsLatencyUnder validates that the probe completed, including reading the
+// whole response body, within the given latency budget. This allows gating
+// readiness on rough performance expectations, e.g. during rollouts.
+func ExpectsLatencyUnder(d time.Duration) Verifier {
+	return func(r *http.Response, _ []byte) (bool, error) {
+		start, ok := r.Request.Context().Value(probeStartKey{}).(time.Time)
+		if !ok {
+			return false, errors.New("the start time of the probe is unknown")
+		}
+		if took := time.Since(start); took >= d {
+			return false, fmt.Errorf("probe too slow: want under %v, took %v", d, took)
+		}
+		return true, nil
+	}
+}
+
+// ExpectsConnectionReused validates whether the probe was sent over a
+// connection reused from a previous request, allowing to verify the
+// connection pooling behavior of the probed path, e.g. that keep-alive
+// connections are not closed. The transport must report its connections
+// through httptrace, as the ones of net/http and x/net/http2 do.
+func ExpectsConnectionReused(reused bool) Verifier {
+	return func(r *http.Response, _ []byte) (bool, error) {
+		ct, ok := r.Request.Context().Value(connTraceKey{}).(*connTrace)
+		if !ok || !ct.got.Load() {
+			return false, errors.New("the transport did not report the connection of the probe")
+		}
+		if got := ct.reused.Load(); got != reused {
+			return false, fmt.Errorf("unexpected connection reuse: want %t, got %t", reused, got)
+		}
+		return true, nil
+	}
+}
+
+// ExpectsConnectionClose validates whether the server announced, with a
+// `Connection: close` header, that it closes the connection of the probe once
+// answered, e.g. to verify that the probed path honors WithConnectionClose, or
+// that draining servers stop keeping their connections alive. The HTTP/2
+// responses never announce it.
+func ExpectsConnectionClose(close bool) Verifier {
+	return func(r *http.Response, _ []byte) (bool, error) {
+		if r.Close != close {
+			return false, fmt.Errorf("unexpected connection close: want %t, got %t", close, r.Close)
+		}
+		return true, nil
+	}
+}
+
+// ExpectsProto validates the protocol of the probe response, e.g. "HTTP/2.0",
+// so that probes catch the ingresses negotiating a different protocol than
+// expected, e.g. downgrading h2c to HTTP/1.1. The ProtoMajor and ProtoMinor
+// of the response are compared when its Proto doesn't match, for the
+// transports only setting them.
+func ExpectsProto(proto string) Verifier {
+	major, minor, ok := http.ParseHTTPVersion(proto)
+	return func(r *http.Response, _ []byte) (bool, error) {
+		if r.Proto == proto || (ok && r.ProtoMajor == major && r.ProtoMinor == minor) {
+			return true, nil
+		}
+		return false, fmt.Errorf("unexpected protocol: want %s, got %s", proto, r.Proto)
+	}
+}
+
+// ExpectsALPN validates the application protocol negotiated through ALPN
+// for the connection of the probe response, e.g. "h2" or "h3", so that probes
+// catch the listeners falling back to another protocol. The probes not sent
+// over TLS fail.
+func ExpectsALPN(proto string) Verifier {
+	return func(r *http.Response, _ []byte) (bool, error) {
+		if r.TLS == nil {
+			return false, fmt.Errorf("unexpected protocol: want %s negotiated through ALPN, got no TLS connection", proto)
+		}
+		if got := r.TLS.NegotiatedProtocol; got != proto {
+			return false, fmt.Errorf("unexpected negotiated protocol: want %s, got %q", proto, got)
+		}
+		return true, nil
+	}
+}
+
 // ExpectsStatusCodes validates that the given status code of the probe response matches the provided int.
 func ExpectsStatusCodes(statusCodes []int) Verifier {
 	return func(r *http.Response, _ []byte) (bool, error) {
@@ -88,41 +399,495 @@ func ExpectsStatusCodes(statusCodes []int) Verifier {
 				return true, nil
 			}
 		}
-		return false, fmt.Errorf("unexpected status code: want %v, got %v", statusCodes, r.StatusCode)
+		return false, fmt.Errorf("%w: want %v, got %v", ErrBadStatus, statusCodes, r.StatusCode)
 	}
 }
 
-// Do sends a single probe to given target, e.g. `http://revision.default.svc.cluster.local:81`.
-// Do returns whether the probe was successful or not, or there was an error probing.
-func Do(ctx context.Context, transport http.RoundTripper, target string, ops ...interface{}) (bool, error) {
+const (
+	// grpcStatusKey is the name of the trailer carrying the gRPC status code.
+	grpcStatusKey = "Grpc-Status"
+	// grpcMessageKey is the name of the trailer carrying the gRPC status message.
+	grpcMessageKey = "Grpc-Message"
+)
+
+// probeStartKey is the context key of the time the current attempt of a probe
+// was sent at.
+type probeStartKey struct{}
+
+// connTraceKey is the context key of the connTrace of the current attempt of
+// a probe.
+type connTraceKey struct{}
+
+// connTrace records the connection the current attempt of a probe was sent over.
+type connTrace struct {
+	got    atomic.Bool
+	reused atomic.Bool
+}
+
+// randInt63n is rand.Int63n, overridable for testing.
+var randInt63n = rand.Int63n
+
+// maxPooledBufferSize is the capacity above which response body buffers are
+// not returned to the pool, to avoid pinning the memory of unusually large
+// responses.
+const maxPooledBufferSize = 64 * 1024
+
+// bufferPool holds the buffers response bodies are read into.
+var bufferPool = sync.Pool{
+	New: func() interface{} {
+		return new(bytes.Buffer)
+	},
+}
+
+// probe is a probe request ready to be sent, possibly multiple times.
+type probe struct {
+	target    string
+	req       *http.Request
+	transport http.RoundTripper
+	ops       []interface{}
+
+	// coalesce is set by WithCoalescer.
+	coalesce *coalesceConfig
+	// negative is set by WithNegativeCache.
+	negative *negativeCacheConfig
+	// hedge is set by WithHedging.
+	hedge *hedgeConfig
+	// dnsCheck is set if WithDNSCheck is.
+	dnsCheck *dialConfig
+	// all is set if WithProbeAllAddresses or WithAddressQuorum is.
+	all *allAddresses
+	// mutators are set by WithRequestMutator, along with attempts, the
+	// number of requests sent, shared by the copies of the probe.
+	mutators []RequestMutator
+	attempts *atomic.Int32
+	// timings are set by WithTimings.
+	timings []TimingsCallback
+}
+
+// probeResult is the outcome of a probe sent.
+type probeResult struct {
+	ok bool
+	// status is the status code of the response, 0 if none was received.
+	status int
+}
+
+// newProbe builds the probe request to target, applying the ops.
+func newProbe(ctx context.Context, transport http.RoundTripper, target string, ops []interface{}) (probe, error) {
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
 	if err != nil {
-		return false, fmt.Errorf("%s is not a valid URL: %w", target, err)
+		return probe{}, fmt.Errorf("%s is not a valid URL: %w", target, err)
 	}
+	return newProbeFromRequest(transport, req, ops), nil
+}
+
+// newProbeFromRequest builds the probe request from req, applying the ops.
+// req is owned by the probe, callers must clone requests they don't own.
+func newProbeFromRequest(transport http.RoundTripper, req *http.Request, ops []interface{}) probe {
+	target := req.URL.String()
+	var (
+		dc *dialConfig
+		cc *coalesceConfig
+		nc *negativeCacheConfig
+		hc *hedgeConfig
+		mu []RequestMutator
+		tc []TimingsCallback
+	)
 	for _, op := range ops {
-		if po, ok := op.(Preparer); ok {
-			req = po(req)
+		switch o := op.(type) {
+		case Preparer:
+			req = o(req)
+		case RequestMutator:
+			mu = append(mu, o)
+		case TimingsCallback:
+			tc = append(tc, o)
+		case DialOption:
+			if dc == nil {
+				dc = &dialConfig{}
+			}
+			o(dc)
+		case CoalesceOption:
+			if cc == nil {
+				cc = &coalesceConfig{}
+			}
+			o(cc)
+		case NegativeCacheOption:
+			if nc == nil {
+				nc = &negativeCacheConfig{}
+			}
+			o(nc)
+		case HedgeOption:
+			if hc == nil {
+				hc = &hedgeConfig{}
+			}
+			o(hc)
+		}
+	}
+	var (
+		dnsCheck *dialConfig
+		all      *allAddresses
+	)
+	if dc != nil {
+		if dc.dnsCheck {
+			dnsCheck = dc
+		}
+		if dc.expectContinue != nil {
+			req = dc.expectContinue.prepare(req)
+		}
+		if dc.allAddresses != nil {
+			all = &allAddresses{dc: dc, transport: transport}
+		}
+		transport = dc.transport(transport)
+	}
+	if cc != nil && cc.coalescer == nil {
+		cc = nil
+	}
+	if nc != nil && nc.cache == nil {
+		nc = nil
+	}
+	if hc != nil && hc.maxHedges <= 0 {
+		hc = nil
+	}
+	p := probe{target: target, req: req, transport: transport, ops: ops, coalesce: cc, negative: nc, hedge: hc, dnsCheck: dnsCheck, all: all, timings: tc}
+	if len(mu) > 0 {
+		p.mutators, p.attempts = mu, atomic.NewInt32(0)
+	}
+	return p
+}
+
+// do sends the probe and verifies the response, sharing the result of the
+// identical probe in flight if the probe is coalesced, and reusing the
+// failure of the identical probe sent recently if the probe is negatively
+// cached. The probe is hedged if asked for.
+func (p probe) do() (bool, error) {
+	if p.negative != nil {
+		if hit, err := p.negative.lookup(p); hit {
+			return false, err
 		}
 	}
+	var (
+		res probeResult
+		err error
+	)
+	if p.coalesce != nil {
+		res, err = p.coalesce.do(p)
+	} else {
+		res, err = p.sendHedged()
+	}
+	if p.negative != nil {
+		p.negative.store(p, res, err)
+	}
+	return res.ok, err
+}
 
-	resp, err := transport.RoundTrip(req)
+// sendHedged sends the probe with hedging if asked for, or once otherwise.
+func (p probe) sendHedged() (probeResult, error) {
+	if p.hedge != nil {
+		return p.hedge.send(p)
+	}
+	return p.send()
+}
+
+// send sends the probe and verifies the response, or the responses of all
+// the addresses of the target if asked for. Each attempt sends a clone
+// of the probe request, as http.RoundTripper must not modify requests but
+// may still be using them once RoundTrip returns.
+func (p probe) send() (probeResult, error) {
+	if p.all != nil {
+		return p.all.send(p)
+	}
+	if p.timings == nil {
+		return p.sendOnce(p.req.Context())
+	}
+	tt := newTimingTrace()
+	res, err := p.sendOnce(httptrace.WithClientTrace(p.req.Context(), tt.clientTrace()))
+	return res, reportTimings(p.timings, tt.timings(), err)
+}
+
+// sendOnce sends a single attempt of the probe with the given context and
+// verifies the response.
+func (p probe) sendOnce(ctx context.Context) (probeResult, error) {
+	if p.dnsCheck != nil {
+		if err := p.dnsCheck.checkDNS(ctx, p.req.URL.Hostname()); err != nil {
+			return probeResult{}, err
+		}
+	}
+	ct := &connTrace{}
+	ctx = context.WithValue(ctx, connTraceKey{}, ct)
+	ctx = httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			ct.reused.Store(info.Reused)
+			ct.got.Store(true)
+		},
+	})
+	req := p.req.Clone(context.WithValue(ctx, probeStartKey{}, time.Now()))
+	if p.req.GetBody != nil {
+		// The body of the previous attempt has been consumed.
+		body, err := p.req.GetBody()
+		if err != nil {
+			return probeResult{}, fmt.Errorf("error getting body: %w", err)
+		}
+		req.Body = body
+	}
+	if len(p.mutators) > 0 {
+		attempt := int(p.attempts.Inc())
+		for _, mutate := range p.mutators {
+			mutate(req, attempt)
+		}
+	}
+	resp, err := p.transport.RoundTrip(req)
 	if err != nil {
-		return false, fmt.Errorf("error roundtripping %s: %w", target, err)
+		return probeResult{}, fmt.Errorf("error roundtripping %s: %w", p.target, classifyRoundTripError(err))
 	}
 	defer resp.Body.Close()
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return false, fmt.Errorf("error reading body: %w", err)
+	if resp.Request == nil {
+		// Not all transports set it, but verifiers rely on it to tell HEAD probes apart.
+		resp.Request = req
 	}
+	res := probeResult{status: resp.StatusCode}
 
-	for _, op := range ops {
+	buf := bufferPool.Get().(*bytes.Buffer)
+	defer func() {
+		if buf.Cap() <= maxPooledBufferSize {
+			bufferPool.Put(buf)
+		}
+	}()
+	buf.Reset()
+	if _, err := buf.ReadFrom(resp.Body); err != nil {
+		return res, fmt.Errorf("error reading body: %w", classifyRoundTripError(err))
+	}
+
+	for _, op := range p.ops {
 		if vo, ok := op.(Verifier); ok {
-			if ok, err := vo(resp, body); err != nil || !ok {
-				return false, err
+			if ok, err := vo(resp, buf.Bytes()); err != nil {
+				return res, newResponseError(err, resp, buf.Bytes())
+			} else if !ok {
+				return res, nil
 			}
 		}
 	}
-	return true, nil
+	res.ok = true
+	return res, nil
+}
+
+// Do sends a single probe to given target, e.g. `http://revision.default.svc.cluster.local:81`.
+// Do returns whether the probe was successful or not, or there was an error probing.
+// The class of the error, e.g. ErrTimeout or ErrBadStatus, can be told with errors.Is.
+func Do(ctx context.Context, transport http.RoundTripper, target string, ops ...interface{}) (bool, error) {
+	p, err := newProbe(ctx, transport, target, ops)
+	if err != nil {
+		return false, err
+	}
+	return p.do()
+}
+
+// DoRequest is like Do, but sends a probe built from the given request rather
+// than from a target URL, for the cases the options can't cover, e.g. custom
+// trailers or protocol settings. The request is used as a template and is not
+// modified: the Preparers apply to a clone of it, and each attempt sends a
+// clone as well. A request with a body must set GetBody so that the body can
+// be sent again, as done by http.NewRequest for the common readers.
+func DoRequest(ctx context.Context, transport http.RoundTripper, req *http.Request, ops ...interface{}) (bool, error) {
+	if req.URL == nil {
+		return false, errors.New("the probe request has no URL")
+	}
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false, errors.New("the body of the probe request must be replayable, GetBody is not set")
+	}
+	return newProbeFromRequest(transport, req.Clone(ctx), ops).do()
+}
+
+// OfferOption is a way for the caller to tune how the Manager runs an async probe.
+// OfferOptions are ignored by Do.
+type OfferOption func(*offerConfig)
+
+// offerConfig accumulates the OfferOptions of a single Offer call.
+type offerConfig struct {
+	// successThreshold is the number of consecutive successful probes
+	// required for the async probe to be considered successful.
+	successThreshold int
+
+	// initialDelayJitter is the maximum random delay before the first probe.
+	initialDelayJitter time.Duration
+
+	// metadata is attached to the async probe.
+	metadata Metadata
+}
+
+// WithSuccessThreshold requires n consecutive successful probes before the
+// async probe is considered successful, like the successThreshold of a
+// Kubernetes readiness probe. Any failed probe resets the count.
+// Values lower than 1 are treated as 1.
+func WithSuccessThreshold(n int) OfferOption {
+	return func(c *offerConfig) {
+		c.successThreshold = n
+	}
+}
+
+// WithInitialDelayJitter delays the first probe by a random duration up to max, so
+// that the probes of many targets offered at once, e.g. after a configuration change
+// triggering the reconciliation of all the Ingresses, are spread over time rather
+// than hitting the gateways at the same instant.
+func WithInitialDelayJitter(max time.Duration) OfferOption {
+	return func(c *offerConfig) {
+		c.initialDelayJitter = max
+	}
+}
+
+// Metadata are the labels the callers attach to the async probes, e.g. the
+// revision or the generation of the object they check, to correlate the
+// outcomes of the probes with them without wrapping their args.
+type Metadata map[string]string
+
+// metadataKey is the context key of the Metadata of an async probe.
+type metadataKey struct{}
+
+// MetadataFromContext returns the Metadata of the async probe the context
+// is the one of, e.g. the context of the request in Preparers or transports,
+// or nil if the probe has none.
+func MetadataFromContext(ctx context.Context) Metadata {
+	md, _ := ctx.Value(metadataKey{}).(Metadata)
+	return md
+}
+
+// WithMetadata attaches md to the async probe, which gets it back in the
+// callback set with WithMetadataCallback, in the context of its requests and
+// in Snapshot. The Metadata of several WithMetadata options, e.g. the
+// defaults given to New and the ones given to Offer, are merged, the latter
+// taking precedence.
+func WithMetadata(md Metadata) OfferOption {
+	return func(c *offerConfig) {
+		merged := make(Metadata, len(c.metadata)+len(md))
+		for k, v := range c.metadata {
+			merged[k] = v
+		}
+		for k, v := range md {
+			merged[k] = v
+		}
+		c.metadata = merged
+	}
+}
+
+// newOfferConfig builds the offer configuration from the given defaults and ops,
+// the latter taking precedence.
+func newOfferConfig(defaults []OfferOption, ops []interface{}) *offerConfig {
+	c := &offerConfig{successThreshold: 1}
+	for _, o := range defaults {
+		o(c)
+	}
+	for _, op := range ops {
+		if oo, ok := op.(OfferOption); ok {
+			oo(c)
+		}
+	}
+	if c.successThreshold < 1 {
+		c.successThreshold = 1
+	}
+	return c
+}
+
+// ManagerOption is a way for the caller to configure a Manager.
+type ManagerOption func(*Manager)
+
+// ErrBudgetExhausted is reported to the callback of the async probes shed
+// because the timeout budget of the Manager was exhausted.
+var ErrBudgetExhausted = errors.New("probing timeout budget exhausted")
+
+// WithTimeoutBudget caps the sum of the timeouts of the async probes the Manager
+// runs concurrently. Probes offered while the budget is exhausted are shed: their
+// callback is invoked with ErrBudgetExhausted, so that the caller can retry later,
+// and the shed_probe_count metric is incremented. This protects the controller
+// from running an unbounded number of probes when lots of Ingresses change at once.
+// A probe is always admitted when no other one runs, even if its timeout exceeds
+// the budget, so that every target can be probed. A budget of zero or less means
+// no limit.
+func WithTimeoutBudget(budget time.Duration) ManagerOption {
+	return func(m *Manager) {
+		m.budget = budget
+	}
+}
+
+// ProbeTimeoutReason is the reason of the events recorded for probes
+// timing out, see WithEventRecorder.
+const ProbeTimeoutReason = "ProbeTimeout"
+
+// EventSubject is implemented by the args of Offer calls whose probes timing
+// out should be reported as Kubernetes events.
+type EventSubject interface {
+	// EventObject returns the object the events are recorded on, e.g. the
+	// Ingress the probe checks the readiness of.
+	EventObject() runtime.Object
+}
+
+// WithEventRecorder makes the Manager record a Warning event when an async
+// probe exhausts its timeout, on the object returned by the arg of the Offer
+// call if it implements EventSubject. This surfaces persistent probing failures
+// to operators without digging through the controller logs.
+func WithEventRecorder(recorder record.EventRecorder) ManagerOption {
+	return func(m *Manager) {
+		m.recorder = recorder
+	}
+}
+
+// WithClock makes the Manager wait on the given clock between the async
+// probes, rather than on the wall clock, so that the tests of the callers can
+// advance the time deterministically, e.g. with a fake clock, instead of
+// sleeping through the probe periods and timeouts.
+func WithClock(c clock.Clock) ManagerOption {
+	return func(m *Manager) {
+		m.clock = c
+	}
+}
+
+// WithMetadataCallback makes the Manager invoke cb rather than the Done
+// callback given to New when an async probe has finished, e.g.
+// `New(nil, transport, WithMetadataCallback(cb))`, so that the outcome of the
+// probe can be told apart with its Metadata.
+func WithMetadataCallback(cb MetadataDone) ManagerOption {
+	return func(m *Manager) {
+		m.metadataCB = cb
+	}
+}
+
+// WithProgressCallback makes the Manager invoke cb after each attempt of the
+// async probes which doesn't complete them, in addition to the Done callback
+// invoked once they are finished, e.g. so that the controllers can surface
+// the last error in the status of the objects still being probed. cb is
+// invoked from the probing goroutine and must not block.
+func WithProgressCallback(cb Progress) ManagerOption {
+	return func(m *Manager) {
+		m.progressCB = cb
+	}
+}
+
+// DedupKey returns the key the Manager deduplicates the async probes of the
+// given Offer call by: concurrent Offer calls with the same key share a single
+// probe. The keys must be comparable.
+type DedupKey func(target string, arg interface{}) interface{}
+
+// DedupByTarget deduplicates the async probes by target, e.g. so that the
+// probes of several revisions sent to the same gateway pod are run once.
+func DedupByTarget(target string, _ interface{}) interface{} {
+	return target
+}
+
+// DedupByArg deduplicates the async probes by the arg of the Offer calls,
+// which must be comparable, e.g. so that a single probe is run per Ingress
+// whatever the target it is offered for.
+func DedupByArg(_ string, arg interface{}) interface{} {
+	return arg
+}
+
+// WithDedupKey makes the Manager deduplicate the async probes by the key
+// returned by key, e.g. DedupByTarget, DedupByArg or a custom func, rather than
+// by target only. Unlike with the default deduplication, which discards
+// the Offer calls for a target already probed, the Offer calls with the key
+// of a probe in flight but a different arg join it: the callback is invoked
+// for their arg too, with the outcome of the shared probe. The probe is run
+// with the ops and timeout of the first Offer call.
+func WithDedupKey(key DedupKey) ManagerOption {
+	return func(m *Manager) {
+		m.dedupKey = key
+	}
 }
 
 // Done is a callback that is executed when the async probe has finished.
@@ -132,68 +897,546 @@ func Do(ctx context.Context, transport http.RoundTripper, target string, ops ...
 // we will coalesce concurrent Offer invocations on target.
 type Done func(arg interface{}, success bool, err error)
 
+// Progress is a callback that is executed after each attempt of an async probe
+// which doesn't complete it, see WithProgressCallback. `arg` is given by the
+// caller at the offering time, `attempt` is the 1-based number of the attempt
+// and `err` is the error it failed with, nil if it succeeded without reaching
+// the success threshold.
+type Progress func(arg interface{}, attempt int, err error)
+
+// MetadataDone is like Done, with the Metadata attached to the async probe
+// with WithMetadata, nil if none.
+type MetadataDone func(arg interface{}, md Metadata, success bool, err error)
+
+// ProbeStatus describes an async probe in flight, see Snapshot.
+type ProbeStatus struct {
+	// Target is the target of the probe.
+	Target string
+	// Metadata is the Metadata attached to the probe, nil if none.
+	Metadata Metadata
+	// Started is when the probe was offered.
+	Started time.Time
+	// Histograms are the histograms of the target, nil if the Manager
+	// doesn't record them, see WithLatencyHistograms.
+	Histograms *TargetHistograms
+}
+
+// asyncProbe is an async probe in flight.
+type asyncProbe struct {
+	status ProbeStatus
+	// arg is the arg of the Offer call which started the probe.
+	arg interface{}
+	// joined are the Offer calls sharing the probe, see WithDedupKey.
+	joined []joinedOffer
+	// cancel interrupts the probe.
+	cancel context.CancelFunc
+}
+
+// joinedOffer is an Offer call sharing the probe of a previous one.
+type joinedOffer struct {
+	arg      interface{}
+	metadata Metadata
+}
+
+// has returns whether arg is the one of an Offer call of the probe.
+func (p *asyncProbe) has(arg interface{}) bool {
+	if sameArg(p.arg, arg) {
+		return true
+	}
+	for _, j := range p.joined {
+		if sameArg(j.arg, arg) {
+			return true
+		}
+	}
+	return false
+}
+
+// sameArg returns whether a and b are equal, without panicking when they
+// aren't comparable.
+func sameArg(a, b interface{}) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	ta, tb := reflect.TypeOf(a), reflect.TypeOf(b)
+	return ta == tb && ta.Comparable() && a == b
+}
+
 // Manager manages async probes and makes sure we run concurrently only a single
 // probe for the same key.
 type Manager struct {
 	cb Done
+	// metadataCB replaces cb if set.
+	metadataCB MetadataDone
+	// progressCB is invoked after the attempts of the probes, if set.
+	progressCB Progress
 	// NB: it is paramount to use a transport that will close the connection
 	// after every request here. Otherwise the cached connections will prohibit
 	// scaling to zero, due to unsuccessful probes to the Activator.
 	transport http.RoundTripper
+	// defaults are the OfferOptions applied to every Offer call.
+	defaults []OfferOption
+	// budget is the maximum sum of the timeouts of the running probes.
+	budget time.Duration
+	// recorder records the events about probes timing out, if set.
+	recorder record.EventRecorder
+	// clock is waited on between the probes.
+	clock clock.Clock
+	// dedupKey returns the key of the probes, if set, see WithDedupKey.
+	dedupKey DedupKey
+	// histograms are the histograms of the targets, if recorded, see
+	// WithLatencyHistograms.
+	histograms *histograms
+
+	// wg tracks the goroutines probing or invoking the callbacks.
+	wg sync.WaitGroup
 
-	// mu guards keys.
-	mu   sync.Mutex
-	keys sets.String
+	// mu guards probes, spent, resumeCh and shutdown.
+	mu sync.Mutex
+	// probes are the async probes in flight, by key.
+	probes map[interface{}]*asyncProbe
+	// spent is the sum of the timeouts of the running probes.
+	spent time.Duration
+	// resumeCh is closed when the Manager is resumed, nil when not paused.
+	resumeCh chan struct{}
+	// shutdown is set once Shutdown is called.
+	shutdown bool
 }
 
+// ErrShutdown is reported to the callback of the async probes interrupted
+// by Shutdown.
+var ErrShutdown = errors.New("prober manager shut down")
+
+// errPaused interrupts the probing loops when the Manager is paused.
+var errPaused = errors.New("probing paused")
+
 // New creates a new Manager, that will invoke the given callback when
-// async probing is finished.
-func New(cb Done, transport http.RoundTripper) *Manager {
-	return &Manager{
-		keys:      sets.NewString(),
+// async probing is finished. The given ops can be ManagerOptions, configuring
+// the Manager, or OfferOptions, applied to all the Offer calls before the ones
+// given to Offer itself.
+func New(cb Done, transport http.RoundTripper, ops ...interface{}) *Manager {
+	m := &Manager{
+		probes:    make(map[interface{}]*asyncProbe),
 		cb:        cb,
 		transport: transport,
+		clock:     clock.RealClock{},
 	}
+	for _, op := range ops {
+		switch o := op.(type) {
+		case ManagerOption:
+			o(m)
+		case OfferOption:
+			m.defaults = append(m.defaults, o)
+		}
+	}
+	return m
 }
 
-// Offer executes asynchronous probe using `target` as the key.
-// If a probe with the same key already exists, Offer will return false and the
-// call is discarded. If the request is accepted, Offer returns true.
-// Otherwise Offer starts a goroutine that periodically executes
-// `Do`, until timeout is reached, the probe succeeds, or fails with an error.
+// ErrAlreadyOffered is the error of the Offer calls discarded because a probe
+// with the same key is already in flight.
+var ErrAlreadyOffered = errors.New("probe already offered")
+
+// OfferResult is the outcome of an Offer call.
+type OfferResult int
+
+const (
+	// OfferAccepted means the probe was started, or joined a probe in
+	// flight, see WithDedupKey. The callback will be invoked with the arg of
+	// the call.
+	OfferAccepted OfferResult = iota
+	// OfferCoalesced means a probe with the same key is already in flight,
+	// so the call was discarded. The callback of that probe will be invoked
+	// with the arg of the call which started it.
+	OfferCoalesced
+	// OfferQueueFull means the timeout budget of the Manager is exhausted, so
+	// the probe was shed, see WithTimeoutBudget. The callback is still invoked
+	// with ErrBudgetExhausted.
+	OfferQueueFull
+	// OfferShutdown means the Manager is shut down, so the call was discarded.
+	OfferShutdown
+)
+
+// Accepted returns whether the callback will be invoked with the results of
+// a probe for the arg of the Offer call.
+func (r OfferResult) Accepted() bool {
+	return r == OfferAccepted
+}
+
+// Err returns the error describing why the Offer call was not accepted, nil
+// if it was.
+func (r OfferResult) Err() error {
+	switch r {
+	case OfferAccepted:
+		return nil
+	case OfferCoalesced:
+		return ErrAlreadyOffered
+	case OfferQueueFull:
+		return ErrBudgetExhausted
+	case OfferShutdown:
+		return ErrShutdown
+	default:
+		return fmt.Errorf("unknown offer result %d", r)
+	}
+}
+
+// String implements fmt.Stringer.
+func (r OfferResult) String() string {
+	switch r {
+	case OfferAccepted:
+		return "Accepted"
+	case OfferCoalesced:
+		return "Coalesced"
+	case OfferQueueFull:
+		return "QueueFull"
+	case OfferShutdown:
+		return "Shutdown"
+	default:
+		return fmt.Sprintf("OfferResult(%d)", int(r))
+	}
+}
+
+// Offer executes asynchronous probe using `target` as the key, unless another
+// key is set with WithDedupKey.
+// If a probe with the same key already exists, Offer returns OfferCoalesced and
+// the call is discarded, unless it joins that probe, see WithDedupKey.
+// If the request is accepted, Offer returns OfferAccepted and starts a goroutine
+// that periodically executes `Do`, until timeout is reached, the probe succeeds,
+// or fails with an error.
 // In the end the callback is invoked with the provided `arg` and probing results.
-func (m *Manager) Offer(ctx context.Context, target string, arg interface{}, period, timeout time.Duration, ops ...interface{}) bool {
+// If the timeout budget of the Manager is exhausted, the probe is shed, Offer
+// returns OfferQueueFull and the callback is invoked with ErrBudgetExhausted
+// instead.
+// Once the Manager is shut down, Offer discards all the calls and returns
+// OfferShutdown.
+func (m *Manager) Offer(ctx context.Context, target string, arg interface{}, period, timeout time.Duration, ops ...interface{}) OfferResult {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.shutdown {
+		return OfferShutdown
+	}
+	var key interface{} = target
+	if m.dedupKey != nil {
+		key = m.dedupKey(target, arg)
+	}
+	cfg := newOfferConfig(m.defaults, ops)
+	if p, ok := m.probes[key]; ok {
+		if m.dedupKey == nil || p.has(arg) {
+			return OfferCoalesced
+		}
+		p.joined = append(p.joined, joinedOffer{arg: arg, metadata: cfg.metadata})
+		return OfferAccepted
+	}
+	if m.budget > 0 && m.spent > 0 && m.spent+timeout > m.budget {
+		logging.FromContext(ctx).Warnw("Shedding probe, timeout budget exhausted",
+			zap.String("target", target), zap.Duration("budget", m.budget), zap.Duration("spent", m.spent))
+		metrics.Record(ctx, shedProbesM.M(1))
+		// Don't invoke the callback under the lock, as it may offer again.
+		m.wg.Add(1)
+		go func() {
+			defer m.wg.Done()
+			m.done(arg, cfg.metadata, false, ErrBudgetExhausted)
+		}()
+		return OfferQueueFull
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	m.probes[key] = &asyncProbe{
+		status: ProbeStatus{Target: target, Metadata: cfg.metadata, Started: m.clock.Now()},
+		arg:    arg,
+		cancel: cancel,
+	}
+	m.spent += timeout
+	m.wg.Add(1)
+	m.doAsync(ctx, key, target, arg, cfg, period, timeout, ops...)
+	return OfferAccepted
+}
+
+// Pending returns whether a probe of target is in flight, so that an Offer
+// call for it would be coalesced, unless another key is set with WithDedupKey.
+func (m *Manager) Pending(target string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, p := range m.probes {
+		if p.status.Target == target {
+			return true
+		}
+	}
+	return false
+}
+
+// Shutdown stops the Manager from accepting Offer calls, and waits for the
+// async probes in flight to complete, or ctx to be done. In the latter case,
+// the probes still in flight are interrupted, their callbacks are invoked
+// with ErrShutdown, and ctx.Err() is returned once they are stopped. The
+// callbacks of the probes are never invoked after Shutdown returns.
+func (m *Manager) Shutdown(ctx context.Context) error {
+	m.mu.Lock()
+	m.shutdown = true
+	m.mu.Unlock()
+
+	finished := make(chan struct{})
+	go func() {
+		m.wg.Wait()
+		close(finished)
+	}()
+	select {
+	case <-finished:
+		return nil
+	case <-ctx.Done():
+	}
+
+	m.mu.Lock()
+	probes := m.probes
+	m.probes = make(map[interface{}]*asyncProbe)
+	m.spent = 0
+	m.mu.Unlock()
+	for _, p := range probes {
+		p.cancel()
+		m.done(p.arg, p.status.Metadata, false, ErrShutdown)
+		for _, j := range p.joined {
+			m.done(j.arg, j.metadata, false, ErrShutdown)
+		}
+	}
+	<-finished
+	return ctx.Err()
+}
+
+// Snapshot returns the async probes in flight, sorted by target, e.g. to
+// expose them on a debug endpoint.
+func (m *Manager) Snapshot() []ProbeStatus {
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	if m.keys.Has(target) {
-		return false
+	snapshot := make([]ProbeStatus, 0, len(m.probes))
+	for _, p := range m.probes {
+		ps := p.status
+		ps.Histograms = m.Histograms(ps.Target)
+		snapshot = append(snapshot, ps)
+	}
+	sort.Slice(snapshot, func(i, j int) bool {
+		return snapshot[i].Target < snapshot[j].Target
+	})
+	return snapshot
+}
+
+// done invokes the callback of the Manager.
+func (m *Manager) done(arg interface{}, md Metadata, success bool, err error) {
+	if m.metadataCB != nil {
+		m.metadataCB(arg, md, success, err)
+	} else {
+		m.cb(arg, success, err)
+	}
+}
+
+// progress invokes the progress callback of the Manager, if any, for the Offer
+// calls sharing the probe with the given key.
+func (m *Manager) progress(key, arg interface{}, attempt int, err error) {
+	if m.progressCB == nil {
+		return
+	}
+	m.mu.Lock()
+	var joined []joinedOffer
+	if p, ok := m.probes[key]; ok {
+		joined = append(joined, p.joined...)
+	}
+	m.mu.Unlock()
+	m.progressCB(arg, attempt, err)
+	for _, j := range joined {
+		m.progressCB(j.arg, attempt, err)
+	}
+}
+
+// Pause temporarily stops all probing, e.g. while the gateways are restarting
+// or during a leader election handover. Offer keeps accepting probes while the
+// Manager is paused, but they are only sent once it is resumed. Probes interrupted
+// by Pause are restarted on Resume, with their full timeout.
+func (m *Manager) Pause() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.resumeCh == nil {
+		m.resumeCh = make(chan struct{})
+	}
+}
+
+// Resume restarts the probing stopped by Pause.
+func (m *Manager) Resume() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.resumeCh != nil {
+		close(m.resumeCh)
+		m.resumeCh = nil
+	}
+}
+
+// Paused returns whether the Manager is paused.
+func (m *Manager) Paused() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.resumeCh != nil
+}
+
+// waitResumed blocks while the Manager is paused, or until ctx is done.
+func (m *Manager) waitResumed(ctx context.Context) error {
+	m.mu.Lock()
+	ch := m.resumeCh
+	m.mu.Unlock()
+	if ch == nil {
+		return nil
+	}
+	select {
+	case <-ch:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
 	}
-	m.keys.Insert(target)
-	m.doAsync(ctx, target, arg, period, timeout, ops...)
-	return true
 }
 
 // doAsync starts a go routine that probes the target with given period.
-func (m *Manager) doAsync(ctx context.Context, target string, arg interface{}, period, timeout time.Duration, ops ...interface{}) {
+func (m *Manager) doAsync(ctx context.Context, key interface{}, target string, arg interface{}, cfg *offerConfig, period, timeout time.Duration, ops ...interface{}) {
 	logger := logging.FromContext(ctx)
+	if cfg.metadata != nil {
+		ctx = context.WithValue(ctx, metadataKey{}, cfg.metadata)
+	}
+	var (
+		// inErr is the error of the last probe.
+		inErr error
+		// attempts is the number of probes sent.
+		attempts int
+	)
+	// done releases the key before invoking the callbacks, so that the
+	// callbacks can offer it again.
+	done := func(success bool, err error) {
+		m.mu.Lock()
+		p, ok := m.probes[key]
+		if !ok {
+			// Shutdown interrupted the probe and invoked the callbacks.
+			m.mu.Unlock()
+			return
+		}
+		defer p.cancel()
+		joined := p.joined
+		delete(m.probes, key)
+		m.spent -= timeout
+		m.mu.Unlock()
+		m.observeAttempts(ctx, target, attempts)
+		timedOut := errors.Is(err, wait.ErrWaitTimeout)
+		if timedOut {
+			m.recordTimeout(arg, target, timeout, inErr)
+		}
+		m.done(arg, cfg.metadata, success, err)
+		for _, j := range joined {
+			if timedOut {
+				m.recordTimeout(j.arg, target, timeout, inErr)
+			}
+			m.done(j.arg, j.metadata, success, err)
+		}
+	}
 	go func() {
-		defer func() {
-			m.mu.Lock()
-			defer m.mu.Unlock()
-			m.keys.Delete(target)
-		}()
+		defer m.wg.Done()
 		var (
-			result bool
-			inErr  error
+			result    bool
+			successes int
 		)
-		err := wait.PollImmediate(period, timeout, func() (bool, error) {
-			result, inErr = Do(ctx, m.transport, target, ops...)
-			// Do not return error, which is from verifierError, as retry is expected until timeout.
-			return result, nil
-		})
+		// Build the probe once and resend it, rather than rebuilding the
+		// request and the transport on every attempt.
+		p, err := newProbe(ctx, m.transport, target, ops)
+		if err != nil {
+			logger.Errorw("Unable to create probe", zap.Error(err))
+			done(false, err)
+			return
+		}
+		if cfg.initialDelayJitter > 0 {
+			select {
+			case <-m.clock.After(time.Duration(randInt63n(int64(cfg.initialDelayJitter)))):
+			case <-ctx.Done():
+				done(false, ctx.Err())
+				return
+			}
+		}
+		for {
+			if err := m.waitResumed(ctx); err != nil {
+				done(false, err)
+				return
+			}
+			successes = 0
+			err = m.poll(ctx, period, timeout, func() (bool, error) {
+				if m.Paused() {
+					return false, errPaused
+				}
+				if err := ctx.Err(); err != nil {
+					// Don't keep failing until the timeout.
+					return false, err
+				}
+				start := m.clock.Now()
+				result, inErr = p.do()
+				m.observeLatency(ctx, target, m.clock.Since(start))
+				attempts++
+				if !result {
+					successes = 0
+					m.progress(key, arg, attempts, inErr)
+					// Do not return error, which is from verifierError, as retry is expected until timeout.
+					return false, nil
+				}
+				successes++
+				if successes < cfg.successThreshold {
+					m.progress(key, arg, attempts, nil)
+					return false, nil
+				}
+				return true, nil
+			})
+			if !errors.Is(err, errPaused) {
+				break
+			}
+		}
 		if inErr != nil {
 			logger.Errorw("Unable to read sockstat", zap.Error(inErr))
 		}
-		m.cb(arg, result, err)
+		// The last probe may have succeeded without reaching the threshold.
+		done(result && err == nil, err)
 	}()
 }
+
+// poll runs condition immediately and then every period, until it returns
+// true or an error, timeout is reached or ctx is done, like wait.PollImmediate
+// but waiting on the clock of the Manager. Only one channel of the clock is
+// waited on at a time, and none while condition runs.
+func (m *Manager) poll(ctx context.Context, period, timeout time.Duration, condition wait.ConditionFunc) error {
+	start := m.clock.Now()
+	for {
+		if ok, err := condition(); err != nil || ok {
+			return err
+		}
+		remaining := timeout - m.clock.Since(start)
+		if remaining <= 0 {
+			return wait.ErrWaitTimeout
+		}
+		d, timedOut := period, false
+		if period >= remaining {
+			d, timedOut = remaining, true
+		}
+		select {
+		case <-m.clock.After(d):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		if timedOut {
+			return wait.ErrWaitTimeout
+		}
+	}
+}
+
+// recordTimeout records an event about the probe of target timing out, if
+// the Manager has a recorder and arg is an EventSubject.
+func (m *Manager) recordTimeout(arg interface{}, target string, timeout time.Duration, lastErr error) {
+	subject, ok := arg.(EventSubject)
+	if m.recorder == nil || !ok {
+		return
+	}
+	obj := subject.EventObject()
+	if obj == nil {
+		return
+	}
+	if lastErr == nil {
+		// The last probes succeeded, but not enough times in a row.
+		lastErr = errors.New("success threshold not reached")
+	}
+	m.recorder.Eventf(obj, corev1.EventTypeWarning, ProbeTimeoutReason,
+		"Probing %s did not succeed within %v: %v", target, timeout, lastErr)
+}
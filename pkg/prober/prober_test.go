@@ -17,16 +17,42 @@ limitations under the License.
 package prober
 
 import (
+	"bufio"
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	cryptorand "crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
 	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
+	"math/rand"
 	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/google/go-cmp/cmp"
+	"go.opencensus.io/stats/view"
+	"go.uber.org/atomic"
+	"google.golang.org/grpc/codes"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/record"
 	"knative.dev/networking/pkg/http/header"
+	"knative.dev/pkg/metrics"
 	"knative.dev/pkg/network"
 )
 
@@ -116,6 +142,21 @@ func TestBadURL(t *testing.T) {
 	t.Log("For the curious the error was:", err)
 }
 
+func TestDoAsyncBadURL(t *testing.T) {
+	errCh := make(chan error, 1)
+	m := New(func(arg interface{}, success bool, err error) {
+		if success {
+			t.Error("result was true")
+		}
+		errCh <- err
+	}, network.NewProberTransport())
+	// The timeout is long enough for the test to time out if the probe were retried.
+	m.Offer(context.Background(), ":foo", nil, probeInterval, time.Hour, ExpectsStatusCodes([]int{http.StatusOK}))
+	if err := <-errCh; err == nil {
+		t.Error("Callback was not given an error")
+	}
+}
+
 func TestDoAsync(t *testing.T) {
 	// This replicates the TestDo.
 	ts := httptest.NewServer(http.HandlerFunc(probeServeFunc))
@@ -211,6 +252,256 @@ func TestDoAsyncRepeat(t *testing.T) {
 	}
 }
 
+// flakyProber fails the probes whose (1-based) index is in fail.
+type flakyProber struct {
+	calls atomic.Int32
+	fail  sets.Int
+}
+
+func (f *flakyProber) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if f.fail.Has(int(f.calls.Inc())) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+}
+
+func TestDoAsyncSuccessThreshold(t *testing.T) {
+	tests := []struct {
+		name      string
+		threshold int
+		fail      sets.Int
+		wantCalls int32
+	}{{
+		name:      "default threshold",
+		fail:      sets.NewInt(1),
+		wantCalls: 2,
+	}, {
+		name:      "threshold below one",
+		threshold: -1,
+		wantCalls: 1,
+	}, {
+		name:      "consecutive successes",
+		threshold: 3,
+		wantCalls: 3,
+	}, {
+		name:      "failure resets the count",
+		threshold: 3,
+		fail:      sets.NewInt(2),
+		wantCalls: 5,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := &flakyProber{fail: test.fail}
+			ts := httptest.NewServer(p)
+			defer ts.Close()
+
+			wch := make(chan interface{})
+			cb := func(arg interface{}, done bool, err error) {
+				if !done {
+					t.Error("done was false")
+				}
+				if err != nil {
+					t.Error("Unexpected error =", err)
+				}
+				close(wch)
+			}
+			m := New(cb, network.NewProberTransport())
+			m.Offer(context.Background(), ts.URL, 42, probeInterval, probeTimeout,
+				ExpectsStatusCodes([]int{http.StatusOK}), WithSuccessThreshold(test.threshold))
+			<-wch
+			if got := p.calls.Load(); got != test.wantCalls {
+				t.Errorf("Probe invocation count = %d, want: %d", got, test.wantCalls)
+			}
+		})
+	}
+}
+
+func TestDoAsyncProgress(t *testing.T) {
+	p := &flakyProber{fail: sets.NewInt(1, 2)}
+	ts := httptest.NewServer(p)
+	defer ts.Close()
+
+	type attempt struct {
+		arg    interface{}
+		number int
+		failed bool
+	}
+	var attempts []attempt
+	wch := make(chan interface{})
+	m := New(func(arg interface{}, done bool, err error) {
+		if !done || err != nil {
+			t.Errorf("Callback = %v, %v, want: true, nil", done, err)
+		}
+		close(wch)
+	}, network.NewProberTransport(), WithProgressCallback(func(arg interface{}, number int, err error) {
+		attempts = append(attempts, attempt{arg: arg, number: number, failed: err != nil})
+	}))
+	m.Offer(context.Background(), ts.URL, 42, probeInterval, probeTimeout,
+		ExpectsStatusCodes([]int{http.StatusOK}), WithSuccessThreshold(2))
+	<-wch
+
+	// The last attempt completes the probe, and is only reported to the
+	// Done callback.
+	want := []attempt{{42, 1, true}, {42, 2, true}, {42, 3, false}}
+	if !cmp.Equal(attempts, want, cmp.AllowUnexported(attempt{})) {
+		t.Error("Progress (-want, +got) =", cmp.Diff(want, attempts, cmp.AllowUnexported(attempt{})))
+	}
+}
+
+func TestWithRequestMutator(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		attempts []string
+	)
+	// taken returns the attempts received so far, and forgets them.
+	taken := func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		got := attempts
+		attempts = nil
+		return got
+	}
+	p := &flakyProber{fail: sets.NewInt(1, 2)}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		attempts = append(attempts, r.Header.Get("X-Probe-Attempt"))
+		mu.Unlock()
+		p.ServeHTTP(w, r)
+	}))
+	defer ts.Close()
+
+	mutator := WithRequestMutator(func(r *http.Request, attempt int) {
+		r.Header.Add("X-Probe-Attempt", strconv.Itoa(attempt))
+	})
+	wch := make(chan interface{})
+	m := New(func(arg interface{}, done bool, err error) {
+		if !done || err != nil {
+			t.Errorf("Callback = %v, %v, want: true, nil", done, err)
+		}
+		close(wch)
+	}, network.NewProberTransport())
+	m.Offer(context.Background(), ts.URL, 42, probeInterval, probeTimeout,
+		mutator, ExpectsStatusCodes([]int{http.StatusOK}))
+	<-wch
+
+	// The attempts are numbered across the retries, and the mutations of
+	// an attempt don't leak into the next ones.
+	if got, want := taken(), []string{"1", "2", "3"}; !cmp.Equal(got, want) {
+		t.Error("Attempts (-want, +got) =", cmp.Diff(want, got))
+	}
+
+	// Each Do call is a new probe.
+	if _, err := Do(context.Background(), network.AutoTransport, ts.URL, mutator); err != nil {
+		t.Error("Do() =", err)
+	}
+	if got, want := taken(), []string{"1"}; !cmp.Equal(got, want) {
+		t.Error("Attempts (-want, +got) =", cmp.Diff(want, got))
+	}
+}
+
+func TestDoAsyncInitialDelayJitter(t *testing.T) {
+	const jitter = 100 * time.Millisecond
+	var drawn atomic.Int64
+	randInt63n = func(n int64) int64 {
+		drawn.Store(n)
+		return n - 1
+	}
+	t.Cleanup(func() { randInt63n = rand.Int63n })
+
+	tests := []struct {
+		name     string
+		defaults []interface{}
+		ops      []interface{}
+	}{{
+		name: "offer option",
+		ops:  []interface{}{WithInitialDelayJitter(jitter)},
+	}, {
+		name:     "manager default",
+		defaults: []interface{}{WithInitialDelayJitter(jitter)},
+	}, {
+		name:     "offer option overrides manager default",
+		defaults: []interface{}{WithInitialDelayJitter(time.Hour)},
+		ops:      []interface{}{WithInitialDelayJitter(jitter)},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			firstProbe := make(chan time.Time, 10)
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				firstProbe <- time.Now()
+			}))
+			defer ts.Close()
+
+			wch := make(chan interface{})
+			m := New(func(arg interface{}, done bool, err error) {
+				if !done || err != nil {
+					t.Errorf("Callback = %v, %v, want: true, nil", done, err)
+				}
+				close(wch)
+			}, network.NewProberTransport(), test.defaults...)
+
+			start := time.Now()
+			m.Offer(context.Background(), ts.URL, 42, probeInterval, time.Second,
+				append(test.ops, ExpectsStatusCodes([]int{http.StatusOK}))...)
+			<-wch
+			if got := (<-firstProbe).Sub(start); got < jitter-time.Millisecond {
+				t.Errorf("First probe after %v, want at least: %v", got, jitter-time.Millisecond)
+			}
+			if got := drawn.Load(); got != int64(jitter) {
+				t.Errorf("Jitter drawn in [0, %v), want: [0, %v)", time.Duration(got), jitter)
+			}
+		})
+	}
+}
+
+func TestDoAsyncInitialDelayJitterCancelled(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("Unexpected probe")
+	}))
+	defer ts.Close()
+
+	errCh := make(chan error, 1)
+	m := New(func(arg interface{}, done bool, err error) {
+		if done {
+			t.Error("done was true")
+		}
+		errCh <- err
+	}, network.NewProberTransport(), WithInitialDelayJitter(time.Hour))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	m.Offer(ctx, ts.URL, 42, probeInterval, time.Hour, ExpectsStatusCodes([]int{http.StatusOK}))
+	cancel()
+	if err := <-errCh; !errors.Is(err, context.Canceled) {
+		t.Errorf("Callback error = %v, want: %v", err, context.Canceled)
+	}
+}
+
+func TestDoAsyncSuccessThresholdTimeout(t *testing.T) {
+	// Every other probe fails, so the threshold is never reached.
+	var calls atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Inc()%2 == 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer ts.Close()
+
+	wch := make(chan interface{})
+	cb := func(arg interface{}, done bool, err error) {
+		if done {
+			t.Error("done was true")
+		}
+		if !errors.Is(err, wait.ErrWaitTimeout) {
+			t.Error("Unexpected error =", err)
+		}
+		close(wch)
+	}
+	m := New(cb, network.NewProberTransport())
+	m.Offer(context.Background(), ts.URL, 42, probeInterval, probeTimeout,
+		ExpectsStatusCodes([]int{http.StatusOK}), WithSuccessThreshold(2))
+	<-wch
+}
+
 func TestDoAsyncTimeout(t *testing.T) {
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusNotFound)
@@ -234,149 +525,1415 @@ func TestDoAsyncTimeout(t *testing.T) {
 	<-wch
 }
 
-func TestAsyncMultiple(t *testing.T) {
-	ts := httptest.NewServer(http.HandlerFunc(probeServeFunc))
+func TestOfferTimeoutBudget(t *testing.T) {
+	metrics.InitForTesting()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
 	defer ts.Close()
 
-	wch := make(chan interface{})
-	defer close(wch)
-	cb := func(arg interface{}, done bool, err error) {
-		<-wch
-		wch <- 2006
+	errs := make(chan error, 3)
+	m := New(func(arg interface{}, done bool, err error) {
+		if done {
+			t.Errorf("Probe %v succeeded unexpectedly", arg)
+		}
+		errs <- err
+	}, network.NewProberTransport(), WithTimeoutBudget(3*probeTimeout/2))
+
+	shedBefore := shedProbeCount(t)
+	if got := m.Offer(context.Background(), ts.URL, 1, probeInterval, probeTimeout, ExpectsStatusCodes([]int{http.StatusOK})); got != OfferAccepted {
+		t.Fatalf("First Offer() = %v, want: %v", got, OfferAccepted)
 	}
-	m := New(cb, network.NewProberTransport())
-	if !m.Offer(context.Background(), ts.URL, 1984, probeInterval, probeTimeout, ExpectsStatusCodes([]int{http.StatusOK})) {
-		t.Error("First call to offer returned false")
+	// The second probe doesn't fit in the budget.
+	if got := m.Offer(context.Background(), ts.URL+"/other", 2, probeInterval, probeTimeout, ExpectsStatusCodes([]int{http.StatusOK})); got != OfferQueueFull {
+		t.Fatalf("Second Offer() = %v, want: %v", got, OfferQueueFull)
 	}
-	if m.Offer(context.Background(), ts.URL, 1982, probeInterval, probeTimeout, ExpectsStatusCodes([]int{http.StatusOK})) {
-		t.Error("Second call to offer returned true")
+	if err := <-errs; !errors.Is(err, ErrBudgetExhausted) {
+		t.Errorf("Shed probe error = %v, want: %v", err, ErrBudgetExhausted)
 	}
-	if got, want := m.len(), 1; got != want {
-		t.Errorf("Number of queued items = %d, want: %d", got, want)
+	if got, want := shedProbeCount(t), shedBefore+1; got != want {
+		t.Errorf("shed_probe_count = %d, want: %d", got, want)
+	}
+	if err := <-errs; !errors.Is(err, wait.ErrWaitTimeout) {
+		t.Errorf("First probe error = %v, want: %v", err, wait.ErrWaitTimeout)
 	}
-	// Make sure we terminate the first probe.
-	wch <- 2009
-	<-wch
 
-	wait.PollImmediate(probeInterval, probeTimeout, func() (bool, error) {
-		return m.len() == 0, nil
-	})
-	if got, want := m.len(), 0; got != want {
-		t.Errorf("Number of queued items = %d, want: %d", got, want)
+	// The budget is given back once the first probe finished.
+	if err := wait.PollImmediate(probeInterval, time.Second, func() (bool, error) {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		return m.spent == 0, nil
+	}); err != nil {
+		t.Fatal("Budget was not given back:", err)
+	}
+	m.Offer(context.Background(), ts.URL+"/other", 3, probeInterval, probeTimeout, ExpectsStatusCodes([]int{http.StatusOK}))
+	if err := <-errs; !errors.Is(err, wait.ErrWaitTimeout) {
+		t.Errorf("Third probe error = %v, want: %v", err, wait.ErrWaitTimeout)
+	}
+
+	if err := wait.PollImmediate(probeInterval, time.Second, func() (bool, error) {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		return m.spent == 0, nil
+	}); err != nil {
+		t.Fatal("Budget was not given back:", err)
+	}
+
+	// A probe whose timeout exceeds the budget runs when no other one does.
+	if got := m.Offer(context.Background(), ts.URL, 4, probeInterval, 2*probeTimeout, ExpectsStatusCodes([]int{http.StatusOK})); got != OfferAccepted {
+		t.Fatalf("Offer() exceeding the budget = %v, want: %v", got, OfferAccepted)
+	}
+	if err := <-errs; !errors.Is(err, wait.ErrWaitTimeout) {
+		t.Errorf("Probe exceeding the budget error = %v, want: %v", err, wait.ErrWaitTimeout)
 	}
 }
 
-func TestWithPathOption(t *testing.T) {
-	const path = "/correct/probe/path/"
+func TestDoAsyncMetadata(t *testing.T) {
+	release := make(chan struct{})
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		t.Logf("Got: %s, Want: %s\n", r.URL.Path, path)
-		if r.URL.Path != path {
-			w.WriteHeader(http.StatusNotFound)
-		}
+		<-release
 	}))
 	defer ts.Close()
 
-	tests := []struct {
-		name    string
-		options []interface{}
-	}{{
-		name:    "no path",
-		options: []interface{}{ExpectsStatusCodes([]int{http.StatusNotFound})},
-	}, {
-		name:    "expected path",
-		options: []interface{}{WithPath(path), ExpectsStatusCodes([]int{http.StatusOK})},
-	}, {
-		name:    "wrong path",
-		options: []interface{}{WithPath("/wrong/path"), ExpectsStatusCodes([]int{http.StatusNotFound})},
-	}}
+	type outcome struct {
+		arg interface{}
+		md  Metadata
+		err error
+	}
+	outcomes := make(chan outcome, 2)
+	m := New(nil, network.NewProberTransport(),
+		WithMetadataCallback(func(arg interface{}, md Metadata, success bool, err error) {
+			outcomes <- outcome{arg: arg, md: md, err: err}
+		}),
+		WithMetadata(Metadata{"component": "test", "revision": "default"}),
+		WithTimeoutBudget(3*time.Second/2))
 
-	for _, test := range tests {
-		t.Run(test.name, func(t *testing.T) {
-			if ok, _ := Do(context.Background(), network.AutoTransport, ts.URL, test.options...); !ok {
-				t.Error("Unexpected probe failure")
-			}
-		})
+	prepared := make(chan Metadata, 1)
+	if !m.Offer(context.Background(), ts.URL, "arg", probeInterval, time.Second,
+		WithMetadata(Metadata{"revision": "rev-00001"}),
+		WithMetadata(Metadata{"generation": "2"}),
+		Preparer(func(r *http.Request) *http.Request {
+			prepared <- MetadataFromContext(r.Context())
+			return r
+		})).Accepted() {
+		t.Fatal("Offer() not accepted")
+	}
+	want := Metadata{"component": "test", "revision": "rev-00001", "generation": "2"}
+	if got := <-prepared; !cmp.Equal(got, want) {
+		t.Error("MetadataFromContext (-want, +got) =", cmp.Diff(want, got))
+	}
+
+	snapshot := m.Snapshot()
+	if len(snapshot) != 1 || snapshot[0].Target != ts.URL || !cmp.Equal(snapshot[0].Metadata, want) || snapshot[0].Started.IsZero() {
+		t.Errorf("Snapshot() = %v, want the probe of %s with metadata %v", snapshot, ts.URL, want)
+	}
+
+	// Shed probes get their metadata as well.
+	m.Offer(context.Background(), ts.URL+"/shed", "shed", probeInterval, time.Second,
+		WithMetadata(Metadata{"revision": "rev-00002"}))
+	got := <-outcomes
+	wantShed := Metadata{"component": "test", "revision": "rev-00002"}
+	if got.arg != "shed" || !errors.Is(got.err, ErrBudgetExhausted) || !cmp.Equal(got.md, wantShed) {
+		t.Errorf("Callback = %v, %v, %v, want: shed, %v, %v", got.arg, got.md, got.err, wantShed, ErrBudgetExhausted)
+	}
+
+	close(release)
+	got = <-outcomes
+	if got.arg != "arg" || got.err != nil || !cmp.Equal(got.md, want) {
+		t.Errorf("Callback = %v, %v, %v, want: arg, %v, nil", got.arg, got.md, got.err, want)
+	}
+	if snapshot := m.Snapshot(); len(snapshot) != 0 {
+		t.Errorf("Snapshot() = %v, want none", snapshot)
 	}
 }
 
-func TestWithHostOption(t *testing.T) {
-	host := "foobar.com"
+// eventSubject is an Offer arg whose probes timing out are reported as events.
+type eventSubject struct {
+	obj runtime.Object
+}
+
+func (s eventSubject) EventObject() runtime.Object {
+	return s.obj
+}
+
+func TestDoAsyncTimeoutEvent(t *testing.T) {
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		t.Logf("Want: %s, Got: %s\n", host, r.Host)
-		if r.Host != host {
-			w.WriteHeader(http.StatusNotFound)
-		}
+		w.WriteHeader(http.StatusNotFound)
 	}))
 	defer ts.Close()
 
 	tests := []struct {
-		name    string
-		options []interface{}
+		name       string
+		arg        interface{}
+		wantEvents int
 	}{{
-		name:    "no hosts",
-		options: []interface{}{ExpectsStatusCodes([]int{http.StatusNotFound})},
-	}, {
-		name:    "expected host",
-		options: []interface{}{WithHost(host), ExpectsStatusCodes([]int{http.StatusOK})},
+		name:       "event subject",
+		arg:        eventSubject{obj: &corev1.Pod{}},
+		wantEvents: 1,
 	}, {
-		name:    "wrong host",
-		options: []interface{}{WithHost("nope.com"), ExpectsStatusCodes([]int{http.StatusNotFound})},
+		name: "other arg",
+		arg:  42,
 	}}
 
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			if ok, _ := Do(context.Background(), network.AutoTransport, ts.URL, test.options...); !ok {
-				t.Error("Unexpected probe result")
+			recorder := record.NewFakeRecorder(10)
+			wch := make(chan interface{})
+			m := New(func(arg interface{}, done bool, err error) {
+				close(wch)
+			}, network.NewProberTransport(), WithEventRecorder(recorder))
+			m.Offer(context.Background(), ts.URL, test.arg, probeInterval, probeTimeout, ExpectsStatusCodes([]int{http.StatusOK}))
+			<-wch
+
+			close(recorder.Events)
+			var events []string
+			for e := range recorder.Events {
+				events = append(events, e)
+			}
+			if len(events) != test.wantEvents {
+				t.Fatalf("Got events %v, want %d events", events, test.wantEvents)
+			}
+			for _, e := range events {
+				if want := corev1.EventTypeWarning + " " + ProbeTimeoutReason + " Probing " + ts.URL; !strings.HasPrefix(e, want) {
+					t.Errorf("Event = %q, want prefix: %q", e, want)
+				}
+				if want := "unexpected status code"; !strings.Contains(e, want) {
+					t.Errorf("Event = %q, want it to contain: %q", e, want)
+				}
 			}
 		})
 	}
 }
 
-func TestExpectsHeaderOption(t *testing.T) {
+// shedProbeCount returns the number of shed probes recorded so far.
+func shedProbeCount(t *testing.T) int64 {
+	t.Helper()
+	rows, err := view.RetrieveData(shedProbesM.Name())
+	if err != nil {
+		t.Fatal("Failed to retrieve shed_probe_count:", err)
+	}
+	if len(rows) == 0 {
+		return 0
+	}
+	return rows[0].Data.(*view.CountData).Value
+}
+
+func TestPauseResume(t *testing.T) {
+	var requests atomic.Int32
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Foo", "Bar")
+		requests.Inc()
 	}))
 	defer ts.Close()
 
-	tests := []struct {
-		name    string
-		options []interface{}
-		success bool
-		expErr  bool
-	}{{
-		name:    "header is present",
-		options: []interface{}{ExpectsHeader("Foo", "Bar"), ExpectsStatusCodes([]int{http.StatusOK})},
-		success: true,
-	}, {
-		name:    "header is absent",
-		options: []interface{}{ExpectsHeader("Baz", "Nope"), ExpectsStatusCodes([]int{http.StatusOK})},
-		success: false,
-		expErr:  true,
-	}, {
-		name:    "header value doesn't match",
-		options: []interface{}{ExpectsHeader("Foo", "Baz"), ExpectsStatusCodes([]int{http.StatusOK})},
-		success: false,
-		expErr:  true,
-	}}
+	wch := make(chan error, 1)
+	m := New(func(arg interface{}, done bool, err error) {
+		if !done {
+			t.Errorf("Callback = %v, %v, want: true", done, err)
+		}
+		wch <- err
+	}, network.NewProberTransport())
 
-	for _, test := range tests {
-		t.Run(test.name, func(t *testing.T) {
-			ok, err := Do(context.Background(), network.AutoTransport, ts.URL, test.options...)
-			if ok != test.success {
-				t.Errorf("unexpected probe result: want: %v, got: %v", test.success, ok)
-			}
-			if err != nil && !test.expErr {
-				t.Errorf("Do() = %v, no error expected", err)
-			}
-			if err == nil && test.expErr {
-				t.Errorf("Do() = nil, expected an error")
-			}
-		})
+	m.Pause()
+	if !m.Paused() {
+		t.Error("Paused() = false after Pause()")
+	}
+	if !m.Offer(context.Background(), ts.URL, 42, probeInterval, probeTimeout, ExpectsStatusCodes([]int{http.StatusOK})).Accepted() {
+		t.Fatal("Offer() not accepted while paused")
+	}
+	// Stay paused longer than the timeout, the probe must not time out.
+	time.Sleep(2 * probeTimeout)
+	if got := requests.Load(); got != 0 {
+		t.Errorf("Got %d requests while paused, want: 0", got)
+	}
+	select {
+	case err := <-wch:
+		t.Fatal("Callback invoked while paused:", err)
+	default:
+	}
+
+	m.Resume()
+	if m.Paused() {
+		t.Error("Paused() = true after Resume()")
+	}
+	if err := <-wch; err != nil {
+		t.Error("Unexpected error =", err)
+	}
+	if got := requests.Load(); got == 0 {
+		t.Error("No request after resuming")
 	}
 }
 
-func (m *Manager) len() int {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-	return m.keys.Len()
+func TestPauseWhileProbing(t *testing.T) {
+	var (
+		requests atomic.Int32
+		ready    atomic.Bool
+	)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Inc()
+		if !ready.Load() {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	wch := make(chan error, 1)
+	m := New(func(arg interface{}, done bool, err error) {
+		if !done {
+			t.Errorf("Callback = %v, %v, want: true", done, err)
+		}
+		wch <- err
+	}, network.NewProberTransport())
+	m.Offer(context.Background(), ts.URL, 42, probeInterval, probeTimeout, ExpectsStatusCodes([]int{http.StatusOK}))
+
+	if err := wait.PollImmediate(probeInterval, probeTimeout, func() (bool, error) {
+		return requests.Load() > 0, nil
+	}); err != nil {
+		t.Fatal("No probe was sent:", err)
+	}
+	m.Pause()
+	// Let an in-flight probe land.
+	time.Sleep(probeInterval)
+	paused := requests.Load()
+	time.Sleep(2 * probeTimeout)
+	if got := requests.Load(); got != paused {
+		t.Errorf("Got %d requests while paused, want: 0", got-paused)
+	}
+
+	ready.Store(true)
+	m.Resume()
+	if err := <-wch; err != nil {
+		t.Error("Unexpected error =", err)
+	}
+}
+
+func TestPausedOfferCancelled(t *testing.T) {
+	wch := make(chan error, 1)
+	m := New(func(arg interface{}, done bool, err error) {
+		wch <- err
+	}, network.NewProberTransport())
+	m.Pause()
+	defer m.Resume()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	m.Offer(ctx, "http://example.com", 42, probeInterval, probeTimeout)
+	cancel()
+	if err := <-wch; !errors.Is(err, context.Canceled) {
+		t.Errorf("Callback error = %v, want: %v", err, context.Canceled)
+	}
+}
+
+func TestShutdownDrains(t *testing.T) {
+	p := &flakyProber{fail: sets.NewInt(1, 2)}
+	ts := httptest.NewServer(p)
+	defer ts.Close()
+
+	var calls atomic.Int32
+	m := New(func(arg interface{}, done bool, err error) {
+		calls.Inc()
+		if !done || err != nil {
+			t.Errorf("Callback = %v, %v, want: true, nil", done, err)
+		}
+	}, network.NewProberTransport())
+	if got := m.Offer(context.Background(), ts.URL, 42, probeInterval, probeTimeout, ExpectsStatusCodes([]int{http.StatusOK})); got != OfferAccepted {
+		t.Fatalf("Offer() = %v, want: %v", got, OfferAccepted)
+	}
+
+	if err := m.Shutdown(context.Background()); err != nil {
+		t.Error("Shutdown() =", err)
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("Callback invoked %d times, want: 1", got)
+	}
+	if got := m.Offer(context.Background(), ts.URL, 42, probeInterval, probeTimeout); got != OfferShutdown {
+		t.Errorf("Offer() = %v after Shutdown(), want: %v", got, OfferShutdown)
+	}
+	if got := m.len(); got != 0 {
+		t.Errorf("Number of queued items = %d, want: 0", got)
+	}
+}
+
+func TestShutdownInterrupts(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer ts.Close()
+
+	var (
+		mu   sync.Mutex
+		args []interface{}
+	)
+	m := New(func(arg interface{}, done bool, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		args = append(args, arg)
+		if done || !errors.Is(err, ErrShutdown) {
+			t.Errorf("Callback = %v, %v, want: false, %v", done, err, ErrShutdown)
+		}
+	}, network.NewProberTransport(), WithDedupKey(func(target string, arg interface{}) interface{} { return target }))
+	const timeout = time.Minute
+	m.Offer(context.Background(), ts.URL, 1, probeInterval, timeout, ExpectsStatusCodes([]int{http.StatusOK}))
+	m.Offer(context.Background(), ts.URL, 2, probeInterval, timeout, ExpectsStatusCodes([]int{http.StatusOK}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*probeInterval)
+	defer cancel()
+	if err := m.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Shutdown() = %v, want: %v", err, context.DeadlineExceeded)
+	}
+	// The interrupted probes don't invoke the callbacks again.
+	time.Sleep(2 * probeInterval)
+	mu.Lock()
+	defer mu.Unlock()
+	if want := []interface{}{1, 2}; !cmp.Equal(args, want) {
+		t.Error("Callbacks (-want, +got) =", cmp.Diff(want, args))
+	}
+}
+
+func TestAsyncMultiple(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(probeServeFunc))
+	defer ts.Close()
+
+	wch := make(chan interface{})
+	defer close(wch)
+	cb := func(arg interface{}, done bool, err error) {
+		<-wch
+		wch <- 2006
+	}
+	m := New(cb, network.NewProberTransport())
+	if got := m.Offer(context.Background(), ts.URL, 1984, probeInterval, probeTimeout, ExpectsStatusCodes([]int{http.StatusOK})); got != OfferAccepted {
+		t.Errorf("First call to offer returned %v, want: %v", got, OfferAccepted)
+	}
+	if !m.Pending(ts.URL) {
+		t.Error("Pending() = false, want: true")
+	}
+	if m.Pending(ts.URL + "/other") {
+		t.Error("Pending() for another target = true, want: false")
+	}
+	got := m.Offer(context.Background(), ts.URL, 1982, probeInterval, probeTimeout, ExpectsStatusCodes([]int{http.StatusOK}))
+	if got != OfferCoalesced {
+		t.Errorf("Second call to offer returned %v, want: %v", got, OfferCoalesced)
+	}
+	if err := got.Err(); !errors.Is(err, ErrAlreadyOffered) {
+		t.Errorf("Err() = %v, want: %v", err, ErrAlreadyOffered)
+	}
+	if got, want := m.len(), 1; got != want {
+		t.Errorf("Number of queued items = %d, want: %d", got, want)
+	}
+	// Make sure we terminate the first probe.
+	wch <- 2009
+	<-wch
+
+	wait.PollImmediate(probeInterval, probeTimeout, func() (bool, error) {
+		return m.len() == 0, nil
+	})
+	if got, want := m.len(), 0; got != want {
+		t.Errorf("Number of queued items = %d, want: %d", got, want)
+	}
+}
+
+func TestOfferResult(t *testing.T) {
+	tests := []struct {
+		result OfferResult
+		want   string
+		err    error
+	}{
+		{OfferAccepted, "Accepted", nil},
+		{OfferCoalesced, "Coalesced", ErrAlreadyOffered},
+		{OfferQueueFull, "QueueFull", ErrBudgetExhausted},
+		{OfferShutdown, "Shutdown", ErrShutdown},
+	}
+	for _, test := range tests {
+		if got := test.result.String(); got != test.want {
+			t.Errorf("String() = %q, want: %q", got, test.want)
+		}
+		if got := test.result.Err(); !errors.Is(got, test.err) {
+			t.Errorf("%v: Err() = %v, want: %v", test.result, got, test.err)
+		}
+		if got, want := test.result.Accepted(), test.err == nil; got != want {
+			t.Errorf("%v: Accepted() = %t, want: %t", test.result, got, want)
+		}
+	}
+	if got := OfferResult(42).Err(); got == nil {
+		t.Error("Err() of an unknown result = nil")
+	}
+}
+
+func TestDedupKey(t *testing.T) {
+	var calls atomic.Int32
+	release := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Inc()
+		<-release
+	}))
+	defer ts.Close()
+
+	type result struct {
+		arg     interface{}
+		md      Metadata
+		success bool
+	}
+	results := make(chan result, 3)
+	cb := func(arg interface{}, md Metadata, success bool, err error) {
+		results <- result{arg: arg, md: md, success: success}
+	}
+	m := New(nil, network.NewProberTransport(), WithMetadataCallback(cb), WithDedupKey(DedupByTarget))
+	ctx := context.Background()
+	if got := m.Offer(ctx, ts.URL, "rev-1", probeInterval, probeTimeout, WithMetadata(Metadata{"revision": "rev-1"})); got != OfferAccepted {
+		t.Errorf("Offer(rev-1) = %v, want: %v", got, OfferAccepted)
+	}
+	if got := m.Offer(ctx, ts.URL, "rev-2", probeInterval, probeTimeout, WithMetadata(Metadata{"revision": "rev-2"})); got != OfferAccepted {
+		t.Errorf("Offer(rev-2) = %v, want: %v", got, OfferAccepted)
+	}
+	if got := m.Offer(ctx, ts.URL, "rev-1", probeInterval, probeTimeout); got != OfferCoalesced {
+		t.Errorf("Offer(rev-1) again = %v, want: %v", got, OfferCoalesced)
+	}
+	// Args which aren't comparable join the probe too.
+	if got := m.Offer(ctx, ts.URL, []string{"rev-3"}, probeInterval, probeTimeout); got != OfferAccepted {
+		t.Errorf("Offer([rev-3]) = %v, want: %v", got, OfferAccepted)
+	}
+	if got, want := m.len(), 1; got != want {
+		t.Errorf("Number of queued items = %d, want: %d", got, want)
+	}
+	close(release)
+
+	got := map[string]Metadata{}
+	for i := 0; i < 3; i++ {
+		r := <-results
+		if !r.success {
+			t.Errorf("Probe of %v failed", r.arg)
+		}
+		got[fmt.Sprint(r.arg)] = r.md
+	}
+	want := map[string]Metadata{
+		"rev-1":   {"revision": "rev-1"},
+		"rev-2":   {"revision": "rev-2"},
+		"[rev-3]": nil,
+	}
+	if !cmp.Equal(got, want) {
+		t.Error("Callbacks (-want, +got) =", cmp.Diff(want, got))
+	}
+	if got, want := calls.Load(), int32(1); got != want {
+		t.Errorf("Probe invocation count = %d, want: %d", got, want)
+	}
+}
+
+func TestDedupByArg(t *testing.T) {
+	release := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer ts.Close()
+
+	results := make(chan interface{}, 2)
+	m := New(func(arg interface{}, success bool, err error) {
+		results <- arg
+	}, network.NewProberTransport(), WithDedupKey(DedupByArg))
+	ctx := context.Background()
+	if got := m.Offer(ctx, ts.URL, "ing", probeInterval, probeTimeout); got != OfferAccepted {
+		t.Errorf("Offer() = %v, want: %v", got, OfferAccepted)
+	}
+	if got := m.Offer(ctx, ts.URL+"/other", "ing", probeInterval, probeTimeout); got != OfferCoalesced {
+		t.Errorf("Offer() for another target = %v, want: %v", got, OfferCoalesced)
+	}
+	if got := m.Offer(ctx, ts.URL, "other-ing", probeInterval, probeTimeout); got != OfferAccepted {
+		t.Errorf("Offer() for another arg = %v, want: %v", got, OfferAccepted)
+	}
+	if got, want := m.len(), 2; got != want {
+		t.Errorf("Number of queued items = %d, want: %d", got, want)
+	}
+	close(release)
+	got := sets.NewString(fmt.Sprint(<-results), fmt.Sprint(<-results))
+	if want := sets.NewString("ing", "other-ing"); !got.Equal(want) {
+		t.Errorf("Callbacks = %v, want: %v", got.List(), want.List())
+	}
+}
+
+func TestWithPathOption(t *testing.T) {
+	const path = "/correct/probe/path/"
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Logf("Got: %s, Want: %s\n", r.URL.Path, path)
+		if r.URL.Path != path {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	tests := []struct {
+		name    string
+		options []interface{}
+	}{{
+		name:    "no path",
+		options: []interface{}{ExpectsStatusCodes([]int{http.StatusNotFound})},
+	}, {
+		name:    "expected path",
+		options: []interface{}{WithPath(path), ExpectsStatusCodes([]int{http.StatusOK})},
+	}, {
+		name:    "wrong path",
+		options: []interface{}{WithPath("/wrong/path"), ExpectsStatusCodes([]int{http.StatusNotFound})},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if ok, _ := Do(context.Background(), network.AutoTransport, ts.URL, test.options...); !ok {
+				t.Error("Unexpected probe failure")
+			}
+		})
+	}
+}
+
+func TestWithMethodOption(t *testing.T) {
+	const body = "a large static resource"
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead:
+			w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+			w.Write([]byte(body))
+		case http.MethodOptions:
+			w.Header().Set("Allow", "GET, HEAD, OPTIONS")
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}))
+	defer ts.Close()
+
+	tests := []struct {
+		name    string
+		options []interface{}
+		want    bool
+	}{{
+		name:    "get",
+		options: []interface{}{ExpectsBody(body), ExpectsContentLength(int64(len(body)))},
+		want:    true,
+	}, {
+		name:    "get with wrong body",
+		options: []interface{}{ExpectsBody("something else")},
+	}, {
+		name: "head skips body expectations",
+		options: []interface{}{WithMethod(http.MethodHead), ExpectsStatusCodes([]int{http.StatusOK}),
+			ExpectsBody("something else"), ExpectsContentLength(int64(len(body)))},
+		want: true,
+	}, {
+		name:    "head with wrong content length",
+		options: []interface{}{WithMethod(http.MethodHead), ExpectsContentLength(1)},
+	}, {
+		name: "options",
+		options: []interface{}{WithMethod(http.MethodOptions),
+			ExpectsStatusCodes([]int{http.StatusNoContent}), ExpectsHeader("Allow", "GET, HEAD, OPTIONS")},
+		want: true,
+	}, {
+		name:    "unsupported method",
+		options: []interface{}{WithMethod(http.MethodDelete), ExpectsStatusCodes([]int{http.StatusOK})},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := Do(context.Background(), network.NewProberTransport(), ts.URL, test.options...)
+			if got != test.want {
+				t.Errorf("Do() = %v, %v, want: %v", got, err, test.want)
+			}
+		})
+	}
+}
+
+func TestExpectsBodyWithoutRequest(t *testing.T) {
+	// Transports not recording the request on the response must not cause
+	// HEAD probes to be verified against an empty body.
+	transport := network.RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode:    http.StatusOK,
+			Header:        http.Header{},
+			Body:          http.NoBody,
+			ContentLength: 42,
+		}, nil
+	})
+	got, err := Do(context.Background(), transport, "http://example.com", WithMethod(http.MethodHead),
+		ExpectsBody("body"), ExpectsContentLength(42))
+	if !got {
+		t.Error("Do() =", got, err)
+	}
+}
+
+func TestDoRequest(t *testing.T) {
+	const body = "probe body"
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		switch {
+		case r.Method != http.MethodPut:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		case string(b) != body:
+			w.WriteHeader(http.StatusBadRequest)
+		case r.Trailer.Get("Checksum") != "abc":
+			w.WriteHeader(http.StatusPreconditionFailed)
+		default:
+			w.Header().Set("Foo", r.Header.Get("Foo"))
+		}
+	}))
+	defer ts.Close()
+
+	tmpl, err := http.NewRequest(http.MethodPut, ts.URL, strings.NewReader(body))
+	if err != nil {
+		t.Fatal("NewRequest() =", err)
+	}
+	// Trailers require a chunked body.
+	tmpl.ContentLength = -1
+	tmpl.Trailer = http.Header{"Checksum": {"abc"}}
+
+	// The template can be used several times, and isn't modified by the Preparers.
+	for _, foo := range []string{"bar", "baz"} {
+		got, err := DoRequest(context.Background(), network.NewProberTransport(), tmpl,
+			WithHeader("Foo", foo), ExpectsStatusCodes([]int{http.StatusOK}), ExpectsHeader("Foo", foo))
+		if !got {
+			t.Errorf("DoRequest() = %v, %v, want: true", got, err)
+		}
+	}
+	if got := tmpl.Header.Get("Foo"); got != "" {
+		t.Errorf("Template header Foo = %q, want it unset", got)
+	}
+}
+
+func TestDoRequestUnreplayableBody(t *testing.T) {
+	req, err := http.NewRequest(http.MethodPost, "http://example.com", ioutil.NopCloser(strings.NewReader("body")))
+	if err != nil {
+		t.Fatal("NewRequest() =", err)
+	}
+	if got, err := DoRequest(context.Background(), network.NewProberTransport(), req); got || err == nil {
+		t.Errorf("DoRequest() = %v, %v, want: false, error", got, err)
+	}
+}
+
+func TestWithHostOption(t *testing.T) {
+	host := "foobar.com"
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Logf("Want: %s, Got: %s\n", host, r.Host)
+		if r.Host != host {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	tests := []struct {
+		name    string
+		options []interface{}
+	}{{
+		name:    "no hosts",
+		options: []interface{}{ExpectsStatusCodes([]int{http.StatusNotFound})},
+	}, {
+		name:    "expected host",
+		options: []interface{}{WithHost(host), ExpectsStatusCodes([]int{http.StatusOK})},
+	}, {
+		name:    "wrong host",
+		options: []interface{}{WithHost("nope.com"), ExpectsStatusCodes([]int{http.StatusNotFound})},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if ok, _ := Do(context.Background(), network.AutoTransport, ts.URL, test.options...); !ok {
+				t.Error("Unexpected probe result")
+			}
+		})
+	}
+}
+
+func TestWithAuthOptions(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); ok && user == "admin" && pass == "s3cr:t" {
+			return
+		}
+		if r.Header.Get("Authorization") == "Bearer token" {
+			return
+		}
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer ts.Close()
+
+	tests := []struct {
+		name    string
+		options []interface{}
+	}{{
+		name:    "no credentials",
+		options: []interface{}{ExpectsStatusCodes([]int{http.StatusUnauthorized})},
+	}, {
+		name:    "bearer token",
+		options: []interface{}{WithBearerToken("token"), ExpectsStatusCodes([]int{http.StatusOK})},
+	}, {
+		name:    "wrong bearer token",
+		options: []interface{}{WithBearerToken("nope"), ExpectsStatusCodes([]int{http.StatusUnauthorized})},
+	}, {
+		name:    "basic auth",
+		options: []interface{}{WithBasicAuth("admin", "s3cr:t"), ExpectsStatusCodes([]int{http.StatusOK})},
+	}, {
+		name:    "wrong password",
+		options: []interface{}{WithBasicAuth("admin", "s3cr"), ExpectsStatusCodes([]int{http.StatusUnauthorized})},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if ok, err := Do(context.Background(), network.AutoTransport, ts.URL, test.options...); !ok || err != nil {
+				t.Errorf("Do() = %v, %v, want: true, nil", ok, err)
+			}
+		})
+	}
+}
+
+func TestExpectsHeaderOption(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Foo", "Bar")
+	}))
+	defer ts.Close()
+
+	tests := []struct {
+		name    string
+		options []interface{}
+		success bool
+		expErr  bool
+	}{{
+		name:    "header is present",
+		options: []interface{}{ExpectsHeader("Foo", "Bar"), ExpectsStatusCodes([]int{http.StatusOK})},
+		success: true,
+	}, {
+		name:    "header is absent",
+		options: []interface{}{ExpectsHeader("Baz", "Nope"), ExpectsStatusCodes([]int{http.StatusOK})},
+		success: false,
+		expErr:  true,
+	}, {
+		name:    "header value doesn't match",
+		options: []interface{}{ExpectsHeader("Foo", "Baz"), ExpectsStatusCodes([]int{http.StatusOK})},
+		success: false,
+		expErr:  true,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ok, err := Do(context.Background(), network.AutoTransport, ts.URL, test.options...)
+			if ok != test.success {
+				t.Errorf("unexpected probe result: want: %v, got: %v", test.success, ok)
+			}
+			if err != nil && !test.expErr {
+				t.Errorf("Do() = %v, no error expected", err)
+			}
+			if err == nil && test.expErr {
+				t.Errorf("Do() = nil, expected an error")
+			}
+		})
+	}
+}
+
+func TestExpectsResponse(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"status":"ready"}`)
+	}))
+	defer ts.Close()
+
+	errNotReady := errors.New("not ready")
+	expectsReady := ExpectsResponse(func(r *http.Response, body []byte) error {
+		if r.StatusCode != http.StatusOK {
+			return fmt.Errorf("%w: got %d", ErrBadStatus, r.StatusCode)
+		}
+		if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+			return fmt.Errorf("unexpected content type %q", ct)
+		}
+		if !strings.Contains(string(body), `"ready"`) {
+			return errNotReady
+		}
+		return nil
+	})
+	if ok, err := Do(context.Background(), network.AutoTransport, ts.URL, expectsReady); !ok || err != nil {
+		t.Errorf("Do() = %v, %v, want: true, nil", ok, err)
+	}
+
+	// The failures keep the error of the predicate.
+	ok, err := Do(context.Background(), network.AutoTransport, ts.URL, ExpectsResponse(func(r *http.Response, _ []byte) error {
+		return fmt.Errorf("%w: got %d", ErrBadStatus, r.StatusCode)
+	}))
+	if ok || !errors.Is(err, ErrBadStatus) {
+		t.Errorf("Do() = %v, %v, want: false, %v", ok, err, ErrBadStatus)
+	}
+	ok, err = Do(context.Background(), network.AutoTransport, ts.URL, ExpectsResponse(func(*http.Response, []byte) error {
+		return errNotReady
+	}))
+	if ok || !errors.Is(err, errNotReady) {
+		t.Errorf("Do() = %v, %v, want: false, %v", ok, err, errNotReady)
+	}
+}
+
+func TestExpectsBodySHA256(t *testing.T) {
+	body := strings.Repeat("static asset ", 100000)
+	sum := sha256.Sum256([]byte(body))
+	digest := hex.EncodeToString(sum[:])
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, body)
+	}))
+	defer ts.Close()
+
+	tests := []struct {
+		name    string
+		options []interface{}
+		success bool
+		wantErr error
+	}{{
+		name:    "matching digest",
+		options: []interface{}{ExpectsBodySHA256(digest)},
+		success: true,
+	}, {
+		name:    "upper case digest",
+		options: []interface{}{ExpectsBodySHA256(strings.ToUpper(digest))},
+		success: true,
+	}, {
+		name:    "mismatching digest",
+		options: []interface{}{ExpectsBodySHA256(strings.Repeat("0", 2*sha256.Size))},
+		wantErr: ErrBodyMismatch,
+	}, {
+		name:    "HEAD probe",
+		options: []interface{}{WithMethod(http.MethodHead), ExpectsBodySHA256(strings.Repeat("0", 2*sha256.Size))},
+		success: true,
+	}, {
+		name:    "malformed digest",
+		options: []interface{}{ExpectsBodySHA256("not hex")},
+	}, {
+		name:    "truncated digest",
+		options: []interface{}{ExpectsBodySHA256(digest[:10])},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ok, err := Do(context.Background(), network.NewProberTransport(), ts.URL, test.options...)
+			if ok != test.success {
+				t.Errorf("Do() = %v, %v, want: %v", ok, err, test.success)
+			}
+			if !test.success && err == nil {
+				t.Error("Do() = nil, want an error")
+			}
+			if test.wantErr != nil && !errors.Is(err, test.wantErr) {
+				t.Errorf("Do() = %v, want: %v", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestExpectsLatencyUnder(t *testing.T) {
+	const delay = 50 * time.Millisecond
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(delay)
+	}))
+	defer ts.Close()
+
+	tests := []struct {
+		name    string
+		budget  time.Duration
+		success bool
+	}{{
+		name:    "within budget",
+		budget:  time.Minute,
+		success: true,
+	}, {
+		name:   "over budget",
+		budget: delay / 2,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ok, err := Do(context.Background(), network.AutoTransport, ts.URL, ExpectsLatencyUnder(test.budget))
+			if ok != test.success {
+				t.Errorf("Do() = %v, %v, want success: %v", ok, err, test.success)
+			}
+			if !test.success && err == nil {
+				t.Error("Do() = nil, expected an error")
+			}
+		})
+	}
+}
+
+func TestExpectsConnectionReused(t *testing.T) {
+	keepAlive := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer keepAlive.Close()
+	closing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Connection", "close")
+	}))
+	defer closing.Close()
+
+	tests := []struct {
+		name   string
+		target string
+		reused bool
+		// Whether the probe is expected to succeed, for each of two probes in a row.
+		want []bool
+	}{{
+		name:   "keep-alive reused",
+		target: keepAlive.URL,
+		reused: true,
+		want:   []bool{false, true},
+	}, {
+		name:   "keep-alive not reused",
+		target: keepAlive.URL,
+		reused: false,
+		want:   []bool{true, false},
+	}, {
+		name:   "connection closed",
+		target: closing.URL,
+		reused: false,
+		want:   []bool{true, true},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Unlike the prober transports, keeps the connections alive.
+			transport := http.DefaultTransport.(*http.Transport).Clone()
+			defer transport.CloseIdleConnections()
+			for i, want := range test.want {
+				ok, err := Do(context.Background(), transport, test.target, ExpectsConnectionReused(test.reused))
+				if ok != want {
+					t.Errorf("Probe %d: Do() = %v, %v, want success: %v", i, ok, err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestExpectsConnectionReusedUntraced(t *testing.T) {
+	transport := network.RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+	})
+	if ok, err := Do(context.Background(), transport, "http://example.com", ExpectsConnectionReused(false)); ok || err == nil {
+		t.Errorf("Do() = %v, %v, want an error", ok, err)
+	}
+}
+
+func TestWithConnectionClose(t *testing.T) {
+	honoring := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer honoring.Close()
+
+	// A server ignoring the Connection header of the requests.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal("Failed to listen:", err)
+	}
+	defer l.Close()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				br := bufio.NewReader(conn)
+				for {
+					if _, err := http.ReadRequest(br); err != nil {
+						return
+					}
+					io.WriteString(conn, "HTTP/1.1 200 OK\r\nContent-Length: 0\r\n\r\n")
+				}
+			}()
+		}
+	}()
+
+	tests := []struct {
+		name   string
+		target string
+		ops    []interface{}
+		// Whether the probe is expected to succeed, for each of two probes in a row.
+		want []bool
+	}{{
+		name:   "close honored",
+		target: honoring.URL,
+		ops:    []interface{}{WithConnectionClose(), ExpectsConnectionClose(true)},
+		want:   []bool{true, true},
+	}, {
+		name:   "close ignored",
+		target: "http://" + l.Addr().String(),
+		ops:    []interface{}{WithConnectionClose(), ExpectsConnectionClose(true)},
+		want:   []bool{false, false},
+	}, {
+		name:   "keep-alive",
+		target: honoring.URL,
+		ops:    []interface{}{ExpectsConnectionClose(false)},
+		want:   []bool{true, true},
+	}, {
+		name:   "new connection per attempt",
+		target: honoring.URL,
+		ops:    []interface{}{WithConnectionClose(), ExpectsConnectionReused(false)},
+		want:   []bool{true, true},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			// Unlike the prober transports, keeps the connections alive.
+			transport := http.DefaultTransport.(*http.Transport).Clone()
+			defer transport.CloseIdleConnections()
+			for i, want := range test.want {
+				ok, err := Do(context.Background(), transport, test.target, test.ops...)
+				if ok != want {
+					t.Errorf("Probe %d: Do() = %v, %v, want success: %v", i, ok, err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestExpectsProto(t *testing.T) {
+	h1 := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer h1.Close()
+	h2 := httptest.NewUnstartedServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	h2.EnableHTTP2 = true
+	h2.StartTLS()
+	defer h2.Close()
+
+	tests := []struct {
+		name  string
+		ts    *httptest.Server
+		proto string
+		want  bool
+	}{{
+		name:  "http/1.1",
+		ts:    h1,
+		proto: "HTTP/1.1",
+		want:  true,
+	}, {
+		name:  "http/2",
+		ts:    h2,
+		proto: "HTTP/2.0",
+		want:  true,
+	}, {
+		name:  "downgraded",
+		ts:    h1,
+		proto: "HTTP/2.0",
+	}, {
+		name:  "upgraded",
+		ts:    h2,
+		proto: "HTTP/1.1",
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ok, err := Do(context.Background(), test.ts.Client().Transport, test.ts.URL, ExpectsProto(test.proto))
+			if ok != test.want || (err == nil) != test.want {
+				t.Errorf("Do() = %v, %v, want success: %v", ok, err, test.want)
+			}
+		})
+	}
+
+	// Responses of transports only setting the version.
+	r := &http.Response{ProtoMajor: 1, ProtoMinor: 1}
+	if ok, err := ExpectsProto("HTTP/1.1")(r, nil); !ok || err != nil {
+		t.Errorf("ExpectsProto() = %v, %v, want: true, nil", ok, err)
+	}
+}
+
+func TestExpectsALPN(t *testing.T) {
+	h1 := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	defer h1.Close()
+	h2 := httptest.NewUnstartedServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+	h2.EnableHTTP2 = true
+	h2.StartTLS()
+	defer h2.Close()
+
+	tests := []struct {
+		name  string
+		ts    *httptest.Server
+		proto string
+		want  bool
+	}{{
+		name:  "h2",
+		ts:    h2,
+		proto: "h2",
+		want:  true,
+	}, {
+		name:  "other protocol",
+		ts:    h2,
+		proto: "h3",
+	}, {
+		name:  "plaintext",
+		ts:    h1,
+		proto: "h2",
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ok, err := Do(context.Background(), test.ts.Client().Transport, test.ts.URL, ExpectsALPN(test.proto))
+			if ok != test.want || (err == nil) != test.want {
+				t.Errorf("Do() = %v, %v, want success: %v", ok, err, test.want)
+			}
+		})
+	}
+}
+
+func TestExpectsTrailers(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Trailer", "X-Checksum")
+		w.Write([]byte("body"))
+		w.Header().Set("X-Checksum", "abc")
+	}))
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		trailers map[string]string
+		want     bool
+	}{{
+		name:     "matching trailer",
+		trailers: map[string]string{"X-Checksum": "abc"},
+		want:     true,
+	}, {
+		name:     "mismatching trailer",
+		trailers: map[string]string{"X-Checksum": "def"},
+	}, {
+		name:     "missing trailer",
+		trailers: map[string]string{"X-Other": "abc"},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := Do(context.Background(), network.NewProberTransport(), ts.URL, ExpectsTrailers(test.trailers))
+			if got != test.want {
+				t.Errorf("Do() = %v, %v, want: %v", got, err, test.want)
+			}
+			if !got && err == nil {
+				t.Error("Do() did not return an error")
+			}
+		})
+	}
+}
+
+func TestExpectsGRPCStatus(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		code    codes.Code
+		want    bool
+	}{{
+		name: "ok in trailers",
+		handler: func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Trailer", "Grpc-Status")
+			w.Write([]byte("payload"))
+			w.Header().Set("Grpc-Status", "0")
+		},
+		code: codes.OK,
+		want: true,
+	}, {
+		name: "error in trailers",
+		handler: func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Trailer", "Grpc-Status, Grpc-Message")
+			w.Write([]byte("payload"))
+			w.Header().Set("Grpc-Status", "14")
+			w.Header().Set("Grpc-Message", "upstream connect error")
+		},
+		code: codes.OK,
+	}, {
+		name: "trailers-only response",
+		handler: func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Grpc-Status", "5")
+		},
+		code: codes.NotFound,
+		want: true,
+	}, {
+		name:    "no status",
+		handler: func(w http.ResponseWriter, r *http.Request) {},
+		code:    codes.OK,
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ts := httptest.NewServer(test.handler)
+			defer ts.Close()
+
+			got, err := Do(context.Background(), network.NewProberTransport(), ts.URL,
+				ExpectsStatusCodes([]int{http.StatusOK}), ExpectsGRPCStatus(test.code))
+			if got != test.want {
+				t.Errorf("Do() = %v, %v, want: %v", got, err, test.want)
+			}
+			if !got && err == nil {
+				t.Error("Do() did not return an error")
+			}
+		})
+	}
+}
+
+func TestExpectsCertificate(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+	roots := x509.NewCertPool()
+	roots.AddCert(ts.Certificate())
+	insecure := &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
+
+	tests := []struct {
+		name     string
+		target   string
+		verifier Verifier
+		want     bool
+	}{{
+		name:     "certificate for host",
+		target:   ts.URL,
+		verifier: ExpectsCertificateFor("example.com"),
+		want:     true,
+	}, {
+		name:     "certificate for another host",
+		target:   ts.URL,
+		verifier: ExpectsCertificateFor("knative.dev"),
+	}, {
+		name:     "issued by CA",
+		target:   ts.URL,
+		verifier: ExpectsCertIssuedBy(roots),
+		want:     true,
+	}, {
+		name:     "issued by another CA",
+		target:   ts.URL,
+		verifier: ExpectsCertIssuedBy(x509.NewCertPool()),
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := Do(context.Background(), insecure, test.target, test.verifier)
+			if got != test.want {
+				t.Errorf("Do() = %v, %v, want: %v", got, err, test.want)
+			}
+			if !got && err == nil {
+				t.Error("Do() did not return an error")
+			}
+		})
+	}
+
+	t.Run("plain HTTP", func(t *testing.T) {
+		plain := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		defer plain.Close()
+		for _, v := range []Verifier{ExpectsCertificateFor("example.com"), ExpectsCertIssuedBy(roots)} {
+			if got, err := Do(context.Background(), insecure, plain.URL, v); got || err == nil {
+				t.Errorf("Do() = %v, %v, want: false, an error", got, err)
+			}
+		}
+	})
+}
+
+func TestExpectsSPIFFEID(t *testing.T) {
+	const id = "spiffe://cluster.local/ns/default/sa/default"
+	insecure := &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
+
+	tests := []struct {
+		name string
+		uris []string
+		want bool
+	}{{
+		name: "matching SPIFFE ID",
+		uris: []string{id},
+		want: true,
+	}, {
+		name: "matching SPIFFE ID and other URIs",
+		uris: []string{"https://example.com", id},
+		want: true,
+	}, {
+		name: "another SPIFFE ID",
+		uris: []string{"spiffe://cluster.local/ns/other/sa/default"},
+	}, {
+		name: "no SPIFFE ID",
+		uris: []string{"https://example.com"},
+	}, {
+		name: "multiple SPIFFE IDs",
+		uris: []string{id, "spiffe://cluster.local/ns/other/sa/default"},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+			ts.TLS = &tls.Config{Certificates: []tls.Certificate{svid(t, test.uris...)}}
+			ts.StartTLS()
+			defer ts.Close()
+
+			got, err := Do(context.Background(), insecure, ts.URL, ExpectsSPIFFEID(id))
+			if got != test.want {
+				t.Errorf("Do() = %v, %v, want: %v", got, err, test.want)
+			}
+			if !got && err == nil {
+				t.Error("Do() did not return an error")
+			}
+		})
+	}
+}
+
+// svid returns a self-signed certificate carrying the given URI SANs.
+func svid(t *testing.T, uris ...string) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), cryptorand.Reader)
+	if err != nil {
+		t.Fatal("Failed to generate key:", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	for _, u := range uris {
+		parsed, err := url.Parse(u)
+		if err != nil {
+			t.Fatalf("Failed to parse URI %q: %v", u, err)
+		}
+		template.URIs = append(template.URIs, parsed)
+	}
+	der, err := x509.CreateCertificate(cryptorand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal("Failed to create certificate:", err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func (m *Manager) len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.probes)
+}
+
+func BenchmarkDo(b *testing.B) {
+	ts := httptest.NewServer(http.HandlerFunc(probeServeFunc))
+	defer ts.Close()
+	transport := network.NewAutoTransport(100, 100)
+	ops := []interface{}{
+		WithHeader(header.ProbeKey, systemName),
+		ExpectsBody(systemName),
+		ExpectsStatusCodes([]int{http.StatusOK}),
+	}
+
+	b.Run("sequential", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if ok, err := Do(context.Background(), transport, ts.URL, ops...); !ok || err != nil {
+				b.Fatalf("Do() = %v, %v", ok, err)
+			}
+		}
+	})
+
+	b.Run("parallel", func(b *testing.B) {
+		b.ReportAllocs()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				if ok, err := Do(context.Background(), transport, ts.URL, ops...); !ok || err != nil {
+					b.Fatalf("Do() = %v, %v", ok, err)
+				}
+			}
+		})
+	})
+}
+
+func BenchmarkOffer(b *testing.B) {
+	// Each probe needs a few attempts to succeed, like a real status prober would.
+	var calls atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Inc()%3 != 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer ts.Close()
+
+	done := make(chan struct{}, 100)
+	m := New(func(arg interface{}, success bool, err error) {
+		done <- struct{}{}
+	}, network.NewAutoTransport(100, 100))
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		target := ts.URL + "/" + strconv.Itoa(i)
+		m.Offer(context.Background(), target, nil, time.Millisecond, time.Second, ExpectsStatusCodes([]int{http.StatusOK}))
+		<-done
+	}
 }
@@ -0,0 +1,282 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package prober
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// The schemes of the proxies the probes can be tunneled through.
+const (
+	proxySchemeSOCKS5 = "socks5"
+	proxySchemeHTTP   = "http"
+)
+
+// WithProxy tunnels the probe connections through the proxy at the given URL,
+// e.g. to probe external custom domains from clusters whose egress is forced
+// through a corporate proxy. URLs with the socks5 scheme are SOCKS5 proxies
+// (RFC 1928), which resolve the probed host themselves, URLs with the http
+// scheme are HTTP proxies supporting CONNECT. The credentials of the URL, if
+// any, authenticate the probes against the proxy.
+//
+// The proxy itself is dialed with the options of the probe, e.g.
+// WithDialContext or WithLocalAddr, while the addresses set with
+// WithResolveTo are the ones the tunnels are established to.
+func WithProxy(proxy *url.URL) DialOption {
+	return func(c *dialConfig) {
+		c.proxy = proxy
+	}
+}
+
+// dialProxy establishes a tunnel to address through the proxy.
+func (c *dialConfig) dialProxy(ctx context.Context, netw, address string) (net.Conn, error) {
+	switch netw {
+	case "tcp", "tcp4", "tcp6":
+	default:
+		return nil, fmt.Errorf("network %s can't be tunneled through a proxy", netw)
+	}
+	var (
+		tunnel      func(net.Conn, string) (net.Conn, error)
+		defaultPort string
+	)
+	switch c.proxy.Scheme {
+	case proxySchemeSOCKS5:
+		tunnel, defaultPort = c.tunnelSOCKS5, "1080"
+	case proxySchemeHTTP:
+		tunnel, defaultPort = c.tunnelCONNECT, "80"
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme %q", c.proxy.Scheme)
+	}
+
+	proxyAddr := c.proxy.Host
+	if c.proxy.Port() == "" {
+		proxyAddr = net.JoinHostPort(c.proxy.Hostname(), defaultPort)
+	}
+	conn, err := c.dialDirect(ctx, netw, proxyAddr)
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	tunneled, err := tunnel(conn, address)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to tunnel to %s through proxy %s: %w", address, proxyAddr, err)
+	}
+	conn.SetDeadline(time.Time{})
+	return tunneled, nil
+}
+
+// The SOCKS5 protocol constants, see RFC 1928 and RFC 1929.
+const (
+	socks5Version          = 0x05
+	socks5AuthNone         = 0x00
+	socks5AuthPassword     = 0x02
+	socks5AuthNoAcceptable = 0xff
+	socks5PasswordVersion  = 0x01
+	socks5CommandConnect   = 0x01
+	socks5AddressIPv4      = 0x01
+	socks5AddressDomain    = 0x03
+	socks5AddressIPv6      = 0x04
+	socks5ReplySucceeded   = 0x00
+	socks5MaxLength        = 255
+)
+
+// socks5Replies are the messages of the failure replies of the SOCKS5 proxies.
+var socks5Replies = map[byte]string{
+	0x01: "general SOCKS server failure",
+	0x02: "connection not allowed by ruleset",
+	0x03: "network unreachable",
+	0x04: "host unreachable",
+	0x05: "connection refused",
+	0x06: "TTL expired",
+	0x07: "command not supported",
+	0x08: "address type not supported",
+}
+
+// tunnelSOCKS5 asks the SOCKS5 proxy at the other end of conn to connect to address.
+func (c *dialConfig) tunnelSOCKS5(conn net.Conn, address string) (net.Conn, error) {
+	host, portStr, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid port %q: %w", portStr, err)
+	}
+
+	methods := []byte{socks5AuthNone}
+	if c.proxy.User != nil {
+		methods = append(methods, socks5AuthPassword)
+	}
+	if _, err := conn.Write(append([]byte{socks5Version, byte(len(methods))}, methods...)); err != nil {
+		return nil, err
+	}
+	var reply [2]byte
+	if _, err := io.ReadFull(conn, reply[:]); err != nil {
+		return nil, err
+	}
+	if reply[0] != socks5Version {
+		return nil, fmt.Errorf("unexpected SOCKS version %d", reply[0])
+	}
+	switch reply[1] {
+	case socks5AuthNone:
+	case socks5AuthPassword:
+		if c.proxy.User == nil {
+			return nil, errors.New("the proxy requires credentials")
+		}
+		if err := c.authenticateSOCKS5(conn); err != nil {
+			return nil, err
+		}
+	case socks5AuthNoAcceptable:
+		return nil, errors.New("no authentication method accepted by the proxy")
+	default:
+		return nil, fmt.Errorf("unsupported authentication method %d", reply[1])
+	}
+
+	req := []byte{socks5Version, socks5CommandConnect, 0x00}
+	if ip := net.ParseIP(host); ip == nil {
+		if len(host) > socks5MaxLength {
+			return nil, fmt.Errorf("host %q is too long", host)
+		}
+		req = append(req, socks5AddressDomain, byte(len(host)))
+		req = append(req, host...)
+	} else if ip4 := ip.To4(); ip4 != nil {
+		req = append(req, socks5AddressIPv4)
+		req = append(req, ip4...)
+	} else {
+		req = append(req, socks5AddressIPv6)
+		req = append(req, ip.To16()...)
+	}
+	req = append(req, byte(port>>8), byte(port))
+	if _, err := conn.Write(req); err != nil {
+		return nil, err
+	}
+
+	var header [4]byte
+	if _, err := io.ReadFull(conn, header[:]); err != nil {
+		return nil, err
+	}
+	if header[0] != socks5Version {
+		return nil, fmt.Errorf("unexpected SOCKS version %d", header[0])
+	}
+	if header[1] != socks5ReplySucceeded {
+		if msg, ok := socks5Replies[header[1]]; ok {
+			return nil, errors.New(msg)
+		}
+		return nil, fmt.Errorf("unknown SOCKS reply %d", header[1])
+	}
+	// Skip the address the proxy bound, followed by its port.
+	var skip int
+	switch header[3] {
+	case socks5AddressIPv4:
+		skip = net.IPv4len
+	case socks5AddressIPv6:
+		skip = net.IPv6len
+	case socks5AddressDomain:
+		var l [1]byte
+		if _, err := io.ReadFull(conn, l[:]); err != nil {
+			return nil, err
+		}
+		skip = int(l[0])
+	default:
+		return nil, fmt.Errorf("unknown SOCKS address type %d", header[3])
+	}
+	if _, err := io.CopyN(io.Discard, conn, int64(skip+2)); err != nil {
+		return nil, err
+	}
+	return conn, nil
+}
+
+// authenticateSOCKS5 authenticates against the SOCKS5 proxy with the
+// credentials of its URL.
+func (c *dialConfig) authenticateSOCKS5(conn net.Conn) error {
+	user := c.proxy.User.Username()
+	password, _ := c.proxy.User.Password()
+	if len(user) > socks5MaxLength || len(password) > socks5MaxLength {
+		return errors.New("the proxy credentials are too long")
+	}
+	req := []byte{socks5PasswordVersion, byte(len(user))}
+	req = append(req, user...)
+	req = append(req, byte(len(password)))
+	req = append(req, password...)
+	if _, err := conn.Write(req); err != nil {
+		return err
+	}
+	var reply [2]byte
+	if _, err := io.ReadFull(conn, reply[:]); err != nil {
+		return err
+	}
+	if reply[1] != 0x00 {
+		return errors.New("the proxy rejected the credentials")
+	}
+	return nil
+}
+
+// tunnelCONNECT asks the HTTP proxy at the other end of conn to connect to address.
+func (c *dialConfig) tunnelCONNECT(conn net.Conn, address string) (net.Conn, error) {
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: address},
+		Host:   address,
+		Header: make(http.Header),
+	}
+	if c.proxy.User != nil {
+		password, _ := c.proxy.User.Password()
+		req.Header.Set("Proxy-Authorization", "Basic "+
+			base64.StdEncoding.EncodeToString([]byte(c.proxy.User.Username()+":"+password)))
+	}
+	if err := req.Write(conn); err != nil {
+		return nil, err
+	}
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("the proxy refused the tunnel: %s", resp.Status)
+	}
+	if br.Buffered() > 0 {
+		// Don't lose what the tunneled endpoint already sent.
+		return &bufferedConn{Conn: conn, r: br}, nil
+	}
+	return conn, nil
+}
+
+// bufferedConn is a connection whose first bytes were already read into r.
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+// Read implements net.Conn.
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
@@ -0,0 +1,296 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package prober
+
+import (
+	"context"
+	"encoding/base64"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"knative.dev/pkg/network"
+)
+
+// tunnels records the addresses the test proxies were asked to tunnel to.
+type tunnels struct {
+	mu    sync.Mutex
+	addrs []string
+}
+
+func (t *tunnels) add(addr string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.addrs = append(t.addrs, addr)
+}
+
+func (t *tunnels) get() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]string(nil), t.addrs...)
+}
+
+// relay connects the tunneled connection to the backend the target is
+// resolved to.
+func relay(conn net.Conn, backend string) {
+	defer conn.Close()
+	upstream, err := net.Dial("tcp", backend)
+	if err != nil {
+		return
+	}
+	defer upstream.Close()
+	go func() {
+		io.Copy(upstream, conn)
+		upstream.Close()
+	}()
+	io.Copy(conn, upstream)
+}
+
+// newSOCKS5Proxy starts a SOCKS5 proxy tunneling all the connections to
+// backend, requiring the given credentials if not empty.
+func newSOCKS5Proxy(t *testing.T, backend, user, password string) (*url.URL, *tunnels) {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal("Failed to listen:", err)
+	}
+	t.Cleanup(func() { l.Close() })
+	tun := &tunnels{}
+
+	serve := func(conn net.Conn) {
+		buf := make([]byte, 512)
+		if _, err := io.ReadFull(conn, buf[:2]); err != nil {
+			conn.Close()
+			return
+		}
+		methods := make([]byte, buf[1])
+		io.ReadFull(conn, methods)
+		method := byte(socks5AuthNone)
+		if user != "" {
+			method = socks5AuthPassword
+		}
+		if !strings.ContainsRune(string(methods), rune(method)) {
+			conn.Write([]byte{socks5Version, socks5AuthNoAcceptable})
+			conn.Close()
+			return
+		}
+		conn.Write([]byte{socks5Version, method})
+		if user != "" {
+			io.ReadFull(conn, buf[:2])
+			u := make([]byte, buf[1])
+			io.ReadFull(conn, u)
+			io.ReadFull(conn, buf[:1])
+			p := make([]byte, buf[0])
+			io.ReadFull(conn, p)
+			if string(u) != user || string(p) != password {
+				conn.Write([]byte{socks5PasswordVersion, 0x01})
+				conn.Close()
+				return
+			}
+			conn.Write([]byte{socks5PasswordVersion, 0x00})
+		}
+
+		io.ReadFull(conn, buf[:4])
+		var host string
+		switch buf[3] {
+		case socks5AddressIPv4:
+			io.ReadFull(conn, buf[:net.IPv4len])
+			host = net.IP(buf[:net.IPv4len]).String()
+		case socks5AddressIPv6:
+			io.ReadFull(conn, buf[:net.IPv6len])
+			host = net.IP(buf[:net.IPv6len]).String()
+		case socks5AddressDomain:
+			io.ReadFull(conn, buf[:1])
+			name := make([]byte, buf[0])
+			io.ReadFull(conn, name)
+			host = string(name)
+		}
+		io.ReadFull(conn, buf[:2])
+		port := int(buf[0])<<8 | int(buf[1])
+		tun.add(net.JoinHostPort(host, strconv.Itoa(port)))
+		if host == "refused.example.com" {
+			conn.Write([]byte{socks5Version, 0x05, 0x00, socks5AddressIPv4, 0, 0, 0, 0, 0, 0})
+			conn.Close()
+			return
+		}
+		conn.Write([]byte{socks5Version, socks5ReplySucceeded, 0x00, socks5AddressIPv4, 127, 0, 0, 1, 0, 0})
+		relay(conn, backend)
+	}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go serve(conn)
+		}
+	}()
+
+	u := &url.URL{Scheme: proxySchemeSOCKS5, Host: l.Addr().String()}
+	if user != "" {
+		u.User = url.UserPassword(user, password)
+	}
+	return u, tun
+}
+
+// newCONNECTProxy starts an HTTP proxy tunneling all the CONNECT requests to
+// backend, requiring the given credentials if not empty.
+func newCONNECTProxy(t *testing.T, backend, user, password string) (*url.URL, *tunnels) {
+	t.Helper()
+	tun := &tunnels{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodConnect {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		tun.add(r.Host)
+		if user != "" && r.Header.Get("Proxy-Authorization") !=
+			"Basic "+base64.StdEncoding.EncodeToString([]byte(user+":"+password)) {
+			w.WriteHeader(http.StatusProxyAuthRequired)
+			return
+		}
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			return
+		}
+		io.WriteString(conn, "HTTP/1.1 200 Connection established\r\n\r\n")
+		relay(conn, backend)
+	}))
+	t.Cleanup(ts.Close)
+
+	u, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal("Failed to parse URL:", err)
+	}
+	if user != "" {
+		u.User = url.UserPassword(user, password)
+	}
+	return u, tun
+}
+
+func TestWithProxy(t *testing.T) {
+	const host = "external.example.com"
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if h, _, err := net.SplitHostPort(r.Host); r.Host != host && (err != nil || h != host) {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer backend.Close()
+	backendAddr := backend.Listener.Addr().String()
+
+	tests := []struct {
+		name      string
+		newProxy  func(t *testing.T, backend, user, password string) (*url.URL, *tunnels)
+		user      string
+		password  string
+		wrongUser bool
+		target    string
+		ops       []interface{}
+		wantOK    bool
+		wantAddr  string
+	}{{
+		name:     "socks5",
+		newProxy: newSOCKS5Proxy,
+		target:   "http://" + host,
+		wantOK:   true,
+		wantAddr: host + ":80",
+	}, {
+		name:     "socks5 with credentials",
+		newProxy: newSOCKS5Proxy,
+		user:     "probe",
+		password: "secret",
+		target:   "http://" + host,
+		wantOK:   true,
+		wantAddr: host + ":80",
+	}, {
+		name:      "socks5 with wrong credentials",
+		newProxy:  newSOCKS5Proxy,
+		user:      "probe",
+		password:  "secret",
+		wrongUser: true,
+		target:    "http://" + host,
+	}, {
+		name:     "socks5 with resolved address",
+		newProxy: newSOCKS5Proxy,
+		target:   "http://" + host + ":8080",
+		ops:      []interface{}{WithResolveTo("10.0.0.1")},
+		wantOK:   true,
+		wantAddr: "10.0.0.1:8080",
+	}, {
+		name:     "socks5 refused",
+		newProxy: newSOCKS5Proxy,
+		target:   "http://refused.example.com",
+		wantAddr: "refused.example.com:80",
+	}, {
+		name:     "connect",
+		newProxy: newCONNECTProxy,
+		target:   "http://" + host,
+		wantOK:   true,
+		wantAddr: host + ":80",
+	}, {
+		name:     "connect with credentials",
+		newProxy: newCONNECTProxy,
+		user:     "probe",
+		password: "secret",
+		target:   "http://" + host,
+		wantOK:   true,
+		wantAddr: host + ":80",
+	}, {
+		name:      "connect with wrong credentials",
+		newProxy:  newCONNECTProxy,
+		user:      "probe",
+		password:  "secret",
+		wrongUser: true,
+		target:    "http://" + host,
+		wantAddr:  host + ":80",
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			proxy, tun := test.newProxy(t, backendAddr, test.user, test.password)
+			if test.wrongUser {
+				proxy.User = url.UserPassword(test.user, "wrong")
+			}
+			ops := append([]interface{}{WithProxy(proxy), ExpectsStatusCodes([]int{http.StatusOK})}, test.ops...)
+			ok, err := Do(context.Background(), network.NewProberTransport(), test.target, ops...)
+			if ok != test.wantOK || (err == nil) != test.wantOK {
+				t.Errorf("Do() = %v, %v, want ok: %v", ok, err, test.wantOK)
+			}
+			var want []string
+			if test.wantAddr != "" {
+				want = []string{test.wantAddr}
+			}
+			if got := tun.get(); strings.Join(got, ",") != strings.Join(want, ",") {
+				t.Errorf("Tunnels = %v, want: %v", got, want)
+			}
+		})
+	}
+}
+
+func TestWithProxyUnsupportedScheme(t *testing.T) {
+	ok, err := Do(context.Background(), network.NewProberTransport(), "http://example.com",
+		WithProxy(&url.URL{Scheme: "ftp", Host: "127.0.0.1:21"}), ExpectsStatusCodes([]int{http.StatusOK}))
+	if ok || err == nil || !strings.Contains(err.Error(), `unsupported proxy scheme "ftp"`) {
+		t.Errorf("Do() = %v, %v, want: false, an unsupported proxy scheme error", ok, err)
+	}
+}
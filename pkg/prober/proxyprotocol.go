@@ -0,0 +1,117 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package prober
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"time"
+)
+
+// The PROXY protocol v2 constants, see
+// https://www.haproxy.org/download/2.6/doc/proxy-protocol.txt.
+const (
+	proxyProtocolLocal    = 0x20
+	proxyProtocolProxy    = 0x21
+	proxyProtocolUnspec   = 0x00
+	proxyProtocolTCPv4    = 0x11
+	proxyProtocolTCPv6    = 0x21
+	proxyProtocolHeaderV4 = 2 * (net.IPv4len + 2)
+	proxyProtocolHeaderV6 = 2 * (net.IPv6len + 2)
+)
+
+// proxyProtocolSignature starts the PROXY protocol v2 headers.
+var proxyProtocolSignature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+// proxyProtocol is the PROXY protocol header sent on the probe connections.
+type proxyProtocol struct {
+	// source is the client address sent with the PROXY command, the LOCAL
+	// command being sent if unset.
+	source netip.AddrPort
+}
+
+// WithProxyProtocol prepends a PROXY protocol v2 header to the probe
+// connections, so that the gateways and backends requiring it, e.g. behind
+// a load balancer with the PROXY protocol enabled, can still be probed. The
+// header carries the LOCAL command, by which load balancers tell the
+// connections they establish themselves, e.g. for health checks, so the
+// receivers use the addresses of the connection. The header is sent on the
+// connections tunneled through the proxy set with WithProxy, if any, and
+// precedes the TLS handshake.
+func WithProxyProtocol() DialOption {
+	return func(c *dialConfig) {
+		c.proxyProtocol = &proxyProtocol{}
+	}
+}
+
+// WithProxyProtocolSource is like WithProxyProtocol, but the header carries
+// the PROXY command, relaying a connection of a client at the given source
+// address, e.g. for the receivers rejecting the LOCAL command, or to check
+// that the ones allowing a range of client addresses do accept it. The
+// destination address is the dialed one, or the remote address of the
+// connection if it is tunneled through a proxy to a host name.
+func WithProxyProtocolSource(source netip.AddrPort) DialOption {
+	return func(c *dialConfig) {
+		c.proxyProtocol = &proxyProtocol{source: source}
+	}
+}
+
+// write sends the header on conn, established to address.
+func (p *proxyProtocol) write(ctx context.Context, conn net.Conn, address string) error {
+	header, err := p.header(conn, address)
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetWriteDeadline(deadline)
+		defer conn.SetWriteDeadline(time.Time{})
+	}
+	_, err = conn.Write(header)
+	return err
+}
+
+// header returns the header to send on conn, established to address.
+func (p *proxyProtocol) header(conn net.Conn, address string) ([]byte, error) {
+	header := append([]byte(nil), proxyProtocolSignature...)
+	if !p.source.IsValid() {
+		return append(header, proxyProtocolLocal, proxyProtocolUnspec, 0, 0), nil
+	}
+
+	dst, err := netip.ParseAddrPort(address)
+	if err != nil {
+		tcp, ok := conn.RemoteAddr().(*net.TCPAddr)
+		if !ok {
+			return nil, fmt.Errorf("no destination address for %s", address)
+		}
+		dst = tcp.AddrPort()
+	}
+	src := p.source
+	srcAddr, dstAddr := src.Addr().Unmap(), dst.Addr().Unmap()
+	if srcAddr.Is4() && dstAddr.Is4() {
+		header = append(header, proxyProtocolProxy, proxyProtocolTCPv4, 0, proxyProtocolHeaderV4)
+		s, d := srcAddr.As4(), dstAddr.As4()
+		header = append(append(header, s[:]...), d[:]...)
+	} else {
+		// Addresses of different families are both sent as IPv6 ones.
+		header = append(header, proxyProtocolProxy, proxyProtocolTCPv6, 0, proxyProtocolHeaderV6)
+		s, d := src.Addr().As16(), dst.Addr().As16()
+		header = append(append(header, s[:]...), d[:]...)
+	}
+	return append(header, byte(src.Port()>>8), byte(src.Port()), byte(dst.Port()>>8), byte(dst.Port())), nil
+}
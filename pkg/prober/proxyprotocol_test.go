@@ -0,0 +1,151 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package prober
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"sync"
+	"testing"
+
+	"knative.dev/pkg/network"
+)
+
+// proxyProtocolListener is a listener requiring a PROXY protocol v2 header
+// on the accepted connections, and recording it.
+type proxyProtocolListener struct {
+	net.Listener
+
+	mu      sync.Mutex
+	headers [][]byte
+}
+
+func (l *proxyProtocolListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+		header := make([]byte, len(proxyProtocolSignature)+4)
+		if _, err := io.ReadFull(conn, header); err != nil || !bytes.HasPrefix(header, proxyProtocolSignature) {
+			conn.Close()
+			continue
+		}
+		addrs := make([]byte, int(header[len(header)-2])<<8|int(header[len(header)-1]))
+		if _, err := io.ReadFull(conn, addrs); err != nil {
+			conn.Close()
+			continue
+		}
+		l.mu.Lock()
+		l.headers = append(l.headers, append(header[len(proxyProtocolSignature):], addrs...))
+		l.mu.Unlock()
+		return conn, nil
+	}
+}
+
+func (l *proxyProtocolListener) get() [][]byte {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([][]byte(nil), l.headers...)
+}
+
+func newProxyProtocolServer(t *testing.T) (*proxyProtocolListener, string) {
+	t.Helper()
+	inner, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal("Failed to listen:", err)
+	}
+	l := &proxyProtocolListener{Listener: inner}
+	s := &http.Server{Handler: http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})}
+	go s.Serve(l)
+	t.Cleanup(func() { s.Close() })
+	return l, inner.Addr().String()
+}
+
+func TestWithProxyProtocol(t *testing.T) {
+	l, addr := newProxyProtocolServer(t)
+	port := netip.MustParseAddrPort(addr).Port()
+
+	tests := []struct {
+		name string
+		op   DialOption
+		want []byte
+	}{{
+		name: "local",
+		op:   WithProxyProtocol(),
+		want: []byte{proxyProtocolLocal, proxyProtocolUnspec, 0, 0},
+	}, {
+		name: "proxy ipv4",
+		op:   WithProxyProtocolSource(netip.MustParseAddrPort("10.0.0.1:4711")),
+		want: []byte{proxyProtocolProxy, proxyProtocolTCPv4, 0, 12,
+			10, 0, 0, 1, 127, 0, 0, 1, 0x12, 0x67, byte(port >> 8), byte(port)},
+	}, {
+		name: "proxy ipv6 source",
+		op:   WithProxyProtocolSource(netip.MustParseAddrPort("[2001:db8::1]:4711")),
+		want: append(append([]byte{proxyProtocolProxy, proxyProtocolTCPv6, 0, 36,
+			0x20, 0x01, 0x0d, 0xb8, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1},
+			netip.MustParseAddr("::ffff:127.0.0.1").AsSlice()...),
+			0x12, 0x67, byte(port>>8), byte(port)),
+	}}
+
+	for i, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ok, err := Do(context.Background(), network.NewProberTransport(), "http://example.com",
+				WithResolveTo(addr), test.op, ExpectsStatusCodes([]int{http.StatusOK}))
+			if !ok || err != nil {
+				t.Fatalf("Do() = %v, %v, want: true, nil", ok, err)
+			}
+			got := l.get()
+			if len(got) != i+1 {
+				t.Fatalf("Got %d headers, want: %d", len(got), i+1)
+			}
+			if !bytes.Equal(got[i], test.want) {
+				t.Errorf("Header = %v, want: %v", got[i], test.want)
+			}
+		})
+	}
+
+	// Without the header the connections are rejected.
+	if ok, err := Do(context.Background(), network.NewProberTransport(), "http://example.com",
+		WithResolveTo(addr), ExpectsStatusCodes([]int{http.StatusOK})); ok || err == nil {
+		t.Errorf("Do() = %v, %v, want an error", ok, err)
+	}
+}
+
+func TestWithProxyProtocolThroughProxy(t *testing.T) {
+	l, addr := newProxyProtocolServer(t)
+	proxy, _ := newSOCKS5Proxy(t, addr, "", "")
+
+	// The tunnel is established to a host name, so the destination is the
+	// remote address of the connection, i.e. the proxy.
+	ok, err := Do(context.Background(), network.NewProberTransport(), "http://example.com",
+		WithProxy(proxy), WithProxyProtocolSource(netip.MustParseAddrPort("10.0.0.1:4711")),
+		ExpectsStatusCodes([]int{http.StatusOK}))
+	if !ok || err != nil {
+		t.Fatalf("Do() = %v, %v, want: true, nil", ok, err)
+	}
+	proxyPort := netip.MustParseAddrPort(proxy.Host).Port()
+	want := [][]byte{{proxyProtocolProxy, proxyProtocolTCPv4, 0, 12,
+		10, 0, 0, 1, 127, 0, 0, 1, 0x12, 0x67, byte(proxyPort >> 8), byte(proxyPort)}}
+	if got := l.get(); len(got) != 1 || !bytes.Equal(got[0], want[0]) {
+		t.Errorf("Headers = %v, want: %v", got, want)
+	}
+}
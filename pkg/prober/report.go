@@ -0,0 +1,233 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package prober
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// redactedHeaders are the request headers whose values are not reported.
+var redactedHeaders = []string{"Authorization", "Cookie", "Proxy-Authorization"}
+
+// Report is the machine-readable outcome of a probe, e.g. for the e2e
+// harnesses to diff it against golden expectations, see DoReport.
+type Report struct {
+	// Name identifies the probe among a set, see DoReports.
+	Name string `json:"name,omitempty"`
+	// Target is the probed target.
+	Target string `json:"target"`
+	// Request is the probe request, or nil if none was sent, e.g. because
+	// the target is invalid or the probe was coalesced.
+	Request *RequestReport `json:"request,omitempty"`
+	// Attempts are the requests sent by the probe, see WithRequestMutator.
+	Attempts []AttemptReport `json:"attempts"`
+	// Success is the verdict of the probe, as returned by Do.
+	Success bool `json:"success"`
+	// Error is the error returned by Do, if any.
+	Error string `json:"error,omitempty"`
+	// Reason is the class of Error, see ErrorReason.
+	Reason string `json:"reason,omitempty"`
+}
+
+// RequestReport describes the probe request, once prepared. The values of
+// the headers carrying credentials are redacted.
+type RequestReport struct {
+	Method string `json:"method"`
+	URL    string `json:"url"`
+	// Host is the Host header, if it differs from the host of the URL.
+	Host   string      `json:"host,omitempty"`
+	Header http.Header `json:"header,omitempty"`
+}
+
+// AttemptReport is the result of a request sent by a probe.
+type AttemptReport struct {
+	// Attempt is the 1-based number of the attempt.
+	Attempt int `json:"attempt"`
+	// Status is the status code of the response, or 0 if none was received.
+	Status int `json:"status,omitempty"`
+	// Success is whether the response passed all the Verifiers.
+	Success bool `json:"success"`
+	// Error is the error of the first Verifier which failed, or "no
+	// response" if none was received.
+	Error string `json:"error,omitempty"`
+	// Duration is the time between the request being sent and its response
+	// being verified, in milliseconds.
+	Duration int64 `json:"durationMs"`
+
+	start  time.Time
+	failed bool
+}
+
+// StripDurations zeroes the durations of the attempts, which vary from one
+// run to another, e.g. to compare the report against a golden one.
+func (r *Report) StripDurations() {
+	for i := range r.Attempts {
+		r.Attempts[i].Duration = 0
+	}
+}
+
+// DoReport is like Do, but returns a Report of the probe rather than its
+// verdict. The attempts are reported in the order they were sent.
+func DoReport(ctx context.Context, transport http.RoundTripper, target string, ops ...interface{}) *Report {
+	rec := &reportRecorder{attempts: make(map[*http.Request]*AttemptReport)}
+	// The responses are recorded before any Verifier can reject them.
+	recOps := append(make([]interface{}, 0, len(ops)+2), Verifier(rec.response), RequestMutator(rec.mutate))
+	for _, op := range ops {
+		if v, ok := op.(Verifier); ok {
+			op = rec.verifier(v)
+		}
+		recOps = append(recOps, op)
+	}
+
+	ok, err := Do(ctx, transport, target, recOps...)
+	report := &Report{
+		Target:  target,
+		Success: ok,
+		Reason:  ErrorReason(err),
+	}
+	if err != nil {
+		report.Error = err.Error()
+	}
+
+	// The hedged requests which lost may still be in flight.
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	report.Request = rec.request
+	report.Attempts = make([]AttemptReport, 0, len(rec.order))
+	for _, a := range rec.order {
+		switch {
+		case a.Status == 0:
+			a.Error = "no response"
+		case a.Error == "" && !a.failed:
+			a.Success = true
+		}
+		report.Attempts = append(report.Attempts, *a)
+	}
+	return report
+}
+
+// ReportProbe is a probe of the set run by DoReports.
+type ReportProbe struct {
+	// Name identifies the probe in its Report.
+	Name   string
+	Target string
+	Ops    []interface{}
+}
+
+// DoReports sends the probes one after the other with DoReport, and returns
+// their Reports in the same order.
+func DoReports(ctx context.Context, transport http.RoundTripper, probes ...ReportProbe) []*Report {
+	reports := make([]*Report, 0, len(probes))
+	for _, p := range probes {
+		r := DoReport(ctx, transport, p.Target, p.Ops...)
+		r.Name = p.Name
+		reports = append(reports, r)
+	}
+	return reports
+}
+
+// WriteReports writes the reports to w as an indented JSON array.
+func WriteReports(w io.Writer, reports ...*Report) error {
+	if reports == nil {
+		reports = []*Report{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(reports)
+}
+
+// reportRecorder records the attempts of a probe.
+type reportRecorder struct {
+	mu       sync.Mutex
+	request  *RequestReport
+	attempts map[*http.Request]*AttemptReport
+	order    []*AttemptReport
+}
+
+// mutate records the start of an attempt, and the request of the first one.
+func (rec *reportRecorder) mutate(r *http.Request, attempt int) {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if rec.request == nil {
+		rec.request = &RequestReport{
+			Method: r.Method,
+			URL:    r.URL.String(),
+		}
+		if r.Host != r.URL.Host {
+			rec.request.Host = r.Host
+		}
+		if len(r.Header) > 0 {
+			rec.request.Header = r.Header.Clone()
+			for _, h := range redactedHeaders {
+				if _, ok := rec.request.Header[h]; ok {
+					rec.request.Header[h] = []string{"REDACTED"}
+				}
+			}
+		}
+	}
+	a := &AttemptReport{Attempt: attempt, start: time.Now()}
+	rec.attempts[r] = a
+	rec.order = append(rec.order, a)
+}
+
+// attemptLocked returns the attempt r answers. rec.mu must be held.
+func (rec *reportRecorder) attemptLocked(r *http.Response) *AttemptReport {
+	if a, ok := rec.attempts[r.Request]; ok {
+		return a
+	}
+	// The transport answered with another request, e.g. a clone of the one
+	// it was given, assume it answers the latest attempt.
+	if len(rec.order) > 0 {
+		return rec.order[len(rec.order)-1]
+	}
+	return nil
+}
+
+// response records the response of an attempt.
+func (rec *reportRecorder) response(r *http.Response, _ []byte) (bool, error) {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if a := rec.attemptLocked(r); a != nil {
+		a.Status = r.StatusCode
+		a.Duration = time.Since(a.start).Milliseconds()
+	}
+	return true, nil
+}
+
+// verifier wraps v to record its result in the attempt the response answers.
+func (rec *reportRecorder) verifier(v Verifier) Verifier {
+	return func(r *http.Response, b []byte) (bool, error) {
+		ok, err := v(r, b)
+		if ok && err == nil {
+			return ok, err
+		}
+		rec.mu.Lock()
+		defer rec.mu.Unlock()
+		if a := rec.attemptLocked(r); a != nil && a.Error == "" && !a.failed {
+			a.failed = true
+			if err != nil {
+				a.Error = err.Error()
+			}
+		}
+		return ok, err
+	}
+}
@@ -0,0 +1,225 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package prober
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"knative.dev/pkg/network"
+)
+
+func TestDoReport(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/unavailable" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("hello"))
+	}))
+	defer ts.Close()
+
+	// The refused target.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal("Failed to listen:", err)
+	}
+	refused := "http://" + l.Addr().String()
+	l.Close()
+
+	tests := []struct {
+		name   string
+		target string
+		ops    []interface{}
+		want   *Report
+	}{{
+		name:   "success",
+		target: ts.URL,
+		ops: []interface{}{
+			WithHost("foo.example.com"),
+			WithHeader("X-Foo", "bar"),
+			WithBearerToken("secret"),
+			ExpectsStatusCodes([]int{http.StatusOK}),
+			ExpectsBody("hello"),
+		},
+		want: &Report{
+			Target: ts.URL,
+			Request: &RequestReport{
+				Method: http.MethodGet,
+				URL:    ts.URL,
+				Host:   "foo.example.com",
+				Header: http.Header{
+					"Authorization": {"REDACTED"},
+					"X-Foo":         {"bar"},
+				},
+			},
+			Attempts: []AttemptReport{{Attempt: 1, Status: http.StatusOK, Success: true}},
+			Success:  true,
+		},
+	}, {
+		name:   "bad status",
+		target: ts.URL + "/unavailable",
+		ops:    []interface{}{ExpectsStatusCodes([]int{http.StatusOK})},
+		want: &Report{
+			Target:  ts.URL + "/unavailable",
+			Request: &RequestReport{Method: http.MethodGet, URL: ts.URL + "/unavailable"},
+			Attempts: []AttemptReport{{
+				Attempt: 1,
+				Status:  http.StatusServiceUnavailable,
+				Error:   "unexpected status code: want [200], got 503",
+			}},
+			Error:  `unexpected status code: want [200], got 503 (status: 503, body: "")`,
+			Reason: "BadStatus",
+		},
+	}, {
+		name:   "not verified",
+		target: ts.URL,
+		ops: []interface{}{Verifier(func(*http.Response, []byte) (bool, error) {
+			return false, nil
+		})},
+		want: &Report{
+			Target:   ts.URL,
+			Request:  &RequestReport{Method: http.MethodGet, URL: ts.URL},
+			Attempts: []AttemptReport{{Attempt: 1, Status: http.StatusOK}},
+		},
+	}, {
+		name:   "no response",
+		target: refused,
+		want: &Report{
+			Target:   refused,
+			Request:  &RequestReport{Method: http.MethodGet, URL: refused},
+			Attempts: []AttemptReport{{Attempt: 1, Error: "no response"}},
+			Reason:   "ConnRefused",
+		},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := DoReport(context.Background(), network.NewProberTransport(), test.target, test.ops...)
+			got.StripDurations()
+			if test.want.Reason == "ConnRefused" {
+				// The error message depends on the platform.
+				if !strings.Contains(got.Error, "connection refused") {
+					t.Errorf("Error = %q, want a connection refused error", got.Error)
+				}
+				got.Error = ""
+			}
+			if !cmp.Equal(test.want, got, cmpopts.IgnoreUnexported(AttemptReport{})) {
+				t.Error("DoReport (-want, +got) =", cmp.Diff(test.want, got, cmpopts.IgnoreUnexported(AttemptReport{})))
+			}
+		})
+	}
+}
+
+func TestDoReportWithRequestMutator(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+
+	got := DoReport(context.Background(), network.NewProberTransport(), ts.URL,
+		WithRequestMutator(func(r *http.Request, attempt int) {
+			r.Header.Set("X-Attempt", "set")
+		}),
+		ExpectsStatusCodes([]int{http.StatusOK}))
+	if !got.Success || len(got.Attempts) != 1 || !got.Attempts[0].Success {
+		t.Errorf("DoReport() = %+v, want a single successful attempt", got)
+	}
+}
+
+func TestWriteReports(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	reports := DoReports(context.Background(), network.NewProberTransport(), ReportProbe{
+		Name:   "ok",
+		Target: ts.URL,
+		Ops:    []interface{}{ExpectsStatusCodes([]int{http.StatusOK})},
+	}, ReportProbe{
+		Name:   "missing",
+		Target: ts.URL + "/missing",
+		Ops:    []interface{}{ExpectsStatusCodes([]int{http.StatusOK})},
+	})
+	for _, r := range reports {
+		r.StripDurations()
+	}
+
+	var buf bytes.Buffer
+	if err := WriteReports(&buf, reports...); err != nil {
+		t.Fatal("WriteReports() =", err)
+	}
+	want := strings.ReplaceAll(`[
+  {
+    "name": "ok",
+    "target": "URL",
+    "request": {
+      "method": "GET",
+      "url": "URL"
+    },
+    "attempts": [
+      {
+        "attempt": 1,
+        "status": 200,
+        "success": true,
+        "durationMs": 0
+      }
+    ],
+    "success": true
+  },
+  {
+    "name": "missing",
+    "target": "URL/missing",
+    "request": {
+      "method": "GET",
+      "url": "URL/missing"
+    },
+    "attempts": [
+      {
+        "attempt": 1,
+        "status": 404,
+        "success": false,
+        "error": "unexpected status code: want [200], got 404",
+        "durationMs": 0
+      }
+    ],
+    "success": false,
+    "error": "unexpected status code: want [200], got 404 (status: 404, body: \"\")",
+    "reason": "BadStatus"
+  }
+]
+`, "URL", ts.URL)
+	if got := buf.String(); got != want {
+		t.Error("WriteReports (-want, +got) =", cmp.Diff(want, got))
+	}
+
+	buf.Reset()
+	if err := WriteReports(&buf); err != nil {
+		t.Fatal("WriteReports() =", err)
+	}
+	if got, want := buf.String(), "[]\n"; got != want {
+		t.Errorf("WriteReports() = %q, want: %q", got, want)
+	}
+}
@@ -0,0 +1,199 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package prober
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// Timings is the breakdown of the time taken by an attempt of a probe, e.g.
+// to tell the rollouts stuck on slow TLS handshakes from the ones stuck on
+// slow backends. The phases which didn't happen, e.g. the DNS resolution of
+// an IP address or the connection of a reused connection, take no time.
+type Timings struct {
+	// DNS is the time taken to resolve the host.
+	DNS time.Duration
+	// Connect is the time taken to establish the TCP connection, including
+	// the attempts to the addresses which failed.
+	Connect time.Duration
+	// TLSHandshake is the time taken by the TLS handshake.
+	TLSHandshake time.Duration
+	// ServerProcessing is the time between the request being written and
+	// the first byte of the response being received.
+	ServerProcessing time.Duration
+	// TimeToFirstByte is the time between the start of the attempt and the
+	// first byte of the response being received.
+	TimeToFirstByte time.Duration
+	// Total is the time taken by the whole attempt, including reading the
+	// body of the response.
+	Total time.Duration
+	// ConnReused is whether the attempt was sent over a reused connection.
+	ConnReused bool
+}
+
+// String returns the phases of t, e.g. for logging.
+func (t Timings) String() string {
+	return fmt.Sprintf("dns: %v, connect: %v, tls: %v, server: %v, ttfb: %v, total: %v, reused: %t",
+		t.DNS, t.Connect, t.TLSHandshake, t.ServerProcessing, t.TimeToFirstByte, t.Total, t.ConnReused)
+}
+
+// TimingsCallback is a way for the caller to get the Timings of the
+// attempts of a probe.
+type TimingsCallback func(Timings)
+
+// WithTimings captures the Timings of each attempt of the probe. f is called
+// with the Timings of each attempt, whether it failed or not, and the errors
+// of the failed attempts carry their Timings, which TimingsOf returns. f may
+// be nil if only the errors matter. As with WithRequestMutator, each retry,
+// hedged request or request to one of the addresses of the target is an
+// attempt, and f may be called concurrently by such requests.
+func WithTimings(f func(Timings)) TimingsCallback {
+	return TimingsCallback(f)
+}
+
+// TimingsError is a probe failure along with the Timings of the attempt
+// which failed.
+type TimingsError struct {
+	err     error
+	timings Timings
+}
+
+// Error implements error.
+func (e *TimingsError) Error() string {
+	return fmt.Sprintf("%v (%v)", e.err, e.timings)
+}
+
+// Unwrap returns the failure, so that its class can be told with errors.Is.
+func (e *TimingsError) Unwrap() error {
+	return e.err
+}
+
+// Timings returns the Timings of the attempt which failed.
+func (e *TimingsError) Timings() Timings {
+	return e.timings
+}
+
+// TimingsOf returns the Timings carried by err, if the probe which failed
+// captured them with WithTimings.
+func TimingsOf(err error) (Timings, bool) {
+	var te *TimingsError
+	if errors.As(err, &te) {
+		return te.timings, true
+	}
+	return Timings{}, false
+}
+
+// timingTrace records the times of the phases of an attempt of a probe. The
+// hooks of the trace may be called concurrently, e.g. when dialing several
+// addresses at once.
+type timingTrace struct {
+	start time.Time
+
+	mu           sync.Mutex
+	dnsStart     time.Time
+	dnsDone      time.Time
+	connectStart time.Time
+	connectDone  time.Time
+	tlsStart     time.Time
+	tlsDone      time.Time
+	wroteRequest time.Time
+	firstByte    time.Time
+	reused       bool
+}
+
+func newTimingTrace() *timingTrace {
+	return &timingTrace{start: time.Now()}
+}
+
+// setOnce sets t to now unless it is already set.
+func (tt *timingTrace) setOnce(t *time.Time) {
+	now := time.Now()
+	tt.mu.Lock()
+	defer tt.mu.Unlock()
+	if t.IsZero() {
+		*t = now
+	}
+}
+
+// set sets t to now.
+func (tt *timingTrace) set(t *time.Time) {
+	now := time.Now()
+	tt.mu.Lock()
+	defer tt.mu.Unlock()
+	*t = now
+}
+
+// clientTrace returns the hooks recording the times of the phases.
+func (tt *timingTrace) clientTrace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		DNSStart:          func(httptrace.DNSStartInfo) { tt.setOnce(&tt.dnsStart) },
+		DNSDone:           func(httptrace.DNSDoneInfo) { tt.set(&tt.dnsDone) },
+		ConnectStart:      func(string, string) { tt.setOnce(&tt.connectStart) },
+		ConnectDone:       func(string, string, error) { tt.set(&tt.connectDone) },
+		TLSHandshakeStart: func() { tt.setOnce(&tt.tlsStart) },
+		TLSHandshakeDone:  func(tls.ConnectionState, error) { tt.set(&tt.tlsDone) },
+		GotConn: func(info httptrace.GotConnInfo) {
+			tt.mu.Lock()
+			defer tt.mu.Unlock()
+			tt.reused = info.Reused
+		},
+		WroteRequest:         func(httptrace.WroteRequestInfo) { tt.set(&tt.wroteRequest) },
+		GotFirstResponseByte: func() { tt.setOnce(&tt.firstByte) },
+	}
+}
+
+// timings returns the Timings of the attempt, which ended now.
+func (tt *timingTrace) timings() Timings {
+	now := time.Now()
+	tt.mu.Lock()
+	defer tt.mu.Unlock()
+	return Timings{
+		DNS:              between(tt.dnsStart, tt.dnsDone),
+		Connect:          between(tt.connectStart, tt.connectDone),
+		TLSHandshake:     between(tt.tlsStart, tt.tlsDone),
+		ServerProcessing: between(tt.wroteRequest, tt.firstByte),
+		TimeToFirstByte:  between(tt.start, tt.firstByte),
+		Total:            now.Sub(tt.start),
+		ConnReused:       tt.reused,
+	}
+}
+
+// between returns the time between from and to, or 0 if either didn't happen.
+func between(from, to time.Time) time.Duration {
+	if from.IsZero() || to.IsZero() || to.Before(from) {
+		return 0
+	}
+	return to.Sub(from)
+}
+
+// reportTimings calls the callbacks with t, and attaches t to err if any.
+func reportTimings(callbacks []TimingsCallback, t Timings, err error) error {
+	for _, f := range callbacks {
+		if f != nil {
+			f(t)
+		}
+	}
+	if err == nil {
+		return nil
+	}
+	return &TimingsError{err: err, timings: t}
+}
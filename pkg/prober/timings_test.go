@@ -0,0 +1,108 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package prober
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestWithTimings(t *testing.T) {
+	const delay = 50 * time.Millisecond
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(delay)
+	}))
+	defer ts.Close()
+	transport := &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
+	defer transport.CloseIdleConnections()
+
+	var got []Timings
+	record := WithTimings(func(t Timings) {
+		got = append(got, t)
+	})
+
+	if ok, err := Do(context.Background(), transport, ts.URL, record); !ok || err != nil {
+		t.Fatalf("Do() = %v, %v, want: true, nil", ok, err)
+	}
+	if len(got) != 1 {
+		t.Fatalf("Got %d timings, want: 1", len(got))
+	}
+	first := got[0]
+	if first.ConnReused {
+		t.Error("ConnReused = true for the first attempt")
+	}
+	if first.Connect <= 0 || first.TLSHandshake <= 0 {
+		t.Errorf("Connect, TLSHandshake = %v, %v, want both > 0", first.Connect, first.TLSHandshake)
+	}
+	if first.ServerProcessing < delay {
+		t.Errorf("ServerProcessing = %v, want >= %v", first.ServerProcessing, delay)
+	}
+	if first.TimeToFirstByte < first.ServerProcessing+first.TLSHandshake {
+		t.Errorf("TimeToFirstByte = %v, want >= %v", first.TimeToFirstByte, first.ServerProcessing+first.TLSHandshake)
+	}
+	if first.Total < first.TimeToFirstByte {
+		t.Errorf("Total = %v, want >= %v", first.Total, first.TimeToFirstByte)
+	}
+
+	// The failures carry the timings of the attempt, which still has its class.
+	ok, err := Do(context.Background(), transport, ts.URL, record, ExpectsStatusCodes([]int{http.StatusCreated}))
+	if ok || err == nil {
+		t.Fatalf("Do() = %v, %v, want: false, an error", ok, err)
+	}
+	if !errors.Is(err, ErrBadStatus) {
+		t.Errorf("Do() = %v, want: %v", err, ErrBadStatus)
+	}
+	var re *ResponseError
+	if !errors.As(err, &re) || re.StatusCode() != http.StatusOK {
+		t.Errorf("Do() = %v, want a ResponseError with status %d", err, http.StatusOK)
+	}
+	timings, ok := TimingsOf(err)
+	if !ok {
+		t.Fatalf("TimingsOf(%v) = _, false", err)
+	}
+	if len(got) != 2 || timings != got[1] {
+		t.Errorf("TimingsOf() = %v, want the timings of the last attempt in %v", timings, got)
+	}
+	// The connection of the first attempt is reused, so there's no new
+	// connection nor handshake.
+	if !timings.ConnReused || timings.Connect != 0 || timings.TLSHandshake != 0 {
+		t.Errorf("Timings = %v, want a reused connection", timings)
+	}
+}
+
+func TestTimingsOf(t *testing.T) {
+	if _, ok := TimingsOf(errors.New("no timings")); ok {
+		t.Error("TimingsOf() = _, true for an error without timings")
+	}
+
+	// The timings are only captured when asked for.
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+	_, err := Do(context.Background(), http.DefaultTransport, ts.URL, ExpectsStatusCodes([]int{http.StatusCreated}))
+	if _, ok := TimingsOf(err); ok {
+		t.Errorf("TimingsOf(%v) = _, true without WithTimings", err)
+	}
+	_, err = Do(context.Background(), http.DefaultTransport, ts.URL, WithTimings(nil), ExpectsStatusCodes([]int{http.StatusCreated}))
+	if timings, ok := TimingsOf(err); !ok || timings.Total <= 0 {
+		t.Errorf("TimingsOf(%v) = %v, %v, want the timings of the attempt", err, timings, ok)
+	}
+}
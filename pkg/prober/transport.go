@@ -0,0 +1,84 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package prober
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net/http"
+
+	"knative.dev/networking/pkg/config"
+	pkghttp "knative.dev/networking/pkg/http"
+	"knative.dev/pkg/network"
+)
+
+// Destination describes the endpoints probed with a transport.
+type Destination struct {
+	// ClusterLocal is whether the endpoints are reached from within the
+	// cluster, e.g. Pods or cluster-local gateways, whose traffic is
+	// encrypted when internal encryption is enabled.
+	ClusterLocal bool
+
+	// H2C is whether the endpoints only serve HTTP/2 over cleartext, e.g.
+	// gRPC backends, rather than also serving HTTP/1.
+	H2C bool
+
+	// ServerName is the name verified in the certificates of the endpoints
+	// when the traffic is encrypted, if different from the host of the
+	// probed URLs, e.g. when probing Pod IPs.
+	ServerName string
+}
+
+// NewTransport returns the transport to probe the destination with, given
+// the networking config, rather than hardcoding network.NewProberTransport
+// which breaks once internal encryption is enabled:
+//   - the cluster-local destinations are probed over TLS, verified with the
+//     roots of the cluster CA, when internal encryption is enabled,
+//   - the h2c destinations are probed with h2c,
+//   - the others are probed in plaintext, with h2c for the HTTP/2 probes.
+//
+// roots is only used, and then required, for encrypted destinations. Like
+// network.NewProberTransport, the transports don't keep the connections
+// alive.
+func NewTransport(cfg *config.Config, dest Destination, roots *x509.CertPool) (http.RoundTripper, error) {
+	switch {
+	case dest.ClusterLocal && cfg.InternalEncryption:
+		if roots == nil {
+			return nil, errors.New("internal encryption is enabled but the cluster CA is not known")
+		}
+		t := http.DefaultTransport.(*http.Transport).Clone()
+		t.DialContext = network.DialWithBackOff
+		t.DisableKeepAlives = true
+		t.TLSClientConfig = &tls.Config{
+			MinVersion: tls.VersionTLS12,
+			RootCAs:    roots,
+			ServerName: dest.ServerName,
+		}
+		if !dest.H2C {
+			// h2c destinations negotiate HTTP/2 through ALPN when
+			// encrypted, the others keep being probed over HTTP/1.
+			t.ForceAttemptHTTP2 = false
+			t.TLSClientConfig.NextProtos = []string{"http/1.1"}
+		}
+		return t, nil
+	case dest.H2C:
+		return pkghttp.NewH2CTransport(pkghttp.H2COptionsFromConfig(cfg)), nil
+	default:
+		return network.NewProberTransport(), nil
+	}
+}
@@ -0,0 +1,131 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package prober
+
+import (
+	"context"
+	"crypto/x509"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"knative.dev/networking/pkg/config"
+	pkghttp "knative.dev/networking/pkg/http"
+)
+
+// protoHandler answers with the major version of the protocol of the requests.
+var protoHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte(strconv.Itoa(r.ProtoMajor)))
+})
+
+func TestNewTransport(t *testing.T) {
+	plain := httptest.NewServer(protoHandler)
+	defer plain.Close()
+
+	h2cListener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal("Listen() =", err)
+	}
+	h2c := pkghttp.NewH2CServer("", protoHandler, pkghttp.H2COptions{})
+	go h2c.Serve(h2cListener)
+	defer h2c.Close()
+
+	encrypted := httptest.NewUnstartedServer(protoHandler)
+	encrypted.EnableHTTP2 = true
+	encrypted.StartTLS()
+	defer encrypted.Close()
+	roots := x509.NewCertPool()
+	roots.AddCert(encrypted.Certificate())
+
+	tests := []struct {
+		name       string
+		encryption bool
+		dest       Destination
+		url        string
+		wantProto  string
+	}{{
+		name:      "plaintext",
+		url:       plain.URL,
+		wantProto: "1",
+	}, {
+		name:       "external with internal encryption",
+		encryption: true,
+		url:        plain.URL,
+		wantProto:  "1",
+	}, {
+		name:      "h2c",
+		dest:      Destination{H2C: true},
+		url:       "http://" + h2cListener.Addr().String(),
+		wantProto: "2",
+	}, {
+		name:       "cluster-local with internal encryption",
+		encryption: true,
+		dest:       Destination{ClusterLocal: true},
+		url:        encrypted.URL,
+		wantProto:  "1",
+	}, {
+		name:       "cluster-local with internal encryption and server name",
+		encryption: true,
+		dest:       Destination{ClusterLocal: true, ServerName: "example.com"},
+		url:        encrypted.URL,
+		wantProto:  "1",
+	}, {
+		name:       "h2c with internal encryption",
+		encryption: true,
+		dest:       Destination{ClusterLocal: true, H2C: true},
+		url:        encrypted.URL,
+		wantProto:  "2",
+	}, {
+		name:      "cluster-local without internal encryption",
+		dest:      Destination{ClusterLocal: true},
+		url:       plain.URL,
+		wantProto: "1",
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := &config.Config{InternalEncryption: test.encryption}
+			transport, err := NewTransport(cfg, test.dest, roots)
+			if err != nil {
+				t.Fatal("NewTransport() =", err)
+			}
+			if ok, err := Do(context.Background(), transport, test.url, ExpectsBody(test.wantProto)); !ok || err != nil {
+				t.Errorf("Do() = %v, %v, want: true, nil", ok, err)
+			}
+		})
+	}
+}
+
+func TestNewTransportErrors(t *testing.T) {
+	cfg := &config.Config{InternalEncryption: true}
+	if _, err := NewTransport(cfg, Destination{ClusterLocal: true}, nil); err == nil {
+		t.Error("NewTransport() succeeded without the cluster CA, want error")
+	}
+
+	// The certificates not issued by the cluster CA are rejected.
+	ts := httptest.NewTLSServer(protoHandler)
+	defer ts.Close()
+	transport, err := NewTransport(cfg, Destination{ClusterLocal: true}, x509.NewCertPool())
+	if err != nil {
+		t.Fatal("NewTransport() =", err)
+	}
+	if ok, err := Do(context.Background(), transport, ts.URL); ok || err == nil {
+		t.Errorf("Do() = %v, %v, want: false, error", ok, err)
+	}
+}
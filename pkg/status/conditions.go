@@ -0,0 +1,160 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package status
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"go.uber.org/zap"
+	"k8s.io/apimachinery/pkg/types"
+	"knative.dev/networking/pkg/apis/networking/v1alpha1"
+)
+
+const (
+	// ProbesPendingReason is the reason of the LoadBalancerReady condition
+	// of the Ingresses whose current version isn't served by all the Pods
+	// yet.
+	ProbesPendingReason = "ProbesPending"
+
+	// maxPendingPods is the maximum number of Pods detailed in the message
+	// of the LoadBalancerReady condition, so that it stays readable.
+	maxPendingPods = 5
+)
+
+// NewConditionProber creates a Prober re-enqueueing the Ingresses with
+// enqueue, e.g. the EnqueueKey of the controller, whenever their probing
+// succeeds or the Pods or hosts still pending change, so that the reconciler
+// reflects the probing results on their status with MarkIngressStatus. The
+// progress callback given with WithProgressCallback, if any, is still called
+// on every progress.
+func NewConditionProber(
+	logger *zap.SugaredLogger,
+	targetLister ProbeTargetLister,
+	enqueue func(types.NamespacedName),
+	opts ...ProberOption) *Prober {
+	keyOf := func(ing *v1alpha1.Ingress) types.NamespacedName {
+		return types.NamespacedName{Namespace: ing.Namespace, Name: ing.Name}
+	}
+	var m *Prober
+	m = NewProber(logger, targetLister, func(ing *v1alpha1.Ingress) {
+		key := keyOf(ing)
+		func() {
+			m.mu.Lock()
+			defer m.mu.Unlock()
+			delete(m.pendingSets, key)
+		}()
+		enqueue(key)
+	}, opts...)
+	m.pendingSets = make(map[types.NamespacedName]string)
+
+	progress := m.progressCallback
+	m.progressCallback = func(s IngressProbeStatus) {
+		if progress != nil {
+			progress(s)
+		}
+		key, set := keyOf(s.Ingress), pendingSet(s)
+		changed := func() bool {
+			m.mu.Lock()
+			defer m.mu.Unlock()
+			if m.pendingSets[key] == set {
+				return false
+			}
+			m.pendingSets[key] = set
+			return true
+		}()
+		if changed {
+			enqueue(key)
+		}
+	}
+	return m
+}
+
+// MarkIngressStatus probes the Ingress and reflects the result on the
+// LoadBalancerReady condition of its status: ready with the given load
+// balancers once all the Pods serve its current version, pending with the
+// Pods and hosts not programmed yet, or the skipped Pods if none could be
+// probed, otherwise. It returns whether the Ingress
+// is ready. The status is left untouched if probing fails to start.
+func (m *Prober) MarkIngressStatus(ctx context.Context, ing *v1alpha1.Ingress,
+	publicLbs, privateLbs []v1alpha1.LoadBalancerIngressStatus) (bool, error) {
+	ready, err := m.IsReady(ctx, ing)
+	if err != nil {
+		return false, err
+	}
+	if ready {
+		ing.Status.MarkLoadBalancerReady(publicLbs, privateLbs)
+		return true, nil
+	}
+
+	status, ok := m.ProbeStatus(types.NamespacedName{Namespace: ing.Namespace, Name: ing.Name})
+	if !ok || status.Ready() {
+		// The probing just completed, the Ingress is enqueued again.
+		ing.Status.MarkLoadBalancerNotReady()
+		return false, nil
+	}
+	ing.Status.MarkLoadBalancerPending(ProbesPendingReason, pendingMessage(status))
+	return false, nil
+}
+
+// pendingSet summarizes the Pods and hosts of the status not programmed yet,
+// regardless of which hosts are pending on which Pod.
+func pendingSet(status IngressProbeStatus) string {
+	ips := make([]string, 0, len(status.NotReady))
+	for ip := range status.NotReady {
+		ips = append(ips, ip)
+	}
+	sort.Strings(ips)
+	return strings.Join(ips, ",") + "/" + strings.Join(status.Hosts().List(), ",")
+}
+
+// pendingMessage details the Pods and hosts of the status not programmed yet,
+// e.g. "Waiting for 2 of the hosts to be programmed on 1 Pod: 10.0.0.1
+// (a.example.com, b.example.com)".
+func pendingMessage(status IngressProbeStatus) string {
+	ips := make([]string, 0, len(status.NotReady))
+	for ip := range status.NotReady {
+		ips = append(ips, ip)
+	}
+	sort.Strings(ips)
+	if len(ips) == 0 {
+		// All the Pods were skipped.
+		return fmt.Sprintf("No Pod could be probed, skipped unprobeable Pods: %s", strings.Join(status.Skipped.List(), ", "))
+	}
+
+	pods := make([]string, 0, maxPendingPods)
+	for _, ip := range ips {
+		if len(pods) == maxPendingPods {
+			pods = append(pods, fmt.Sprintf("and %d more", len(ips)-maxPendingPods))
+			break
+		}
+		pods = append(pods, fmt.Sprintf("%s (%s)", ip, strings.Join(status.NotReady[ip].List(), ", ")))
+	}
+
+	noun := "Pods"
+	if len(ips) == 1 {
+		noun = "Pod"
+	}
+	msg := fmt.Sprintf("Waiting for %d of the hosts to be programmed on %d %s: %s",
+		status.Hosts().Len(), len(ips), noun, strings.Join(pods, ", "))
+	if status.Degraded() {
+		msg += fmt.Sprintf("; skipped unprobeable Pods: %s", strings.Join(status.Skipped.List(), ", "))
+	}
+	return msg
+}
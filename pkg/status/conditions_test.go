@@ -0,0 +1,258 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package status
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/atomic"
+	"go.uber.org/zap/zaptest"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"knative.dev/networking/pkg/apis/networking/v1alpha1"
+	"knative.dev/networking/pkg/http/header"
+	"knative.dev/networking/pkg/http/probe"
+	"knative.dev/networking/pkg/ingress"
+)
+
+func TestMarkIngressStatus(t *testing.T) {
+	const hostA = "foo.bar.com"
+	const hostB = "ksvc.test.dev"
+	var hostBEnabled atomic.Bool
+
+	ing := ingTemplate.DeepCopy()
+	ing.Spec.Rules[0].Hosts = append(ing.Spec.Rules[0].Hosts, hostB)
+	hash, err := ingress.InsertProbe(ing.DeepCopy())
+	if err != nil {
+		t.Fatal("Failed to insert probe:", err)
+	}
+
+	// Probes to hostA always succeed and probes to hostB only succeed if hostBEnabled is true
+	probeHandler := probe.NewHandler(http.NotFoundHandler())
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Host, hostA) &&
+			(!hostBEnabled.Load() || !strings.HasPrefix(r.Host, hostB)) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		r.Header.Set(header.HashKey, hash)
+		probeHandler.ServeHTTP(w, r)
+	}))
+	defer ts.Close()
+	tsURL, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatalf("Failed to parse URL %q: %v", ts.URL, err)
+	}
+	ip := tsURL.Hostname()
+
+	progress := make(chan IngressProbeStatus, 10)
+	enqueued := make(chan types.NamespacedName, 10)
+	prober := NewConditionProber(
+		zaptest.NewLogger(t).Sugar(),
+		fakeProbeTargetLister{{
+			PodIPs:  sets.NewString(ip),
+			PodPort: tsURL.Port(),
+			URLs:    []*url.URL{tsURL},
+		}},
+		func(key types.NamespacedName) {
+			enqueued <- key
+		},
+		WithProgressCallback(func(status IngressProbeStatus) {
+			progress <- status
+		}))
+
+	done := make(chan struct{})
+	cancelled := prober.Start(done)
+	defer func() {
+		close(done)
+		<-cancelled
+	}()
+
+	key := types.NamespacedName{Namespace: ing.Namespace, Name: ing.Name}
+	lbs := []v1alpha1.LoadBalancerIngressStatus{{DomainInternal: "gateway.default.svc"}}
+	ing.Status.InitializeConditions()
+	if ready, err := prober.MarkIngressStatus(context.Background(), ing, lbs, lbs); err != nil || ready {
+		t.Fatalf("MarkIngressStatus() = %t, %v, want: false, nil", ready, err)
+	}
+	cond := ing.Status.GetCondition(v1alpha1.IngressConditionLoadBalancerReady)
+	if cond.Status != corev1.ConditionUnknown || cond.Reason != ProbesPendingReason {
+		t.Errorf("LoadBalancerReady = %s %s, want: %s %s", cond.Status, cond.Reason, corev1.ConditionUnknown, ProbesPendingReason)
+	}
+
+	// hostA gets programmed first, the Ingress is enqueued.
+	waitEnqueued := func() {
+		t.Helper()
+		select {
+		case got := <-enqueued:
+			if got != key {
+				t.Errorf("Enqueued %v, want: %v", got, key)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("Timed out waiting for the Ingress to be enqueued")
+		}
+	}
+	waitEnqueued()
+	if _, err := prober.MarkIngressStatus(context.Background(), ing, lbs, lbs); err != nil {
+		t.Fatal("MarkIngressStatus failed:", err)
+	}
+	cond = ing.Status.GetCondition(v1alpha1.IngressConditionLoadBalancerReady)
+	if want := "Waiting for 1 of the hosts to be programmed on 1 Pod: " + ip + " (" + hostB + ")"; cond.Message != want {
+		t.Errorf("Message = %q, want: %q", cond.Message, want)
+	}
+	// The progress callback is still called.
+	select {
+	case <-progress:
+	default:
+		t.Error("The progress callback wasn't called")
+	}
+
+	// Then hostB, the Ingress is enqueued and becomes ready.
+	hostBEnabled.Store(true)
+	waitEnqueued()
+	if err := wait.PollImmediate(10*time.Millisecond, 5*time.Second, func() (bool, error) {
+		return prober.MarkIngressStatus(context.Background(), ing, lbs, nil)
+	}); err != nil {
+		t.Fatal("The Ingress never became ready:", err)
+	}
+	if !ing.Status.GetCondition(v1alpha1.IngressConditionLoadBalancerReady).IsTrue() {
+		t.Error("LoadBalancerReady is not true")
+	}
+	if got := ing.Status.PublicLoadBalancer.Ingress; len(got) != 1 || got[0] != lbs[0] {
+		t.Errorf("PublicLoadBalancer = %v, want: %v", got, lbs)
+	}
+}
+
+func TestMarkIngressStatusListerFail(t *testing.T) {
+	prober := NewConditionProber(zaptest.NewLogger(t).Sugar(), notFoundLister{}, func(types.NamespacedName) {})
+	ing := ingTemplate.DeepCopy()
+	ing.Status.InitializeConditions()
+	want := ing.Status.DeepCopy()
+	if _, err := prober.MarkIngressStatus(context.Background(), ing, nil, nil); err == nil {
+		t.Error("MarkIngressStatus() = nil, wanted an error")
+	}
+	if got := ing.Status.GetCondition(v1alpha1.IngressConditionLoadBalancerReady); got.Reason != want.GetCondition(v1alpha1.IngressConditionLoadBalancerReady).Reason {
+		t.Errorf("LoadBalancerReady changed to %v", got)
+	}
+}
+
+func TestPendingMessage(t *testing.T) {
+	tests := []struct {
+		name   string
+		status IngressProbeStatus
+		want   string
+	}{{
+		name: "several pods",
+		status: IngressProbeStatus{NotReady: map[string]sets.String{
+			"10.0.0.2": sets.NewString("b.example.com"),
+			"10.0.0.1": sets.NewString("b.example.com", "a.example.com"),
+		}},
+		want: "Waiting for 2 of the hosts to be programmed on 2 Pods: 10.0.0.1 (a.example.com, b.example.com), 10.0.0.2 (b.example.com)",
+	}, {
+		name: "many pods",
+		status: IngressProbeStatus{NotReady: map[string]sets.String{
+			"10.0.0.1": sets.NewString("a.example.com"),
+			"10.0.0.2": sets.NewString("a.example.com"),
+			"10.0.0.3": sets.NewString("a.example.com"),
+			"10.0.0.4": sets.NewString("a.example.com"),
+			"10.0.0.5": sets.NewString("a.example.com"),
+			"10.0.0.6": sets.NewString("a.example.com"),
+			"10.0.0.7": sets.NewString("a.example.com"),
+		}},
+		want: "Waiting for 1 of the hosts to be programmed on 7 Pods: 10.0.0.1 (a.example.com), 10.0.0.2 (a.example.com), " +
+			"10.0.0.3 (a.example.com), 10.0.0.4 (a.example.com), 10.0.0.5 (a.example.com), and 2 more",
+	}, {
+		name: "skipped pods",
+		status: IngressProbeStatus{
+			NotReady: map[string]sets.String{"10.0.0.1": sets.NewString("a.example.com")},
+			Skipped:  sets.NewString("10.0.0.9", "10.0.0.8"),
+		},
+		want: "Waiting for 1 of the hosts to be programmed on 1 Pod: 10.0.0.1 (a.example.com); skipped unprobeable Pods: 10.0.0.8, 10.0.0.9",
+	}, {
+		name:   "all pods skipped",
+		status: IngressProbeStatus{Skipped: sets.NewString("10.0.0.9", "10.0.0.8")},
+		want:   "No Pod could be probed, skipped unprobeable Pods: 10.0.0.8, 10.0.0.9",
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := pendingMessage(test.status); got != test.want {
+				t.Errorf("pendingMessage() = %q, want: %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestConditionProberEnqueuesPendingChanges(t *testing.T) {
+	var enqueued int
+	prober := NewConditionProber(zaptest.NewLogger(t).Sugar(), notFoundLister{}, func(types.NamespacedName) {
+		enqueued++
+	})
+	ing := ingTemplate.DeepCopy()
+
+	steps := []struct {
+		name     string
+		notReady map[string]sets.String
+		want     int
+	}{{
+		name: "first progress",
+		notReady: map[string]sets.String{
+			"10.0.0.1": sets.NewString("a.example.com", "b.example.com"),
+			"10.0.0.2": sets.NewString("a.example.com", "b.example.com"),
+		},
+		want: 1,
+	}, {
+		name: "same pods and hosts",
+		notReady: map[string]sets.String{
+			"10.0.0.1": sets.NewString("a.example.com"),
+			"10.0.0.2": sets.NewString("a.example.com", "b.example.com"),
+		},
+		want: 1,
+	}, {
+		name: "fewer pods",
+		notReady: map[string]sets.String{
+			"10.0.0.2": sets.NewString("a.example.com", "b.example.com"),
+		},
+		want: 2,
+	}, {
+		name: "fewer hosts",
+		notReady: map[string]sets.String{
+			"10.0.0.2": sets.NewString("b.example.com"),
+		},
+		want: 3,
+	}}
+	for _, step := range steps {
+		prober.progressCallback(IngressProbeStatus{Ingress: ing, NotReady: step.notReady})
+		if enqueued != step.want {
+			t.Errorf("%s: enqueued %d times, want: %d", step.name, enqueued, step.want)
+		}
+	}
+
+	// Once ready, the next probing round is enqueued again.
+	prober.readyCallback(ing)
+	prober.progressCallback(IngressProbeStatus{Ingress: ing, NotReady: steps[len(steps)-1].notReady})
+	if want := 5; enqueued != want {
+		t.Errorf("Enqueued %d times, want: %d", enqueued, want)
+	}
+}
@@ -0,0 +1,48 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package status
+
+import (
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"knative.dev/pkg/metrics"
+)
+
+var (
+	skippedPodsM = stats.Int64(
+		"skipped_pod_count",
+		"Number of times a gateway Pod IP was marked as unprobeable",
+		stats.UnitDimensionless)
+	skippedProbesM = stats.Int64(
+		"skipped_probe_count",
+		"Number of Ingress probes not sent or abandoned because their gateway Pod IP was marked as unprobeable",
+		stats.UnitDimensionless)
+)
+
+func init() {
+	if err := metrics.RegisterResourceView(&view.View{
+		Description: skippedPodsM.Description(),
+		Measure:     skippedPodsM,
+		Aggregation: view.Count(),
+	}, &view.View{
+		Description: skippedProbesM.Description(),
+		Measure:     skippedProbesM,
+		Aggregation: view.Sum(),
+	}); err != nil {
+		panic(err)
+	}
+}
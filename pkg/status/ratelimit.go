@@ -0,0 +1,47 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package status
+
+import (
+	"time"
+
+	"k8s.io/client-go/util/workqueue"
+)
+
+// ingressRateLimiter rate limits the work items per Ingress, with the token
+// bucket of the state of their Ingress. The buckets go away with the states
+// of the Ingresses, so there is nothing to forget.
+type ingressRateLimiter struct{}
+
+var _ workqueue.RateLimiter = ingressRateLimiter{}
+
+// When implements workqueue.RateLimiter.
+func (ingressRateLimiter) When(item interface{}) time.Duration {
+	wi, ok := item.(*workItem)
+	if !ok {
+		return 0
+	}
+	return wi.ingressState.retryLimiter.Reserve().Delay()
+}
+
+// Forget implements workqueue.RateLimiter.
+func (ingressRateLimiter) Forget(interface{}) {}
+
+// NumRequeues implements workqueue.RateLimiter.
+func (ingressRateLimiter) NumRequeues(interface{}) int {
+	return 0
+}
@@ -0,0 +1,89 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package status
+
+import (
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// skipList is the set of the Pod IPs not to probe, each of them until its
+// TTL expires.
+type skipList struct {
+	// now returns the current time, replaced in tests.
+	now func() time.Time
+
+	mu       sync.Mutex
+	expiries map[string]time.Time
+}
+
+func newSkipList() *skipList {
+	return &skipList{
+		now:      time.Now,
+		expiries: make(map[string]time.Time),
+	}
+}
+
+// add skips the IP for ttl, extending or shortening the TTL of an IP already
+// skipped.
+func (s *skipList) add(ip string, ttl time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expiries[ip] = s.now().Add(ttl)
+}
+
+// remove stops skipping the IPs.
+func (s *skipList) remove(ips ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, ip := range ips {
+		delete(s.expiries, ip)
+	}
+}
+
+// has returns whether the IP is skipped, forgetting it if its TTL expired.
+func (s *skipList) has(ip string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	expiry, ok := s.expiries[ip]
+	if !ok {
+		return false
+	}
+	if !s.now().Before(expiry) {
+		delete(s.expiries, ip)
+		return false
+	}
+	return true
+}
+
+// list returns the IPs skipped, forgetting the ones whose TTL expired.
+func (s *skipList) list() sets.String {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	ips := sets.NewString()
+	for ip, expiry := range s.expiries {
+		if now.Before(expiry) {
+			ips.Insert(ip)
+		} else {
+			delete(s.expiries, ip)
+		}
+	}
+	return ips
+}
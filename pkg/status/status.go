@@ -41,9 +41,11 @@ import (
 	nethttp "knative.dev/networking/pkg/http"
 	"knative.dev/networking/pkg/http/header"
 	"knative.dev/networking/pkg/ingress"
+	"knative.dev/networking/pkg/k8s"
 	"knative.dev/networking/pkg/prober"
 	"knative.dev/pkg/kmeta"
 	"knative.dev/pkg/logging"
+	"knative.dev/pkg/metrics"
 )
 
 const (
@@ -54,27 +56,119 @@ const (
 	// initialDelay defines the delay before enqueuing a probing request the first time.
 	// It gives times for the change to propagate and prevents unnecessary retries.
 	initialDelay = 200 * time.Millisecond
+	// ingressRetryQPS and ingressRetryBurst bound the rate of the retries of
+	// the probes of a single Ingress, so that an Ingress served by many Pods
+	// or with many hosts doesn't starve the others.
+	ingressRetryQPS   = 5
+	ingressRetryBurst = 50
 )
 
-var dialContext = (&net.Dialer{Timeout: probeTimeout}).DialContext
-
 // ingressState represents the probing state of an Ingress
 type ingressState struct {
 	hash string
 	ing  *v1alpha1.Ingress
 
+	// context is cancelled when the Ingress is not probed anymore.
+	context context.Context
+	// podStates are the probing states of the Pods serving the Ingress.
+	podStates []*podState
+	// retryLimiter rate limits the retries of the probes of the Ingress.
+	retryLimiter *rate.Limiter
+
 	// pendingCount is the number of pods that haven't been successfully probed yet
 	pendingCount atomic.Int32
 	lastAccessed time.Time
 
+	// mu guards pendingHosts, skipped and probed
+	mu sync.Mutex
+	// pendingHosts is the number of probes not yet successful per Pod IP and host
+	pendingHosts map[podHost]int
+	// skipped are the Pod IPs not probed because they were marked as
+	// unprobeable
+	skipped sets.String
+	// probed is whether at least one Pod was probed successfully
+	probed bool
+	// notifyMu serializes the calls to the progress callback, so that they
+	// are not reordered
+	notifyMu sync.Mutex
+
 	cancel func()
 }
 
+// ready returns whether all the Pods serving the Ingress were probed, at
+// least one of them successfully unless none was skipped: an Ingress whose
+// Pods were all skipped is not known to be served at all.
+func (s *ingressState) ready() bool {
+	if s.pendingCount.Load() != 0 {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.probed || s.skipped.Len() == 0
+}
+
+// podHost identifies a host probed on a Pod
+type podHost struct {
+	ip   string
+	host string
+}
+
+// IngressProbeStatus is the probing status of an Ingress per Pod and host.
+type IngressProbeStatus struct {
+	// Ingress is the version of the Ingress being probed.
+	Ingress *v1alpha1.Ingress
+
+	// NotReady lists, per Pod IP, the hosts the Pod hasn't been found to be
+	// serving the current version of the Ingress for yet, i.e. the hosts not
+	// yet programmed on that Pod.
+	NotReady map[string]sets.String
+
+	// Skipped lists the IPs of the Pods which were not probed, or whose
+	// probing was abandoned, because they were marked as unprobeable, see
+	// Prober.SkipPod. They don't hold the Ingress back from being ready as
+	// long as another Pod was probed successfully, but may not serve its
+	// current version.
+	Skipped sets.String
+
+	// Probed is whether at least one Pod was found to serve the current
+	// version of the Ingress.
+	Probed bool
+}
+
+// Ready returns whether all the hosts of the Ingress are programmed on all
+// the Pods, at least one Pod having been probed successfully if some were
+// skipped.
+func (s IngressProbeStatus) Ready() bool {
+	return len(s.NotReady) == 0 && (s.Probed || !s.Degraded())
+}
+
+// Degraded returns whether some Pods were skipped, the Ingress possibly not
+// being programmed on all of them even once ready.
+func (s IngressProbeStatus) Degraded() bool {
+	return len(s.Skipped) > 0
+}
+
+// Hosts returns the hosts not yet programmed on at least one Pod.
+func (s IngressProbeStatus) Hosts() sets.String {
+	hosts := sets.NewString()
+	for _, h := range s.NotReady {
+		hosts = hosts.Union(h)
+	}
+	return hosts
+}
+
 // podState represents the probing state of a Pod (for a specific Ingress)
 type podState struct {
+	ip           string
+	ingressState *ingressState
+
 	// pendingCount is the number of probes for the Pod
 	pendingCount atomic.Int32
 
+	// ipContext is cancelled when the probing of the Pod IP is cancelled,
+	// for all the Ingresses.
+	ipContext context.Context
+
 	cancel func()
 }
 
@@ -82,6 +176,10 @@ type podState struct {
 type cancelContext struct {
 	context context.Context
 	cancel  func()
+
+	// podStates are the probing states of the Ingresses on the Pod IP,
+	// notified when the probing of the Pod IP is cancelled.
+	podStates map[*podState]struct{}
 }
 
 type workItem struct {
@@ -125,19 +223,78 @@ type Prober struct {
 
 	workQueue workqueue.RateLimitingInterface
 
+	// transport is the transport the probes are derived from.
+	transport *http.Transport
+
 	targetLister ProbeTargetLister
 
 	readyCallback func(*v1alpha1.Ingress)
 
+	progressCallback func(IngressProbeStatus)
+
 	probeConcurrency int
+
+	// skipped are the Pod IPs not to probe.
+	skipped *skipList
+	// skipThreshold is the number of consecutive probes of a Pod IP failing
+	// to get a response after which the Pod IP is skipped for skipTTL, or 0
+	// to never skip the Pod IPs automatically.
+	skipThreshold int
+	skipTTL       time.Duration
+	// failures is the number of consecutive probes which failed to get a
+	// response per Pod IP, guarded by mu.
+	failures map[string]int
+
+	// pendingSets summarizes, per Ingress, the Pods and hosts pending when
+	// it was last enqueued by NewConditionProber, guarded by mu.
+	pendingSets map[types.NamespacedName]string
+}
+
+// ProberOption configures a Prober.
+type ProberOption func(*Prober)
+
+// WithProgressCallback registers a callback invoked with the probing status
+// of an Ingress every time hosts get programmed on a Pod, e.g. to surface
+// the hosts still not programmed through conditions or events. The callback
+// must not block.
+func WithProgressCallback(cb func(IngressProbeStatus)) ProberOption {
+	return func(m *Prober) {
+		m.progressCallback = cb
+	}
 }
 
-// NewProber creates a new instance of Prober
+// WithProbeConcurrency sets the number of workers probing the Pods, i.e. the
+// maximum number of probes in flight, 15 by default.
+func WithProbeConcurrency(n int) ProberOption {
+	return func(m *Prober) {
+		m.probeConcurrency = n
+	}
+}
+
+// WithUnreachablePodSkipping marks the Pod IPs as unprobeable for ttl, as
+// SkipPod does, once threshold probes in a row, for any Ingress, failed to get
+// a response from them, so that e.g. a crash-looping gateway Pod doesn't hold
+// all the Ingresses back from being ready.
+func WithUnreachablePodSkipping(threshold int, ttl time.Duration) ProberOption {
+	return func(m *Prober) {
+		m.skipThreshold = threshold
+		m.skipTTL = ttl
+	}
+}
+
+// NewProber creates a new instance of Prober.
+//
+// The probes are processed by a bounded pool of workers draining a work
+// queue, retrying the failed probes with a per-probe exponential backoff,
+// bounded per Ingress and globally. No goroutine is started per Ingress or
+// Pod being probed, so that the goroutine count stays flat however many
+// Ingresses are probed.
 func NewProber(
 	logger *zap.SugaredLogger,
 	targetLister ProbeTargetLister,
-	readyCallback func(*v1alpha1.Ingress)) *Prober {
-	return &Prober{
+	readyCallback func(*v1alpha1.Ingress),
+	opts ...ProberOption) *Prober {
+	m := &Prober{
 		logger:        logger,
 		ingressStates: make(map[types.NamespacedName]*ingressState),
 		podContexts:   make(map[string]cancelContext),
@@ -145,14 +302,23 @@ func NewProber(
 			workqueue.NewMaxOfRateLimiter(
 				// Per item exponential backoff
 				workqueue.NewItemExponentialFailureRateLimiter(50*time.Millisecond, 30*time.Second),
+				// Per Ingress rate limiter
+				ingressRateLimiter{},
 				// Global rate limiter
 				&workqueue.BucketRateLimiter{Limiter: rate.NewLimiter(rate.Limit(50), 100)},
 			),
 			"ProbingQueue"),
+		transport:        newTransport(),
 		targetLister:     targetLister,
 		readyCallback:    readyCallback,
 		probeConcurrency: probeConcurrency,
+		skipped:          newSkipList(),
+		failures:         make(map[string]int),
+	}
+	for _, opt := range opts {
+		opt(m)
 	}
+	return m
 }
 
 // IsReady checks if the provided Ingress is ready, i.e. the Envoy pods serving the Ingress
@@ -176,12 +342,19 @@ func (m *Prober) IsReady(ctx context.Context, ing *v1alpha1.Ingress) (bool, erro
 		if state, ok := m.ingressStates[ingressKey]; ok {
 			if state.hash == hash {
 				state.lastAccessed = time.Now()
-				return state.pendingCount.Load() == 0, true
+				if state.pendingCount.Load() != 0 {
+					return false, true
+				}
+				if state.ready() {
+					return true, true
+				}
+				// All the Pods were skipped, probe them again as some may
+				// not be skipped anymore.
 			}
 
-			// Cancel the polling for the outdated version
-			state.cancel()
-			delete(m.ingressStates, ingressKey)
+			// Cancel the polling for the outdated version, or of the
+			// skipped Pods
+			m.cancelIngressProbingLocked(ingressKey, state)
 		}
 		return false, false
 	}(); ok {
@@ -192,7 +365,11 @@ func (m *Prober) IsReady(ctx context.Context, ing *v1alpha1.Ingress) (bool, erro
 	ingressState := &ingressState{
 		hash:         hash,
 		ing:          ing,
+		context:      ingCtx,
+		retryLimiter: rate.NewLimiter(ingressRetryQPS, ingressRetryBurst),
 		lastAccessed: time.Now(),
+		pendingHosts: make(map[podHost]int),
+		skipped:      sets.NewString(),
 		cancel:       cancel,
 	}
 
@@ -204,6 +381,11 @@ func (m *Prober) IsReady(ctx context.Context, ing *v1alpha1.Ingress) (bool, erro
 	workItems := make(map[string][]*workItem)
 	for _, target := range targets {
 		for ip := range target.PodIPs {
+			if m.skipped.has(ip) {
+				ingressState.skipped.Insert(ip)
+				metrics.Record(ctx, skippedProbesM.M(int64(len(target.URLs))))
+				continue
+			}
 			for _, url := range target.URLs {
 				workItems[ip] = append(workItems[ip], &workItem{
 					ingressState: ingressState,
@@ -212,56 +394,47 @@ func (m *Prober) IsReady(ctx context.Context, ing *v1alpha1.Ingress) (bool, erro
 					podPort:      target.PodPort,
 					logger:       logger,
 				})
+				ingressState.pendingHosts[podHost{ip: ip, host: url.Hostname()}]++
 			}
 		}
 	}
 
+	if ingressState.skipped.Len() > 0 {
+		logger.Warnf("Not probing the unprobeable IPs %v", ingressState.skipped.List())
+	}
 	ingressState.pendingCount.Store(int32(len(workItems)))
 
 	for ip, ipWorkItems := range workItems {
-		// Get or create the context for that IP
-		ipCtx := func() context.Context {
+		podCtx, cancel := context.WithCancel(ingCtx)
+		ps := &podState{
+			ip:           ip,
+			ingressState: ingressState,
+			pendingCount: *atomic.NewInt32(int32(len(ipWorkItems))),
+			cancel:       cancel,
+		}
+		ingressState.podStates = append(ingressState.podStates, ps)
+
+		// Get or create the context for that IP, and register the Pod to be
+		// notified of its cancellation.
+		func() {
 			m.mu.Lock()
 			defer m.mu.Unlock()
 			cancelCtx, ok := m.podContexts[ip]
 			if !ok {
 				ctx, cancel := context.WithCancel(context.Background())
 				cancelCtx = cancelContext{
-					context: ctx,
-					cancel:  cancel,
+					context:   ctx,
+					cancel:    cancel,
+					podStates: make(map[*podState]struct{}),
 				}
 				m.podContexts[ip] = cancelCtx
 			}
-			return cancelCtx.context
-		}()
-
-		podCtx, cancel := context.WithCancel(ingCtx)
-		podState := &podState{
-			pendingCount: *atomic.NewInt32(int32(len(ipWorkItems))),
-			cancel:       cancel,
-		}
-
-		// Quick and dirty way to join two contexts (i.e. podCtx is cancelled when either ingCtx or ipCtx are cancelled)
-		go func() {
-			select {
-			case <-podCtx.Done():
-				// This is the actual context, there is nothing to do except
-				// break to avoid leaking this goroutine.
-				break
-			case <-ipCtx.Done():
-				// Cancel podCtx
-				cancel()
-			}
-		}()
-
-		// Update the states when probing is cancelled
-		go func() {
-			<-podCtx.Done()
-			m.onProbingCancellation(ingressState, podState)
+			cancelCtx.podStates[ps] = struct{}{}
+			ps.ipContext = cancelCtx.context
 		}()
 
 		for _, wi := range ipWorkItems {
-			wi.podState = podState
+			wi.podState = ps
 			wi.context = podCtx
 			m.workQueue.AddAfter(wi, initialDelay)
 			logger.Infof("Queuing probe for %s, IP: %s:%s (depth: %d)",
@@ -274,7 +447,21 @@ func (m *Prober) IsReady(ctx context.Context, ing *v1alpha1.Ingress) (bool, erro
 		defer m.mu.Unlock()
 		m.ingressStates[ingressKey] = ingressState
 	}()
-	return len(workItems) == 0, nil
+	return len(workItems) == 0 && ingressState.skipped.Len() == 0, nil
+}
+
+// ProbeStatus returns the probing status of the current version of the
+// Ingress identified by the provided key, if it is being probed.
+func (m *Prober) ProbeStatus(key types.NamespacedName) (IngressProbeStatus, bool) {
+	m.mu.Lock()
+	state, ok := m.ingressStates[key]
+	m.mu.Unlock()
+	if !ok {
+		return IngressProbeStatus{}, false
+	}
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	return state.statusLocked(), true
 }
 
 // Start starts the Manager background operations
@@ -322,8 +509,28 @@ func (m *Prober) CancelIngressProbingByKey(key types.NamespacedName) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	if state, ok := m.ingressStates[key]; ok {
-		state.cancel()
-		delete(m.ingressStates, key)
+		m.cancelIngressProbingLocked(key, state)
+	}
+}
+
+// cancelIngressProbingLocked cancels probing of the Ingress, dropping its
+// state without notifying its readiness.
+// mu must be held.
+func (m *Prober) cancelIngressProbingLocked(key types.NamespacedName, state *ingressState) {
+	state.cancel()
+	for _, ps := range state.podStates {
+		m.unregisterPodLocked(ps)
+	}
+	delete(m.ingressStates, key)
+	delete(m.pendingSets, key)
+}
+
+// unregisterPodLocked stops notifying the Pod of the cancellation of the
+// probing of its IP.
+// mu must be held.
+func (m *Prober) unregisterPodLocked(ps *podState) {
+	if ctx, ok := m.podContexts[ps.ip]; ok {
+		delete(ctx.podStates, ps)
 	}
 }
 
@@ -332,14 +539,78 @@ func (m *Prober) CancelIngressProbingByKey(key types.NamespacedName) {
 // TODO(#6269): make this cancellation based on Pod x port instead of just Pod.
 func (m *Prober) CancelPodProbing(obj interface{}) {
 	if pod, ok := obj.(*corev1.Pod); ok {
+		m.cancelPodProbing(pod.Status.PodIP)
+	}
+}
+
+// CancelRemovedAddressProbing cancels probing of the Pod IPs which are not ready
+// anymore. It can be registered as a k8s.ReadyAddressesCallback.
+func (m *Prober) CancelRemovedAddressProbing(change k8s.ReadyAddressesChange) {
+	m.cancelPodProbing(change.Removed.UnsortedList()...)
+}
+
+// SkipPod marks the Pod IP as unprobeable for ttl, e.g. because the gateway
+// Pod is known to be unreachable. The Pod is then considered ready for all
+// the Ingresses it is being probed for, and isn't probed for the Ingresses
+// whose probing starts before ttl elapses, which are reported as degraded by
+// their IngressProbeStatus instead. The Ingresses whose Pods were all skipped
+// are not ready until one of them is probed successfully. The Pod IP is probed again once ttl
+// elapsed, or once it is removed by CancelPodProbing or
+// CancelRemovedAddressProbing, e.g. to be reused by another Pod.
+func (m *Prober) SkipPod(ip string, ttl time.Duration) {
+	m.skipped.add(ip, ttl)
+	metrics.Record(context.Background(), skippedPodsM.M(1))
+	m.logger.Warnf("Skipping the probing of %s for %v", ip, ttl)
+	m.stopPodProbing(true, ip)
+}
+
+// SkippedPods returns the Pod IPs currently marked as unprobeable.
+func (m *Prober) SkippedPods() sets.String {
+	return m.skipped.list()
+}
+
+// cancelPodProbing cancels probing of the provided Pod IPs, the Pods then
+// being considered ready for all the Ingresses they were probed for.
+func (m *Prober) cancelPodProbing(ips ...string) {
+	m.skipped.remove(ips...)
+	func() {
 		m.mu.Lock()
 		defer m.mu.Unlock()
+		for _, ip := range ips {
+			delete(m.failures, ip)
+		}
+	}()
+	m.stopPodProbing(false, ips...)
+}
 
-		if ctx, ok := m.podContexts[pod.Status.PodIP]; ok {
-			ctx.cancel()
-			delete(m.podContexts, pod.Status.PodIP)
+// stopPodProbing stops probing the provided Pod IPs, the Pods then being
+// considered ready for all the Ingresses they were probed for, and skipped if
+// skip is true.
+func (m *Prober) stopPodProbing(skip bool, ips ...string) {
+	var cancelled []*podState
+	func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		for _, ip := range ips {
+			if ctx, ok := m.podContexts[ip]; ok {
+				ctx.cancel()
+				delete(m.podContexts, ip)
+				for ps := range ctx.podStates {
+					cancelled = append(cancelled, ps)
+				}
+			}
 		}
+	}()
+	if len(cancelled) == 0 {
+		return
 	}
+	// Notify asynchronously, as it may call the ready callback.
+	go func() {
+		for _, ps := range cancelled {
+			ps.cancel()
+			m.onProbingCancellation(ps, skip)
+		}
+	}()
 }
 
 // processWorkItem processes a single work item from workQueue.
@@ -358,45 +629,45 @@ func (m *Prober) processWorkItem() bool {
 		m.logger.Fatalf("Unexpected work item type: want: %s, got: %s\n",
 			reflect.TypeOf(&workItem{}).Name(), reflect.TypeOf(obj).Name())
 	}
+	// In case of cancellation, drop the work item
+	if item.cancelled() {
+		m.workQueue.Forget(obj)
+		return true
+	}
 	item.logger.Infof("Processing probe for %s, IP: %s:%s (depth: %d)",
 		item.url, item.podIP, item.podPort, m.workQueue.Len())
 
-	transport := http.DefaultTransport.(*http.Transport).Clone()
-	transport.TLSClientConfig = &tls.Config{
-		//nolint:gosec
-		// We only want to know that the Gateway is configured, not that the configuration is valid.
-		// Therefore, we can safely ignore any TLS certificate validation.
-		InsecureSkipVerify: true,
-	}
-	transport.DialContext = func(ctx context.Context, network, addr string) (conn net.Conn, e error) {
-		// Requests with the IP as hostname and the Host header set do no pass client-side validation
-		// because the HTTP client validates that the hostname (not the Host header) matches the server
-		// TLS certificate Common Name or Alternative Names. Therefore, http.Request.URL is set to the
-		// hostname and it is substituted it here with the target IP.
-		return dialContext(ctx, network, net.JoinHostPort(item.podIP, item.podPort))
-	}
-
 	probeURL := deepCopy(item.url)
 	probeURL.Path = path.Join(probeURL.Path, nethttp.HealthCheckPath)
 
 	ctx, cancel := context.WithTimeout(item.context, probeTimeout)
 	defer cancel()
+	// responded is whether the Pod responded, even if the probe failed.
+	var responded bool
+	verifier := m.probeVerifier(item)
 	ok, err := prober.Do(
 		ctx,
-		transport,
+		m.transport,
 		probeURL.String(),
 		prober.WithHeader(header.UserAgentKey, header.IngressReadinessUserAgent),
 		prober.WithHeader(header.ProbeKey, header.ProbeValue),
 		prober.WithHeader(header.HashKey, header.HashValueOverride),
-		m.probeVerifier(item))
+		// Requests with the IP as hostname and the Host header set do no pass client-side validation
+		// because the HTTP client validates that the hostname (not the Host header) matches the server
+		// TLS certificate Common Name or Alternative Names. Therefore, http.Request.URL is set to the
+		// hostname and the connection is redirected to the target IP.
+		prober.WithResolveTo(net.JoinHostPort(item.podIP, item.podPort)),
+		prober.Verifier(func(r *http.Response, b []byte) (bool, error) {
+			responded = true
+			return verifier(r, b)
+		}))
 
 	// In case of cancellation, drop the work item
-	select {
-	case <-item.context.Done():
+	if item.cancelled() {
 		m.workQueue.Forget(obj)
 		return true
-	default:
 	}
+	m.recordResponse(item.podIP, responded)
 
 	if err != nil || !ok {
 		// In case of error, enqueue for retry
@@ -404,16 +675,79 @@ func (m *Prober) processWorkItem() bool {
 		item.logger.Errorf("Probing of %s failed, IP: %s:%s, ready: %t, error: %v (depth: %d)",
 			item.url, item.podIP, item.podPort, ok, err, m.workQueue.Len())
 	} else {
-		m.onProbingSuccess(item.ingressState, item.podState)
+		// Don't keep tracking the failures of the item.
+		m.workQueue.Forget(obj)
+		m.onProbingSuccess(item)
 	}
 	return true
 }
 
-func (m *Prober) onProbingSuccess(ingressState *ingressState, podState *podState) {
-	// The last probe call for the Pod succeeded, the Pod is ready
-	if podState.pendingCount.Dec() == 0 {
-		// Unlock the goroutine blocked on <-podCtx.Done()
+// recordResponse counts the consecutive probes of the Pod IP failing to get a
+// response, skipping the Pod IP once they reach skipThreshold.
+func (m *Prober) recordResponse(ip string, responded bool) {
+	if m.skipThreshold <= 0 {
+		return
+	}
+	if skip := func() bool {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		if responded {
+			delete(m.failures, ip)
+			return false
+		}
+		if m.failures[ip]++; m.failures[ip] < m.skipThreshold {
+			return false
+		}
+		delete(m.failures, ip)
+		return true
+	}(); skip {
+		m.SkipPod(ip, m.skipTTL)
+	}
+}
+
+// cancelled returns whether the probing of the Ingress or of the Pod IP of
+// the item was cancelled.
+func (item *workItem) cancelled() bool {
+	return item.context.Err() != nil || item.podState.ipContext.Err() != nil
+}
+
+// newTransport returns the transport the probes are derived from.
+func newTransport() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{
+		//nolint:gosec
+		// We only want to know that the Gateway is configured, not that the configuration is valid.
+		// Therefore, we can safely ignore any TLS certificate validation.
+		InsecureSkipVerify: true,
+	}
+	return transport
+}
+
+func (m *Prober) onProbingSuccess(item *workItem) {
+	ingressState, podState := item.ingressState, item.podState
+	podReady := false
+	m.updateHosts(ingressState, func() bool {
+		// The last probe call for the Pod succeeded, the Pod is ready
+		if podState.pendingCount.Dec() == 0 {
+			podReady = true
+			ingressState.probed = true
+		}
+
+		ph := podHost{ip: item.podIP, host: item.url.Hostname()}
+		if ingressState.pendingHosts[ph]--; ingressState.pendingHosts[ph] > 0 {
+			return false
+		}
+		delete(ingressState.pendingHosts, ph)
+		return true
+	})
+
+	if podReady {
 		podState.cancel()
+		func() {
+			m.mu.Lock()
+			defer m.mu.Unlock()
+			m.unregisterPodLocked(podState)
+		}()
 
 		// This is the last pod being successfully probed, the Ingress is ready
 		if ingressState.pendingCount.Dec() == 0 {
@@ -422,7 +756,31 @@ func (m *Prober) onProbingSuccess(ingressState *ingressState, podState *podState
 	}
 }
 
-func (m *Prober) onProbingCancellation(ingressState *ingressState, podState *podState) {
+func (m *Prober) onProbingCancellation(podState *podState, skipped bool) {
+	ingressState, ip := podState.ingressState, podState.ip
+	if ingressState.context.Err() != nil {
+		// The Ingress is not probed anymore.
+		return
+	}
+
+	// The Pod went away or was skipped, it no longer holds any host.
+	m.updateHosts(ingressState, func() bool {
+		changed := false
+		for ph, n := range ingressState.pendingHosts {
+			if ph.ip == ip {
+				delete(ingressState.pendingHosts, ph)
+				changed = true
+				if skipped {
+					metrics.Record(ingressState.context, skippedProbesM.M(int64(n)))
+				}
+			}
+		}
+		if skipped && changed {
+			ingressState.skipped.Insert(ip)
+		}
+		return changed
+	})
+
 	for {
 		pendingCount := podState.pendingCount.Load()
 		if pendingCount <= 0 {
@@ -432,8 +790,9 @@ func (m *Prober) onProbingCancellation(ingressState *ingressState, podState *pod
 
 		// Attempt to set pendingCount to 0.
 		if podState.pendingCount.CAS(pendingCount, 0) {
-			// This is the last pod being successfully probed, the Ingress is ready
-			if ingressState.pendingCount.Dec() == 0 {
+			// This is the last pod being probed, the Ingress is ready unless
+			// all its Pods were skipped
+			if ingressState.pendingCount.Dec() == 0 && ingressState.ready() {
 				m.readyCallback(ingressState.ing)
 			}
 			return
@@ -441,6 +800,38 @@ func (m *Prober) onProbingCancellation(ingressState *ingressState, podState *pod
 	}
 }
 
+// updateHosts applies update to the pending hosts of the Ingress, calling the
+// progress callback if it reports a change.
+func (m *Prober) updateHosts(ingressState *ingressState, update func() bool) {
+	ingressState.mu.Lock()
+	changed := update()
+	ingressState.mu.Unlock()
+	if !changed || m.progressCallback == nil {
+		return
+	}
+
+	ingressState.notifyMu.Lock()
+	defer ingressState.notifyMu.Unlock()
+	ingressState.mu.Lock()
+	status := ingressState.statusLocked()
+	ingressState.mu.Unlock()
+	m.progressCallback(status)
+}
+
+// statusLocked returns the IngressProbeStatus of the Ingress.
+// s.mu must be held.
+func (s *ingressState) statusLocked() IngressProbeStatus {
+	notReady := make(map[string]sets.String)
+	for ph := range s.pendingHosts {
+		if notReady[ph.ip] == nil {
+			notReady[ph.ip] = sets.NewString()
+		}
+		notReady[ph.ip].Insert(ph.host)
+	}
+	return IngressProbeStatus{Ingress: s.ing, NotReady: notReady, Skipped: sets.NewString(s.skipped.UnsortedList()...),
+		Probed: s.probed}
+}
+
 func (m *Prober) probeVerifier(item *workItem) prober.Verifier {
 	return func(r *http.Response, _ []byte) (bool, error) {
 		// In the happy path, the probe request is forwarded to Activator or Queue-Proxy and the response (HTTP 200)
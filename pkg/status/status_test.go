@@ -19,9 +19,11 @@ package status
 import (
 	"context"
 	"errors"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"runtime"
 	"strconv"
 	"strings"
 	"testing"
@@ -32,11 +34,16 @@ import (
 	"knative.dev/networking/pkg/http/header"
 	"knative.dev/networking/pkg/http/probe"
 	"knative.dev/networking/pkg/ingress"
+	"knative.dev/networking/pkg/k8s"
 
+	"github.com/google/go-cmp/cmp"
 	"go.uber.org/atomic"
 	"go.uber.org/zap/zaptest"
+	"golang.org/x/time/rate"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
 )
 
 var (
@@ -175,6 +182,113 @@ func TestProbeAllHosts(t *testing.T) {
 	}
 }
 
+func TestProbeProgress(t *testing.T) {
+	const hostA = "foo.bar.com"
+	const hostB = "ksvc.test.dev"
+	var hostBEnabled atomic.Bool
+
+	ing := ingTemplate.DeepCopy()
+	ing.Spec.Rules[0].Hosts = append(ing.Spec.Rules[0].Hosts, hostB)
+	hash, err := ingress.InsertProbe(ing.DeepCopy())
+	if err != nil {
+		t.Fatal("Failed to insert probe:", err)
+	}
+
+	// Probes to hostA always succeed and probes to hostB only succeed if hostBEnabled is true
+	probeHandler := probe.NewHandler(http.NotFoundHandler())
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Host, hostA) &&
+			(!hostBEnabled.Load() || !strings.HasPrefix(r.Host, hostB)) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		r.Header.Set(header.HashKey, hash)
+		probeHandler.ServeHTTP(w, r)
+	}))
+	defer ts.Close()
+	tsURL, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatalf("Failed to parse URL %q: %v", ts.URL, err)
+	}
+	ip := tsURL.Hostname()
+
+	progress := make(chan IngressProbeStatus, 10)
+	ready := make(chan *v1alpha1.Ingress, 1)
+	prober := NewProber(
+		zaptest.NewLogger(t).Sugar(),
+		fakeProbeTargetLister{{
+			PodIPs:  sets.NewString(ip),
+			PodPort: tsURL.Port(),
+			URLs:    []*url.URL{tsURL},
+		}},
+		func(ing *v1alpha1.Ingress) {
+			ready <- ing
+		},
+		WithProgressCallback(func(status IngressProbeStatus) {
+			progress <- status
+		}))
+
+	done := make(chan struct{})
+	cancelled := prober.Start(done)
+	defer func() {
+		close(done)
+		<-cancelled
+	}()
+
+	key := types.NamespacedName{Namespace: ing.Namespace, Name: ing.Name}
+	if _, ok := prober.ProbeStatus(key); ok {
+		t.Fatal("ProbeStatus() found a status before probing started")
+	}
+	if _, err := prober.IsReady(context.Background(), ing); err != nil {
+		t.Fatal("IsReady failed:", err)
+	}
+	status, ok := prober.ProbeStatus(key)
+	if !ok {
+		t.Fatal("ProbeStatus() found no status")
+	}
+	if want := map[string]sets.String{ip: sets.NewString(hostA, hostB)}; !cmp.Equal(status.NotReady, want) {
+		t.Error("NotReady (-want, +got) =", cmp.Diff(want, status.NotReady))
+	}
+
+	// hostA gets programmed first.
+	select {
+	case status = <-progress:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for progress")
+	}
+	if want := map[string]sets.String{ip: sets.NewString(hostB)}; !cmp.Equal(status.NotReady, want) {
+		t.Error("NotReady (-want, +got) =", cmp.Diff(want, status.NotReady))
+	}
+	if status.Ready() {
+		t.Error("Ready() = true, want: false")
+	}
+	if got, want := status.Hosts(), sets.NewString(hostB); !got.Equal(want) {
+		t.Errorf("Hosts() = %v, want: %v", got.List(), want.List())
+	}
+	if status.Ingress != ing {
+		t.Errorf("Ingress = %v, want: %v", status.Ingress, ing)
+	}
+
+	// Then hostB.
+	hostBEnabled.Store(true)
+	select {
+	case status = <-progress:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for progress")
+	}
+	if !status.Ready() {
+		t.Errorf("Ready() = false, NotReady: %v", status.NotReady)
+	}
+	select {
+	case <-ready:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for probing to succeed.")
+	}
+	if status, _ := prober.ProbeStatus(key); !status.Ready() {
+		t.Errorf("ProbeStatus() = %v, want ready", status.NotReady)
+	}
+}
+
 func TestProbeLifecycle(t *testing.T) {
 	ing := ingTemplate.DeepCopy()
 	hash, err := ingress.InsertProbe(ing.DeepCopy())
@@ -481,6 +595,35 @@ func TestCancelPodProbing(t *testing.T) {
 	}
 }
 
+func TestCancelRemovedAddressProbing(t *testing.T) {
+	prober := NewProber(zaptest.NewLogger(t).Sugar(), fakeProbeTargetLister{}, func(*v1alpha1.Ingress) {})
+
+	cancelled := sets.NewString()
+	for _, ip := range []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"} {
+		ip := ip
+		prober.podContexts[ip] = cancelContext{
+			context: context.Background(),
+			cancel:  func() { cancelled.Insert(ip) },
+		}
+	}
+
+	prober.CancelRemovedAddressProbing(k8s.ReadyAddressesChange{
+		Ready:   sets.NewString("10.0.0.2", "10.0.0.4"),
+		Added:   sets.NewString("10.0.0.4"),
+		Removed: sets.NewString("10.0.0.1", "10.0.0.3"),
+	})
+
+	if want := sets.NewString("10.0.0.1", "10.0.0.3"); !cancelled.Equal(want) {
+		t.Errorf("Cancelled = %v, want: %v", cancelled.List(), want.List())
+	}
+	if _, ok := prober.podContexts["10.0.0.2"]; !ok {
+		t.Error("Probing of 10.0.0.2 was cancelled")
+	}
+	if got := len(prober.podContexts); got != 1 {
+		t.Errorf("len(podContexts) = %d, want: 1", got)
+	}
+}
+
 func TestPartialPodCancellation(t *testing.T) {
 	ing := ingTemplate.DeepCopy()
 	hash, err := ingress.InsertProbe(ing.DeepCopy())
@@ -793,3 +936,353 @@ type notFoundLister struct{}
 func (l notFoundLister) ListProbeTargets(ctx context.Context, ing *v1alpha1.Ingress) ([]ProbeTarget, error) {
 	return nil, errors.New("not found")
 }
+
+func TestProbeConcurrency(t *testing.T) {
+	ing := ingTemplate.DeepCopy()
+	ing.Spec.Rules[0].Hosts = []string{"a.foo.com", "b.foo.com", "c.foo.com", "d.foo.com", "e.foo.com", "f.foo.com"}
+	hash, err := ingress.InsertProbe(ing.DeepCopy())
+	if err != nil {
+		t.Fatal("Failed to insert probe:", err)
+	}
+
+	const concurrency = 2
+	var inflight, maxInflight atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := inflight.Inc()
+		defer inflight.Dec()
+		for max := maxInflight.Load(); n > max && !maxInflight.CAS(max, n); max = maxInflight.Load() {
+		}
+		time.Sleep(50 * time.Millisecond)
+		w.Header().Set(header.HashKey, hash)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+	tsURL, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatalf("Failed to parse URL %q: %v", ts.URL, err)
+	}
+
+	ready := make(chan *v1alpha1.Ingress)
+	prober := NewProber(
+		zaptest.NewLogger(t).Sugar(),
+		fakeProbeTargetLister{{
+			PodIPs:  sets.NewString(tsURL.Hostname()),
+			PodPort: tsURL.Port(),
+			URLs:    []*url.URL{tsURL},
+		}},
+		func(ing *v1alpha1.Ingress) {
+			ready <- ing
+		},
+		WithProbeConcurrency(concurrency))
+
+	done := make(chan struct{})
+	cancelled := prober.Start(done)
+	defer func() {
+		close(done)
+		<-cancelled
+	}()
+
+	if ok, err := prober.IsReady(context.Background(), ing); err != nil || ok {
+		t.Fatalf("IsReady() = %v, %v, want: false, nil", ok, err)
+	}
+	select {
+	case <-ready:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for probing to succeed.")
+	}
+	if got := maxInflight.Load(); got > concurrency {
+		t.Errorf("Probes in flight = %d, want at most %d", got, concurrency)
+	}
+}
+
+func TestProbeGoroutines(t *testing.T) {
+	const (
+		ingresses = 200
+		pods      = 10
+	)
+	ips := sets.NewString()
+	for i := 0; i < pods; i++ {
+		ips.Insert("10.0.0." + strconv.Itoa(i+1))
+	}
+	tsURL := &url.URL{Scheme: "http", Host: "foo.bar.com"}
+
+	var readyCount atomic.Int32
+	prober := NewProber(
+		zaptest.NewLogger(t).Sugar(),
+		fakeProbeTargetLister{{
+			PodIPs:  ips,
+			PodPort: "8080",
+			URLs:    []*url.URL{tsURL},
+		}},
+		func(*v1alpha1.Ingress) {
+			readyCount.Inc()
+		})
+
+	before := runtime.NumGoroutine()
+	for i := 0; i < ingresses; i++ {
+		ing := ingTemplate.DeepCopy()
+		ing.Name = "ingress-" + strconv.Itoa(i)
+		if ok, err := prober.IsReady(context.Background(), ing); err != nil || ok {
+			t.Fatalf("IsReady() = %v, %v, want: false, nil", ok, err)
+		}
+	}
+	// The probes are only queued: no goroutine is started per Ingress or Pod.
+	if after := runtime.NumGoroutine(); after > before+1 {
+		t.Errorf("Goroutines = %d after probing %d Ingresses on %d Pods, want at most %d",
+			after, ingresses, pods, before+1)
+	}
+
+	// The Pods going away, the Ingresses are all ready.
+	prober.CancelRemovedAddressProbing(k8s.ReadyAddressesChange{Removed: ips})
+	if err := wait.PollImmediate(10*time.Millisecond, 5*time.Second, func() (bool, error) {
+		return readyCount.Load() == ingresses, nil
+	}); err != nil {
+		t.Errorf("Ready Ingresses = %d, want: %d", readyCount.Load(), ingresses)
+	}
+	if got := len(prober.podContexts); got != 0 {
+		t.Errorf("len(podContexts) = %d, want: 0", got)
+	}
+}
+
+func TestIngressRateLimiter(t *testing.T) {
+	item := &workItem{ingressState: &ingressState{retryLimiter: rate.NewLimiter(1, 2)}}
+	other := &workItem{ingressState: &ingressState{retryLimiter: rate.NewLimiter(1, 2)}}
+
+	var limiter ingressRateLimiter
+	for i := 0; i < 2; i++ {
+		if got := limiter.When(item); got != 0 {
+			t.Errorf("When(#%d) = %v, want: 0", i, got)
+		}
+	}
+	if got := limiter.When(item); got <= 0 {
+		t.Errorf("When() = %v once the burst is exhausted, want > 0", got)
+	}
+	// The other Ingresses are not throttled.
+	if got := limiter.When(other); got != 0 {
+		t.Errorf("When(other) = %v, want: 0", got)
+	}
+}
+
+func TestSkipList(t *testing.T) {
+	now := time.Now()
+	skipped := newSkipList()
+	skipped.now = func() time.Time { return now }
+
+	skipped.add("10.0.0.1", time.Minute)
+	skipped.add("10.0.0.2", time.Hour)
+	if !skipped.has("10.0.0.1") {
+		t.Error("has(10.0.0.1) = false, want: true")
+	}
+	if skipped.has("10.0.0.3") {
+		t.Error("has(10.0.0.3) = true, want: false")
+	}
+
+	now = now.Add(time.Minute)
+	if skipped.has("10.0.0.1") {
+		t.Error("has(10.0.0.1) = true after its TTL, want: false")
+	}
+	if got, want := skipped.list(), sets.NewString("10.0.0.2"); !got.Equal(want) {
+		t.Errorf("list() = %v, want: %v", got.List(), want.List())
+	}
+
+	skipped.remove("10.0.0.2")
+	if got := skipped.list(); got.Len() != 0 {
+		t.Errorf("list() = %v after removing, want: none", got.List())
+	}
+}
+
+func TestSkipUnreachablePod(t *testing.T) {
+	hash, err := ingress.InsertProbe(ingTemplate.DeepCopy())
+	if err != nil {
+		t.Fatal("Failed to insert probe:", err)
+	}
+	probeHandler := probe.NewHandler(http.NotFoundHandler())
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Header.Set(header.HashKey, hash)
+		probeHandler.ServeHTTP(w, r)
+	}))
+	defer ts.Close()
+	tsURL, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatalf("Failed to parse URL %q: %v", ts.URL, err)
+	}
+
+	// The unreachable Pod refuses the connections.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal("Failed to listen:", err)
+	}
+	unreachablePort := strconv.Itoa(l.Addr().(*net.TCPAddr).Port)
+	l.Close()
+	const unreachable = "127.0.0.2"
+
+	progress := make(chan IngressProbeStatus, 10)
+	ready := make(chan *v1alpha1.Ingress, 2)
+	prober := NewProber(
+		zaptest.NewLogger(t).Sugar(),
+		fakeProbeTargetLister{{
+			PodIPs:  sets.NewString(tsURL.Hostname()),
+			PodPort: tsURL.Port(),
+			URLs:    []*url.URL{tsURL},
+		}, {
+			PodIPs:  sets.NewString(unreachable),
+			PodPort: unreachablePort,
+			URLs:    []*url.URL{tsURL},
+		}},
+		func(ing *v1alpha1.Ingress) {
+			ready <- ing
+		},
+		WithProgressCallback(func(status IngressProbeStatus) {
+			progress <- status
+		}),
+		WithUnreachablePodSkipping(3, time.Hour))
+
+	done := make(chan struct{})
+	cancelled := prober.Start(done)
+	defer func() {
+		close(done)
+		<-cancelled
+	}()
+
+	ing := ingTemplate.DeepCopy()
+	if ok, err := prober.IsReady(context.Background(), ing); err != nil || ok {
+		t.Fatalf("IsReady() = %t, %v, want: false, nil", ok, err)
+	}
+	select {
+	case <-ready:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the unreachable Pod to be skipped")
+	}
+	if got, want := prober.SkippedPods(), sets.NewString(unreachable); !got.Equal(want) {
+		t.Errorf("SkippedPods() = %v, want: %v", got.List(), want.List())
+	}
+
+	// The last progress reports the Ingress as ready, but degraded.
+	var status IngressProbeStatus
+	for len(progress) > 0 {
+		status = <-progress
+	}
+	if !status.Ready() || !status.Degraded() {
+		t.Errorf("Ready() = %t, Degraded() = %t, want: true, true", status.Ready(), status.Degraded())
+	}
+	if want := sets.NewString(unreachable); !status.Skipped.Equal(want) {
+		t.Errorf("Skipped = %v, want: %v", status.Skipped.List(), want.List())
+	}
+
+	// The skipped Pod isn't probed for the other Ingresses.
+	other := ingTemplate.DeepCopy()
+	other.Name = "other"
+	if _, err := prober.IsReady(context.Background(), other); err != nil {
+		t.Fatal("IsReady failed:", err)
+	}
+	status, _ = prober.ProbeStatus(types.NamespacedName{Namespace: other.Namespace, Name: other.Name})
+	if want := sets.NewString(unreachable); !status.Skipped.Equal(want) {
+		t.Errorf("Skipped = %v, want: %v", status.Skipped.List(), want.List())
+	}
+	if _, ok := status.NotReady[unreachable]; ok {
+		t.Errorf("NotReady = %v, want no entry for %s", status.NotReady, unreachable)
+	}
+
+	// Removing the Pod forgets it.
+	prober.CancelRemovedAddressProbing(k8s.ReadyAddressesChange{Removed: sets.NewString(unreachable)})
+	if got := prober.SkippedPods(); got.Len() != 0 {
+		t.Errorf("SkippedPods() = %v after removing the Pod, want: none", got.List())
+	}
+}
+
+func TestSkipAllPods(t *testing.T) {
+	hash, err := ingress.InsertProbe(ingTemplate.DeepCopy())
+	if err != nil {
+		t.Fatal("Failed to insert probe:", err)
+	}
+	var reachable atomic.Bool
+	probeHandler := probe.NewHandler(http.NotFoundHandler())
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !reachable.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		r.Header.Set(header.HashKey, hash)
+		probeHandler.ServeHTTP(w, r)
+	}))
+	defer ts.Close()
+	tsURL, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatalf("Failed to parse URL %q: %v", ts.URL, err)
+	}
+	ip := tsURL.Hostname()
+
+	progress := make(chan IngressProbeStatus, 10)
+	ready := make(chan *v1alpha1.Ingress, 2)
+	prober := NewProber(
+		zaptest.NewLogger(t).Sugar(),
+		fakeProbeTargetLister{{
+			PodIPs:  sets.NewString(ip),
+			PodPort: tsURL.Port(),
+			URLs:    []*url.URL{tsURL},
+		}},
+		func(ing *v1alpha1.Ingress) {
+			ready <- ing
+		},
+		WithProgressCallback(func(status IngressProbeStatus) {
+			progress <- status
+		}))
+
+	done := make(chan struct{})
+	cancelled := prober.Start(done)
+	defer func() {
+		close(done)
+		<-cancelled
+	}()
+
+	ing := ingTemplate.DeepCopy()
+	if ok, err := prober.IsReady(context.Background(), ing); err != nil || ok {
+		t.Fatalf("IsReady() = %t, %v, want: false, nil", ok, err)
+	}
+
+	// Skipping the only Pod being probed doesn't make the Ingress ready.
+	prober.SkipPod(ip, time.Hour)
+	var status IngressProbeStatus
+	select {
+	case status = <-progress:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the Pod to be skipped")
+	}
+	if status.Ready() || !status.Degraded() || status.Probed {
+		t.Errorf("Ready() = %t, Degraded() = %t, Probed = %t, want: false, true, false",
+			status.Ready(), status.Degraded(), status.Probed)
+	}
+
+	// Neither does starting the probing with all the Pods skipped.
+	other := ingTemplate.DeepCopy()
+	other.Name = "other"
+	for _, ing := range []*v1alpha1.Ingress{ing, other} {
+		if ok, err := prober.IsReady(context.Background(), ing); err != nil || ok {
+			t.Fatalf("IsReady(%s) = %t, %v, want: false, nil", ing.Name, ok, err)
+		}
+		status, _ := prober.ProbeStatus(types.NamespacedName{Namespace: ing.Namespace, Name: ing.Name})
+		if status.Ready() || !status.Skipped.Equal(sets.NewString(ip)) {
+			t.Errorf("%s: Ready() = %t, Skipped = %v, want: false, %v", ing.Name, status.Ready(), status.Skipped.List(), ip)
+		}
+	}
+	select {
+	case ing := <-ready:
+		t.Errorf("Ingress %s reported ready with all its Pods skipped", ing.Name)
+	default:
+	}
+
+	// Once the Pod isn't skipped anymore, it's probed again.
+	reachable.Store(true)
+	prober.skipped.remove(ip)
+	if ok, err := prober.IsReady(context.Background(), ing); err != nil || ok {
+		t.Fatalf("IsReady() = %t, %v, want: false, nil", ok, err)
+	}
+	select {
+	case <-ready:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for the Ingress to be ready")
+	}
+	if ok, err := prober.IsReady(context.Background(), ing); err != nil || !ok {
+		t.Errorf("IsReady() = %t, %v, want: true, nil", ok, err)
+	}
+}
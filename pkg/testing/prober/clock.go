@@ -0,0 +1,68 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package prober
+
+import (
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
+	clocktesting "k8s.io/utils/clock/testing"
+	"knative.dev/networking/pkg/prober"
+)
+
+// waitPollInterval is how often the fake clock is checked for waiters.
+const waitPollInterval = time.Millisecond
+
+// FakeClock is a fake clock to pass to prober.WithClock, so that the tests of
+// the callers of the prober Manager drive the async probes deterministically.
+type FakeClock struct {
+	*clocktesting.FakeClock
+}
+
+// NewFakeClock creates a FakeClock set to the given time.
+func NewFakeClock(t time.Time) *FakeClock {
+	return &FakeClock{FakeClock: clocktesting.NewFakeClock(t)}
+}
+
+// ManagerOption returns the option making a prober Manager wait on the clock.
+func (c *FakeClock) ManagerOption() prober.ManagerOption {
+	return prober.WithClock(c.FakeClock)
+}
+
+// WaitForProbes blocks until an async probe waits on the clock, i.e. until
+// it is done probing and waits for its next period, or until timeout of
+// real time elapses.
+func (c *FakeClock) WaitForProbes(timeout time.Duration) error {
+	if err := wait.PollImmediate(waitPollInterval, timeout, func() (bool, error) {
+		return c.HasWaiters(), nil
+	}); err != nil {
+		return fmt.Errorf("no probe waited on the clock within %v: %w", timeout, err)
+	}
+	return nil
+}
+
+// StepProbes waits for an async probe to wait on the clock, as WaitForProbes,
+// and advances the clock by d, e.g. the period of the probes to send them
+// again or their timeout to expire them.
+func (c *FakeClock) StepProbes(d, timeout time.Duration) error {
+	if err := c.WaitForProbes(timeout); err != nil {
+		return err
+	}
+	c.Step(d)
+	return nil
+}
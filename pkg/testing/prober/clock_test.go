@@ -0,0 +1,115 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package prober
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.uber.org/atomic"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"knative.dev/networking/pkg/prober"
+	"knative.dev/pkg/network"
+)
+
+const (
+	period  = time.Hour
+	timeout = 3 * time.Hour
+	// realTimeout bounds the real time waited for the probes.
+	realTimeout = 10 * time.Second
+)
+
+func TestFakeClockPeriods(t *testing.T) {
+	var probes atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if probes.Inc() < 3 {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	results := make(chan error, 1)
+	clock := NewFakeClock(time.Now())
+	m := prober.New(func(_ interface{}, success bool, err error) {
+		if !success && err == nil {
+			err = errors.New("probing failed")
+		}
+		results <- err
+	}, network.NewProberTransport(), clock.ManagerOption())
+	m.Offer(context.Background(), ts.URL, nil, period, timeout, prober.ExpectsStatusCodes([]int{http.StatusOK}))
+
+	for i := 0; i < 2; i++ {
+		if err := clock.StepProbes(period, realTimeout); err != nil {
+			t.Fatal("StepProbes() =", err)
+		}
+	}
+	select {
+	case err := <-results:
+		if err != nil {
+			t.Error("Probing failed:", err)
+		}
+	case <-time.After(realTimeout):
+		t.Fatal("Timed out waiting for the probe to succeed")
+	}
+	if got, want := probes.Load(), int32(3); got != want {
+		t.Errorf("Probes = %d, want %d", got, want)
+	}
+}
+
+func TestFakeClockTimeout(t *testing.T) {
+	var probes atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		probes.Inc()
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer ts.Close()
+
+	results := make(chan error, 1)
+	clock := NewFakeClock(time.Now())
+	m := prober.New(func(_ interface{}, _ bool, err error) {
+		results <- err
+	}, network.NewProberTransport(), clock.ManagerOption())
+	m.Offer(context.Background(), ts.URL, nil, period, timeout, prober.ExpectsStatusCodes([]int{http.StatusOK}))
+
+	if err := clock.StepProbes(timeout, realTimeout); err != nil {
+		t.Fatal("StepProbes() =", err)
+	}
+	select {
+	case err := <-results:
+		if !errors.Is(err, wait.ErrWaitTimeout) {
+			t.Errorf("Probing error = %v, want %v", err, wait.ErrWaitTimeout)
+		}
+	case <-time.After(realTimeout):
+		t.Fatal("Timed out waiting for the probe to time out")
+	}
+	// Stepping past the timeout sends a single late probe, not one per
+	// period skipped.
+	if got, want := probes.Load(), int32(2); got != want {
+		t.Errorf("Probes = %d, want %d", got, want)
+	}
+}
+
+func TestWaitForProbesTimeout(t *testing.T) {
+	clock := NewFakeClock(time.Now())
+	if err := clock.WaitForProbes(10 * time.Millisecond); !errors.Is(err, wait.ErrWaitTimeout) {
+		t.Errorf("WaitForProbes() = %v, want %v", err, wait.ErrWaitTimeout)
+	}
+}
@@ -0,0 +1,100 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"knative.dev/networking/pkg/apis/networking"
+	"knative.dev/networking/test"
+)
+
+// TestCookies verifies that an Ingress forwards the cookies of the requests
+// intact, and the Set-Cookie headers of the responses as separate headers,
+// since merging them into a single header breaks their Expires attributes,
+// which contain commas.
+func TestCookies(t *testing.T) {
+	t.Parallel()
+	ctx, clients := context.Background(), test.Setup(t)
+
+	t.Run("request", func(t *testing.T) {
+		t.Parallel()
+		name, port, _ := CreateRuntimeService(ctx, t, clients, networking.ServicePortNameHTTP1)
+		host := name + ".example.com"
+		_, client, _ := CreateIngressReady(ctx, t, clients, hostsIngressSpec(name, port, host))
+
+		const cookie = `session=38afes7a8; theme="dark mode"; csrf=a=b==; empty=`
+		ri := RuntimeRequest(ctx, t, client, "http://"+host, func(r *http.Request) {
+			r.Header.Set("Cookie", cookie)
+		})
+		if ri == nil {
+			return
+		}
+		// The cookies may be reformatted, but their names and values must be
+		// preserved, in order.
+		sent := cookiePairs(http.Header{"Cookie": []string{cookie}})
+		if got := cookiePairs(ri.Request.Headers); !cmp.Equal(got, sent) {
+			t.Errorf("Cookies forwarded = %q, want: %q (-want, +got): %s",
+				ri.Request.Headers.Values("Cookie"), cookie, cmp.Diff(sent, got))
+		}
+	})
+
+	t.Run("response", func(t *testing.T) {
+		t.Parallel()
+		name, port, _ := CreateTimeoutService(ctx, t, clients)
+		host := name + ".example.com"
+		_, client, _ := CreateIngressReady(ctx, t, clients, hostsIngressSpec(name, port, host))
+
+		cookies := []string{
+			"session=38afes7a8; Path=/; Expires=Wed, 21 Oct 2026 07:28:00 GMT; HttpOnly",
+			"theme=dark; Max-Age=3600; SameSite=Lax",
+			"legacy=1; Expires=Thu, 01 Jan 1970 00:00:00 GMT",
+		}
+		query := url.Values{"setCookie": cookies}
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://"+host+"?"+query.Encode(), nil)
+		if err != nil {
+			t.Fatal("Error creating the request:", err)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal("Error making GET request:", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			DumpResponse(ctx, t, resp)
+			t.Fatalf("Status = %d, want: %d", resp.StatusCode, http.StatusOK)
+		}
+		if got := resp.Header.Values("Set-Cookie"); !cmp.Equal(got, cookies) {
+			t.Error("Set-Cookie headers (-want, +got) =", cmp.Diff(cookies, got))
+		}
+	})
+}
+
+// cookiePairs returns the name=value pairs of the cookies of the Cookie
+// headers.
+func cookiePairs(h http.Header) []string {
+	r := &http.Request{Header: h}
+	var pairs []string
+	for _, c := range r.Cookies() {
+		pairs = append(pairs, c.Name+"="+c.Value)
+	}
+	return pairs
+}
@@ -0,0 +1,204 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"knative.dev/networking/pkg/apis/networking"
+	"knative.dev/networking/pkg/apis/networking/v1alpha1"
+	"knative.dev/networking/test"
+)
+
+const (
+	// drainRequestDuration is how long the requests in flight to the backend
+	// removed from a split take.
+	drainRequestDuration = 10 * time.Second
+
+	// drainDelay bounds how long new requests can still reach a backend
+	// removed from a split once the Ingress is ready.
+	drainDelay = 2 * time.Second
+
+	// drainInFlight is the number of requests in flight when the backend is
+	// removed, about half of them being to the removed backend.
+	drainInFlight = 10
+)
+
+// drainResponse is the outcome of a request sent by TestUpdateDrain.
+type drainResponse struct {
+	start, end time.Time
+	// removed is whether the request was served by the removed backend.
+	removed bool
+	err     error
+}
+
+// TestUpdateDrain verifies that removing a backend from a split while traffic
+// flows lets the requests in flight to it complete, and that no new request
+// reaches it once the Ingress is ready, give or take drainDelay.
+func TestUpdateDrain(t *testing.T) {
+	t.Parallel()
+	ctx, clients := context.Background(), test.Setup(t)
+
+	// The removed backend is the timeout one, which can hold the requests in
+	// flight, and whose responses tell it apart from the kept runtime one.
+	keptName, keptPort, keptCancel := CreateRuntimeService(ctx, t, clients, networking.ServicePortNameHTTP1)
+	defer keptCancel()
+	removedName, removedPort, removedCancel := CreateTimeoutService(ctx, t, clients)
+	defer removedCancel()
+
+	hostname := test.ObjectNameForTest(t)
+	url := "http://" + hostname + ".example.com"
+	splitFor := func(name string, port, percent int) v1alpha1.IngressBackendSplit {
+		return v1alpha1.IngressBackendSplit{
+			IngressBackend: v1alpha1.IngressBackend{
+				ServiceName:      name,
+				ServiceNamespace: test.ServingNamespace,
+				ServicePort:      intstr.FromInt(port),
+			},
+			Percent: percent,
+		}
+	}
+	specFor := func(splits ...v1alpha1.IngressBackendSplit) v1alpha1.IngressSpec {
+		return v1alpha1.IngressSpec{
+			Rules: []v1alpha1.IngressRule{{
+				Hosts:      []string{hostname + ".example.com"},
+				Visibility: v1alpha1.IngressVisibilityExternalIP,
+				HTTP: &v1alpha1.HTTPIngressRuleValue{
+					Paths: []v1alpha1.HTTPIngressPath{{
+						Splits: splits,
+					}},
+				},
+			}},
+		}
+	}
+
+	ing, client, cancel := CreateIngressReady(ctx, t, clients,
+		specFor(splitFor(keptName, keptPort, 50), splitFor(removedName, removedPort, 50)))
+	defer cancel()
+
+	inFlight := make(chan drainResponse, drainInFlight)
+	slowURL := fmt.Sprintf("%s?timeout=%d", url, drainRequestDuration.Milliseconds())
+	for i := 0; i < drainInFlight; i++ {
+		go func() {
+			inFlight <- sendDrainRequest(ctx, client, slowURL)
+		}()
+	}
+	responses, stop := sendDrainTraffic(ctx, client, url)
+
+	// Give the requests a chance to reach the backends.
+	time.Sleep(1 * time.Second)
+
+	t.Logf("Removing %q from the split", removedName)
+	updated := time.Now()
+	UpdateIngressReady(ctx, t, clients, ing.Name, specFor(splitFor(keptName, keptPort, 100)))
+	ready := time.Now()
+	t.Logf("The Ingress was ready %v after removing %q from the split", ready.Sub(updated), removedName)
+
+	time.Sleep(drainDelay + 2*time.Second)
+	stop()
+
+	var drained int
+	for i := 0; i < drainInFlight; i++ {
+		r := <-inFlight
+		switch {
+		case r.err != nil:
+			t.Errorf("Request in flight since %v before the update failed: %v", updated.Sub(r.start), r.err)
+		case r.removed:
+			drained++
+			if r.end.Before(updated) {
+				t.Logf("Request to %q completed %v before the update", removedName, updated.Sub(r.end))
+			}
+		}
+	}
+	if drained == 0 {
+		t.Errorf("No request was in flight to %q when it was removed from the split", removedName)
+	}
+
+	var late int
+	for _, r := range *responses {
+		if r.err != nil {
+			t.Errorf("[+%v] Request failed: %v", r.start.Sub(updated).Round(time.Millisecond), r.err)
+			continue
+		}
+		if r.removed && r.start.After(ready.Add(drainDelay)) {
+			late++
+		}
+	}
+	t.Logf("Sent %d requests while removing %q from the split", len(*responses), removedName)
+	if late > 0 {
+		t.Errorf("Got %d requests served by %q more than %v after the Ingress was ready", late, removedName, drainDelay)
+	}
+}
+
+// sendDrainRequest sends a request to url, telling whether it was served by
+// the timeout backend.
+func sendDrainRequest(ctx context.Context, client *http.Client, url string) drainResponse {
+	r := drainResponse{start: time.Now()}
+	r.removed, r.err = func() (bool, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return false, err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return false, err
+		}
+		defer resp.Body.Close()
+		body, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return false, fmt.Errorf("failed to read the response: %w", err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			return false, fmt.Errorf("unexpected status %d", resp.StatusCode)
+		}
+		return strings.HasPrefix(string(body), "Slept for"), nil
+	}()
+	r.end = time.Now()
+	return r
+}
+
+// sendDrainTraffic sends requests to url in a loop until the returned function
+// is called. The responses must only be read once that function returned.
+func sendDrainTraffic(ctx context.Context, client *http.Client, url string) (*[]drainResponse, context.CancelFunc) {
+	responses := &[]drainResponse{}
+	stopCh := make(chan struct{})
+	doneCh := make(chan struct{})
+
+	go func() {
+		defer close(doneCh)
+		for {
+			select {
+			case <-stopCh:
+				return
+			default:
+			}
+			*responses = append(*responses, sendDrainRequest(ctx, client, url))
+		}
+	}()
+
+	return responses, func() {
+		close(stopCh)
+		<-doneCh
+	}
+}
@@ -18,9 +18,13 @@ package ingress
 
 import (
 	"context"
+	"net/http"
+	"strings"
 	"testing"
 
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"knative.dev/networking/pkg/apis/networking"
 	"knative.dev/networking/pkg/apis/networking/v1alpha1"
 	"knative.dev/networking/test"
@@ -71,3 +75,228 @@ func TestMultipleHosts(t *testing.T) {
 		RuntimeRequest(ctx, t, client, "http://"+host)
 	}
 }
+
+// TestHostConflict verifies that a host claimed by multiple Ingresses, e.g. in
+// different namespaces, is either rejected by the webhook or served by the
+// Ingress taking precedence, as defined by Ingress.TakesPrecedenceOver, the
+// other Ingress not becoming ready.
+//
+// The conformance namespace is the only one guaranteed to exist, so both
+// Ingresses live in it: the precedence only depends on the namespaces to
+// break ties between Ingresses created at the same time.
+func TestHostConflict(t *testing.T) {
+	t.Parallel()
+	ctx, clients := context.Background(), test.Setup(t)
+
+	// Use a pre-split injected header to establish which Ingress we are sending traffic to.
+	const headerName = "Foo-Bar-Baz"
+
+	ownerName, ownerPort, _ := CreateRuntimeService(ctx, t, clients, networking.ServicePortNameHTTP1)
+	otherName, otherPort, _ := CreateRuntimeService(ctx, t, clients, networking.ServicePortNameHTTP1)
+	host := ownerName + ".example.com"
+
+	spec := func(name string, port int) v1alpha1.IngressSpec {
+		return v1alpha1.IngressSpec{
+			Rules: []v1alpha1.IngressRule{{
+				Hosts:      []string{host},
+				Visibility: v1alpha1.IngressVisibilityExternalIP,
+				HTTP: &v1alpha1.HTTPIngressRuleValue{
+					Paths: []v1alpha1.HTTPIngressPath{{
+						Splits: []v1alpha1.IngressBackendSplit{{
+							IngressBackend: v1alpha1.IngressBackend{
+								ServiceName:      name,
+								ServiceNamespace: test.ServingNamespace,
+								ServicePort:      intstr.FromInt(port),
+							},
+						}},
+						AppendHeaders: map[string]string{
+							headerName: name,
+						},
+					}},
+				},
+			}},
+		}
+	}
+
+	// The first Ingress takes precedence, being the oldest.
+	_, client, _ := CreateIngressReady(ctx, t, clients, spec(ownerName, ownerPort))
+
+	// Not using CreateIngress, the webhook may reject the conflicting Ingress.
+	other := &v1alpha1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      test.ObjectNameForTest(t),
+			Namespace: test.ServingNamespace,
+			Annotations: map[string]string{
+				networking.IngressClassAnnotationKey: test.NetworkingFlags.IngressClass,
+			},
+		},
+		Spec: spec(otherName, otherPort),
+	}
+	other.SetDefaults(ctx)
+	setDefaultsForTest(other)
+	t.Cleanup(func() { clients.NetworkingClient.Ingresses.Delete(ctx, other.Name, metav1.DeleteOptions{}) })
+	if _, err := clients.NetworkingClient.Ingresses.Create(ctx, other, metav1.CreateOptions{}); err != nil {
+		t.Log("The conflicting Ingress was rejected:", err)
+	} else {
+		// Wait for the conflicting Ingress to be reconciled.
+		if err := WaitForIngressState(ctx, clients.NetworkingClient, other.Name, func(ing *v1alpha1.Ingress) (bool, error) {
+			return ing.Status.ObservedGeneration == ing.Generation &&
+				!ing.Status.GetCondition(v1alpha1.IngressConditionReady).IsUnknown(), nil
+		}, "Reconciled"); err != nil {
+			t.Fatal("Error waiting for the conflicting Ingress to be reconciled:", err)
+		}
+		ing, err := clients.NetworkingClient.Ingresses.Get(ctx, other.Name, metav1.GetOptions{})
+		if err != nil {
+			t.Fatal("Error getting the conflicting Ingress:", err)
+		}
+		if ing.IsReady() {
+			t.Error("The conflicting Ingress became ready, want: not ready")
+		}
+	}
+
+	// The host is still served by the first Ingress.
+	ri := RuntimeRequest(ctx, t, client, "http://"+host)
+	if ri == nil {
+		return
+	}
+	if got := ri.Request.Headers.Get(headerName); got != ownerName {
+		t.Errorf("Header[%s] = %q, wanted %q", headerName, got, ownerName)
+	}
+}
+
+// TestHostCaseInsensitive verifies that the hosts of an Ingress are matched
+// case-insensitively, as host names are, so that the requests sent with an
+// uppercase or mixed case Host header reach the backend.
+func TestHostCaseInsensitive(t *testing.T) {
+	t.Parallel()
+	ctx, clients := context.Background(), test.Setup(t)
+
+	name, port, _ := CreateRuntimeService(ctx, t, clients, networking.ServicePortNameHTTP1)
+	host := name + ".example.com"
+
+	// Create a simple Ingress over the Service.
+	_, client, _ := CreateIngressReady(ctx, t, clients, hostsIngressSpec(name, port, host))
+
+	for _, h := range []string{host, strings.ToUpper(host), strings.ToUpper(name) + ".Example.Com"} {
+		RuntimeRequest(ctx, t, client, "http://"+h)
+	}
+}
+
+// TestIDNHost verifies that an Ingress serves the internationalized hosts of
+// its rules, which are specified in their punycode form, e.g.
+// `xn--bcher-kva.example.com` for `bücher.example.com`.
+func TestIDNHost(t *testing.T) {
+	t.Parallel()
+	ctx, clients := context.Background(), test.Setup(t)
+
+	name, port, _ := CreateRuntimeService(ctx, t, clients, networking.ServicePortNameHTTP1)
+	const (
+		unicodeDomain  = "bücher.example.com"
+		punycodeDomain = "xn--bcher-kva.example.com"
+	)
+
+	// Create a simple Ingress over the Service.
+	_, client, _ := CreateIngressReady(ctx, t, clients, hostsIngressSpec(name, port, name+"."+punycodeDomain))
+
+	// The clients send the punycode form of the host, whatever the form of
+	// the URL.
+	for _, domain := range []string{punycodeDomain, unicodeDomain} {
+		ri := RuntimeRequest(ctx, t, client, "http://"+name+"."+domain)
+		if ri == nil {
+			continue
+		}
+		if got, want := ri.Request.Host, name+"."+punycodeDomain; got != want {
+			t.Errorf("Host = %q, want: %q", got, want)
+		}
+	}
+}
+
+// TestWildcardHostPrecedence verifies that the wildcard hosts of an Ingress
+// match the hosts with one or more labels in place of the wildcard, and that
+// the requests matching several rules are routed following the precedence
+// of IngressSpec.MatchRule: the exact host over the wildcards, and the
+// wildcard with the longest suffix over the others.
+func TestWildcardHostPrecedence(t *testing.T) {
+	t.Parallel()
+	ctx, clients := context.Background(), test.Setup(t)
+
+	// Use a per-rule injected header to establish which rule the requests
+	// are routed by.
+	const headerName = "Foo-Bar-Baz"
+
+	name, port, _ := CreateRuntimeService(ctx, t, clients, networking.ServicePortNameHTTP1)
+	domain := name + ".example.com"
+
+	rule := func(host, which string) v1alpha1.IngressRule {
+		return v1alpha1.IngressRule{
+			Hosts:      []string{host},
+			Visibility: v1alpha1.IngressVisibilityExternalIP,
+			HTTP: &v1alpha1.HTTPIngressRuleValue{
+				Paths: []v1alpha1.HTTPIngressPath{{
+					Splits: []v1alpha1.IngressBackendSplit{{
+						IngressBackend: v1alpha1.IngressBackend{
+							ServiceName:      name,
+							ServiceNamespace: test.ServingNamespace,
+							ServicePort:      intstr.FromInt(port),
+						},
+					}},
+					AppendHeaders: map[string]string{
+						headerName: which,
+					},
+				}},
+			},
+		}
+	}
+
+	// The wildcards come first, so that the precedence doesn't depend on the
+	// order of the rules.
+	_, client, _ := CreateIngressReady(ctx, t, clients, v1alpha1.IngressSpec{
+		Rules: []v1alpha1.IngressRule{
+			rule("*."+domain, "wildcard"),
+			rule("*.bar."+domain, "longest-wildcard"),
+			rule("foo."+domain, "exact"),
+		},
+	})
+
+	for host, want := range map[string]string{
+		"foo." + domain:     "exact",
+		"baz." + domain:     "wildcard",
+		"baz.qux." + domain: "wildcard",
+		"bar." + domain:     "wildcard",
+		"baz.bar." + domain: "longest-wildcard",
+	} {
+		ri := RuntimeRequest(ctx, t, client, "http://"+host)
+		if ri == nil {
+			continue
+		}
+		if got := ri.Request.Headers.Get(headerName); got != want {
+			t.Errorf("Header[%s] for %s = %q, wanted %q", headerName, host, got, want)
+		}
+	}
+
+	// The wildcards don't match their suffix itself.
+	RuntimeRequestWithExpectations(ctx, t, client, "http://"+domain,
+		[]ResponseExpectation{StatusCodeExpectation(sets.NewInt(http.StatusNotFound))}, false)
+}
+
+// hostsIngressSpec returns the spec of an Ingress exposing the given hosts
+// over the Service.
+func hostsIngressSpec(name string, port int, hosts ...string) v1alpha1.IngressSpec {
+	return v1alpha1.IngressSpec{
+		Rules: []v1alpha1.IngressRule{{
+			Hosts:      hosts,
+			Visibility: v1alpha1.IngressVisibilityExternalIP,
+			HTTP: &v1alpha1.HTTPIngressRuleValue{
+				Paths: []v1alpha1.HTTPIngressPath{{
+					Splits: []v1alpha1.IngressBackendSplit{{
+						IngressBackend: v1alpha1.IngressBackend{
+							ServiceName:      name,
+							ServiceNamespace: test.ServingNamespace,
+							ServicePort:      intstr.FromInt(port),
+						},
+					}},
+				}},
+			},
+		}},
+	}
+}
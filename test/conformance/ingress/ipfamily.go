@@ -0,0 +1,174 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"knative.dev/networking/pkg/apis/networking"
+	"knative.dev/networking/test"
+)
+
+// The IP families of the service network of the cluster, set with the
+// ip-family flag.
+const (
+	// IPFamilyIPv4 is the family of the IPv4-only clusters.
+	IPFamilyIPv4 = "IPv4"
+
+	// IPFamilyIPv6 is the family of the IPv6-only clusters.
+	IPFamilyIPv6 = "IPv6"
+
+	// IPFamilyDualStack is the family of the dual-stack clusters, whose
+	// Services the tests create with both families, IPv6 first.
+	IPFamilyDualStack = "DualStack"
+)
+
+// ipFamilies returns the IP families of the Services created by the tests,
+// the primary one first, or none if the ip-family flag is unset.
+func ipFamilies(t *testing.T) []corev1.IPFamily {
+	t.Helper()
+	switch family := test.NetworkingFlags.IPFamily; family {
+	case "":
+		return nil
+	case IPFamilyIPv4:
+		return []corev1.IPFamily{corev1.IPv4Protocol}
+	case IPFamilyIPv6:
+		return []corev1.IPFamily{corev1.IPv6Protocol}
+	case IPFamilyDualStack:
+		return []corev1.IPFamily{corev1.IPv6Protocol, corev1.IPv4Protocol}
+	default:
+		t.Fatalf("Unknown IP family %q, wanted %q, %q or %q",
+			family, IPFamilyIPv4, IPFamilyIPv6, IPFamilyDualStack)
+		return nil // Unreachable
+	}
+}
+
+// setIPFamilies sets the IP families of the Service to the ones of the
+// ip-family flag, if set.
+func setIPFamilies(t *testing.T, svc *corev1.Service) {
+	t.Helper()
+	families := ipFamilies(t)
+	if len(families) == 0 || svc.Spec.Type == corev1.ServiceTypeExternalName {
+		return
+	}
+	policy := corev1.IPFamilyPolicySingleStack
+	if len(families) > 1 {
+		policy = corev1.IPFamilyPolicyRequireDualStack
+	}
+	svc.Spec.IPFamilies = families
+	svc.Spec.IPFamilyPolicy = &policy
+}
+
+// ipFamilyOf returns the IP family of the IP address.
+func ipFamilyOf(ip string) corev1.IPFamily {
+	if parsed := net.ParseIP(ip); parsed != nil && parsed.To4() == nil {
+		return corev1.IPv6Protocol
+	}
+	return corev1.IPv4Protocol
+}
+
+// loadBalancerIngress returns the ingress point of a load balancer to send
+// the requests to, the first one whose IP is of the primary family of the
+// ip-family flag if any, or the first one otherwise.
+func loadBalancerIngress(ingresses []corev1.LoadBalancerIngress) corev1.LoadBalancerIngress {
+	var primary corev1.IPFamily
+	switch test.NetworkingFlags.IPFamily {
+	case IPFamilyIPv4:
+		primary = corev1.IPv4Protocol
+	case IPFamilyIPv6, IPFamilyDualStack:
+		primary = corev1.IPv6Protocol
+	}
+	if primary != "" {
+		for _, ingress := range ingresses {
+			if ingress.IP != "" && ipFamilyOf(ingress.IP) == primary {
+				return ingress
+			}
+		}
+	}
+	return ingresses[0]
+}
+
+// TestIPFamily verifies that an Ingress serves the backends of the IP
+// families of the ip-family flag, e.g. IPv6-only or dual-stack Services,
+// including when the requests target a literal IP of its load balancer, e.g.
+// a bracketed IPv6 address. The other tests run with the Services of these
+// families too once the flag is set.
+func TestIPFamily(t *testing.T) {
+	t.Parallel()
+	if test.NetworkingFlags.IPFamily == "" {
+		t.Skip("The IP families of the cluster are not set, see the ip-family flag")
+	}
+	families := ipFamilies(t)
+	ctx, clients := context.Background(), test.Setup(t)
+
+	name, port, _ := CreateRuntimeService(ctx, t, clients, networking.ServicePortNameHTTP1)
+	host := name + ".example.com"
+	ing, client, _ := CreateIngressReady(ctx, t, clients, hostsIngressSpec(name, port, host))
+
+	t.Run("backend", func(t *testing.T) {
+		svc, err := clients.KubeClient.CoreV1().Services(test.ServingNamespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			t.Fatal("Error getting the backend Service:", err)
+		}
+		var got []corev1.IPFamily
+		for _, ip := range svc.Spec.ClusterIPs {
+			got = append(got, ipFamilyOf(ip))
+		}
+		if !cmp.Equal(got, families) {
+			t.Errorf("Families of the cluster IPs %v = %v, want: %v", svc.Spec.ClusterIPs, got, families)
+		}
+	})
+
+	t.Run("gateway", func(t *testing.T) {
+		// The gateway must at least be reachable with the primary family.
+		svc := publicLoadBalancerService(ctx, t, ing, clients)
+		for _, ip := range svc.Spec.ClusterIPs {
+			if ipFamilyOf(ip) == families[0] {
+				return
+			}
+		}
+		t.Errorf("Cluster IPs of the gateway %s/%s = %v, want an %s one",
+			svc.Namespace, svc.Name, svc.Spec.ClusterIPs, families[0])
+	})
+
+	t.Run("request", func(t *testing.T) {
+		RuntimeRequest(ctx, t, client, "http://"+host)
+	})
+
+	t.Run("literal IP", func(t *testing.T) {
+		svc := publicLoadBalancerService(ctx, t, ing, clients)
+		if len(svc.Status.LoadBalancer.Ingress) == 0 {
+			t.Skip("The gateway has no load balancer IP to send the requests to")
+		}
+		ip := loadBalancerIngress(svc.Status.LoadBalancer.Ingress).IP
+		if ip == "" || ipFamilyOf(ip) != families[0] {
+			t.Skipf("The gateway has no %s load balancer IP to send the requests to", families[0])
+		}
+		// The URL holds the IP, bracketed if it is an IPv6 one, and the Host
+		// header the host of the Ingress.
+		url := "http://" + net.JoinHostPort(ip, "80")
+		RuntimeRequest(ctx, t, &http.Client{}, url, func(r *http.Request) {
+			r.Host = host
+		})
+	})
+}
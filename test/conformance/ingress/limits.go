@@ -0,0 +1,115 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+	"knative.dev/networking/pkg/apis/networking"
+	"knative.dev/networking/test"
+)
+
+const (
+	// MinHeaderBytesLimit is the size of the request headers, e.g. of large
+	// cookies, that Ingress implementations must forward to the backends.
+	MinHeaderBytesLimit = 32 << 10
+
+	// MinURLLengthLimit is the length of the request URLs that Ingress
+	// implementations must forward to the backends.
+	MinURLLengthLimit = 8 << 10
+
+	// oversizedRequestBytes is the size of the headers or URL of the requests
+	// that Ingress implementations must reject, being well beyond the limits
+	// of any reasonable implementation.
+	oversizedRequestBytes = 1 << 20
+)
+
+// TestLargeHeaders verifies that an Ingress forwards the requests having
+// headers of up to MinHeaderBytesLimit bytes, e.g. large cookies, and rejects
+// the requests with oversized headers with a 431 Request Header Fields Too
+// Large.
+func TestLargeHeaders(t *testing.T) {
+	t.Parallel()
+	ctx, clients := context.Background(), test.Setup(t)
+
+	name, port, _ := CreateRuntimeService(ctx, t, clients, networking.ServicePortNameHTTP1)
+	host := name + ".example.com"
+
+	// Create a simple Ingress over the Service.
+	_, client, _ := CreateIngressReady(ctx, t, clients, hostsIngressSpec(name, port, host))
+
+	t.Run("within limit", func(t *testing.T) {
+		// Leave room for the other headers sent by the client.
+		cookie := "large=" + strings.Repeat("a", MinHeaderBytesLimit-1024)
+		ri := RuntimeRequest(ctx, t, client, "http://"+host, func(r *http.Request) {
+			r.Header.Set("Cookie", cookie)
+		})
+		if ri == nil {
+			return
+		}
+		if got := ri.Request.Headers.Get("Cookie"); got != cookie {
+			t.Errorf("Cookie of %d bytes forwarded, want the %d bytes sent", len(got), len(cookie))
+		}
+	})
+
+	t.Run("oversized", func(t *testing.T) {
+		cookie := "large=" + strings.Repeat("a", oversizedRequestBytes)
+		RuntimeRequestWithExpectations(ctx, t, client, "http://"+host,
+			[]ResponseExpectation{StatusCodeExpectation(sets.NewInt(http.StatusRequestHeaderFieldsTooLarge))},
+			false,
+			func(r *http.Request) {
+				r.Header.Set("Cookie", cookie)
+			})
+	})
+}
+
+// TestLongURL verifies that an Ingress forwards the requests having URLs of
+// up to MinURLLengthLimit bytes, and rejects the requests with oversized URLs
+// with a 414 URI Too Long, or a 431 Request Header Fields Too Large for the
+// implementations accounting the request line as part of the headers.
+func TestLongURL(t *testing.T) {
+	t.Parallel()
+	ctx, clients := context.Background(), test.Setup(t)
+
+	name, port, _ := CreateRuntimeService(ctx, t, clients, networking.ServicePortNameHTTP1)
+	host := name + ".example.com"
+
+	// Create a simple Ingress over the Service.
+	_, client, _ := CreateIngressReady(ctx, t, clients, hostsIngressSpec(name, port, host))
+
+	t.Run("within limit", func(t *testing.T) {
+		prefix := "http://" + host
+		uri := "/" + strings.Repeat("a", MinURLLengthLimit-len(prefix)-1)
+		ri := RuntimeRequest(ctx, t, client, prefix+uri)
+		if ri == nil {
+			return
+		}
+		if got := ri.Request.URI; got != uri {
+			t.Errorf("URI of %d bytes forwarded, want the %d bytes sent", len(got), len(uri))
+		}
+	})
+
+	t.Run("oversized", func(t *testing.T) {
+		RuntimeRequestWithExpectations(ctx, t, client, "http://"+host+"/"+strings.Repeat("a", oversizedRequestBytes),
+			[]ResponseExpectation{StatusCodeExpectation(sets.NewInt(http.StatusRequestURITooLong, http.StatusRequestHeaderFieldsTooLarge))},
+			false)
+	})
+}
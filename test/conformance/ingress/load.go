@@ -0,0 +1,124 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"knative.dev/networking/pkg/apis/networking"
+	"knative.dev/networking/pkg/apis/networking/v1alpha1"
+	"knative.dev/networking/pkg/bench"
+	"knative.dev/networking/test"
+)
+
+const (
+	// loadConcurrency is the number of requests kept in flight.
+	loadConcurrency = 10
+	// loadDuration is how long the load is sent.
+	loadDuration = 30 * time.Second
+	// loadRequestTimeout bounds how long each request may take.
+	loadRequestTimeout = 5 * time.Second
+)
+
+// LoadResults are the latencies of requests sent through an Ingress under a
+// steady load, as written to the file given by --measure-load.
+type LoadResults struct {
+	IngressClass string `json:"ingressClass"`
+	Concurrency  int    `json:"concurrency"`
+	DurationSecs int    `json:"durationSeconds"`
+	Requests     int    `json:"requests"`
+	Failures     int    `json:"failures"`
+
+	// Throughput is the number of requests answered per second.
+	Throughput float64 `json:"throughputRps"`
+
+	// Latency measures the time from sending a request to reading the whole
+	// response.
+	Latency LatencyStats `json:"latency"`
+}
+
+// TestLoadLatency measures the latency of the requests routed by an Ingress
+// under a steady load, for comparing implementations.
+// It only runs when --measure-load is set.
+func TestLoadLatency(t *testing.T) {
+	if test.NetworkingFlags.MeasureLoad == "" {
+		t.Skip("Load measurement is disabled, set --measure-load to run it")
+	}
+	ctx, clients := context.Background(), test.Setup(t)
+
+	name, port, _ := CreateRuntimeService(ctx, t, clients, networking.ServicePortNameHTTP1)
+	domain := name + ".example.com"
+	_, client, _ := CreateIngressReady(ctx, t, clients, v1alpha1.IngressSpec{
+		Rules: []v1alpha1.IngressRule{{
+			Hosts:      []string{domain},
+			Visibility: v1alpha1.IngressVisibilityExternalIP,
+			HTTP: &v1alpha1.HTTPIngressRuleValue{
+				Paths: []v1alpha1.HTTPIngressPath{{
+					Splits: []v1alpha1.IngressBackendSplit{{
+						IngressBackend: v1alpha1.IngressBackend{
+							ServiceName:      name,
+							ServiceNamespace: test.ServingNamespace,
+							ServicePort:      intstr.FromInt(port),
+						},
+					}},
+				}},
+			},
+		}},
+	})
+
+	res, err := bench.Run(ctx, bench.Target{URL: "http://" + domain}, bench.Options{
+		Concurrency: loadConcurrency,
+		Duration:    loadDuration,
+		Timeout:     loadRequestTimeout,
+		Client:      client,
+	})
+	if err != nil {
+		t.Fatal("Error sending the load:", err)
+	}
+	if res.Failures > 0 {
+		t.Errorf("%d out of %d requests failed, e.g.: %v", res.Failures, res.Requests, res.Errors)
+	}
+
+	results := LoadResults{
+		IngressClass: test.NetworkingFlags.IngressClass,
+		Concurrency:  loadConcurrency,
+		DurationSecs: int(loadDuration.Seconds()),
+		Requests:     res.Requests,
+		Failures:     res.Failures,
+		Throughput:   res.Throughput(),
+		Latency: LatencyStats{
+			P50: res.Latencies.Percentile(50).Milliseconds(),
+			P95: res.Latencies.Percentile(95).Milliseconds(),
+			P99: res.Latencies.Percentile(99).Milliseconds(),
+			Max: res.Latencies.Max().Milliseconds(),
+		},
+	}
+	t.Logf("Load latency: %.1f requests/s, %+v", results.Throughput, results.Latency)
+
+	b, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		t.Fatal("Error marshalling the results:", err)
+	}
+	if err := ioutil.WriteFile(test.NetworkingFlags.MeasureLoad, b, 0644); err != nil {
+		t.Fatal("Error writing the results:", err)
+	}
+}
@@ -0,0 +1,152 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"knative.dev/networking/test"
+)
+
+// ConformanceOptions configure how RunConformanceWithOptions runs the
+// conformance tests, e.g. to cut the runtime of large test matrices or to
+// bound the load put on the cluster. The zero value runs the tests as
+// RunConformance does.
+type ConformanceOptions struct {
+	// Parallelism is the maximum number of tests running at once, or 0 for
+	// the limit of go test, set with -parallel.
+	Parallelism int
+
+	// Timeout is the maximum duration of each test, from the time it starts
+	// running in parallel with the others, or 0 for no limit. A test running
+	// over aborts the whole run, naming the test, rather than leaving it
+	// hanging until the timeout of go test.
+	Timeout time.Duration
+
+	// ShareRuntimePods makes the tests share the Pods running the runtime
+	// test image, one per port name, rather than deploying their own, saving
+	// the scheduling and start-up of a Pod per test. Each test still gets its
+	// own Service over the shared Pod, and the Pods are deleted once all the
+	// tests completed.
+	ShareRuntimePods bool
+}
+
+// sharedPods are the runtime Pods shared by the tests of the current run, if
+// ShareRuntimePods is set.
+var sharedPods *runtimePods
+
+// RunConformanceWithOptions is like RunConformance, but runs the tests as
+// configured by opts.
+func RunConformanceWithOptions(t *testing.T, opts ConformanceOptions) {
+	if opts.Parallelism > 0 || opts.Timeout > 0 {
+		th := &throttle{
+			timeout:  opts.Timeout,
+			admitted: make(map[string]struct{}),
+		}
+		if opts.Parallelism > 0 {
+			th.slots = make(chan struct{}, opts.Parallelism)
+		}
+		test.SetupHook = th.admit
+		t.Cleanup(func() { test.SetupHook = nil })
+	}
+	if opts.ShareRuntimePods {
+		sharedPods = &runtimePods{suite: t, pods: make(map[string]*corev1.Pod)}
+		t.Cleanup(func() { sharedPods = nil })
+	}
+	runConformance(t)
+}
+
+// throttle bounds the number of tests running at once and their duration,
+// admitting them as they call test.Setup.
+type throttle struct {
+	slots   chan struct{}
+	timeout time.Duration
+
+	mu sync.Mutex
+	// admitted are the names of the tests admitted and still running.
+	admitted map[string]struct{}
+}
+
+// admit waits for a slot for t, unless t or one of its parents was already
+// admitted, and releases it once t completes.
+func (th *throttle) admit(t testing.TB) {
+	name := t.Name()
+	th.mu.Lock()
+	for n := range th.admitted {
+		if name == n || strings.HasPrefix(name, n+"/") {
+			th.mu.Unlock()
+			return
+		}
+	}
+	th.mu.Unlock()
+
+	if th.slots != nil {
+		th.slots <- struct{}{}
+	}
+	th.mu.Lock()
+	th.admitted[name] = struct{}{}
+	th.mu.Unlock()
+
+	var timer *time.Timer
+	if th.timeout > 0 {
+		timeout := th.timeout
+		timer = time.AfterFunc(timeout, func() {
+			panic(fmt.Sprintf("conformance test %s timed out after %v", name, timeout))
+		})
+	}
+	t.Cleanup(func() {
+		if timer != nil {
+			timer.Stop()
+		}
+		th.mu.Lock()
+		delete(th.admitted, name)
+		th.mu.Unlock()
+		if th.slots != nil {
+			<-th.slots
+		}
+	})
+}
+
+// runtimePods are the runtime Pods shared by the tests, per port name.
+type runtimePods struct {
+	// suite is the test running the conformance tests, which the Pods are
+	// cleaned up with.
+	suite *testing.T
+
+	mu   sync.Mutex
+	pods map[string]*corev1.Pod
+}
+
+// get returns the shared Pod for the port name, creating it from pod if
+// there is none yet.
+func (rp *runtimePods) get(ctx context.Context, t *testing.T, clients *test.Clients, portName string, pod *corev1.Pod) *corev1.Pod {
+	t.Helper()
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+	if shared, ok := rp.pods[portName]; ok {
+		return shared
+	}
+	createPod(ctx, t, clients, pod, rp.suite.Cleanup)
+	rp.pods[portName] = pod
+	return pod
+}
@@ -0,0 +1,188 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"knative.dev/networking/pkg/apis/networking/v1alpha1"
+	"knative.dev/networking/test"
+)
+
+const (
+	// overloadConcurrency is the number of requests the saturated backend
+	// handles concurrently, rejecting the other ones.
+	overloadConcurrency = 1
+
+	// overloadRequests is the number of concurrent requests sent to the
+	// saturated backend.
+	overloadRequests = 10
+
+	// overloadRequestDuration is how long the requests handled by the
+	// saturated backend take.
+	overloadRequestDuration = 3 * time.Second
+
+	// overloadRetryAfter is the Retry-After, in seconds, of the responses of
+	// the saturated backend to the requests it rejects.
+	overloadRetryAfter = 1
+)
+
+// overloadResponse is the outcome of a request sent by TestOverload.
+type overloadResponse struct {
+	status     int
+	retryAfter string
+	// backend is whether the response is the rejection of the backend, rather
+	// than one generated by the Ingress.
+	backend bool
+	err     error
+}
+
+// TestOverload verifies the backpressure of an Ingress in front of a saturated
+// backend: the requests beyond its concurrency must be rejected with a 503
+// Service Unavailable, rather than e.g. a 502 or a reset connection, the
+// Retry-After header of the rejections of the backend must be passed through
+// unchanged, and the ones of the rejections of the Ingress itself, which are
+// optional, must be valid. Once the backend is not saturated anymore, retrying
+// after the Retry-After delay must succeed.
+func TestOverload(t *testing.T) {
+	t.Parallel()
+	ctx, clients := context.Background(), test.Setup(t)
+
+	name, port, _ := CreateTimeoutService(ctx, t, clients)
+	domain := name + ".example.com"
+
+	// Create a simple Ingress over the Service.
+	_, client, _ := CreateIngressReady(ctx, t, clients, v1alpha1.IngressSpec{
+		Rules: []v1alpha1.IngressRule{{
+			Hosts:      []string{domain},
+			Visibility: v1alpha1.IngressVisibilityExternalIP,
+			HTTP: &v1alpha1.HTTPIngressRuleValue{
+				Paths: []v1alpha1.HTTPIngressPath{{
+					Splits: []v1alpha1.IngressBackendSplit{{
+						IngressBackend: v1alpha1.IngressBackend{
+							ServiceName:      name,
+							ServiceNamespace: test.ServingNamespace,
+							ServicePort:      intstr.FromInt(port),
+						},
+					}},
+				}},
+			},
+		}},
+	})
+	url := fmt.Sprintf("http://%s?timeout=%d&maxConcurrency=%d&retryAfter=%d",
+		domain, overloadRequestDuration.Milliseconds(), overloadConcurrency, overloadRetryAfter)
+
+	responses := make([]overloadResponse, overloadRequests)
+	var grp errgroup.Group
+	for i := range responses {
+		i := i
+		grp.Go(func() error {
+			responses[i] = sendOverloadRequest(ctx, client, url)
+			return nil
+		})
+	}
+	grp.Wait()
+
+	var served, rejected int
+	for _, r := range responses {
+		switch {
+		case r.err != nil:
+			t.Error("Request failed instead of being rejected with a 503:", r.err)
+		case r.status == http.StatusOK:
+			served++
+		case r.status != http.StatusServiceUnavailable:
+			t.Errorf("Got status %d, want: %d or %d", r.status, http.StatusOK, http.StatusServiceUnavailable)
+		case r.backend:
+			rejected++
+			if got, want := r.retryAfter, strconv.Itoa(overloadRetryAfter); got != want {
+				t.Errorf("Retry-After of the backend = %q, want: %q", got, want)
+			}
+		default:
+			rejected++
+			if r.retryAfter == "" {
+				continue
+			}
+			if _, err := parseRetryAfter(r.retryAfter); err != nil {
+				t.Errorf("Retry-After of the Ingress = %q, want delay-seconds or an HTTP-date: %v", r.retryAfter, err)
+			}
+		}
+	}
+	t.Logf("Got %d requests served and %d rejected out of %d", served, rejected, overloadRequests)
+	if served == 0 {
+		t.Error("No request was served by the saturated backend")
+	}
+	if rejected == 0 {
+		t.Errorf("No request was rejected by the backend handling %d of %d concurrent requests", overloadConcurrency, overloadRequests)
+	}
+
+	// The requests in flight are done, retrying after the Retry-After delay
+	// must succeed.
+	time.Sleep(overloadRetryAfter * time.Second)
+	if r := sendOverloadRequest(ctx, client, url); r.err != nil {
+		t.Error("Retried request failed:", r.err)
+	} else if r.status != http.StatusOK {
+		t.Errorf("Retried request got status %d, want: %d", r.status, http.StatusOK)
+	}
+}
+
+// sendOverloadRequest sends a request to url, telling whether it was rejected
+// by the saturated backend.
+func sendOverloadRequest(ctx context.Context, client *http.Client, url string) overloadResponse {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return overloadResponse{err: err}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return overloadResponse{err: err}
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return overloadResponse{err: fmt.Errorf("failed to read the response: %w", err)}
+	}
+	return overloadResponse{
+		status:     resp.StatusCode,
+		retryAfter: resp.Header.Get("Retry-After"),
+		backend:    strings.HasPrefix(string(body), test.OverloadedBody),
+	}
+}
+
+// parseRetryAfter returns the delay of a Retry-After header value, either
+// delay-seconds or an HTTP-date, as defined by RFC 9110.
+func parseRetryAfter(v string) (time.Duration, error) {
+	if seconds, err := strconv.Atoi(v); err == nil {
+		if seconds < 0 {
+			return 0, fmt.Errorf("negative delay %d", seconds)
+		}
+		return time.Duration(seconds) * time.Second, nil
+	}
+	date, err := http.ParseTime(v)
+	if err != nil {
+		return 0, err
+	}
+	return time.Until(date), nil
+}
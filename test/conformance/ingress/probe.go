@@ -0,0 +1,127 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"knative.dev/networking/pkg/apis/networking"
+	"knative.dev/networking/pkg/apis/networking/v1alpha1"
+	nethttp "knative.dev/networking/pkg/http"
+	"knative.dev/networking/pkg/http/header"
+	"knative.dev/networking/pkg/ingress"
+	"knative.dev/networking/test"
+)
+
+// TestProbeContract verifies that an Ingress implements the dataplane
+// contract the readiness of the Ingresses and the rollouts of the activator
+// rely on:
+//   - the probe requests reach the backends with their K-Network-Probe
+//     header, so that the networking layer in front of the user containers
+//     answers them instead of forwarding them,
+//   - the probe requests asking for it get the hash of the Ingress currently
+//     served injected in their K-Network-Hash header, which the backends echo,
+//   - the hash is only injected when asked for, not in the regular requests.
+func TestProbeContract(t *testing.T) {
+	t.Parallel()
+	ctx, clients := context.Background(), test.Setup(t)
+
+	name, port, _ := CreateRuntimeService(ctx, t, clients, networking.ServicePortNameHTTP1)
+	host := name + ".example.com"
+
+	// Create a simple Ingress over the Service.
+	ing, client, _ := CreateIngressReady(ctx, t, clients, hostsIngressSpec(name, port, host))
+
+	t.Run("probe header is forwarded", func(t *testing.T) {
+		ri := RuntimeRequest(ctx, t, client, "http://"+host, func(r *http.Request) {
+			r.Header.Set(header.ProbeKey, header.ProbeValue)
+			r.Header.Set(header.HashKey, header.HashValueOverride)
+		})
+		if ri == nil {
+			return
+		}
+		if got, want := ri.Request.Headers.Get(header.ProbeKey), header.ProbeValue; got != want {
+			t.Errorf("Header[%q] = %q, wanted %q", header.ProbeKey, got, want)
+		}
+	})
+
+	t.Run("hash is not injected in regular requests", func(t *testing.T) {
+		ri := RuntimeRequest(ctx, t, client, "http://"+host)
+		if ri == nil {
+			return
+		}
+		if got := ri.Request.Headers.Get(header.HashKey); got != "" {
+			t.Errorf("Header[%q] = %q, wanted none", header.HashKey, got)
+		}
+	})
+
+	// The runtime image answers the probes of its health check path itself,
+	// the way the queue-proxy and the activator do, while its handler of the
+	// regular requests of that path rejects the ones not sent by the kubelet.
+	t.Run("probe is answered with the hash", func(t *testing.T) {
+		checkProbeHash(ctx, t, client, host, ing)
+	})
+
+	t.Run("probe is answered with the updated hash", func(t *testing.T) {
+		spec := ing.Spec.DeepCopy()
+		spec.Rules[0].HTTP.Paths[0].AppendHeaders = map[string]string{"Probe-Contract": "updated"}
+		UpdateIngressReady(ctx, t, clients, ing.Name, *spec)
+
+		updated, err := clients.NetworkingClient.Ingresses.Get(ctx, ing.Name, metav1.GetOptions{})
+		if err != nil {
+			t.Fatal("Failed to get the updated Ingress:", err)
+		}
+		// The Ingress is only Ready once the update is served everywhere.
+		checkProbeHash(ctx, t, client, host, updated)
+	})
+}
+
+// checkProbeHash probes the health check path of the runtime image and
+// checks that the probe is answered with the hash of ing.
+func checkProbeHash(ctx context.Context, t *testing.T, client *http.Client, host string, ing *v1alpha1.Ingress) {
+	t.Helper()
+	bytes, err := ingress.ComputeHash(ing)
+	if err != nil {
+		t.Fatal("Failed to compute hash:", err)
+	}
+	want := fmt.Sprintf("%x", bytes)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://"+host+nethttp.HealthCheckPath, nil)
+	if err != nil {
+		t.Fatal("Error creating Request:", err)
+	}
+	req.Header.Set(header.ProbeKey, header.ProbeValue)
+	req.Header.Set(header.HashKey, header.HashValueOverride)
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal("Error making GET request:", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Status = %d, wanted %d: the probe was forwarded to the user container", resp.StatusCode, http.StatusOK)
+		DumpResponse(ctx, t, resp)
+		return
+	}
+	if got := resp.Header.Get(header.HashKey); got != want {
+		t.Errorf("Response header[%q] = %q, wanted %q", header.HashKey, got, want)
+	}
+}
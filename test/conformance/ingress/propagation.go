@@ -0,0 +1,193 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"testing"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"knative.dev/networking/pkg/apis/networking"
+	"knative.dev/networking/pkg/apis/networking/v1alpha1"
+	"knative.dev/networking/test"
+	"knative.dev/networking/test/types"
+)
+
+const (
+	// propagationSamples is the number of Ingress updates measured.
+	propagationSamples = 20
+	// propagationPollInterval is the interval the routing change is polled at,
+	// and thus the resolution of the measurements.
+	propagationPollInterval = 50 * time.Millisecond
+	// propagationTimeout is how long an update may take to propagate.
+	propagationTimeout = 2 * time.Minute
+)
+
+// PropagationResults are the propagation latencies of Ingress updates, as
+// written to the file given by --measure-propagation.
+type PropagationResults struct {
+	IngressClass string `json:"ingressClass"`
+	Samples      int    `json:"samples"`
+
+	// Routing measures the time from an update to the first request routed
+	// according to it.
+	Routing LatencyStats `json:"routing"`
+
+	// Ready measures the time from an update to the Ingress reporting Ready.
+	Ready LatencyStats `json:"ready"`
+}
+
+// LatencyStats summarizes latency samples, in milliseconds.
+type LatencyStats struct {
+	P50 int64 `json:"p50Ms"`
+	P95 int64 `json:"p95Ms"`
+	P99 int64 `json:"p99Ms"`
+	Max int64 `json:"maxMs"`
+}
+
+// TestUpdatePropagationLatency measures the time it takes for updates of the
+// Ingress to be reflected in its routing, for comparing implementations.
+// It only runs when --measure-propagation is set.
+func TestUpdatePropagationLatency(t *testing.T) {
+	if test.NetworkingFlags.MeasurePropagation == "" {
+		t.Skip("Propagation measurement is disabled, set --measure-propagation to run it")
+	}
+	ctx, clients := context.Background(), test.Setup(t)
+
+	name, port, _ := CreateRuntimeService(ctx, t, clients, networking.ServicePortNameHTTP1)
+	domain := name + ".example.com"
+	spec := func(sentinel string) v1alpha1.IngressSpec {
+		return v1alpha1.IngressSpec{
+			Rules: []v1alpha1.IngressRule{{
+				Hosts:      []string{domain},
+				Visibility: v1alpha1.IngressVisibilityExternalIP,
+				HTTP: &v1alpha1.HTTPIngressRuleValue{
+					Paths: []v1alpha1.HTTPIngressPath{{
+						Splits: []v1alpha1.IngressBackendSplit{{
+							IngressBackend: v1alpha1.IngressBackend{
+								ServiceName:      name,
+								ServiceNamespace: test.ServingNamespace,
+								ServicePort:      intstr.FromInt(port),
+							},
+							AppendHeaders: map[string]string{
+								updateHeaderName: sentinel,
+							},
+						}},
+					}},
+				},
+			}},
+		}
+	}
+	ing, client, _ := CreateIngressReady(ctx, t, clients, spec(test.ObjectNameForTest(t)))
+
+	var routing, ready []time.Duration
+	for i := 0; i < propagationSamples; i++ {
+		sentinel := test.ObjectNameForTest(t)
+
+		start := time.Now()
+		UpdateIngress(ctx, t, clients, ing.Name, spec(sentinel))
+
+		var grp errgroup.Group
+		grp.Go(func() error {
+			if err := waitForSentinel(ctx, client, "http://"+domain, sentinel); err != nil {
+				return fmt.Errorf("update %q was not routed: %w", sentinel, err)
+			}
+			routing = append(routing, time.Since(start))
+			return nil
+		})
+		grp.Go(func() error {
+			if err := WaitForIngressState(ctx, clients.NetworkingClient, ing.Name, IsIngressReady, t.Name()); err != nil {
+				return fmt.Errorf("ingress did not become ready after update %q: %w", sentinel, err)
+			}
+			ready = append(ready, time.Since(start))
+			return nil
+		})
+		if err := grp.Wait(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	results := PropagationResults{
+		IngressClass: test.NetworkingFlags.IngressClass,
+		Samples:      propagationSamples,
+		Routing:      latencyStats(routing),
+		Ready:        latencyStats(ready),
+	}
+	t.Logf("Propagation latency: routing %+v, ready %+v", results.Routing, results.Ready)
+
+	b, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		t.Fatal("Error marshalling the results:", err)
+	}
+	if err := ioutil.WriteFile(test.NetworkingFlags.MeasurePropagation, b, 0644); err != nil {
+		t.Fatal("Error writing the results:", err)
+	}
+}
+
+// waitForSentinel polls url until the request is routed with the sentinel
+// value of the updateHeaderName header appended.
+func waitForSentinel(ctx context.Context, client *http.Client, url, sentinel string) error {
+	return wait.PollImmediate(propagationPollInterval, propagationTimeout, func() (bool, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return false, err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			// The previous configuration is expected to keep serving, but
+			// the measurement is not the place to enforce it.
+			return false, nil
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return false, nil
+		}
+		ri := &types.RuntimeInfo{}
+		if err := json.NewDecoder(resp.Body).Decode(ri); err != nil {
+			return false, nil
+		}
+		return ri.Request.Headers.Get(updateHeaderName) == sentinel, nil
+	})
+}
+
+// latencyStats computes the nearest-rank percentiles of the samples.
+func latencyStats(samples []time.Duration) LatencyStats {
+	if len(samples) == 0 {
+		return LatencyStats{}
+	}
+	sorted := append([]time.Duration(nil), samples...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	percentile := func(p int) int64 {
+		// The smallest sample such that p% of the samples are lower or equal.
+		idx := (p*len(sorted)+99)/100 - 1
+		return sorted[idx].Milliseconds()
+	}
+	return LatencyStats{
+		P50: percentile(50),
+		P95: percentile(95),
+		P99: percentile(99),
+		Max: sorted[len(sorted)-1].Milliseconds(),
+	}
+}
@@ -32,14 +32,17 @@ var stableTests = map[string]func(t *testing.T){
 	"headers/post-split":           TestPostSplitSetHeaders,
 	"headers/probe":                TestProbeHeaders,
 	"hosts/multiple":               TestMultipleHosts,
+	"load/latency":                 TestLoadLatency,
 	"dispatch/path":                TestPath,
 	"dispatch/percentage":          TestPercentage,
 	"dispatch/path_and_percentage": TestPathAndPercentageSplit,
 	"dispatch/rule":                TestRule,
 	"retry":                        TestRetry,
+	"scale-from-zero":              TestScaleFromZero,
 	"timeout":                      TestTimeout,
 	"tls":                          TestIngressTLS,
 	"update":                       TestUpdate,
+	"update/propagation-latency":   TestUpdatePropagationLatency,
 	"visibility":                   TestVisibility,
 	"visibility/split":             TestVisibilitySplit,
 	"visibility/path":              TestVisibilityPath,
@@ -56,13 +59,35 @@ var betaTests = map[string]func(t *testing.T){
 
 var alphaTests = map[string]func(t *testing.T){
 	// Add your conformance test for alpha features
-	"httpoption": TestHTTPOption,
+	"httpoption":             TestHTTPOption,
+	"hosts/conflict":         TestHostConflict,
+	"hosts/case-insensitive": TestHostCaseInsensitive,
+	"hosts/idn":              TestIDNHost,
+	"hosts/wildcard":         TestWildcardHostPrecedence,
+	"ip-family":              TestIPFamily,
+	"limits/headers":         TestLargeHeaders,
+	"limits/url":             TestLongURL,
+	"load/overload":          TestOverload,
+	"hardening/smuggling":    TestRequestSmuggling,
+	"headers/probe-contract": TestProbeContract,
+	"headers/cookies":        TestCookies,
+	"streaming/sse":          TestServerSentEvents,
+	"tls/unmatched-sni":      TestIngressTLSUnmatchedSNI,
+	"tls/wildcard-overlap":   TestIngressTLSWildcardOverlap,
+	"update/warm-up":         TestUpdateWarmUp,
+	"update/drain":           TestUpdateDrain,
+	"update/zero-downtime":   TestUpdateZeroDowntime,
 }
 
 // RunConformance will run ingress conformance tests
 //
 // Depending on the options it may test alpha and beta features
 func RunConformance(t *testing.T) {
+	RunConformanceWithOptions(t, ConformanceOptions{})
+}
+
+// runConformance runs the conformance tests selected by the flags.
+func runConformance(t *testing.T) {
 	skipTests := skipTests()
 
 	for name, test := range stableTests {
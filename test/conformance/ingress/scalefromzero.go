@@ -0,0 +1,138 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"knative.dev/networking/pkg/apis/networking/v1alpha1"
+	"knative.dev/networking/test"
+)
+
+// coldStartDelay is the latency of the first requests to a revision scaling from
+// zero, held by the activator until a pod is ready. It is deliberately above the
+// default route timeouts of common proxies.
+const coldStartDelay = 30 * time.Second
+
+// TestScaleFromZero verifies that the Ingress forwards the requests of a backend
+// scaling from zero: requests are held for a long time before being answered and
+// the backend may answer 503 with a Retry-After header, which must be passed
+// through rather than retried or replaced.
+func TestScaleFromZero(t *testing.T) {
+	if !test.NetworkingFlags.ScaleFromZero {
+		t.Skip("Scale-from-zero tests are disabled, set --scale-from-zero to run them")
+	}
+	t.Parallel()
+	ctx, clients := context.Background(), test.Setup(t)
+
+	name, port, _ := CreateTimeoutService(ctx, t, clients)
+	domain := name + ".example.com"
+
+	// Create a simple Ingress over the Service.
+	_, client, _ := CreateIngressReady(ctx, t, clients, v1alpha1.IngressSpec{
+		Rules: []v1alpha1.IngressRule{{
+			Hosts:      []string{domain},
+			Visibility: v1alpha1.IngressVisibilityExternalIP,
+			HTTP: &v1alpha1.HTTPIngressRuleValue{
+				Paths: []v1alpha1.HTTPIngressPath{{
+					Splits: []v1alpha1.IngressBackendSplit{{
+						IngressBackend: v1alpha1.IngressBackend{
+							ServiceName:      name,
+							ServiceNamespace: test.ServingNamespace,
+							ServicePort:      intstr.FromInt(port),
+						},
+					}},
+				}},
+			},
+		}},
+	})
+	url := fmt.Sprintf("http://%s?initialTimeout=%d", domain, coldStartDelay.Milliseconds())
+
+	t.Run("long first request", func(t *testing.T) {
+		t.Parallel()
+		checkColdStartRequest(ctx, t, client, http.MethodGet, url, nil)
+	})
+
+	t.Run("concurrent requests", func(t *testing.T) {
+		t.Parallel()
+		// All the requests arriving while the revision scales up are held together.
+		var grp errgroup.Group
+		for i := 0; i < 5; i++ {
+			grp.Go(func() error {
+				checkColdStartRequest(ctx, t, client, http.MethodGet, url, nil)
+				return nil
+			})
+		}
+		grp.Wait()
+	})
+
+	t.Run("request body is buffered", func(t *testing.T) {
+		t.Parallel()
+		checkColdStartRequest(ctx, t, client, http.MethodPost, url, bytes.Repeat([]byte("a"), 1024*1024))
+	})
+
+	t.Run("503 with Retry-After is passed through", func(t *testing.T) {
+		t.Parallel()
+		resp, err := client.Get(fmt.Sprintf("http://%s?status=%d&retryAfter=5", domain, http.StatusServiceUnavailable))
+		if err != nil {
+			t.Fatal("Error making GET request:", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusServiceUnavailable {
+			t.Errorf("Got status %d, expected %d", resp.StatusCode, http.StatusServiceUnavailable)
+			DumpResponse(ctx, t, resp)
+		}
+		if got, want := resp.Header.Get("Retry-After"), "5"; got != want {
+			t.Errorf("Retry-After = %q, want: %q", got, want)
+		}
+	})
+}
+
+// checkColdStartRequest sends a request which the backend only answers after
+// coldStartDelay, and checks it succeeds with the whole body delivered.
+func checkColdStartRequest(ctx context.Context, t *testing.T, client *http.Client, method, url string, body []byte) {
+	t.Helper()
+
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		t.Error("Error creating request:", err)
+		return
+	}
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Errorf("Error making %s request: %v", method, err)
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Got status %d after %v, expected %d", resp.StatusCode, time.Since(start), http.StatusOK)
+		DumpResponse(ctx, t, resp)
+		return
+	}
+	if got, want := resp.Header.Get(test.RequestBodyLengthHeader), strconv.Itoa(len(body)); got != want {
+		t.Errorf("Backend received %s bytes of body, want: %s", got, want)
+	}
+}
@@ -0,0 +1,150 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"knative.dev/networking/pkg/apis/networking"
+	"knative.dev/networking/test"
+)
+
+// rawRequestTimeout is how long the requests written on raw connections
+// wait for their response.
+const rawRequestTimeout = 10 * time.Second
+
+// TestRequestSmuggling verifies that an Ingress rejects the malformed
+// requests which the hops of the data plane could frame differently, e.g.
+// with both a Content-Length and a Transfer-Encoding, with a 400 Bad Request
+// rather than forwarding them, and doesn't answer the requests smuggled in
+// their bodies.
+func TestRequestSmuggling(t *testing.T) {
+	t.Parallel()
+	ctx, clients := context.Background(), test.Setup(t)
+
+	name, port, _ := CreateRuntimeService(ctx, t, clients, networking.ServicePortNameHTTP1)
+	host := name + ".example.com"
+
+	// Create a simple Ingress over the Service.
+	_, dial, _ := createIngressReadyDialContext(ctx, t, clients, hostsIngressSpec(name, port, host))
+
+	// smuggled is a request hidden in the bodies of the malformed requests,
+	// which would be answered if the Ingress framed them differently.
+	smuggled := "GET /smuggled HTTP/1.1\r\nHost: " + host + "\r\n\r\n"
+
+	tests := []struct {
+		name    string
+		request string
+	}{{
+		name: "content-length and transfer-encoding",
+		request: "POST / HTTP/1.1\r\nHost: " + host + "\r\n" +
+			"Content-Length: 5\r\nTransfer-Encoding: chunked\r\n\r\n" +
+			"0\r\n\r\n" + smuggled,
+	}, {
+		name: "conflicting content-lengths",
+		request: "POST / HTTP/1.1\r\nHost: " + host + "\r\n" +
+			"Content-Length: 0\r\nContent-Length: 5\r\n\r\n" +
+			"hello" + smuggled,
+	}, {
+		name: "unknown transfer-encoding",
+		request: "POST / HTTP/1.1\r\nHost: " + host + "\r\n" +
+			"Transfer-Encoding: xchunked\r\n\r\n" +
+			"0\r\n\r\n" + smuggled,
+	}, {
+		name: "whitespace before the header colon",
+		request: "POST / HTTP/1.1\r\nHost: " + host + "\r\n" +
+			"Transfer-Encoding : chunked\r\nContent-Length: 5\r\n\r\n" +
+			"0\r\n\r\n" + smuggled,
+	}, {
+		name: "invalid chunk size",
+		request: "POST / HTTP/1.1\r\nHost: " + host + "\r\n" +
+			"Transfer-Encoding: chunked\r\n\r\n" +
+			"zz\r\nhello\r\n0\r\n\r\n" + smuggled,
+	}, {
+		name: "oversized chunk extension",
+		request: "POST / HTTP/1.1\r\nHost: " + host + "\r\n" +
+			"Transfer-Encoding: chunked\r\n\r\n" +
+			"5;ext=" + strings.Repeat("a", oversizedRequestBytes) + "\r\nhello\r\n0\r\n\r\n" + smuggled,
+	}}
+
+	for _, tc := range tests {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			resp, next, err := rawRequest(ctx, dial, host, tc.request)
+			if err != nil {
+				t.Fatal("Error sending the request:", err)
+			}
+			if resp.StatusCode != http.StatusBadRequest {
+				t.Errorf("Status = %d, want: %d", resp.StatusCode, http.StatusBadRequest)
+			}
+			if resp, err := next(); err == nil {
+				t.Errorf("The smuggled request was answered with status %d", resp.StatusCode)
+			}
+		})
+	}
+}
+
+// rawRequest writes the raw request on a connection to the Ingress on port
+// 80 and reads its response. next reads the next response on the
+// connection, if any comes in time.
+func rawRequest(ctx context.Context, dial func(context.Context, string, string) (net.Conn, error),
+	host, request string) (resp *http.Response, next func() (*http.Response, error), err error) {
+	ctx, cancel := context.WithTimeout(ctx, rawRequestTimeout)
+	defer cancel()
+	conn, err := dial(ctx, "tcp", net.JoinHostPort(host, "80"))
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := conn.SetDeadline(time.Now().Add(rawRequestTimeout)); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+
+	// The Ingress may answer and close the connection before reading
+	// the whole request, so the errors writing it don't matter as long as
+	// a response is read.
+	go io.WriteString(conn, request)
+
+	br := bufio.NewReader(conn)
+	resp, err = http.ReadResponse(br, nil)
+	if err != nil {
+		conn.Close()
+		return nil, nil, fmt.Errorf("error reading the response: %w", err)
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	next = func() (*http.Response, error) {
+		defer conn.Close()
+		resp, err := http.ReadResponse(br, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp.Body.Close()
+		return resp, nil
+	}
+	return resp, next, nil
+}
@@ -0,0 +1,82 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ingress
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"knative.dev/networking/pkg/http/sse"
+	"knative.dev/networking/test"
+)
+
+const (
+	// sseEvents is the number of events streamed by TestServerSentEvents.
+	sseEvents = 5
+	// sseInterval is the delay between the events.
+	sseInterval = time.Second
+)
+
+// TestServerSentEvents verifies that an Ingress streams the Server-Sent
+// Events of a backend to the client as they are sent, rather than buffering
+// the response until it completes or a buffer fills up.
+func TestServerSentEvents(t *testing.T) {
+	t.Parallel()
+	ctx, clients := context.Background(), test.Setup(t)
+
+	name, port, _ := CreateTimeoutService(ctx, t, clients)
+	host := name + ".example.com"
+	_, client, _ := CreateIngressReady(ctx, t, clients, hostsIngressSpec(name, port, host))
+
+	url := fmt.Sprintf("http://%s?events=%d&interval=%d", host, sseEvents, sseInterval.Milliseconds())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatal("Error creating the request:", err)
+	}
+	req.Header.Set("Accept", sse.ContentType)
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal("Error making GET request:", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Status = %d, want: %d", resp.StatusCode, http.StatusOK)
+	}
+	if got := resp.Header.Get("Content-Type"); got != sse.ContentType {
+		t.Errorf("Content-Type = %q, want: %q", got, sse.ContentType)
+	}
+
+	events, err := sse.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal("Error reading the events:", err)
+	}
+	if len(events) != sseEvents {
+		t.Fatalf("Got %d events, want: %d", len(events), sseEvents)
+	}
+	for i, e := range events {
+		if want := strconv.Itoa(i + 1); e.ID != want || e.Data != want {
+			t.Errorf("Event %d = %+v, want the id and data %q", i, e.Event, want)
+		}
+	}
+	if err := sse.VerifyStreamed(events, sseInterval); err != nil {
+		t.Error("Events were not streamed:", err)
+	}
+}
@@ -18,7 +18,12 @@ package ingress
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"net/http"
 	"testing"
+	"time"
 
 	"k8s.io/apimachinery/pkg/util/intstr"
 	"knative.dev/networking/pkg/apis/networking"
@@ -67,4 +72,161 @@ func TestIngressTLS(t *testing.T) {
 	RuntimeRequest(ctx, t, client, "https://"+name+".example.com")
 }
 
-// TODO(mattmoor): Consider adding variants where we have multiple hosts with distinct certificates.
+// The behaviors of the Ingresses for the TLS connections whose SNI matches
+// none of their hosts, set with the tls-unmatched-sni flag.
+const (
+	// TLSUnmatchedSNIReset is the behavior of the Ingresses failing the
+	// handshakes of these connections.
+	TLSUnmatchedSNIReset = "reset"
+
+	// TLSUnmatchedSNIDefaultCertificate is the behavior of the Ingresses
+	// completing the handshakes of these connections with a default
+	// certificate, which must not be the certificate of any of their hosts.
+	TLSUnmatchedSNIDefaultCertificate = "default-certificate"
+)
+
+// tlsHandshakeTimeout bounds the TLS handshakes of the tests.
+const tlsHandshakeTimeout = 30 * time.Second
+
+// TestIngressTLSUnmatchedSNI verifies that the TLS connections whose SNI
+// matches none of the hosts of the Ingresses get the behavior set with the
+// tls-unmatched-sni flag, and never the certificate of another host.
+func TestIngressTLSUnmatchedSNI(t *testing.T) {
+	t.Parallel()
+	behavior := test.NetworkingFlags.TLSUnmatchedSNI
+	switch behavior {
+	case TLSUnmatchedSNIReset, TLSUnmatchedSNIDefaultCertificate:
+	case "":
+		t.Skip("The behavior expected for the unmatched SNIs is not set, see the tls-unmatched-sni flag")
+	default:
+		t.Fatalf("Unknown behavior %q for the unmatched SNIs, wanted %q or %q",
+			behavior, TLSUnmatchedSNIReset, TLSUnmatchedSNIDefaultCertificate)
+	}
+	ctx, clients := context.Background(), test.Setup(t)
+
+	name, port, _ := CreateRuntimeService(ctx, t, clients, networking.ServicePortNameHTTP1)
+
+	hosts := []string{name + ".example.com"}
+
+	secretName, tlsConfig, _ := CreateTLSSecret(ctx, t, clients, hosts)
+
+	spec := tlsIngressSpec(name, port, hosts)
+	spec.TLS = []v1alpha1.IngressTLS{{
+		Hosts:           hosts,
+		SecretName:      secretName,
+		SecretNamespace: test.ServingNamespace,
+	}}
+	_, dial, _ := createIngressReadyDialContext(ctx, t, clients, spec)
+
+	// The host of the Ingress is served with its certificate.
+	if _, err := tlsHandshake(ctx, dial, hosts[0], tlsConfig); err != nil {
+		t.Fatalf("Handshake with SNI %q failed: %v", hosts[0], err)
+	}
+
+	unmatched := "unmatched-" + hosts[0]
+	state, err := tlsHandshake(ctx, dial, unmatched, &tls.Config{
+		//nolint:gosec
+		// The default certificates are checked below.
+		InsecureSkipVerify: true,
+	})
+	switch behavior {
+	case TLSUnmatchedSNIReset:
+		if err == nil {
+			t.Errorf("Handshake with SNI %q succeeded with a certificate for %v, wanted a failure",
+				unmatched, state.PeerCertificates[0].DNSNames)
+		}
+	case TLSUnmatchedSNIDefaultCertificate:
+		if err != nil {
+			t.Fatalf("Handshake with SNI %q failed: %v", unmatched, err)
+		}
+		if _, err := state.PeerCertificates[0].Verify(x509.VerifyOptions{Roots: tlsConfig.RootCAs}); err == nil {
+			t.Errorf("Handshake with SNI %q got the certificate of %q, wanted a default certificate", unmatched, hosts[0])
+		}
+	}
+}
+
+// TestIngressTLSWildcardOverlap verifies that the hosts covered by several
+// certificates of an Ingress, e.g. a wildcard certificate and one of their
+// own, are served with the certificate of the TLS entry listing them.
+func TestIngressTLSWildcardOverlap(t *testing.T) {
+	t.Parallel()
+	ctx, clients := context.Background(), test.Setup(t)
+
+	name, port, _ := CreateRuntimeService(ctx, t, clients, networking.ServicePortNameHTTP1)
+
+	domain := name + ".example.com"
+	wildcardHost, specificHost := "wildcard."+domain, "specific."+domain
+
+	// Both certificates are valid for specificHost.
+	wildcardSecret, wildcardTLS, _ := CreateTLSSecret(ctx, t, clients, []string{"*." + domain})
+	specificSecret, specificTLS, _ := CreateTLSSecret(ctx, t, clients, []string{specificHost})
+
+	spec := tlsIngressSpec(name, port, []string{wildcardHost, specificHost})
+	spec.TLS = []v1alpha1.IngressTLS{{
+		Hosts:           []string{wildcardHost},
+		SecretName:      wildcardSecret,
+		SecretNamespace: test.ServingNamespace,
+	}, {
+		Hosts:           []string{specificHost},
+		SecretName:      specificSecret,
+		SecretNamespace: test.ServingNamespace,
+	}}
+	_, dial, _ := createIngressReadyDialContext(ctx, t, clients, spec)
+
+	// Each client only trusts the certificate the host must be served with.
+	for host, tlsConfig := range map[string]*tls.Config{
+		wildcardHost: wildcardTLS,
+		specificHost: specificTLS,
+	} {
+		client := &http.Client{
+			Transport: &http.Transport{
+				DialContext:     dial,
+				TLSClientConfig: tlsConfig,
+			},
+		}
+		RuntimeRequest(ctx, t, client, "https://"+host)
+	}
+}
+
+// tlsIngressSpec returns the spec of an Ingress routing the hosts to the
+// Service, to be completed with its TLS entries.
+func tlsIngressSpec(name string, port int, hosts []string) v1alpha1.IngressSpec {
+	return v1alpha1.IngressSpec{
+		Rules: []v1alpha1.IngressRule{{
+			Hosts:      hosts,
+			Visibility: v1alpha1.IngressVisibilityExternalIP,
+			HTTP: &v1alpha1.HTTPIngressRuleValue{
+				Paths: []v1alpha1.HTTPIngressPath{{
+					Splits: []v1alpha1.IngressBackendSplit{{
+						IngressBackend: v1alpha1.IngressBackend{
+							ServiceName:      name,
+							ServiceNamespace: test.ServingNamespace,
+							ServicePort:      intstr.FromInt(port),
+						},
+					}},
+				}},
+			},
+		}},
+	}
+}
+
+// tlsHandshake establishes a TLS connection to the Ingress with the given
+// SNI, and returns the state of the handshake.
+func tlsHandshake(ctx context.Context, dial func(context.Context, string, string) (net.Conn, error),
+	serverName string, config *tls.Config) (tls.ConnectionState, error) {
+	ctx, cancel := context.WithTimeout(ctx, tlsHandshakeTimeout)
+	defer cancel()
+	conn, err := dial(ctx, "tcp", net.JoinHostPort(serverName, "443"))
+	if err != nil {
+		return tls.ConnectionState{}, err
+	}
+	defer conn.Close()
+
+	config = config.Clone()
+	config.ServerName = serverName
+	tlsConn := tls.Client(conn, config)
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		return tls.ConnectionState{}, err
+	}
+	return tlsConn.ConnectionState(), nil
+}
@@ -18,6 +18,9 @@ package ingress
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
 	"net/http"
 	"testing"
 	"time"
@@ -26,6 +29,7 @@ import (
 	"knative.dev/networking/pkg/apis/networking"
 	"knative.dev/networking/pkg/apis/networking/v1alpha1"
 	"knative.dev/networking/test"
+	"knative.dev/networking/test/types"
 )
 
 // Header to disambiguate what version we're talking to.
@@ -40,35 +44,14 @@ func TestUpdate(t *testing.T) {
 
 	// Create a simple Ingress over the Service.
 	hostname := test.ObjectNameForTest(t)
-	ing, client, cancel := CreateIngressReady(ctx, t, clients, v1alpha1.IngressSpec{
-		Rules: []v1alpha1.IngressRule{{
-			Hosts:      []string{hostname + ".example.com"},
-			Visibility: v1alpha1.IngressVisibilityExternalIP,
-			HTTP: &v1alpha1.HTTPIngressRuleValue{
-				Paths: []v1alpha1.HTTPIngressPath{{
-					Splits: []v1alpha1.IngressBackendSplit{{
-						IngressBackend: v1alpha1.IngressBackend{
-							ServiceName:      firstName,
-							ServiceNamespace: test.ServingNamespace,
-							ServicePort:      intstr.FromInt(firstPort),
-						},
-						// Append different headers to each split, which lets us identify
-						// which backend we hit.
-						AppendHeaders: map[string]string{
-							updateHeaderName: firstName,
-						},
-					}},
-				}},
-			},
-		}},
-	})
+	ing, client, cancel := CreateIngressReady(ctx, t, clients, updateSpec(hostname, firstName, firstPort, firstName))
 
 	previousVersionCancel := func() {
 		t.Logf("Tearing down %q", firstName)
 		firstCancel()
 	}
 
-	proberCancel := checkOK(ctx, t, "http://"+hostname+".example.com", client)
+	_, proberCancel := checkOK(ctx, t, "http://"+hostname+".example.com", client)
 	defer func() {
 		proberCancel()
 		previousVersionCancel()
@@ -85,26 +68,7 @@ func TestUpdate(t *testing.T) {
 		t.Logf("Rolling out %q w/ %q", firstName, sentinel)
 
 		// Update the Ingress, and wait for it to report ready.
-		UpdateIngressReady(ctx, t, clients, ing.Name, v1alpha1.IngressSpec{
-			Rules: []v1alpha1.IngressRule{{
-				Hosts:      []string{hostname + ".example.com"},
-				Visibility: v1alpha1.IngressVisibilityExternalIP,
-				HTTP: &v1alpha1.HTTPIngressRuleValue{
-					Paths: []v1alpha1.HTTPIngressPath{{
-						Splits: []v1alpha1.IngressBackendSplit{{
-							IngressBackend: v1alpha1.IngressBackend{
-								ServiceName:      firstName,
-								ServiceNamespace: test.ServingNamespace,
-								ServicePort:      intstr.FromInt(firstPort),
-							},
-							AppendHeaders: map[string]string{
-								updateHeaderName: sentinel,
-							},
-						}},
-					}},
-				},
-			}},
-		})
+		UpdateIngressReady(ctx, t, clients, ing.Name, updateSpec(hostname, firstName, firstPort, sentinel))
 
 		// Check that it serves the right message as soon as we get "Ready",
 		// but before we stop probing.
@@ -128,26 +92,7 @@ func TestUpdate(t *testing.T) {
 		t.Logf("Rolling out %q w/ %q", nextName, sentinel)
 
 		// Update the Ingress, and wait for it to report ready.
-		UpdateIngressReady(ctx, t, clients, ing.Name, v1alpha1.IngressSpec{
-			Rules: []v1alpha1.IngressRule{{
-				Hosts:      []string{hostname + ".example.com"},
-				Visibility: v1alpha1.IngressVisibilityExternalIP,
-				HTTP: &v1alpha1.HTTPIngressRuleValue{
-					Paths: []v1alpha1.HTTPIngressPath{{
-						Splits: []v1alpha1.IngressBackendSplit{{
-							IngressBackend: v1alpha1.IngressBackend{
-								ServiceName:      nextName,
-								ServiceNamespace: test.ServingNamespace,
-								ServicePort:      intstr.FromInt(nextPort),
-							},
-							AppendHeaders: map[string]string{
-								updateHeaderName: sentinel,
-							},
-						}},
-					}},
-				},
-			}},
-		})
+		UpdateIngressReady(ctx, t, clients, ing.Name, updateSpec(hostname, nextName, nextPort, sentinel))
 
 		// Check that it serves the right message as soon as we get "Ready",
 		// but before we stop probing.
@@ -168,7 +113,43 @@ func TestUpdate(t *testing.T) {
 	}
 }
 
-func checkOK(ctx context.Context, t *testing.T, url string, client *http.Client) context.CancelFunc {
+// updateSpec returns the spec of an Ingress routing hostname to the given
+// Service, appending the sentinel as the updateHeaderName header, which lets
+// us identify which version of the Ingress we hit.
+func updateSpec(hostname, name string, port int, sentinel string) v1alpha1.IngressSpec {
+	return v1alpha1.IngressSpec{
+		Rules: []v1alpha1.IngressRule{{
+			Hosts:      []string{hostname + ".example.com"},
+			Visibility: v1alpha1.IngressVisibilityExternalIP,
+			HTTP: &v1alpha1.HTTPIngressRuleValue{
+				Paths: []v1alpha1.HTTPIngressPath{{
+					Splits: []v1alpha1.IngressBackendSplit{{
+						IngressBackend: v1alpha1.IngressBackend{
+							ServiceName:      name,
+							ServiceNamespace: test.ServingNamespace,
+							ServicePort:      intstr.FromInt(port),
+						},
+						AppendHeaders: map[string]string{
+							updateHeaderName: sentinel,
+						},
+					}},
+				}},
+			},
+		}},
+	}
+}
+
+// trafficStats counts the requests sent by checkOK.
+type trafficStats struct {
+	total  int
+	failed int
+}
+
+// checkOK sends requests to url in a loop until the returned function is
+// called, failing the test on the requests not answered with a 200. The stats
+// must only be read once that function returned.
+func checkOK(ctx context.Context, t *testing.T, url string, client *http.Client) (*trafficStats, context.CancelFunc) {
+	stats := &trafficStats{}
 	stopCh := make(chan struct{})
 	doneCh := make(chan struct{})
 
@@ -184,18 +165,234 @@ func checkOK(ctx context.Context, t *testing.T, url string, client *http.Client)
 			}
 			// Scope the defer below to avoid leaking until the test completes.
 			func() {
+				stats.total++
 				ri := RuntimeRequest(ctx, t, client, url)
-				if ri != nil {
-					// Use the updateHeaderName as a debug marker to identify which version
-					// (of programming) is responding.
-					t.Logf("[%s] Got OK status!", ri.Request.Headers.Get(updateHeaderName))
+				if ri == nil {
+					stats.failed++
+					return
 				}
+				// Use the updateHeaderName as a debug marker to identify which version
+				// (of programming) is responding.
+				t.Logf("[%s] Got OK status!", ri.Request.Headers.Get(updateHeaderName))
 			}()
 		}
 	}()
 
 	// Return a cancel function that stops the prober and then waits for it to complete.
-	return func() {
+	return stats, func() {
+		close(stopCh)
+		<-doneCh
+	}
+}
+
+// TestUpdateZeroDowntime verifies that traffic sent continuously while the Ingress
+// is updated, alternately swapping backends and changing headers, never sees a
+// non-2xx response.
+func TestUpdateZeroDowntime(t *testing.T) {
+	t.Parallel()
+	ctx, clients := context.Background(), test.Setup(t)
+
+	names, ports := make([]string, 2), make([]int, 2)
+	for i := range names {
+		var cancel context.CancelFunc
+		names[i], ports[i], cancel = CreateRuntimeService(ctx, t, clients, networking.ServicePortNameHTTP1)
+		defer cancel()
+	}
+
+	hostname := test.ObjectNameForTest(t)
+	ing, client, cancel := CreateIngressReady(ctx, t, clients, updateSpec(hostname, names[0], ports[0], names[0]))
+	defer cancel()
+
+	stats, stop := checkOK(ctx, t, "http://"+hostname+".example.com", client)
+
+	// Give the traffic a chance to get started.
+	time.Sleep(1 * time.Second)
+
+	for i := 0; i < 10; i++ {
+		// Alternate between swapping the backend and only changing the header.
+		idx := (i / 2) % len(names)
+		sentinel := test.ObjectNameForTest(t)
+		t.Logf("Rolling out %q w/ %q", names[idx], sentinel)
+		UpdateIngressReady(ctx, t, clients, ing.Name, updateSpec(hostname, names[idx], ports[idx], sentinel))
+	}
+
+	// Keep the traffic flowing for a bit after the last update settled.
+	time.Sleep(1 * time.Second)
+	stop()
+
+	if stats.total == 0 {
+		t.Fatal("No request was sent while updating the Ingress")
+	}
+	t.Logf("Sent %d requests while updating the Ingress", stats.total)
+	if stats.failed > 0 {
+		t.Errorf("Got %d failed requests out of %d while updating the Ingress", stats.failed, stats.total)
+	}
+}
+
+// warmUpWindow is how long the traffic is watched once an Ingress adding a new
+// backend to a split is ready.
+const warmUpWindow = 5 * time.Second
+
+// TestUpdateWarmUp verifies that adding a new backend to a split doesn't cause a
+// spike of errors, e.g. 503s from implementations routing traffic to the new
+// backend before its endpoints are programmed, in the first seconds it serves.
+func TestUpdateWarmUp(t *testing.T) {
+	t.Parallel()
+	ctx, clients := context.Background(), test.Setup(t)
+
+	oldName, oldPort, oldCancel := CreateRuntimeService(ctx, t, clients, networking.ServicePortNameHTTP1)
+	defer oldCancel()
+
+	hostname := test.ObjectNameForTest(t)
+	splitFor := func(name string, port, percent int) v1alpha1.IngressBackendSplit {
+		return v1alpha1.IngressBackendSplit{
+			IngressBackend: v1alpha1.IngressBackend{
+				ServiceName:      name,
+				ServiceNamespace: test.ServingNamespace,
+				ServicePort:      intstr.FromInt(port),
+			},
+			Percent: percent,
+			AppendHeaders: map[string]string{
+				updateHeaderName: name,
+			},
+		}
+	}
+	specFor := func(splits ...v1alpha1.IngressBackendSplit) v1alpha1.IngressSpec {
+		return v1alpha1.IngressSpec{
+			Rules: []v1alpha1.IngressRule{{
+				Hosts:      []string{hostname + ".example.com"},
+				Visibility: v1alpha1.IngressVisibilityExternalIP,
+				HTTP: &v1alpha1.HTTPIngressRuleValue{
+					Paths: []v1alpha1.HTTPIngressPath{{
+						Splits: splits,
+					}},
+				},
+			}},
+		}
+	}
+
+	ing, client, cancel := CreateIngressReady(ctx, t, clients, specFor(splitFor(oldName, oldPort, 100)))
+	defer cancel()
+
+	buckets, stop := sendBucketedTraffic(ctx, client, "http://"+hostname+".example.com", time.Second)
+
+	// Give the traffic a chance to get started.
+	time.Sleep(1 * time.Second)
+
+	newName, newPort, newCancel := CreateRuntimeService(ctx, t, clients, networking.ServicePortNameHTTP1)
+	defer newCancel()
+
+	t.Logf("Adding %q to the split", newName)
+	updated := time.Now()
+	UpdateIngressReady(ctx, t, clients, ing.Name, specFor(splitFor(oldName, oldPort, 50), splitFor(newName, newPort, 50)))
+	ready := time.Now()
+	t.Logf("The Ingress was ready %v after adding %q to the split", ready.Sub(updated), newName)
+
+	time.Sleep(warmUpWindow)
+	stop()
+
+	var newTotal int
+	for _, b := range *buckets {
+		if b.end.Before(updated) {
+			continue
+		}
+		if b.start.After(ready) {
+			newTotal += b.backends[newName]
+		}
+		t.Logf("[+%v] %d requests, %d to %q, %d unavailable, %d failed",
+			b.start.Sub(updated).Round(time.Second), b.total, b.backends[newName], newName, b.unavailable, b.failed)
+		if b.unavailable > 0 || b.failed > 0 {
+			t.Errorf("[+%v] Got %d 503s and %d other failures out of %d requests after adding %q to the split, first failures: %v",
+				b.start.Sub(updated).Round(time.Second), b.unavailable, b.failed, b.total, newName, b.failures)
+		}
+	}
+	if newTotal == 0 {
+		t.Errorf("No request reached %q within %v of the Ingress being ready", newName, warmUpWindow)
+	}
+}
+
+// trafficBucket summarizes the responses to the requests sent during an
+// interval by sendBucketedTraffic.
+type trafficBucket struct {
+	start, end time.Time
+
+	total       int
+	unavailable int
+	failed      int
+	// backends counts the successful responses per value of updateHeaderName.
+	backends map[string]int
+	// failures holds a description of the first failed requests.
+	failures []string
+}
+
+// maxRecordedFailures bounds the failures kept by trafficBucket.
+const maxRecordedFailures = 10
+
+// sendBucketedTraffic sends requests to url in a loop until the returned function
+// is called, summarizing the responses per interval. The buckets must only be read
+// once that function returned.
+func sendBucketedTraffic(ctx context.Context, client *http.Client, url string, interval time.Duration) (*[]trafficBucket, context.CancelFunc) {
+	buckets := &[]trafficBucket{}
+	stopCh := make(chan struct{})
+	doneCh := make(chan struct{})
+
+	go func() {
+		defer close(doneCh)
+		var b *trafficBucket
+		for {
+			select {
+			case <-stopCh:
+				return
+			default:
+			}
+
+			if now := time.Now(); b == nil || !now.Before(b.end) {
+				*buckets = append(*buckets, trafficBucket{start: now, end: now.Add(interval), backends: map[string]int{}})
+				b = &(*buckets)[len(*buckets)-1]
+			}
+			b.total++
+			backend, status, err := func() (string, int, error) {
+				req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+				if err != nil {
+					return "", 0, err
+				}
+				resp, err := client.Do(req)
+				if err != nil {
+					return "", 0, err
+				}
+				defer resp.Body.Close()
+				body, err := ioutil.ReadAll(resp.Body)
+				if err != nil || resp.StatusCode != http.StatusOK {
+					return "", resp.StatusCode, err
+				}
+				ri := &types.RuntimeInfo{}
+				if err := json.Unmarshal(body, ri); err != nil {
+					return "", resp.StatusCode, err
+				}
+				return ri.Request.Headers.Get(updateHeaderName), resp.StatusCode, nil
+			}()
+
+			var failure string
+			switch {
+			case err != nil:
+				b.failed++
+				failure = err.Error()
+			case status == http.StatusServiceUnavailable:
+				b.unavailable++
+				failure = fmt.Sprintf("unexpected status %d", status)
+			case status != http.StatusOK:
+				b.failed++
+				failure = fmt.Sprintf("unexpected status %d", status)
+			default:
+				b.backends[backend]++
+			}
+			if failure != "" && len(b.failures) < maxRecordedFailures {
+				b.failures = append(b.failures, failure)
+			}
+		}
+	}()
+
+	return buckets, func() {
 		close(stopCh)
 		<-doneCh
 	}
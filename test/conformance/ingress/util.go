@@ -25,16 +25,13 @@ import (
 	"crypto/tls"
 	"crypto/x509"
 	"crypto/x509/pkix"
-	"encoding/json"
 	"encoding/pem"
 	"errors"
 	"fmt"
-	"io/ioutil"
 	"math/big"
 	"math/rand"
 	"net"
 	"net/http"
-	"net/http/httputil"
 	"os"
 	"strconv"
 	"strings"
@@ -51,6 +48,7 @@ import (
 	"knative.dev/networking/pkg/apis/networking"
 	"knative.dev/networking/pkg/apis/networking/v1alpha1"
 	"knative.dev/networking/test"
+	"knative.dev/networking/test/httpclient"
 	"knative.dev/networking/test/types"
 	"knative.dev/pkg/network"
 	"knative.dev/pkg/ptr"
@@ -73,19 +71,6 @@ var dialBackoff = wait.Backoff{
 	Cap:      10 * time.Second,
 }
 
-// uaRoundTripper wraps the given http.RoundTripper and
-// sets a custom UserAgent.
-type uaRoundTripper struct {
-	http.RoundTripper
-	ua string
-}
-
-// RoundTrip implements http.RoundTripper.
-func (ua *uaRoundTripper) RoundTrip(rq *http.Request) (*http.Response, error) {
-	rq.Header.Set("User-Agent", ua.ua)
-	return ua.RoundTripper.RoundTrip(rq)
-}
-
 // CreateRuntimeService creates a Kubernetes service that will respond to the protocol
 // specified with the given portName.  It returns the service name, the port on
 // which the service is listening, and a "cancel" function to clean up the
@@ -169,6 +154,13 @@ func CreateRuntimeService(ctx context.Context, t *testing.T, clients *test.Clien
 		},
 	}
 
+	if sharedPods != nil {
+		// Select the shared Pod, which is only deleted with the suite.
+		shared := sharedPods.get(ctx, t, clients, portName, pod)
+		svc.Spec.Selector = shared.Labels
+		svc.Spec.Ports[0].TargetPort = intstr.FromInt(int(shared.Spec.Containers[0].Ports[0].ContainerPort))
+		return name, port, createServiceReady(ctx, t, clients, svc)
+	}
 	return name, port, createPodAndService(ctx, t, clients, pod, svc)
 }
 
@@ -640,6 +632,7 @@ func createService(ctx context.Context, t *testing.T, clients *test.Clients, svc
 	t.Helper()
 
 	svcName := ktypes.NamespacedName{Name: svc.Name, Namespace: svc.Namespace}
+	setIPFamilies(t, svc)
 
 	t.Cleanup(func() {
 		clients.KubeClient.CoreV1().Services(svc.Namespace).Delete(ctx, svc.Name, metav1.DeleteOptions{})
@@ -694,10 +687,26 @@ func createExternalNameService(ctx context.Context, t *testing.T, clients *test.
 func createPodAndService(ctx context.Context, t *testing.T, clients *test.Clients, pod *corev1.Pod, svc *corev1.Service) context.CancelFunc {
 	t.Helper()
 
+	createPod(ctx, t, clients, pod, t.Cleanup)
+	cancel := createServiceReady(ctx, t, clients, svc)
+
+	return func() {
+		cancel()
+		err := clients.KubeClient.CoreV1().Pods(pod.Namespace).Delete(ctx, pod.Name, metav1.DeleteOptions{})
+		if err != nil {
+			t.Errorf("Error cleaning up Pod %q", pod.Name)
+		}
+	}
+}
+
+// createPod creates the Pod, registering its deletion with cleanup, e.g.
+// t.Cleanup.
+func createPod(ctx context.Context, t *testing.T, clients *test.Clients, pod *corev1.Pod, cleanup func(func())) {
+	t.Helper()
+
 	podName := ktypes.NamespacedName{Name: pod.Name, Namespace: pod.Namespace}
-	svcName := ktypes.NamespacedName{Name: svc.Name, Namespace: svc.Namespace}
 
-	t.Cleanup(func() {
+	cleanup(func() {
 		clients.KubeClient.CoreV1().Pods(pod.Namespace).Delete(ctx, pod.Name, metav1.DeleteOptions{})
 	})
 	if err := reconciler.RetryTestErrors(func(attempts int) error {
@@ -712,6 +721,15 @@ func createPodAndService(ctx context.Context, t *testing.T, clients *test.Client
 	}); err != nil {
 		t.Fatalf("Error creating Pod %q: %v", podName, err)
 	}
+}
+
+// createServiceReady creates the Service and waits for a Pod to show up in
+// its Endpoints.
+func createServiceReady(ctx context.Context, t *testing.T, clients *test.Clients, svc *corev1.Service) context.CancelFunc {
+	t.Helper()
+
+	svcName := ktypes.NamespacedName{Name: svc.Name, Namespace: svc.Namespace}
+	setIPFamilies(t, svc)
 
 	t.Cleanup(func() {
 		clients.KubeClient.CoreV1().Services(svc.Namespace).Delete(ctx, svc.Name, metav1.DeleteOptions{})
@@ -758,10 +776,6 @@ func createPodAndService(ctx context.Context, t *testing.T, clients *test.Client
 		if err != nil {
 			t.Errorf("Error cleaning up Service %q: %v", svcName, err)
 		}
-		err = clients.KubeClient.CoreV1().Pods(pod.Namespace).Delete(ctx, pod.Name, metav1.DeleteOptions{})
-		if err != nil {
-			t.Errorf("Error cleaning up Pod %q", pod.Name)
-		}
 	}
 }
 
@@ -869,15 +883,7 @@ func CreateIngressReadyWithTLS(ctx context.Context, t *testing.T, clients *test.
 	// Create a client with a dialer based on the Ingress' public load balancer.
 	ing, dialer, cancel := createIngressReadyDialContext(ctx, t, clients, spec)
 
-	return ing, &http.Client{
-		Transport: &uaRoundTripper{
-			RoundTripper: &http.Transport{
-				DialContext:     dialer,
-				TLSClientConfig: tlsConfig,
-			},
-			ua: fmt.Sprintf("knative.dev/%s/%s", t.Name(), ing.Name),
-		},
-	}, cancel
+	return ing, httpclient.NewClient(dialer, tlsConfig, fmt.Sprintf("knative.dev/%s/%s", t.Name(), ing.Name)), cancel
 }
 
 // UpdateIngress updates a Knative Ingress resource
@@ -1028,24 +1034,7 @@ func CreateDialContext(ctx context.Context, t *testing.T, ing *v1alpha1.Ingress,
 	// public load balancers or LBs with multiple ingresses (below), but want to
 	// keep our simple tests simple, thus the [0]s...
 
-	// We expect an ingress LB with the form foo.bar.svc.cluster.local (though
-	// we aren't strictly sensitive to the suffix, this is just illustrative.
-	internalDomain := ing.Status.PublicLoadBalancer.Ingress[0].DomainInternal
-	parts := strings.SplitN(internalDomain, ".", 3)
-	if len(parts) < 3 {
-		t.Fatal("Too few parts in internal domain:", internalDomain)
-	}
-	name, namespace := parts[0], parts[1]
-
-	var svc *corev1.Service
-	err := reconciler.RetryTestErrors(func(attempts int) (err error) {
-		svc, err = clients.KubeClient.CoreV1().Services(namespace).Get(ctx, name, metav1.GetOptions{})
-		return err
-	})
-	if err != nil {
-		t.Fatalf("Unable to retrieve Kubernetes service %s/%s: %v", namespace, name, err)
-	}
-
+	svc := publicLoadBalancerService(ctx, t, ing, clients)
 	dial := network.NewBackoffDialer(dialBackoff)
 	if pkgTest.Flags.IngressEndpoint != "" {
 		t.Logf("ingressendpoint: %q", pkgTest.Flags.IngressEndpoint)
@@ -1059,23 +1048,23 @@ func CreateDialContext(ctx context.Context, t *testing.T, ing *v1alpha1.Ingress,
 			}
 			for _, sp := range svc.Spec.Ports {
 				if fmt.Sprint(sp.Port) == port {
-					return dial(ctx, "tcp", fmt.Sprintf("%s:%d", pkgTest.Flags.IngressEndpoint, sp.NodePort))
+					return dial(ctx, "tcp", net.JoinHostPort(pkgTest.Flags.IngressEndpoint, strconv.Itoa(int(sp.NodePort))))
 				}
 			}
 			return nil, fmt.Errorf("service doesn't contain a matching port: %s", port)
 		}
 	} else if len(svc.Status.LoadBalancer.Ingress) >= 1 {
-		ingress := svc.Status.LoadBalancer.Ingress[0]
+		ingress := loadBalancerIngress(svc.Status.LoadBalancer.Ingress)
 		return func(ctx context.Context, _ string, address string) (net.Conn, error) {
 			_, port, err := net.SplitHostPort(address)
 			if err != nil {
 				return nil, err
 			}
 			if ingress.IP != "" {
-				return dial(ctx, "tcp", ingress.IP+":"+port)
+				return dial(ctx, "tcp", net.JoinHostPort(ingress.IP, port))
 			}
 			if ingress.Hostname != "" {
-				return dial(ctx, "tcp", ingress.Hostname+":"+port)
+				return dial(ctx, "tcp", net.JoinHostPort(ingress.Hostname, port))
 			}
 			return nil, errors.New("service ingress does not contain dialing information")
 		}
@@ -1085,95 +1074,66 @@ func CreateDialContext(ctx context.Context, t *testing.T, ing *v1alpha1.Ingress,
 	}
 }
 
-type RequestOption func(*http.Request)
-type ResponseExpectation func(response *http.Response) error
+// publicLoadBalancerService returns the Service of the public load balancer of
+// the Ingress.
+func publicLoadBalancerService(ctx context.Context, t *testing.T, ing *v1alpha1.Ingress, clients *test.Clients) *corev1.Service {
+	t.Helper()
+	// We expect an ingress LB with the form foo.bar.svc.cluster.local (though
+	// we aren't strictly sensitive to the suffix, this is just illustrative.
+	internalDomain := ing.Status.PublicLoadBalancer.Ingress[0].DomainInternal
+	parts := strings.SplitN(internalDomain, ".", 3)
+	if len(parts) < 3 {
+		t.Fatal("Too few parts in internal domain:", internalDomain)
+	}
+	name, namespace := parts[0], parts[1]
 
+	var svc *corev1.Service
+	err := reconciler.RetryTestErrors(func(attempts int) (err error) {
+		svc, err = clients.KubeClient.CoreV1().Services(namespace).Get(ctx, name, metav1.GetOptions{})
+		return err
+	})
+	if err != nil {
+		t.Fatalf("Unable to retrieve Kubernetes service %s/%s: %v", namespace, name, err)
+	}
+	return svc
+}
+
+// RequestOption is a way for the caller to modify the request before it is
+// sent, see httpclient.RequestOption.
+type RequestOption = httpclient.RequestOption
+
+// ResponseExpectation checks a response, see httpclient.ResponseExpectation.
+type ResponseExpectation = httpclient.ResponseExpectation
+
+// RuntimeRequest is httpclient.RuntimeRequest.
 func RuntimeRequest(ctx context.Context, t *testing.T, client *http.Client, url string, opts ...RequestOption) *types.RuntimeInfo {
-	return RuntimeRequestWithExpectations(ctx, t, client, url,
-		[]ResponseExpectation{StatusCodeExpectation(sets.NewInt(http.StatusOK))},
-		false,
-		opts...)
+	t.Helper()
+	return httpclient.RuntimeRequest(ctx, t, client, url, opts...)
 }
 
-// RuntimeRequestWithExpectations attempts to make a request to url and return runtime information.
-// If connection is successful only then it will validate all response expectations.
-// If allowDialError is set to true then function will not fail if connection is a dial error.
+// RuntimeRequestWithExpectations is httpclient.RuntimeRequestWithExpectations.
 func RuntimeRequestWithExpectations(ctx context.Context, t *testing.T, client *http.Client, url string,
 	responseExpectations []ResponseExpectation,
 	allowDialError bool,
 	opts ...RequestOption) *types.RuntimeInfo {
 	t.Helper()
-
-	req, err := http.NewRequest(http.MethodGet, url, nil)
-	if err != nil {
-		t.Error("Error creating Request:", err)
-		return nil
-	}
-
-	for _, opt := range opts {
-		opt(req)
-	}
-
-	resp, err := client.Do(req)
-
-	if err != nil {
-		if !allowDialError || !IsDialError(err) {
-			t.Error("Error making GET request:", err)
-		}
-		return nil
-	}
-
-	defer resp.Body.Close()
-
-	for _, e := range responseExpectations {
-		if err := e(resp); err != nil {
-			t.Error("Error meeting response expectations:", err)
-			DumpResponse(ctx, t, resp)
-			return nil
-		}
-	}
-
-	if resp.StatusCode == http.StatusOK {
-		b, err := ioutil.ReadAll(resp.Body)
-		if err != nil {
-			t.Error("Unable to read response body:", err)
-			DumpResponse(ctx, t, resp)
-			return nil
-		}
-		ri := &types.RuntimeInfo{}
-		if err := json.Unmarshal(b, ri); err != nil {
-			t.Error("Unable to parse runtime image's response payload:", err)
-			return nil
-		}
-		return ri
-	}
-	return nil
+	return httpclient.RuntimeRequestWithExpectations(ctx, t, client, url, responseExpectations, allowDialError, opts...)
 }
 
+// DumpResponse is httpclient.DumpResponse.
 func DumpResponse(ctx context.Context, t *testing.T, resp *http.Response) {
 	t.Helper()
-	b, err := httputil.DumpResponse(resp, true)
-	if err != nil {
-		t.Error("Error dumping response:", err)
-	}
-	t.Log(string(b))
+	httpclient.DumpResponse(ctx, t, resp)
 }
 
+// StatusCodeExpectation is httpclient.StatusCodeExpectation.
 func StatusCodeExpectation(statusCodes sets.Int) ResponseExpectation {
-	return func(response *http.Response) error {
-		if !statusCodes.Has(response.StatusCode) {
-			return fmt.Errorf("got unexpected status: %d, expected %v", response.StatusCode, statusCodes)
-		}
-		return nil
-	}
+	return httpclient.StatusCodeExpectation(statusCodes)
 }
 
+// IsDialError is httpclient.IsDialError.
 func IsDialError(err error) bool {
-	var errNetOp *net.OpError
-	if !errors.As(err, &errNetOp) {
-		return false
-	}
-	return errNetOp.Op == "dial"
+	return httpclient.IsDialError(err)
 }
 
 // WaitForIngressState polls the status of the Ingress called name from client every
@@ -23,4 +23,12 @@ package test
 const (
 	// ServingNamespace is the default namespace for serving e2e tests
 	ServingNamespace = "serving-tests"
+
+	// RequestBodyLengthHeader is the response header in which the timeout
+	// test image reports the length of the request body it received.
+	RequestBodyLengthHeader = "Request-Body-Length"
+
+	// OverloadedBody is the body of the 503 responses of the timeout test
+	// image to the requests beyond the concurrency it is asked to handle.
+	OverloadedBody = "Overloaded"
 )
@@ -44,6 +44,11 @@ type NetworkingEnvironmentFlags struct {
 	EnableBetaFeatures  bool   // Indicates whether we run tests for beta features
 	SkipTests           string // Indicates the test names we want to skip in alpha or beta features.
 	ClusterSuffix       string // Specifies the cluster DNS suffix to be used in tests.
+	ScaleFromZero       bool   // Indicates whether we run the tests simulating scale-from-zero latencies.
+	MeasurePropagation  string // Specifies the file the propagation latencies of Ingress updates are written to.
+	MeasureLoad         string // Specifies the file the latencies of requests under load are written to.
+	TLSUnmatchedSNI     string // Specifies how the Ingress answers TLS connections whose SNI matches none of its hosts.
+	IPFamily            string // Specifies the IP families of the service network of the cluster.
 }
 
 func initializeNetworkingFlags() *NetworkingEnvironmentFlags {
@@ -100,5 +105,30 @@ func initializeNetworkingFlags() *NetworkingEnvironmentFlags {
 		"cluster.local",
 		"Set this flag to the cluster suffix to be used in tests.")
 
+	flag.BoolVar(&f.ScaleFromZero,
+		"scale-from-zero",
+		false,
+		"Set this flag to run the tests simulating the latencies of requests buffered while scaling from zero.")
+
+	flag.StringVar(&f.MeasurePropagation,
+		"measure-propagation",
+		"",
+		"Set this flag to a file path to measure the propagation latency of Ingress updates and write the results there as JSON.")
+
+	flag.StringVar(&f.MeasureLoad,
+		"measure-load",
+		"",
+		"Set this flag to a file path to measure the latency of requests sent through an Ingress under load and write the results there as JSON.")
+
+	flag.StringVar(&f.TLSUnmatchedSNI,
+		"tls-unmatched-sni",
+		"",
+		"Set this flag to how the Ingress answers TLS connections whose SNI matches none of its hosts: `reset` or `default-certificate`. The tests of this behavior are skipped if unset.")
+
+	flag.StringVar(&f.IPFamily,
+		"ip-family",
+		"",
+		"Set this flag to the IP families of the service network of the cluster, `IPv4`, `IPv6` or `DualStack`, to create the Services of the tests with these families, preferring IPv6 for `DualStack`. The Services get the default family of the cluster if unset.")
+
 	return &f
 }
@@ -0,0 +1,221 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package httpclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/sets"
+	"knative.dev/networking/test/types"
+)
+
+// RequestOption is a way for the caller to modify the request before it is
+// sent, e.g. to set its headers, its protocol or its retry policy.
+type RequestOption func(*http.Request)
+
+// ResponseExpectation checks a response, returning an error if it isn't the
+// expected one.
+type ResponseExpectation func(response *http.Response) error
+
+type protocolKey struct{}
+
+// WithProtocol sends the request with the given protocol, when sent through
+// a Transport. For ProtocolH2C, the URL of the request must be a http one.
+func WithProtocol(p Protocol) RequestOption {
+	return func(r *http.Request) {
+		*r = *r.WithContext(context.WithValue(r.Context(), protocolKey{}, p))
+	}
+}
+
+// protocolFromContext returns the protocol set with WithProtocol, or
+// ProtocolHTTP1 if none.
+func protocolFromContext(ctx context.Context) Protocol {
+	if p, ok := ctx.Value(protocolKey{}).(Protocol); ok {
+		return p
+	}
+	return ProtocolHTTP1
+}
+
+// RetryPolicy specifies how the failed attempts to send a request are
+// retried by RuntimeRequestWithExpectations.
+type RetryPolicy struct {
+	// Attempts is the maximum number of attempts. Values lower than 1 mean
+	// a single attempt.
+	Attempts int
+
+	// Backoff is how long to wait between the attempts.
+	Backoff time.Duration
+
+	// Retryable returns whether the attempt which returned resp or err is
+	// retried. If nil, the attempts failing with an error, e.g. a dial
+	// error or a connection reset, are retried, but not the ones returning
+	// a response.
+	Retryable func(resp *http.Response, err error) bool
+}
+
+type retryKey struct{}
+
+// WithRetry retries the request according to policy.
+func WithRetry(policy RetryPolicy) RequestOption {
+	return func(r *http.Request) {
+		*r = *r.WithContext(context.WithValue(r.Context(), retryKey{}, policy))
+	}
+}
+
+// retryable returns whether the attempt which returned resp or err is
+// retried.
+func (p RetryPolicy) retryable(resp *http.Response, err error) bool {
+	if p.Retryable != nil {
+		return p.Retryable(resp, err)
+	}
+	return err != nil
+}
+
+// RuntimeRequest sends a GET request to url, expecting a 200 response from
+// the runtime test image, and returns the runtime information it reports.
+func RuntimeRequest(ctx context.Context, t *testing.T, client *http.Client, url string, opts ...RequestOption) *types.RuntimeInfo {
+	return RuntimeRequestWithExpectations(ctx, t, client, url,
+		[]ResponseExpectation{StatusCodeExpectation(sets.NewInt(http.StatusOK))},
+		false,
+		opts...)
+}
+
+// RuntimeRequestWithExpectations attempts to make a request to url and return runtime information.
+// If connection is successful only then it will validate all response expectations.
+// If allowDialError is set to true then function will not fail if connection is a dial error.
+// The failed attempts are retried according to the policy set with WithRetry,
+// and the DNS, connection and TLS state of the last attempt are dumped if it
+// fails.
+func RuntimeRequestWithExpectations(ctx context.Context, t *testing.T, client *http.Client, url string,
+	responseExpectations []ResponseExpectation,
+	allowDialError bool,
+	opts ...RequestOption) *types.RuntimeInfo {
+	t.Helper()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		t.Error("Error creating Request:", err)
+		return nil
+	}
+
+	for _, opt := range opts {
+		opt(req)
+	}
+
+	tr := &Trace{}
+	resp, err := do(t, client, req, tr)
+	if err != nil {
+		if !allowDialError || !IsDialError(err) {
+			t.Error("Error making GET request:", err)
+			t.Log("Request trace:\n" + tr.String())
+		}
+		return nil
+	}
+
+	defer resp.Body.Close()
+
+	for _, e := range responseExpectations {
+		if err := e(resp); err != nil {
+			t.Error("Error meeting response expectations:", err)
+			t.Log("Request trace:\n" + tr.String())
+			DumpResponse(ctx, t, resp)
+			return nil
+		}
+	}
+
+	if resp.StatusCode == http.StatusOK {
+		b, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			t.Error("Unable to read response body:", err)
+			DumpResponse(ctx, t, resp)
+			return nil
+		}
+		ri := &types.RuntimeInfo{}
+		if err := json.Unmarshal(b, ri); err != nil {
+			t.Error("Unable to parse runtime image's response payload:", err)
+			return nil
+		}
+		return ri
+	}
+	return nil
+}
+
+// do sends req with client, retrying the failed attempts according to the
+// retry policy of the request, and records the last attempt in tr.
+func do(t *testing.T, client *http.Client, req *http.Request, tr *Trace) (*http.Response, error) {
+	t.Helper()
+	ctx := req.Context()
+	policy, _ := ctx.Value(retryKey{}).(RetryPolicy)
+	for attempt := 1; ; attempt++ {
+		tr.Reset()
+		resp, err := client.Do(req.Clone(WithTrace(ctx, tr)))
+		if attempt >= policy.Attempts || !policy.retryable(resp, err) {
+			return resp, err
+		}
+		if err == nil {
+			err = fmt.Errorf("retryable response status: %d", resp.StatusCode)
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		t.Logf("Attempt %d of %d failed, retrying in %v: %v\nRequest trace:\n%s", attempt, policy.Attempts, policy.Backoff, err, tr)
+		select {
+		case <-time.After(policy.Backoff):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// DumpResponse logs the headers and the body of resp.
+func DumpResponse(ctx context.Context, t *testing.T, resp *http.Response) {
+	t.Helper()
+	b, err := httputil.DumpResponse(resp, true)
+	if err != nil {
+		t.Error("Error dumping response:", err)
+	}
+	t.Log(string(b))
+}
+
+// StatusCodeExpectation expects the status code of the response to be one
+// of statusCodes.
+func StatusCodeExpectation(statusCodes sets.Int) ResponseExpectation {
+	return func(response *http.Response) error {
+		if !statusCodes.Has(response.StatusCode) {
+			return fmt.Errorf("got unexpected status: %d, expected %v", response.StatusCode, statusCodes)
+		}
+		return nil
+	}
+}
+
+// IsDialError returns whether err is an error dialing the server.
+func IsDialError(err error) bool {
+	var errNetOp *net.OpError
+	if !errors.As(err, &errNetOp) {
+		return false
+	}
+	return errNetOp.Op == "dial"
+}
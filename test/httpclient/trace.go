@@ -0,0 +1,150 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package httpclient
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http/httptrace"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Trace records the DNS lookups, the connections and the TLS handshakes of
+// a request, to be dumped when it fails. Unlike httputil.DumpResponse, it
+// tells apart the requests failing before reaching the server.
+type Trace struct {
+	mu     sync.Mutex
+	start  time.Time
+	events []string
+}
+
+// WithTrace returns a context recording the requests sent with it in tr.
+func WithTrace(ctx context.Context, tr *Trace) context.Context {
+	tr.mu.Lock()
+	tr.start = time.Now()
+	tr.mu.Unlock()
+	return httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		GetConn: func(hostPort string) {
+			tr.record("get connection to %s", hostPort)
+		},
+		DNSStart: func(info httptrace.DNSStartInfo) {
+			tr.record("DNS lookup of %s", info.Host)
+		},
+		DNSDone: func(info httptrace.DNSDoneInfo) {
+			if info.Err != nil {
+				tr.record("DNS lookup failed: %v", info.Err)
+				return
+			}
+			addrs := make([]string, 0, len(info.Addrs))
+			for _, a := range info.Addrs {
+				addrs = append(addrs, a.String())
+			}
+			tr.record("DNS lookup returned [%s]", strings.Join(addrs, ", "))
+		},
+		ConnectStart: func(network, addr string) {
+			tr.record("connecting to %s/%s", network, addr)
+		},
+		ConnectDone: func(network, addr string, err error) {
+			if err != nil {
+				tr.record("connection to %s/%s failed: %v", network, addr, err)
+				return
+			}
+			tr.record("connected to %s/%s", network, addr)
+		},
+		TLSHandshakeStart: func() {
+			tr.record("TLS handshake")
+		},
+		TLSHandshakeDone: func(cs tls.ConnectionState, err error) {
+			if err != nil {
+				tr.record("TLS handshake failed: %v", err)
+				return
+			}
+			tr.record("TLS handshake done: %s", connectionState(cs))
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			tr.record("got connection %s -> %s (reused: %v, idle: %v)",
+				info.Conn.LocalAddr(), info.Conn.RemoteAddr(), info.Reused, info.IdleTime)
+		},
+		WroteRequest: func(info httptrace.WroteRequestInfo) {
+			if info.Err != nil {
+				tr.record("writing the request failed: %v", info.Err)
+				return
+			}
+			tr.record("wrote the request")
+		},
+		GotFirstResponseByte: func() {
+			tr.record("got the first response byte")
+		},
+	})
+}
+
+// record appends an event to the trace.
+func (tr *Trace) record(format string, args ...interface{}) {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	tr.events = append(tr.events, fmt.Sprintf("%8v "+format,
+		append([]interface{}{time.Since(tr.start).Round(time.Millisecond)}, args...)...))
+}
+
+// Reset drops the recorded events, e.g. before retrying the request.
+func (tr *Trace) Reset() {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	tr.start = time.Now()
+	tr.events = nil
+}
+
+// String returns the recorded events, one per line, prefixed with the time
+// elapsed since the start of the trace.
+func (tr *Trace) String() string {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	return strings.Join(tr.events, "\n")
+}
+
+// connectionState describes the negotiated parameters and the peer
+// certificates of a TLS connection.
+func connectionState(cs tls.ConnectionState) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "version %s, cipher suite %s, ALPN %q, server name %q",
+		tlsVersion(cs.Version), tls.CipherSuiteName(cs.CipherSuite), cs.NegotiatedProtocol, cs.ServerName)
+	for i, cert := range cs.PeerCertificates {
+		fmt.Fprintf(&b, "\n         certificate %d: subject %q, issuer %q, DNS names %v, valid %s - %s",
+			i, cert.Subject, cert.Issuer, cert.DNSNames,
+			cert.NotBefore.Format(time.RFC3339), cert.NotAfter.Format(time.RFC3339))
+	}
+	return b.String()
+}
+
+// tlsVersion returns the name of the TLS version v.
+func tlsVersion(v uint16) string {
+	switch v {
+	case tls.VersionTLS10:
+		return "TLS 1.0"
+	case tls.VersionTLS11:
+		return "TLS 1.1"
+	case tls.VersionTLS12:
+		return "TLS 1.2"
+	case tls.VersionTLS13:
+		return "TLS 1.3"
+	default:
+		return fmt.Sprintf("0x%04x", v)
+	}
+}
@@ -0,0 +1,149 @@
+/*
+Copyright 2022 The Knative Authors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package httpclient holds the HTTP client of the conformance tests, exported
+// so that the e2e tests of the Ingress implementations and of the projects
+// built on them can send their requests the same way: through the load
+// balancer of an Ingress, with a protocol selected per request, retries,
+// and dumps of the DNS, connection and TLS state of the failed requests.
+package httpclient
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+
+	"golang.org/x/net/http2"
+)
+
+// DialContextFunc dials the given address, e.g. through the load balancer
+// of an Ingress whatever the host of the request.
+type DialContextFunc func(ctx context.Context, network, address string) (net.Conn, error)
+
+// Protocol is the protocol a request is sent with, see WithProtocol.
+type Protocol string
+
+const (
+	// ProtocolHTTP1 sends the requests with HTTP/1.1, also over TLS. It is
+	// the default.
+	ProtocolHTTP1 Protocol = "http1"
+	// ProtocolH2 sends the requests with HTTP/2 over TLS, negotiated with
+	// ALPN, falling back to HTTP/1.1 if the server doesn't support it.
+	ProtocolH2 Protocol = "h2"
+	// ProtocolH2C sends the requests with HTTP/2 over cleartext, with prior
+	// knowledge.
+	ProtocolH2C Protocol = "h2c"
+)
+
+// Transport is a http.RoundTripper sending the requests with the Protocol
+// selected for each of them with WithProtocol, and setting their User-Agent.
+type Transport struct {
+	dial      DialContextFunc
+	tlsConfig *tls.Config
+	userAgent string
+
+	mu         sync.Mutex
+	transports map[Protocol]http.RoundTripper
+}
+
+var _ http.RoundTripper = (*Transport)(nil)
+
+// NewTransport returns a Transport dialing with dial, verifying the servers
+// with tlsConfig, which may be nil, and setting the User-Agent of the
+// requests to userAgent, if not empty. A nil dial dials the address of the
+// requests directly.
+func NewTransport(dial DialContextFunc, tlsConfig *tls.Config, userAgent string) *Transport {
+	if dial == nil {
+		dial = (&net.Dialer{}).DialContext
+	}
+	return &Transport{
+		dial:       dial,
+		tlsConfig:  tlsConfig,
+		userAgent:  userAgent,
+		transports: make(map[Protocol]http.RoundTripper, 3),
+	}
+}
+
+// NewClient returns a http.Client sending its requests through a
+// Transport, see NewTransport.
+func NewClient(dial DialContextFunc, tlsConfig *tls.Config, userAgent string) *http.Client {
+	return &http.Client{Transport: NewTransport(dial, tlsConfig, userAgent)}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *Transport) RoundTrip(r *http.Request) (*http.Response, error) {
+	rt, err := t.transport(protocolFromContext(r.Context()))
+	if err != nil {
+		return nil, err
+	}
+	if t.userAgent != "" {
+		r = r.Clone(r.Context())
+		r.Header.Set("User-Agent", t.userAgent)
+	}
+	return rt.RoundTrip(r)
+}
+
+// transport returns the transport of the given protocol, creating it on
+// first use so that its connections are reused across the requests.
+func (t *Transport) transport(p Protocol) (http.RoundTripper, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if rt, ok := t.transports[p]; ok {
+		return rt, nil
+	}
+
+	var rt http.RoundTripper
+	switch p {
+	case ProtocolHTTP1:
+		rt = &http.Transport{
+			DialContext:     t.dial,
+			TLSClientConfig: t.tlsConfig,
+		}
+	case ProtocolH2:
+		// A custom dialer disables HTTP/2 unless it's forced.
+		rt = &http.Transport{
+			DialContext:       t.dial,
+			TLSClientConfig:   t.tlsConfig,
+			ForceAttemptHTTP2: true,
+		}
+	case ProtocolH2C:
+		rt = &http2.Transport{
+			AllowHTTP: true,
+			DialTLS: func(network, addr string, _ *tls.Config) (net.Conn, error) {
+				return t.dial(context.Background(), network, addr)
+			},
+		}
+	default:
+		return nil, fmt.Errorf("unsupported protocol %q", p)
+	}
+	t.transports[p] = rt
+	return rt, nil
+}
+
+// CloseIdleConnections closes the idle connections of the transports of
+// all the protocols.
+func (t *Transport) CloseIdleConnections() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, rt := range t.transports {
+		if ci, ok := rt.(interface{ CloseIdleConnections() }); ok {
+			ci.CloseIdleConnections()
+		}
+	}
+}
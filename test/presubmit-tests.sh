@@ -25,6 +25,18 @@ source $(dirname $0)/../vendor/knative.dev/hack/presubmit-tests.sh
 
 # TODO(mattmoor): integration tests
 
-# We use the default build, unit and integration test runners.
+# We use the default build, unit and integration test runners, which only
+# cover the root module. The HTTP/3 prober is a module of its own, as quic-go
+# requires Go 1.26 while the rest of the repository builds with Go 1.18.
+
+function post_build_tests() {
+  subheader "Checking that the go code of pkg/prober/http3 builds"
+  (cd pkg/prober/http3 && GOFLAGS=-mod=mod report_build_test Build_Go_HTTP3 \
+    go test -vet=off -exec echo ./...)
+}
+
+function post_unit_tests() {
+  (cd pkg/prober/http3 && GOFLAGS=-mod=mod report_go_test -short -race -count 1 ./...)
+}
 
 main "$@"
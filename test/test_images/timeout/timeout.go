@@ -18,24 +18,72 @@ package main
 
 import (
 	"fmt"
+	"io"
+	"io/ioutil"
 	"log"
 	"net/http"
 	"os"
 	"strconv"
+	"sync/atomic"
 	"time"
 
 	"knative.dev/networking/pkg/http/probe"
+	"knative.dev/networking/pkg/http/sse"
 	"knative.dev/networking/test"
 )
 
+// inFlight is the number of requests being handled.
+var inFlight int64
+
 func handler(w http.ResponseWriter, r *http.Request) {
+	concurrency := atomic.AddInt64(&inFlight, 1)
+	defer atomic.AddInt64(&inFlight, -1)
+
+	// Reject the requests beyond the given concurrency, like an overloaded
+	// backend, with the given Retry-After if any.
+	if maxConcurrency := r.URL.Query().Get("maxConcurrency"); maxConcurrency != "" {
+		if parsed, _ := strconv.ParseInt(maxConcurrency, 10, 64); concurrency > parsed {
+			if retryAfter := r.URL.Query().Get("retryAfter"); retryAfter != "" {
+				w.Header().Set("Retry-After", retryAfter)
+			}
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprint(w, test.OverloadedBody)
+			return
+		}
+	}
+
 	// Sleep for a set amount of time before sending headers.
 	if initialTimeout := r.URL.Query().Get("initialTimeout"); initialTimeout != "" {
 		parsed, _ := strconv.Atoi(initialTimeout)
 		time.Sleep(time.Duration(parsed) * time.Millisecond)
 	}
 
-	w.WriteHeader(http.StatusOK)
+	// Read the whole request body before answering, like a container receiving
+	// a request which was buffered while it was starting.
+	n, _ := io.Copy(ioutil.Discard, r.Body)
+	w.Header().Set(test.RequestBodyLengthHeader, strconv.FormatInt(n, 10))
+
+	// Stream Server-Sent Events, as many as requested.
+	if events := r.URL.Query().Get("events"); events != "" {
+		count, _ := strconv.Atoi(events)
+		interval, _ := strconv.Atoi(r.URL.Query().Get("interval"))
+		if err := sse.Stream(w, r, count, time.Duration(interval)*time.Millisecond); err != nil {
+			log.Print("Failed to stream the events: ", err)
+		}
+		return
+	}
+
+	if retryAfter := r.URL.Query().Get("retryAfter"); retryAfter != "" {
+		w.Header().Set("Retry-After", retryAfter)
+	}
+	for _, cookie := range r.URL.Query()["setCookie"] {
+		w.Header().Add("Set-Cookie", cookie)
+	}
+	status := http.StatusOK
+	if s := r.URL.Query().Get("status"); s != "" {
+		status, _ = strconv.Atoi(s)
+	}
+	w.WriteHeader(status)
 
 	// Explicitly flush the already written data to trigger (or not)
 	// the time-to-first-byte timeout.
@@ -46,10 +46,19 @@ const (
 
 // util.go provides shared utilities methods across knative serving test
 
+// SetupHook, if not nil, is called by Setup with each test before creating
+// its clients, e.g. for the conformance runners to throttle the tests or to
+// bound their duration.
+var SetupHook func(t testing.TB)
+
 // Setup creates client to run Knative Service requests
 func Setup(t testing.TB) *Clients {
 	t.Helper()
 
+	if SetupHook != nil {
+		SetupHook(t)
+	}
+
 	cancel := logstream.Start(t)
 	t.Cleanup(cancel)
 